@@ -30,23 +30,24 @@ import (
 // AddrManager provides a concurrency safe address manager for caching potential
 // peers on the bitcoin network.
 type AddrManager struct {
-	mtx            sync.RWMutex
-	peersFile      string
-	lookupFunc     func(string) ([]net.IP, error)
-	rand           *rand.Rand
-	key            [32]byte
-	addrIndex      map[string]*KnownAddress // address key to ka for all addrs.
-	addrNew        [newBucketCount]map[string]*KnownAddress
-	addrTried      [triedBucketCount]*list.List
-	started        int32
-	shutdown       int32
-	wg             sync.WaitGroup
-	quit           chan struct{}
-	nTried         int
-	nNew           int
-	lamtx          sync.Mutex
-	localAddresses map[string]*localAddress
-	version        int
+	mtx             sync.RWMutex
+	peersFile       string
+	lookupFunc      func(string) ([]net.IP, error)
+	rand            *rand.Rand
+	key             [32]byte
+	addrIndex       map[string]*KnownAddress // address key to ka for all addrs.
+	addrNew         [newBucketCount]map[string]*KnownAddress
+	addrTried       [triedBucketCount]*list.List
+	triedCollisions map[string]*KnownAddress // address key to ka.
+	started         int32
+	shutdown        int32
+	wg              sync.WaitGroup
+	quit            chan struct{}
+	nTried          int
+	nNew            int
+	lamtx           sync.Mutex
+	localAddresses  map[string]*localAddress
+	version         int
 }
 
 type serializedKnownAddress struct {
@@ -157,6 +158,25 @@ const (
 	// will share with a call to AddressCache.
 	getAddrPercent = 23
 
+	// maxTriedCollisions is the maximum number of addresses which collide
+	// with an address in a full tried bucket that are kept while the
+	// eviction candidates are tested.
+	maxTriedCollisions = 10
+
+	// triedCollisionRecentSuccess is the duration within which an eviction
+	// candidate of a tried bucket must have connected successfully to be
+	// kept instead of the colliding address.
+	triedCollisionRecentSuccess = 4 * time.Hour
+
+	// triedCollisionTestTimeout is the duration after an attempt to connect
+	// to an eviction candidate after which the test is considered to have
+	// failed if it didn't succeed.
+	triedCollisionTestTimeout = time.Minute
+
+	// triedCollisionTimeout is the duration after which a collision whose
+	// eviction candidate wasn't tested is resolved by evicting it.
+	triedCollisionTimeout = 40 * time.Minute
+
 	// serialisationVersion is the current version of the on-disk format.
 	serialisationVersion = 2
 )
@@ -708,6 +728,7 @@ func (a *AddrManager) getAddresses() []*wire.NetAddressV2 {
 func (a *AddrManager) reset() {
 
 	a.addrIndex = make(map[string]*KnownAddress)
+	a.triedCollisions = make(map[string]*KnownAddress)
 
 	// fill key with bytes from a good random source.
 	io.ReadFull(crand.Reader, a.key[:])
@@ -827,34 +848,56 @@ func (a *AddrManager) GetAddress() *KnownAddress {
 			}
 			factor *= 1.2
 		}
-	} else {
-		// new node.
-		// XXX use a closure/function to avoid repeating this.
-		large := 1 << 30
-		factor := 1.0
-		for {
-			// Pick a random bucket.
-			bucket := a.rand.Intn(len(a.addrNew))
-			if len(a.addrNew[bucket]) == 0 {
-				continue
-			}
-			// Then, a random entry in it.
-			var ka *KnownAddress
-			nth := a.rand.Intn(len(a.addrNew[bucket]))
-			for _, value := range a.addrNew[bucket] {
-				if nth == 0 {
-					ka = value
-				}
-				nth--
-			}
-			randval := a.rand.Intn(large)
-			if float64(randval) < (factor * ka.chance() * float64(large)) {
-				log.Tracef("Selected %v from new bucket",
-					NetAddressKey(ka.na))
-				return ka
+	}
+
+	return a.getNewAddress()
+}
+
+// GetNewAddress returns a single address from the new table, which holds the
+// addresses that have not been connected to successfully yet.  It is used to
+// pick the addresses to test with feeler connections.  It returns nil when
+// the new table is empty.
+func (a *AddrManager) GetNewAddress() *KnownAddress {
+	// Protect concurrent access.
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if a.nNew == 0 {
+		return nil
+	}
+	return a.getNewAddress()
+}
+
+// getNewAddress returns a random address from the new table with preference
+// given to ones that have not been used recently.  The new table must not be
+// empty.
+//
+// This function MUST be called with the address manager lock held.
+func (a *AddrManager) getNewAddress() *KnownAddress {
+	large := 1 << 30
+	factor := 1.0
+	for {
+		// Pick a random bucket.
+		bucket := a.rand.Intn(len(a.addrNew))
+		if len(a.addrNew[bucket]) == 0 {
+			continue
+		}
+		// Then, a random entry in it.
+		var ka *KnownAddress
+		nth := a.rand.Intn(len(a.addrNew[bucket]))
+		for _, value := range a.addrNew[bucket] {
+			if nth == 0 {
+				ka = value
 			}
-			factor *= 1.2
+			nth--
 		}
+		randval := a.rand.Intn(large)
+		if float64(randval) < (factor * ka.chance() * float64(large)) {
+			log.Tracef("Selected %v from new bucket",
+				NetAddressKey(ka.na))
+			return ka
+		}
+		factor *= 1.2
 	}
 }
 
@@ -910,6 +953,11 @@ func (a *AddrManager) Connected(addr *wire.NetAddressV2) {
 // Good marks the given address as good.  To be called after a successful
 // connection and version exchange.  If the address is unknown to the address
 // manager it will be ignored.
+//
+// An address of the new table is moved to the tried table.  When its tried
+// bucket is full, the address to be evicted is tested first, so the colliding
+// address is kept in the new table until ResolveCollisions resolves the
+// collision.
 func (a *AddrManager) Good(addr *wire.NetAddressV2) {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
@@ -933,11 +981,33 @@ func (a *AddrManager) Good(addr *wire.NetAddressV2) {
 		return
 	}
 
-	// ok, need to move it to tried.
+	// Rather than evicting an address which might still be good, record
+	// the collision so the eviction candidate can be tested first.
+	bucket := a.getTriedBucket(ka.na)
+	if a.addrTried[bucket].Len() >= triedBucketSize {
+		addrKey := NetAddressKey(ka.na)
+		if _, ok := a.triedCollisions[addrKey]; !ok &&
+			len(a.triedCollisions) < maxTriedCollisions {
+
+			log.Tracef("Tried bucket for %s is full, testing "+
+				"eviction candidate first", addrKey)
+			a.triedCollisions[addrKey] = ka
+		}
+		return
+	}
+
+	a.moveToTried(ka)
+}
 
+// moveToTried moves the given address of the new table to the tried table,
+// evicting the oldest address of its tried bucket back to the new table if
+// there is no room.
+//
+// This function MUST be called with the address manager lock held.
+func (a *AddrManager) moveToTried(ka *KnownAddress) {
 	// remove from all new buckets.
 	// record one of the buckets in question and call it the `first'
-	addrKey := NetAddressKey(addr)
+	addrKey := NetAddressKey(ka.na)
 	oldBucket := -1
 	for i := range a.addrNew {
 		// we check for existence so we can record the first one
@@ -998,6 +1068,85 @@ func (a *AddrManager) Good(addr *wire.NetAddressV2) {
 	a.addrNew[newBucket][rmkey] = rmka
 }
 
+// ResolveCollisions resolves the collisions of addresses which were marked as
+// good while their tried bucket was full.  The eviction candidate of a
+// collision is kept when it connected successfully recently.  Otherwise, it is
+// evicted in favor of the colliding address once a test of it failed or when
+// it hasn't been tested for too long.  Collisions whose eviction candidate is
+// still being tested are left to be resolved later.
+func (a *AddrManager) ResolveCollisions() {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	now := time.Now()
+	for addrKey, ka := range a.triedCollisions {
+		// Drop collisions of addresses which were removed or moved to
+		// the tried table in the meantime.
+		if a.addrIndex[addrKey] != ka || ka.tried {
+			delete(a.triedCollisions, addrKey)
+			continue
+		}
+
+		// There is nothing to evict if room was made in the meantime.
+		bucket := a.getTriedBucket(ka.na)
+		if a.addrTried[bucket].Len() < triedBucketSize {
+			a.moveToTried(ka)
+			delete(a.triedCollisions, addrKey)
+			continue
+		}
+
+		old := a.pickTried(bucket).Value.(*KnownAddress)
+		switch {
+		// Keep the eviction candidate when it is known to be good.
+		case now.Sub(old.lastsuccess) < triedCollisionRecentSuccess:
+			log.Tracef("Keeping %s in tried over %s",
+				NetAddressKey(old.na), addrKey)
+			delete(a.triedCollisions, addrKey)
+
+		// Evict the eviction candidate once the test of it failed.  It
+		// is still being tested otherwise.
+		case now.Sub(old.lastattempt) < triedCollisionRecentSuccess:
+			if now.Sub(old.lastattempt) > triedCollisionTestTimeout {
+				a.moveToTried(ka)
+				delete(a.triedCollisions, addrKey)
+			}
+
+		// Evict the eviction candidate when it wasn't tested in time.
+		case now.Sub(ka.lastsuccess) > triedCollisionTimeout:
+			a.moveToTried(ka)
+			delete(a.triedCollisions, addrKey)
+		}
+	}
+}
+
+// SelectTriedCollision returns the address in the tried table which would be
+// evicted by a random pending collision, so it can be tested before it is.  It
+// returns nil when there are no collisions to resolve.
+func (a *AddrManager) SelectTriedCollision() *KnownAddress {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if len(a.triedCollisions) == 0 {
+		return nil
+	}
+
+	var ka *KnownAddress
+	nth := a.rand.Intn(len(a.triedCollisions))
+	for _, value := range a.triedCollisions {
+		if nth == 0 {
+			ka = value
+			break
+		}
+		nth--
+	}
+
+	bucket := a.getTriedBucket(ka.na)
+	if a.addrTried[bucket].Len() < triedBucketSize {
+		return nil
+	}
+	return a.pickTried(bucket).Value.(*KnownAddress)
+}
+
 // SetServices sets the services for the giiven address to the provided value.
 func (a *AddrManager) SetServices(addr *wire.NetAddressV2, services wire.ServiceFlag) {
 	a.mtx.Lock()
@@ -198,3 +198,103 @@ func TestAddrManagerV1ToV2(t *testing.T) {
 	addrMgr.loadPeers()
 	assertAddrs(t, addrMgr, expectedAddrs)
 }
+
+// TestTriedCollisions ensures addresses colliding with an address in a full
+// tried bucket are only moved to the tried table once the eviction candidate
+// was tested unsuccessfully or wasn't tested in time.
+func TestTriedCollisions(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	tests := []struct {
+		name        string
+		lastSuccess time.Time // of the eviction candidate
+		lastAttempt time.Time // of the eviction candidate
+		goodSince   time.Duration
+		wantEvicted bool
+		wantPending bool
+	}{
+		{
+			name:        "recent success",
+			lastSuccess: now.Add(-time.Hour),
+			lastAttempt: now.Add(-time.Hour),
+		},
+		{
+			name:        "test in progress",
+			lastAttempt: now,
+			wantPending: true,
+		},
+		{
+			name:        "test failed",
+			lastAttempt: now.Add(-2 * time.Minute),
+			wantEvicted: true,
+		},
+		{
+			name:        "untested",
+			wantPending: true,
+		},
+		{
+			name:        "untested timeout",
+			goodSince:   triedCollisionTimeout + time.Minute,
+			wantEvicted: true,
+		},
+	}
+
+	for _, test := range tests {
+		addrMgr := New("testtriedcollisions", nil)
+		addr, err := addrMgr.DeserializeNetAddress("173.194.115.66:8333",
+			wire.SFNodeNetwork)
+		if err != nil {
+			t.Fatalf("%s: unable to deserialize address: %v",
+				test.name, err)
+		}
+		addrMgr.AddAddress(addr, addr)
+
+		// Fill the tried bucket of the address.  The first entry is
+		// the oldest one, which makes it the eviction candidate.
+		bucket := addrMgr.getTriedBucket(addr)
+		var old *KnownAddress
+		for i := 0; i < triedBucketSize; i++ {
+			na := wire.NetAddressV2FromBytes(
+				now.Add(time.Duration(i-triedBucketSize)*time.Minute),
+				wire.SFNodeNetwork, net.IPv4(10, 0, byte(i>>8), byte(i)),
+				8333,
+			)
+			ka := &KnownAddress{na: na, srcAddr: na, tried: true}
+			if i == 0 {
+				old = ka
+			}
+			addrMgr.addrTried[bucket].PushBack(ka)
+			addrMgr.nTried++
+		}
+
+		addrMgr.Good(addr)
+		ka := addrMgr.find(addr)
+		if ka.tried {
+			t.Fatalf("%s: address evicted without a test", test.name)
+		}
+		if got := addrMgr.SelectTriedCollision(); got != old {
+			t.Fatalf("%s: SelectTriedCollision: got %v, want %v",
+				test.name, got, old)
+		}
+
+		old.lastsuccess = test.lastSuccess
+		old.lastattempt = test.lastAttempt
+		ka.lastsuccess = now.Add(-test.goodSince)
+		addrMgr.ResolveCollisions()
+
+		if ka.tried != test.wantEvicted {
+			t.Fatalf("%s: got tried %v, want %v", test.name,
+				ka.tried, test.wantEvicted)
+		}
+		if old.tried == test.wantEvicted {
+			t.Fatalf("%s: got eviction candidate tried %v, want %v",
+				test.name, old.tried, !test.wantEvicted)
+		}
+		pending := addrMgr.SelectTriedCollision() != nil
+		if pending != test.wantPending {
+			t.Fatalf("%s: got pending collision %v, want %v",
+				test.name, pending, test.wantPending)
+		}
+	}
+}
@@ -369,6 +369,34 @@ func TestGetAddress(t *testing.T) {
 	}
 }
 
+func TestGetNewAddress(t *testing.T) {
+	n := addrmgr.New("testgetnewaddress", lookupFunc)
+
+	// Get an address from an empty set (should error)
+	if rv := n.GetNewAddress(); rv != nil {
+		t.Errorf("GetNewAddress failed: got: %v want: %v\n", rv, nil)
+	}
+
+	// Add a new address and get it
+	err := n.AddAddressByIP(someIP + ":8333")
+	if err != nil {
+		t.Fatalf("Adding address failed: %v", err)
+	}
+	ka := n.GetNewAddress()
+	if ka == nil {
+		t.Fatalf("Did not get an address where there is one in the pool")
+	}
+	if ka.NetAddress().Addr.String() != someIP {
+		t.Errorf("Wrong IP: got %v, want %v", ka.NetAddress().Addr.String(), someIP)
+	}
+
+	// Mark this as a good address, which moves it out of the new table
+	n.Good(ka.NetAddress())
+	if rv := n.GetNewAddress(); rv != nil {
+		t.Errorf("GetNewAddress failed: got: %v want: %v\n", rv, nil)
+	}
+}
+
 func TestGetBestLocalAddress(t *testing.T) {
 	localAddrs := []wire.NetAddressV2{
 		*wire.NetAddressV2FromBytes(
@@ -34,5 +34,11 @@ provided by the caller about connected, known good, and attempted addresses to
 periodically purge peers which no longer appear to be good peers as well as
 bias the selection toward known good peers.  The general idea is to make a best
 effort at only providing usable addresses.
+
+Addresses which were never connected to successfully are kept in a separate
+table from the ones which were, and they can be tested with short-lived feeler
+connections to move them over.  When there is no room for an address in the
+table of known good addresses, the address which would be evicted is tested
+first, so known good addresses can't easily be flushed out by an attacker.
 */
 package addrmgr
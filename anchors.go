@@ -0,0 +1,60 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+)
+
+// anchorsFilename is the name of the file in the data directory the addresses
+// of the block-relay-only peers are saved to on shutdown.
+const anchorsFilename = "anchors.json"
+
+// writeAnchors saves the passed addresses of block-relay-only peers to the
+// anchors file at the passed path so they can be reconnected on the next
+// startup.
+func writeAnchors(path string, addrs []string) error {
+	data, err := json.Marshal(addrs)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// readAnchors returns the addresses saved to the anchors file at the passed
+// path, if any.  The file is removed afterwards so the anchors are only
+// reconnected once, which avoids reconnecting to the same peers after a crash
+// they might have caused.
+func readAnchors(path string) ([]net.Addr, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, err
+	}
+
+	var addrStrs []string
+	if err := json.Unmarshal(data, &addrStrs); err != nil {
+		return nil, err
+	}
+	addrs := make([]net.Addr, 0, len(addrStrs))
+	for _, addrStr := range addrStrs {
+		addr, err := addrStringToNetAddr(addrStr)
+		if err != nil {
+			srvrLog.Debugf("Skipping invalid anchor %s: %v", addrStr,
+				err)
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
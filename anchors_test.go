@@ -0,0 +1,53 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestAnchors ensures the anchors survive a round trip through the anchors
+// file, which is only read once.
+func TestAnchors(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "btcd")
+	if err != nil {
+		t.Fatalf("Failed creating a temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, anchorsFilename)
+
+	// A missing file results in no anchors.
+	addrs, err := readAnchors(path)
+	if err != nil || len(addrs) != 0 {
+		t.Fatalf("readAnchors: got %v (err %v), want no anchors", addrs,
+			err)
+	}
+
+	want := []string{"127.0.0.1:8333", "[::1]:18333"}
+	if err := writeAnchors(path, append(want, "invalid")); err != nil {
+		t.Fatalf("writeAnchors: unexpected error: %v", err)
+	}
+	addrs, err = readAnchors(path)
+	if err != nil {
+		t.Fatalf("readAnchors: unexpected error: %v", err)
+	}
+	if len(addrs) != len(want) {
+		t.Fatalf("readAnchors: got %d anchors, want %d", len(addrs),
+			len(want))
+	}
+	for i, addr := range addrs {
+		if addr.String() != want[i] {
+			t.Fatalf("readAnchors: got anchor %v, want %v", addr,
+				want[i])
+		}
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("readAnchors: anchors file was not removed")
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// banlistFilename is the name of the file in the data directory the
+	// banned IPs and subnets are saved to.
+	banlistFilename = "banlist.json"
+
+	// banReasonMisbehaving is the reason recorded for peers which are
+	// banned since their ban score exceeded the ban threshold.
+	banReasonMisbehaving = "node misbehaving"
+
+	// banReasonManual is the reason recorded for IPs and subnets which are
+	// banned with the setban RPC.
+	banReasonManual = "manually added"
+)
+
+// banEntry is a banned IP or subnet along with the time the ban was created,
+// the time it expires and the reason for it.
+type banEntry struct {
+	Subnet  string `json:"subnet"`
+	Created int64  `json:"created"`
+	Until   int64  `json:"until"`
+	Reason  string `json:"reason"`
+
+	ipnet *net.IPNet
+}
+
+// expired returns whether the ban expired at the passed time.
+func (e *banEntry) expired(now time.Time) bool {
+	return now.Unix() >= e.Until
+}
+
+// banList maintains the banned IPs and subnets and persists them to a file so
+// the bans survive restarts.  It is safe for concurrent access.
+type banList struct {
+	mtx  sync.Mutex
+	path string
+	bans map[string]*banEntry
+}
+
+// newBanList returns a new, empty ban list which is persisted to the file at
+// the passed path.
+func newBanList(path string) *banList {
+	return &banList{
+		path: path,
+		bans: make(map[string]*banEntry),
+	}
+}
+
+// parseSubnet parses an IP network in CIDR notation or a single IP, which is
+// treated as a /32 or /128 network for IPv4 and IPv6 respectively.
+func parseSubnet(s string) (*net.IPNet, error) {
+	if _, ipnet, err := net.ParseCIDR(s); err == nil {
+		return ipnet, nil
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP or subnet %q", s)
+	}
+	bits := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bits = 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// load reads the bans saved to the ban list file, if any, skipping the ones
+// which already expired.  It returns the number of loaded bans.
+func (bl *banList) load() (int, error) {
+	data, err := ioutil.ReadFile(bl.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var entries []*banEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return 0, err
+	}
+
+	bl.mtx.Lock()
+	defer bl.mtx.Unlock()
+
+	now := time.Now()
+	for _, e := range entries {
+		ipnet, err := parseSubnet(e.Subnet)
+		if err != nil {
+			srvrLog.Debugf("Skipping invalid ban of %s: %v", e.Subnet,
+				err)
+			continue
+		}
+		if e.expired(now) {
+			continue
+		}
+		e.ipnet = ipnet
+		e.Subnet = ipnet.String()
+		bl.bans[e.Subnet] = e
+	}
+	return len(bl.bans), nil
+}
+
+// save writes the bans to the ban list file.  A temporary file is written
+// first and then renamed so a crash can't leave a truncated file behind.
+//
+// This function MUST be called with the ban list lock held.
+func (bl *banList) save() error {
+	entries := make([]*banEntry, 0, len(bl.bans))
+	for _, e := range bl.bans {
+		entries = append(entries, e)
+	}
+	sortBanEntries(entries)
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	tmpPath := bl.path + ".new"
+	if err := ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, bl.path)
+}
+
+// sortBanEntries sorts the passed bans in the order they were created.
+func sortBanEntries(entries []*banEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Created != entries[j].Created {
+			return entries[i].Created < entries[j].Created
+		}
+		return entries[i].Subnet < entries[j].Subnet
+	})
+}
+
+// sweep removes the expired bans and returns whether any were removed.
+//
+// This function MUST be called with the ban list lock held.
+func (bl *banList) sweep(now time.Time) bool {
+	swept := false
+	for subnet, e := range bl.bans {
+		if e.expired(now) {
+			delete(bl.bans, subnet)
+			swept = true
+		}
+	}
+	return swept
+}
+
+// Ban bans the passed subnet until the passed time for the passed reason and
+// saves the ban list.  An existing ban of the subnet is only extended, so a
+// shorter ban never replaces a longer one.
+func (bl *banList) Ban(ipnet *net.IPNet, until time.Time, reason string) error {
+	bl.mtx.Lock()
+	defer bl.mtx.Unlock()
+
+	now := time.Now()
+	bl.sweep(now)
+
+	subnet := ipnet.String()
+	if e, ok := bl.bans[subnet]; ok && e.Until >= until.Unix() {
+		return nil
+	}
+	bl.bans[subnet] = &banEntry{
+		Subnet:  subnet,
+		Created: now.Unix(),
+		Until:   until.Unix(),
+		Reason:  reason,
+		ipnet:   ipnet,
+	}
+	return bl.save()
+}
+
+// Unban removes the ban of the passed subnet and saves the ban list.  It
+// returns false when the subnet is not banned.
+func (bl *banList) Unban(ipnet *net.IPNet) (bool, error) {
+	bl.mtx.Lock()
+	defer bl.mtx.Unlock()
+
+	bl.sweep(time.Now())
+
+	subnet := ipnet.String()
+	if _, ok := bl.bans[subnet]; !ok {
+		return false, nil
+	}
+	delete(bl.bans, subnet)
+	return true, bl.save()
+}
+
+// Clear removes all bans and saves the ban list.
+func (bl *banList) Clear() error {
+	bl.mtx.Lock()
+	defer bl.mtx.Unlock()
+
+	bl.bans = make(map[string]*banEntry)
+	return bl.save()
+}
+
+// IsSubnetBanned returns whether the passed subnet itself is banned, as
+// opposed to being included in a banned subnet.
+func (bl *banList) IsSubnetBanned(ipnet *net.IPNet) bool {
+	bl.mtx.Lock()
+	defer bl.mtx.Unlock()
+
+	e, ok := bl.bans[ipnet.String()]
+	return ok && !e.expired(time.Now())
+}
+
+// IsBanned returns whether the passed IP is included in a banned subnet along
+// with the time the longest of the matching bans expires.
+func (bl *banList) IsBanned(ip net.IP) (time.Time, bool) {
+	bl.mtx.Lock()
+	defer bl.mtx.Unlock()
+
+	now := time.Now()
+	var until int64
+	for _, e := range bl.bans {
+		if !e.expired(now) && e.ipnet.Contains(ip) && e.Until > until {
+			until = e.Until
+		}
+	}
+	if until == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(until, 0), true
+}
+
+// Entries returns copies of the bans which didn't expire yet in the order they
+// were created.  The expired bans are removed from the ban list.
+func (bl *banList) Entries() ([]*banEntry, error) {
+	bl.mtx.Lock()
+	defer bl.mtx.Unlock()
+
+	var err error
+	if bl.sweep(time.Now()) {
+		err = bl.save()
+	}
+
+	entries := make([]*banEntry, 0, len(bl.bans))
+	for _, e := range bl.bans {
+		entry := *e
+		entries = append(entries, &entry)
+	}
+	sortBanEntries(entries)
+	return entries, err
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestParseSubnet ensures IPs and subnets are parsed into the expected
+// networks.
+func TestParseSubnet(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "192.168.0.1", want: "192.168.0.1/32"},
+		{in: "192.168.0.1/24", want: "192.168.0.0/24"},
+		{in: "::1", want: "::1/128"},
+		{in: "fd00::/16", want: "fd00::/16"},
+		{in: "::ffff:10.0.0.1", want: "10.0.0.1/32"},
+		{in: "192.168.0.1:8333", wantErr: true},
+		{in: "example.com", wantErr: true},
+	}
+
+	for _, test := range tests {
+		ipnet, err := parseSubnet(test.in)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", test.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.in, err)
+			continue
+		}
+		if ipnet.String() != test.want {
+			t.Errorf("%s: got %s, want %s", test.in, ipnet, test.want)
+		}
+	}
+}
+
+// TestBanList ensures bans of IPs and subnets apply to the IPs they include
+// and survive reloading the ban list from its file.
+func TestBanList(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "banlist")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, banlistFilename)
+
+	mustParseSubnet := func(s string) *net.IPNet {
+		ipnet, err := parseSubnet(s)
+		if err != nil {
+			t.Fatalf("unable to parse %s: %v", s, err)
+		}
+		return ipnet
+	}
+
+	now := time.Now()
+	bl := newBanList(path)
+	subnet := mustParseSubnet("10.0.0.0/8")
+	if err := bl.Ban(subnet, now.Add(time.Hour), banReasonManual); err != nil {
+		t.Fatalf("unable to ban subnet: %v", err)
+	}
+	host := mustParseSubnet("192.168.0.1")
+	err = bl.Ban(host, now.Add(2*time.Hour), banReasonMisbehaving)
+	if err != nil {
+		t.Fatalf("unable to ban host: %v", err)
+	}
+
+	// A shorter ban doesn't replace a longer one.
+	err = bl.Ban(host, now.Add(time.Minute), banReasonManual)
+	if err != nil {
+		t.Fatalf("unable to ban host: %v", err)
+	}
+
+	checkBanned := func(bl *banList, ip string, want bool) {
+		t.Helper()
+		if _, banned := bl.IsBanned(net.ParseIP(ip)); banned != want {
+			t.Fatalf("%s: got banned %v, want %v", ip, banned, want)
+		}
+	}
+	checkBanned(bl, "10.1.2.3", true)
+	checkBanned(bl, "192.168.0.1", true)
+	checkBanned(bl, "192.168.0.2", false)
+	if !bl.IsSubnetBanned(subnet) {
+		t.Fatalf("subnet %s not banned", subnet)
+	}
+	if bl.IsSubnetBanned(mustParseSubnet("10.0.0.1")) {
+		t.Fatal("IP in a banned subnet reported as banned subnet")
+	}
+
+	// The bans are loaded from the file by a new ban list.
+	loaded := newBanList(path)
+	if n, err := loaded.load(); err != nil || n != 2 {
+		t.Fatalf("got %d loaded bans (err %v), want 2", n, err)
+	}
+	checkBanned(loaded, "10.1.2.3", true)
+	checkBanned(loaded, "192.168.0.1", true)
+	entries, err := loaded.Entries()
+	if err != nil {
+		t.Fatalf("unable to list bans: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d bans, want 2", len(entries))
+	}
+	for _, e := range entries {
+		switch e.Subnet {
+		case "10.0.0.0/8":
+			if e.Reason != banReasonManual {
+				t.Fatalf("%s: got reason %q", e.Subnet, e.Reason)
+			}
+		case "192.168.0.1/32":
+			if e.Reason != banReasonMisbehaving ||
+				e.Until != now.Add(2*time.Hour).Unix() {
+
+				t.Fatalf("%s: got reason %q until %d", e.Subnet,
+					e.Reason, e.Until)
+			}
+		default:
+			t.Fatalf("unexpected ban of %s", e.Subnet)
+		}
+	}
+
+	// Unbanning only removes the exact subnet.
+	if unbanned, err := loaded.Unban(mustParseSubnet("10.0.0.1")); err != nil ||
+		unbanned {
+
+		t.Fatalf("unbanned IP in banned subnet (err %v)", err)
+	}
+	if unbanned, err := loaded.Unban(subnet); err != nil || !unbanned {
+		t.Fatalf("unable to unban %s (err %v)", subnet, err)
+	}
+	checkBanned(loaded, "10.1.2.3", false)
+
+	// Clearing the bans removes them from the file as well.
+	if err := loaded.Clear(); err != nil {
+		t.Fatalf("unable to clear bans: %v", err)
+	}
+	checkBanned(loaded, "192.168.0.1", false)
+	if n, err := newBanList(path).load(); err != nil || n != 0 {
+		t.Fatalf("got %d loaded bans (err %v), want 0", n, err)
+	}
+}
+
+// TestBanListExpiry ensures expired bans no longer apply and are dropped when
+// the ban list is loaded.
+func TestBanListExpiry(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "banlist")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, banlistFilename)
+
+	bl := newBanList(path)
+	ipnet, err := parseSubnet("192.168.0.1")
+	if err != nil {
+		t.Fatalf("unable to parse subnet: %v", err)
+	}
+	err = bl.Ban(ipnet, time.Now().Add(-time.Second), banReasonManual)
+	if err != nil {
+		t.Fatalf("unable to ban: %v", err)
+	}
+	if _, banned := bl.IsBanned(ipnet.IP); banned {
+		t.Fatal("expired ban still applies")
+	}
+	if n, err := newBanList(path).load(); err != nil || n != 0 {
+		t.Fatalf("got %d loaded bans (err %v), want 0", n, err)
+	}
+	entries, err := bl.Entries()
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("got %d bans (err %v), want 0", len(entries), err)
+	}
+}
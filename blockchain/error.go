@@ -220,6 +220,11 @@ const (
 	// current chain tip. This is not a block validation rule, but is required
 	// for block proposals submitted via getblocktemplate RPC.
 	ErrPrevBlockNotBest
+
+	// ErrBadSignetSolution indicates the signet solution in the witness
+	// commitment of a block is malformed or does not satisfy the signet
+	// challenge as defined by BIP0325.
+	ErrBadSignetSolution
 )
 
 // Map of ErrorCode values back to their constant names for pretty printing.
@@ -267,6 +272,7 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrPreviousBlockUnknown:      "ErrPreviousBlockUnknown",
 	ErrInvalidAncestorBlock:      "ErrInvalidAncestorBlock",
 	ErrPrevBlockNotBest:          "ErrPrevBlockNotBest",
+	ErrBadSignetSolution:         "ErrBadSignetSolution",
 }
 
 // String returns the ErrorCode as a human-readable name.
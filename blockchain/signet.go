@@ -0,0 +1,300 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// signetScriptFlags are the script flags the signet solution of a
+	// block is verified with as defined by BIP0325.
+	signetScriptFlags = txscript.ScriptBip16 |
+		txscript.ScriptVerifyWitness |
+		txscript.ScriptVerifyDERSignatures |
+		txscript.ScriptStrictMultiSig
+)
+
+var (
+	// SignetHeader is the 4-byte header which identifies the data push
+	// holding the signet solution within the witness commitment of a
+	// signet block as defined by BIP0325.
+	SignetHeader = []byte{0xec, 0xc7, 0xda, 0xa2}
+)
+
+// appendPush appends a canonical data push of the passed data to the passed
+// script.  Unlike txscript.ScriptBuilder, small integers are pushed as data
+// rather than with the small integer opcodes and no limit is imposed on the
+// size of the data.
+func appendPush(script, data []byte) []byte {
+	dataLen := len(data)
+	switch {
+	case dataLen == 0:
+		return append(script, txscript.OP_0)
+
+	case dataLen < txscript.OP_PUSHDATA1:
+		script = append(script, byte(dataLen))
+
+	case dataLen <= 0xff:
+		script = append(script, txscript.OP_PUSHDATA1, byte(dataLen))
+
+	case dataLen <= 0xffff:
+		var buf [2]byte
+		binary.LittleEndian.PutUint16(buf[:], uint16(dataLen))
+		script = append(script, txscript.OP_PUSHDATA2)
+		script = append(script, buf[:]...)
+
+	default:
+		var buf [4]byte
+		binary.LittleEndian.PutUint32(buf[:], uint32(dataLen))
+		script = append(script, txscript.OP_PUSHDATA4)
+		script = append(script, buf[:]...)
+	}
+	return append(script, data...)
+}
+
+// witnessCommitmentIndex returns the index of the output of the passed
+// coinbase transaction which holds the witness commitment, or -1 when there is
+// none.
+func witnessCommitmentIndex(coinbase *wire.MsgTx) int {
+	for i := len(coinbase.TxOut) - 1; i >= 0; i-- {
+		pkScript := coinbase.TxOut[i].PkScript
+		if len(pkScript) >= CoinbaseWitnessPkScriptLength &&
+			bytes.HasPrefix(pkScript, WitnessMagicBytes) {
+
+			return i
+		}
+	}
+	return -1
+}
+
+// extractSignetSolution returns the signet solution within the passed witness
+// commitment script, if any, along with the script with the data push holding
+// the solution replaced by a push of only the signet header.  All other
+// opcodes are retained verbatim, including pushes which are not canonically
+// encoded.  This is the form of the witness commitment that is committed to by
+// the solution.
+func extractSignetSolution(commitment []byte) ([]byte, []byte, error) {
+	var solution, stripped []byte
+	var found bool
+	var opStart int32
+	tokenizer := txscript.MakeScriptTokenizer(0, commitment)
+	for tokenizer.Next() {
+		// Only the first push which holds the signet header followed
+		// by some data counts as the solution.
+		data := tokenizer.Data()
+		if !found && len(data) > len(SignetHeader) &&
+			bytes.HasPrefix(data, SignetHeader) {
+
+			solution = data[len(SignetHeader):]
+			stripped = append(stripped, commitment[:opStart]...)
+			stripped = appendPush(stripped, SignetHeader)
+			stripped = append(stripped,
+				commitment[tokenizer.ByteIndex():]...)
+			found = true
+		}
+		opStart = tokenizer.ByteIndex()
+	}
+	if err := tokenizer.Err(); err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		return nil, commitment, nil
+	}
+	return solution, stripped, nil
+}
+
+// SerializeSignetSolution returns the signet solution which consists of the
+// passed signature script and witness in the format defined by BIP0325.
+func SerializeSignetSolution(sigScript []byte, witness wire.TxWitness) []byte {
+	var buf bytes.Buffer
+	_ = wire.WriteVarBytes(&buf, 0, sigScript)
+	_ = wire.WriteVarInt(&buf, 0, uint64(len(witness)))
+	for _, item := range witness {
+		_ = wire.WriteVarBytes(&buf, 0, item)
+	}
+	return buf.Bytes()
+}
+
+// deserializeSignetSolution returns the signature script and witness of the
+// passed signet solution.
+func deserializeSignetSolution(solution []byte) ([]byte, wire.TxWitness, error) {
+	r := bytes.NewReader(solution)
+	sigScript, err := wire.ReadVarBytes(r, 0, uint32(len(solution)),
+		"signet signature script")
+	if err != nil {
+		return nil, nil, err
+	}
+	numItems, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if numItems > uint64(r.Len()) {
+		return nil, nil, fmt.Errorf("signet witness has %d items which "+
+			"exceeds the remaining %d bytes", numItems, r.Len())
+	}
+	var witness wire.TxWitness
+	for i := uint64(0); i < numItems; i++ {
+		item, err := wire.ReadVarBytes(r, 0, uint32(len(solution)),
+			"signet witness item")
+		if err != nil {
+			return nil, nil, err
+		}
+		witness = append(witness, item)
+	}
+	if r.Len() != 0 {
+		return nil, nil, fmt.Errorf("signet solution has %d extraneous "+
+			"bytes", r.Len())
+	}
+	return sigScript, witness, nil
+}
+
+// AddSignetSolution appends a data push of the signet header followed by the
+// passed signet solution to the witness commitment of the passed coinbase
+// transaction.  Passing a nil solution only appends the signet header, which
+// is the form of the witness commitment the solution commits to, so a block
+// is signed by first adding a nil solution, signing the transaction returned
+// by SignetTxs and then replacing the nil solution with the actual one.
+func AddSignetSolution(coinbase *wire.MsgTx, solution []byte) error {
+	idx := witnessCommitmentIndex(coinbase)
+	if idx < 0 {
+		return ruleError(ErrBadSignetSolution, "coinbase transaction "+
+			"has no witness commitment")
+	}
+
+	data := make([]byte, 0, len(SignetHeader)+len(solution))
+	data = append(data, SignetHeader...)
+	data = append(data, solution...)
+	txOut := coinbase.TxOut[idx]
+	pkScript := make([]byte, len(txOut.PkScript), len(txOut.PkScript)+
+		len(data)+5)
+	copy(pkScript, txOut.PkScript)
+	txOut.PkScript = appendPush(pkScript, data)
+	return nil
+}
+
+// SignetTxs returns the virtual transactions defined by BIP0325 for the passed
+// block and signet challenge.  The first one has a single output with the
+// challenge as its script and commits to the block, while the second one
+// spends that output with the signet solution in the witness commitment of the
+// block, if any.  A block satisfies the challenge when the second transaction
+// is valid.
+func SignetTxs(block *wire.MsgBlock, challenge []byte) (*wire.MsgTx, *wire.MsgTx, error) {
+	if len(block.Transactions) == 0 {
+		return nil, nil, ruleError(ErrNoTransactions, "block does not "+
+			"contain any transactions")
+	}
+
+	// Signet blocks must have a witness commitment which is committed to
+	// with the solution, if any, removed.
+	coinbase := block.Transactions[0].Copy()
+	idx := witnessCommitmentIndex(coinbase)
+	if idx < 0 {
+		return nil, nil, ruleError(ErrBadSignetSolution, "coinbase "+
+			"transaction has no witness commitment")
+	}
+	solution, stripped, err := extractSignetSolution(
+		coinbase.TxOut[idx].PkScript)
+	if err != nil {
+		str := fmt.Sprintf("malformed witness commitment: %v", err)
+		return nil, nil, ruleError(ErrBadSignetSolution, str)
+	}
+	coinbase.TxOut[idx].PkScript = stripped
+
+	toSign := &wire.MsgTx{
+		Version: 0,
+		TxIn: []*wire.TxIn{{
+			Sequence: 0,
+		}},
+		TxOut: []*wire.TxOut{{
+			Value:    0,
+			PkScript: []byte{txscript.OP_RETURN},
+		}},
+		LockTime: 0,
+	}
+	if solution != nil {
+		sigScript, witness, err := deserializeSignetSolution(solution)
+		if err != nil {
+			str := fmt.Sprintf("malformed signet solution: %v", err)
+			return nil, nil, ruleError(ErrBadSignetSolution, str)
+		}
+		toSign.TxIn[0].SignatureScript = sigScript
+		toSign.TxIn[0].Witness = witness
+	}
+
+	// The merkle root committed to is the one of the block with the
+	// stripped coinbase transaction.
+	txns := make([]*btcutil.Tx, 0, len(block.Transactions))
+	txns = append(txns, btcutil.NewTx(coinbase))
+	for _, tx := range block.Transactions[1:] {
+		txns = append(txns, btcutil.NewTx(tx))
+	}
+	merkles := BuildMerkleTreeStore(txns, false)
+	merkleRoot := merkles[len(merkles)-1]
+
+	var blockData bytes.Buffer
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], uint32(block.Header.Version))
+	blockData.Write(buf[:])
+	blockData.Write(block.Header.PrevBlock[:])
+	blockData.Write(merkleRoot[:])
+	binary.LittleEndian.PutUint32(buf[:],
+		uint32(block.Header.Timestamp.Unix()))
+	blockData.Write(buf[:])
+
+	toSpendSigScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_0).AddData(blockData.Bytes()).Script()
+	if err != nil {
+		return nil, nil, err
+	}
+	toSpend := &wire.MsgTx{
+		Version: 0,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+				wire.MaxPrevOutIndex),
+			SignatureScript: toSpendSigScript,
+			Sequence:        0,
+		}},
+		TxOut: []*wire.TxOut{{
+			Value:    0,
+			PkScript: challenge,
+		}},
+		LockTime: 0,
+	}
+	toSpendHash := toSpend.TxHash()
+	toSign.TxIn[0].PreviousOutPoint = *wire.NewOutPoint(&toSpendHash, 0)
+
+	return toSpend, toSign, nil
+}
+
+// CheckSignetSolution ensures the signet solution of the passed block
+// satisfies the passed signet challenge as defined by BIP0325.
+func CheckSignetSolution(block *wire.MsgBlock, challenge []byte) error {
+	_, toSign, err := SignetTxs(block, challenge)
+	if err != nil {
+		return err
+	}
+
+	prevOutFetcher := txscript.NewCannedPrevOutputFetcher(challenge, 0)
+	sigHashes := txscript.NewTxSigHashes(toSign, prevOutFetcher)
+	vm, err := txscript.NewEngine(challenge, toSign, 0, signetScriptFlags,
+		nil, sigHashes, 0, prevOutFetcher)
+	if err == nil {
+		err = vm.Execute()
+	}
+	if err != nil {
+		str := fmt.Sprintf("signet solution does not satisfy the "+
+			"challenge: %v", err)
+		return ruleError(ErrBadSignetSolution, str)
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// newSignetTestBlock returns a block with a coinbase transaction which has a
+// witness commitment and another transaction for use in the signet tests.
+func newSignetTestBlock() *wire.MsgBlock {
+	commitment := append([]byte(nil), WitnessMagicBytes...)
+	commitment = append(commitment, bytes.Repeat([]byte{0x01}, 32)...)
+
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		SignatureScript: []byte{txscript.OP_1, txscript.OP_0},
+		Sequence:        wire.MaxTxInSequenceNum,
+	})
+	coinbase.AddTxOut(wire.NewTxOut(5000000000, []byte{txscript.OP_TRUE}))
+	coinbase.AddTxOut(wire.NewTxOut(0, commitment))
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{0x01}, 0),
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	tx.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}))
+
+	return &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   0x20000000,
+			PrevBlock: chainhash.Hash{0x02},
+			Timestamp: time.Unix(1700000000, 0),
+			Bits:      chaincfg.SigNetParams.PowLimitBits,
+		},
+		Transactions: []*wire.MsgTx{coinbase, tx},
+	}
+}
+
+// TestSignetSolution ensures blocks signed according to BIP0325 satisfy their
+// signet challenge while blocks with modified contents or malformed solutions
+// do not.
+func TestSignetSolution(t *testing.T) {
+	t.Parallel()
+
+	// Create a 1-of-2 multisig challenge like the one of the default
+	// signet.
+	keys := make(map[string]*btcec.PrivateKey)
+	var pubKeys []*btcutil.AddressPubKey
+	for i := 0; i < 2; i++ {
+		privKey, err := btcec.NewPrivateKey()
+		if err != nil {
+			t.Fatalf("unable to generate key: %v", err)
+		}
+		addr, err := btcutil.NewAddressPubKey(
+			privKey.PubKey().SerializeCompressed(),
+			&chaincfg.SigNetParams)
+		if err != nil {
+			t.Fatalf("unable to create address: %v", err)
+		}
+		pubKeys = append(pubKeys, addr)
+		if i == 1 {
+			keys[string(addr.ScriptAddress())] = privKey
+		}
+	}
+	challenge, err := txscript.MultiSigScript(pubKeys, 1)
+	if err != nil {
+		t.Fatalf("unable to create challenge: %v", err)
+	}
+
+	// signBlock signs the passed block by committing to it with a solution
+	// which only consists of the signet header and then adding the
+	// solution which spends the challenge.
+	signBlock := func(block *wire.MsgBlock) {
+		t.Helper()
+
+		coinbase := block.Transactions[0]
+		commitment := coinbase.TxOut[1].PkScript
+		if err := AddSignetSolution(coinbase, nil); err != nil {
+			t.Fatalf("unable to add signet header: %v", err)
+		}
+		_, toSign, err := SignetTxs(block, challenge)
+		if err != nil {
+			t.Fatalf("unable to create signet transactions: %v", err)
+		}
+		sigScript, err := txscript.SignTxOutput(&chaincfg.SigNetParams,
+			toSign, 0, challenge, txscript.SigHashAll,
+			txscript.KeyClosure(func(addr btcutil.Address) (*btcec.PrivateKey, bool, error) {
+				key, ok := keys[string(addr.ScriptAddress())]
+				if !ok {
+					return nil, false, errors.New("unknown key")
+				}
+				return key, true, nil
+			}), nil, nil)
+		if err != nil {
+			t.Fatalf("unable to sign block: %v", err)
+		}
+		coinbase.TxOut[1].PkScript = commitment
+		solution := SerializeSignetSolution(sigScript, nil)
+		if err := AddSignetSolution(coinbase, solution); err != nil {
+			t.Fatalf("unable to add signet solution: %v", err)
+		}
+	}
+
+	block := newSignetTestBlock()
+	signBlock(block)
+	if err := CheckSignetSolution(block, challenge); err != nil {
+		t.Fatalf("signed block does not satisfy challenge: %v", err)
+	}
+
+	// The nonce is not committed to, so the block can be solved after it
+	// was signed.
+	block.Header.Nonce++
+	if err := CheckSignetSolution(block, challenge); err != nil {
+		t.Fatalf("solved block does not satisfy challenge: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(block *wire.MsgBlock)
+	}{{
+		name: "modified timestamp",
+		modify: func(block *wire.MsgBlock) {
+			block.Header.Timestamp = block.Header.Timestamp.Add(
+				time.Second)
+		},
+	}, {
+		name: "modified previous block",
+		modify: func(block *wire.MsgBlock) {
+			block.Header.PrevBlock[0] ^= 0x01
+		},
+	}, {
+		name: "modified transaction",
+		modify: func(block *wire.MsgBlock) {
+			block.Transactions[1].TxOut[0].Value++
+		},
+	}, {
+		name: "extraneous solution data",
+		modify: func(block *wire.MsgBlock) {
+			coinbase := block.Transactions[0]
+			pkScript := coinbase.TxOut[1].PkScript
+			solution, _, _ := extractSignetSolution(pkScript)
+			solution = append(solution, 0x00)
+			coinbase.TxOut[1].PkScript =
+				pkScript[:CoinbaseWitnessPkScriptLength]
+			AddSignetSolution(coinbase, solution)
+		},
+	}, {
+		name: "missing solution",
+		modify: func(block *wire.MsgBlock) {
+			block.Transactions[0].TxOut[1].PkScript =
+				block.Transactions[0].TxOut[1].PkScript[:CoinbaseWitnessPkScriptLength]
+		},
+	}, {
+		name: "missing witness commitment",
+		modify: func(block *wire.MsgBlock) {
+			block.Transactions[0].TxOut = block.Transactions[0].TxOut[:1]
+		},
+	}}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := block.Serialize(&buf); err != nil {
+			t.Fatalf("unable to serialize block: %v", err)
+		}
+		var modified wire.MsgBlock
+		if err := modified.Deserialize(&buf); err != nil {
+			t.Fatalf("unable to deserialize block: %v", err)
+		}
+		test.modify(&modified)
+		err := CheckSignetSolution(&modified, challenge)
+		if !isRuleError(err, ErrBadSignetSolution) {
+			t.Fatalf("%s: unexpected error: got %v, want %v", test.name,
+				err, ErrBadSignetSolution)
+		}
+	}
+
+	// Blocks without a solution satisfy a trivial challenge.
+	block = newSignetTestBlock()
+	if err := CheckSignetSolution(block, []byte{txscript.OP_TRUE}); err != nil {
+		t.Fatalf("block does not satisfy trivial challenge: %v", err)
+	}
+}
+
+// TestExtractSignetSolution ensures the signet solution is extracted from the
+// first data push holding the signet header followed by some data and that
+// all other opcodes of the witness commitment are retained verbatim.
+func TestExtractSignetSolution(t *testing.T) {
+	t.Parallel()
+
+	header := []byte{0x04, 0xec, 0xc7, 0xda, 0xa2}
+	tests := []struct {
+		name         string
+		commitment   []byte
+		wantSolution []byte
+		wantStripped []byte
+		wantErr      bool
+	}{{
+		name:         "no solution",
+		commitment:   []byte{0x6a, 0x01, 0xaa},
+		wantStripped: []byte{0x6a, 0x01, 0xaa},
+	}, {
+		name:         "header only",
+		commitment:   append([]byte{0x6a}, header...),
+		wantStripped: append([]byte{0x6a}, header...),
+	}, {
+		name:         "solution",
+		commitment:   []byte{0x6a, 0x06, 0xec, 0xc7, 0xda, 0xa2, 0x01, 0x02},
+		wantSolution: []byte{0x01, 0x02},
+		wantStripped: append([]byte{0x6a}, header...),
+	}, {
+		name: "solution in non-canonical push",
+		commitment: []byte{0x6a, 0x4c, 0x05, 0xec, 0xc7, 0xda, 0xa2,
+			0x01},
+		wantSolution: []byte{0x01},
+		wantStripped: append([]byte{0x6a}, header...),
+	}, {
+		name: "non-canonical pushes retained",
+		commitment: []byte{0x6a, 0x4c, 0x01, 0xaa, 0x4d, 0x00, 0x00,
+			0x05, 0xec, 0xc7, 0xda, 0xa2, 0x03, 0x4c, 0x01, 0xbb,
+			0x51},
+		wantSolution: []byte{0x03},
+		wantStripped: append(append([]byte{0x6a, 0x4c, 0x01, 0xaa, 0x4d,
+			0x00, 0x00}, header...), 0x4c, 0x01, 0xbb, 0x51),
+	}, {
+		name: "only first solution extracted",
+		commitment: []byte{0x6a, 0x05, 0xec, 0xc7, 0xda, 0xa2, 0x01,
+			0x05, 0xec, 0xc7, 0xda, 0xa2, 0x02},
+		wantSolution: []byte{0x01},
+		wantStripped: append(append([]byte{0x6a}, header...), 0x05,
+			0xec, 0xc7, 0xda, 0xa2, 0x02),
+	}, {
+		name:       "truncated push",
+		commitment: []byte{0x6a, 0x05, 0xec, 0xc7, 0xda, 0xa2},
+		wantErr:    true,
+	}}
+	for _, test := range tests {
+		solution, stripped, err := extractSignetSolution(test.commitment)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !bytes.Equal(solution, test.wantSolution) {
+			t.Errorf("%s: got solution %x, want %x", test.name,
+				solution, test.wantSolution)
+		}
+		if !bytes.Equal(stripped, test.wantStripped) {
+			t.Errorf("%s: got stripped commitment %x, want %x",
+				test.name, stripped, test.wantStripped)
+		}
+	}
+}
+
+// isRuleError returns whether or not the passed error is a rule error with the
+// passed error code.
+func isRuleError(err error, code ErrorCode) bool {
+	rerr, ok := err.(RuleError)
+	return ok && rerr.ErrorCode == code
+}
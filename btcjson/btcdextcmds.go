@@ -76,6 +76,47 @@ func NewGenerateToAddressCmd(numBlocks int64, address string, maxTries *int64) *
 	}
 }
 
+// GenerateToDescriptorCmd defines the generatetodescriptor JSON-RPC command.
+type GenerateToDescriptorCmd struct {
+	NumBlocks  int64
+	Descriptor string
+	MaxTries   *int64 `jsonrpcdefault:"1000000"`
+}
+
+// NewGenerateToDescriptorCmd returns a new instance which can be used to issue
+// a generatetodescriptor JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGenerateToDescriptorCmd(numBlocks int64, descriptor string, maxTries *int64) *GenerateToDescriptorCmd {
+	return &GenerateToDescriptorCmd{
+		NumBlocks:  numBlocks,
+		Descriptor: descriptor,
+		MaxTries:   maxTries,
+	}
+}
+
+// GenerateBlockCmd defines the generateblock JSON-RPC command.
+type GenerateBlockCmd struct {
+	Output       string
+	Transactions []string
+	Submit       *bool `jsonrpcdefault:"true"`
+}
+
+// NewGenerateBlockCmd returns a new instance which can be used to issue a
+// generateblock JSON-RPC command.  Each transaction is either the hash of a
+// transaction in the memory pool or a hex-encoded raw transaction.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGenerateBlockCmd(output string, transactions []string, submit *bool) *GenerateBlockCmd {
+	return &GenerateBlockCmd{
+		Output:       output,
+		Transactions: transactions,
+		Submit:       submit,
+	}
+}
+
 // GenerateCmd defines the generate JSON-RPC command.
 type GenerateCmd struct {
 	NumBlocks uint32
@@ -148,7 +189,9 @@ func init() {
 	MustRegisterCmd("debuglevel", (*DebugLevelCmd)(nil), flags)
 	MustRegisterCmd("node", (*NodeCmd)(nil), flags)
 	MustRegisterCmd("generate", (*GenerateCmd)(nil), flags)
+	MustRegisterCmd("generateblock", (*GenerateBlockCmd)(nil), flags)
 	MustRegisterCmd("generatetoaddress", (*GenerateToAddressCmd)(nil), flags)
+	MustRegisterCmd("generatetodescriptor", (*GenerateToDescriptorCmd)(nil), flags)
 	MustRegisterCmd("getbestblock", (*GetBestBlockCmd)(nil), flags)
 	MustRegisterCmd("getcurrentnet", (*GetCurrentNetCmd)(nil), flags)
 	MustRegisterCmd("getheaders", (*GetHeadersCmd)(nil), flags)
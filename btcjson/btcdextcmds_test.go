@@ -114,6 +114,36 @@ func TestBtcdExtCmds(t *testing.T) {
 				NumBlocks: 1,
 			},
 		},
+		{
+			name: "generateblock",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generateblock", "1Address", []string{"txid"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGenerateBlockCmd("1Address", []string{"txid"}, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generateblock","params":["1Address",["txid"]],"id":1}`,
+			unmarshalled: &btcjson.GenerateBlockCmd{
+				Output:       "1Address",
+				Transactions: []string{"txid"},
+				Submit:       btcjson.Bool(true),
+			},
+		},
+		{
+			name: "generateblock optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generateblock", "1Address", []string{}, false)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGenerateBlockCmd("1Address", []string{}, btcjson.Bool(false))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generateblock","params":["1Address",[],false],"id":1}`,
+			unmarshalled: &btcjson.GenerateBlockCmd{
+				Output:       "1Address",
+				Transactions: []string{},
+				Submit:       btcjson.Bool(false),
+			},
+		},
 		{
 			name: "generatetoaddress",
 			newCmd: func() (interface{}, error) {
@@ -132,6 +162,21 @@ func TestBtcdExtCmds(t *testing.T) {
 				}(),
 			},
 		},
+		{
+			name: "generatetodescriptor",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generatetodescriptor", 1, "addr(1Address)")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGenerateToDescriptorCmd(1, "addr(1Address)", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generatetodescriptor","params":[1,"addr(1Address)"],"id":1}`,
+			unmarshalled: &btcjson.GenerateToDescriptorCmd{
+				NumBlocks:  1,
+				Descriptor: "addr(1Address)",
+				MaxTries:   btcjson.Int64(1000000),
+			},
+		},
 		{
 			name: "getbestblock",
 			newCmd: func() (interface{}, error) {
@@ -18,3 +18,9 @@ type VersionResult struct {
 	Prerelease    string `json:"prerelease"`
 	BuildMetadata string `json:"buildmetadata"`
 }
+
+// GenerateBlockResult models the data from the generateblock command.
+type GenerateBlockResult struct {
+	Hash string `json:"hash"`
+	Hex  string `json:"hex,omitempty"`
+}
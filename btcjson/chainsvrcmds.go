@@ -48,6 +48,15 @@ func NewAddNodeCmd(addr string, subCmd AddNodeSubCmd) *AddNodeCmd {
 	}
 }
 
+// ClearBannedCmd defines the clearbanned JSON-RPC command.
+type ClearBannedCmd struct{}
+
+// NewClearBannedCmd returns a new instance which can be used to issue a
+// clearbanned JSON-RPC command.
+func NewClearBannedCmd() *ClearBannedCmd {
+	return &ClearBannedCmd{}
+}
+
 // TransactionInput represents the inputs to a transaction.  Specifically a
 // transaction hash and output number pair.
 type TransactionInput struct {
@@ -122,6 +131,59 @@ func NewDeriveAddressesCmd(descriptor string, descriptorRange *DescriptorRange)
 	}
 }
 
+// DisconnectNodeCmd defines the disconnectnode JSON-RPC command.
+type DisconnectNodeCmd struct {
+	Address *string `jsonrpcdefault:"\"\""`
+	NodeID  *int32
+}
+
+// NewDisconnectNodeCmd returns a new instance which can be used to issue a
+// disconnectnode JSON-RPC command.  Only one of the address and the node id
+// may be set.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewDisconnectNodeCmd(address *string, nodeID *int32) *DisconnectNodeCmd {
+	return &DisconnectNodeCmd{
+		Address: address,
+		NodeID:  nodeID,
+	}
+}
+
+// EstimateRawFeeCmd defines the estimaterawfee JSON-RPC command.
+type EstimateRawFeeCmd struct {
+	ConfTarget int64
+	Threshold  *float64 `jsonrpcdefault:"0.95"`
+}
+
+// NewEstimateRawFeeCmd returns a new instance which can be used to issue an
+// estimaterawfee JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewEstimateRawFeeCmd(confTarget int64, threshold *float64) *EstimateRawFeeCmd {
+	return &EstimateRawFeeCmd{
+		ConfTarget: confTarget,
+		Threshold:  threshold,
+	}
+}
+
+// EstimateMempoolFeeCmd defines the estimatemempoolfee JSON-RPC command.
+type EstimateMempoolFeeCmd struct {
+	NumBlocks *int64 `jsonrpcdefault:"8"`
+}
+
+// NewEstimateMempoolFeeCmd returns a new instance which can be used to issue
+// an estimatemempoolfee JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewEstimateMempoolFeeCmd(numBlocks *int64) *EstimateMempoolFeeCmd {
+	return &EstimateMempoolFeeCmd{
+		NumBlocks: numBlocks,
+	}
+}
+
 // ChangeType defines the different output types to use for the change address
 // of a transaction built by the node.
 type ChangeType string
@@ -355,6 +417,17 @@ type TemplateRequest struct {
 	// list of supported softfork deployments, by name
 	// Ref: https://en.bitcoin.it/wiki/BIP_0009#getblocktemplate_changes.
 	Rules []string `json:"rules,omitempty"`
+
+	// Extra outputs to add to the coinbase transaction when it is provided
+	// by the server.  NOTE: This is a btcd extension.
+	CoinbaseOutputs []TemplateRequestCoinbaseOutput `json:"coinbaseoutputs,omitempty"`
+}
+
+// TemplateRequestCoinbaseOutput describes an extra output of the coinbase
+// transaction requested via the coinbaseoutputs field of a TemplateRequest.
+type TemplateRequestCoinbaseOutput struct {
+	Script string `json:"script"`
+	Value  int64  `json:"value"`
 }
 
 // convertTemplateRequestField potentially converts the provided value as
@@ -665,6 +738,15 @@ func NewGetRawTransactionCmd(txHash string, verbose *int) *GetRawTransactionCmd
 	}
 }
 
+// GetStratumInfoCmd defines the getstratuminfo JSON-RPC command.
+type GetStratumInfoCmd struct{}
+
+// NewGetStratumInfoCmd returns a new instance which can be used to issue a
+// getstratuminfo JSON-RPC command.
+func NewGetStratumInfoCmd() *GetStratumInfoCmd {
+	return &GetStratumInfoCmd{}
+}
+
 // GetTxOutCmd defines the gettxout JSON-RPC command.
 type GetTxOutCmd struct {
 	Txid           string
@@ -757,6 +839,15 @@ func NewInvalidateBlockCmd(blockHash string) *InvalidateBlockCmd {
 	}
 }
 
+// ListBannedCmd defines the listbanned JSON-RPC command.
+type ListBannedCmd struct{}
+
+// NewListBannedCmd returns a new instance which can be used to issue a
+// listbanned JSON-RPC command.
+func NewListBannedCmd() *ListBannedCmd {
+	return &ListBannedCmd{}
+}
+
 // PingCmd defines the ping JSON-RPC command.
 type PingCmd struct{}
 
@@ -779,6 +870,21 @@ func NewPreciousBlockCmd(blockHash string) *PreciousBlockCmd {
 	}
 }
 
+// PrioritiseTransactionCmd defines the prioritisetransaction JSON-RPC command.
+type PrioritiseTransactionCmd struct {
+	Txid     string
+	FeeDelta int64
+}
+
+// NewPrioritiseTransactionCmd returns a new instance which can be used to
+// issue a prioritisetransaction JSON-RPC command.
+func NewPrioritiseTransactionCmd(txHash string, feeDelta int64) *PrioritiseTransactionCmd {
+	return &PrioritiseTransactionCmd{
+		Txid:     txHash,
+		FeeDelta: feeDelta,
+	}
+}
+
 // ReconsiderBlockCmd defines the reconsiderblock JSON-RPC command.
 type ReconsiderBlockCmd struct {
 	BlockHash string
@@ -903,6 +1009,45 @@ func NewBitcoindSendRawTransactionCmd(hexTx string, maxFeeRate int32) *SendRawTr
 	}
 }
 
+// SetBanSubCmd defines the type used in the setban JSON-RPC command for the
+// sub command field.
+type SetBanSubCmd string
+
+const (
+	// SBAdd indicates the specified IP or subnet should be banned.
+	SBAdd SetBanSubCmd = "add"
+
+	// SBRemove indicates the ban of the specified IP or subnet should be
+	// removed.
+	SBRemove SetBanSubCmd = "remove"
+)
+
+// SetBanCmd defines the setban JSON-RPC command.
+type SetBanCmd struct {
+	SubNet   string
+	SubCmd   SetBanSubCmd `jsonrpcusage:"\"add|remove\""`
+	BanTime  *int64       `jsonrpcdefault:"0"`
+	Absolute *bool        `jsonrpcdefault:"false"`
+}
+
+// NewSetBanCmd returns a new instance which can be used to issue a setban
+// JSON-RPC command.  The ban time is the duration of the ban in seconds, or
+// the time the ban expires in seconds since 1 Jan 1970 GMT when absolute is
+// set, and defaults to the configured ban duration when it is 0.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewSetBanCmd(subNet string, subCmd SetBanSubCmd, banTime *int64,
+	absolute *bool) *SetBanCmd {
+
+	return &SetBanCmd{
+		SubNet:   subNet,
+		SubCmd:   subCmd,
+		BanTime:  banTime,
+		Absolute: absolute,
+	}
+}
+
 // SetGenerateCmd defines the setgenerate JSON-RPC command.
 type SetGenerateCmd struct {
 	Generate     bool
@@ -1047,10 +1192,14 @@ func init() {
 	flags := UsageFlag(0)
 
 	MustRegisterCmd("addnode", (*AddNodeCmd)(nil), flags)
+	MustRegisterCmd("clearbanned", (*ClearBannedCmd)(nil), flags)
 	MustRegisterCmd("createrawtransaction", (*CreateRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("decoderawtransaction", (*DecodeRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("decodescript", (*DecodeScriptCmd)(nil), flags)
 	MustRegisterCmd("deriveaddresses", (*DeriveAddressesCmd)(nil), flags)
+	MustRegisterCmd("disconnectnode", (*DisconnectNodeCmd)(nil), flags)
+	MustRegisterCmd("estimatemempoolfee", (*EstimateMempoolFeeCmd)(nil), flags)
+	MustRegisterCmd("estimaterawfee", (*EstimateRawFeeCmd)(nil), flags)
 	MustRegisterCmd("fundrawtransaction", (*FundRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("getaddednodeinfo", (*GetAddedNodeInfoCmd)(nil), flags)
 	MustRegisterCmd("getbestblockhash", (*GetBestBlockHashCmd)(nil), flags)
@@ -1082,17 +1231,21 @@ func init() {
 	MustRegisterCmd("getpeerinfo", (*GetPeerInfoCmd)(nil), flags)
 	MustRegisterCmd("getrawmempool", (*GetRawMempoolCmd)(nil), flags)
 	MustRegisterCmd("getrawtransaction", (*GetRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("getstratuminfo", (*GetStratumInfoCmd)(nil), flags)
 	MustRegisterCmd("gettxout", (*GetTxOutCmd)(nil), flags)
 	MustRegisterCmd("gettxoutproof", (*GetTxOutProofCmd)(nil), flags)
 	MustRegisterCmd("gettxoutsetinfo", (*GetTxOutSetInfoCmd)(nil), flags)
 	MustRegisterCmd("getwork", (*GetWorkCmd)(nil), flags)
 	MustRegisterCmd("help", (*HelpCmd)(nil), flags)
 	MustRegisterCmd("invalidateblock", (*InvalidateBlockCmd)(nil), flags)
+	MustRegisterCmd("listbanned", (*ListBannedCmd)(nil), flags)
 	MustRegisterCmd("ping", (*PingCmd)(nil), flags)
 	MustRegisterCmd("preciousblock", (*PreciousBlockCmd)(nil), flags)
+	MustRegisterCmd("prioritisetransaction", (*PrioritiseTransactionCmd)(nil), flags)
 	MustRegisterCmd("reconsiderblock", (*ReconsiderBlockCmd)(nil), flags)
 	MustRegisterCmd("searchrawtransactions", (*SearchRawTransactionsCmd)(nil), flags)
 	MustRegisterCmd("sendrawtransaction", (*SendRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("setban", (*SetBanCmd)(nil), flags)
 	MustRegisterCmd("setgenerate", (*SetGenerateCmd)(nil), flags)
 	MustRegisterCmd("signmessagewithprivkey", (*SignMessageWithPrivKeyCmd)(nil), flags)
 	MustRegisterCmd("stop", (*StopCmd)(nil), flags)
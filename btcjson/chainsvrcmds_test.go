@@ -42,6 +42,17 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"addnode","params":["127.0.0.1","remove"],"id":1}`,
 			unmarshalled: &btcjson.AddNodeCmd{Addr: "127.0.0.1", SubCmd: btcjson.ANRemove},
 		},
+		{
+			name: "clearbanned",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("clearbanned")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewClearBannedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"clearbanned","params":[],"id":1}`,
+			unmarshalled: &btcjson.ClearBannedCmd{},
+		},
 		{
 			name: "createrawtransaction",
 			newCmd: func() (interface{}, error) {
@@ -220,6 +231,60 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"decodescript","params":["00"],"id":1}`,
 			unmarshalled: &btcjson.DecodeScriptCmd{HexScript: "00"},
 		},
+		{
+			name: "estimatemempoolfee",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("estimatemempoolfee")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewEstimateMempoolFeeCmd(nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"estimatemempoolfee","params":[],"id":1}`,
+			unmarshalled: &btcjson.EstimateMempoolFeeCmd{
+				NumBlocks: btcjson.Int64(8),
+			},
+		},
+		{
+			name: "estimatemempoolfee optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("estimatemempoolfee", 3)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewEstimateMempoolFeeCmd(btcjson.Int64(3))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"estimatemempoolfee","params":[3],"id":1}`,
+			unmarshalled: &btcjson.EstimateMempoolFeeCmd{
+				NumBlocks: btcjson.Int64(3),
+			},
+		},
+		{
+			name: "estimaterawfee",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("estimaterawfee", 6)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewEstimateRawFeeCmd(6, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"estimaterawfee","params":[6],"id":1}`,
+			unmarshalled: &btcjson.EstimateRawFeeCmd{
+				ConfTarget: 6,
+				Threshold:  btcjson.Float64(0.95),
+			},
+		},
+		{
+			name: "estimaterawfee optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("estimaterawfee", 6, 0.5)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewEstimateRawFeeCmd(6, btcjson.Float64(0.5))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"estimaterawfee","params":[6,0.5],"id":1}`,
+			unmarshalled: &btcjson.EstimateRawFeeCmd{
+				ConfTarget: 6,
+				Threshold:  btcjson.Float64(0.5),
+			},
+		},
 		{
 			name: "deriveaddresses no range",
 			newCmd: func() (interface{}, error) {
@@ -265,6 +330,48 @@ func TestChainSvrCmds(t *testing.T) {
 				Range:      &btcjson.DescriptorRange{Value: []int{0, 2}},
 			},
 		},
+		{
+			name: "disconnectnode",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("disconnectnode")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDisconnectNodeCmd(nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"disconnectnode","params":[],"id":1}`,
+			unmarshalled: &btcjson.DisconnectNodeCmd{
+				Address: btcjson.String(""),
+			},
+		},
+		{
+			name: "disconnectnode address",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("disconnectnode", "127.0.0.1:8333")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDisconnectNodeCmd(
+					btcjson.String("127.0.0.1:8333"), nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"disconnectnode","params":["127.0.0.1:8333"],"id":1}`,
+			unmarshalled: &btcjson.DisconnectNodeCmd{
+				Address: btcjson.String("127.0.0.1:8333"),
+			},
+		},
+		{
+			name: "disconnectnode node id",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("disconnectnode", "", 5)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDisconnectNodeCmd(btcjson.String(""),
+					btcjson.Int32(5))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"disconnectnode","params":["",5],"id":1}`,
+			unmarshalled: &btcjson.DisconnectNodeCmd{
+				Address: btcjson.String(""),
+				NodeID:  btcjson.Int32(5),
+			},
+		},
 		{
 			name: "getaddednodeinfo",
 			newCmd: func() (interface{}, error) {
@@ -563,6 +670,30 @@ func TestChainSvrCmds(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "getblocktemplate optional - template request with coinbase outputs",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblocktemplate", `{"capabilities":["coinbasetxn"],"coinbaseoutputs":[{"script":"6a0474657374","value":0}]}`)
+			},
+			staticCmd: func() interface{} {
+				template := btcjson.TemplateRequest{
+					Capabilities: []string{"coinbasetxn"},
+					CoinbaseOutputs: []btcjson.TemplateRequestCoinbaseOutput{
+						{Script: "6a0474657374", Value: 0},
+					},
+				}
+				return btcjson.NewGetBlockTemplateCmd(&template)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblocktemplate","params":[{"capabilities":["coinbasetxn"],"coinbaseoutputs":[{"script":"6a0474657374","value":0}]}],"id":1}`,
+			unmarshalled: &btcjson.GetBlockTemplateCmd{
+				Request: &btcjson.TemplateRequest{
+					Capabilities: []string{"coinbasetxn"},
+					CoinbaseOutputs: []btcjson.TemplateRequestCoinbaseOutput{
+						{Script: "6a0474657374", Value: 0},
+					},
+				},
+			},
+		},
 		{
 			name: "getcfilter",
 			newCmd: func() (interface{}, error) {
@@ -889,6 +1020,17 @@ func TestChainSvrCmds(t *testing.T) {
 				Verbose: btcjson.Int(1),
 			},
 		},
+		{
+			name: "getstratuminfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getstratuminfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetStratumInfoCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getstratuminfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetStratumInfoCmd{},
+		},
 		{
 			name: "gettxout",
 			newCmd: func() (interface{}, error) {
@@ -1025,6 +1167,17 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "123",
 			},
 		},
+		{
+			name: "listbanned",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("listbanned")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewListBannedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"listbanned","params":[],"id":1}`,
+			unmarshalled: &btcjson.ListBannedCmd{},
+		},
 		{
 			name: "ping",
 			newCmd: func() (interface{}, error) {
@@ -1049,6 +1202,20 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "0123",
 			},
 		},
+		{
+			name: "prioritisetransaction",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("prioritisetransaction", "123", 1000)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewPrioritiseTransactionCmd("123", 1000)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"prioritisetransaction","params":["123",1000],"id":1}`,
+			unmarshalled: &btcjson.PrioritiseTransactionCmd{
+				Txid:     "123",
+				FeeDelta: 1000,
+			},
+		},
 		{
 			name: "reconsiderblock",
 			newCmd: func() (interface{}, error) {
@@ -1269,6 +1436,41 @@ func TestChainSvrCmds(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "setban",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setban", "192.168.0.0/24", btcjson.SBAdd)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetBanCmd("192.168.0.0/24", btcjson.SBAdd,
+					nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setban","params":["192.168.0.0/24","add"],"id":1}`,
+			unmarshalled: &btcjson.SetBanCmd{
+				SubNet:   "192.168.0.0/24",
+				SubCmd:   btcjson.SBAdd,
+				BanTime:  btcjson.Int64(0),
+				Absolute: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "setban optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setban", "127.0.0.1", btcjson.SBAdd,
+					1700000000, true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetBanCmd("127.0.0.1", btcjson.SBAdd,
+					btcjson.Int64(1700000000), btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setban","params":["127.0.0.1","add",1700000000,true],"id":1}`,
+			unmarshalled: &btcjson.SetBanCmd{
+				SubNet:   "127.0.0.1",
+				SubCmd:   btcjson.SBAdd,
+				BanTime:  btcjson.Int64(1700000000),
+				Absolute: btcjson.Bool(true),
+			},
+		},
 		{
 			name: "setgenerate",
 			newCmd: func() (interface{}, error) {
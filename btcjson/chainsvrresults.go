@@ -333,8 +333,12 @@ type GetMempoolEntryResult struct {
 // GetMempoolInfoResult models the data returned from the getmempoolinfo
 // command.
 type GetMempoolInfoResult struct {
-	Size  int64 `json:"size"`
-	Bytes int64 `json:"bytes"`
+	Size              int64  `json:"size"`
+	Bytes             int64  `json:"bytes"`
+	FullRBF           bool   `json:"fullrbf"`
+	ReplacementPolicy string `json:"replacementpolicy"`
+	Expired           uint64 `json:"expired"`
+	MempoolSequence   uint64 `json:"mempoolsequence"`
 }
 
 // NetworksResult models the networks data from the getnetworkinfo command.
@@ -386,27 +390,45 @@ type GetNodeAddressesResult struct {
 
 // GetPeerInfoResult models the data returned from the getpeerinfo command.
 type GetPeerInfoResult struct {
-	ID             int32   `json:"id"`
-	Addr           string  `json:"addr"`
-	AddrLocal      string  `json:"addrlocal,omitempty"`
-	Services       string  `json:"services"`
-	RelayTxes      bool    `json:"relaytxes"`
-	LastSend       int64   `json:"lastsend"`
-	LastRecv       int64   `json:"lastrecv"`
-	BytesSent      uint64  `json:"bytessent"`
-	BytesRecv      uint64  `json:"bytesrecv"`
-	ConnTime       int64   `json:"conntime"`
-	TimeOffset     int64   `json:"timeoffset"`
-	PingTime       float64 `json:"pingtime"`
-	PingWait       float64 `json:"pingwait,omitempty"`
-	Version        uint32  `json:"version"`
-	SubVer         string  `json:"subver"`
-	Inbound        bool    `json:"inbound"`
-	StartingHeight int32   `json:"startingheight"`
-	CurrentHeight  int32   `json:"currentheight,omitempty"`
-	BanScore       int32   `json:"banscore"`
-	FeeFilter      int64   `json:"feefilter"`
-	SyncNode       bool    `json:"syncnode"`
+	ID                    int32             `json:"id"`
+	Addr                  string            `json:"addr"`
+	AddrLocal             string            `json:"addrlocal,omitempty"`
+	Services              string            `json:"services"`
+	RelayTxes             bool              `json:"relaytxes"`
+	LastSend              int64             `json:"lastsend"`
+	LastRecv              int64             `json:"lastrecv"`
+	BytesSent             uint64            `json:"bytessent"`
+	BytesRecv             uint64            `json:"bytesrecv"`
+	ConnTime              int64             `json:"conntime"`
+	TimeOffset            int64             `json:"timeoffset"`
+	PingTime              float64           `json:"pingtime"`
+	PingWait              float64           `json:"pingwait,omitempty"`
+	Version               uint32            `json:"version"`
+	SubVer                string            `json:"subver"`
+	Inbound               bool              `json:"inbound"`
+	StartingHeight        int32             `json:"startingheight"`
+	CurrentHeight         int32             `json:"currentheight,omitempty"`
+	BanScore              int32             `json:"banscore"`
+	FeeFilter             int64             `json:"feefilter"`
+	SyncNode              bool              `json:"syncnode"`
+	BIP152HBTo            bool              `json:"bip152_hb_to"`
+	BIP152HBFrom          bool              `json:"bip152_hb_from"`
+	TransportProtocolType string            `json:"transport_protocol_type"`
+	SessionID             string            `json:"session_id"`
+	BytesSentPerMsg       map[string]uint64 `json:"bytessent_per_msg"`
+	BytesRecvPerMsg       map[string]uint64 `json:"bytesrecv_per_msg"`
+	ConnectionType        string            `json:"connection_type"`
+	Permissions           []string          `json:"permissions"`
+}
+
+// ListBannedResult models the data returned from the listbanned command.
+type ListBannedResult struct {
+	Address       string `json:"address"`
+	BanCreated    int64  `json:"ban_created"`
+	BannedUntil   int64  `json:"banned_until"`
+	BanDuration   int64  `json:"ban_duration"`
+	TimeRemaining int64  `json:"time_remaining"`
+	BanReason     string `json:"ban_reason"`
 }
 
 // GetRawMempoolVerboseResult models the data returned from the getrawmempool
@@ -501,11 +523,23 @@ func (g *GetTxOutSetInfoResult) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// UploadTargetResult models the upload target data returned as part of the
+// getnettotals command.
+type UploadTargetResult struct {
+	TimeFrame             int64  `json:"timeframe"`
+	Target                uint64 `json:"target"`
+	TargetReached         bool   `json:"target_reached"`
+	ServeHistoricalBlocks bool   `json:"serve_historical_blocks"`
+	BytesLeftInCycle      uint64 `json:"bytes_left_in_cycle"`
+	TimeLeftInCycle       int64  `json:"time_left_in_cycle"`
+}
+
 // GetNetTotalsResult models the data returned from the getnettotals command.
 type GetNetTotalsResult struct {
-	TotalBytesRecv uint64 `json:"totalbytesrecv"`
-	TotalBytesSent uint64 `json:"totalbytessent"`
-	TimeMillis     int64  `json:"timemillis"`
+	TotalBytesRecv uint64             `json:"totalbytesrecv"`
+	TotalBytesSent uint64             `json:"totalbytessent"`
+	TimeMillis     int64              `json:"timemillis"`
+	UploadTarget   UploadTargetResult `json:"uploadtarget"`
 }
 
 // ScriptSig models a signature script.  It is defined separately since it only
@@ -685,6 +719,31 @@ type GetMiningInfoResult struct {
 	TestNet            bool    `json:"testnet"`
 }
 
+// StratumWorkerResult models the share accounting of a stratum worker that is
+// returned by the getstratuminfo command.
+type StratumWorkerResult struct {
+	Name           string  `json:"name"`
+	Connections    int64   `json:"connections"`
+	SharesAccepted uint64  `json:"sharesaccepted"`
+	SharesRejected uint64  `json:"sharesrejected"`
+	SharesStale    uint64  `json:"sharesstale"`
+	BlocksFound    uint64  `json:"blocksfound"`
+	Work           float64 `json:"work"`
+	HashesPerSec   float64 `json:"hashespersec"`
+	FirstShare     int64   `json:"firstshare"`
+	LastShare      int64   `json:"lastshare"`
+}
+
+// GetStratumInfoResult models the data from the getstratuminfo command.
+type GetStratumInfoResult struct {
+	Listeners    []string              `json:"listeners"`
+	Clients      int64                 `json:"clients"`
+	JobID        string                `json:"jobid"`
+	Height       int64                 `json:"height"`
+	HashesPerSec float64               `json:"hashespersec"`
+	Workers      []StratumWorkerResult `json:"workers"`
+}
+
 // GetWorkResult models the data from the getwork command.
 type GetWorkResult struct {
 	Data     string `json:"data"`
@@ -776,6 +835,49 @@ type EstimateSmartFeeResult struct {
 	Blocks  int64    `json:"blocks"`
 }
 
+// FeeRateBucket models the statistics of a range of fee rate buckets
+// returned as part of the estimaterawfee command.
+type FeeRateBucket struct {
+	StartRange     float64 `json:"startrange"`
+	EndRange       float64 `json:"endrange"`
+	WithinTarget   float64 `json:"withintarget"`
+	TotalConfirmed float64 `json:"totalconfirmed"`
+	InMempool      float64 `json:"inmempool"`
+	LeftMempool    float64 `json:"leftmempool"`
+}
+
+// EstimateRawFeeHorizonResult models the estimate for a single horizon
+// returned by the estimaterawfee command.
+type EstimateRawFeeHorizonResult struct {
+	FeeRate *float64       `json:"feerate,omitempty"`
+	Decay   float64        `json:"decay"`
+	Scale   int64          `json:"scale"`
+	Pass    *FeeRateBucket `json:"pass,omitempty"`
+	Fail    *FeeRateBucket `json:"fail,omitempty"`
+	Errors  []string       `json:"errors,omitempty"`
+}
+
+// EstimateRawFeeResult models the data returned by the chain server
+// estimaterawfee command.  Horizons which do not track the requested
+// confirmation target are omitted.
+type EstimateRawFeeResult struct {
+	Short  *EstimateRawFeeHorizonResult `json:"short,omitempty"`
+	Medium *EstimateRawFeeHorizonResult `json:"medium,omitempty"`
+	Long   *EstimateRawFeeHorizonResult `json:"long,omitempty"`
+}
+
+// ProjectedBlockResult models a block projected from the memory pool that is
+// returned by the estimatemempoolfee command.  Fee rates are in satoshis per
+// virtual byte.
+type ProjectedBlockResult struct {
+	NumTxs             int64     `json:"ntx"`
+	Weight             int64     `json:"weight"`
+	TotalFees          float64   `json:"totalfees"`
+	MinFeeRate         float64   `json:"minfeerate"`
+	MaxFeeRate         float64   `json:"maxfeerate"`
+	FeeRatePercentiles []float64 `json:"feeratepercentiles"`
+}
+
 var _ json.Unmarshaler = &FundRawTransactionResult{}
 
 type rawFundRawTransactionResult struct {
@@ -100,6 +100,50 @@ func TestChainSvrWsCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifynewtransactions","params":[],"id":1}`,
 			unmarshalled: &btcjson.StopNotifyNewTransactionsCmd{},
 		},
+		{
+			name: "notifyblocktemplate",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifyblocktemplate")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyBlockTemplateCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"notifyblocktemplate","params":[],"id":1}`,
+			unmarshalled: &btcjson.NotifyBlockTemplateCmd{},
+		},
+		{
+			name: "stopnotifyblocktemplate",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("stopnotifyblocktemplate")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStopNotifyBlockTemplateCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifyblocktemplate","params":[],"id":1}`,
+			unmarshalled: &btcjson.StopNotifyBlockTemplateCmd{},
+		},
+		{
+			name: "notifymempoolsequence",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifymempoolsequence")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyMempoolSequenceCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"notifymempoolsequence","params":[],"id":1}`,
+			unmarshalled: &btcjson.NotifyMempoolSequenceCmd{},
+		},
+		{
+			name: "stopnotifymempoolsequence",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("stopnotifymempoolsequence")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStopNotifyMempoolSequenceCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifymempoolsequence","params":[],"id":1}`,
+			unmarshalled: &btcjson.StopNotifyMempoolSequenceCmd{},
+		},
 		{
 			name: "notifyreceived",
 			newCmd: func() (interface{}, error) {
@@ -75,6 +75,26 @@ const (
 	// from the chain server that inform a client that a transaction that
 	// matches the loaded filter was accepted by the mempool.
 	RelevantTxAcceptedNtfnMethod = "relevanttxaccepted"
+
+	// MempoolSequenceNtfnMethod is the method used for notifications from
+	// the chain server that a transaction has been added to or removed
+	// from the mempool along with the resulting mempool sequence number.
+	MempoolSequenceNtfnMethod = "mempoolsequence"
+
+	// BlockTemplateChangedNtfnMethod is the method used for notifications
+	// from the chain server that the transactions included in a new block
+	// template have changed.
+	BlockTemplateChangedNtfnMethod = "blocktemplatechanged"
+)
+
+const (
+	// MempoolSequenceAdded is the event used in mempoolsequence
+	// notifications when a transaction has been added to the mempool.
+	MempoolSequenceAdded = "added"
+
+	// MempoolSequenceRemoved is the event used in mempoolsequence
+	// notifications when a transaction has been removed from the mempool.
+	MempoolSequenceRemoved = "removed"
 )
 
 // BlockConnectedNtfn defines the blockconnected JSON-RPC notification.
@@ -285,6 +305,61 @@ func NewRelevantTxAcceptedNtfn(txHex string) *RelevantTxAcceptedNtfn {
 	return &RelevantTxAcceptedNtfn{Transaction: txHex}
 }
 
+// MempoolSequenceNtfn defines the mempoolsequence JSON-RPC notification.
+//
+// The reason is only set for removals, while the replacing transaction hash is
+// only set for removals caused by a replacement or a conflicting transaction
+// being mined.
+type MempoolSequenceNtfn struct {
+	TxID       string
+	Event      string
+	Sequence   uint64
+	Reason     *string
+	ReplacedBy *string
+}
+
+// NewMempoolSequenceNtfn returns a new instance which can be used to issue a
+// mempoolsequence JSON-RPC notification.
+func NewMempoolSequenceNtfn(txHash, event string, sequence uint64,
+	reason, replacedBy *string) *MempoolSequenceNtfn {
+
+	return &MempoolSequenceNtfn{
+		TxID:       txHash,
+		Event:      event,
+		Sequence:   sequence,
+		Reason:     reason,
+		ReplacedBy: replacedBy,
+	}
+}
+
+// BlockTemplateChangedNtfn defines the blocktemplatechanged JSON-RPC
+// notification.
+//
+// The number of transactions excludes the coinbase, while the fees are the
+// total fees paid by the included transactions and the weight is the weight of
+// the entire block.
+type BlockTemplateChangedNtfn struct {
+	PrevHash string
+	Height   int32
+	NumTxns  int
+	Fees     int64
+	Weight   int64
+}
+
+// NewBlockTemplateChangedNtfn returns a new instance which can be used to
+// issue a blocktemplatechanged JSON-RPC notification.
+func NewBlockTemplateChangedNtfn(prevHash string, height int32, numTxns int,
+	fees, weight int64) *BlockTemplateChangedNtfn {
+
+	return &BlockTemplateChangedNtfn{
+		PrevHash: prevHash,
+		Height:   height,
+		NumTxns:  numTxns,
+		Fees:     fees,
+		Weight:   weight,
+	}
+}
+
 func init() {
 	// The commands in this file are only usable by websockets and are
 	// notifications.
@@ -301,4 +376,6 @@ func init() {
 	MustRegisterCmd(TxAcceptedNtfnMethod, (*TxAcceptedNtfn)(nil), flags)
 	MustRegisterCmd(TxAcceptedVerboseNtfnMethod, (*TxAcceptedVerboseNtfn)(nil), flags)
 	MustRegisterCmd(RelevantTxAcceptedNtfnMethod, (*RelevantTxAcceptedNtfn)(nil), flags)
+	MustRegisterCmd(MempoolSequenceNtfnMethod, (*MempoolSequenceNtfn)(nil), flags)
+	MustRegisterCmd(BlockTemplateChangedNtfnMethod, (*BlockTemplateChangedNtfn)(nil), flags)
 }
@@ -225,6 +225,56 @@ func TestChainSvrWsNtfns(t *testing.T) {
 				Transaction: "001122",
 			},
 		},
+		{
+			name: "mempoolsequence added",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("mempoolsequence", "123", "added", 1)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewMempoolSequenceNtfn("123", "added", 1, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"mempoolsequence","params":["123","added",1],"id":null}`,
+			unmarshalled: &btcjson.MempoolSequenceNtfn{
+				TxID:     "123",
+				Event:    "added",
+				Sequence: 1,
+			},
+		},
+		{
+			name: "mempoolsequence removed",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("mempoolsequence", "123", "removed", 2, "replaced", "456")
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewMempoolSequenceNtfn("123", "removed", 2,
+					btcjson.String("replaced"), btcjson.String("456"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"mempoolsequence","params":["123","removed",2,"replaced","456"],"id":null}`,
+			unmarshalled: &btcjson.MempoolSequenceNtfn{
+				TxID:       "123",
+				Event:      "removed",
+				Sequence:   2,
+				Reason:     btcjson.String("replaced"),
+				ReplacedBy: btcjson.String("456"),
+			},
+		},
+		{
+			name: "blocktemplatechanged",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("blocktemplatechanged", "123", 100, 2, 3000, 4000)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewBlockTemplateChangedNtfn("123", 100, 2, 3000, 4000)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"blocktemplatechanged","params":["123",100,2,3000,4000],"id":null}`,
+			unmarshalled: &btcjson.BlockTemplateChangedNtfn{
+				PrevHash: "123",
+				Height:   100,
+				NumTxns:  2,
+				Fees:     3000,
+				Weight:   4000,
+			},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
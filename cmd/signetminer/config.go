@@ -0,0 +1,123 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	flags "github.com/jessevdk/go-flags"
+)
+
+const (
+	defaultRPCServer     = "localhost:38332"
+	defaultNumBlocks     = 1
+	defaultBlockInterval = 10 * time.Minute
+)
+
+var (
+	btcdHomeDir        = btcutil.AppDataDir("btcd", false)
+	defaultRPCCertFile = filepath.Join(btcdHomeDir, "rpc.cert")
+)
+
+// config defines the configuration options for signetminer.
+//
+// See loadConfig for details on the configuration load process.
+type config struct {
+	Address         string        `long:"address" description:"Address to pay the block subsidy and transaction fees to"`
+	BlockInterval   time.Duration `long:"blockinterval" description:"Time to wait in between generating blocks"`
+	Keys            []string      `long:"key" description:"WIF-encoded private key to sign blocks with -- Can be specified multiple times"`
+	NoTLS           bool          `long:"notls" description:"Disable TLS"`
+	NumBlocks       uint32        `short:"n" long:"numblocks" description:"Number of blocks to generate -- Use 0 to generate blocks until interrupted"`
+	RPCCert         string        `short:"c" long:"rpccert" description:"RPC server certificate chain for validation"`
+	RPCPassword     string        `short:"P" long:"rpcpass" default-mask:"-" description:"RPC password"`
+	RPCServer       string        `short:"s" long:"rpcserver" description:"RPC server of the btcd instance to generate blocks with"`
+	RPCUser         string        `short:"u" long:"rpcuser" description:"RPC username"`
+	SigNetChallenge string        `long:"signetchallenge" description:"Challenge of the signet to generate blocks for in hex (default: the challenge of the global default signet)"`
+
+	// The following fields are derived from the options above.
+	challenge []byte
+	params    *chaincfg.Params
+	payAddr   btcutil.Address
+	keys      []*btcutil.WIF
+}
+
+// loadConfig initializes and parses the config using command line options.
+func loadConfig() (*config, []string, error) {
+	// Default config.
+	cfg := config{
+		BlockInterval: defaultBlockInterval,
+		NumBlocks:     defaultNumBlocks,
+		RPCCert:       defaultRPCCertFile,
+		RPCServer:     defaultRPCServer,
+	}
+
+	// Parse command line options.
+	parser := flags.NewParser(&cfg, flags.Default)
+	remainingArgs, err := parser.Parse()
+	if err != nil {
+		if e, ok := err.(*flags.Error); !ok || e.Type != flags.ErrHelp {
+			parser.WriteHelp(os.Stderr)
+		}
+		return nil, nil, err
+	}
+
+	// Use the parameters of the global default signet unless a custom
+	// challenge was specified, the same way btcd does.
+	funcName := "loadConfig"
+	cfg.challenge = chaincfg.DefaultSignetChallenge
+	cfg.params = &chaincfg.SigNetParams
+	if cfg.SigNetChallenge != "" {
+		challenge, err := hex.DecodeString(cfg.SigNetChallenge)
+		if err != nil {
+			str := "%s: Invalid signet challenge, hex decode " +
+				"failed: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			parser.WriteHelp(os.Stderr)
+			return nil, nil, err
+		}
+		params := chaincfg.CustomSignetParams(challenge, nil)
+		cfg.challenge = challenge
+		cfg.params = &params
+	}
+
+	// A payment address is required.
+	if cfg.Address == "" {
+		str := "%s: The address option must be specified"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+	cfg.payAddr, err = btcutil.DecodeAddress(cfg.Address, cfg.params)
+	if err != nil || !cfg.payAddr.IsForNet(cfg.params) {
+		str := "%s: The address [%s] is invalid for signet"
+		err := fmt.Errorf(str, funcName, cfg.Address)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
+	// Decode the signing keys.
+	for _, key := range cfg.Keys {
+		wif, err := btcutil.DecodeWIF(key)
+		if err != nil {
+			str := "%s: Invalid signing key: %v"
+			err := fmt.Errorf(str, funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			parser.WriteHelp(os.Stderr)
+			return nil, nil, err
+		}
+		cfg.keys = append(cfg.keys, wif)
+	}
+
+	return &cfg, remainingArgs, nil
+}
@@ -0,0 +1,296 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/mining"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+var (
+	cfg *config
+)
+
+// newBlock returns a new unsigned and unsolved block built from the passed
+// block template.  The coinbase transaction pays the subsidy and fees to the
+// configured address and always has a witness commitment since signet blocks
+// require one to hold the signet solution.
+func newBlock(template *btcjson.GetBlockTemplateResult) (*wire.MsgBlock, error) {
+	prevHash, err := chainhash.NewHashFromStr(template.PreviousHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid previous block hash: %v", err)
+	}
+	bits, err := strconv.ParseUint(template.Bits, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid difficulty bits: %v", err)
+	}
+	if template.CoinbaseValue == nil {
+		return nil, errors.New("block template does not include the " +
+			"coinbase value")
+	}
+
+	// Create the coinbase transaction paying to the configured address.
+	coinbaseScript, err := txscript.NewScriptBuilder().
+		AddInt64(template.Height).
+		AddData([]byte(mining.CoinbaseFlags)).Script()
+	if err != nil {
+		return nil, err
+	}
+	pkScript, err := txscript.PayToAddrScript(cfg.payAddr)
+	if err != nil {
+		return nil, err
+	}
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		SignatureScript: coinbaseScript,
+		Sequence:        wire.MaxTxInSequenceNum,
+	})
+	coinbase.AddTxOut(wire.NewTxOut(*template.CoinbaseValue, pkScript))
+
+	txns := make([]*btcutil.Tx, 0, len(template.Transactions)+1)
+	txns = append(txns, btcutil.NewTx(coinbase))
+	for i, txResult := range template.Transactions {
+		serializedTx, err := hex.DecodeString(txResult.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transaction %d: %v", i,
+				err)
+		}
+		tx, err := btcutil.NewTxFromBytes(serializedTx)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transaction %d: %v", i,
+				err)
+		}
+		txns = append(txns, tx)
+	}
+	mining.AddWitnessCommitment(txns[0], txns)
+
+	// The timestamp must be after the median time of the previous blocks
+	// which is provided as the minimum time of the template.
+	timestamp := template.CurTime
+	if timestamp < template.MinTime {
+		timestamp = template.MinTime
+	}
+	block := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   template.Version,
+			PrevBlock: *prevHash,
+			Timestamp: time.Unix(timestamp, 0),
+			Bits:      uint32(bits),
+		},
+	}
+	for _, tx := range txns {
+		block.AddTransaction(tx.MsgTx())
+	}
+	return block, nil
+}
+
+// signChallenge returns the signature script and witness which satisfy the
+// signet challenge when spending it with the passed transaction using the
+// configured keys.
+func signChallenge(tx *wire.MsgTx) ([]byte, wire.TxWitness, error) {
+	switch txscript.GetScriptClass(cfg.challenge) {
+	case txscript.WitnessV0PubKeyHashTy:
+		for _, wif := range cfg.keys {
+			pkHash := btcutil.Hash160(wif.SerializePubKey())
+			if string(pkHash) != string(cfg.challenge[2:]) {
+				continue
+			}
+
+			prevOutFetcher := txscript.NewCannedPrevOutputFetcher(
+				cfg.challenge, 0)
+			sigHashes := txscript.NewTxSigHashes(tx, prevOutFetcher)
+			witness, err := txscript.WitnessSignature(tx, sigHashes,
+				0, 0, cfg.challenge, txscript.SigHashAll,
+				wif.PrivKey, wif.CompressPubKey)
+			return nil, witness, err
+		}
+		return nil, nil, errors.New("no key for the signet challenge")
+
+	case txscript.NonStandardTy:
+		// Challenges such as OP_TRUE might not require a solution at
+		// all, which is checked once the block is signed.
+		return nil, nil, nil
+	}
+
+	// The keys are looked up by both their serialized public key and its
+	// hash to support pay-to-pubkey, pay-to-pubkey-hash and multisig
+	// challenges.
+	keys := make(map[string]*btcutil.WIF, len(cfg.keys)*2)
+	for _, wif := range cfg.keys {
+		pubKey := wif.SerializePubKey()
+		keys[string(pubKey)] = wif
+		keys[string(btcutil.Hash160(pubKey))] = wif
+	}
+	lookupKey := func(addr btcutil.Address) (*btcec.PrivateKey, bool, error) {
+		wif, ok := keys[string(addr.ScriptAddress())]
+		if !ok {
+			return nil, false, fmt.Errorf("no key for %v", addr)
+		}
+		return wif.PrivKey, wif.CompressPubKey, nil
+	}
+	sigScript, err := txscript.SignTxOutput(cfg.params, tx, 0, cfg.challenge,
+		txscript.SigHashAll, txscript.KeyClosure(lookupKey), nil, nil)
+	return sigScript, nil, err
+}
+
+// signBlock adds a signet solution which satisfies the signet challenge to the
+// witness commitment of the passed block and updates its merkle root
+// accordingly.  The solution commits to the block timestamp, so the block must
+// be signed again whenever it changes.
+func signBlock(block *wire.MsgBlock) error {
+	// The solution commits to the block with a witness commitment which
+	// only holds the signet header.  The witness commitment is the last
+	// output of the coinbase transaction created by newBlock, and any
+	// previous solution is removed from it first.
+	coinbase := block.Transactions[0]
+	commitmentOut := coinbase.TxOut[len(coinbase.TxOut)-1]
+	commitment := commitmentOut.PkScript[:blockchain.CoinbaseWitnessPkScriptLength]
+	commitmentOut.PkScript = commitment
+	if err := blockchain.AddSignetSolution(coinbase, nil); err != nil {
+		return err
+	}
+	_, toSign, err := blockchain.SignetTxs(block, cfg.challenge)
+	commitmentOut.PkScript = commitment
+	if err != nil {
+		return err
+	}
+
+	sigScript, witness, err := signChallenge(toSign)
+	if err != nil {
+		return err
+	}
+	solution := blockchain.SerializeSignetSolution(sigScript, witness)
+	if err := blockchain.AddSignetSolution(coinbase, solution); err != nil {
+		return err
+	}
+
+	utilBlock := btcutil.NewBlock(block)
+	merkles := blockchain.BuildMerkleTreeStore(utilBlock.Transactions(), false)
+	block.Header.MerkleRoot = *merkles[len(merkles)-1]
+
+	// Ensure the solution is valid, for instance, that enough keys were
+	// provided for a multisig challenge.
+	return blockchain.CheckSignetSolution(block, cfg.challenge)
+}
+
+// solveBlock searches for a nonce which makes the hash of the passed block
+// header less than its target difficulty.  It returns false when the entire
+// nonce range was searched without finding a solution.
+func solveBlock(header *wire.BlockHeader) bool {
+	target := blockchain.CompactToBig(header.Bits)
+	for nonce := uint32(0); ; nonce++ {
+		header.Nonce = nonce
+		hash := header.BlockHash()
+		if blockchain.HashToBig(&hash).Cmp(target) <= 0 {
+			return true
+		}
+		if nonce == math.MaxUint32 {
+			return false
+		}
+	}
+}
+
+// generateBlock generates a signed and solved block from a new block template
+// of the passed client and submits it.
+func generateBlock(client *rpcclient.Client) (*btcutil.Block, error) {
+	template, err := client.GetBlockTemplate(&btcjson.TemplateRequest{
+		Mode:  "template",
+		Rules: []string{"segwit"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get block template: %v", err)
+	}
+	msgBlock, err := newBlock(template)
+	if err != nil {
+		return nil, err
+	}
+
+	// Signing the block fixes its timestamp, so the timestamp is advanced
+	// and the block signed again when the nonce range is exhausted.
+	for {
+		if err := signBlock(msgBlock); err != nil {
+			return nil, fmt.Errorf("unable to sign block: %v", err)
+		}
+		if solveBlock(&msgBlock.Header) {
+			break
+		}
+		msgBlock.Header.Timestamp = msgBlock.Header.Timestamp.Add(
+			time.Second)
+	}
+
+	block := btcutil.NewBlock(msgBlock)
+	block.SetHeight(int32(template.Height))
+	if err := client.SubmitBlock(block, nil); err != nil {
+		return nil, fmt.Errorf("unable to submit block %v: %v",
+			block.Hash(), err)
+	}
+	return block, nil
+}
+
+func main() {
+	// Load configuration and parse command line.
+	tcfg, _, err := loadConfig()
+	if err != nil {
+		os.Exit(1)
+	}
+	cfg = tcfg
+
+	// Connect to the RPC server of btcd.
+	var certs []byte
+	if !cfg.NoTLS {
+		certs, err = os.ReadFile(cfg.RPCCert)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to read RPC certificate: "+
+				"%v\n", err)
+			os.Exit(1)
+		}
+	}
+	client, err := rpcclient.New(&rpcclient.ConnConfig{
+		Host:         cfg.RPCServer,
+		User:         cfg.RPCUser,
+		Pass:         cfg.RPCPassword,
+		HTTPPostMode: true,
+		DisableTLS:   cfg.NoTLS,
+		Certificates: certs,
+	}, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to connect to RPC server: %v\n",
+			err)
+		os.Exit(1)
+	}
+
+	for i := uint32(0); cfg.NumBlocks == 0 || i < cfg.NumBlocks; i++ {
+		if i > 0 {
+			time.Sleep(cfg.BlockInterval)
+		}
+
+		block, err := generateBlock(client)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			client.Shutdown()
+			os.Exit(1)
+		}
+		fmt.Printf("Generated block %v at height %d\n", block.Hash(),
+			block.Height())
+	}
+	client.Shutdown()
+}
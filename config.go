@@ -28,6 +28,8 @@ import (
 	"github.com/btcsuite/btcd/database"
 	_ "github.com/btcsuite/btcd/database/ffldb"
 	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/mining"
+	"github.com/btcsuite/btcd/mining/stratum"
 	"github.com/btcsuite/btcd/peer"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcd/btcutil"
@@ -42,6 +44,7 @@ const (
 	defaultLogDirname            = "logs"
 	defaultLogFilename           = "btcd.log"
 	defaultMaxPeers              = 125
+	defaultBlockRelayPeers       = 2
 	defaultBanDuration           = time.Hour * 24
 	defaultBanThreshold          = 100
 	defaultConnectTimeout        = time.Second * 30
@@ -61,10 +64,13 @@ const (
 	blockMaxWeightMax            = blockchain.MaxBlockWeight - 4000
 	defaultGenerate              = false
 	defaultMaxOrphanTransactions = 100
+	defaultMaxOrphanTxsPerPeer   = 25
 	defaultMaxOrphanTxSize       = 100000
+	defaultMempoolExpiry         = int(mempool.DefaultExpiry / time.Hour)
 	defaultSigCacheMaxSize       = 100000
 	sampleConfigFilename         = "sample-btcd.conf"
 	defaultTxIndex               = false
+	defaultStratumPort           = "3333"
 	defaultAddrIndex             = false
 )
 
@@ -107,7 +113,10 @@ type config struct {
 	BlockMaxWeight       uint32        `long:"blockmaxweight" description:"Maximum block weight to be used when creating a block"`
 	BlockMinWeight       uint32        `long:"blockminweight" description:"Mininum block weight to be used when creating a block"`
 	BlockPrioritySize    uint32        `long:"blockprioritysize" description:"Size in bytes for high-priority/low-fee transactions when creating a block"`
+	BlockRelayPeers      uint32        `long:"blockrelaypeers" description:"Number of block-relay-only outbound peers to maintain in addition to the other outbound peers.  They don't relay transactions and addresses, which makes them harder to discover."`
 	BlocksOnly           bool          `long:"blocksonly" description:"Do not accept transactions from remote peers."`
+	CoinbasePayouts      []string      `long:"coinbasepayout" description:"Add the specified payment address to the list of addresses the coinbase of generated blocks is split between according to their weights -- May not be used with the miningaddr option.  Format: '<address>[:<weight>]' (default weight: 1)"`
+	CoinbaseTag          string        `long:"coinbasetag" description:"Tag to append to the coinbase flags in the coinbase script of generated blocks"`
 	ConfigFile           string        `short:"C" long:"configfile" description:"Path to configuration file"`
 	ConnectPeers         []string      `long:"connect" description:"Connect only to the specified peers at startup"`
 	CPUProfile           string        `long:"cpuprofile" description:"Write CPU profile to the specified file"`
@@ -123,7 +132,11 @@ type config struct {
 	Listeners            []string      `long:"listen" description:"Add an interface/port to listen for connections (default all interfaces port: 8333, testnet: 18333)"`
 	LogDir               string        `long:"logdir" description:"Directory to log output."`
 	MaxOrphanTxs         int           `long:"maxorphantx" description:"Max number of orphan transactions to keep in memory"`
+	MaxOrphanTxsPerPeer  int           `long:"maxorphantxperpeer" description:"Max number of orphan transactions from a single peer to keep in memory (0 to disable)"`
 	MaxPeers             int           `long:"maxpeers" description:"Max number of inbound and outbound peers"`
+	MaxUploadTarget      uint64        `long:"maxuploadtarget" description:"Try to keep the upload traffic under the given target in MiB per 24h by not serving historical blocks to peers without the download permission once it is close to being reached (0 to disable)"`
+	MempoolExpiry        int           `long:"mempoolexpiry" description:"Do not keep transactions in the mempool longer than <n> hours (0 to disable)"`
+	MempoolFullRBF       bool          `long:"mempoolfullrbf" description:"Accept transactions that replace existing transactions within the mempool regardless of whether or not they signal replaceability through the Replace-By-Fee (RBF) policy."`
 	MiningAddrs          []string      `long:"miningaddr" description:"Add the specified payment address to the list of addresses to use for generated blocks -- At least one address is required if the generate option is set"`
 	MinRelayTxFee        float64       `long:"minrelaytxfee" description:"The minimum transaction fee in BTC/kB to be considered a non-zero fee."`
 	DisableBanning       bool          `long:"nobanning" description:"Disable banning of misbehaving peers"`
@@ -162,6 +175,9 @@ type config struct {
 	RPCUser              string        `short:"u" long:"rpcuser" description:"Username for RPC connections"`
 	SigCacheMaxSize      uint          `long:"sigcachemaxsize" description:"The maximum number of entries in the signature verification cache"`
 	SimNet               bool          `long:"simnet" description:"Use the simulation test network"`
+	StratumDifficulty    float64       `long:"stratumdifficulty" description:"Initial share difficulty assigned to stratum mining clients"`
+	StratumListeners     []string      `long:"stratumlisten" description:"Add an interface/port to listen for stratum mining connections (default port: 3333) -- At least one mining address is required if this option is set"`
+	StratumShareInterval time.Duration `long:"stratumshareinterval" description:"Target time between the shares of each stratum mining client used to adjust its share difficulty.  Valid time units are {s, m, h}"`
 	SigNet               bool          `long:"signet" description:"Use the signet test network"`
 	SigNetChallenge      string        `long:"signetchallenge" description:"Connect to a custom signet network defined by this challenge instead of using the global default signet test network -- Can be specified multiple times"`
 	SigNetSeedNode       []string      `long:"signetseednode" description:"Specify a seed node for the signet network instead of using the global default signet network seed nodes"`
@@ -169,17 +185,22 @@ type config struct {
 	TorIsolation         bool          `long:"torisolation" description:"Enable Tor stream isolation by randomizing user credentials for each connection."`
 	TrickleInterval      time.Duration `long:"trickleinterval" description:"Minimum time between attempts to send new inventory to a connected peer"`
 	TxIndex              bool          `long:"txindex" description:"Maintain a full hash-based transaction index which makes all transactions available via the getrawtransaction RPC"`
+	TxReconciliation     bool          `long:"txreconciliation" description:"Relay transactions to peers which support it through set reconciliation (BIP0330) instead of flooding inventory"`
 	UserAgentComments    []string      `long:"uacomment" description:"Comment to add to the user agent -- See BIP 14 for more information."`
 	Upnp                 bool          `long:"upnp" description:"Use UPnP to map our listening port outside of NAT"`
+	V2Transport          bool          `long:"v2transport" description:"Support the v2 encrypted P2P transport protocol (BIP0324) for peer connections"`
 	ShowVersion          bool          `short:"V" long:"version" description:"Display version information and exit"`
-	Whitelists           []string      `long:"whitelist" description:"Add an IP network or IP that will not be banned. (eg. 192.168.1.0/24 or ::1)"`
+	WhiteBinds           []string      `long:"whitebind" description:"Add an interface/port to listen for connections and grant permissions to the peers connecting to it, in the form [permissions@]<interface:port> -- See --whitelist for the permissions"`
+	Whitelists           []string      `long:"whitelist" description:"Grant permissions to the peers connecting from an IP network or IP, in the form [permissions@]<IP network or IP> (eg. 192.168.1.0/24 or noban,mempool@::1).  The permissions are a comma-separated list of bloomfilter, noban, forcerelay, relay, mempool, download, addr or all, and default to noban,relay,mempool,download"`
 	lookup               func(string) ([]net.IP, error)
 	oniondial            func(string, string, time.Duration) (net.Conn, error)
 	dial                 func(string, string, time.Duration) (net.Conn, error)
 	addCheckpoints       []chaincfg.Checkpoint
+	coinbasePayouts      []mining.CoinbasePayout
 	miningAddrs          []btcutil.Address
 	minRelayTxFee        btcutil.Amount
-	whitelists           []*net.IPNet
+	whitelists           []whitelist
+	whitebinds           []whitebind
 }
 
 // serviceOptions defines the configuration options for the daemon as a service on
@@ -379,6 +400,44 @@ func parseCheckpoints(checkpointStrings []string) ([]chaincfg.Checkpoint, error)
 	return checkpoints, nil
 }
 
+// parseCoinbasePayouts checks the coinbase payout strings for valid syntax
+// ('<address>[:<weight>]') and parses them to mining.CoinbasePayout instances
+// for the passed network.
+func parseCoinbasePayouts(payoutStrings []string, params *chaincfg.Params) ([]mining.CoinbasePayout, error) {
+	if len(payoutStrings) == 0 {
+		return nil, nil
+	}
+	payouts := make([]mining.CoinbasePayout, len(payoutStrings))
+	for i, payoutString := range payoutStrings {
+		strAddr, weight := payoutString, uint64(1)
+		if j := strings.LastIndexByte(payoutString, ':'); j != -1 {
+			var err error
+			strAddr = payoutString[:j]
+			weight, err = strconv.ParseUint(payoutString[j+1:], 10, 32)
+			if err != nil || weight == 0 {
+				return nil, fmt.Errorf("unable to parse coinbase "+
+					"payout %q due to malformed weight",
+					payoutString)
+			}
+		}
+
+		addr, err := btcutil.DecodeAddress(strAddr, params)
+		if err != nil {
+			return nil, fmt.Errorf("coinbase payout address '%s' "+
+				"failed to decode: %v", strAddr, err)
+		}
+		if !addr.IsForNet(params) {
+			return nil, fmt.Errorf("coinbase payout address '%s' "+
+				"is on the wrong network", strAddr)
+		}
+		payouts[i] = mining.CoinbasePayout{
+			Address: addr,
+			Weight:  uint32(weight),
+		}
+	}
+	return payouts, nil
+}
+
 // filesExists reports whether the named file or directory exists.
 func fileExists(name string) bool {
 	if _, err := os.Stat(name); err != nil {
@@ -416,6 +475,7 @@ func loadConfig() (*config, []string, error) {
 		ConfigFile:           defaultConfigFile,
 		DebugLevel:           defaultLogLevel,
 		MaxPeers:             defaultMaxPeers,
+		BlockRelayPeers:      defaultBlockRelayPeers,
 		BanDuration:          defaultBanDuration,
 		BanThreshold:         defaultBanThreshold,
 		RPCMaxClients:        defaultMaxRPCClients,
@@ -435,7 +495,11 @@ func loadConfig() (*config, []string, error) {
 		BlockMaxWeight:       defaultBlockMaxWeight,
 		BlockPrioritySize:    mempool.DefaultBlockPrioritySize,
 		MaxOrphanTxs:         defaultMaxOrphanTransactions,
+		MaxOrphanTxsPerPeer:  defaultMaxOrphanTxsPerPeer,
+		MempoolExpiry:        defaultMempoolExpiry,
 		SigCacheMaxSize:      defaultSigCacheMaxSize,
+		StratumDifficulty:    stratum.DefaultDifficulty,
+		StratumShareInterval: stratum.DefaultShareInterval,
 		Generate:             defaultGenerate,
 		TxIndex:              defaultTxIndex,
 		AddrIndex:            defaultAddrIndex,
@@ -635,6 +699,17 @@ func loadConfig() (*config, []string, error) {
 	}
 	cfg.RelayNonStd = relayNonStd
 
+	// Full RBF mode makes no sense when replacements are rejected
+	// altogether.
+	if cfg.MempoolFullRBF && cfg.RejectReplacement {
+		str := "%s: mempoolfullrbf and rejectreplacement cannot be " +
+			"used together -- choose only one"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Append the network type to the data directory so it is "namespaced"
 	// per network.  In addition to the block database, there are other
 	// pieces of data that are saved to disk such as address manager state.
@@ -698,18 +773,27 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
-	// Validate any given whitelisted IP addresses and networks.
+	// Validate any given whitelisted IP addresses and networks along with
+	// their permissions.
 	if len(cfg.Whitelists) > 0 {
 		var ip net.IP
-		cfg.whitelists = make([]*net.IPNet, 0, len(cfg.Whitelists))
+		cfg.whitelists = make([]whitelist, 0, len(cfg.Whitelists))
 
-		for _, addr := range cfg.Whitelists {
+		for _, entry := range cfg.Whitelists {
+			perms, addr, err := parsePermissionEntry(entry)
+			if err != nil {
+				str := "%s: The whitelist value of '%s' is invalid: %v"
+				err = fmt.Errorf(str, funcName, entry, err)
+				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(os.Stderr, usageMessage)
+				return nil, nil, err
+			}
 			_, ipnet, err := net.ParseCIDR(addr)
 			if err != nil {
 				ip = net.ParseIP(addr)
 				if ip == nil {
 					str := "%s: The whitelist value of '%s' is invalid"
-					err = fmt.Errorf(str, funcName, addr)
+					err = fmt.Errorf(str, funcName, entry)
 					fmt.Fprintln(os.Stderr, err)
 					fmt.Fprintln(os.Stderr, usageMessage)
 					return nil, nil, err
@@ -726,10 +810,29 @@ func loadConfig() (*config, []string, error) {
 					Mask: net.CIDRMask(bits, bits),
 				}
 			}
-			cfg.whitelists = append(cfg.whitelists, ipnet)
+			cfg.whitelists = append(cfg.whitelists, whitelist{
+				ipnet:       ipnet,
+				permissions: perms,
+			})
 		}
 	}
 
+	// Validate the permissions of any given whitebind listeners.
+	for _, entry := range cfg.WhiteBinds {
+		perms, addr, err := parsePermissionEntry(entry)
+		if err != nil {
+			str := "%s: The whitebind value of '%s' is invalid: %v"
+			err = fmt.Errorf(str, funcName, entry, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.whitebinds = append(cfg.whitebinds, whitebind{
+			addr:        normalizeAddress(addr, activeNetParams.DefaultPort),
+			permissions: perms,
+		})
+	}
+
 	// --addPeer and --connect do not mix.
 	if len(cfg.AddPeers) > 0 && len(cfg.ConnectPeers) > 0 {
 		str := "%s: the --addpeer and --connect options can not be " +
@@ -740,9 +843,10 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
-	// --proxy or --connect without --listen disables listening.
+	// --proxy or --connect without --listen or --whitebind disables
+	// listening.
 	if (cfg.Proxy != "" || len(cfg.ConnectPeers) > 0) &&
-		len(cfg.Listeners) == 0 {
+		len(cfg.Listeners) == 0 && len(cfg.WhiteBinds) == 0 {
 		cfg.DisableListen = true
 	}
 
@@ -753,8 +857,9 @@ func loadConfig() (*config, []string, error) {
 
 	// Add the default listener if none were specified. The default
 	// listener is all addresses on the listen port for the network
-	// we are to connect to.
-	if len(cfg.Listeners) == 0 {
+	// we are to connect to.  Whitebind listeners replace the default
+	// listener as well.
+	if len(cfg.Listeners) == 0 && len(cfg.WhiteBinds) == 0 {
 		cfg.Listeners = []string{
 			net.JoinHostPort("", activeNetParams.DefaultPort),
 		}
@@ -858,6 +963,40 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// The per peer orphan count may not be negative.
+	if cfg.MaxOrphanTxsPerPeer < 0 {
+		str := "%s: The maxorphantxperpeer option may not be less " +
+			"than 0 -- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.MaxOrphanTxsPerPeer)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The mempool expiry may not be negative.
+	if cfg.MempoolExpiry < 0 {
+		str := "%s: The mempoolexpiry option may not be less than 0 " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.MempoolExpiry)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The upload target must leave room for serving historical blocks
+	// beyond the part of it kept for serving recent blocks.
+	minUploadTarget := historicalBlockBuffer/(1024*1024) + 1
+	if cfg.MaxUploadTarget != 0 && cfg.MaxUploadTarget < minUploadTarget {
+		str := "%s: The maxuploadtarget option must be 0 or at least " +
+			"%d MiB since that much is kept for serving recent " +
+			"blocks -- parsed [%d]"
+		err := fmt.Errorf(str, funcName, minUploadTarget,
+			cfg.MaxUploadTarget)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Limit the block priority and minimum block sizes to max block size.
 	cfg.BlockPrioritySize = minUint32(cfg.BlockPrioritySize, cfg.BlockMaxSize)
 	cfg.BlockMinSize = minUint32(cfg.BlockMinSize, cfg.BlockMaxSize)
@@ -945,17 +1084,83 @@ func loadConfig() (*config, []string, error) {
 		cfg.miningAddrs = append(cfg.miningAddrs, addr)
 	}
 
-	// Ensure there is at least one mining address when the generate flag is
-	// set.
-	if cfg.Generate && len(cfg.MiningAddrs) == 0 {
+	// Check the coinbase payouts are valid and save parsed versions.
+	cfg.coinbasePayouts, err = parseCoinbasePayouts(cfg.CoinbasePayouts,
+		activeNetParams.Params)
+	if err != nil {
+		str := "%s: Error parsing coinbase payouts: %v"
+		err := fmt.Errorf(str, funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The coinbase payouts are only used for blocks which are generated
+	// without a mining address, so they may not be used together.
+	if len(cfg.MiningAddrs) > 0 && len(cfg.CoinbasePayouts) > 0 {
+		str := "%s: the miningaddr and coinbasepayout options may not " +
+			"be used together"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Ensure the coinbase tag fits in the coinbase script.
+	if len(cfg.CoinbaseTag) > mining.MaxCoinbaseTagLen {
+		str := "%s: the coinbasetag option may be at most %d bytes " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, mining.MaxCoinbaseTagLen,
+			len(cfg.CoinbaseTag))
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Ensure there is at least one mining address or coinbase payout when
+	// the generate flag is set.
+	if cfg.Generate && len(cfg.miningAddrs) == 0 &&
+		len(cfg.coinbasePayouts) == 0 {
+
 		str := "%s: the generate flag is set, but there are no mining " +
-			"addresses specified "
+			"addresses or coinbase payouts specified "
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Ensure there is at least one mining address or coinbase payout when
+	// the stratum server is enabled.
+	if len(cfg.StratumListeners) > 0 && len(cfg.miningAddrs) == 0 &&
+		len(cfg.coinbasePayouts) == 0 {
+
+		str := "%s: the stratumlisten option is set, but there are no " +
+			"mining addresses or coinbase payouts specified"
 		err := fmt.Errorf(str, funcName)
 		fmt.Fprintln(os.Stderr, err)
 		fmt.Fprintln(os.Stderr, usageMessage)
 		return nil, nil, err
 	}
 
+	// Validate the stratum share difficulty and interval.
+	if cfg.StratumDifficulty <= 0 {
+		str := "%s: the stratumdifficulty option must be greater " +
+			"than 0 -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.StratumDifficulty)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.StratumShareInterval < time.Second {
+		str := "%s: the stratumshareinterval option may not be less " +
+			"than 1s -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.StratumShareInterval)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Add default port to all listener addresses if needed and remove
 	// duplicate addresses.
 	cfg.Listeners = normalizeAddresses(cfg.Listeners,
@@ -966,6 +1171,11 @@ func loadConfig() (*config, []string, error) {
 	cfg.RPCListeners = normalizeAddresses(cfg.RPCListeners,
 		activeNetParams.rpcPort)
 
+	// Add default port to all stratum listener addresses if needed and
+	// remove duplicate addresses.
+	cfg.StratumListeners = normalizeAddresses(cfg.StratumListeners,
+		defaultStratumPort)
+
 	// Only allow TLS to be disabled if the RPC is bound to localhost
 	// addresses.
 	if !cfg.DisableRPC && cfg.DisableTLS {
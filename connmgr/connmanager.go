@@ -35,6 +35,10 @@ var (
 	// defaultTargetOutbound is the default number of outbound connections to
 	// maintain.
 	defaultTargetOutbound = uint32(8)
+
+	// defaultFeelerInterval is the default duration of time between feeler
+	// connections.
+	defaultFeelerInterval = time.Minute * 2
 )
 
 // ConnState represents the state of the requested connection.
@@ -53,13 +57,19 @@ const (
 )
 
 // ConnReq is the connection request to a network address. If permanent, the
-// connection will be retried on disconnection.
+// connection will be retried on disconnection.  Block-relay-only connections
+// are only used to relay blocks and are maintained separately from the other
+// outbound connections.  Feeler connections are short-lived connections which
+// only test whether an address is reachable.  They are neither retried nor
+// replaced and don't count towards any of the outbound connections.
 type ConnReq struct {
 	// The following variables must only be used atomically.
 	id uint64
 
-	Addr      net.Addr
-	Permanent bool
+	Addr           net.Addr
+	Permanent      bool
+	BlockRelayOnly bool
+	Feeler         bool
 
 	conn       net.Conn
 	state      ConnState
@@ -124,6 +134,16 @@ type Config struct {
 	// maintain. Defaults to 8.
 	TargetOutbound uint32
 
+	// TargetBlockRelayOnly is the number of block-relay-only outbound
+	// network connections to maintain in addition to TargetOutbound.
+	TargetBlockRelayOnly uint32
+
+	// Anchors holds the addresses of block-relay-only peers to connect to
+	// before any new addresses are requested from GetNewAddress, such as
+	// those of the previous session.  Only up to TargetBlockRelayOnly of
+	// them are used.
+	Anchors []net.Addr
+
 	// RetryDuration is the duration to wait before retrying connection
 	// requests. Defaults to 5s.
 	RetryDuration time.Duration
@@ -140,6 +160,14 @@ type Config struct {
 	// to.  If nil, no new connections will be made automatically.
 	GetNewAddress func() (net.Addr, error)
 
+	// GetFeelerAddress is a way to get an address to make a feeler
+	// connection to.  If nil, no feeler connections will be made.
+	GetFeelerAddress func() (net.Addr, error)
+
+	// FeelerInterval is the duration of time between feeler connections.
+	// Defaults to 2m.
+	FeelerInterval time.Duration
+
 	// Dial connects to the address on the named network. It cannot be nil.
 	Dial func(net.Addr) (net.Conn, error)
 }
@@ -213,17 +241,30 @@ func (cm *ConnManager) handleFailedConn(c *ConnReq) {
 			theId := c.id
 			time.AfterFunc(cm.cfg.RetryDuration, func() {
 				cm.Remove(theId)
-				cm.NewConnReq()
+				cm.newConnReq(c.BlockRelayOnly)
 			})
 		} else {
 			go func(theId uint64) {
 				cm.Remove(theId)
-				cm.NewConnReq()
+				cm.newConnReq(c.BlockRelayOnly)
 			}(c.id)
 		}
 	}
 }
 
+// numConns returns the number of connections in the passed set which are
+// block-relay-only when blockRelayOnly is true or the number of the other
+// connections otherwise.  Feeler connections are not counted.
+func numConns(conns map[uint64]*ConnReq, blockRelayOnly bool) uint32 {
+	var n uint32
+	for _, connReq := range conns {
+		if !connReq.Feeler && connReq.BlockRelayOnly == blockRelayOnly {
+			n++
+		}
+	}
+	return n
+}
+
 // connHandler handles all connection related requests.  It must be run as a
 // goroutine.
 //
@@ -322,13 +363,18 @@ out:
 				}
 
 				// Otherwise, we will attempt a reconnection if
-				// we do not have enough peers, or if this is a
-				// persistent peer. The connection request is
-				// re added to the pending map, so that
-				// subsequent processing of connections and
-				// failures do not ignore the request.
-				if uint32(len(conns)) < cm.cfg.TargetOutbound ||
-					connReq.Permanent {
+				// we do not have enough peers of its kind, or
+				// if this is a persistent peer. The connection
+				// request is re added to the pending map, so
+				// that subsequent processing of connections and
+				// failures do not ignore the request.  Feeler
+				// connections are never reconnected.
+				target := cm.cfg.TargetOutbound
+				if connReq.BlockRelayOnly {
+					target = cm.cfg.TargetBlockRelayOnly
+				}
+				if !connReq.Feeler && (connReq.Permanent ||
+					numConns(conns, connReq.BlockRelayOnly) < target) {
 
 					connReq.updateState(ConnPending)
 					log.Debugf("Reconnecting to %v",
@@ -349,6 +395,13 @@ out:
 				connReq.updateState(ConnFailing)
 				log.Debugf("Failed to connect to %v: %v",
 					connReq, msg.err)
+
+				// Failed feeler connections are simply
+				// dropped.
+				if connReq.Feeler {
+					delete(pending, connReq.id)
+					continue
+				}
 				cm.handleFailedConn(connReq)
 			}
 
@@ -364,14 +417,38 @@ out:
 // NewConnReq creates a new connection request and connects to the
 // corresponding address.
 func (cm *ConnManager) NewConnReq() {
-	if atomic.LoadInt32(&cm.stop) != 0 {
+	cm.newConnReq(false)
+}
+
+// NewBlockRelayOnlyConnReq creates a new block-relay-only connection request
+// and connects to the corresponding address.
+func (cm *ConnManager) NewBlockRelayOnlyConnReq() {
+	cm.newConnReq(true)
+}
+
+// newConnReq creates a new connection request of the requested kind and
+// connects to the corresponding address.
+func (cm *ConnManager) newConnReq(blockRelayOnly bool) {
+	if cm.cfg.GetNewAddress == nil {
 		return
 	}
-	if cm.cfg.GetNewAddress == nil {
+	cm.requestConn(&ConnReq{BlockRelayOnly: blockRelayOnly},
+		cm.cfg.GetNewAddress)
+}
+
+// newFeelerConnReq creates a new feeler connection request and connects to the
+// corresponding address.
+func (cm *ConnManager) newFeelerConnReq() {
+	cm.requestConn(&ConnReq{Feeler: true}, cm.cfg.GetFeelerAddress)
+}
+
+// requestConn registers the passed connection request and connects to the
+// address returned by the passed function.
+func (cm *ConnManager) requestConn(c *ConnReq, getAddr func() (net.Addr, error)) {
+	if atomic.LoadInt32(&cm.stop) != 0 {
 		return
 	}
 
-	c := &ConnReq{}
 	atomic.StoreUint64(&c.id, atomic.AddUint64(&cm.connReqCount, 1))
 
 	// Submit a request of a pending connection attempt to the connection
@@ -393,7 +470,7 @@ func (cm *ConnManager) NewConnReq() {
 		return
 	}
 
-	addr, err := cm.cfg.GetNewAddress()
+	addr, err := getAddr()
 	if err != nil {
 		select {
 		case cm.requests <- handleFailed{c, err}:
@@ -511,6 +588,27 @@ func (cm *ConnManager) listenHandler(listener net.Listener) {
 	log.Tracef("Listener handler done for %s", listener.Addr())
 }
 
+// feelerHandler periodically makes a feeler connection to test an address.  It
+// must be run as a goroutine.
+func (cm *ConnManager) feelerHandler() {
+	ticker := time.NewTicker(cm.cfg.FeelerInterval)
+	defer ticker.Stop()
+
+out:
+	for {
+		select {
+		case <-ticker.C:
+			go cm.newFeelerConnReq()
+
+		case <-cm.quit:
+			break out
+		}
+	}
+
+	cm.wg.Done()
+	log.Trace("Feeler handler done")
+}
+
 // Start launches the connection manager and begins connecting to the network.
 func (cm *ConnManager) Start() {
 	// Already started?
@@ -531,9 +629,30 @@ func (cm *ConnManager) Start() {
 		}
 	}
 
-	for i := atomic.LoadUint64(&cm.connReqCount); i < uint64(cm.cfg.TargetOutbound); i++ {
+	// Reconnect to the anchors first and fill the remaining
+	// block-relay-only connections with new addresses.  The number of
+	// requests is loaded beforehand so the anchors don't count towards the
+	// other outbound connections.
+	numReqs := atomic.LoadUint64(&cm.connReqCount)
+	anchors := cm.cfg.Anchors
+	if uint32(len(anchors)) > cm.cfg.TargetBlockRelayOnly {
+		anchors = anchors[:cm.cfg.TargetBlockRelayOnly]
+	}
+	for _, addr := range anchors {
+		go cm.Connect(&ConnReq{Addr: addr, BlockRelayOnly: true})
+	}
+	for i := len(anchors); i < int(cm.cfg.TargetBlockRelayOnly); i++ {
+		go cm.NewBlockRelayOnlyConnReq()
+	}
+
+	for i := numReqs; i < uint64(cm.cfg.TargetOutbound); i++ {
 		go cm.NewConnReq()
 	}
+
+	if cm.cfg.GetFeelerAddress != nil {
+		cm.wg.Add(1)
+		go cm.feelerHandler()
+	}
 }
 
 // Wait blocks until the connection manager halts gracefully.
@@ -573,6 +692,9 @@ func New(cfg *Config) (*ConnManager, error) {
 	if cfg.TargetOutbound == 0 {
 		cfg.TargetOutbound = defaultTargetOutbound
 	}
+	if cfg.FeelerInterval <= 0 {
+		cfg.FeelerInterval = defaultFeelerInterval
+	}
 	cm := ConnManager{
 		cfg:      *cfg, // Copy so caller can't mutate
 		requests: make(chan interface{}),
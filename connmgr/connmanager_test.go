@@ -216,6 +216,150 @@ func TestTargetOutbound(t *testing.T) {
 	cmgr.Stop()
 }
 
+// TestBlockRelayOnly tests the target number of block-relay-only connections.
+//
+// We wait until all connections are established, then test the anchors were
+// used for block-relay-only connections and that a lost block-relay-only
+// connection is replaced by another one.
+func TestBlockRelayOnly(t *testing.T) {
+	anchor := &net.TCPAddr{IP: net.ParseIP("127.0.0.2"), Port: 18555}
+	connected := make(chan *ConnReq)
+	cmgr, err := New(&Config{
+		TargetOutbound:       2,
+		TargetBlockRelayOnly: 2,
+		Anchors:              []net.Addr{anchor, anchor, anchor},
+		Dial:                 mockDialer,
+		GetNewAddress: func() (net.Addr, error) {
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.1"),
+				Port: 18555,
+			}, nil
+		},
+		OnConnection: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+	defer cmgr.Stop()
+
+	var numBlockRelayOnly, numAnchors int
+	var blockRelayOnly *ConnReq
+	for i := 0; i < 4; i++ {
+		c := <-connected
+		if c.BlockRelayOnly {
+			numBlockRelayOnly++
+			blockRelayOnly = c
+		}
+		if c.Addr.String() == anchor.String() {
+			if !c.BlockRelayOnly {
+				t.Fatalf("block relay only: anchor %v is not "+
+					"block-relay-only", c)
+			}
+			numAnchors++
+		}
+	}
+	if numBlockRelayOnly != 2 || numAnchors != 2 {
+		t.Fatalf("block relay only: got %d block-relay-only "+
+			"connections and %d anchors, want 2 and 2",
+			numBlockRelayOnly, numAnchors)
+	}
+	select {
+	case c := <-connected:
+		t.Fatalf("block relay only: got unexpected connection - %v",
+			c.Addr)
+	case <-time.After(time.Millisecond):
+		break
+	}
+
+	// A lost block-relay-only connection is replaced by another one.
+	cmgr.Disconnect(blockRelayOnly.ID())
+	select {
+	case c := <-connected:
+		if !c.BlockRelayOnly {
+			t.Fatalf("block relay only: replacement %v is not "+
+				"block-relay-only", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("block relay only: connection was not replaced")
+	}
+}
+
+// TestFeelers tests that feeler connections are made periodically and are
+// neither retried nor replaced.
+func TestFeelers(t *testing.T) {
+	feelerAddr := &net.TCPAddr{IP: net.ParseIP("127.0.0.3"), Port: 18555}
+	connected := make(chan *ConnReq)
+	var numFeelerAddrs uint32
+	cmgr, err := New(&Config{
+		TargetOutbound: 1,
+		FeelerInterval: 10 * time.Millisecond,
+		Dial:           mockDialer,
+		GetNewAddress: func() (net.Addr, error) {
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.1"),
+				Port: 18555,
+			}, nil
+		},
+		GetFeelerAddress: func() (net.Addr, error) {
+			// Every other feeler fails to get an address.
+			if atomic.AddUint32(&numFeelerAddrs, 1)%2 == 0 {
+				return nil, errors.New("no feeler address")
+			}
+			return feelerAddr, nil
+		},
+		OnConnection: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+	defer cmgr.Stop()
+
+	var numOutbound, numFeelers int
+	var feeler *ConnReq
+	for numFeelers < 3 {
+		c := <-connected
+		if !c.Feeler {
+			numOutbound++
+			continue
+		}
+		if c.Addr.String() != feelerAddr.String() {
+			t.Fatalf("feelers: got feeler to %v, want %v", c.Addr,
+				feelerAddr)
+		}
+		numFeelers++
+		feeler = c
+	}
+	if numOutbound != 1 {
+		t.Fatalf("feelers: got %d outbound connections, want 1",
+			numOutbound)
+	}
+
+	// A disconnected feeler connection is not reconnected and doesn't
+	// count towards the outbound connections.
+	cmgr.Disconnect(feeler.ID())
+	timeout := time.After(50 * time.Millisecond)
+	for {
+		select {
+		case c := <-connected:
+			if c.ID() == feeler.ID() {
+				t.Fatalf("feelers: feeler %v was reconnected", c)
+			}
+			if !c.Feeler {
+				t.Fatalf("feelers: got unexpected outbound "+
+					"connection %v", c)
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
 // TestRetryPermanent tests that permanent connection requests are retried.
 //
 // We make a permanent connection request using Connect, disconnect it using
@@ -43,7 +43,19 @@ Application Options:
       --blockprioritysize=    Size in bytes for high-priority/low-fee
                               transactions when creating a block (default:
                               50000)
+      --blockrelaypeers=      Number of block-relay-only outbound peers to
+                              maintain in addition to the other outbound
+                              peers.  They don't relay transactions and
+                              addresses, which makes them harder to discover.
+                              (default: 2)
       --blocksonly            Do not accept transactions from remote peers.
+      --coinbasepayout=       Add the specified payment address to the list of
+                              addresses the coinbase of generated blocks is
+                              split between according to their weights -- May
+                              not be used with the miningaddr option.  Format:
+                              '<address>[:<weight>]' (default weight: 1)
+      --coinbasetag=          Tag to append to the coinbase flags in the
+                              coinbase script of generated blocks
   -C, --configfile=           Path to configuration file
       --connect=              Connect only to the specified peers at startup
       --cpuprofile=           Write CPU profile to the specified file
@@ -76,8 +88,21 @@ Application Options:
       --logdir=               Directory to log output
       --maxorphantx=          Max number of orphan transactions to keep in
                               memory (default: 100)
+      --maxorphantxperpeer=   Max number of orphan transactions from a single
+                              peer to keep in memory (0 to disable)
+                              (default: 25)
       --maxpeers=             Max number of inbound and outbound peers
                               (default: 125)
+      --maxuploadtarget=      Try to keep the upload traffic under the given
+                              target in MiB per 24h by not serving historical
+                              blocks to peers without the download permission
+                              once it is close to being reached (0 to disable)
+      --mempoolexpiry=        Do not keep transactions in the mempool longer
+                              than <n> hours (0 to disable) (default: 336)
+      --mempoolfullrbf        Accept transactions that replace existing
+                              transactions within the mempool regardless of
+                              whether or not they signal replaceability
+                              through the Replace-By-Fee (RBF) policy.
       --miningaddr=           Add the specified payment address to the list of
                               addresses to use for generated blocks -- At least
                               one address is required if the generate option is
@@ -138,6 +163,16 @@ Application Options:
       --sigcachemaxsize=      The maximum number of entries in the signature
                               verification cache (default: 100000)
       --simnet                Use the simulation test network
+      --stratumdifficulty=    Initial share difficulty assigned to stratum
+                              mining clients (default: 1)
+      --stratumlisten=        Add an interface/port to listen for stratum
+                              mining connections (default port: 3333) -- At
+                              least one mining address is required if this
+                              option is set
+      --stratumshareinterval= Target time between the shares of each stratum
+                              mining client used to adjust its share
+                              difficulty.  Valid time units are {s, m, h}
+                              (default: 10s)
       --testnet               Use the test network
       --torisolation          Enable Tor stream isolation by randomizing user
                               credentials for each connection.
@@ -146,12 +181,27 @@ Application Options:
       --txindex               Maintain a full hash-based transaction index
                               which makes all transactions available via the
                               getrawtransaction RPC
+      --txreconciliation      Relay transactions to peers which support it
+                              through set reconciliation (BIP0330) instead of
+                              flooding inventory
       --uacomment=            Comment to add to the user agent -- See BIP 14
                               for more information.
       --upnp                  Use UPnP to map our listening port outside of NAT
+      --v2transport           Support the v2 encrypted P2P transport protocol
+                              (BIP0324) for peer connections
   -V, --version               Display version information and exit
-      --whitelist=            Add an IP network or IP that will not be banned.
-                              (eg. 192.168.1.0/24 or ::1)
+      --whitebind=            Add an interface/port to listen for connections
+                              and grant permissions to the peers connecting to
+                              it, in the form [permissions@]<interface:port>
+                              -- See --whitelist for the permissions
+      --whitelist=            Grant permissions to the peers connecting from
+                              an IP network or IP, in the form
+                              [permissions@]<IP network or IP> (eg.
+                              192.168.1.0/24 or noban,mempool@::1).  The
+                              permissions are a comma-separated list of
+                              bloomfilter, noban, forcerelay, relay, mempool,
+                              download, addr or all, and default to
+                              noban,relay,mempool,download
 
 Help Options:
   -h, --help           Show this help message
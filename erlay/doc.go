@@ -0,0 +1,25 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package erlay implements transaction relay through set reconciliation as
+defined by BIP0330, which is also known as Erlay.
+
+Rather than flooding the announcements of every transaction to every peer,
+each peer keeps a reconciliation set of the transactions it would have
+announced to the other side of a connection.  The peer which initiated the
+connection periodically requests a sketch of the set of the other peer.  It
+merges the sketch with a sketch of its own set, which decodes to the
+transactions only one of them has, and concludes the round by announcing the
+transactions it has and requesting the ones it is missing.  The bandwidth of a
+round only depends on the size of the difference rather than the number of
+transactions and peers.
+
+Transactions are identified by 32-bit short ids derived from their witness
+hash with a key both peers agree on.  When the difference can't be decoded,
+both peers fall back to announcing their whole set.
+
+The sketches are provided by the minisketch subpackage.
+*/
+package erlay
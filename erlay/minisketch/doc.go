@@ -0,0 +1,18 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package minisketch implements PinSketch set sketches over GF(2^32) compatible
+with the minisketch library used by BIP0330.
+
+A sketch of capacity c summarizes a set of non-zero 32-bit elements in 4c
+bytes regardless of the size of the set.  Merging the sketches of two sets
+results in a sketch of their symmetric difference, which can be decoded to
+the elements themselves as long as there are no more than c of them.
+
+Decoding finds the polynomial whose roots are the elements with the
+Berlekamp-Massey algorithm and then finds its roots with the Berlekamp trace
+algorithm.
+*/
+package minisketch
@@ -0,0 +1,68 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package minisketch
+
+// fieldModulus holds the low terms of the irreducible polynomial
+// x^32 + x^7 + x^3 + x^2 + 1 which defines the field GF(2^32) elements of a
+// sketch belong to.
+const fieldModulus = 0x8d
+
+// reduce returns the passed 64-bit carry-less product reduced modulo the field
+// polynomial.
+func reduce(v uint64) uint32 {
+	// Since x^32 is congruent to the low terms of the modulus, the high
+	// half is folded back in by multiplying it with them.  That product
+	// extends at most 7 bits beyond the low half, which are folded back in
+	// the same way.
+	hi := v >> 32
+	v = v&0xffffffff ^ clmul8(hi)
+	hi = v >> 32
+	return uint32(v ^ clmul8(hi))
+}
+
+// clmul8 returns the carry-less product of v and the low terms of the field
+// modulus.
+func clmul8(v uint64) uint64 {
+	return v ^ v<<2 ^ v<<3 ^ v<<7
+}
+
+// mul returns the product of a and b in GF(2^32).
+func mul(a, b uint32) uint32 {
+	var product uint64
+	aa := uint64(a)
+	for b != 0 {
+		if b&1 != 0 {
+			product ^= aa
+		}
+		aa <<= 1
+		b >>= 1
+	}
+	return reduce(product)
+}
+
+// sqr returns the square of a in GF(2^32).
+func sqr(a uint32) uint32 {
+	// Squaring is linear in characteristic 2, so it only spreads the bits
+	// of a to the even positions.
+	v := uint64(a)
+	v = (v | v<<16) & 0x0000ffff0000ffff
+	v = (v | v<<8) & 0x00ff00ff00ff00ff
+	v = (v | v<<4) & 0x0f0f0f0f0f0f0f0f
+	v = (v | v<<2) & 0x3333333333333333
+	v = (v | v<<1) & 0x5555555555555555
+	return reduce(v)
+}
+
+// inv returns the multiplicative inverse of the non-zero element a in
+// GF(2^32), which is a^(2^32-2).
+func inv(a uint32) uint32 {
+	// a^(2^32-2) = (a^(2^31-1))^2 where the exponent 2^31-1 consists of
+	// 31 set bits.
+	result := a
+	for i := 1; i < 31; i++ {
+		result = mul(sqr(result), a)
+	}
+	return sqr(result)
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package minisketch
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	// fieldBits is the number of bits of the elements of a sketch.
+	fieldBits = 32
+
+	// ElementSize is the number of bytes every syndrome of a serialized
+	// sketch occupies.
+	ElementSize = fieldBits / 8
+)
+
+var (
+	// ErrDecode is returned when a sketch can't be decoded, which is the
+	// case when the sets it summarizes differ by more elements than its
+	// capacity.
+	ErrDecode = errors.New("sketch has more differences than its capacity")
+
+	// ErrSketchLength is returned when a serialized sketch doesn't consist
+	// of whole elements.
+	ErrSketchLength = errors.New("serialized sketch length is not a " +
+		"multiple of the element size")
+)
+
+// Sketch is a PinSketch over GF(2^32) which summarizes a set of non-zero
+// 32-bit elements.  The sketches of two sets merge into a sketch of their
+// symmetric difference, which can be decoded as long as the sets differ by no
+// more elements than the capacity of the sketch.
+//
+// A sketch of capacity c consists of the odd power sums of the elements up to
+// the power 2c-1.  The even power sums, which are needed for decoding, are
+// implied by them since the square of a sum is the sum of the squares in
+// characteristic 2.
+type Sketch struct {
+	syndromes []uint32
+}
+
+// New returns an empty sketch with the passed capacity.
+func New(capacity int) *Sketch {
+	return &Sketch{syndromes: make([]uint32, capacity)}
+}
+
+// Capacity returns the maximum number of differences the sketch decodes.
+func (s *Sketch) Capacity() int {
+	return len(s.syndromes)
+}
+
+// Add toggles the passed element in the set summarized by the sketch.  Adding
+// an element twice removes it again.  Zero elements are ignored since they
+// don't affect the power sums.
+func (s *Sketch) Add(element uint32) {
+	if element == 0 {
+		return
+	}
+
+	power := element
+	square := sqr(element)
+	for i := range s.syndromes {
+		s.syndromes[i] ^= power
+		power = mul(power, square)
+	}
+}
+
+// Merge adds the elements summarized by the passed sketch to the sketch,
+// resulting in a sketch of the symmetric difference of both sets.  The
+// capacity of the sketch is reduced to the capacity of the passed sketch when
+// it is smaller.
+func (s *Sketch) Merge(other *Sketch) {
+	if len(other.syndromes) < len(s.syndromes) {
+		s.syndromes = s.syndromes[:len(other.syndromes)]
+	}
+	for i := range s.syndromes {
+		s.syndromes[i] ^= other.syndromes[i]
+	}
+}
+
+// Serialize returns the serialized sketch, which consists of its syndromes
+// encoded in little-endian.
+func (s *Sketch) Serialize() []byte {
+	data := make([]byte, len(s.syndromes)*ElementSize)
+	for i, syndrome := range s.syndromes {
+		binary.LittleEndian.PutUint32(data[i*ElementSize:], syndrome)
+	}
+	return data
+}
+
+// Deserialize returns the sketch encoded by the passed serialized sketch.  Its
+// capacity is implied by the length of the data.
+func Deserialize(data []byte) (*Sketch, error) {
+	if len(data)%ElementSize != 0 {
+		return nil, ErrSketchLength
+	}
+
+	s := New(len(data) / ElementSize)
+	for i := range s.syndromes {
+		s.syndromes[i] = binary.LittleEndian.Uint32(data[i*ElementSize:])
+	}
+	return s, nil
+}
+
+// Decode returns the elements of the set summarized by the sketch.  For the
+// merged sketches of two sets, these are the elements which are only in one
+// of them.  ErrDecode is returned when the set has more elements than the
+// capacity of the sketch.  This is only detected with high probability, which
+// improves with the capacity, so sketches of a small capacity may decode to a
+// set of bogus elements instead.
+func (s *Sketch) Decode() ([]uint32, error) {
+	// Restore the even power sums from the odd ones.
+	capacity := len(s.syndromes)
+	sums := make([]uint32, 2*capacity)
+	for i, syndrome := range s.syndromes {
+		sums[2*i] = syndrome
+	}
+	for i := 1; i < len(sums); i += 2 {
+		sums[i] = sqr(sums[i/2])
+	}
+
+	// The Berlekamp-Massey algorithm finds the shortest linear recurrence
+	// of the power sums, which is given by the polynomial whose roots are
+	// the inverses of the elements.
+	locator, length := berlekampMassey(sums)
+	if length == 0 {
+		return nil, nil
+	}
+	if length > capacity || locator.degree() != length {
+		return nil, ErrDecode
+	}
+
+	// Reversing the polynomial results in a polynomial whose roots are the
+	// elements themselves.  Its leading coefficient is the constant term
+	// of the connection polynomial, which is always one.  A valid set
+	// implies distinct non-zero roots which all lie in the field.
+	reversed := make(poly, length+1)
+	for i, coeff := range locator {
+		reversed[length-i] = coeff
+	}
+	if !splitsDistinct(reversed) {
+		return nil, ErrDecode
+	}
+	roots, ok := findRoots(reversed, 0, make([]uint32, 0, length))
+	if !ok || len(roots) != length {
+		return nil, ErrDecode
+	}
+	return roots, nil
+}
+
+// berlekampMassey returns the normalized connection polynomial of the shortest
+// linear recurrence which generates the passed sequence along with the length
+// of the recurrence.
+func berlekampMassey(sequence []uint32) (poly, int) {
+	current := poly{1}
+	prev := poly{1}
+	length := 0
+	shift := 1
+	prevDiscrepancy := uint32(1)
+	for n := range sequence {
+		discrepancy := sequence[n]
+		for i := 1; i <= length && i < len(current); i++ {
+			discrepancy ^= mul(current[i], sequence[n-i])
+		}
+		if discrepancy == 0 {
+			shift++
+			continue
+		}
+
+		// Subtract the scaled and shifted previous polynomial to cancel
+		// out the discrepancy.
+		factor := mul(discrepancy, inv(prevDiscrepancy))
+		next := current
+		if len(prev)+shift > len(next) {
+			next = make(poly, len(prev)+shift)
+			copy(next, current)
+		} else if 2*length <= n {
+			next = append(poly(nil), current...)
+		}
+		for i, coeff := range prev {
+			next[i+shift] ^= mul(factor, coeff)
+		}
+
+		if 2*length <= n {
+			length = n + 1 - length
+			prev = current
+			prevDiscrepancy = discrepancy
+			shift = 1
+		} else {
+			shift++
+		}
+		current = next
+	}
+	return current.normalize(), length
+}
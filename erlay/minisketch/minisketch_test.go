@@ -0,0 +1,203 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package minisketch
+
+import (
+	"bytes"
+	"math/bits"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// pow returns a raised to the power e in GF(2^32).
+func pow(a uint32, e uint64) uint32 {
+	result := uint32(1)
+	for e != 0 {
+		if e&1 != 0 {
+			result = mul(result, a)
+		}
+		a = sqr(a)
+		e >>= 1
+	}
+	return result
+}
+
+// gf2Mod returns a modulo b for polynomials over GF(2) represented by their
+// coefficient bits.
+func gf2Mod(a, b uint64) uint64 {
+	degB := bits.Len64(b) - 1
+	for a != 0 && bits.Len64(a)-1 >= degB {
+		a ^= b << uint(bits.Len64(a)-1-degB)
+	}
+	return a
+}
+
+// TestField ensures the field arithmetic is consistent and the field modulus
+// is irreducible.
+func TestField(t *testing.T) {
+	t.Parallel()
+
+	// By Rabin's test, the modulus f of degree 32 is irreducible when it
+	// divides x^(2^32) - x and x^(2^16) - x is coprime to it.
+	const x = 2
+	if pow(x, 1<<32) != x {
+		t.Fatal("pow: x^(2^32) is not x")
+	}
+	a, b := uint64(pow(x, 1<<16)^x), uint64(1<<32|fieldModulus)
+	for a != 0 {
+		a, b = gf2Mod(b, a), a
+	}
+	if b != 1 {
+		t.Fatalf("gcd: x^(2^16) - x shares the factor %x with the "+
+			"modulus", b)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		a, b, c := rng.Uint32(), rng.Uint32(), rng.Uint32()
+		if mul(a, b) != mul(b, a) {
+			t.Fatalf("mul: %x*%x is not commutative", a, b)
+		}
+		if mul(a, b^c) != mul(a, b)^mul(a, c) {
+			t.Fatalf("mul: %x*(%x+%x) is not distributive", a, b, c)
+		}
+		if sqr(a) != mul(a, a) {
+			t.Fatalf("sqr: mismatched square of %x", a)
+		}
+		if a != 0 && mul(a, inv(a)) != 1 {
+			t.Fatalf("inv: %x*inv(%x) is not one", a, a)
+		}
+	}
+}
+
+// randomSet returns the passed number of distinct random non-zero elements.
+func randomSet(rng *rand.Rand, n int) []uint32 {
+	seen := make(map[uint32]struct{}, n)
+	set := make([]uint32, 0, n)
+	for len(set) < n {
+		element := rng.Uint32()
+		if _, ok := seen[element]; ok || element == 0 {
+			continue
+		}
+		seen[element] = struct{}{}
+		set = append(set, element)
+	}
+	return set
+}
+
+// sorted returns a sorted copy of the passed elements.
+func sorted(elements []uint32) []uint32 {
+	sorted := append([]uint32(nil), elements...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// TestSketchDecode ensures the merged sketches of two sets decode to their
+// symmetric difference as long as it doesn't exceed the capacity.
+func TestSketchDecode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		capacity int
+		shared   int
+		onlyA    int
+		onlyB    int
+	}{
+		{"empty", 10, 0, 0, 0},
+		{"identical", 10, 50, 0, 0},
+		{"single difference", 1, 20, 1, 0},
+		{"both sides", 10, 100, 4, 5},
+		{"full capacity", 16, 30, 10, 6},
+		{"large capacity", 200, 500, 90, 110},
+	}
+
+	rng := rand.New(rand.NewSource(2))
+	for _, test := range tests {
+		elements := randomSet(rng, test.shared+test.onlyA+test.onlyB)
+		a, b := New(test.capacity), New(test.capacity)
+		for _, e := range elements[:test.shared+test.onlyA] {
+			a.Add(e)
+		}
+		for _, e := range elements[:test.shared] {
+			b.Add(e)
+		}
+		for _, e := range elements[test.shared+test.onlyA:] {
+			b.Add(e)
+		}
+
+		a.Merge(b)
+		got, err := a.Decode()
+		if err != nil {
+			t.Fatalf("%s: Decode: unexpected error: %v", test.name, err)
+		}
+		got, want := sorted(got), sorted(elements[test.shared:])
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: Decode: got %x, want %x", test.name, got,
+				want)
+		}
+	}
+}
+
+// TestSketchDecodeOverCapacity ensures sketches of sets which are larger than
+// their capacity fail to decode.  Sketches of a small capacity lack the
+// redundancy to detect this reliably, so they are not tested.
+func TestSketchDecodeOverCapacity(t *testing.T) {
+	t.Parallel()
+
+	rng := rand.New(rand.NewSource(3))
+	for _, capacity := range []int{8, 20, 50} {
+		for _, size := range []int{capacity + 1, 2 * capacity, 100} {
+			s := New(capacity)
+			for _, e := range randomSet(rng, size) {
+				s.Add(e)
+			}
+			if got, err := s.Decode(); err != ErrDecode {
+				t.Fatalf("Decode (capacity %d, size %d): got %x "+
+					"(err %v), want %v", capacity, size, got,
+					err, ErrDecode)
+			}
+		}
+	}
+}
+
+// TestSketchSerialize ensures sketches survive a serialization round trip and
+// that elements are toggled.
+func TestSketchSerialize(t *testing.T) {
+	t.Parallel()
+
+	s := New(3)
+	s.Add(1)
+	s.Add(0)
+	s.Add(2)
+	s.Add(2)
+
+	// The power sums of the single element one are all one.
+	want := []byte{1, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0}
+	data := s.Serialize()
+	if !bytes.Equal(data, want) {
+		t.Fatalf("Serialize: got %x, want %x", data, want)
+	}
+
+	s2, err := Deserialize(data)
+	if err != nil {
+		t.Fatalf("Deserialize: unexpected error: %v", err)
+	}
+	if s2.Capacity() != 3 || !reflect.DeepEqual(s2, s) {
+		t.Fatalf("Deserialize: got %v, want %v", s2, s)
+	}
+	if _, err := Deserialize(data[:5]); err != ErrSketchLength {
+		t.Fatalf("Deserialize: unexpected error: got %v, want %v", err,
+			ErrSketchLength)
+	}
+
+	// Merging with a smaller sketch reduces the capacity.
+	s.Merge(New(2))
+	if s.Capacity() != 2 {
+		t.Fatalf("Merge: got capacity %d, want 2", s.Capacity())
+	}
+}
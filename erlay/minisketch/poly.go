@@ -0,0 +1,173 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package minisketch
+
+// poly is a polynomial over GF(2^32) with the coefficients ordered from the
+// constant term up.  The leading coefficient of a normalized polynomial is
+// non-zero.
+type poly []uint32
+
+// normalize strips the zero leading coefficients from p.
+func (p poly) normalize() poly {
+	for len(p) > 0 && p[len(p)-1] == 0 {
+		p = p[:len(p)-1]
+	}
+	return p
+}
+
+// degree returns the degree of the normalized polynomial p, which is -1 for
+// the zero polynomial.
+func (p poly) degree() int {
+	return len(p) - 1
+}
+
+// monic scales the normalized polynomial p in place so its leading
+// coefficient is one.
+func (p poly) monic() {
+	lead := p[len(p)-1]
+	if lead == 1 {
+		return
+	}
+	factor := inv(lead)
+	for i := range p {
+		p[i] = mul(p[i], factor)
+	}
+}
+
+// mod replaces p with its remainder modulo the normalized monic polynomial m
+// and returns the normalized result.
+func (p poly) mod(m poly) poly {
+	p = p.normalize()
+	deg := m.degree()
+	for len(p) > deg {
+		lead := p[len(p)-1]
+		offset := len(p) - 1 - deg
+		for i := 0; i < deg; i++ {
+			p[offset+i] ^= mul(lead, m[i])
+		}
+		p = p[:len(p)-1].normalize()
+	}
+	return p
+}
+
+// divMod returns the quotient of p divided by the normalized monic polynomial
+// m, leaving the remainder in p.
+func (p poly) divMod(m poly) (poly, poly) {
+	p = p.normalize()
+	deg := m.degree()
+	if len(p) <= deg {
+		return nil, p
+	}
+	quotient := make(poly, len(p)-deg)
+	for len(p) > deg {
+		lead := p[len(p)-1]
+		offset := len(p) - 1 - deg
+		quotient[offset] = lead
+		for i := 0; i < deg; i++ {
+			p[offset+i] ^= mul(lead, m[i])
+		}
+		p = p[:len(p)-1]
+	}
+	return quotient, p.normalize()
+}
+
+// sqrMod returns the square of p modulo the normalized monic polynomial m.
+func (p poly) sqrMod(m poly) poly {
+	if len(p) == 0 {
+		return nil
+	}
+
+	// Squaring is linear in characteristic 2, so the square only consists
+	// of the squares of the coefficients at twice their degree.
+	result := make(poly, 2*len(p)-1)
+	for i, coeff := range p {
+		result[2*i] = sqr(coeff)
+	}
+	return result.mod(m)
+}
+
+// gcd returns the monic greatest common divisor of a and b.  Both polynomials
+// are modified.
+func gcd(a, b poly) poly {
+	a, b = a.normalize(), b.normalize()
+	for len(b) > 0 {
+		b.monic()
+		a = a.mod(b)
+		a, b = b, a
+	}
+	if len(a) > 0 {
+		a.monic()
+	}
+	return a
+}
+
+// traceMod returns the polynomial Tr(factor*x) modulo the normalized monic
+// polynomial m of a degree of at least two, where Tr is the trace map of
+// GF(2^32) over GF(2).
+func traceMod(factor uint32, m poly) poly {
+	// Tr(y) is the sum of y^(2^i) for i from 0 through 31.
+	term := poly{0, factor}.mod(m)
+	trace := make(poly, len(term), m.degree())
+	copy(trace, term)
+	for i := 1; i < fieldBits; i++ {
+		term = term.sqrMod(m)
+		if len(term) > len(trace) {
+			trace = trace[:len(term)]
+		}
+		for j, coeff := range term {
+			trace[j] ^= coeff
+		}
+	}
+	return trace.normalize()
+}
+
+// findRoots appends the roots of the normalized monic polynomial p to roots
+// and returns the result.  The polynomial must be the product of distinct
+// linear factors, which is verified beforehand.
+//
+// The trace of factor*x splits the polynomial into the factors whose roots
+// have a trace of zero and one respectively.  Since the trace is a
+// non-degenerate linear map, trying the basis elements of the field as the
+// factor in turn eventually separates all roots, and basis elements which
+// failed to split a polynomial can't split its factors either.
+func findRoots(p poly, basis int, roots []uint32) ([]uint32, bool) {
+	switch p.degree() {
+	case 0:
+		return roots, true
+	case 1:
+		return append(roots, p[0]), true
+	}
+
+	for ; basis < fieldBits; basis++ {
+		trace := traceMod(1<<uint(basis), p)
+		factor := gcd(append(poly(nil), p...), trace)
+		if factor.degree() <= 0 || factor.degree() == p.degree() {
+			continue
+		}
+		quotient, _ := append(poly(nil), p...).divMod(factor)
+		var ok bool
+		roots, ok = findRoots(factor, basis+1, roots)
+		if !ok {
+			return nil, false
+		}
+		return findRoots(quotient, basis+1, roots)
+	}
+	return nil, false
+}
+
+// splitsDistinct returns whether the normalized monic polynomial p of a
+// degree of at least one is the product of distinct linear factors over
+// GF(2^32), which is the case when it divides x^(2^32) - x.
+func splitsDistinct(p poly) bool {
+	if p.degree() == 1 {
+		return true
+	}
+	x := poly{0, 1}
+	power := append(poly(nil), x...)
+	for i := 0; i < fieldBits; i++ {
+		power = power.sqrMod(p)
+	}
+	return len(power) == 2 && power[0] == 0 && power[1] == 1
+}
@@ -0,0 +1,339 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package erlay
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sync"
+
+	"github.com/aead/siphash"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/erlay/minisketch"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// QPrecision is the value the coefficient q is scaled by when it is
+	// sent in a reqrecon message.
+	QPrecision = 1<<15 - 1
+
+	// DefaultQ is the coefficient q requested by initiators.  It is the
+	// expected fraction of the smaller of both sets which is missing from
+	// the other set and accounts for the differences which are not
+	// explained by the difference in their sizes.
+	DefaultQ = 0.25
+
+	// MaxSetSize is the maximum number of transactions in the
+	// reconciliation set of a peer.  Transactions which don't fit are
+	// announced by flooding instead.
+	MaxSetSize = 3000
+
+	// MaxDecodeCapacity is the maximum capacity of the sketches which are
+	// decoded.  The cost of decoding grows quadratically with the capacity,
+	// so reconciliation is considered to have failed for sketches of a
+	// larger capacity, which only result from large sets with a large
+	// difference anyway.
+	MaxDecodeCapacity = 256
+)
+
+var (
+	// saltTag is the tag of the hash which combines the salts of both
+	// peers into the key of the short transaction ids.
+	saltTag = []byte("Tx Relay Salting")
+
+	// defaultScaledQ is DefaultQ scaled by QPrecision.
+	defaultScaledQ = uint16(math.Floor(DefaultQ * QPrecision))
+
+	// ErrUnexpectedMessage is returned when a peer sends a reconciliation
+	// message which is not expected in its role or the current state of the
+	// reconciliation.
+	ErrUnexpectedMessage = errors.New("unexpected reconciliation message")
+)
+
+// ShortIDKey returns the key of the short transaction ids used by two peers
+// for the passed salts they exchanged in their sendtxrcncl messages.
+func ShortIDKey(salt1, salt2 uint64) [siphash.KeySize]byte {
+	// The salts are combined in ascending order so both peers derive the
+	// same key.
+	if salt1 > salt2 {
+		salt1, salt2 = salt2, salt1
+	}
+	var salts [16]byte
+	binary.LittleEndian.PutUint64(salts[:8], salt1)
+	binary.LittleEndian.PutUint64(salts[8:], salt2)
+	hash := chainhash.TaggedHash(saltTag, salts[:])
+
+	var key [siphash.KeySize]byte
+	copy(key[:], hash[:])
+	return key
+}
+
+// ShortID returns the 32-bit short id of the transaction with the passed
+// witness hash for the passed key.
+func ShortID(key *[siphash.KeySize]byte, wtxid *chainhash.Hash) uint32 {
+	return uint32(siphash.Sum64(wtxid[:], key)) + 1
+}
+
+// EstimateCapacity returns the capacity of the sketch a responder sends for
+// the passed sizes of both reconciliation sets and the coefficient q, which is
+// the expected fraction of the smaller set which is missing from the other.
+func EstimateCapacity(localSize, remoteSize int, q float64) int {
+	difference, smaller := localSize-remoteSize, remoteSize
+	if difference < 0 {
+		difference, smaller = -difference, localSize
+	}
+	capacity := difference + int(q*float64(smaller)) + 1
+	if capacity > wire.MaxSketchCapacity {
+		capacity = wire.MaxSketchCapacity
+	}
+	return capacity
+}
+
+// Reconciler tracks the reconciliation set of a peer, which holds the
+// transactions to be announced to it, and takes part in the rounds of set
+// reconciliation with it.  The peer which initiated the connection initiates
+// the rounds by requesting a sketch of the set of the other peer, which is the
+// responder.
+//
+// All methods are safe for concurrent access.
+type Reconciler struct {
+	initiator bool
+	key       [siphash.KeySize]byte
+
+	mtx sync.Mutex
+
+	// set maps the short ids of the transactions to be announced to the
+	// peer to their witness hashes.
+	set map[uint32]chainhash.Hash
+
+	// snapshot is the set a responder sent a sketch of while it waits for
+	// the reconcildiff message which concludes the round.  Transactions
+	// added in the meantime are deferred to the next round.
+	snapshot map[uint32]chainhash.Hash
+
+	// requested indicates an initiator is waiting for the sketch it
+	// requested.
+	requested bool
+}
+
+// NewReconciler returns a new reconciler for a peer.  The passed salts are the
+// ones sent in the sendtxrcncl messages of both peers, and initiator indicates
+// whether the local peer initiated the connection.
+func NewReconciler(initiator bool, localSalt, remoteSalt uint64) *Reconciler {
+	return &Reconciler{
+		initiator: initiator,
+		key:       ShortIDKey(localSalt, remoteSalt),
+		set:       make(map[uint32]chainhash.Hash),
+	}
+}
+
+// IsInitiator returns whether the local peer initiates the rounds of
+// reconciliation.
+func (r *Reconciler) IsInitiator() bool {
+	return r.initiator
+}
+
+// Add adds the transaction with the passed witness hash to the set of
+// transactions to be announced to the peer.  It returns false when the
+// transaction can't be added because the set is full or its short id collides
+// with another transaction, in which case it must be announced by flooding.
+// The same applies to the rare transactions with a zero short id, which is not
+// a valid sketch element.
+func (r *Reconciler) Add(wtxid *chainhash.Hash) bool {
+	shortID := ShortID(&r.key, wtxid)
+	if shortID == 0 {
+		return false
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if existing, ok := r.set[shortID]; ok {
+		return existing == *wtxid
+	}
+	if len(r.set) >= MaxSetSize {
+		return false
+	}
+	r.set[shortID] = *wtxid
+	return true
+}
+
+// Remove removes the transaction with the passed witness hash from the set of
+// transactions to be announced to the peer, such as when the peer is known to
+// have it.
+func (r *Reconciler) Remove(wtxid *chainhash.Hash) {
+	shortID := ShortID(&r.key, wtxid)
+
+	r.mtx.Lock()
+	if existing, ok := r.set[shortID]; ok && existing == *wtxid {
+		delete(r.set, shortID)
+	}
+	r.mtx.Unlock()
+}
+
+// SetSize returns the number of transactions to be announced to the peer.
+func (r *Reconciler) SetSize() int {
+	r.mtx.Lock()
+	size := len(r.set)
+	r.mtx.Unlock()
+
+	return size
+}
+
+// sketch returns a sketch of the passed capacity of the passed set.
+func sketch(set map[uint32]chainhash.Hash, capacity int) *minisketch.Sketch {
+	s := minisketch.New(capacity)
+	for shortID := range set {
+		s.Add(shortID)
+	}
+	return s
+}
+
+// wtxids returns the witness hashes of the passed set.
+func wtxids(set map[uint32]chainhash.Hash) []chainhash.Hash {
+	hashes := make([]chainhash.Hash, 0, len(set))
+	for _, wtxid := range set {
+		hashes = append(hashes, wtxid)
+	}
+	return hashes
+}
+
+// RequestReconciliation returns the reqrecon message an initiator sends to
+// start a round of reconciliation.  It returns nil when the local peer is not
+// the initiator or a round is already in progress.
+func (r *Reconciler) RequestReconciliation() *wire.MsgReqRecon {
+	if !r.initiator {
+		return nil
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.requested {
+		return nil
+	}
+	r.requested = true
+	return wire.NewMsgReqRecon(uint16(len(r.set)), defaultScaledQ)
+}
+
+// HandleReqRecon handles a reqrecon message from an initiator and returns the
+// sketch message to reply with.  The set is held back until the round is
+// concluded by a reconcildiff message.
+func (r *Reconciler) HandleReqRecon(msg *wire.MsgReqRecon) (*wire.MsgSketch, error) {
+	if r.initiator {
+		return nil, ErrUnexpectedMessage
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.snapshot != nil {
+		return nil, ErrUnexpectedMessage
+	}
+	r.snapshot = r.set
+	r.set = make(map[uint32]chainhash.Hash)
+
+	// An empty sketch tells the initiator there is nothing to reconcile
+	// and it should announce its whole set.
+	if len(r.snapshot) == 0 {
+		return wire.NewMsgSketch(nil), nil
+	}
+
+	q := float64(msg.Q) / QPrecision
+	capacity := EstimateCapacity(len(r.snapshot), int(msg.SetSize), q)
+	s := sketch(r.snapshot, capacity)
+	return wire.NewMsgSketch(s.Serialize()), nil
+}
+
+// HandleSketch handles the sketch message of a responder and concludes the
+// round of reconciliation.  It returns the witness hashes of the transactions
+// to announce to the peer along with the reconcildiff message which requests
+// the transactions the responder has but the local peer is missing.  When the
+// difference can't be decoded, all transactions of the set are announced
+// instead.
+func (r *Reconciler) HandleSketch(msg *wire.MsgSketch) ([]chainhash.Hash,
+	*wire.MsgReconcilDiff, error) {
+
+	if !r.initiator {
+		return nil, nil, ErrUnexpectedMessage
+	}
+	remote, err := minisketch.Deserialize(msg.SketchData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if !r.requested {
+		return nil, nil, ErrUnexpectedMessage
+	}
+	r.requested = false
+	set := r.set
+	r.set = make(map[uint32]chainhash.Hash)
+
+	// The responder has nothing to reconcile.
+	if remote.Capacity() == 0 {
+		return wtxids(set), wire.NewMsgReconcilDiff(true, nil), nil
+	}
+
+	// Reconciliation fails when the difference can't be decoded.
+	failed := wire.NewMsgReconcilDiff(false, nil)
+	if remote.Capacity() > MaxDecodeCapacity {
+		return wtxids(set), failed, nil
+	}
+	local := sketch(set, remote.Capacity())
+	local.Merge(remote)
+	difference, err := local.Decode()
+	if err != nil {
+		return wtxids(set), failed, nil
+	}
+
+	// Announce the transactions of the difference which are in the local
+	// set and request the remaining ones.
+	var announce []chainhash.Hash
+	askShortIDs := make([]uint32, 0, len(difference))
+	for _, shortID := range difference {
+		if wtxid, ok := set[shortID]; ok {
+			announce = append(announce, wtxid)
+			continue
+		}
+		askShortIDs = append(askShortIDs, shortID)
+	}
+	return announce, wire.NewMsgReconcilDiff(true, askShortIDs), nil
+}
+
+// HandleReconcilDiff handles the reconcildiff message which concludes a round
+// of reconciliation for a responder.  It returns the witness hashes of the
+// transactions to announce to the peer, which are the ones it requested or,
+// when the difference couldn't be decoded, all transactions of the set sent in
+// the sketch.
+func (r *Reconciler) HandleReconcilDiff(msg *wire.MsgReconcilDiff) ([]chainhash.Hash, error) {
+	if r.initiator {
+		return nil, ErrUnexpectedMessage
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	snapshot := r.snapshot
+	if snapshot == nil {
+		return nil, ErrUnexpectedMessage
+	}
+	r.snapshot = nil
+
+	if !msg.Success {
+		return wtxids(snapshot), nil
+	}
+	announce := make([]chainhash.Hash, 0, len(msg.AskShortIDs))
+	for _, shortID := range msg.AskShortIDs {
+		if wtxid, ok := snapshot[shortID]; ok {
+			announce = append(announce, wtxid)
+		}
+	}
+	return announce, nil
+}
@@ -0,0 +1,291 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package erlay
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testWTxIDs returns the passed number of distinct witness hashes starting
+// from the passed seed.
+func testWTxIDs(seed, n int) []chainhash.Hash {
+	hashes := make([]chainhash.Hash, n)
+	for i := range hashes {
+		hashes[i] = chainhash.DoubleHashH([]byte{byte(seed), byte(i),
+			byte(i >> 8)})
+	}
+	return hashes
+}
+
+// sortedHashes returns a sorted copy of the passed hashes.
+func sortedHashes(hashes []chainhash.Hash) []chainhash.Hash {
+	sorted := append([]chainhash.Hash(nil), hashes...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].String() < sorted[j].String()
+	})
+	return sorted
+}
+
+// equalHashes returns whether both lists hold the same hashes in any order.
+func equalHashes(a, b []chainhash.Hash) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = sortedHashes(a), sortedHashes(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestShortIDKey ensures both peers derive the same short id key regardless
+// of the order of the salts.
+func TestShortIDKey(t *testing.T) {
+	t.Parallel()
+
+	key := ShortIDKey(1, 2)
+	if ShortIDKey(2, 1) != key {
+		t.Fatal("ShortIDKey: key depends on the order of the salts")
+	}
+	if ShortIDKey(1, 3) == key {
+		t.Fatal("ShortIDKey: same key for different salts")
+	}
+
+	wtxid := testWTxIDs(0, 1)[0]
+	otherKey := ShortIDKey(1, 3)
+	if ShortID(&key, &wtxid) == ShortID(&otherKey, &wtxid) {
+		t.Fatal("ShortID: same short id for different keys")
+	}
+}
+
+// TestEstimateCapacity ensures the capacity estimate accounts for the
+// difference of the set sizes and the coefficient q.
+func TestEstimateCapacity(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		local, remote int
+		q             float64
+		want          int
+	}{
+		{0, 0, DefaultQ, 1},
+		{10, 0, DefaultQ, 11},
+		{0, 10, DefaultQ, 11},
+		{100, 80, DefaultQ, 41},
+		{80, 100, 0, 21},
+		{MaxSetSize, 0, DefaultQ, MaxSetSize + 1},
+		{wire.MaxSketchCapacity * 2, 0, DefaultQ, wire.MaxSketchCapacity},
+	}
+	for _, test := range tests {
+		got := EstimateCapacity(test.local, test.remote, test.q)
+		if got != test.want {
+			t.Errorf("EstimateCapacity(%d, %d, %v): got %d, want %d",
+				test.local, test.remote, test.q, got, test.want)
+		}
+	}
+}
+
+// reconcile runs a round of reconciliation between the passed initiator and
+// responder and returns the transactions each of them announces to the other.
+func reconcile(t *testing.T, initiator, responder *Reconciler) (
+	[]chainhash.Hash, []chainhash.Hash, *wire.MsgReconcilDiff) {
+
+	t.Helper()
+
+	reqRecon := initiator.RequestReconciliation()
+	if reqRecon == nil {
+		t.Fatal("RequestReconciliation: no request")
+	}
+	if initiator.RequestReconciliation() != nil {
+		t.Fatal("RequestReconciliation: request while in progress")
+	}
+	sketch, err := responder.HandleReqRecon(reqRecon)
+	if err != nil {
+		t.Fatalf("HandleReqRecon: unexpected error: %v", err)
+	}
+	initiatorAnnounce, diff, err := initiator.HandleSketch(sketch)
+	if err != nil {
+		t.Fatalf("HandleSketch: unexpected error: %v", err)
+	}
+	responderAnnounce, err := responder.HandleReconcilDiff(diff)
+	if err != nil {
+		t.Fatalf("HandleReconcilDiff: unexpected error: %v", err)
+	}
+	return initiatorAnnounce, responderAnnounce, diff
+}
+
+// TestReconciliation ensures rounds of reconciliation result in each peer
+// announcing the transactions the other one is missing.
+func TestReconciliation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                         string
+		shared, initiator, responder int
+		success                      bool
+	}{
+		{"empty", 0, 0, 0, true},
+		{"only initiator", 0, 10, 0, true},
+		{"only responder", 0, 0, 10, true},
+		{"identical", 50, 0, 0, true},
+		{"both sides", 100, 5, 7, true},
+		{"capacity exceeded", 0, 50, 50, false},
+	}
+
+	for i, test := range tests {
+		initiator := NewReconciler(true, 1, 2)
+		responder := NewReconciler(false, 2, 1)
+		shared := testWTxIDs(3*i, test.shared)
+		onlyInitiator := testWTxIDs(3*i+1, test.initiator)
+		onlyResponder := testWTxIDs(3*i+2, test.responder)
+
+		// Each peer tracks the transactions to announce to the other,
+		// which are the ones the other peer might not have.
+		for _, wtxid := range append(shared, onlyInitiator...) {
+			wtxid := wtxid
+			if !initiator.Add(&wtxid) {
+				t.Fatalf("%s: Add: unable to add %v", test.name, wtxid)
+			}
+		}
+		for _, wtxid := range append(shared, onlyResponder...) {
+			wtxid := wtxid
+			if !responder.Add(&wtxid) {
+				t.Fatalf("%s: Add: unable to add %v", test.name, wtxid)
+			}
+		}
+
+		wantInitiator := onlyInitiator
+		wantResponder := onlyResponder
+		if !test.success {
+			wantInitiator = append(shared, onlyInitiator...)
+			wantResponder = append(shared, onlyResponder...)
+		}
+		gotInitiator, gotResponder, diff := reconcile(t, initiator,
+			responder)
+		if diff.Success != test.success {
+			t.Fatalf("%s: got success %v, want %v", test.name,
+				diff.Success, test.success)
+		}
+		if !equalHashes(gotInitiator, wantInitiator) {
+			t.Fatalf("%s: initiator announced %v, want %v",
+				test.name, gotInitiator, wantInitiator)
+		}
+		if !equalHashes(gotResponder, wantResponder) {
+			t.Fatalf("%s: responder announced %v, want %v",
+				test.name, gotResponder, wantResponder)
+		}
+
+		// Both sets are cleared after the round.
+		if initiator.SetSize() != 0 || responder.SetSize() != 0 {
+			t.Fatalf("%s: sets not cleared", test.name)
+		}
+	}
+}
+
+// TestReconciliationDeferred ensures transactions added to the set of a
+// responder while a round is in progress are deferred to the next round.
+func TestReconciliationDeferred(t *testing.T) {
+	t.Parallel()
+
+	initiator := NewReconciler(true, 1, 2)
+	responder := NewReconciler(false, 2, 1)
+	wtxids := testWTxIDs(0, 2)
+
+	responder.Add(&wtxids[0])
+	sketch, err := responder.HandleReqRecon(initiator.RequestReconciliation())
+	if err != nil {
+		t.Fatalf("HandleReqRecon: unexpected error: %v", err)
+	}
+	responder.Add(&wtxids[1])
+	_, diff, err := initiator.HandleSketch(sketch)
+	if err != nil {
+		t.Fatalf("HandleSketch: unexpected error: %v", err)
+	}
+	announce, err := responder.HandleReconcilDiff(diff)
+	if err != nil {
+		t.Fatalf("HandleReconcilDiff: unexpected error: %v", err)
+	}
+	if !equalHashes(announce, wtxids[:1]) {
+		t.Fatalf("HandleReconcilDiff: announced %v, want %v", announce,
+			wtxids[:1])
+	}
+
+	_, announce, _ = reconcile(t, initiator, responder)
+	if !equalHashes(announce, wtxids[1:]) {
+		t.Fatalf("reconcile: announced %v, want %v", announce,
+			wtxids[1:])
+	}
+}
+
+// TestReconcilerSet ensures transactions are only added to the set while it
+// has room and can be removed again.
+func TestReconcilerSet(t *testing.T) {
+	t.Parallel()
+
+	r := NewReconciler(true, 1, 2)
+	wtxids := testWTxIDs(0, MaxSetSize+1)
+	for i := 0; i < MaxSetSize; i++ {
+		if !r.Add(&wtxids[i]) {
+			t.Fatalf("Add #%d: unable to add transaction", i)
+		}
+	}
+	if !r.Add(&wtxids[0]) {
+		t.Fatal("Add: unable to add existing transaction")
+	}
+	if r.Add(&wtxids[MaxSetSize]) {
+		t.Fatal("Add: added transaction to full set")
+	}
+
+	r.Remove(&wtxids[0])
+	r.Remove(&wtxids[MaxSetSize])
+	if r.SetSize() != MaxSetSize-1 {
+		t.Fatalf("Remove: got set size %d, want %d", r.SetSize(),
+			MaxSetSize-1)
+	}
+}
+
+// TestReconcilerUnexpectedMessages ensures messages which don't match the
+// role of a peer or the state of the reconciliation are rejected.
+func TestReconcilerUnexpectedMessages(t *testing.T) {
+	t.Parallel()
+
+	initiator := NewReconciler(true, 1, 2)
+	responder := NewReconciler(false, 2, 1)
+
+	if responder.RequestReconciliation() != nil {
+		t.Fatal("RequestReconciliation: responder requested a sketch")
+	}
+	if _, err := initiator.HandleReqRecon(wire.NewMsgReqRecon(0, 0)); err != ErrUnexpectedMessage {
+		t.Fatalf("HandleReqRecon: initiator got err %v", err)
+	}
+	if _, _, err := responder.HandleSketch(wire.NewMsgSketch(nil)); err != ErrUnexpectedMessage {
+		t.Fatalf("HandleSketch: responder got err %v", err)
+	}
+	if _, _, err := initiator.HandleSketch(wire.NewMsgSketch(nil)); err != ErrUnexpectedMessage {
+		t.Fatalf("HandleSketch: unrequested sketch got err %v", err)
+	}
+	diff := wire.NewMsgReconcilDiff(true, nil)
+	if _, err := initiator.HandleReconcilDiff(diff); err != ErrUnexpectedMessage {
+		t.Fatalf("HandleReconcilDiff: initiator got err %v", err)
+	}
+	if _, err := responder.HandleReconcilDiff(diff); err != ErrUnexpectedMessage {
+		t.Fatalf("HandleReconcilDiff: no round in progress got err %v",
+			err)
+	}
+
+	reqRecon := initiator.RequestReconciliation()
+	if _, err := responder.HandleReqRecon(reqRecon); err != nil {
+		t.Fatalf("HandleReqRecon: unexpected error: %v", err)
+	}
+	if _, err := responder.HandleReqRecon(reqRecon); err != ErrUnexpectedMessage {
+		t.Fatalf("HandleReqRecon: round in progress got err %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aead/siphash"
+	"github.com/btcsuite/btcd/addrmgr"
+)
+
+const (
+	// evictionProtectNetGroups is the number of inbound peers in distinct
+	// network groups which are protected from eviction.
+	evictionProtectNetGroups = 4
+
+	// evictionProtectPing is the number of inbound peers with the lowest
+	// ping times which are protected from eviction.
+	evictionProtectPing = 8
+
+	// evictionProtectTx is the number of inbound peers which most recently
+	// relayed new transactions that are protected from eviction.
+	evictionProtectTx = 4
+
+	// evictionProtectBlockRelayOnly is the number of inbound peers which
+	// don't relay transactions and most recently relayed new blocks that
+	// are protected from eviction.
+	evictionProtectBlockRelayOnly = 8
+
+	// evictionProtectBlock is the number of inbound peers which most
+	// recently relayed new blocks that are protected from eviction.
+	evictionProtectBlock = 4
+)
+
+// evictionCandidate holds the properties of an inbound peer which determine
+// whether it is protected from eviction when an inbound peer needs to be
+// evicted to make room for a new one.
+type evictionCandidate struct {
+	id            int32
+	addr          string
+	timeConnected time.Time
+	pingMicros    int64
+	lastTxTime    time.Time
+	lastBlockTime time.Time
+	relayTxs      bool
+	netGroup      string
+
+	// keyedNetGroup is the hash of the network group keyed with a secret
+	// of the local node, so the groups which are protected by it can't be
+	// predicted by an attacker.
+	keyedNetGroup uint64
+
+	// protectedNetwork indicates the peer is connected through Tor or
+	// localhost.  These peers tend to have longer ping times and would be
+	// at a disadvantage otherwise.
+	protectedNetwork bool
+}
+
+// newEvictionCandidate returns the eviction candidate for the passed inbound
+// peer.  The passed key is used to hash the network group of the peer.
+func newEvictionCandidate(sp *serverPeer,
+	key *[siphash.KeySize]byte) *evictionCandidate {
+
+	c := &evictionCandidate{
+		id:            sp.ID(),
+		addr:          sp.Addr(),
+		timeConnected: sp.TimeConnected(),
+		pingMicros:    sp.LastPingMicros(),
+		lastTxTime:    sp.LastTxTime(),
+		lastBlockTime: sp.LastBlockTime(),
+		relayTxs:      !sp.relayTxDisabled(),
+		netGroup:      "unknown",
+	}
+	if na := sp.NA(); na != nil {
+		lna := na.ToLegacy()
+		c.netGroup = addrmgr.GroupKey(na)
+		c.protectedNetwork = na.IsTorV3() ||
+			addrmgr.IsOnionCatTor(lna) || addrmgr.IsLocal(lna)
+	}
+	c.keyedNetGroup = siphash.Sum64([]byte(c.netGroup), key)
+	return c
+}
+
+// protectCandidates removes the k most protected candidates from the passed
+// list according to the passed ordering, which sorts the candidates from the
+// least to the most protected ones, and returns the remaining candidates
+// along with the number of protected ones.  Only the candidates which satisfy
+// the passed filter are protected when it is not nil.
+func protectCandidates(candidates []*evictionCandidate, k int,
+	less func(a, b *evictionCandidate) bool,
+	filter func(c *evictionCandidate) bool) ([]*evictionCandidate, int) {
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return less(candidates[i], candidates[j])
+	})
+
+	protected := make(map[*evictionCandidate]struct{}, k)
+	for i := len(candidates) - 1; i >= 0 && len(protected) < k; i-- {
+		if filter == nil || filter(candidates[i]) {
+			protected[candidates[i]] = struct{}{}
+		}
+	}
+
+	remaining := make([]*evictionCandidate, 0, len(candidates)-len(protected))
+	for _, c := range candidates {
+		if _, ok := protected[c]; !ok {
+			remaining = append(remaining, c)
+		}
+	}
+	return remaining, len(protected)
+}
+
+// The following orderings sort eviction candidates from the least to the most
+// protected ones for the various categories of protected peers.
+
+// lessByNetGroup orders candidates by their keyed network group.
+func lessByNetGroup(a, b *evictionCandidate) bool {
+	return a.keyedNetGroup < b.keyedNetGroup
+}
+
+// lessByPing orders candidates by descending ping times, treating peers which
+// never responded to a ping as the slowest ones.
+func lessByPing(a, b *evictionCandidate) bool {
+	if a.pingMicros == 0 || b.pingMicros == 0 {
+		return a.pingMicros == 0 && b.pingMicros != 0
+	}
+	return a.pingMicros > b.pingMicros
+}
+
+// lessByUptime orders candidates by ascending uptime.
+func lessByUptime(a, b *evictionCandidate) bool {
+	return a.timeConnected.After(b.timeConnected)
+}
+
+// lessByTxTime orders candidates by the time they last relayed a new
+// transaction, preferring peers which relay transactions and then the longest
+// connected ones on ties.
+func lessByTxTime(a, b *evictionCandidate) bool {
+	if !a.lastTxTime.Equal(b.lastTxTime) {
+		return a.lastTxTime.Before(b.lastTxTime)
+	}
+	if a.relayTxs != b.relayTxs {
+		return b.relayTxs
+	}
+	return lessByUptime(a, b)
+}
+
+// lessByBlockTime orders candidates by the time they last relayed a new block,
+// preferring the longest connected ones on ties.
+func lessByBlockTime(a, b *evictionCandidate) bool {
+	if !a.lastBlockTime.Equal(b.lastBlockTime) {
+		return a.lastBlockTime.Before(b.lastBlockTime)
+	}
+	return lessByUptime(a, b)
+}
+
+// selectPeerToEvict returns the candidate to evict from the passed inbound
+// peers to make room for a new one, or nil when all of them are protected.
+//
+// Peers are protected in several categories an attacker can't easily
+// dominate: distinct network groups, the lowest ping times, the most recent
+// relay of new transactions and blocks, and the longest uptimes, of which a
+// share is reserved for peers connected through Tor or localhost.  The peer
+// to evict is the youngest one of the network group with the most remaining
+// peers, so an attacker who controls many addresses in few network groups
+// mostly evicts its own peers.
+func selectPeerToEvict(candidates []*evictionCandidate) *evictionCandidate {
+	numCandidates := len(candidates)
+	remaining := append([]*evictionCandidate(nil), candidates...)
+
+	var numNetGroup, numPing, numTx, numBlockRelayOnly, numBlock int
+	remaining, numNetGroup = protectCandidates(remaining,
+		evictionProtectNetGroups, lessByNetGroup, nil)
+	remaining, numPing = protectCandidates(remaining, evictionProtectPing,
+		lessByPing, nil)
+	remaining, numTx = protectCandidates(remaining, evictionProtectTx,
+		lessByTxTime, nil)
+	remaining, numBlockRelayOnly = protectCandidates(remaining,
+		evictionProtectBlockRelayOnly, lessByBlockTime,
+		func(c *evictionCandidate) bool { return !c.relayTxs })
+	remaining, numBlock = protectCandidates(remaining, evictionProtectBlock,
+		lessByBlockTime, nil)
+
+	// Protect half of the remaining peers by their uptime, reserving up to
+	// half of them for peers connected through Tor or localhost.
+	numUptime := len(remaining) / 2
+	remaining, numNetwork := protectCandidates(remaining, numUptime/2,
+		lessByUptime, func(c *evictionCandidate) bool {
+			return c.protectedNetwork
+		})
+	remaining, numUptime = protectCandidates(remaining,
+		numUptime-numNetwork, lessByUptime, nil)
+
+	srvrLog.Debugf("Protected inbound peers from eviction: %d by network "+
+		"group, %d by ping, %d by tx relay, %d by block relay, %d by "+
+		"network and %d by uptime, %d of %d left", numNetGroup, numPing,
+		numTx, numBlockRelayOnly+numBlock, numNetwork, numUptime,
+		len(remaining), numCandidates)
+	if len(remaining) == 0 {
+		return nil
+	}
+
+	// Find the network group with the most peers, preferring the one with
+	// the youngest peer on ties, and evict its youngest peer.
+	groups := make(map[string][]*evictionCandidate)
+	for _, c := range remaining {
+		groups[c.netGroup] = append(groups[c.netGroup], c)
+	}
+	var largest []*evictionCandidate
+	for _, group := range groups {
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].timeConnected.After(group[j].timeConnected)
+		})
+		if len(group) > len(largest) || (len(group) == len(largest) &&
+			group[0].timeConnected.After(largest[0].timeConnected)) {
+
+			largest = group
+		}
+	}
+	return largest[0]
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aead/siphash"
+)
+
+// newTestEvictionCandidate returns an eviction candidate in the passed network
+// group which connected at the passed time.
+func newTestEvictionCandidate(id int32, netGroup string,
+	timeConnected time.Time) *evictionCandidate {
+
+	var key [siphash.KeySize]byte
+	return &evictionCandidate{
+		id:            id,
+		addr:          fmt.Sprintf("peer%d", id),
+		timeConnected: timeConnected,
+		pingMicros:    1000,
+		relayTxs:      true,
+		netGroup:      netGroup,
+		keyedNetGroup: siphash.Sum64([]byte(netGroup), &key),
+	}
+}
+
+// TestSelectPeerToEvictProtected ensures the youngest inbound peer, which is
+// evicted otherwise, is protected when it stands out in one of the protected
+// categories.
+func TestSelectPeerToEvictProtected(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	tests := []struct {
+		name        string
+		modify      func(c *evictionCandidate)
+		wantEvicted bool
+	}{
+		{
+			name:        "unprotected",
+			modify:      func(c *evictionCandidate) {},
+			wantEvicted: true,
+		},
+		{
+			name:   "other network group",
+			modify: func(c *evictionCandidate) { c.netGroup = "10.1.0.0" },
+		},
+		{
+			name:   "lowest ping",
+			modify: func(c *evictionCandidate) { c.pingMicros = 1 },
+		},
+		{
+			name:   "recent tx relay",
+			modify: func(c *evictionCandidate) { c.lastTxTime = now },
+		},
+		{
+			name:   "recent block relay",
+			modify: func(c *evictionCandidate) { c.lastBlockTime = now },
+		},
+		{
+			name: "recent block relay without tx relay",
+			modify: func(c *evictionCandidate) {
+				c.lastBlockTime = now
+				c.relayTxs = false
+			},
+		},
+		{
+			name: "tor or localhost",
+			modify: func(c *evictionCandidate) {
+				c.protectedNetwork = true
+			},
+		},
+	}
+
+	for _, test := range tests {
+		// The youngest peer goes first since the peers which share a
+		// network group are protected by their order in the list.
+		youngest := newTestEvictionCandidate(0, "10.0.0.0", now)
+		test.modify(youngest)
+		candidates := []*evictionCandidate{youngest}
+		for i := 1; i <= 40; i++ {
+			candidates = append(candidates, newTestEvictionCandidate(
+				int32(i), "10.0.0.0",
+				now.Add(-time.Duration(i)*time.Minute)))
+		}
+
+		evicted := selectPeerToEvict(candidates)
+		if evicted == nil {
+			t.Fatalf("%s: no peer selected for eviction", test.name)
+		}
+		if (evicted == youngest) != test.wantEvicted {
+			t.Fatalf("%s: evicted %s, want youngest peer evicted %v",
+				test.name, evicted.addr, test.wantEvicted)
+		}
+	}
+}
+
+// TestSelectPeerToEvictNetGroup ensures peers are evicted from the network
+// group with the most peers, so an attacker who controls many addresses in a
+// single network group can't evict the other peers.
+func TestSelectPeerToEvictNetGroup(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	var candidates []*evictionCandidate
+	for i := 0; i < 30; i++ {
+		c := newTestEvictionCandidate(int32(i),
+			fmt.Sprintf("10.%d.0.0", i), now.Add(-time.Hour))
+		c.pingMicros = int64(1000 + i)
+		candidates = append(candidates, c)
+	}
+	for i := 30; i < 60; i++ {
+		c := newTestEvictionCandidate(int32(i), "192.168.0.0",
+			now.Add(-time.Duration(i)*time.Second))
+		c.pingMicros = 0
+		candidates = append(candidates, c)
+	}
+
+	// Repeatedly evicting peers only evicts the attacker's peers, starting
+	// with the youngest one.
+	for i := 30; i < 50; i++ {
+		evicted := selectPeerToEvict(candidates)
+		if evicted == nil {
+			t.Fatalf("eviction #%d: no peer selected", i)
+		}
+		if evicted.netGroup != "192.168.0.0" {
+			t.Fatalf("eviction #%d: evicted %s from network group "+
+				"%s", i, evicted.addr, evicted.netGroup)
+		}
+		if evicted.id != int32(i) {
+			t.Fatalf("eviction #%d: evicted %s, want youngest peer "+
+				"peer%d", i, evicted.addr, i)
+		}
+
+		remaining := candidates[:0]
+		for _, c := range candidates {
+			if c != evicted {
+				remaining = append(remaining, c)
+			}
+		}
+		candidates = remaining
+	}
+}
+
+// TestSelectPeerToEvictNone ensures no peer is evicted when all of them are
+// protected.
+func TestSelectPeerToEvictNone(t *testing.T) {
+	t.Parallel()
+
+	if evicted := selectPeerToEvict(nil); evicted != nil {
+		t.Fatalf("evicted %s without candidates", evicted.addr)
+	}
+
+	// The peers in distinct network groups with the lowest ping times and
+	// the most recent transaction relay are all protected.
+	now := time.Now()
+	var candidates []*evictionCandidate
+	for i := 0; i < evictionProtectNetGroups+evictionProtectPing+
+		evictionProtectTx; i++ {
+
+		candidates = append(candidates, newTestEvictionCandidate(int32(i),
+			fmt.Sprintf("10.%d.0.0", i), now))
+	}
+	if evicted := selectPeerToEvict(candidates); evicted != nil {
+		t.Fatalf("evicted protected peer %s", evicted.addr)
+	}
+}
@@ -13,10 +13,12 @@ import (
 	"fmt"
 	"os"
 	"runtime/debug"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/integration/rpctest"
@@ -289,6 +291,109 @@ func testGetNetworkHashPS3(r *rpctest.Harness, t *testing.T) {
 	}
 }
 
+func testGetBlockTemplateCoinbaseOutputs(r *rpctest.Harness, t *testing.T) {
+	client := r.Client
+
+	templateRequest := func(script string) *btcjson.TemplateRequest {
+		return &btcjson.TemplateRequest{
+			Capabilities: []string{"coinbasetxn"},
+			Rules:        []string{"segwit"},
+			CoinbaseOutputs: []btcjson.TemplateRequestCoinbaseOutput{{
+				Script: script,
+				Value:  0,
+			}},
+		}
+	}
+	getTemplate := func(script string) *btcjson.GetBlockTemplateResult {
+		t.Helper()
+		result, err := client.GetBlockTemplate(templateRequest(script))
+		if err != nil {
+			t.Fatalf("unable to get block template: %v", err)
+		}
+		if result.CoinbaseTxn == nil ||
+			!strings.Contains(result.CoinbaseTxn.Data, script) {
+
+			t.Fatalf("block template coinbase doesn't include "+
+				"output script %s", script)
+		}
+		return result
+	}
+
+	// Wait for a second between the requests so a template generated
+	// again would have a different long poll ID.
+	first := getTemplate("6a0161")
+	time.Sleep(time.Second + 100*time.Millisecond)
+	other := getTemplate("6a0162")
+	again := getTemplate("6a0161")
+
+	// The templates with different coinbase outputs don't replace each
+	// other, so they share the long poll ID of the first template.
+	if other.LongPollID != first.LongPollID ||
+		again.LongPollID != first.LongPollID {
+
+		t.Fatalf("got long poll IDs %s, %s and %s, want %s",
+			first.LongPollID, other.LongPollID, again.LongPollID,
+			first.LongPollID)
+	}
+}
+
+func testSetBan(r *rpctest.Harness, t *testing.T) {
+	client := r.Client
+
+	err := client.SetBan("192.168.0.0/24", btcjson.SBAdd, nil, nil)
+	if err != nil {
+		t.Fatalf("unable to ban subnet: %v", err)
+	}
+	banTime := int64(3600)
+	err = client.SetBan("10.0.0.1", btcjson.SBAdd, &banTime, nil)
+	if err != nil {
+		t.Fatalf("unable to ban IP: %v", err)
+	}
+	err = client.SetBan("10.0.0.1", btcjson.SBAdd, nil, nil)
+	if err == nil {
+		t.Fatal("banned an IP which is already banned")
+	}
+	err = client.SetBan("10.0.0.1:8333", btcjson.SBAdd, nil, nil)
+	if err == nil {
+		t.Fatal("banned an invalid IP")
+	}
+
+	bans, err := client.ListBanned()
+	if err != nil {
+		t.Fatalf("unable to list bans: %v", err)
+	}
+	if len(bans) != 2 {
+		t.Fatalf("got %d bans, want 2", len(bans))
+	}
+	for _, ban := range bans {
+		if ban.Address == "10.0.0.1/32" && ban.BanDuration != banTime {
+			t.Fatalf("got ban of %s for %d seconds, want %d seconds",
+				ban.Address, ban.BanDuration, banTime)
+		}
+	}
+
+	if err := client.SetBan("10.0.0.1", btcjson.SBRemove, nil, nil); err != nil {
+		t.Fatalf("unable to unban IP: %v", err)
+	}
+	if err := client.SetBan("10.0.0.1", btcjson.SBRemove, nil, nil); err == nil {
+		t.Fatal("unbanned an IP which is not banned")
+	}
+	if err := client.ClearBanned(); err != nil {
+		t.Fatalf("unable to clear bans: %v", err)
+	}
+	bans, err = client.ListBanned()
+	if err != nil {
+		t.Fatalf("unable to list bans: %v", err)
+	}
+	if len(bans) != 0 {
+		t.Fatalf("got %d bans after clearing them", len(bans))
+	}
+
+	if err := client.DisconnectNode("10.0.0.1:8333"); err == nil {
+		t.Fatal("disconnected a peer which is not connected")
+	}
+}
+
 var rpcTestCases = []rpctest.HarnessTestCase{
 	testGetBestBlock,
 	testGetBlockCount,
@@ -297,6 +402,8 @@ var rpcTestCases = []rpctest.HarnessTestCase{
 	testGetNetworkHashPS,
 	testGetNetworkHashPS2,
 	testGetNetworkHashPS3,
+	testGetBlockTemplateCoinbaseOutputs,
+	testSetBan,
 }
 
 var primaryHarness *rpctest.Harness
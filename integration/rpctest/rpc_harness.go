@@ -5,6 +5,8 @@
 package rpctest
 
 import (
+	"bytes"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -522,6 +524,43 @@ func (h *Harness) GenerateAndSubmitBlockWithCustomCoinbaseOutputs(
 	return newBlock, nil
 }
 
+// GenerateToAddress mines the passed number of blocks paying to the passed
+// address using the generatetoaddress RPC of the running node and returns
+// their hashes.
+//
+// This function is safe for concurrent access.
+func (h *Harness) GenerateToAddress(numBlocks uint32,
+	addr btcutil.Address) ([]*chainhash.Hash, error) {
+
+	return h.Client.GenerateToAddress(int64(numBlocks), addr, nil)
+}
+
+// GenerateBlock mines a block paying to the passed address which contains
+// exactly the passed transactions in the passed order using the generateblock
+// RPC of the running node.  The transactions do not need to be in the mempool
+// of the node.  The hash of the new block is returned.
+//
+// This function is safe for concurrent access.
+func (h *Harness) GenerateBlock(addr btcutil.Address,
+	txns []*btcutil.Tx) (*chainhash.Hash, error) {
+
+	rawTxns := make([]string, 0, len(txns))
+	for _, tx := range txns {
+		var buf bytes.Buffer
+		if err := tx.MsgTx().Serialize(&buf); err != nil {
+			return nil, err
+		}
+		rawTxns = append(rawTxns, hex.EncodeToString(buf.Bytes()))
+	}
+
+	result, err := h.Client.GenerateBlock(addr.EncodeAddress(), rawTxns,
+		nil)
+	if err != nil {
+		return nil, err
+	}
+	return chainhash.NewHashFromStr(result.Hash)
+}
+
 // generateListeningAddresses is a function that returns two listener
 // addresses with unique ports and should be used to overwrite rpctest's
 // default generator which is prone to use colliding ports.
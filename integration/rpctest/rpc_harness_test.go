@@ -9,6 +9,7 @@
 package rpctest
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"testing"
@@ -326,6 +327,76 @@ func testJoinBlocks(r *Harness, t *testing.T) {
 	}
 }
 
+func testGenerateToAddress(r *Harness, t *testing.T) {
+	addr, err := r.NewAddress()
+	if err != nil {
+		t.Fatalf("unable to generate new address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create script: %v", err)
+	}
+
+	blockHashes, err := r.GenerateToAddress(2, addr)
+	if err != nil {
+		t.Fatalf("unable to generate blocks: %v", err)
+	}
+	if len(blockHashes) != 2 {
+		t.Fatalf("unexpected number of blocks: got %d, want 2",
+			len(blockHashes))
+	}
+
+	// Ensure the coinbase of each block pays to the address.
+	for _, hash := range blockHashes {
+		block, err := r.Client.GetBlock(hash)
+		if err != nil {
+			t.Fatalf("unable to get block %v: %v", hash, err)
+		}
+		txOut := block.Transactions[0].TxOut[0]
+		if !bytes.Equal(txOut.PkScript, pkScript) {
+			t.Fatalf("coinbase of block %v does not pay to %v",
+				hash, addr)
+		}
+	}
+}
+
+func testGenerateBlock(r *Harness, t *testing.T) {
+	addr, err := r.NewAddress()
+	if err != nil {
+		t.Fatalf("unable to generate new address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create script: %v", err)
+	}
+	output := wire.NewTxOut(btcutil.SatoshiPerBitcoin, pkScript)
+
+	// Create a transaction which is never broadcast so it is not in the
+	// mempool.
+	tx, err := r.CreateTransaction([]*wire.TxOut{output}, 10, true)
+	if err != nil {
+		t.Fatalf("unable to create tx: %v", err)
+	}
+
+	blockHash, err := r.GenerateBlock(addr, []*btcutil.Tx{btcutil.NewTx(tx)})
+	if err != nil {
+		t.Fatalf("unable to generate block: %v", err)
+	}
+	block, err := r.Client.GetBlock(blockHash)
+	if err != nil {
+		t.Fatalf("unable to get block %v: %v", blockHash, err)
+	}
+	if len(block.Transactions) != 2 ||
+		block.Transactions[1].TxHash() != tx.TxHash() {
+
+		t.Fatalf("block does not contain exactly the transaction")
+	}
+	if !bytes.Equal(block.Transactions[0].TxOut[0].PkScript, pkScript) {
+		t.Fatalf("coinbase of block %v does not pay to %v", blockHash,
+			addr)
+	}
+}
+
 func testGenerateAndSubmitBlock(r *Harness, t *testing.T) {
 	// Generate a few test spend transactions.
 	addr, err := r.NewAddress()
@@ -553,6 +624,8 @@ var harnessTestCases = []HarnessTestCase{
 	testActiveHarnesses,
 	testJoinBlocks,
 	testJoinMempools, // Depends on results of testJoinBlocks
+	testGenerateToAddress,
+	testGenerateBlock,
 	testGenerateAndSubmitBlock,
 	testGenerateAndSubmitBlockWithCustomCoinbaseOutputs,
 	testMemWalletReorg,
@@ -43,6 +43,9 @@ be an exhaustive list.
    - Reject invalid transactions according to the network consensus rules
    - Full script execution and validation with signature cache support
    - Individual transaction query support
+   - TRUC (version 3) topology restrictions with sibling eviction,
+     ephemeral dust support and acceptance of a TRUC parent along with an
+     orphan child which pays for it (1p1c package)
  - Orphan transaction support (transactions that spend from unknown outputs)
    - Configurable limits (see transaction acceptance policy)
    - Automatic addition of orphan transactions that are no longer orphans as new
@@ -5,6 +5,8 @@
 package mempool
 
 import (
+	"fmt"
+
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/wire"
 )
@@ -50,6 +52,74 @@ func txRuleError(c wire.RejectCode, desc string) RuleError {
 	}
 }
 
+// RBFRule identifies one of the rules a transaction must satisfy in order to
+// replace transactions in the memory pool.  The first five rules are the ones
+// defined by BIP 125, while the remaining ones are additional policy rules that
+// are also enforced by the reference implementation.
+type RBFRule int
+
+// These constants identify the replacement rules that can be violated by a
+// replacement transaction.
+const (
+	// RBFRuleSignaling is violated when a transaction being replaced
+	// doesn't signal replaceability, either explicitly or through one of
+	// its unconfirmed ancestors.
+	RBFRuleSignaling RBFRule = 1
+
+	// RBFRuleNewUnconfirmedInput is violated when the replacement spends
+	// an unconfirmed output that isn't already spent by one of the
+	// transactions being replaced.
+	RBFRuleNewUnconfirmedInput RBFRule = 2
+
+	// RBFRuleAbsoluteFee is violated when the replacement doesn't pay a
+	// higher absolute fee than the sum of the transactions being replaced.
+	RBFRuleAbsoluteFee RBFRule = 3
+
+	// RBFRuleIncrementalFee is violated when the additional fee paid by
+	// the replacement doesn't cover its own bandwidth at the minimum relay
+	// fee rate.
+	RBFRuleIncrementalFee RBFRule = 4
+
+	// RBFRuleEvictionCount is violated when the replacement would evict
+	// more than MaxReplacementEvictions transactions from the pool.
+	RBFRuleEvictionCount RBFRule = 5
+
+	// RBFRuleFeeRate is violated when the replacement doesn't pay a higher
+	// fee rate than each of the transactions being replaced.
+	RBFRuleFeeRate RBFRule = 6
+)
+
+// Map of RBFRule values back to their descriptive names for pretty printing.
+var rbfRuleStrings = map[RBFRule]string{
+	RBFRuleSignaling:           "signaling",
+	RBFRuleNewUnconfirmedInput: "new unconfirmed input",
+	RBFRuleAbsoluteFee:         "absolute fee",
+	RBFRuleIncrementalFee:      "incremental feerate",
+	RBFRuleEvictionCount:       "eviction count",
+	RBFRuleFeeRate:             "feerate",
+}
+
+// String returns the RBFRule as a human-readable name.
+func (r RBFRule) String() string {
+	if s, ok := rbfRuleStrings[r]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown RBFRule (%d)", int(r))
+}
+
+// replacementRuleError creates an underlying TxRuleError for a replacement
+// transaction that violates the given rule.  The rule is appended to the
+// description so that it is included in the reject reason.
+func replacementRuleError(c wire.RejectCode, rule RBFRule, desc string) RuleError {
+	prefix := "BIP125"
+	if rule > RBFRuleEvictionCount {
+		prefix = "replacement"
+	}
+	desc = fmt.Sprintf("%s (%s rule #%d: %s)", desc, prefix, int(rule),
+		rule)
+	return txRuleError(c, desc)
+}
+
 // chainRuleError returns a RuleError that encapsulates the given
 // blockchain.RuleError.
 func chainRuleError(chainErr blockchain.RuleError) RuleError {
@@ -178,6 +178,9 @@ type FeeEstimator struct {
 	// Transactions that have been removed from the bins. This allows us to
 	// revert in case of an orphaned block.
 	dropped []*registeredBlock
+
+	// The bucketed confirmation statistics used for smart fee estimation.
+	smart *smartFeeEstimator
 }
 
 // NewFeeEstimator creates a FeeEstimator for which at most maxRollback blocks
@@ -192,6 +195,7 @@ func NewFeeEstimator(maxRollback, minRegisteredBlocks uint32) *FeeEstimator {
 		maxReplacements:     estimateFeeMaxReplacements,
 		observed:            make(map[chainhash.Hash]*observedTransaction),
 		dropped:             make([]*registeredBlock, 0, maxRollback),
+		smart:               newSmartFeeEstimator(),
 	}
 }
 
@@ -207,9 +211,8 @@ func (ef *FeeEstimator) ObserveTransaction(t *TxDesc) {
 	}
 
 	hash := *t.Tx.Hash()
+	size := uint32(GetTxVirtualSize(t.Tx))
 	if _, ok := ef.observed[hash]; !ok {
-		size := uint32(GetTxVirtualSize(t.Tx))
-
 		ef.observed[hash] = &observedTransaction{
 			hash:     hash,
 			feeRate:  NewSatoshiPerByte(btcutil.Amount(t.Fee), size),
@@ -217,6 +220,10 @@ func (ef *FeeEstimator) ObserveTransaction(t *TxDesc) {
 			mined:    mining.UnminedHeight,
 		}
 	}
+
+	// Track the transaction for smart fee estimation as well.
+	ef.smart.processTransaction(hash, t.Height,
+		float64(t.Fee)*bytePerKb/float64(size))
 }
 
 // RegisterBlock informs the fee estimator of a new block to take into account.
@@ -237,6 +244,9 @@ func (ef *FeeEstimator) RegisterBlock(block *btcutil.Block) error {
 	ef.lastKnownHeight = height
 	ef.numBlocksRegistered++
 
+	// Update the smart fee estimation statistics.
+	ef.smart.processBlock(height, block.Transactions())
+
 	// Randomly order txs in block.
 	transactions := make(map[*btcutil.Tx]struct{})
 	for _, t := range block.Transactions() {
@@ -587,7 +597,7 @@ func (ef *FeeEstimator) EstimateFee(numBlocks uint32) (BtcPerKilobyte, error) {
 // we use a version number. If the version number changes, it does not make
 // sense to try to upgrade a previous version to a new version. Instead, just
 // start fee estimation over.
-const estimateFeeSaveVersion = 1
+const estimateFeeSaveVersion = 2
 
 func deserializeRegisteredBlock(r io.Reader, txs map[uint32]*observedTransaction) (*registeredBlock, error) {
 	var lenTransactions uint32
@@ -680,6 +690,9 @@ func (ef *FeeEstimator) Save() FeeEstimatorState {
 		registered.serialize(w, observed)
 	}
 
+	// Smart fee estimation statistics.
+	ef.smart.serialize(w)
+
 	// Commit the tx and return.
 	return FeeEstimatorState(w.Bytes())
 }
@@ -754,5 +767,11 @@ func RestoreFeeEstimator(data FeeEstimatorState) (*FeeEstimator, error) {
 		}
 	}
 
+	// Read smart fee estimation statistics.
+	ef.smart, err = deserializeSmartFeeEstimator(r)
+	if err != nil {
+		return nil, err
+	}
+
 	return ef, nil
 }
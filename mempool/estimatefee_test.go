@@ -6,6 +6,7 @@ package mempool
 
 import (
 	"bytes"
+	"math"
 	"math/rand"
 	"testing"
 
@@ -26,6 +27,7 @@ func newTestFeeEstimator(binSize, maxReplacements, maxRollback uint32) *FeeEstim
 		maxReplacements:     int32(maxReplacements),
 		observed:            make(map[chainhash.Hash]*observedTransaction),
 		dropped:             make([]*registeredBlock, 0, maxRollback),
+		smart:               newSmartFeeEstimator(),
 	}
 }
 
@@ -422,3 +424,117 @@ func TestDatabase(t *testing.T) {
 		eft.checkSaveAndRestore(estimateHistory[len(estimateHistory)-round-1])
 	}
 }
+
+// TestEstimateSmartFee tests the bucketed fee estimation used for smart fee
+// estimates along with its persistence.
+func TestEstimateSmartFee(t *testing.T) {
+	ef := NewFeeEstimator(DefaultEstimateFeeMaxRollback,
+		DefaultEstimateFeeMinRegisteredBlocks)
+	eft := estimateFeeTester{ef: ef, t: t}
+
+	// The test transactions have a virtual size of 10 bytes, so these fees
+	// result in fee rates of 50000 and 1000 satoshis per kilo virtual
+	// byte respectively.
+	const highFee, lowFee = 500, 10
+	expected := BtcPerKilobyte(50000 * btcPerSatoshi)
+
+	// mineBlocks adds high and low fee transactions to the estimator and
+	// mines a block with only the high fee transactions for the passed
+	// number of blocks.
+	mineBlocks := func(numBlocks int) {
+		for i := 0; i < numBlocks; i++ {
+			var mined []*wire.MsgTx
+			for j := 0; j < 10; j++ {
+				highTx := eft.testTx(highFee)
+				eft.ef.ObserveTransaction(highTx)
+				eft.ef.ObserveTransaction(eft.testTx(lowFee))
+				mined = append(mined, highTx.Tx.MsgTx())
+			}
+			eft.newBlock(mined)
+		}
+	}
+
+	// Estimates aren't available before transactions have been confirmed
+	// over a few blocks.
+	eft.newBlock(nil)
+	mineBlocks(3)
+	if _, target, err := ef.EstimateSmartFee(2, false); err == nil {
+		t.Fatalf("expected estimate to fail with insufficient data, "+
+			"got target %d", target)
+	}
+
+	// Invalid confirmation targets are rejected.
+	for _, target := range []uint32{0, 1009} {
+		if _, _, err := ef.EstimateSmartFee(target, false); err == nil {
+			t.Fatalf("expected confirmation target %d to be "+
+				"rejected", target)
+		}
+	}
+
+	// With enough data, both modes should estimate the rate of the high
+	// fee transactions since the low fee transactions never confirm.
+	mineBlocks(40)
+	checkEstimate := func(ef *FeeEstimator, confTarget,
+		expectedTarget uint32, conservative bool) {
+
+		t.Helper()
+		feeRate, target, err := ef.EstimateSmartFee(confTarget,
+			conservative)
+		if err != nil {
+			t.Fatalf("unable to estimate fee for target %d: %v",
+				confTarget, err)
+		}
+		if math.Abs(float64(feeRate-expected)) > 1e-12 {
+			t.Fatalf("expected fee rate %v for target %d, got %v",
+				expected, confTarget, feeRate)
+		}
+		if target != expectedTarget {
+			t.Fatalf("expected target %d, got %d", expectedTarget,
+				target)
+		}
+	}
+	checkEstimate(ef, 1, 2, false)
+	checkEstimate(ef, 6, 6, false)
+	checkEstimate(ef, 6, 6, true)
+
+	// Targets beyond the recorded history are clamped to half of it.
+	checkEstimate(ef, 1000, 21, false)
+
+	// The raw estimate should report the range of buckets containing the
+	// high fee rate as passing and the one containing the low fee rate as
+	// failing.
+	raw, err := ef.EstimateRawFee(2, DefaultEstimateRawFeeThreshold,
+		FeeHorizonShort)
+	if err != nil {
+		t.Fatalf("unable to get raw fee estimate: %v", err)
+	}
+	if math.Abs(float64(raw.FeeRate-expected)) > 1e-12 {
+		t.Fatalf("expected raw fee rate %v, got %v", expected,
+			raw.FeeRate)
+	}
+	if raw.Pass.StartRange >= 50000 || raw.Pass.EndRange < 50000 {
+		t.Fatalf("passing range [%v, %v] does not contain the high "+
+			"fee rate", raw.Pass.StartRange, raw.Pass.EndRange)
+	}
+	if raw.Fail.StartRange >= 1000 || raw.Fail.EndRange < 1000 {
+		t.Fatalf("failing range [%v, %v] does not contain the low "+
+			"fee rate", raw.Fail.StartRange, raw.Fail.EndRange)
+	}
+	if raw.Fail.InMempool == 0 {
+		t.Fatal("expected unconfirmed transactions in failing range")
+	}
+	if _, err := ef.EstimateRawFee(2, 1.5, FeeHorizonShort); err == nil {
+		t.Fatal("expected invalid threshold to be rejected")
+	}
+	if _, err := ef.EstimateRawFee(13, 0.95, FeeHorizonShort); err == nil {
+		t.Fatal("expected target beyond the short horizon to be " +
+			"rejected")
+	}
+
+	// The estimates should survive a save and restore.
+	restored, err := RestoreFeeEstimator(ef.Save())
+	if err != nil {
+		t.Fatalf("unable to restore fee estimator: %v", err)
+	}
+	checkEstimate(restored, 6, 6, true)
+}
@@ -48,6 +48,10 @@ const (
 	// can be evicted from the mempool when accepting a transaction
 	// replacement.
 	MaxReplacementEvictions = 100
+
+	// DefaultExpiry is the default maximum amount of time a transaction
+	// may stay in the mempool before it expires.
+	DefaultExpiry = time.Hour * 336
 )
 
 // Tag represents an identifier to use for tagging orphan transactions.  The
@@ -55,6 +59,72 @@ const (
 // so that orphans can be identified by which peer first relayed them.
 type Tag uint64
 
+// RemovalReason describes why a transaction was removed from the mempool.
+type RemovalReason int
+
+const (
+	// RemovalReasonUnknown indicates the transaction was removed for a
+	// reason not covered by any of the other removal reasons.
+	RemovalReasonUnknown RemovalReason = iota
+
+	// RemovalReasonExpiry indicates the transaction, or one of its
+	// ancestors, stayed in the mempool for longer than the expiry timeout.
+	RemovalReasonExpiry
+
+	// RemovalReasonBlock indicates the transaction was included in a block
+	// connected to the main chain.
+	RemovalReasonBlock
+
+	// RemovalReasonConflict indicates the transaction, or one of its
+	// ancestors, spends an output that was spent by a transaction included
+	// in a block connected to the main chain.
+	RemovalReasonConflict
+
+	// RemovalReasonReplaced indicates the transaction, or one of its
+	// ancestors, was replaced by a transaction paying a higher fee.
+	RemovalReasonReplaced
+
+	// RemovalReasonReorg indicates the transaction is no longer valid
+	// following a chain reorganization.
+	RemovalReasonReorg
+)
+
+// removalReasonStrings is a map of removal reasons back to their constant
+// names for pretty printing.
+var removalReasonStrings = map[RemovalReason]string{
+	RemovalReasonUnknown:  "unknown",
+	RemovalReasonExpiry:   "expiry",
+	RemovalReasonBlock:    "block",
+	RemovalReasonConflict: "conflict",
+	RemovalReasonReplaced: "replaced",
+	RemovalReasonReorg:    "reorg",
+}
+
+// String returns the RemovalReason as a human-readable name.
+func (r RemovalReason) String() string {
+	if s := removalReasonStrings[r]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown RemovalReason (%d)", int(r))
+}
+
+// TxRemoval describes the removal of a transaction from the main pool.
+type TxRemoval struct {
+	// Tx is the transaction that was removed.
+	Tx *btcutil.Tx
+
+	// Reason is the reason the transaction was removed.
+	Reason RemovalReason
+
+	// ReplacedBy is the hash of the transaction responsible for the
+	// removal when the reason is RemovalReasonReplaced or
+	// RemovalReasonConflict.  It is nil otherwise.
+	ReplacedBy *chainhash.Hash
+
+	// Sequence is the mempool sequence number assigned to the removal.
+	Sequence uint64
+}
+
 // Config is a descriptor containing the memory pool configuration.
 type Config struct {
 	// Policy defines the various mempool configuration options related
@@ -103,6 +173,23 @@ type Config struct {
 	// FeeEstimatator provides a feeEstimator. If it is not nil, the mempool
 	// records all new transactions it observes into the feeEstimator.
 	FeeEstimator *FeeEstimator
+
+	// TxRemoved defines an optional function to call when a transaction is
+	// removed from the main pool along with the reason it was removed.  See
+	// TxAccepted for how the notifications are delivered.
+	TxRemoved func(removal *TxRemoval)
+
+	// TxAccepted defines an optional function to call when a transaction
+	// is added to the main pool.  It is also invoked with the updated
+	// descriptor when the fee delta of a transaction in the main pool is
+	// changed via PrioritiseTransaction.
+	//
+	// The additions and removals are delivered to TxAccepted and TxRemoved
+	// asynchronously by a single goroutine owned by the pool, one at a
+	// time and in the order they occurred, so modifying the pool never
+	// waits for the callbacks.  The callbacks are invoked without the
+	// mempool lock held and may query or modify the pool.
+	TxAccepted func(txDesc *TxDesc)
 }
 
 // Policy houses the policy (configuration parameters) which is used to
@@ -130,6 +217,14 @@ type Policy struct {
 	// that can be queued.
 	MaxOrphanTxs int
 
+	// MaxOrphanTxsPerTag is the maximum number of orphan transactions with
+	// the same tag, which typically identifies the peer that sent them,
+	// that can be queued.  Once reached, the oldest orphan with the tag is
+	// evicted to make room for a new one, so a single peer can't take over
+	// the orphan pool.  Zero means orphans are only limited by
+	// MaxOrphanTxs.
+	MaxOrphanTxsPerTag int
+
 	// MaxOrphanTxSize is the maximum size allowed for orphan transactions.
 	// This helps prevent memory exhaustion attacks from sending a lot of
 	// of big orphans.
@@ -148,6 +243,17 @@ type Policy struct {
 	// transactions using the Replace-By-Fee (RBF) signaling policy into
 	// the mempool.
 	RejectReplacement bool
+
+	// FullRBF, if true, accepts replacement transactions into the mempool
+	// regardless of whether or not the transactions they replace signal
+	// replaceability.  All other replacement rules still apply.  It has no
+	// effect when RejectReplacement is set.
+	FullRBF bool
+
+	// Expiry is the maximum amount of time a transaction may stay in the
+	// mempool before it's evicted along with its descendants.  A value of
+	// zero disables expiry.
+	Expiry time.Duration
 }
 
 // TxDesc is a descriptor containing a transaction in the mempool along with
@@ -158,15 +264,20 @@ type TxDesc struct {
 	// StartingPriority is the priority of the transaction when it was added
 	// to the pool.
 	StartingPriority float64
+
+	// Sequence is the mempool sequence number assigned to the transaction
+	// when it was added to the pool.
+	Sequence uint64
 }
 
 // orphanTx is normal transaction that references an ancestor transaction
 // that is not yet available.  It also contains additional information related
 // to it such as an expiration time to help prevent caching the orphan forever.
 type orphanTx struct {
-	tx         *btcutil.Tx
-	tag        Tag
-	expiration time.Time
+	tx             *btcutil.Tx
+	tag            Tag
+	expiration     time.Time
+	missingParents []*chainhash.Hash
 }
 
 // TxPool is used as a source of transactions that need to be mined into blocks
@@ -174,17 +285,51 @@ type orphanTx struct {
 // peers.
 type TxPool struct {
 	// The following variables must only be used atomically.
-	lastUpdated int64 // last time pool was updated
+	lastUpdated int64  // last time pool was updated
+	numExpired  uint64 // total number of expired transactions
 
 	mtx           sync.RWMutex
 	cfg           Config
 	pool          map[chainhash.Hash]*TxDesc
 	orphans       map[chainhash.Hash]*orphanTx
 	orphansByPrev map[wire.OutPoint]map[chainhash.Hash]*btcutil.Tx
+	orphansByTag  map[Tag]int
 	outpoints     map[wire.OutPoint]*btcutil.Tx
+
+	// poolByWTxId and orphansByWTxId map the witness hashes of the
+	// transactions in the main pool and orphan pool, respectively, to
+	// their hashes so transactions announced by their witness hash can be
+	// looked up.
+	poolByWTxId    map[chainhash.Hash]chainhash.Hash
+	orphansByWTxId map[chainhash.Hash]chainhash.Hash
+
 	pennyTotal    float64 // exponentially decaying total for penny spends.
 	lastPennyUnix int64   // unix time of last ``penny spend''
 
+	// feeDeltas houses the fee adjustments that have been applied to
+	// transactions via PrioritiseTransaction.  Entries may refer to
+	// transactions that are not (yet) in the pool.
+	feeDeltas map[chainhash.Hash]int64
+
+	// sequence is incremented every time a transaction is added to or
+	// removed from the main pool.  Each addition and removal is assigned
+	// the resulting value which allows consumers to order the events and
+	// detect any they have missed.
+	sequence uint64
+
+	// pendingNtfns houses additions and removals that have yet to be
+	// queued for delivery to the TxAccepted and TxRemoved callbacks.  They
+	// are queued when the mempool lock is released.
+	pendingNtfns []poolNtfn
+
+	// ntfnQueue houses the additions and removals that have been queued
+	// for delivery in the order they occurred.  They are delivered by a
+	// single goroutine which is running whenever ntfnDelivering is set.
+	// Both fields are protected by ntfnMtx.
+	ntfnMtx        sync.Mutex
+	ntfnQueue      []poolNtfn
+	ntfnDelivering bool
+
 	// nextExpireScan is the time after which the orphan pool will be
 	// scanned in order to evict orphans.  This is NOT a hard deadline as
 	// the scan will only run when an orphan is added to the pool as opposed
@@ -234,6 +379,10 @@ func (mp *TxPool) removeOrphan(tx *btcutil.Tx, removeRedeemers bool) {
 
 	// Remove the transaction from the orphan pool.
 	delete(mp.orphans, *txHash)
+	delete(mp.orphansByWTxId, *tx.WitnessHash())
+	if mp.orphansByTag[otx.tag]--; mp.orphansByTag[otx.tag] <= 0 {
+		delete(mp.orphansByTag, otx.tag)
+	}
 }
 
 // RemoveOrphan removes the passed orphan transaction from the orphan pool and
@@ -263,6 +412,23 @@ func (mp *TxPool) RemoveOrphansByTag(tag Tag) uint64 {
 	return numEvicted
 }
 
+// OrphanMissingParents returns the hashes of the transactions the orphan
+// transaction with the passed hash spends outputs of which were unknown when it
+// was added to the orphan pool.  It returns nil when the transaction is not in
+// the orphan pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) OrphanMissingParents(hash *chainhash.Hash) []*chainhash.Hash {
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	otx, exists := mp.orphans[*hash]
+	if !exists {
+		return nil
+	}
+	return otx.missingParents
+}
+
 // limitNumOrphans limits the number of orphan transactions by evicting a random
 // orphan if adding a new one would cause it to overflow the max allowed.
 //
@@ -316,25 +482,58 @@ func (mp *TxPool) limitNumOrphans() error {
 	return nil
 }
 
-// addOrphan adds an orphan transaction to the orphan pool.
+// limitNumOrphansByTag limits the number of orphan transactions with the passed
+// tag by evicting the oldest orphan with the tag if adding a new one would cause
+// it to exceed the max allowed per tag.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) limitNumOrphansByTag(tag Tag) {
+	maxPerTag := mp.cfg.Policy.MaxOrphanTxsPerTag
+	if maxPerTag <= 0 || mp.orphansByTag[tag]+1 <= maxPerTag {
+		return
+	}
+
+	var oldest *orphanTx
+	for _, otx := range mp.orphans {
+		if otx.tag == tag && (oldest == nil ||
+			otx.expiration.Before(oldest.expiration)) {
+
+			oldest = otx
+		}
+	}
+	if oldest != nil {
+		log.Debugf("Evicting orphan transaction %v since tag %d "+
+			"exceeds the limit of %d orphans", oldest.tx.Hash(), tag,
+			maxPerTag)
+		mp.removeOrphan(oldest.tx, false)
+	}
+}
+
+// addOrphan adds an orphan transaction which spends outputs of the passed
+// missing parent transactions to the orphan pool.
 //
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) addOrphan(tx *btcutil.Tx, tag Tag) {
+func (mp *TxPool) addOrphan(tx *btcutil.Tx, tag Tag, missingParents []*chainhash.Hash) {
 	// Nothing to do if no orphans are allowed.
 	if mp.cfg.Policy.MaxOrphanTxs <= 0 {
 		return
 	}
 
 	// Limit the number orphan transactions to prevent memory exhaustion.
-	// This will periodically remove any expired orphans and evict a random
-	// orphan if space is still needed.
+	// This will evict the oldest orphan with the same tag when the tag
+	// has reached its quota, periodically remove any expired orphans,
+	// and evict a random orphan if space is still needed.
+	mp.limitNumOrphansByTag(tag)
 	mp.limitNumOrphans()
 
 	mp.orphans[*tx.Hash()] = &orphanTx{
-		tx:         tx,
-		tag:        tag,
-		expiration: time.Now().Add(orphanTTL),
+		tx:             tx,
+		tag:            tag,
+		expiration:     time.Now().Add(orphanTTL),
+		missingParents: missingParents,
 	}
+	mp.orphansByWTxId[*tx.WitnessHash()] = *tx.Hash()
+	mp.orphansByTag[tag]++
 	for _, txIn := range tx.MsgTx().TxIn {
 		if _, exists := mp.orphansByPrev[txIn.PreviousOutPoint]; !exists {
 			mp.orphansByPrev[txIn.PreviousOutPoint] =
@@ -347,10 +546,11 @@ func (mp *TxPool) addOrphan(tx *btcutil.Tx, tag Tag) {
 		len(mp.orphans))
 }
 
-// maybeAddOrphan potentially adds an orphan to the orphan pool.
+// maybeAddOrphan potentially adds an orphan which spends outputs of the passed
+// missing parent transactions to the orphan pool.
 //
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) maybeAddOrphan(tx *btcutil.Tx, tag Tag) error {
+func (mp *TxPool) maybeAddOrphan(tx *btcutil.Tx, tag Tag, missingParents []*chainhash.Hash) error {
 	// Ignore orphan transactions that are too large.  This helps avoid
 	// a memory exhaustion attack based on sending a lot of really large
 	// orphans.  In the case there is a valid transaction larger than this,
@@ -370,7 +570,7 @@ func (mp *TxPool) maybeAddOrphan(tx *btcutil.Tx, tag Tag) error {
 	}
 
 	// Add the orphan if the none of the above disqualified it.
-	mp.addOrphan(tx, tag)
+	mp.addOrphan(tx, tag, missingParents)
 
 	return nil
 }
@@ -462,18 +662,39 @@ func (mp *TxPool) HaveTransaction(hash *chainhash.Hash) bool {
 	return haveTx
 }
 
+// HaveTransactionByWitnessHash returns whether or not a transaction with the
+// passed witness hash already exists in the main pool or in the orphan pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) HaveTransactionByWitnessHash(wtxid *chainhash.Hash) bool {
+	// Protect concurrent access.
+	mp.mtx.RLock()
+	_, inPool := mp.poolByWTxId[*wtxid]
+	_, isOrphan := mp.orphansByWTxId[*wtxid]
+	mp.mtx.RUnlock()
+
+	return inPool || isOrphan
+}
+
 // removeTransaction is the internal function which implements the public
 // RemoveTransaction.  See the comment for RemoveTransaction for more details.
 //
+// The passed reason, along with the hash of the transaction responsible for
+// the removal if any, is attached to the removal of the transaction and all of
+// the redeemers removed along with it.
+//
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) removeTransaction(tx *btcutil.Tx, removeRedeemers bool) {
+func (mp *TxPool) removeTransaction(tx *btcutil.Tx, removeRedeemers bool,
+	reason RemovalReason, replacedBy *chainhash.Hash) {
+
 	txHash := tx.Hash()
 	if removeRedeemers {
 		// Remove any transactions which rely on this one.
 		for i := uint32(0); i < uint32(len(tx.MsgTx().TxOut)); i++ {
 			prevOut := wire.OutPoint{Hash: *txHash, Index: i}
 			if txRedeemer, exists := mp.outpoints[prevOut]; exists {
-				mp.removeTransaction(txRedeemer, true)
+				mp.removeTransaction(txRedeemer, true, reason,
+					replacedBy)
 			}
 		}
 	}
@@ -491,21 +712,175 @@ func (mp *TxPool) removeTransaction(tx *btcutil.Tx, removeRedeemers bool) {
 			delete(mp.outpoints, txIn.PreviousOutPoint)
 		}
 		delete(mp.pool, *txHash)
+		delete(mp.poolByWTxId, *tx.WitnessHash())
 		atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+
+		// Inform the fee estimator the transaction failed to confirm
+		// unless it was removed due to being included in a block.
+		if mp.cfg.FeeEstimator != nil && reason != RemovalReasonBlock {
+			mp.cfg.FeeEstimator.RemoveTransaction(txHash)
+		}
+
+		// Queue the removal to be delivered once the lock is released.
+		mp.sequence++
+		if mp.cfg.TxRemoved != nil {
+			mp.pendingNtfns = append(mp.pendingNtfns, poolNtfn{
+				removal: &TxRemoval{
+					Tx:         tx,
+					Reason:     reason,
+					ReplacedBy: replacedBy,
+					Sequence:   mp.sequence,
+				},
+			})
+		}
+	}
+}
+
+// poolNtfn is either an addition to or a removal from the main pool that is
+// queued for delivery to the TxAccepted or TxRemoved callback.
+type poolNtfn struct {
+	accepted *TxDesc
+	removal  *TxRemoval
+}
+
+// unlockAndNotify queues any additions and removals that were made while the
+// mempool lock was held for delivery to the TxAccepted and TxRemoved callbacks
+// and releases the lock.  The notifications are queued before the lock is
+// released, so they are always delivered in the order the changes to the pool
+// occurred, and the delivery goroutine is started when it isn't running.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) unlockAndNotify() {
+	if len(mp.pendingNtfns) > 0 {
+		mp.ntfnMtx.Lock()
+		mp.ntfnQueue = append(mp.ntfnQueue, mp.pendingNtfns...)
+		if !mp.ntfnDelivering {
+			mp.ntfnDelivering = true
+			go mp.deliverNotifications()
+		}
+		mp.ntfnMtx.Unlock()
+		mp.pendingNtfns = nil
+	}
+	mp.mtx.Unlock()
+}
+
+// deliverNotifications delivers the queued additions and removals to the
+// TxAccepted and TxRemoved callbacks in order until the queue is empty.
+//
+// It must be run as a goroutine.
+func (mp *TxPool) deliverNotifications() {
+	for {
+		mp.ntfnMtx.Lock()
+		if len(mp.ntfnQueue) == 0 {
+			mp.ntfnQueue = nil
+			mp.ntfnDelivering = false
+			mp.ntfnMtx.Unlock()
+			return
+		}
+		ntfn := mp.ntfnQueue[0]
+		mp.ntfnQueue[0] = poolNtfn{}
+		mp.ntfnQueue = mp.ntfnQueue[1:]
+		mp.ntfnMtx.Unlock()
+
+		if ntfn.accepted != nil {
+			mp.cfg.TxAccepted(ntfn.accepted)
+		} else {
+			mp.cfg.TxRemoved(ntfn.removal)
+		}
 	}
 }
 
+// expireTransactions removes all transactions which have been in the pool for
+// longer than the expiry timeout as of the passed time, along with all of
+// their descendants, and returns the number of transactions removed.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) expireTransactions(now time.Time) int {
+	// Nothing to do when expiry is disabled.
+	if mp.cfg.Policy.Expiry <= 0 {
+		return 0
+	}
+
+	// Gather the expired transactions along with their descendants since
+	// they can't be mined without them.
+	cutoff := now.Add(-mp.cfg.Policy.Expiry)
+	expired := make(map[chainhash.Hash]*btcutil.Tx)
+	cache := make(map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx)
+	for txHash, txDesc := range mp.pool {
+		if !txDesc.Added.Before(cutoff) {
+			continue
+		}
+		expired[txHash] = txDesc.Tx
+		for hash, descendant := range mp.txDescendants(txDesc.Tx, cache) {
+			expired[hash] = descendant
+		}
+	}
+
+	// The set already includes all of the descendants, so there's no need
+	// to remove the redeemers of each transaction.
+	for _, tx := range expired {
+		mp.removeTransaction(tx, false, RemovalReasonExpiry, nil)
+	}
+
+	if numExpired := len(expired); numExpired > 0 {
+		atomic.AddUint64(&mp.numExpired, uint64(numExpired))
+		log.Debugf("Expired %d %s (pool size: %d)", numExpired,
+			pickNoun(numExpired, "transaction", "transactions"),
+			len(mp.pool))
+	}
+
+	return len(expired)
+}
+
+// ExpireTransactions removes all transactions which have been in the pool for
+// longer than the configured expiry timeout, along with all of their
+// descendants, and returns the number of transactions removed.  It is intended
+// to be called periodically.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) ExpireTransactions() int {
+	// Protect concurrent access.
+	mp.mtx.Lock()
+	numExpired := mp.expireTransactions(time.Now())
+	mp.unlockAndNotify()
+
+	return numExpired
+}
+
+// NumExpired returns the total number of transactions that have been expired
+// from the pool since it was created.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) NumExpired() uint64 {
+	return atomic.LoadUint64(&mp.numExpired)
+}
+
+// Sequence returns the current mempool sequence number.  It is incremented
+// every time a transaction is added to or removed from the main pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Sequence() uint64 {
+	mp.mtx.RLock()
+	sequence := mp.sequence
+	mp.mtx.RUnlock()
+
+	return sequence
+}
+
 // RemoveTransaction removes the passed transaction from the mempool. When the
 // removeRedeemers flag is set, any transactions that redeem outputs from the
 // removed transaction will also be removed recursively from the mempool, as
-// they would otherwise become orphans.
+// they would otherwise become orphans.  The passed reason is reported for all
+// removed transactions via the TxRemoved callback.
 //
 // This function is safe for concurrent access.
-func (mp *TxPool) RemoveTransaction(tx *btcutil.Tx, removeRedeemers bool) {
+func (mp *TxPool) RemoveTransaction(tx *btcutil.Tx, removeRedeemers bool,
+	reason RemovalReason) {
+
 	// Protect concurrent access.
 	mp.mtx.Lock()
-	mp.removeTransaction(tx, removeRedeemers)
-	mp.mtx.Unlock()
+	mp.removeTransaction(tx, removeRedeemers, reason, nil)
+	mp.unlockAndNotify()
 }
 
 // RemoveDoubleSpends removes all transactions which spend outputs spent by the
@@ -521,11 +896,12 @@ func (mp *TxPool) RemoveDoubleSpends(tx *btcutil.Tx) {
 	for _, txIn := range tx.MsgTx().TxIn {
 		if txRedeemer, ok := mp.outpoints[txIn.PreviousOutPoint]; ok {
 			if !txRedeemer.Hash().IsEqual(tx.Hash()) {
-				mp.removeTransaction(txRedeemer, true)
+				mp.removeTransaction(txRedeemer, true,
+					RemovalReasonConflict, tx.Hash())
 			}
 		}
 	}
-	mp.mtx.Unlock()
+	mp.unlockAndNotify()
 }
 
 // addTransaction adds the passed transaction to the memory pool.  It should
@@ -543,11 +919,15 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *btcutil
 			Height:   height,
 			Fee:      fee,
 			FeePerKB: fee * 1000 / GetTxVirtualSize(tx),
+			FeeDelta: mp.feeDeltas[*tx.Hash()],
 		},
 		StartingPriority: mining.CalcPriority(tx.MsgTx(), utxoView, height),
 	}
 
+	mp.sequence++
+	txD.Sequence = mp.sequence
 	mp.pool[*tx.Hash()] = txD
+	mp.poolByWTxId[*tx.WitnessHash()] = *tx.Hash()
 	for _, txIn := range tx.MsgTx().TxIn {
 		mp.outpoints[txIn.PreviousOutPoint] = tx
 	}
@@ -564,6 +944,11 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *btcutil
 		mp.cfg.FeeEstimator.ObserveTransaction(txD)
 	}
 
+	// Queue the addition to be delivered once the lock is released.
+	if mp.cfg.TxAccepted != nil {
+		mp.pendingNtfns = append(mp.pendingNtfns, poolNtfn{accepted: txD})
+	}
+
 	return txD
 }
 
@@ -585,15 +970,28 @@ func (mp *TxPool) checkPoolDoubleSpend(tx *btcutil.Tx) (bool, error) {
 		}
 
 		// Reject the transaction if we don't accept replacement
-		// transactions or if it doesn't signal replacement.
-		if mp.cfg.Policy.RejectReplacement ||
-			!mp.signalsReplacement(conflict, nil) {
+		// transactions.
+		if mp.cfg.Policy.RejectReplacement {
 			str := fmt.Sprintf("output %v already spent by "+
 				"transaction %v in the memory pool",
 				txIn.PreviousOutPoint, conflict.Hash())
 			return false, txRuleError(wire.RejectDuplicate, str)
 		}
 
+		// Reject the transaction if the one it conflicts with doesn't
+		// signal replacement, unless full RBF is enabled in which case
+		// signaling is not required.
+		if !mp.cfg.Policy.FullRBF &&
+			!mp.signalsReplacement(conflict, nil) {
+
+			str := fmt.Sprintf("output %v already spent by "+
+				"transaction %v in the memory pool",
+				txIn.PreviousOutPoint, conflict.Hash())
+			return false, replacementRuleError(
+				wire.RejectDuplicate, RBFRuleSignaling, str,
+			)
+		}
+
 		isReplacement = true
 	}
 
@@ -612,6 +1010,8 @@ func (mp *TxPool) checkPoolDoubleSpend(tx *btcutil.Tx) (bool, error) {
 // are replaceable under this policy for as long as any one of their ancestors
 // signals replaceability and remains unconfirmed.
 //
+// TRUC transactions are always considered to signal replaceability.
+//
 // The cache is optional and serves as an optimization to avoid visiting
 // transactions we've already determined don't signal replacement.
 //
@@ -625,6 +1025,10 @@ func (mp *TxPool) signalsReplacement(tx *btcutil.Tx,
 		cache = make(map[chainhash.Hash]struct{})
 	}
 
+	if isTRUC(tx) {
+		return true
+	}
+
 	for _, txIn := range tx.MsgTx().TxIn {
 		if txIn.Sequence <= MaxRBFSequence {
 			return true
@@ -825,23 +1229,79 @@ func (mp *TxPool) FetchTransaction(txHash *chainhash.Hash) (*btcutil.Tx, error)
 	return nil, fmt.Errorf("transaction is not in the pool")
 }
 
+// FetchTransactionByWitnessHash returns the requested transaction from the
+// transaction pool by its witness hash.  This only fetches from the main
+// transaction pool and does not include orphans.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) FetchTransactionByWitnessHash(wtxid *chainhash.Hash) (*btcutil.Tx, error) {
+	// Protect concurrent access.
+	mp.mtx.RLock()
+	var txDesc *TxDesc
+	txHash, exists := mp.poolByWTxId[*wtxid]
+	if exists {
+		txDesc = mp.pool[txHash]
+	}
+	mp.mtx.RUnlock()
+
+	if exists {
+		return txDesc.Tx, nil
+	}
+
+	return nil, fmt.Errorf("transaction is not in the pool")
+}
+
+// FetchTxDesc returns the descriptor of the requested transaction from the
+// transaction pool.  This only fetches from the main transaction pool and does
+// not include orphans.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) FetchTxDesc(txHash *chainhash.Hash) (*TxDesc, error) {
+	// Protect concurrent access.
+	mp.mtx.RLock()
+	txDesc, exists := mp.pool[*txHash]
+	mp.mtx.RUnlock()
+
+	if exists {
+		return txDesc, nil
+	}
+
+	return nil, fmt.Errorf("transaction is not in the pool")
+}
+
 // validateReplacement determines whether a transaction is deemed as a valid
 // replacement of all of its conflicts according to the RBF policy. If it is
 // valid, no error is returned. Otherwise, an error is returned indicating what
 // went wrong.
 //
+// The passed fee, along with the fees of the conflicting transactions, are
+// expected to be modified fees, that is, they include any fee deltas applied
+// via PrioritiseTransaction.
+//
+// The optional sibling is a TRUC transaction sharing the same parent which the
+// transaction must evict in order to respect the TRUC descendant limit.  It is
+// treated like any other conflict.
+//
 // This function MUST be called with the mempool lock held (for reads).
-func (mp *TxPool) validateReplacement(tx *btcutil.Tx,
-	txFee int64) (map[chainhash.Hash]*btcutil.Tx, error) {
+func (mp *TxPool) validateReplacement(tx *btcutil.Tx, txFee int64,
+	sibling *btcutil.Tx) (map[chainhash.Hash]*btcutil.Tx, error) {
 
 	// First, we'll make sure the set of conflicting transactions doesn't
 	// exceed the maximum allowed.
 	conflicts := mp.txConflicts(tx)
+	if sibling != nil {
+		conflicts[*sibling.Hash()] = sibling
+		for hash, descendant := range mp.txDescendants(sibling, nil) {
+			conflicts[hash] = descendant
+		}
+	}
 	if len(conflicts) > MaxReplacementEvictions {
 		str := fmt.Sprintf("replacement transaction %v evicts more "+
 			"transactions than permitted: max is %v, evicts %v",
 			tx.Hash(), MaxReplacementEvictions, len(conflicts))
-		return nil, txRuleError(wire.RejectNonstandard, str)
+		return nil, replacementRuleError(
+			wire.RejectNonstandard, RBFRuleEvictionCount, str,
+		)
 	}
 
 	// The set of conflicts (transactions we'll replace) and ancestors
@@ -871,15 +1331,17 @@ func (mp *TxPool) validateReplacement(tx *btcutil.Tx,
 		conflictsParents = make(map[chainhash.Hash]struct{})
 	)
 	for hash, conflict := range conflicts {
-		if txFeeRate <= mp.pool[hash].FeePerKB {
+		conflictFeeRate := mp.pool[hash].ModifiedFeePerKB()
+		if txFeeRate <= conflictFeeRate {
 			str := fmt.Sprintf("replacement transaction %v has an "+
 				"insufficient fee rate: needs more than %v, "+
-				"has %v", tx.Hash(), mp.pool[hash].FeePerKB,
-				txFeeRate)
-			return nil, txRuleError(wire.RejectInsufficientFee, str)
+				"has %v", tx.Hash(), conflictFeeRate, txFeeRate)
+			return nil, replacementRuleError(
+				wire.RejectInsufficientFee, RBFRuleFeeRate, str,
+			)
 		}
 
-		conflictsFee += mp.pool[hash].Fee
+		conflictsFee += mp.pool[hash].ModifiedFee()
 
 		// We'll track each conflict's parents to ensure the replacement
 		// isn't spending any new unconfirmed inputs.
@@ -889,14 +1351,27 @@ func (mp *TxPool) validateReplacement(tx *btcutil.Tx,
 	}
 
 	// It should also have an absolute fee greater than all of the
-	// transactions it intends to replace and pay for its own bandwidth,
-	// which is determined by our minimum relay fee.
+	// transactions it intends to replace.
+	if txFee <= conflictsFee {
+		str := fmt.Sprintf("replacement transaction %v has an "+
+			"insufficient absolute fee: needs more than %v, has %v",
+			tx.Hash(), conflictsFee, txFee)
+		return nil, replacementRuleError(
+			wire.RejectInsufficientFee, RBFRuleAbsoluteFee, str,
+		)
+	}
+
+	// The additional fee it pays over the transactions it replaces must
+	// also pay for its own bandwidth, which is determined by our minimum
+	// relay fee.
 	minFee := calcMinRequiredTxRelayFee(txSize, mp.cfg.Policy.MinRelayTxFee)
 	if txFee < conflictsFee+minFee {
 		str := fmt.Sprintf("replacement transaction %v has an "+
-			"insufficient absolute fee: needs %v, has %v",
+			"insufficient incremental fee: needs %v, has %v",
 			tx.Hash(), conflictsFee+minFee, txFee)
-		return nil, txRuleError(wire.RejectInsufficientFee, str)
+		return nil, replacementRuleError(
+			wire.RejectInsufficientFee, RBFRuleIncrementalFee, str,
+		)
 	}
 
 	// Finally, it should not spend any new unconfirmed outputs, other than
@@ -913,7 +1388,9 @@ func (mp *TxPool) validateReplacement(tx *btcutil.Tx,
 		str := fmt.Sprintf("replacement transaction spends new "+
 			"unconfirmed input %v not found in conflicting "+
 			"transactions", txIn.PreviousOutPoint)
-		return nil, txRuleError(wire.RejectInvalid, str)
+		return nil, replacementRuleError(
+			wire.RejectInvalid, RBFRuleNewUnconfirmedInput, str,
+		)
 	}
 
 	return conflicts, nil
@@ -923,8 +1400,11 @@ func (mp *TxPool) validateReplacement(tx *btcutil.Tx,
 // MaybeAcceptTransaction.  See the comment for MaybeAcceptTransaction for
 // more details.
 //
+// When a sponsor is passed, the fee related policy checks are performed
+// against the combined fee rate of the transaction and the sponsoring child.
+//
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejectDupOrphans bool) ([]*chainhash.Hash, *TxDesc, error) {
+func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejectDupOrphans bool, sponsor *packageSponsor) ([]*chainhash.Hash, *TxDesc, error) {
 	txHash := tx.Hash()
 
 	// If a transaction has witness data, and segwit isn't active yet, If
@@ -1134,6 +1614,24 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 		return nil, nil, txRuleError(wire.RejectNonstandard, str)
 	}
 
+	// Any fee delta applied to the transaction via PrioritiseTransaction is
+	// taken into account for all of the fee related policy checks below.
+	modifiedFee := txFee + mp.feeDeltas[*txHash]
+
+	// Don't allow transactions to create ephemeral dust unless they pay no
+	// fee, nor allow them to leave the ephemeral dust of their parents
+	// unspent.
+	if !mp.cfg.Policy.AcceptNonStd {
+		err := checkEphemeralDust(tx, txFee, modifiedFee,
+			mp.cfg.Policy.MinRelayTxFee)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := mp.checkEphemeralSpends(tx); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	// Don't allow transactions with fees too low to get into a mined block.
 	//
 	// Most miners allow a free transaction area in blocks they mine to go
@@ -1145,12 +1643,22 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 	// which is more desirable.  Therefore, as long as the size of the
 	// transaction does not exceed 1000 less than the reserved space for
 	// high-priority transactions, don't require a fee for it.
+	//
+	// A transaction accepted as the parent of a 1p1c package is judged by
+	// the fees and size of the whole package instead.
 	serializedSize := GetTxVirtualSize(tx)
-	minFee := calcMinRequiredTxRelayFee(serializedSize,
+	relayFee, relaySize := modifiedFee, serializedSize
+	if sponsor != nil {
+		relayFee += sponsor.fee
+		relaySize += sponsor.size
+	}
+	minFee := calcMinRequiredTxRelayFee(relaySize,
 		mp.cfg.Policy.MinRelayTxFee)
-	if serializedSize >= (DefaultBlockPrioritySize-1000) && txFee < minFee {
+	if serializedSize >= (DefaultBlockPrioritySize-1000) &&
+		relayFee < minFee {
+
 		str := fmt.Sprintf("transaction %v has %d fees which is under "+
-			"the required amount of %d", txHash, txFee,
+			"the required amount of %d", txHash, relayFee,
 			minFee)
 		return nil, nil, txRuleError(wire.RejectInsufficientFee, str)
 	}
@@ -1159,7 +1667,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 	// in the next block.  Transactions which are being added back to the
 	// memory pool from blocks that have been disconnected during a reorg
 	// are exempted.
-	if isNew && !mp.cfg.Policy.DisableRelayPriority && txFee < minFee {
+	if isNew && !mp.cfg.Policy.DisableRelayPriority && relayFee < minFee {
 		currentPriority := mining.CalcPriority(tx.MsgTx(), utxoView,
 			nextBlockHeight)
 		if currentPriority <= mining.MinHighPriority {
@@ -1172,7 +1680,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 
 	// Free-to-relay transactions are rate limited here to prevent
 	// penny-flooding with tiny transactions as a form of attack.
-	if rateLimit && txFee < minFee {
+	if rateLimit && relayFee < minFee {
 		nowUnix := time.Now().Unix()
 		// Decay passed data with an exponentially decaying ~10 minute
 		// window - matches bitcoind handling.
@@ -1194,11 +1702,24 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 			mp.cfg.Policy.FreeTxRelayLimit*10*1000)
 	}
 
+	// Enforce the topology restrictions of TRUC transactions.  A TRUC
+	// child may evict its sibling, in which case the transaction is
+	// treated as a replacement of it.
+	sibling, err := mp.checkTRUCPolicy(tx, serializedSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sibling != nil {
+		isReplacement = true
+	}
+
 	// If the transaction has any conflicts, and we've made it this far, then
 	// we're processing a potential replacement.
 	var conflicts map[chainhash.Hash]*btcutil.Tx
 	if isReplacement {
-		conflicts, err = mp.validateReplacement(tx, txFee)
+		conflicts, err = mp.validateReplacement(
+			tx, modifiedFee, sibling,
+		)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -1222,13 +1743,14 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 	for _, conflict := range conflicts {
 		log.Debugf("Replacing transaction %v (fee_rate=%v sat/kb) "+
 			"with %v (fee_rate=%v sat/kb)\n", conflict.Hash(),
-			mp.pool[*conflict.Hash()].FeePerKB, tx.Hash(),
-			txFee*1000/serializedSize)
+			mp.pool[*conflict.Hash()].ModifiedFeePerKB(), tx.Hash(),
+			modifiedFee*1000/serializedSize)
 
 		// The conflict set should already include the descendants for
 		// each one, so we don't need to remove the redeemers within
 		// this call as they'll be removed eventually.
-		mp.removeTransaction(conflict, false)
+		mp.removeTransaction(conflict, false, RemovalReasonReplaced,
+			txHash)
 	}
 	txD := mp.addTransaction(utxoView, tx, bestHeight, txFee)
 
@@ -1252,8 +1774,9 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 func (mp *TxPool) MaybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit bool) ([]*chainhash.Hash, *TxDesc, error) {
 	// Protect concurrent access.
 	mp.mtx.Lock()
-	hashes, txD, err := mp.maybeAcceptTransaction(tx, isNew, rateLimit, true)
-	mp.mtx.Unlock()
+	hashes, txD, err := mp.maybeAcceptTransaction(tx, isNew, rateLimit, true,
+		nil)
+	mp.unlockAndNotify()
 
 	return hashes, txD, err
 }
@@ -1295,7 +1818,7 @@ func (mp *TxPool) processOrphans(acceptedTx *btcutil.Tx) []*TxDesc {
 			// Potentially accept an orphan into the tx pool.
 			for _, tx := range orphans {
 				missing, txD, err := mp.maybeAcceptTransaction(
-					tx, true, true, false)
+					tx, true, true, false, nil)
 				if err != nil {
 					// The orphan is now invalid, so there
 					// is no way any other orphans which
@@ -1354,11 +1877,51 @@ func (mp *TxPool) processOrphans(acceptedTx *btcutil.Tx) []*TxDesc {
 func (mp *TxPool) ProcessOrphans(acceptedTx *btcutil.Tx) []*TxDesc {
 	mp.mtx.Lock()
 	acceptedTxns := mp.processOrphans(acceptedTx)
-	mp.mtx.Unlock()
+	mp.unlockAndNotify()
 
 	return acceptedTxns
 }
 
+// processPackage accepts the passed transaction as the parent of a 1p1c
+// package along with the orphan child which pays for it.  The parent is only
+// kept in the pool when the child is accepted as well, so a transaction which
+// doesn't pay for itself is never left behind.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) processPackage(parent *btcutil.Tx, sponsor *packageSponsor,
+	rateLimit bool) ([]*TxDesc, error) {
+
+	numNtfns, sequence := len(mp.pendingNtfns), mp.sequence
+	_, txD, err := mp.maybeAcceptTransaction(parent, true, rateLimit, true,
+		sponsor)
+	if err != nil {
+		return nil, err
+	}
+
+	newTxs := mp.processOrphans(parent)
+	if _, ok := mp.pool[*sponsor.tx.Hash()]; !ok {
+		// Undo the acceptance of the parent, along with anything
+		// accepted as a result of it, without notifying about either.
+		mp.removeTransaction(parent, true, RemovalReasonUnknown, nil)
+		mp.pendingNtfns = mp.pendingNtfns[:numNtfns]
+		mp.sequence = sequence
+
+		str := fmt.Sprintf("transaction %v has insufficient fees and "+
+			"its child %v which pays for it was not accepted",
+			parent.Hash(), sponsor.tx.Hash())
+		return nil, txRuleError(wire.RejectInsufficientFee, str)
+	}
+
+	acceptedTxs := make([]*TxDesc, len(newTxs)+1)
+	acceptedTxs[0] = txD
+	copy(acceptedTxs[1:], newTxs)
+
+	log.Debugf("Accepted transaction %v along with child %v as a package",
+		parent.Hash(), sponsor.tx.Hash())
+
+	return acceptedTxs, nil
+}
+
 // ProcessTransaction is the main workhorse for handling insertion of new
 // free-standing transactions into the memory pool.  It includes functionality
 // such as rejecting duplicate transactions, ensuring transactions follow all
@@ -1375,13 +1938,24 @@ func (mp *TxPool) ProcessTransaction(tx *btcutil.Tx, allowOrphan, rateLimit bool
 
 	// Protect concurrent access.
 	mp.mtx.Lock()
-	defer mp.mtx.Unlock()
+	defer mp.unlockAndNotify()
 
 	// Potentially accept the transaction to the memory pool.
 	missingParents, txD, err := mp.maybeAcceptTransaction(tx, true, rateLimit,
-		true)
+		true, nil)
 	if err != nil {
-		return nil, err
+		// A TRUC transaction which doesn't pay enough fees on its own
+		// may still be accepted along with an orphan child which pays
+		// for it.
+		code, _ := extractRejectCode(err)
+		if code != wire.RejectInsufficientFee {
+			return nil, err
+		}
+		sponsor := mp.findPackageSponsor(tx)
+		if sponsor == nil {
+			return nil, err
+		}
+		return mp.processPackage(tx, sponsor, rateLimit)
 	}
 
 	if len(missingParents) == 0 {
@@ -1419,7 +1993,7 @@ func (mp *TxPool) ProcessTransaction(tx *btcutil.Tx, allowOrphan, rateLimit bool
 	}
 
 	// Potentially add the orphan transaction to the orphan pool.
-	err = mp.maybeAddOrphan(tx, tag)
+	err = mp.maybeAddOrphan(tx, tag, missingParents)
 	return nil, err
 }
 
@@ -1537,6 +2111,137 @@ func (mp *TxPool) RawMempoolVerbose() map[string]*btcjson.GetRawMempoolVerboseRe
 	return result
 }
 
+// PrioritiseTransaction adds the passed fee delta, in satoshi, to the fee delta
+// of the transaction with the given hash.  Deltas accumulate over multiple
+// calls and are tracked regardless of whether or not the transaction is
+// currently in the pool so that transactions which arrive later are also
+// affected.  The modified fee is used in place of the actual fee for all fee
+// related policy decisions as well as for transaction selection when
+// generating block templates.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) PrioritiseTransaction(hash *chainhash.Hash, feeDelta int64) {
+	mp.mtx.Lock()
+	defer mp.unlockAndNotify()
+
+	delta := mp.feeDeltas[*hash] + feeDelta
+	if delta == 0 {
+		delete(mp.feeDeltas, *hash)
+	} else {
+		mp.feeDeltas[*hash] = delta
+	}
+
+	// Update the descriptor of the transaction if it is already in the
+	// pool.  A copy is stored rather than modifying the existing descriptor
+	// in place since descriptors handed out by the pool are treated as
+	// read only by callers.
+	txD, exists := mp.pool[*hash]
+	if !exists {
+		return
+	}
+	newTxD := *txD
+	newTxD.FeeDelta = delta
+	mp.pool[*hash] = &newTxD
+	atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+	if mp.cfg.TxAccepted != nil {
+		mp.pendingNtfns = append(mp.pendingNtfns,
+			poolNtfn{accepted: &newTxD})
+	}
+
+	log.Debugf("Prioritised transaction %v (fee delta: %d, modified "+
+		"fee: %d)", hash, delta, newTxD.ModifiedFee())
+}
+
+// FeeDelta returns the fee delta, in satoshi, that has been applied to the
+// transaction with the given hash via PrioritiseTransaction.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) FeeDelta(hash *chainhash.Hash) int64 {
+	mp.mtx.RLock()
+	delta := mp.feeDeltas[*hash]
+	mp.mtx.RUnlock()
+
+	return delta
+}
+
+// ClearFeeDelta removes any fee delta that has been applied to the transaction
+// with the given hash.  It is intended to be called once a transaction has been
+// mined since the delta is no longer needed at that point.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) ClearFeeDelta(hash *chainhash.Hash) {
+	mp.mtx.Lock()
+	delete(mp.feeDeltas, *hash)
+	mp.mtx.Unlock()
+}
+
+// MempoolEntry returns a fully populated btcjson result for the transaction
+// with the given hash.  An error is returned if the transaction is not in the
+// main pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MempoolEntry(hash *chainhash.Hash) (*btcjson.GetMempoolEntryResult, error) {
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	desc, exists := mp.pool[*hash]
+	if !exists {
+		return nil, fmt.Errorf("transaction is not in the pool")
+	}
+
+	tx := desc.Tx
+	vsize := GetTxVirtualSize(tx)
+
+	// The ancestor and descendant statistics include the transaction
+	// itself as is the case with the reference implementation.
+	ancestorCount, ancestorSize := int64(1), vsize
+	ancestorFees := desc.ModifiedFee()
+	for ancestorHash, ancestor := range mp.txAncestors(tx, nil) {
+		ancestorCount++
+		ancestorSize += GetTxVirtualSize(ancestor)
+		ancestorFees += mp.pool[ancestorHash].ModifiedFee()
+	}
+	descendantCount, descendantSize := int64(1), vsize
+	descendantFees := desc.ModifiedFee()
+	for descendantHash, descendant := range mp.txDescendants(tx, nil) {
+		descendantCount++
+		descendantSize += GetTxVirtualSize(descendant)
+		descendantFees += mp.pool[descendantHash].ModifiedFee()
+	}
+
+	result := &btcjson.GetMempoolEntryResult{
+		VSize:           int32(vsize),
+		Size:            int32(tx.MsgTx().SerializeSize()),
+		Weight:          blockchain.GetTransactionWeight(tx),
+		Fee:             btcutil.Amount(desc.Fee).ToBTC(),
+		ModifiedFee:     btcutil.Amount(desc.ModifiedFee()).ToBTC(),
+		Time:            desc.Added.Unix(),
+		Height:          int64(desc.Height),
+		DescendantCount: descendantCount,
+		DescendantSize:  descendantSize,
+		DescendantFees:  btcutil.Amount(descendantFees).ToBTC(),
+		AncestorCount:   ancestorCount,
+		AncestorSize:    ancestorSize,
+		AncestorFees:    btcutil.Amount(ancestorFees).ToBTC(),
+		WTxId:           tx.WitnessHash().String(),
+		Fees: btcjson.MempoolFees{
+			Base:       btcutil.Amount(desc.Fee).ToBTC(),
+			Modified:   btcutil.Amount(desc.ModifiedFee()).ToBTC(),
+			Ancestor:   btcutil.Amount(ancestorFees).ToBTC(),
+			Descendant: btcutil.Amount(descendantFees).ToBTC(),
+		},
+		Depends: make([]string, 0),
+	}
+	for _, txIn := range tx.MsgTx().TxIn {
+		prevHash := &txIn.PreviousOutPoint.Hash
+		if mp.isTransactionInPool(prevHash) {
+			result.Depends = append(result.Depends, prevHash.String())
+		}
+	}
+
+	return result, nil
+}
+
 // LastUpdated returns the last time a transaction was added to or removed from
 // the main pool.  It does not include the orphan pool.
 //
@@ -1553,7 +2258,11 @@ func New(cfg *Config) *TxPool {
 		pool:           make(map[chainhash.Hash]*TxDesc),
 		orphans:        make(map[chainhash.Hash]*orphanTx),
 		orphansByPrev:  make(map[wire.OutPoint]map[chainhash.Hash]*btcutil.Tx),
+		orphansByTag:   make(map[Tag]int),
 		nextExpireScan: time.Now().Add(orphanExpireScanInterval),
 		outpoints:      make(map[wire.OutPoint]*btcutil.Tx),
+		poolByWTxId:    make(map[chainhash.Hash]chainhash.Hash),
+		orphansByWTxId: make(map[chainhash.Hash]chainhash.Hash),
+		feeDeltas:      make(map[chainhash.Hash]int64),
 	}
 }
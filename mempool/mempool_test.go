@@ -448,6 +448,24 @@ func testPoolMembership(tc *testContext, tx *btcutil.Tx, inOrphanPool, inTxPool
 		tc.t.Fatalf("HaveTransaction: want %v, got %v", wantHaveTx,
 			gotHaveTx)
 	}
+
+	// The transaction must also be known by its witness hash.
+	wtxid := tx.WitnessHash()
+	gotHaveTx = tc.harness.txPool.HaveTransactionByWitnessHash(wtxid)
+	if wantHaveTx != gotHaveTx {
+		tc.t.Fatalf("HaveTransactionByWitnessHash: want %v, got %v",
+			wantHaveTx, gotHaveTx)
+	}
+
+	fetchedTx, err := tc.harness.txPool.FetchTransactionByWitnessHash(wtxid)
+	if inTxPool != (err == nil) {
+		tc.t.Fatalf("FetchTransactionByWitnessHash: want %v, got %v",
+			inTxPool, err == nil)
+	}
+	if err == nil && *fetchedTx.Hash() != *txHash {
+		tc.t.Fatalf("FetchTransactionByWitnessHash: want %v, got %v",
+			txHash, fetchedTx.Hash())
+	}
 }
 
 // TestSimpleOrphanChain ensures that a simple chain of orphans is handled
@@ -634,6 +652,75 @@ func TestOrphanEviction(t *testing.T) {
 	}
 }
 
+// TestOrphanEvictionByTag ensures that exceeding the maximum number of orphans
+// with the same tag evicts the oldest orphan with that tag while orphans with
+// other tags are unaffected, and that the missing parents of orphans are
+// tracked.
+func TestOrphanEvictionByTag(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	harness.txPool.cfg.Policy.MaxOrphanTxsPerTag = 3
+	tc := &testContext{t, harness}
+
+	chainedTxns, err := harness.CreateTxChain(outputs[0], 6)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+
+	// Add one more orphan with the same tag than allowed.  The first one
+	// should be evicted.
+	for _, tx := range chainedTxns[1:5] {
+		_, err := harness.txPool.ProcessTransaction(tx, true, false, 1)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: failed to accept valid "+
+				"orphan %v", err)
+		}
+	}
+	testPoolMembership(tc, chainedTxns[1], false, false)
+	for _, tx := range chainedTxns[2:5] {
+		testPoolMembership(tc, tx, true, false)
+	}
+
+	// An orphan with another tag does not evict any orphans.
+	_, err = harness.txPool.ProcessTransaction(chainedTxns[5], true, false, 2)
+	if err != nil {
+		t.Fatalf("ProcessTransaction: failed to accept valid orphan %v",
+			err)
+	}
+	for _, tx := range chainedTxns[2:] {
+		testPoolMembership(tc, tx, true, false)
+	}
+
+	// The missing parent of every orphan is its predecessor in the chain
+	// while transactions which aren't orphans have no missing parents.
+	for i, tx := range chainedTxns[2:] {
+		parents := harness.txPool.OrphanMissingParents(tx.Hash())
+		if len(parents) != 1 || *parents[0] != *chainedTxns[i+1].Hash() {
+			t.Fatalf("unexpected missing parents of orphan %d: %v",
+				i+2, parents)
+		}
+	}
+	if parents := harness.txPool.OrphanMissingParents(chainedTxns[1].Hash()); parents != nil {
+		t.Fatalf("unexpected missing parents of evicted orphan: %v",
+			parents)
+	}
+
+	// Removing the orphans by tag also removes the orphan with the other
+	// tag since it spends an output of one of them, which leaves no tags
+	// with orphans behind.
+	harness.txPool.RemoveOrphansByTag(1)
+	for _, tx := range chainedTxns[2:] {
+		testPoolMembership(tc, tx, false, false)
+	}
+	if n := len(harness.txPool.orphansByTag); n != 0 {
+		t.Fatalf("unexpected number of tags with orphans: %d", n)
+	}
+}
+
 // TestBasicOrphanRemoval ensure that orphan removal works as expected when an
 // orphan that doesn't exist is removed  both when there is another orphan that
 // redeems it and when there is not.
@@ -1749,6 +1836,111 @@ func TestRBF(t *testing.T) {
 			},
 			err: "",
 		},
+		{
+			// A transaction that doesn't signal replacement is
+			// rejected with the signaling rule as the reason.
+			name: "non-replaceable parent rule",
+			setup: func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx) {
+				coinbase := ctx.addCoinbaseTx(1)
+
+				coinbaseOut := txOutToSpendableOut(coinbase, 0)
+				outs := []spendableOutput{coinbaseOut}
+				ctx.addSignedTx(outs, 1, defaultFee, false, false)
+
+				tx, err := ctx.harness.CreateSignedTx(
+					outs, 1, defaultFee*3, false,
+				)
+				if err != nil {
+					ctx.t.Fatalf("unable to create "+
+						"transaction: %v", err)
+				}
+
+				return tx, nil
+			},
+			err: "BIP125 rule #1: signaling",
+		},
+		{
+			// A transaction that doesn't signal replacement can be
+			// replaced when full RBF is enabled.
+			name: "full rbf non-replaceable parent",
+			setup: func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx) {
+				ctx.harness.txPool.cfg.Policy.FullRBF = true
+
+				coinbase := ctx.addCoinbaseTx(1)
+
+				// Create a transaction that spends the coinbase
+				// output and doesn't signal for replacement.
+				coinbaseOut := txOutToSpendableOut(coinbase, 0)
+				outs := []spendableOutput{coinbaseOut}
+				parent := ctx.addSignedTx(
+					outs, 1, defaultFee, false, false,
+				)
+
+				// The replacement should be accepted despite
+				// the lack of signaling since it pays a higher
+				// fee.
+				tx, err := ctx.harness.CreateSignedTx(
+					outs, 1, defaultFee*3, false,
+				)
+				if err != nil {
+					ctx.t.Fatalf("unable to create "+
+						"transaction: %v", err)
+				}
+
+				return tx, []*btcutil.Tx{parent}
+			},
+			err: "",
+		},
+		{
+			// Full RBF does not lift the other replacement rules.
+			name: "full rbf insufficient absolute fee",
+			setup: func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx) {
+				ctx.harness.txPool.cfg.Policy.FullRBF = true
+
+				coinbase := ctx.addCoinbaseTx(1)
+
+				coinbaseOut := txOutToSpendableOut(coinbase, 0)
+				outs := []spendableOutput{coinbaseOut}
+				ctx.addSignedTx(outs, 2, defaultFee, false, false)
+
+				tx, err := ctx.harness.CreateSignedTx(
+					outs, 1, defaultFee, false,
+				)
+				if err != nil {
+					ctx.t.Fatalf("unable to create "+
+						"transaction: %v", err)
+				}
+
+				return tx, nil
+			},
+			err: "BIP125 rule #3: absolute fee",
+		},
+		{
+			// A replacement must pay for its own bandwidth on top
+			// of the fees of the transactions it replaces.
+			name: "insufficient incremental fee",
+			setup: func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx) {
+				coinbase := ctx.addCoinbaseTx(1)
+
+				coinbaseOut := txOutToSpendableOut(coinbase, 0)
+				outs := []spendableOutput{coinbaseOut}
+				ctx.addSignedTx(outs, 2, defaultFee, true, false)
+
+				// Paying a single satoshi more than the
+				// original is not enough to cover the
+				// replacement's relay fee.
+				tx, err := ctx.harness.CreateSignedTx(
+					outs, 1, defaultFee+1, false,
+				)
+				if err != nil {
+					ctx.t.Fatalf("unable to create "+
+						"transaction: %v", err)
+				}
+
+				return tx, nil
+			},
+			err: "BIP125 rule #4: incremental feerate",
+		},
 		{
 			// A transaction that doesn't signal replacement, can
 			// be replaced if the parent signals replacement.
@@ -1851,3 +2043,969 @@ func TestRBF(t *testing.T) {
 		}
 	}
 }
+
+// TestPrioritiseTransaction ensures that fee deltas applied via
+// PrioritiseTransaction are honored by the mempool's fee related policy checks,
+// including those for transactions that were prioritised before being seen.
+func TestPrioritiseTransaction(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	harness.txPool.cfg.Policy.FreeTxRelayLimit = 0
+	ctx := &testContext{t, harness}
+
+	// Create a transaction that pays no fee.  It should be rejected by the
+	// rate limiter since free transactions aren't allowed.
+	coinbase := ctx.addCoinbaseTx(1)
+	coinbaseOut := txOutToSpendableOut(coinbase, 0)
+	tx, err := harness.CreateSignedTx(
+		[]spendableOutput{coinbaseOut}, 1, 0, true,
+	)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(tx, false, true, 0)
+	if err == nil || !strings.Contains(err.Error(), "rate limiter") {
+		t.Fatalf("expected rate limiter error, got: %v", err)
+	}
+
+	// Prioritise the transaction before it's in the pool.  It should now be
+	// accepted.
+	const feeDelta = 100000
+	harness.txPool.PrioritiseTransaction(tx.Hash(), feeDelta)
+	_, err = harness.txPool.ProcessTransaction(tx, false, true, 0)
+	if err != nil {
+		t.Fatalf("unable to process prioritised transaction: %v", err)
+	}
+	testPoolMembership(ctx, tx, false, true)
+
+	// The mempool entry should report both the base and modified fee.
+	entry, err := harness.txPool.MempoolEntry(tx.Hash())
+	if err != nil {
+		t.Fatalf("unable to fetch mempool entry: %v", err)
+	}
+	if entry.Fees.Base != 0 {
+		t.Fatalf("expected base fee of 0, got %v", entry.Fees.Base)
+	}
+	wantModified := btcutil.Amount(feeDelta).ToBTC()
+	if entry.ModifiedFee != wantModified ||
+		entry.Fees.Modified != wantModified {
+
+		t.Fatalf("expected modified fee of %v, got %v and %v",
+			wantModified, entry.ModifiedFee, entry.Fees.Modified)
+	}
+
+	// A replacement paying a higher actual fee, but less than the modified
+	// fee of the original, should be rejected.
+	replacement, err := harness.CreateSignedTx(
+		[]spendableOutput{coinbaseOut}, 1, feeDelta/2, false,
+	)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(replacement, false, false, 0)
+	if err == nil || !strings.Contains(err.Error(), "insufficient fee rate") {
+		t.Fatalf("expected insufficient fee rate error, got: %v", err)
+	}
+	testPoolMembership(ctx, tx, false, true)
+
+	// Removing the delta again should allow the replacement.
+	harness.txPool.PrioritiseTransaction(tx.Hash(), -feeDelta)
+	if delta := harness.txPool.FeeDelta(tx.Hash()); delta != 0 {
+		t.Fatalf("expected fee delta of 0, got %d", delta)
+	}
+	_, err = harness.txPool.ProcessTransaction(replacement, false, false, 0)
+	if err != nil {
+		t.Fatalf("unable to process replacement: %v", err)
+	}
+	testPoolMembership(ctx, tx, false, false)
+	testPoolMembership(ctx, replacement, false, true)
+}
+
+// createTRUCTx creates a signed TRUC transaction that spends the provided
+// inputs, all paying to the harness' payment script, along with the provided
+// anchor outputs.  The total input amount minus the fee and the value of any
+// extra outputs is split evenly amongst the requested number of outputs to the
+// harness' payment script, followed by the extra outputs.
+func (ctx *testContext) createTRUCTx(inputs, anchors []spendableOutput,
+	numOutputs uint32, fee btcutil.Amount,
+	extraOutputs ...*wire.TxOut) *btcutil.Tx {
+
+	ctx.t.Helper()
+
+	tx := wire.NewMsgTx(TRUCVersion)
+	totalInput := -fee
+	for _, input := range append(inputs, anchors...) {
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: input.outPoint,
+			Sequence:         wire.MaxTxInSequenceNum,
+		})
+		totalInput += input.amount
+	}
+	for _, txOut := range extraOutputs {
+		totalInput -= btcutil.Amount(txOut.Value)
+	}
+
+	amountPerOutput := int64(totalInput) / int64(numOutputs)
+	remainder := int64(totalInput) - amountPerOutput*int64(numOutputs)
+	for i := uint32(0); i < numOutputs; i++ {
+		amount := amountPerOutput
+		if i == numOutputs-1 {
+			amount = amountPerOutput + remainder
+		}
+		tx.AddTxOut(&wire.TxOut{
+			PkScript: ctx.harness.payScript,
+			Value:    amount,
+		})
+	}
+	for _, txOut := range extraOutputs {
+		tx.AddTxOut(txOut)
+	}
+
+	// Only the inputs paying to the harness' payment script need to be
+	// signed since anchors are keyless.
+	for i := range inputs {
+		sigScript, err := txscript.SignatureScript(
+			tx, i, ctx.harness.payScript, txscript.SigHashAll,
+			ctx.harness.signKey, true,
+		)
+		if err != nil {
+			ctx.t.Fatalf("unable to sign transaction: %v", err)
+		}
+		tx.TxIn[i].SignatureScript = sigScript
+	}
+
+	return btcutil.NewTx(tx)
+}
+
+// acceptTx processes the transaction and ensures it is accepted into the
+// mempool.
+func (ctx *testContext) acceptTx(tx *btcutil.Tx) {
+	ctx.t.Helper()
+
+	_, err := ctx.harness.txPool.ProcessTransaction(tx, false, false, 0)
+	if err != nil {
+		ctx.t.Fatalf("unable to process transaction: %v", err)
+	}
+	testPoolMembership(ctx, tx, false, true)
+}
+
+// TestTRUC ensures that the mempool enforces the topology restrictions of TRUC
+// transactions, including sibling eviction, along with the ephemeral dust
+// rules.
+func TestTRUC(t *testing.T) {
+	t.Parallel()
+
+	const defaultFee = 10000
+
+	anchor := func(value int64) *wire.TxOut {
+		return wire.NewTxOut(value, txscript.PayToAnchorScript())
+	}
+
+	testCases := []struct {
+		name  string
+		setup func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx)
+		err   string
+	}{
+		{
+			name: "truc parent and child",
+			setup: func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx) {
+				coinbase := ctx.addCoinbaseTx(1)
+				outs := []spendableOutput{
+					txOutToSpendableOut(coinbase, 0),
+				}
+				parent := ctx.createTRUCTx(outs, nil, 1, defaultFee)
+				ctx.acceptTx(parent)
+
+				outs = []spendableOutput{
+					txOutToSpendableOut(parent, 0),
+				}
+				child := ctx.createTRUCTx(outs, nil, 1, defaultFee)
+
+				return child, nil
+			},
+			err: "",
+		},
+		{
+			name: "non-truc child of truc parent",
+			setup: func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx) {
+				coinbase := ctx.addCoinbaseTx(1)
+				outs := []spendableOutput{
+					txOutToSpendableOut(coinbase, 0),
+				}
+				parent := ctx.createTRUCTx(outs, nil, 1, defaultFee)
+				ctx.acceptTx(parent)
+
+				outs = []spendableOutput{
+					txOutToSpendableOut(parent, 0),
+				}
+				child, err := ctx.harness.CreateSignedTx(
+					outs, 1, defaultFee, false,
+				)
+				if err != nil {
+					ctx.t.Fatalf("unable to create "+
+						"transaction: %v", err)
+				}
+
+				return child, nil
+			},
+			err: "non-TRUC transaction",
+		},
+		{
+			name: "truc child of non-truc parent",
+			setup: func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx) {
+				coinbase := ctx.addCoinbaseTx(1)
+				outs := []spendableOutput{
+					txOutToSpendableOut(coinbase, 0),
+				}
+				parent := ctx.addSignedTx(
+					outs, 1, defaultFee, false, false,
+				)
+
+				outs = []spendableOutput{
+					txOutToSpendableOut(parent, 0),
+				}
+				child := ctx.createTRUCTx(outs, nil, 1, defaultFee)
+
+				return child, nil
+			},
+			err: "spends unconfirmed non-TRUC transaction",
+		},
+		{
+			name: "truc grandchild",
+			setup: func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx) {
+				coinbase := ctx.addCoinbaseTx(1)
+				outs := []spendableOutput{
+					txOutToSpendableOut(coinbase, 0),
+				}
+				parent := ctx.createTRUCTx(outs, nil, 1, defaultFee)
+				ctx.acceptTx(parent)
+
+				outs = []spendableOutput{
+					txOutToSpendableOut(parent, 0),
+				}
+				child := ctx.createTRUCTx(outs, nil, 1, defaultFee)
+				ctx.acceptTx(child)
+
+				outs = []spendableOutput{
+					txOutToSpendableOut(child, 0),
+				}
+				grandchild := ctx.createTRUCTx(
+					outs, nil, 1, defaultFee,
+				)
+
+				return grandchild, nil
+			},
+			err: "too many unconfirmed ancestors",
+		},
+		{
+			name: "truc child with two unconfirmed parents",
+			setup: func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx) {
+				coinbase := ctx.addCoinbaseTx(2)
+				var outs []spendableOutput
+				for i := uint32(0); i < 2; i++ {
+					parent := ctx.createTRUCTx(
+						[]spendableOutput{
+							txOutToSpendableOut(
+								coinbase, i,
+							),
+						}, nil, 1, defaultFee,
+					)
+					ctx.acceptTx(parent)
+					outs = append(
+						outs, txOutToSpendableOut(parent, 0),
+					)
+				}
+				child := ctx.createTRUCTx(outs, nil, 1, defaultFee)
+
+				return child, nil
+			},
+			err: "too many unconfirmed ancestors",
+		},
+		{
+			name: "truc child too large",
+			setup: func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx) {
+				coinbase := ctx.addCoinbaseTx(1)
+				outs := []spendableOutput{
+					txOutToSpendableOut(coinbase, 0),
+				}
+				parent := ctx.createTRUCTx(outs, nil, 1, defaultFee)
+				ctx.acceptTx(parent)
+
+				// Each pay-to-pubkey-hash output is 34 bytes,
+				// so 40 of them exceed the child size limit.
+				outs = []spendableOutput{
+					txOutToSpendableOut(parent, 0),
+				}
+				child := ctx.createTRUCTx(outs, nil, 40, defaultFee)
+
+				return child, nil
+			},
+			err: "TRUC child transaction",
+		},
+		{
+			name: "truc sibling eviction",
+			setup: func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx) {
+				coinbase := ctx.addCoinbaseTx(1)
+				outs := []spendableOutput{
+					txOutToSpendableOut(coinbase, 0),
+				}
+				parent := ctx.createTRUCTx(outs, nil, 2, defaultFee)
+				ctx.acceptTx(parent)
+
+				outs = []spendableOutput{
+					txOutToSpendableOut(parent, 0),
+				}
+				child := ctx.createTRUCTx(outs, nil, 1, defaultFee)
+				ctx.acceptTx(child)
+
+				// A child spending the other output of the
+				// parent should evict its sibling as long as it
+				// pays enough fees to replace it.
+				outs = []spendableOutput{
+					txOutToSpendableOut(parent, 1),
+				}
+				sibling := ctx.createTRUCTx(
+					outs, nil, 1, defaultFee*2,
+				)
+
+				return sibling, []*btcutil.Tx{child}
+			},
+			err: "",
+		},
+		{
+			name: "truc sibling eviction insufficient fee",
+			setup: func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx) {
+				coinbase := ctx.addCoinbaseTx(1)
+				outs := []spendableOutput{
+					txOutToSpendableOut(coinbase, 0),
+				}
+				parent := ctx.createTRUCTx(outs, nil, 2, defaultFee)
+				ctx.acceptTx(parent)
+
+				outs = []spendableOutput{
+					txOutToSpendableOut(parent, 0),
+				}
+				child := ctx.createTRUCTx(outs, nil, 1, defaultFee)
+				ctx.acceptTx(child)
+
+				outs = []spendableOutput{
+					txOutToSpendableOut(parent, 1),
+				}
+				sibling := ctx.createTRUCTx(outs, nil, 1, defaultFee)
+
+				return sibling, []*btcutil.Tx{child}
+			},
+			err: "insufficient",
+		},
+		{
+			name: "truc replacement without signaling",
+			setup: func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx) {
+				coinbase := ctx.addCoinbaseTx(1)
+				outs := []spendableOutput{
+					txOutToSpendableOut(coinbase, 0),
+				}
+				tx := ctx.createTRUCTx(outs, nil, 1, defaultFee)
+				ctx.acceptTx(tx)
+
+				replacement := ctx.createTRUCTx(
+					outs, nil, 2, defaultFee*2,
+				)
+
+				return replacement, []*btcutil.Tx{tx}
+			},
+			err: "",
+		},
+		{
+			name: "ephemeral dust spent by child",
+			setup: func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx) {
+				coinbase := ctx.addCoinbaseTx(1)
+				outs := []spendableOutput{
+					txOutToSpendableOut(coinbase, 0),
+				}
+				parent := ctx.createTRUCTx(
+					outs, nil, 1, 0, anchor(0),
+				)
+				ctx.acceptTx(parent)
+
+				outs = []spendableOutput{
+					txOutToSpendableOut(parent, 0),
+				}
+				anchors := []spendableOutput{
+					txOutToSpendableOut(parent, 1),
+				}
+				child := ctx.createTRUCTx(
+					outs, anchors, 1, defaultFee,
+				)
+
+				return child, nil
+			},
+			err: "",
+		},
+		{
+			name: "ephemeral dust not spent by child",
+			setup: func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx) {
+				coinbase := ctx.addCoinbaseTx(1)
+				outs := []spendableOutput{
+					txOutToSpendableOut(coinbase, 0),
+				}
+				parent := ctx.createTRUCTx(
+					outs, nil, 1, 0, anchor(0),
+				)
+				ctx.acceptTx(parent)
+
+				outs = []spendableOutput{
+					txOutToSpendableOut(parent, 0),
+				}
+				child := ctx.createTRUCTx(outs, nil, 1, defaultFee)
+
+				return child, nil
+			},
+			err: "does not spend ephemeral dust",
+		},
+		{
+			name: "ephemeral dust with fee",
+			setup: func(ctx *testContext) (*btcutil.Tx, []*btcutil.Tx) {
+				coinbase := ctx.addCoinbaseTx(1)
+				outs := []spendableOutput{
+					txOutToSpendableOut(coinbase, 0),
+				}
+				parent := ctx.createTRUCTx(
+					outs, nil, 1, defaultFee, anchor(0),
+				)
+
+				return parent, nil
+			},
+			err: "non-zero fee",
+		},
+	}
+
+	for _, testCase := range testCases {
+		success := t.Run(testCase.name, func(t *testing.T) {
+			harness, _, err := newPoolHarness(
+				&chaincfg.MainNetParams,
+			)
+			if err != nil {
+				t.Fatalf("unable to create test pool: %v", err)
+			}
+			harness.txPool.cfg.Policy.MaxTxVersion = TRUCVersion
+
+			ctx := &testContext{t, harness}
+			tx, replacedTxs := testCase.setup(ctx)
+
+			_, err = ctx.harness.txPool.ProcessTransaction(
+				tx, false, false, 0,
+			)
+			if testCase.err == "" && err != nil {
+				t.Fatalf("expected no error when processing "+
+					"transaction, got: %v", err)
+			}
+			if testCase.err != "" && err == nil {
+				t.Fatalf("expected error when processing "+
+					"transaction: %v", testCase.err)
+			}
+			if testCase.err != "" &&
+				!strings.Contains(err.Error(), testCase.err) {
+
+				t.Fatalf("expected error: %v\ngot: %v",
+					testCase.err, err)
+			}
+
+			valid := testCase.err == ""
+			for _, replacedTx := range replacedTxs {
+				testPoolMembership(ctx, replacedTx, false, !valid)
+			}
+			testPoolMembership(ctx, tx, false, valid)
+		})
+		if !success {
+			break
+		}
+	}
+}
+
+// TestEphemeralDustPackage ensures that a zero-fee TRUC parent with ephemeral
+// dust, which doesn't pay the minimum relay fee on its own, is accepted along
+// with an orphan child that spends the dust and pays for both of them, and
+// that the parent is left out of the pool when the child doesn't pay for it or
+// is rejected.
+func TestEphemeralDustPackage(t *testing.T) {
+	t.Parallel()
+
+	const defaultFee = 10000
+
+	testCases := []struct {
+		name      string
+		childFee  btcutil.Amount
+		spendDust bool
+		err       string
+	}{
+		{
+			name:      "child pays for parent",
+			childFee:  defaultFee,
+			spendDust: true,
+			err:       "",
+		},
+		{
+			name:      "child pays too little for package",
+			childFee:  1,
+			spendDust: true,
+			err:       "rate limiter",
+		},
+		{
+			name:      "child does not spend ephemeral dust",
+			childFee:  defaultFee,
+			spendDust: false,
+			err:       "child",
+		},
+	}
+
+	for _, testCase := range testCases {
+		success := t.Run(testCase.name, func(t *testing.T) {
+			harness, _, err := newPoolHarness(
+				&chaincfg.MainNetParams,
+			)
+			if err != nil {
+				t.Fatalf("unable to create test pool: %v", err)
+			}
+			harness.txPool.cfg.Policy.MaxTxVersion = TRUCVersion
+
+			// Don't relay any free transactions, so the parent is
+			// only accepted when its child pays for it.
+			harness.txPool.cfg.Policy.FreeTxRelayLimit = 0
+
+			ctx := &testContext{t, harness}
+			coinbase := ctx.addCoinbaseTx(1)
+			outs := []spendableOutput{txOutToSpendableOut(coinbase, 0)}
+			parent := ctx.createTRUCTx(
+				outs, nil, 1, 0,
+				wire.NewTxOut(0, txscript.PayToAnchorScript()),
+			)
+
+			outs = []spendableOutput{txOutToSpendableOut(parent, 0)}
+			var anchors []spendableOutput
+			if testCase.spendDust {
+				anchors = append(anchors,
+					txOutToSpendableOut(parent, 1))
+			}
+			child := ctx.createTRUCTx(
+				outs, anchors, 1, testCase.childFee,
+			)
+
+			// The parent is rejected on its own.
+			_, err = harness.txPool.ProcessTransaction(
+				parent, true, true, 0,
+			)
+			if code, _ := extractRejectCode(err); code !=
+				wire.RejectInsufficientFee {
+
+				t.Fatalf("expected insufficient fee error "+
+					"for parent, got: %v", err)
+			}
+			testPoolMembership(ctx, parent, false, false)
+
+			// The child is an orphan until its parent arrives.
+			_, err = harness.txPool.ProcessTransaction(
+				child, true, true, 0,
+			)
+			if err != nil {
+				t.Fatalf("unable to process child: %v", err)
+			}
+			testPoolMembership(ctx, child, true, false)
+
+			sequence := harness.txPool.Sequence()
+			acceptedTxs, err := harness.txPool.ProcessTransaction(
+				parent, true, true, 0,
+			)
+			if testCase.err != "" {
+				if err == nil || !strings.Contains(
+					err.Error(), testCase.err) {
+
+					t.Fatalf("expected error: %v\ngot: %v",
+						testCase.err, err)
+				}
+				testPoolMembership(ctx, parent, false, false)
+				if got := harness.txPool.Sequence(); got != sequence {
+					t.Fatalf("expected mempool sequence "+
+						"%d, got %d", sequence, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unable to process package: %v", err)
+			}
+
+			if len(acceptedTxs) != 2 ||
+				acceptedTxs[0].Tx.Hash() != parent.Hash() ||
+				acceptedTxs[1].Tx.Hash() != child.Hash() {
+
+				t.Fatalf("expected parent and child to be " +
+					"accepted in order")
+			}
+			testPoolMembership(ctx, parent, false, true)
+			testPoolMembership(ctx, child, false, true)
+		})
+		if !success {
+			break
+		}
+	}
+}
+
+// waitForNotifications blocks until all additions and removals queued for
+// delivery to the notification callbacks of the passed pool were delivered.
+func waitForNotifications(mp *TxPool) {
+	for {
+		mp.ntfnMtx.Lock()
+		delivering := mp.ntfnDelivering
+		mp.ntfnMtx.Unlock()
+		if !delivering {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestExpireTransactions ensures that transactions which have been in the
+// mempool for longer than the expiry timeout are removed along with their
+// descendants and that a removal notification is emitted for each of them.
+func TestExpireTransactions(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	harness.txPool.cfg.Policy.Expiry = time.Hour
+	removed := make(map[chainhash.Hash]RemovalReason)
+	harness.txPool.cfg.TxRemoved = func(removal *TxRemoval) {
+		removed[*removal.Tx.Hash()] = removal.Reason
+	}
+	ctx := &testContext{t, harness}
+
+	// Create a parent with a child along with an unrelated transaction.
+	coinbase := ctx.addCoinbaseTx(2)
+	parent := ctx.addSignedTx(
+		[]spendableOutput{txOutToSpendableOut(coinbase, 0)}, 1, 1000,
+		false, false,
+	)
+	child := ctx.addSignedTx(
+		[]spendableOutput{txOutToSpendableOut(parent, 0)}, 1, 1000,
+		false, false,
+	)
+	unrelated := ctx.addSignedTx(
+		[]spendableOutput{txOutToSpendableOut(coinbase, 1)}, 1, 1000,
+		false, false,
+	)
+
+	// Nothing should expire while all transactions are recent.
+	if n := harness.txPool.ExpireTransactions(); n != 0 {
+		t.Fatalf("expected no expired transactions, got %d", n)
+	}
+
+	// Age the parent past the expiry timeout.  It should be expired along
+	// with its child while the unrelated transaction remains.
+	harness.txPool.pool[*parent.Hash()].Added = time.Now().Add(-2 * time.Hour)
+	if n := harness.txPool.ExpireTransactions(); n != 2 {
+		t.Fatalf("expected 2 expired transactions, got %d", n)
+	}
+	waitForNotifications(harness.txPool)
+	testPoolMembership(ctx, parent, false, false)
+	testPoolMembership(ctx, child, false, false)
+	testPoolMembership(ctx, unrelated, false, true)
+
+	for _, tx := range []*btcutil.Tx{parent, child} {
+		reason, ok := removed[*tx.Hash()]
+		if !ok {
+			t.Fatalf("missing removal notification for %v",
+				tx.Hash())
+		}
+		if reason != RemovalReasonExpiry {
+			t.Fatalf("expected removal reason %v, got %v",
+				RemovalReasonExpiry, reason)
+		}
+	}
+	if len(removed) != 2 {
+		t.Fatalf("expected 2 removal notifications, got %d",
+			len(removed))
+	}
+	if n := harness.txPool.NumExpired(); n != 2 {
+		t.Fatalf("expected 2 total expired transactions, got %d", n)
+	}
+}
+
+// TestRemovalNotifications ensures that removal notifications are emitted with
+// the expected reason and replacing transaction, and that every addition to and
+// removal from the pool is assigned an increasing sequence number.
+func TestRemovalNotifications(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	var removals []*TxRemoval
+	harness.txPool.cfg.TxRemoved = func(removal *TxRemoval) {
+		removals = append(removals, removal)
+	}
+	ctx := &testContext{t, harness}
+
+	// sequences tracks the sequence numbers assigned to all additions and
+	// removals in the order they are observed.
+	var sequences []uint64
+	addTx := func(inputs []spendableOutput, fee btcutil.Amount,
+		signalsReplacement bool) *btcutil.Tx {
+
+		t.Helper()
+		tx := ctx.addSignedTx(inputs, 1, fee, signalsReplacement, false)
+		sequences = append(sequences, harness.txPool.pool[*tx.Hash()].Sequence)
+		return tx
+	}
+	checkRemovals := func(numRemovals int, txns []*btcutil.Tx,
+		reason RemovalReason, replacedBy *chainhash.Hash) {
+
+		t.Helper()
+		waitForNotifications(harness.txPool)
+		newRemovals := removals[numRemovals:]
+		if len(newRemovals) != len(txns) {
+			t.Fatalf("expected %d removal notifications, got %d",
+				len(txns), len(newRemovals))
+		}
+		want := make(map[chainhash.Hash]struct{})
+		for _, tx := range txns {
+			want[*tx.Hash()] = struct{}{}
+		}
+		for _, removal := range newRemovals {
+			if _, ok := want[*removal.Tx.Hash()]; !ok {
+				t.Fatalf("unexpected removal notification for %v",
+					removal.Tx.Hash())
+			}
+			if removal.Reason != reason {
+				t.Fatalf("expected removal reason %v, got %v",
+					reason, removal.Reason)
+			}
+			switch {
+			case replacedBy == nil && removal.ReplacedBy != nil:
+				t.Fatalf("unexpected replacing transaction %v",
+					removal.ReplacedBy)
+			case replacedBy != nil && (removal.ReplacedBy == nil ||
+				*removal.ReplacedBy != *replacedBy):
+				t.Fatalf("expected replacing transaction %v, got %v",
+					replacedBy, removal.ReplacedBy)
+			}
+			sequences = append(sequences, removal.Sequence)
+		}
+	}
+
+	// Create a parent signaling replacement with a child.
+	coinbase := ctx.addCoinbaseTx(2)
+	parent := addTx(
+		[]spendableOutput{txOutToSpendableOut(coinbase, 0)}, 1000, true,
+	)
+	child := addTx(
+		[]spendableOutput{txOutToSpendableOut(parent, 0)}, 1000, false,
+	)
+
+	// Replacing the parent should remove both the parent and its child
+	// with the replacing transaction attached to the removals.
+	waitForNotifications(harness.txPool)
+	numRemovals := len(removals)
+	replacement := ctx.addSignedTx(
+		[]spendableOutput{txOutToSpendableOut(coinbase, 0)}, 1, 100000,
+		false, false,
+	)
+	checkRemovals(
+		numRemovals, []*btcutil.Tx{parent, child},
+		RemovalReasonReplaced, replacement.Hash(),
+	)
+	sequences = append(sequences,
+		harness.txPool.pool[*replacement.Hash()].Sequence)
+
+	// A transaction mined in a block which conflicts with one in the pool
+	// should cause the pool transaction to be removed as a conflict.
+	unrelated := addTx(
+		[]spendableOutput{txOutToSpendableOut(coinbase, 1)}, 1000, false,
+	)
+	mined, err := harness.CreateSignedTx(
+		[]spendableOutput{txOutToSpendableOut(coinbase, 1)}, 1, 2000,
+		false,
+	)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	waitForNotifications(harness.txPool)
+	numRemovals = len(removals)
+	harness.txPool.RemoveDoubleSpends(mined)
+	testPoolMembership(ctx, unrelated, false, false)
+	checkRemovals(
+		numRemovals, []*btcutil.Tx{unrelated}, RemovalReasonConflict,
+		mined.Hash(),
+	)
+
+	// Finally, removing a transaction due to it being mined should report
+	// the provided reason without a replacing transaction.
+	waitForNotifications(harness.txPool)
+	numRemovals = len(removals)
+	harness.txPool.RemoveTransaction(replacement, false, RemovalReasonBlock)
+	testPoolMembership(ctx, replacement, false, false)
+	checkRemovals(
+		numRemovals, []*btcutil.Tx{replacement}, RemovalReasonBlock,
+		nil,
+	)
+
+	// Every addition and removal should have been assigned a unique
+	// sequence number in increasing order with no gaps.
+	for i, sequence := range sequences {
+		if sequence != uint64(i+1) {
+			t.Fatalf("expected sequence %d for event %d, got %d",
+				i+1, i, sequence)
+		}
+	}
+	if sequence := harness.txPool.Sequence(); sequence != uint64(len(sequences)) {
+		t.Fatalf("expected mempool sequence %d, got %d",
+			len(sequences), sequence)
+	}
+}
+
+// TestAcceptNotifications ensures that addition notifications are emitted for
+// transactions added to the pool and prioritised, and that they are delivered
+// in order with the removal notifications.
+func TestAcceptNotifications(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	var events []string
+	var accepted []*TxDesc
+	harness.txPool.cfg.TxAccepted = func(txDesc *TxDesc) {
+		events = append(events, "accepted "+txDesc.Tx.Hash().String())
+		accepted = append(accepted, txDesc)
+	}
+	harness.txPool.cfg.TxRemoved = func(removal *TxRemoval) {
+		events = append(events, "removed "+removal.Tx.Hash().String())
+	}
+	ctx := &testContext{t, harness}
+
+	// Create a parent signaling replacement and replace it.  The removal
+	// of the parent must be delivered before the addition of the
+	// replacement.
+	coinbase := ctx.addCoinbaseTx(1)
+	parent := ctx.addSignedTx(
+		[]spendableOutput{txOutToSpendableOut(coinbase, 0)}, 1, 1000,
+		true, false,
+	)
+	replacement := ctx.addSignedTx(
+		[]spendableOutput{txOutToSpendableOut(coinbase, 0)}, 1, 100000,
+		false, false,
+	)
+
+	// Prioritising a transaction in the pool should deliver its updated
+	// descriptor while prioritising an unknown one should not.
+	harness.txPool.PrioritiseTransaction(replacement.Hash(), 5000)
+	harness.txPool.PrioritiseTransaction(parent.Hash(), 5000)
+	waitForNotifications(harness.txPool)
+
+	want := []string{
+		"accepted " + parent.Hash().String(),
+		"removed " + parent.Hash().String(),
+		"accepted " + replacement.Hash().String(),
+		"accepted " + replacement.Hash().String(),
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d notifications, got %d: %v", len(want),
+			len(events), events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("expected notification %d to be %q, got %q", i,
+				want[i], events[i])
+		}
+	}
+	if delta := accepted[len(accepted)-1].FeeDelta; delta != 5000 {
+		t.Fatalf("expected fee delta 5000 in updated descriptor, got %d",
+			delta)
+	}
+	if accepted[1].FeeDelta != 0 {
+		t.Fatalf("original descriptor was modified by prioritisation")
+	}
+}
+
+// TestConcurrentNotifications ensures that modifying the pool does not wait for
+// the notification callbacks, that the additions and removals are delivered in
+// the order the changes occurred regardless, and that the callbacks are able
+// to modify the pool.
+func TestConcurrentNotifications(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+
+	const numTxns = 6
+	coinbase := ctx.addCoinbaseTx(numTxns + 1)
+	evicted, err := harness.CreateSignedTx(
+		[]spendableOutput{txOutToSpendableOut(coinbase, numTxns)}, 1,
+		1000, false,
+	)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	evictedHash := *evicted.Hash()
+
+	// Block the delivery of the first addition until all changes were
+	// made, and remove the evicted transaction from within the callback
+	// delivering its addition.
+	release := make(chan struct{})
+	var events []string
+	harness.txPool.cfg.TxAccepted = func(txDesc *TxDesc) {
+		if len(events) == 0 {
+			<-release
+		}
+		txHash := txDesc.Tx.Hash()
+		events = append(events, "accepted "+txHash.String())
+		if *txHash == evictedHash {
+			harness.txPool.RemoveTransaction(txDesc.Tx, false,
+				RemovalReasonBlock)
+		}
+	}
+	harness.txPool.cfg.TxRemoved = func(removal *TxRemoval) {
+		events = append(events, "removed "+removal.Tx.Hash().String())
+	}
+
+	// Add and remove or replace transactions while the delivery is
+	// blocked.
+	var want []string
+	for i := uint32(0); i < numTxns; i++ {
+		input := []spendableOutput{txOutToSpendableOut(coinbase, i)}
+		tx := ctx.addSignedTx(input, 1, 1000, true, false)
+		want = append(want, "accepted "+tx.Hash().String(),
+			"removed "+tx.Hash().String())
+		if i%2 == 0 {
+			harness.txPool.RemoveTransaction(tx, false,
+				RemovalReasonBlock)
+		} else {
+			replacement := ctx.addSignedTx(input, 1, 100000, false,
+				false)
+			want = append(want, "accepted "+
+				replacement.Hash().String())
+		}
+	}
+	ctx.addSignedTx(
+		[]spendableOutput{txOutToSpendableOut(coinbase, numTxns)}, 1,
+		1000, false, false,
+	)
+	want = append(want, "accepted "+evictedHash.String(),
+		"removed "+evictedHash.String())
+
+	close(release)
+	waitForNotifications(harness.txPool)
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("got notifications %v, want %v", events, want)
+	}
+	testPoolMembership(ctx, evicted, false, false)
+}
@@ -53,6 +53,11 @@ const (
 	// in a multi-signature transaction output script for it to be
 	// considered standard.
 	maxStandardMultiSigKeys = 3
+
+	// maxEphemeralDustOutputs is the maximum number of dust outputs a TRUC
+	// transaction may create.  Such ephemeral dust is only standard when
+	// the transaction pays no fee and the dust is spent by its child.
+	maxEphemeralDustOutputs = 1
 )
 
 // calcMinRequiredTxRelayFee returns the minimum transaction fee required for a
@@ -112,6 +117,16 @@ func checkInputsStandard(tx *btcutil.Tx, utxoView *blockchain.UtxoViewpoint) err
 				return txRuleError(wire.RejectNonstandard, str)
 			}
 
+		case txscript.PayToAnchorTy:
+			// Anchor outputs are keyless, so any witness data is
+			// only useful to pad the transaction.
+			if len(txIn.Witness) != 0 {
+				str := fmt.Sprintf("transaction input #%d "+
+					"spends an anchor output with a "+
+					"non-empty witness", i)
+				return txRuleError(wire.RejectNonstandard, str)
+			}
+
 		case txscript.NonStandardTy:
 			str := fmt.Sprintf("transaction input #%d has a "+
 				"non-standard script form", i)
@@ -275,6 +290,23 @@ func IsDust(txOut *wire.TxOut, minRelayTxFee btcutil.Amount) bool {
 	return txOut.Value*1000/GetDustThreshold(txOut) < int64(minRelayTxFee)
 }
 
+// dustOutputs returns the indexes of all outputs of the passed transaction that
+// are considered dust based on the passed minimum transaction relay fee.  Null
+// data outputs are never considered dust.
+func dustOutputs(tx *btcutil.Tx, minRelayTxFee btcutil.Amount) []uint32 {
+	var dust []uint32
+	for i, txOut := range tx.MsgTx().TxOut {
+		if txscript.GetScriptClass(txOut.PkScript) == txscript.NullDataTy {
+			continue
+		}
+		if IsDust(txOut, minRelayTxFee) {
+			dust = append(dust, uint32(i))
+		}
+	}
+
+	return dust
+}
+
 // CheckTransactionStandard performs a series of checks on a transaction to
 // ensure it is a "standard" transaction.  A standard transaction is one that
 // conforms to several additional limiting cases over what is considered a
@@ -282,6 +314,10 @@ func IsDust(txOut *wire.TxOut, minRelayTxFee btcutil.Amount) bool {
 // finalized, conforming to more stringent size constraints, having scripts
 // of recognized forms, and not containing "dust" outputs (those that are
 // so small it costs more to process them than they are worth).
+//
+// TRUC transactions are allowed a single dust output, known as ephemeral
+// dust.  The additional requirements that the transaction pays no fee and
+// that the dust is spent by its child are enforced by the memory pool.
 func CheckTransactionStandard(tx *btcutil.Tx, height int32,
 	medianTimePast time.Time, minRelayTxFee btcutil.Amount,
 	maxTxVersion int32) error {
@@ -338,6 +374,7 @@ func CheckTransactionStandard(tx *btcutil.Tx, height int32,
 	// None of the output public key scripts can be a non-standard script or
 	// be "dust" (except when the script is a null data script).
 	numNullDataOutputs := 0
+	numDustOutputs := 0
 	for i, txOut := range msgTx.TxOut {
 		scriptClass := txscript.GetScriptClass(txOut.PkScript)
 		err := checkPkScriptStandard(txOut.PkScript, scriptClass)
@@ -359,6 +396,13 @@ func CheckTransactionStandard(tx *btcutil.Tx, height int32,
 		if scriptClass == txscript.NullDataTy {
 			numNullDataOutputs++
 		} else if IsDust(txOut, minRelayTxFee) {
+			numDustOutputs++
+			if msgTx.Version == TRUCVersion &&
+				numDustOutputs <= maxEphemeralDustOutputs {
+
+				continue
+			}
+
 			str := fmt.Sprintf("transaction output %d: payment "+
 				"of %d is dust", i, txOut.Value)
 			return txRuleError(wire.RejectDust, str)
@@ -307,11 +307,12 @@ func TestCheckTransactionStandard(t *testing.T) {
 	}
 
 	tests := []struct {
-		name       string
-		tx         wire.MsgTx
-		height     int32
-		isStandard bool
-		code       wire.RejectCode
+		name         string
+		tx           wire.MsgTx
+		height       int32
+		maxTxVersion int32
+		isStandard   bool
+		code         wire.RejectCode
 	}{
 		{
 			name: "Typical pay-to-pubkey-hash transaction",
@@ -464,13 +465,79 @@ func TestCheckTransactionStandard(t *testing.T) {
 			height:     300000,
 			isStandard: true,
 		},
+		{
+			name: "Pay-to-anchor output (standard)",
+			tx: wire.MsgTx{
+				Version: 1,
+				TxIn:    []*wire.TxIn{&dummyTxIn},
+				TxOut: []*wire.TxOut{&dummyTxOut, {
+					Value:    240,
+					PkScript: txscript.PayToAnchorScript(),
+				}},
+				LockTime: 0,
+			},
+			height:     300000,
+			isStandard: true,
+		},
+		{
+			name: "TRUC transaction",
+			tx: wire.MsgTx{
+				Version:  TRUCVersion,
+				TxIn:     []*wire.TxIn{&dummyTxIn},
+				TxOut:    []*wire.TxOut{&dummyTxOut},
+				LockTime: 0,
+			},
+			height:       300000,
+			maxTxVersion: TRUCVersion,
+			isStandard:   true,
+		},
+		{
+			name: "TRUC transaction with one ephemeral dust output",
+			tx: wire.MsgTx{
+				Version: TRUCVersion,
+				TxIn:    []*wire.TxIn{&dummyTxIn},
+				TxOut: []*wire.TxOut{&dummyTxOut, {
+					Value:    0,
+					PkScript: txscript.PayToAnchorScript(),
+				}},
+				LockTime: 0,
+			},
+			height:       300000,
+			maxTxVersion: TRUCVersion,
+			isStandard:   true,
+		},
+		{
+			name: "TRUC transaction with two dust outputs",
+			tx: wire.MsgTx{
+				Version: TRUCVersion,
+				TxIn:    []*wire.TxIn{&dummyTxIn},
+				TxOut: []*wire.TxOut{{
+					Value:    0,
+					PkScript: txscript.PayToAnchorScript(),
+				}, {
+					Value:    0,
+					PkScript: dummyPkScript,
+				}},
+				LockTime: 0,
+			},
+			height:       300000,
+			maxTxVersion: TRUCVersion,
+			isStandard:   false,
+			code:         wire.RejectDust,
+		},
 	}
 
 	pastMedianTime := time.Now()
 	for _, test := range tests {
+		maxTxVersion := test.maxTxVersion
+		if maxTxVersion == 0 {
+			maxTxVersion = 1
+		}
+
 		// Ensure standardness is as expected.
 		err := CheckTransactionStandard(btcutil.NewTx(&test.tx),
-			test.height, pastMedianTime, DefaultMinRelayTxFee, 1)
+			test.height, pastMedianTime, DefaultMinRelayTxFee,
+			maxTxVersion)
 		if err == nil && test.isStandard {
 			// Test passes since function returned standard for a
 			// transaction which is intended to be standard.
@@ -0,0 +1,923 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// The smart fee estimator is modelled after the block policy estimator of the
+// reference implementation.  Transactions are grouped into exponentially
+// spaced fee rate buckets and, for each bucket, exponentially decaying
+// counters track how many transactions confirmed within a given number of
+// blocks, how many left the mempool without confirming and how many are still
+// waiting.  The counters are maintained over a short, medium and long horizon,
+// each with its own decay and granularity, so that estimates react quickly to
+// changing conditions for short targets while remaining stable for long ones.
+
+const (
+	// smartFeeMinBucketFeeRate is the upper bound, in satoshis per kilo
+	// virtual byte, of the lowest fee rate bucket.
+	smartFeeMinBucketFeeRate = 100
+
+	// smartFeeMaxBucketFeeRate is the highest bucket bound, in satoshis per
+	// kilo virtual byte, below the catch-all bucket.
+	smartFeeMaxBucketFeeRate = 1e7
+
+	// smartFeeInfFeeRate is the upper bound of the catch-all bucket that
+	// holds all fee rates above smartFeeMaxBucketFeeRate.
+	smartFeeInfFeeRate = 1e99
+
+	// smartFeeBucketSpacing is the ratio between the bounds of adjacent
+	// fee rate buckets.
+	smartFeeBucketSpacing = 1.05
+
+	// shortBlockPeriods, shortScale and shortDecay define the short
+	// horizon which tracks up to 12 blocks at a granularity of one block
+	// with a half life of 18 blocks.
+	shortBlockPeriods = 12
+	shortScale        = 1
+	shortDecay        = .962
+
+	// medBlockPeriods, medScale and medDecay define the medium horizon
+	// which tracks up to 48 blocks at a granularity of two blocks with a
+	// half life of 144 blocks.
+	medBlockPeriods = 24
+	medScale        = 2
+	medDecay        = .9952
+
+	// longBlockPeriods, longScale and longDecay define the long horizon
+	// which tracks up to 1008 blocks at a granularity of 24 blocks with a
+	// half life of 1008 blocks.
+	longBlockPeriods = 42
+	longScale        = 24
+	longDecay        = .99931
+
+	// halfSuccessPct is the success rate required for half of the
+	// confirmation target.
+	halfSuccessPct = .6
+
+	// successPct is the success rate required for the confirmation target.
+	successPct = .85
+
+	// doubleSuccessPct is the success rate required for double the
+	// confirmation target.
+	doubleSuccessPct = .95
+
+	// sufficientFeeTxs is the number of transactions per block, on
+	// average, that must be in a range of buckets for it to be
+	// considered by the medium and long horizons.
+	sufficientFeeTxs = 0.1
+
+	// sufficientTxsShort is the number of transactions per block, on
+	// average, that must be in a range of buckets for it to be
+	// considered by the short horizon.
+	sufficientTxsShort = 0.5
+
+	// DefaultEstimateRawFeeThreshold is the default success rate required
+	// by EstimateRawFee.
+	DefaultEstimateRawFeeThreshold = doubleSuccessPct
+)
+
+// FeeEstimateHorizon identifies one of the time horizons over which the fee
+// estimator tracks confirmation statistics.
+type FeeEstimateHorizon int
+
+const (
+	// FeeHorizonShort tracks confirmations within 12 blocks and reacts
+	// quickly to changing conditions.
+	FeeHorizonShort FeeEstimateHorizon = iota
+
+	// FeeHorizonMedium tracks confirmations within 48 blocks.
+	FeeHorizonMedium
+
+	// FeeHorizonLong tracks confirmations within 1008 blocks and changes
+	// slowly over time.
+	FeeHorizonLong
+)
+
+// FeeHorizons lists all fee estimate horizons from shortest to longest.
+var FeeHorizons = []FeeEstimateHorizon{
+	FeeHorizonShort, FeeHorizonMedium, FeeHorizonLong,
+}
+
+// feeHorizonStrings is a map of fee estimate horizons back to their names for
+// pretty printing.
+var feeHorizonStrings = map[FeeEstimateHorizon]string{
+	FeeHorizonShort:  "short",
+	FeeHorizonMedium: "medium",
+	FeeHorizonLong:   "long",
+}
+
+// String returns the FeeEstimateHorizon as a human-readable name.
+func (h FeeEstimateHorizon) String() string {
+	if s := feeHorizonStrings[h]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown FeeEstimateHorizon (%d)", int(h))
+}
+
+// FeeRateBucketStats describes the statistics of a contiguous range of fee
+// rate buckets that were considered while producing a raw fee estimate.
+type FeeRateBucketStats struct {
+	// StartRange and EndRange are the bounds of the range in satoshis per
+	// kilo virtual byte.  They are both -1 if the range is not set.
+	StartRange float64
+	EndRange   float64
+
+	// WithinTarget is the decayed number of transactions in the range that
+	// confirmed within the target.
+	WithinTarget float64
+
+	// TotalConfirmed is the decayed number of transactions in the range
+	// that confirmed at any point.
+	TotalConfirmed float64
+
+	// InMempool is the number of transactions in the range that are still
+	// in the mempool after more blocks than the target.
+	InMempool float64
+
+	// LeftMempool is the decayed number of transactions in the range that
+	// left the mempool without confirming after more blocks than the
+	// target.
+	LeftMempool float64
+}
+
+// newFeeRateBucketStats returns bucket statistics for an unset range.
+func newFeeRateBucketStats() FeeRateBucketStats {
+	return FeeRateBucketStats{StartRange: -1, EndRange: -1}
+}
+
+// RawFeeEstimate is the result of a fee estimate over a single horizon along
+// with the statistics that it was derived from.
+type RawFeeEstimate struct {
+	// FeeRate is the estimated fee rate.  It is zero when there wasn't
+	// enough data or no fee rate met the required success rate.
+	FeeRate BtcPerKilobyte
+
+	// Decay is the exponential decay applied to the statistics of the
+	// horizon every block.
+	Decay float64
+
+	// Scale is the number of blocks per confirmation period of the
+	// horizon.
+	Scale uint32
+
+	// Pass describes the lowest range of buckets that met the success
+	// rate.
+	Pass FeeRateBucketStats
+
+	// Fail describes the highest range of buckets that did not meet the
+	// success rate.
+	Fail FeeRateBucketStats
+}
+
+// confirmStats tracks decaying confirmation statistics for every fee rate
+// bucket over a single horizon.
+type confirmStats struct {
+	// buckets houses the upper bounds of the fee rate buckets.  It is
+	// shared by all horizons.
+	buckets []float64
+
+	decay float64
+	scale uint32
+
+	// txCtAvg and feeRateAvg are the decayed number of confirmed
+	// transactions and the sum of their fee rates per bucket.
+	txCtAvg    []float64
+	feeRateAvg []float64
+
+	// confAvg and failAvg are the decayed number of transactions per
+	// period and bucket that confirmed within, or left the mempool
+	// without confirming after, the number of periods.
+	confAvg [][]float64
+	failAvg [][]float64
+
+	// unconfTxs is the number of unconfirmed transactions per bucket that
+	// entered the mempool at each of the most recent heights, while
+	// oldUnconfTxs is the number of those which entered it earlier.
+	unconfTxs    [][]int
+	oldUnconfTxs []int
+}
+
+// newConfirmStats returns confirmation statistics for the passed buckets that
+// track the given number of periods of scale blocks.
+func newConfirmStats(buckets []float64, periods int, decay float64,
+	scale uint32) *confirmStats {
+
+	s := &confirmStats{
+		buckets:      buckets,
+		decay:        decay,
+		scale:        scale,
+		txCtAvg:      make([]float64, len(buckets)),
+		feeRateAvg:   make([]float64, len(buckets)),
+		confAvg:      make([][]float64, periods),
+		failAvg:      make([][]float64, periods),
+		oldUnconfTxs: make([]int, len(buckets)),
+	}
+	for i := 0; i < periods; i++ {
+		s.confAvg[i] = make([]float64, len(buckets))
+		s.failAvg[i] = make([]float64, len(buckets))
+	}
+	s.unconfTxs = make([][]int, s.maxConfirms())
+	for i := range s.unconfTxs {
+		s.unconfTxs[i] = make([]int, len(buckets))
+	}
+
+	return s
+}
+
+// maxConfirms returns the highest confirmation target tracked by the
+// statistics.
+func (s *confirmStats) maxConfirms() uint32 {
+	return s.scale * uint32(len(s.confAvg))
+}
+
+// bucketIndex returns the index of the bucket the passed fee rate belongs to.
+func (s *confirmStats) bucketIndex(feeRate float64) int {
+	return sort.SearchFloat64s(s.buckets, feeRate)
+}
+
+// unconfIndex returns the index into unconfTxs for the passed height.
+func (s *confirmStats) unconfIndex(height int64) int {
+	bins := int64(len(s.unconfTxs))
+	return int((height%bins + bins) % bins)
+}
+
+// record accounts for a transaction with the passed fee rate that confirmed
+// after the passed number of blocks.
+func (s *confirmStats) record(blocksToConfirm int32, feeRate float64) {
+	if blocksToConfirm < 1 {
+		return
+	}
+
+	periodsToConfirm := (uint32(blocksToConfirm) + s.scale - 1) / s.scale
+	bucket := s.bucketIndex(feeRate)
+	for i := int(periodsToConfirm); i <= len(s.confAvg); i++ {
+		s.confAvg[i-1][bucket]++
+	}
+	s.txCtAvg[bucket]++
+	s.feeRateAvg[bucket] += feeRate
+}
+
+// updateMovingAverages decays all of the tracked statistics.  It is called
+// once per block.
+func (s *confirmStats) updateMovingAverages() {
+	for j := range s.buckets {
+		for i := range s.confAvg {
+			s.confAvg[i][j] *= s.decay
+			s.failAvg[i][j] *= s.decay
+		}
+		s.feeRateAvg[j] *= s.decay
+		s.txCtAvg[j] *= s.decay
+	}
+}
+
+// clearCurrent moves the unconfirmed transactions that entered the mempool
+// maxConfirms blocks before the passed height into the bucket of old
+// unconfirmed transactions so the slot can be reused for the passed height.
+func (s *confirmStats) clearCurrent(height int32) {
+	index := s.unconfIndex(int64(height))
+	for j := range s.buckets {
+		s.oldUnconfTxs[j] += s.unconfTxs[index][j]
+		s.unconfTxs[index][j] = 0
+	}
+}
+
+// newTx accounts for a new unconfirmed transaction which entered the mempool
+// at the passed height.
+func (s *confirmStats) newTx(height int32, bucket int) {
+	s.unconfTxs[s.unconfIndex(int64(height))][bucket]++
+}
+
+// removeTx removes an unconfirmed transaction that entered the mempool at the
+// passed height.  When the transaction left the mempool without being
+// included in a block, it is accounted as a failure for every period that
+// passed since it entered the mempool.
+func (s *confirmStats) removeTx(entryHeight, bestSeenHeight int32, bucket int,
+	inBlock bool) {
+
+	blocksAgo := bestSeenHeight - entryHeight
+	if bestSeenHeight == 0 {
+		blocksAgo = 0
+	}
+	if blocksAgo < 0 {
+		log.Debugf("Fee estimator: transaction entered the mempool in " +
+			"the future")
+		return
+	}
+
+	if blocksAgo >= int32(len(s.unconfTxs)) {
+		if s.oldUnconfTxs[bucket] > 0 {
+			s.oldUnconfTxs[bucket]--
+		}
+	} else {
+		index := s.unconfIndex(int64(entryHeight))
+		if s.unconfTxs[index][bucket] > 0 {
+			s.unconfTxs[index][bucket]--
+		}
+	}
+
+	if !inBlock && uint32(blocksAgo) >= s.scale {
+		periodsAgo := uint32(blocksAgo) / s.scale
+		for i := uint32(0); i < periodsAgo && int(i) < len(s.failAvg); i++ {
+			s.failAvg[i][bucket]++
+		}
+	}
+}
+
+// estimateMedianVal returns the median fee rate of the lowest range of
+// buckets, searching from the highest fee rate down, in which at least the
+// passed success rate of transactions confirmed within the confirmation
+// target.  Only ranges with enough transactions on average per block are
+// considered.  It returns -1 when no such range exists along with the
+// statistics of the ranges that passed and failed.
+func (s *confirmStats) estimateMedianVal(confTarget uint32,
+	sufficientTxVal, successBreakPoint float64,
+	height int32) (float64, FeeRateBucketStats, FeeRateBucketStats) {
+
+	// Counters for a bucket (or range of buckets).
+	var nConf, totalNum, failNum, extraNum float64
+
+	periodTarget := int((confTarget + s.scale - 1) / s.scale)
+	maxBucketIndex := len(s.buckets) - 1
+
+	// We'll combine buckets until we have enough samples.  The near and
+	// far variables will define the range we've combined.  The best
+	// variables are the last range we saw which still had a high enough
+	// confirmation rate to count as success.  The cur variables are the
+	// current range we're counting.
+	curNearBucket, bestNearBucket := maxBucketIndex, maxBucketIndex
+	curFarBucket, bestFarBucket := maxBucketIndex, maxBucketIndex
+
+	foundAnswer := false
+	newBucketRange := true
+	passing := true
+	passBucket := newFeeRateBucketStats()
+	failBucket := newFeeRateBucketStats()
+	rangeBounds := func(near, far int) (float64, float64) {
+		minBucket, maxBucket := near, far
+		if far < near {
+			minBucket, maxBucket = far, near
+		}
+		start := float64(0)
+		if minBucket > 0 {
+			start = s.buckets[minBucket-1]
+		}
+		return start, s.buckets[maxBucket]
+	}
+
+	// Start counting from highest fee rate transactions.
+	for bucket := maxBucketIndex; bucket >= 0; bucket-- {
+		if newBucketRange {
+			curNearBucket = bucket
+			newBucketRange = false
+		}
+		curFarBucket = bucket
+		nConf += s.confAvg[periodTarget-1][bucket]
+		totalNum += s.txCtAvg[bucket]
+		failNum += s.failAvg[periodTarget-1][bucket]
+		for confct := confTarget; confct < s.maxConfirms(); confct++ {
+			index := s.unconfIndex(int64(height) - int64(confct))
+			extraNum += float64(s.unconfTxs[index][bucket])
+		}
+		extraNum += float64(s.oldUnconfTxs[bucket])
+
+		// If we have enough transaction data points in this range of
+		// buckets, we can test for success.  Combine with the next
+		// bucket otherwise.
+		if totalNum < sufficientTxVal/(1-s.decay) {
+			continue
+		}
+
+		curPct := nConf / (totalNum + failNum + extraNum)
+
+		// Check to see if we are no longer getting confirmed at the
+		// success rate.
+		if curPct < successBreakPoint {
+			if passing {
+				// First time we hit a failure record the
+				// failed bucket.
+				failBucket.StartRange, failBucket.EndRange =
+					rangeBounds(curNearBucket, curFarBucket)
+				failBucket.WithinTarget = nConf
+				failBucket.TotalConfirmed = totalNum
+				failBucket.InMempool = extraNum
+				failBucket.LeftMempool = failNum
+				passing = false
+			}
+			continue
+		}
+
+		// Otherwise update the cumulative stats, and the bucket
+		// variables, and reset the counters.
+		failBucket = newFeeRateBucketStats()
+		foundAnswer = true
+		passing = true
+		passBucket.WithinTarget = nConf
+		passBucket.TotalConfirmed = totalNum
+		passBucket.InMempool = extraNum
+		passBucket.LeftMempool = failNum
+		nConf, totalNum, failNum, extraNum = 0, 0, 0, 0
+		bestNearBucket = curNearBucket
+		bestFarBucket = curFarBucket
+		newBucketRange = true
+	}
+
+	median := float64(-1)
+	var txSum float64
+
+	// Calculate the "average" fee rate of the best bucket range that met
+	// the success conditions.  Find the bucket with the median transaction
+	// and then report the average fee rate from that bucket.  This is a
+	// compromise between finding the median, which we can't since we don't
+	// save all transactions, and reporting the average, which is less
+	// accurate.
+	minBucket, maxBucket := bestNearBucket, bestFarBucket
+	if bestFarBucket < bestNearBucket {
+		minBucket, maxBucket = bestFarBucket, bestNearBucket
+	}
+	for j := minBucket; j <= maxBucket; j++ {
+		txSum += s.txCtAvg[j]
+	}
+	if foundAnswer && txSum != 0 {
+		txSum /= 2
+		for j := minBucket; j <= maxBucket; j++ {
+			if s.txCtAvg[j] < txSum {
+				txSum -= s.txCtAvg[j]
+				continue
+			}
+
+			// We're in the right bucket.
+			median = s.feeRateAvg[j] / s.txCtAvg[j]
+			break
+		}
+
+		passBucket.StartRange, passBucket.EndRange =
+			rangeBounds(bestNearBucket, bestFarBucket)
+	}
+
+	// If we were passing until we reached the last few buckets with
+	// insufficient data, then report those as failed.
+	if passing && !newBucketRange {
+		failBucket.StartRange, failBucket.EndRange =
+			rangeBounds(curNearBucket, curFarBucket)
+		failBucket.WithinTarget = nConf
+		failBucket.TotalConfirmed = totalNum
+		failBucket.InMempool = extraNum
+		failBucket.LeftMempool = failNum
+	}
+
+	return median, passBucket, failBucket
+}
+
+// serialize writes the decaying statistics to w.  The unconfirmed
+// transactions are not written since they refer to the contents of the
+// mempool which does not survive restarts.
+func (s *confirmStats) serialize(w io.Writer) {
+	binary.Write(w, binary.BigEndian, s.txCtAvg)
+	binary.Write(w, binary.BigEndian, s.feeRateAvg)
+	for i := range s.confAvg {
+		binary.Write(w, binary.BigEndian, s.confAvg[i])
+	}
+	for i := range s.failAvg {
+		binary.Write(w, binary.BigEndian, s.failAvg[i])
+	}
+}
+
+// deserialize reads the decaying statistics written by serialize from r.
+func (s *confirmStats) deserialize(r io.Reader) error {
+	if err := binary.Read(r, binary.BigEndian, s.txCtAvg); err != nil {
+		return err
+	}
+	if err := binary.Read(r, binary.BigEndian, s.feeRateAvg); err != nil {
+		return err
+	}
+	for i := range s.confAvg {
+		err := binary.Read(r, binary.BigEndian, s.confAvg[i])
+		if err != nil {
+			return err
+		}
+	}
+	for i := range s.failAvg {
+		err := binary.Read(r, binary.BigEndian, s.failAvg[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// trackedTx houses the information needed to account for a transaction in the
+// mempool once it is removed.
+type trackedTx struct {
+	height  int32
+	bucket  int
+	feeRate float64
+}
+
+// smartFeeEstimator tracks confirmation statistics over the short, medium and
+// long horizons and produces fee estimates from them.
+type smartFeeEstimator struct {
+	shortStats *confirmStats
+	medStats   *confirmStats
+	longStats  *confirmStats
+
+	// tracked houses the mempool transactions being tracked.  Only
+	// transactions that entered the mempool while it was up to date with
+	// the best seen block are tracked.
+	tracked map[chainhash.Hash]trackedTx
+
+	// bestSeenHeight is the height of the last processed block and
+	// firstRecordedHeight is the height of the first block which
+	// confirmed a tracked transaction.
+	bestSeenHeight      int32
+	firstRecordedHeight int32
+}
+
+// newSmartFeeEstimator returns a new smart fee estimator without any
+// statistics.
+func newSmartFeeEstimator() *smartFeeEstimator {
+	var buckets []float64
+	for bucket := float64(smartFeeMinBucketFeeRate); bucket <= smartFeeMaxBucketFeeRate; bucket *= smartFeeBucketSpacing {
+		buckets = append(buckets, bucket)
+	}
+	buckets = append(buckets, smartFeeInfFeeRate)
+
+	return &smartFeeEstimator{
+		shortStats: newConfirmStats(buckets, shortBlockPeriods,
+			shortDecay, shortScale),
+		medStats: newConfirmStats(buckets, medBlockPeriods, medDecay,
+			medScale),
+		longStats: newConfirmStats(buckets, longBlockPeriods, longDecay,
+			longScale),
+		tracked: make(map[chainhash.Hash]trackedTx),
+	}
+}
+
+// allStats returns the statistics of all horizons.
+func (se *smartFeeEstimator) allStats() []*confirmStats {
+	return []*confirmStats{se.shortStats, se.medStats, se.longStats}
+}
+
+// stats returns the statistics of the passed horizon.
+func (se *smartFeeEstimator) stats(horizon FeeEstimateHorizon) *confirmStats {
+	switch horizon {
+	case FeeHorizonShort:
+		return se.shortStats
+	case FeeHorizonMedium:
+		return se.medStats
+	default:
+		return se.longStats
+	}
+}
+
+// processTransaction starts tracking a transaction with the passed fee rate,
+// in satoshis per kilo virtual byte, that entered the mempool at the passed
+// height.
+func (se *smartFeeEstimator) processTransaction(hash chainhash.Hash,
+	height int32, feeRate float64) {
+
+	if _, ok := se.tracked[hash]; ok {
+		return
+	}
+
+	// Only transactions that entered the mempool while it was up to date
+	// with the best seen block can be tracked since it's otherwise unknown
+	// how many blocks they've been waiting for.
+	if height != se.bestSeenHeight {
+		return
+	}
+
+	bucket := se.shortStats.bucketIndex(feeRate)
+	for _, stats := range se.allStats() {
+		stats.newTx(height, bucket)
+	}
+	se.tracked[hash] = trackedTx{
+		height:  height,
+		bucket:  bucket,
+		feeRate: feeRate,
+	}
+}
+
+// removeTransaction stops tracking the transaction with the passed hash and
+// returns whether it was tracked.  When the transaction was not included in a
+// block, it is accounted as a failure to confirm.
+func (se *smartFeeEstimator) removeTransaction(hash chainhash.Hash,
+	inBlock bool) bool {
+
+	tx, ok := se.tracked[hash]
+	if !ok {
+		return false
+	}
+
+	for _, stats := range se.allStats() {
+		stats.removeTx(tx.height, se.bestSeenHeight, tx.bucket, inBlock)
+	}
+	delete(se.tracked, hash)
+
+	return true
+}
+
+// processBlock updates the statistics with the transactions included in the
+// block at the passed height.  Blocks at or below the best seen height, which
+// happens on reorganizations, are ignored.
+func (se *smartFeeEstimator) processBlock(height int32, txs []*btcutil.Tx) {
+	if height <= se.bestSeenHeight {
+		return
+	}
+	se.bestSeenHeight = height
+
+	// Clear the current block's slot of unconfirmed transactions and decay
+	// the existing statistics before recording the new confirmations.
+	for _, stats := range se.allStats() {
+		stats.clearCurrent(height)
+		stats.updateMovingAverages()
+	}
+
+	var numRecorded int
+	for _, tx := range txs {
+		entry, ok := se.tracked[*tx.Hash()]
+		if !ok {
+			continue
+		}
+		se.removeTransaction(*tx.Hash(), true)
+
+		blocksToConfirm := height - entry.height
+		if blocksToConfirm <= 0 {
+			continue
+		}
+
+		for _, stats := range se.allStats() {
+			stats.record(blocksToConfirm, entry.feeRate)
+		}
+		numRecorded++
+	}
+
+	if se.firstRecordedHeight == 0 && numRecorded > 0 {
+		se.firstRecordedHeight = height
+	}
+}
+
+// maxUsableEstimate returns the highest confirmation target that can be
+// estimated given the number of blocks that have been recorded.
+func (se *smartFeeEstimator) maxUsableEstimate() uint32 {
+	var blockSpan uint32
+	if se.firstRecordedHeight != 0 {
+		blockSpan = uint32(se.bestSeenHeight - se.firstRecordedHeight)
+	}
+
+	maxConfirms := se.longStats.maxConfirms()
+	if blockSpan/2 < maxConfirms {
+		return blockSpan / 2
+	}
+	return maxConfirms
+}
+
+// estimateCombinedFee returns the fee rate needed to confirm within the
+// target with the passed success rate using the shortest horizon that tracks
+// the target.  When checkShorterHorizon is set, estimates for the maximum
+// targets of the shorter horizons are also considered to react faster to
+// falling fee rates.  It returns -1 if there isn't an estimate.
+func (se *smartFeeEstimator) estimateCombinedFee(confTarget uint32,
+	successThreshold float64, checkShorterHorizon bool) float64 {
+
+	estimate := float64(-1)
+	if confTarget < 1 || confTarget > se.longStats.maxConfirms() {
+		return estimate
+	}
+
+	switch {
+	case confTarget <= se.shortStats.maxConfirms():
+		estimate, _, _ = se.shortStats.estimateMedianVal(confTarget,
+			sufficientTxsShort, successThreshold, se.bestSeenHeight)
+	case confTarget <= se.medStats.maxConfirms():
+		estimate, _, _ = se.medStats.estimateMedianVal(confTarget,
+			sufficientFeeTxs, successThreshold, se.bestSeenHeight)
+	default:
+		estimate, _, _ = se.longStats.estimateMedianVal(confTarget,
+			sufficientFeeTxs, successThreshold, se.bestSeenHeight)
+	}
+
+	if !checkShorterHorizon {
+		return estimate
+	}
+
+	// If the target is beyond a shorter horizon, a lower estimate for the
+	// maximum target of that horizon is used instead.
+	if confTarget > se.medStats.maxConfirms() {
+		medMax, _, _ := se.medStats.estimateMedianVal(
+			se.medStats.maxConfirms(), sufficientFeeTxs,
+			successThreshold, se.bestSeenHeight)
+		if medMax > 0 && (estimate == -1 || medMax < estimate) {
+			estimate = medMax
+		}
+	}
+	if confTarget > se.shortStats.maxConfirms() {
+		shortMax, _, _ := se.shortStats.estimateMedianVal(
+			se.shortStats.maxConfirms(), sufficientTxsShort,
+			successThreshold, se.bestSeenHeight)
+		if shortMax > 0 && (estimate == -1 || shortMax < estimate) {
+			estimate = shortMax
+		}
+	}
+
+	return estimate
+}
+
+// estimateConservativeFee returns the highest fee rate required to confirm
+// within the passed target, which is double the requested one, on the medium
+// and long horizons.  This ensures estimates don't drop too quickly after a
+// brief period of lower fee rates.  It returns -1 if there isn't an estimate.
+func (se *smartFeeEstimator) estimateConservativeFee(doubleTarget uint32) float64 {
+	estimate := float64(-1)
+	if doubleTarget <= se.shortStats.maxConfirms() {
+		estimate, _, _ = se.medStats.estimateMedianVal(doubleTarget,
+			sufficientFeeTxs, doubleSuccessPct, se.bestSeenHeight)
+	}
+	if doubleTarget <= se.medStats.maxConfirms() {
+		longEstimate, _, _ := se.longStats.estimateMedianVal(
+			doubleTarget, sufficientFeeTxs, doubleSuccessPct,
+			se.bestSeenHeight)
+		if longEstimate > estimate {
+			estimate = longEstimate
+		}
+	}
+
+	return estimate
+}
+
+// estimateSmartFee returns the fee rate, in satoshis per kilo virtual byte,
+// needed to confirm within the passed target along with the target that was
+// actually used, which may be lower when there isn't enough data.  The fee
+// rate is zero if there isn't an estimate.
+//
+// The estimate is the maximum of the estimates for half the target with a 60%
+// success rate, the target with an 85% success rate and double the target with
+// a 95% success rate.  Economical estimates only consider the shorter horizons
+// for the latter while conservative estimates also take the longer horizons
+// into account.
+func (se *smartFeeEstimator) estimateSmartFee(confTarget uint32,
+	conservative bool) (float64, uint32) {
+
+	if confTarget == 0 || confTarget > se.longStats.maxConfirms() {
+		return 0, 0
+	}
+
+	// It's not possible to get reasonable estimates for a target of one
+	// block, so use two instead.
+	if confTarget == 1 {
+		confTarget = 2
+	}
+
+	if maxUsable := se.maxUsableEstimate(); confTarget > maxUsable {
+		confTarget = maxUsable
+	}
+	if confTarget <= 1 {
+		return 0, confTarget
+	}
+
+	median := se.estimateCombinedFee(confTarget/2, halfSuccessPct, true)
+	if actual := se.estimateCombinedFee(confTarget, successPct, true); actual > median {
+		median = actual
+	}
+	doubleEst := se.estimateCombinedFee(2*confTarget, doubleSuccessPct,
+		!conservative)
+	if doubleEst > median {
+		median = doubleEst
+	}
+	if conservative || median == -1 {
+		consEst := se.estimateConservativeFee(2 * confTarget)
+		if consEst > median {
+			median = consEst
+		}
+	}
+
+	if median < 0 {
+		return 0, confTarget
+	}
+	return median, confTarget
+}
+
+// serialize writes the state of the estimator to w.
+func (se *smartFeeEstimator) serialize(w io.Writer) {
+	binary.Write(w, binary.BigEndian, se.bestSeenHeight)
+	binary.Write(w, binary.BigEndian, se.firstRecordedHeight)
+	for _, stats := range se.allStats() {
+		stats.serialize(w)
+	}
+}
+
+// deserializeSmartFeeEstimator reads the state written by serialize from r.
+func deserializeSmartFeeEstimator(r io.Reader) (*smartFeeEstimator, error) {
+	se := newSmartFeeEstimator()
+	if err := binary.Read(r, binary.BigEndian, &se.bestSeenHeight); err != nil {
+		return nil, err
+	}
+	err := binary.Read(r, binary.BigEndian, &se.firstRecordedHeight)
+	if err != nil {
+		return nil, err
+	}
+	for _, stats := range se.allStats() {
+		if err := stats.deserialize(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return se, nil
+}
+
+// EstimateSmartFee estimates the fee rate needed for a transaction to be
+// confirmed within the passed number of blocks.  It returns the estimate along
+// with the number of blocks the estimate is actually valid for, which may be
+// lower than requested when there isn't enough data.  Conservative estimates
+// take a longer history into account and are less susceptible to short-term
+// drops in fee rates.
+func (ef *FeeEstimator) EstimateSmartFee(confTarget uint32,
+	conservative bool) (BtcPerKilobyte, uint32, error) {
+
+	ef.mtx.Lock()
+	defer ef.mtx.Unlock()
+
+	maxTarget := ef.smart.longStats.maxConfirms()
+	if confTarget == 0 || confTarget > maxTarget {
+		return -1, 0, fmt.Errorf("confirmation target must be between "+
+			"1 and %d", maxTarget)
+	}
+
+	feeRate, target := ef.smart.estimateSmartFee(confTarget, conservative)
+	if feeRate == 0 {
+		return -1, target, errors.New("insufficient data or no " +
+			"feerate found")
+	}
+
+	return BtcPerKilobyte(feeRate * btcPerSatoshi), target, nil
+}
+
+// EstimateRawFee estimates the fee rate needed for a transaction to be
+// confirmed within the passed number of blocks with the given success rate
+// using the statistics of a single horizon.  It is primarily intended for
+// debugging.
+func (ef *FeeEstimator) EstimateRawFee(confTarget uint32, threshold float64,
+	horizon FeeEstimateHorizon) (*RawFeeEstimate, error) {
+
+	ef.mtx.Lock()
+	defer ef.mtx.Unlock()
+
+	stats := ef.smart.stats(horizon)
+	if confTarget == 0 || confTarget > stats.maxConfirms() {
+		return nil, fmt.Errorf("confirmation target must be between 1 "+
+			"and %d for the %v horizon", stats.maxConfirms(),
+			horizon)
+	}
+	if threshold < 0 || threshold > 1 {
+		return nil, errors.New("threshold must be between 0 and 1")
+	}
+
+	sufficientTxs := float64(sufficientFeeTxs)
+	if horizon == FeeHorizonShort {
+		sufficientTxs = sufficientTxsShort
+	}
+	median, pass, fail := stats.estimateMedianVal(confTarget,
+		sufficientTxs, threshold, ef.smart.bestSeenHeight)
+
+	estimate := &RawFeeEstimate{
+		Decay: stats.decay,
+		Scale: stats.scale,
+		Pass:  pass,
+		Fail:  fail,
+	}
+	if median >= 0 {
+		estimate.FeeRate = BtcPerKilobyte(median * btcPerSatoshi)
+	}
+
+	return estimate, nil
+}
+
+// HighestTargetTracked returns the highest confirmation target that can be
+// estimated using the passed horizon.
+func (ef *FeeEstimator) HighestTargetTracked(horizon FeeEstimateHorizon) uint32 {
+	ef.mtx.RLock()
+	defer ef.mtx.RUnlock()
+
+	return ef.smart.stats(horizon).maxConfirms()
+}
+
+// RemoveTransaction is called when a transaction is removed from the mempool
+// for any reason other than being included in a block.  The transaction is
+// then accounted as having failed to confirm.
+func (ef *FeeEstimator) RemoveTransaction(hash *chainhash.Hash) {
+	ef.mtx.Lock()
+	ef.smart.removeTransaction(*hash, false)
+	ef.mtx.Unlock()
+}
@@ -0,0 +1,297 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// TRUCVersion is the transaction version used to opt in to the
+	// Topologically Restricted Until Confirmation (TRUC) policy defined in
+	// BIP 431.
+	TRUCVersion = 3
+
+	// MaxTRUCVirtualSize is the maximum virtual size of a TRUC transaction.
+	MaxTRUCVirtualSize = 10000
+
+	// MaxTRUCChildVirtualSize is the maximum virtual size of a TRUC
+	// transaction that spends an unconfirmed TRUC parent.
+	MaxTRUCChildVirtualSize = 1000
+)
+
+// isTRUC returns whether the passed transaction opts in to the TRUC policy.
+func isTRUC(tx *btcutil.Tx) bool {
+	return tx.MsgTx().Version == TRUCVersion
+}
+
+// checkTRUCPolicy ensures the passed transaction adheres to the TRUC topology
+// restrictions with respect to the unconfirmed transactions within the memory
+// pool:
+//
+//   - TRUC transactions may only spend unconfirmed TRUC transactions and
+//     unconfirmed TRUC transactions may only be spent by TRUC transactions
+//   - A TRUC transaction may have at most one unconfirmed ancestor and one
+//     unconfirmed descendant
+//   - A TRUC transaction may not exceed MaxTRUCVirtualSize, or
+//     MaxTRUCChildVirtualSize if it has an unconfirmed parent
+//
+// A new child of a TRUC parent that already has a different child in the
+// memory pool is not rejected outright.  Instead, the existing child is
+// returned as a sibling which the new transaction must replace according to
+// the usual replacement fee rules in order to be accepted.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) checkTRUCPolicy(tx *btcutil.Tx,
+	txSize int64) (*btcutil.Tx, error) {
+
+	// Gather the unconfirmed parents of the transaction along with the
+	// transactions it directly conflicts with.
+	parents := make(map[chainhash.Hash]*TxDesc)
+	directConflicts := make(map[chainhash.Hash]struct{})
+	for _, txIn := range tx.MsgTx().TxIn {
+		prevHash := txIn.PreviousOutPoint.Hash
+		if parent, ok := mp.pool[prevHash]; ok {
+			parents[prevHash] = parent
+		}
+		if conflict, ok := mp.outpoints[txIn.PreviousOutPoint]; ok {
+			directConflicts[*conflict.Hash()] = struct{}{}
+		}
+	}
+
+	// Non-TRUC transactions are not restricted other than not being
+	// allowed to spend unconfirmed TRUC transactions.
+	if !isTRUC(tx) {
+		for parentHash, parent := range parents {
+			if !isTRUC(parent.Tx) {
+				continue
+			}
+			str := fmt.Sprintf("non-TRUC transaction %v spends "+
+				"unconfirmed TRUC transaction %v", tx.Hash(),
+				parentHash)
+			return nil, txRuleError(wire.RejectNonstandard, str)
+		}
+
+		return nil, nil
+	}
+
+	if txSize > MaxTRUCVirtualSize {
+		str := fmt.Sprintf("TRUC transaction %v has a virtual size of "+
+			"%d which is larger than the max allowed size of %d",
+			tx.Hash(), txSize, MaxTRUCVirtualSize)
+		return nil, txRuleError(wire.RejectNonstandard, str)
+	}
+
+	for parentHash, parent := range parents {
+		if isTRUC(parent.Tx) {
+			continue
+		}
+		str := fmt.Sprintf("TRUC transaction %v spends unconfirmed "+
+			"non-TRUC transaction %v", tx.Hash(), parentHash)
+		return nil, txRuleError(wire.RejectNonstandard, str)
+	}
+
+	// There's nothing left to check if the transaction doesn't have any
+	// unconfirmed parents.
+	if len(parents) == 0 {
+		return nil, nil
+	}
+
+	// The transaction may only have a single unconfirmed ancestor, so its
+	// parent must not have any unconfirmed parents of its own.
+	var parent *TxDesc
+	for _, txD := range parents {
+		parent = txD
+	}
+	if len(parents) > 1 || len(mp.txAncestors(parent.Tx, nil)) > 0 {
+		str := fmt.Sprintf("TRUC transaction %v would have too many "+
+			"unconfirmed ancestors", tx.Hash())
+		return nil, txRuleError(wire.RejectNonstandard, str)
+	}
+
+	if txSize > MaxTRUCChildVirtualSize {
+		str := fmt.Sprintf("TRUC child transaction %v has a virtual "+
+			"size of %d which is larger than the max allowed size "+
+			"of %d", tx.Hash(), txSize, MaxTRUCChildVirtualSize)
+		return nil, txRuleError(wire.RejectNonstandard, str)
+	}
+
+	// Finally, the parent may only have a single child.  Any existing child
+	// the transaction directly conflicts with is already being replaced,
+	// otherwise it's a sibling that must be evicted in order to accept the
+	// transaction.
+	var sibling *btcutil.Tx
+	prevOut := wire.OutPoint{Hash: *parent.Tx.Hash()}
+	for i := range parent.Tx.MsgTx().TxOut {
+		prevOut.Index = uint32(i)
+		child, ok := mp.outpoints[prevOut]
+		if !ok {
+			continue
+		}
+		if _, ok := directConflicts[*child.Hash()]; ok {
+			continue
+		}
+		if sibling != nil && *sibling.Hash() != *child.Hash() {
+			str := fmt.Sprintf("TRUC transaction %v would exceed "+
+				"the descendant limit of parent %v",
+				tx.Hash(), parent.Tx.Hash())
+			return nil, txRuleError(wire.RejectNonstandard, str)
+		}
+		sibling = child
+	}
+
+	return sibling, nil
+}
+
+// checkEphemeralDust ensures a TRUC transaction creating ephemeral dust pays
+// no fee, so that there's no incentive to mine it without the child that
+// spends the dust.  Since such a transaction doesn't pay the minimum relay fee
+// on its own, it is only accepted along with an orphan child which pays for
+// it.  See findPackageSponsor for more details.
+func checkEphemeralDust(tx *btcutil.Tx, txFee, modifiedFee int64,
+	minRelayTxFee btcutil.Amount) error {
+
+	if !isTRUC(tx) || (txFee == 0 && modifiedFee == 0) {
+		return nil
+	}
+	if len(dustOutputs(tx, minRelayTxFee)) == 0 {
+		return nil
+	}
+
+	str := fmt.Sprintf("transaction %v has a dust output but pays a "+
+		"non-zero fee", tx.Hash())
+	return txRuleError(wire.RejectDust, str)
+}
+
+// checkEphemeralSpends ensures the passed transaction spends all of the
+// ephemeral dust outputs of its unconfirmed TRUC parents.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) checkEphemeralSpends(tx *btcutil.Tx) error {
+	spent := make(map[wire.OutPoint]struct{}, len(tx.MsgTx().TxIn))
+	for _, txIn := range tx.MsgTx().TxIn {
+		spent[txIn.PreviousOutPoint] = struct{}{}
+	}
+
+	for _, txIn := range tx.MsgTx().TxIn {
+		parent, ok := mp.pool[txIn.PreviousOutPoint.Hash]
+		if !ok || !isTRUC(parent.Tx) {
+			continue
+		}
+
+		prevOut := wire.OutPoint{Hash: *parent.Tx.Hash()}
+		dust := dustOutputs(parent.Tx, mp.cfg.Policy.MinRelayTxFee)
+		for _, index := range dust {
+			prevOut.Index = index
+			if _, ok := spent[prevOut]; ok {
+				continue
+			}
+			str := fmt.Sprintf("transaction %v does not spend "+
+				"ephemeral dust output %v of its parent",
+				tx.Hash(), prevOut)
+			return txRuleError(wire.RejectNonstandard, str)
+		}
+	}
+
+	return nil
+}
+
+// packageSponsor is an orphan TRUC transaction which pays for its parent when
+// the two of them are accepted as a one-parent-one-child (1p1c) package.
+type packageSponsor struct {
+	tx   *btcutil.Tx
+	fee  int64
+	size int64
+}
+
+// findPackageSponsor returns the orphan which spends the passed TRUC
+// transaction and pays the highest fee for its size, if any, so the two of
+// them can be evaluated as a 1p1c package by the combined fee rate.  Only
+// orphans whose sole missing parent is the passed transaction are considered.
+//
+// Since the parent is accepted before its child and removed again should the
+// child be rejected, only parents that neither spend nor conflict with other
+// transactions in the pool are sponsored, so that nothing else in the pool is
+// affected by accepting the parent.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) findPackageSponsor(parent *btcutil.Tx) *packageSponsor {
+	if !isTRUC(parent) {
+		return nil
+	}
+	for _, txIn := range parent.MsgTx().TxIn {
+		if _, ok := mp.pool[txIn.PreviousOutPoint.Hash]; ok {
+			return nil
+		}
+		if _, ok := mp.outpoints[txIn.PreviousOutPoint]; ok {
+			return nil
+		}
+	}
+
+	var sponsor *packageSponsor
+	prevOut := wire.OutPoint{Hash: *parent.Hash()}
+	for i := range parent.MsgTx().TxOut {
+		prevOut.Index = uint32(i)
+		for _, orphan := range mp.orphansByPrev[prevOut] {
+			if !isTRUC(orphan) {
+				continue
+			}
+			fee, ok := mp.orphanPackageFee(orphan, parent)
+			if !ok {
+				continue
+			}
+			size := GetTxVirtualSize(orphan)
+			if sponsor != nil && fee*sponsor.size <= sponsor.fee*size {
+				continue
+			}
+			sponsor = &packageSponsor{tx: orphan, fee: fee, size: size}
+		}
+	}
+
+	return sponsor
+}
+
+// orphanPackageFee returns the fee, including any fee delta, the passed orphan
+// pays when it is accepted along with the passed parent.  False is returned
+// when any input of the orphan other than those spending the parent is not
+// available.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) orphanPackageFee(orphan, parent *btcutil.Tx) (int64, bool) {
+	utxoView, err := mp.fetchInputUtxos(orphan)
+	if err != nil {
+		return 0, false
+	}
+
+	var fee int64
+	parentOuts := parent.MsgTx().TxOut
+	for _, txIn := range orphan.MsgTx().TxIn {
+		prevOut := txIn.PreviousOutPoint
+		if prevOut.Hash == *parent.Hash() {
+			if prevOut.Index >= uint32(len(parentOuts)) {
+				return 0, false
+			}
+			fee += parentOuts[prevOut.Index].Value
+			continue
+		}
+		entry := utxoView.LookupEntry(prevOut)
+		if entry == nil || entry.IsSpent() {
+			return 0, false
+		}
+		fee += entry.Amount()
+	}
+	for _, txOut := range orphan.MsgTx().TxOut {
+		fee -= txOut.Value
+	}
+	if fee < 0 {
+		return 0, false
+	}
+
+	return fee + mp.feeDeltas[*orphan.Hash()], true
+}
@@ -7,9 +7,11 @@ package cpuminer
 import (
 	"errors"
 	"fmt"
+	"math/big"
 	"math/rand"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/btcsuite/btcd/blockchain"
@@ -38,13 +40,47 @@ const (
 	// reduce the amount of syncs between the workers that must be done to
 	// keep track of the hashes per second.
 	hashUpdateSecs = 15
+
+	// hashBatchBits is the base-2 logarithm of hashBatchSize.
+	hashBatchBits = 16
+
+	// hashBatchSize is the number of nonces each worker searches in between
+	// checks for a request to stop, such as when another worker found a
+	// solution, and updates to the block timestamp.
+	hashBatchSize = 1 << hashBatchBits
+
+	// extraNonceWorkerShift is the number of bits the index of a worker is
+	// shifted by to obtain the start of its extra nonce space.  This
+	// partitions the extra nonce space among the workers solving a block
+	// so no two workers ever hash the same header.
+	extraNonceWorkerShift = 48
+
+	// blockTimeUpdateInterval is the interval at which each worker updates
+	// the timestamp of the block it is solving.
+	blockTimeUpdateInterval = time.Second
 )
 
 var (
+	// errStaleBlock is returned when a solved block no longer extends the
+	// best chain.
+	errStaleBlock = errors.New("block is stale")
+
+	// errOrphanBlock is returned when a solved block was accepted as an
+	// orphan.
+	errOrphanBlock = errors.New("block is an orphan")
+
 	// defaultNumWorkers is the default number of workers to use for mining
 	// and is based on the number of processor cores.  This helps ensure the
 	// system stays reasonably responsive under heavy load.
 	defaultNumWorkers = uint32(runtime.NumCPU())
+
+	// fastSolveTarget is the lowest target difficulty for which a block
+	// is expected to be solved within a single batch of hashes, such as
+	// the min-difficulty blocks of the regression test and simulation test
+	// networks.  Blocks with such a target are solved directly by the
+	// caller instead of by a set of workers since starting the workers
+	// would take longer than solving the block.
+	fastSolveTarget = new(big.Int).Lsh(big.NewInt(1), 256-hashBatchBits)
 )
 
 // Config is a descriptor containing the cpu miner configuration.
@@ -58,7 +94,9 @@ type Config struct {
 	BlockTemplateGenerator *mining.BlkTmplGenerator
 
 	// MiningAddrs is a list of payment addresses to use for the generated
-	// blocks.  Each generated block will randomly choose one of them.
+	// blocks.  Each generated block will randomly choose one of them.  When
+	// it is empty, the coinbase payouts of the block template generator
+	// policy are used instead.
 	MiningAddrs []btcutil.Address
 
 	// ProcessBlock defines the function to call with any solved blocks.
@@ -150,12 +188,12 @@ out:
 	log.Tracef("CPU miner speed monitor done")
 }
 
-// submitBlock submits the passed block to network after ensuring it passes all
-// of the consensus validation rules.
-func (m *CPUMiner) submitBlock(block *btcutil.Block) bool {
-	m.submitBlockLock.Lock()
-	defer m.submitBlockLock.Unlock()
-
+// processBlock submits the passed block to network after ensuring it passes
+// all of the consensus validation rules.  errStaleBlock is returned when the
+// block no longer extends the best chain.
+//
+// This function MUST be called with the submit block lock held.
+func (m *CPUMiner) processBlock(block *btcutil.Block) error {
 	// Ensure the block is not stale since a new block could have shown up
 	// while the solution was being found.  Typically that condition is
 	// detected and all work on the stale block is halted to start work on
@@ -163,15 +201,50 @@ func (m *CPUMiner) submitBlock(block *btcutil.Block) bool {
 	// possible a block was found and submitted in between.
 	msgBlock := block.MsgBlock()
 	if !msgBlock.Header.PrevBlock.IsEqual(&m.g.BestSnapshot().Hash) {
-		log.Debugf("Block submitted via CPU miner with previous "+
-			"block %s is stale", msgBlock.Header.PrevBlock)
-		return false
+		return errStaleBlock
 	}
 
 	// Process this block using the same rules as blocks coming from other
 	// nodes.  This will in turn relay it to the network like normal.
 	isOrphan, err := m.cfg.ProcessBlock(block, blockchain.BFNone)
 	if err != nil {
+		return err
+	}
+	if isOrphan {
+		return errOrphanBlock
+	}
+	return nil
+}
+
+// randomMiningAddr returns one of the configured mining addresses chosen at
+// random or nil when there are none, in which case the coinbase payouts of the
+// block template generator policy apply.
+func (m *CPUMiner) randomMiningAddr() btcutil.Address {
+	if len(m.cfg.MiningAddrs) == 0 {
+		return nil
+	}
+	rand.Seed(time.Now().UnixNano())
+	return m.cfg.MiningAddrs[rand.Intn(len(m.cfg.MiningAddrs))]
+}
+
+// submitBlock submits the passed block to network after ensuring it passes all
+// of the consensus validation rules.
+func (m *CPUMiner) submitBlock(block *btcutil.Block) bool {
+	m.submitBlockLock.Lock()
+	defer m.submitBlockLock.Unlock()
+
+	err := m.processBlock(block)
+	switch {
+	case err == errStaleBlock:
+		log.Debugf("Block submitted via CPU miner with previous "+
+			"block %s is stale", block.MsgBlock().Header.PrevBlock)
+		return false
+
+	case err == errOrphanBlock:
+		log.Debugf("Block submitted via CPU miner is an orphan")
+		return false
+
+	case err != nil:
 		// Anything other than a rule violation is an unexpected error,
 		// so log that error as an internal error.
 		if _, ok := err.(blockchain.RuleError); !ok {
@@ -183,10 +256,6 @@ func (m *CPUMiner) submitBlock(block *btcutil.Block) bool {
 		log.Debugf("Block submitted via CPU miner rejected: %v", err)
 		return false
 	}
-	if isOrphan {
-		log.Debugf("Block submitted via CPU miner is an orphan")
-		return false
-	}
 
 	// The block was accepted.
 	coinbaseTx := block.MsgBlock().Transactions[0].TxOut[0]
@@ -195,20 +264,111 @@ func (m *CPUMiner) submitBlock(block *btcutil.Block) bool {
 	return true
 }
 
+// solveWork attempts to find some combination of a nonce, extra nonce, and
+// current timestamp which makes the passed block hash to a value less than the
+// target difficulty.  The search starts with the extra nonce the coinbase of
+// the block was last updated with, which must be the passed one, and then
+// moves on to subsequent extra nonces.  The timestamp is updated periodically
+// and the passed block is modified with all tweaks during this process.  This
+// means that when the function returns true, the block is ready for
+// submission.
+//
+// The number of hashes performed is periodically added to the passed counter.
+// The function returns false when the passed stop channel is closed.
+func (m *CPUMiner) solveWork(msgBlock *wire.MsgBlock, blockHeight int32,
+	extraNonce uint64, stop <-chan struct{}, hashesCompleted *uint64) bool {
+
+	header := &msgBlock.Header
+	for {
+		// The header hasher caches the state of the hash over the part
+		// of the header which only changes along with the extra nonce.
+		hasher := newHeaderHasher(header)
+		target := targetHash(header.Bits)
+		lastTimeUpdate := time.Now()
+
+		// Search through the entire nonce range in batches while
+		// periodically checking for early quit and updating the block
+		// timestamp.
+		for start := uint64(0); start <= uint64(maxNonce); start += hashBatchSize {
+			select {
+			case <-stop:
+				return false
+			default:
+				// Non-blocking select to fall through
+			}
+
+			if time.Since(lastTimeUpdate) >= blockTimeUpdateInterval {
+				if err := m.g.UpdateBlockTime(msgBlock); err != nil {
+					log.Errorf("Unable to update block time: %v",
+						err)
+					return false
+				}
+				hasher.setTimeAndBits(header.Timestamp, header.Bits)
+				target = targetHash(header.Bits)
+				lastTimeUpdate = time.Now()
+			}
+
+			// Each hash is actually a double sha256 (two hashes),
+			// so increment the number of hashes completed for each
+			// attempt accordingly.
+			end := uint32(start + hashBatchSize - 1)
+			nonce, numHashes, solved := hasher.search(uint32(start),
+				end, &target)
+			atomic.AddUint64(hashesCompleted, 2*numHashes)
+
+			// The block is solved when the new block hash is less
+			// than the target difficulty.  Yay!
+			if solved {
+				header.Nonce = nonce
+				return true
+			}
+		}
+
+		// Move on to the next extra nonce by regenerating the coinbase
+		// script and setting the merkle root to the new value.  Note
+		// that the extra nonce wraps around 0 on overflow as provided
+		// by the Go spec.
+		extraNonce++
+		err := m.g.UpdateExtraNonce(msgBlock, blockHeight, extraNonce)
+		if err != nil {
+			log.Errorf("Unable to update extra nonce: %v", err)
+			return false
+		}
+	}
+}
+
 // solveBlock attempts to find some combination of a nonce, extra nonce, and
 // current timestamp which makes the passed block hash to a value less than the
-// target difficulty.  The timestamp is updated periodically and the passed
-// block is modified with all tweaks during this process.  This means that
-// when the function returns true, the block is ready for submission.
+// target difficulty using the passed number of workers.  Each worker searches
+// its own copy of the block in its own partition of the extra nonce space.
+// When a worker finds a solution, the passed block is updated with it, which
+// means that when the function returns true, the block is ready for
+// submission.
+//
+// Blocks which are expected to be solved with a handful of hashes, such as
+// min-difficulty blocks on the regression test network, are solved directly
+// without any workers to keep the overhead to a minimum.
 //
 // This function will return early with false when conditions that trigger a
 // stale block such as a new block showing up or periodically when there are
 // new transactions and enough time has elapsed without finding a solution.
 func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight int32,
-	ticker *time.Ticker, quit chan struct{}) bool {
+	numWorkers uint32, ticker *time.Ticker, quit chan struct{}) bool {
 
-	// Choose a random extra nonce offset for this block template and
-	// worker.
+	// Create some convenience variables.
+	header := &msgBlock.Header
+	var hashesCompleted uint64
+
+	// Solve easy blocks directly starting with the extra nonce of the
+	// block template.
+	if blockchain.CompactToBig(header.Bits).Cmp(fastSolveTarget) >= 0 {
+		solved := m.solveWork(msgBlock, blockHeight, 0, quit,
+			&hashesCompleted)
+		m.updateHashes <- hashesCompleted
+		return solved
+	}
+
+	// Choose a random extra nonce offset for this block template.
 	enOffset, err := wire.RandomUint64()
 	if err != nil {
 		log.Errorf("Unexpected error while generating random "+
@@ -216,87 +376,94 @@ func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight int32,
 		enOffset = 0
 	}
 
-	// Create some convenience variables.
-	header := &msgBlock.Header
-	targetDifficulty := blockchain.CompactToBig(header.Bits)
-
 	// Initial state.
 	lastGenerated := time.Now()
 	lastTxUpdate := m.g.TxSource().LastUpdated()
-	hashesCompleted := uint64(0)
-
-	// Note that the entire extra nonce range is iterated and the offset is
-	// added relying on the fact that overflow will wrap around 0 as
-	// provided by the Go spec.
-	for extraNonce := uint64(0); extraNonce < maxExtraNonce; extraNonce++ {
-		// Update the extra nonce in the block template with the
-		// new value by regenerating the coinbase script and
-		// setting the merkle root to the new value.
-		m.g.UpdateExtraNonce(msgBlock, blockHeight, extraNonce+enOffset)
-
-		// Search through the entire nonce range for a solution while
-		// periodically checking for early quit and stale block
-		// conditions along with updates to the speed monitor.
-		for i := uint32(0); i <= maxNonce; i++ {
-			select {
-			case <-quit:
-				return false
 
-			case <-ticker.C:
-				m.updateHashes <- hashesCompleted
-				hashesCompleted = 0
+	// Launch the workers, each of which solves its own copy of the block
+	// starting at its own partition of the extra nonce space.  The workers
+	// are stopped on return, whether a solution was found or not.
+	stop := make(chan struct{})
+	solutions := make(chan *wire.MsgBlock, numWorkers)
+	var wg sync.WaitGroup
+	defer func() {
+		close(stop)
+		wg.Wait()
+	}()
+	for i := uint32(0); i < numWorkers; i++ {
+		work := copyWork(msgBlock)
+		extraNonce := enOffset + uint64(i)<<extraNonceWorkerShift
+		err := m.g.UpdateExtraNonce(work, blockHeight, extraNonce)
+		if err != nil {
+			log.Errorf("Unable to update extra nonce: %v", err)
+			return false
+		}
 
-				// The current block is stale if the best block
-				// has changed.
-				best := m.g.BestSnapshot()
-				if !header.PrevBlock.IsEqual(&best.Hash) {
-					return false
-				}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if m.solveWork(work, blockHeight, extraNonce, stop,
+				&hashesCompleted) {
 
-				// The current block is stale if the memory pool
-				// has been updated since the block template was
-				// generated and it has been at least one
-				// minute.
-				if lastTxUpdate != m.g.TxSource().LastUpdated() &&
-					time.Now().After(lastGenerated.Add(time.Minute)) {
+				solutions <- work
+			}
+		}()
+	}
 
-					return false
-				}
+	for {
+		select {
+		case <-quit:
+			return false
 
-				m.g.UpdateBlockTime(msgBlock)
+		case work := <-solutions:
+			msgBlock.Header = work.Header
+			msgBlock.Transactions[0] = work.Transactions[0]
+			m.updateHashes <- atomic.SwapUint64(&hashesCompleted, 0)
+			return true
 
-			default:
-				// Non-blocking select to fall through
+		case <-ticker.C:
+			m.updateHashes <- atomic.SwapUint64(&hashesCompleted, 0)
+
+			// The current block is stale if the best block has
+			// changed.
+			best := m.g.BestSnapshot()
+			if !header.PrevBlock.IsEqual(&best.Hash) {
+				return false
 			}
 
-			// Update the nonce and hash the block header.  Each
-			// hash is actually a double sha256 (two hashes), so
-			// increment the number of hashes completed for each
-			// attempt accordingly.
-			header.Nonce = i
-			hash := header.BlockHash()
-			hashesCompleted += 2
+			// The current block is stale if the memory pool has
+			// been updated since the block template was generated
+			// and it has been at least one minute.
+			if lastTxUpdate != m.g.TxSource().LastUpdated() &&
+				time.Now().After(lastGenerated.Add(time.Minute)) {
 
-			// The block is solved when the new block hash is less
-			// than the target difficulty.  Yay!
-			if blockchain.HashToBig(&hash).Cmp(targetDifficulty) <= 0 {
-				m.updateHashes <- hashesCompleted
-				return true
+				return false
 			}
 		}
 	}
+}
 
-	return false
+// copyWork returns a copy of the passed block which a worker is able to modify
+// while solving it.  Only the header and coinbase transaction, which are
+// modified while solving, are copied.
+func copyWork(msgBlock *wire.MsgBlock) *wire.MsgBlock {
+	work := &wire.MsgBlock{
+		Header:       msgBlock.Header,
+		Transactions: make([]*wire.MsgTx, len(msgBlock.Transactions)),
+	}
+	copy(work.Transactions, msgBlock.Transactions)
+	work.Transactions[0] = msgBlock.Transactions[0].Copy()
+	return work
 }
 
-// generateBlocks is a worker that is controlled by the miningWorkerController.
-// It is self contained in that it creates block templates and attempts to solve
-// them while detecting when it is performing stale work and reacting
-// accordingly by generating a new block template.  When a block is solved, it
-// is submitted.
+// generateBlocks is controlled by the miningWorkerController.  It is self
+// contained in that it creates block templates and attempts to solve them with
+// the passed number of workers while detecting when it is performing stale
+// work and reacting accordingly by generating a new block template.  When a
+// block is solved, it is submitted.
 //
 // It must be run as a goroutine.
-func (m *CPUMiner) generateBlocks(quit chan struct{}) {
+func (m *CPUMiner) generateBlocks(numWorkers uint32, quit chan struct{}) {
 	log.Tracef("Starting generate blocks worker")
 
 	// Start a ticker which is used to signal checks for stale work and
@@ -335,8 +502,7 @@ out:
 		}
 
 		// Choose a payment address at random.
-		rand.Seed(time.Now().UnixNano())
-		payToAddr := m.cfg.MiningAddrs[rand.Intn(len(m.cfg.MiningAddrs))]
+		payToAddr := m.randomMiningAddr()
 
 		// Create a new block template using the available transactions
 		// in the memory pool as a source of transactions to potentially
@@ -354,7 +520,9 @@ out:
 		// with false when conditions that trigger a stale block, so
 		// a new block template can be generated.  When the return is
 		// true a solution was found, so submit the solved block.
-		if m.solveBlock(template.Block, curHeight+1, ticker, quit) {
+		if m.solveBlock(template.Block, curHeight+1, numWorkers, ticker,
+			quit) {
+
 			block := btcutil.NewBlock(template.Block)
 			m.submitBlock(block)
 		}
@@ -364,28 +532,37 @@ out:
 	log.Tracef("Generate blocks worker done")
 }
 
-// miningWorkerController launches the worker goroutines that are used to
-// generate block templates and solve them.  It also provides the ability to
-// dynamically adjust the number of running worker goroutines.
+// miningWorkerController launches the goroutine that is used to generate block
+// templates and solve them with the configured number of workers.  It also
+// provides the ability to dynamically adjust the number of workers by
+// relaunching that goroutine.
 //
 // It must be run as a goroutine.
 func (m *CPUMiner) miningWorkerController() {
-	// launchWorkers groups common code to launch a specified number of
-	// workers for generating blocks.
-	var runningWorkers []chan struct{}
-	launchWorkers := func(numWorkers uint32) {
-		for i := uint32(0); i < numWorkers; i++ {
-			quit := make(chan struct{})
-			runningWorkers = append(runningWorkers, quit)
-
-			m.workerWg.Add(1)
-			go m.generateBlocks(quit)
+	// launchGenerator groups common code to launch the goroutine which
+	// generates blocks with the current number of workers.
+	var generatorQuit chan struct{}
+	var numRunning uint32
+	launchGenerator := func() {
+		numRunning = m.numWorkers
+		if numRunning == 0 {
+			generatorQuit = nil
+			return
+		}
+
+		generatorQuit = make(chan struct{})
+		m.workerWg.Add(1)
+		go m.generateBlocks(numRunning, generatorQuit)
+	}
+	stopGenerator := func() {
+		if generatorQuit != nil {
+			close(generatorQuit)
 		}
+		m.workerWg.Wait()
 	}
 
 	// Launch the current number of workers by default.
-	runningWorkers = make([]chan struct{}, 0, m.numWorkers)
-	launchWorkers(m.numWorkers)
+	launchGenerator()
 
 out:
 	for {
@@ -393,35 +570,23 @@ out:
 		// Update the number of running workers.
 		case <-m.updateNumWorkers:
 			// No change.
-			numRunning := uint32(len(runningWorkers))
 			if m.numWorkers == numRunning {
 				continue
 			}
 
-			// Add new workers.
-			if m.numWorkers > numRunning {
-				launchWorkers(m.numWorkers - numRunning)
-				continue
-			}
-
-			// Signal the most recently created goroutines to exit.
-			for i := numRunning - 1; i >= m.numWorkers; i-- {
-				close(runningWorkers[i])
-				runningWorkers[i] = nil
-				runningWorkers = runningWorkers[:i]
-			}
+			// Relaunch the generator so it uses the new number of
+			// workers for the next block.
+			stopGenerator()
+			launchGenerator()
 
 		case <-m.quit:
-			for _, quit := range runningWorkers {
-				close(quit)
-			}
 			break out
 		}
 	}
 
-	// Wait until all workers shut down to stop the speed monitor since
-	// they rely on being able to send updates to it.
-	m.workerWg.Wait()
+	// Wait until the generator shuts down to stop the speed monitor since
+	// it relies on being able to send updates to it.
+	stopGenerator()
 	close(m.speedMonitorQuit)
 	m.wg.Done()
 }
@@ -539,18 +704,16 @@ func (m *CPUMiner) NumWorkers() int32 {
 	return int32(m.numWorkers)
 }
 
-// GenerateNBlocks generates the requested number of blocks. It is self
-// contained in that it creates block templates and attempts to solve them while
-// detecting when it is performing stale work and reacting accordingly by
-// generating a new block template.  When a block is solved, it is submitted.
-// The function returns a list of the hashes of generated blocks.
-func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
+// startDiscreteMining marks the miner as mining a discrete number of blocks
+// and starts the speed monitor.  An error is returned when the miner is
+// already mining.
+func (m *CPUMiner) startDiscreteMining() error {
 	m.Lock()
+	defer m.Unlock()
 
 	// Respond with an error if server is already mining.
 	if m.started || m.discreteMining {
-		m.Unlock()
-		return nil, errors.New("Server is already CPU mining. Please call " +
+		return errors.New("Server is already CPU mining. Please call " +
 			"`setgenerate 0` before calling discrete `generate` commands.")
 	}
 
@@ -560,11 +723,73 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 	m.speedMonitorQuit = make(chan struct{})
 	m.wg.Add(1)
 	go m.speedMonitor()
+	return nil
+}
+
+// numSolveWorkers returns the number of workers to solve a discrete number of
+// blocks with, which is the configured number of workers, but at least one.
+func (m *CPUMiner) numSolveWorkers() uint32 {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.numWorkers == 0 {
+		return 1
+	}
+	return m.numWorkers
+}
 
+// stopDiscreteMining stops the speed monitor and marks the miner as no longer
+// mining once a discrete number of blocks has been generated.
+func (m *CPUMiner) stopDiscreteMining() {
+	m.Lock()
+	close(m.speedMonitorQuit)
+	m.wg.Wait()
+	m.started = false
+	m.discreteMining = false
 	m.Unlock()
+}
+
+// GenerateNBlocks generates the requested number of blocks. It is self
+// contained in that it creates block templates and attempts to solve them while
+// detecting when it is performing stale work and reacting accordingly by
+// generating a new block template.  When a block is solved, it is submitted.
+// The function returns a list of the hashes of generated blocks.
+func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
+	return m.generateNBlocks(n, nil)
+}
+
+// GenerateNBlocksToAddress generates the requested number of blocks paying to
+// the passed address instead of the configured mining addresses.  See
+// GenerateNBlocks for more details.
+func (m *CPUMiner) GenerateNBlocksToAddress(n uint32,
+	payToAddr btcutil.Address) ([]*chainhash.Hash, error) {
+
+	if payToAddr == nil {
+		return nil, errors.New("no payment address specified")
+	}
+	return m.generateNBlocks(n, payToAddr)
+}
+
+// generateNBlocks generates the requested number of blocks paying to the
+// passed address or to a random one of the configured mining addresses when it
+// is nil.
+func (m *CPUMiner) generateNBlocks(n uint32,
+	payToAddr btcutil.Address) ([]*chainhash.Hash, error) {
+
+	if payToAddr == nil && len(m.cfg.MiningAddrs) == 0 &&
+		len(m.g.Policy().CoinbasePayouts) == 0 {
+
+		return nil, errors.New("no mining addresses configured")
+	}
+	if err := m.startDiscreteMining(); err != nil {
+		return nil, err
+	}
+	defer m.stopDiscreteMining()
 
 	log.Tracef("Generating %d blocks", n)
 
+	numWorkers := m.numSolveWorkers()
+
 	i := uint32(0)
 	blockHashes := make([]*chainhash.Hash, n)
 
@@ -573,10 +798,10 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 	ticker := time.NewTicker(time.Second * hashUpdateSecs)
 	defer ticker.Stop()
 
-	for {
+	for i < n {
 		// Read updateNumWorkers in case someone tries a `setgenerate` while
-		// we're generating. We can ignore it as the `generate` RPC call only
-		// uses 1 worker.
+		// we're generating. We can ignore it as the number of workers is
+		// only read once the blocks are solved.
 		select {
 		case <-m.updateNumWorkers:
 		default:
@@ -588,14 +813,17 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 		m.submitBlockLock.Lock()
 		curHeight := m.g.BestSnapshot().Height
 
-		// Choose a payment address at random.
-		rand.Seed(time.Now().UnixNano())
-		payToAddr := m.cfg.MiningAddrs[rand.Intn(len(m.cfg.MiningAddrs))]
+		// Choose a payment address at random when one was not
+		// specified.
+		addr := payToAddr
+		if addr == nil {
+			addr = m.randomMiningAddr()
+		}
 
 		// Create a new block template using the available transactions
 		// in the memory pool as a source of transactions to potentially
 		// include in the block.
-		template, err := m.g.NewBlockTemplate(payToAddr)
+		template, err := m.g.NewBlockTemplate(addr)
 		m.submitBlockLock.Unlock()
 		if err != nil {
 			errStr := fmt.Sprintf("Failed to create new block "+
@@ -608,23 +836,80 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 		// with false when conditions that trigger a stale block, so
 		// a new block template can be generated.  When the return is
 		// true a solution was found, so submit the solved block.
-		if m.solveBlock(template.Block, curHeight+1, ticker, nil) {
+		if m.solveBlock(template.Block, curHeight+1, numWorkers,
+			ticker, nil) {
+
 			block := btcutil.NewBlock(template.Block)
 			m.submitBlock(block)
 			blockHashes[i] = block.Hash()
 			i++
-			if i == n {
-				log.Tracef("Generated %d blocks", i)
-				m.Lock()
-				close(m.speedMonitorQuit)
-				m.wg.Wait()
-				m.started = false
-				m.discreteMining = false
-				m.Unlock()
-				return blockHashes, nil
-			}
 		}
 	}
+
+	log.Tracef("Generated %d blocks", i)
+	return blockHashes, nil
+}
+
+// GenerateBlock generates a single block which contains exactly the passed
+// transactions in the passed order and pays to the passed address.  Unlike
+// GenerateNBlocks, the transactions need not be in the memory pool.  The solved
+// block is only submitted when submit is set.  An error is returned when the
+// transactions do not form a valid block or a submitted block is rejected.
+//
+// See mining.BlkTmplGenerator.NewBlockTemplateWithTxs for more details.
+func (m *CPUMiner) GenerateBlock(payToAddr btcutil.Address, txns []*btcutil.Tx,
+	submit bool) (*btcutil.Block, error) {
+
+	if err := m.startDiscreteMining(); err != nil {
+		return nil, err
+	}
+	defer m.stopDiscreteMining()
+
+	numWorkers := m.numSolveWorkers()
+	ticker := time.NewTicker(time.Second * hashUpdateSecs)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.updateNumWorkers:
+		default:
+		}
+
+		m.submitBlockLock.Lock()
+		curHeight := m.g.BestSnapshot().Height
+		template, err := m.g.NewBlockTemplateWithTxs(payToAddr, txns)
+		m.submitBlockLock.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		// Create a new template when the block becomes stale before it
+		// is solved.
+		if !m.solveBlock(template.Block, curHeight+1, numWorkers,
+			ticker, nil) {
+
+			continue
+		}
+		block := btcutil.NewBlock(template.Block)
+		block.SetHeight(curHeight + 1)
+		if !submit {
+			return block, nil
+		}
+
+		m.submitBlockLock.Lock()
+		err = m.processBlock(block)
+		m.submitBlockLock.Unlock()
+		switch {
+		case err == errStaleBlock:
+			continue
+		case err != nil:
+			return nil, err
+		}
+
+		log.Infof("Block generated via CPU miner accepted (hash %s, "+
+			"%d transactions)", block.Hash(), len(txns))
+		return block, nil
+	}
 }
 
 // New returns a new instance of a CPU miner for the provided configuration.
@@ -0,0 +1,135 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cpuminer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding"
+	"encoding/binary"
+	"hash"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// midstateLen is the number of bytes at the start of a serialized block
+	// header which make up the first SHA-256 block.  They consist of the
+	// version, previous block hash and the start of the merkle root, which
+	// do not change while searching the nonce range.
+	midstateLen = 64
+
+	// Offsets of the fields in the tail of a serialized block header which
+	// follows the first SHA-256 block.
+	tailTimestampOffset = 4
+	tailBitsOffset      = 8
+	tailNonceOffset     = 12
+)
+
+// sha256State is the interface implemented by the SHA-256 digest of the
+// standard library which allows its state to be saved and restored.
+type sha256State interface {
+	hash.Hash
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// headerHasher hashes a block header with different nonces.  Since the nonce
+// is at the end of the header, the state of the first SHA-256 pass after the
+// first block of the header, known as the midstate, is the same for all
+// nonces, so it is only computed once and restored for each hash.
+type headerHasher struct {
+	digest   sha256State
+	midstate []byte
+	tail     [wire.MaxBlockHeaderPayload - midstateLen]byte
+	first    [sha256.Size]byte
+}
+
+// newHeaderHasher returns a new header hasher for the passed block header.
+func newHeaderHasher(header *wire.BlockHeader) *headerHasher {
+	var buf bytes.Buffer
+	buf.Grow(wire.MaxBlockHeaderPayload)
+	_ = header.Serialize(&buf)
+	serialized := buf.Bytes()
+
+	h := &headerHasher{digest: sha256.New().(sha256State)}
+	h.digest.Write(serialized[:midstateLen])
+	h.midstate, _ = h.digest.MarshalBinary()
+	copy(h.tail[:], serialized[midstateLen:])
+	return h
+}
+
+// setTimeAndBits updates the timestamp and difficulty bits of the header
+// which is hashed.  Both are part of the tail of the header, so the midstate
+// remains valid.
+func (h *headerHasher) setTimeAndBits(timestamp time.Time, bits uint32) {
+	binary.LittleEndian.PutUint32(h.tail[tailTimestampOffset:],
+		uint32(timestamp.Unix()))
+	binary.LittleEndian.PutUint32(h.tail[tailBitsOffset:], bits)
+}
+
+// hash returns the block hash of the header with the passed nonce.
+func (h *headerHasher) hash(nonce uint32) chainhash.Hash {
+	binary.LittleEndian.PutUint32(h.tail[tailNonceOffset:], nonce)
+	_ = h.digest.UnmarshalBinary(h.midstate)
+	h.digest.Write(h.tail[:])
+	return sha256.Sum256(h.digest.Sum(h.first[:0]))
+}
+
+// search hashes the header with each nonce from start through end, inclusive,
+// until the hash meets the passed target.  It returns the solving nonce, if
+// any, the number of nonces which were tried and whether or not a solution was
+// found.
+func (h *headerHasher) search(start, end uint32,
+	target *chainhash.Hash) (uint32, uint64, bool) {
+
+	var numHashes uint64
+	for nonce := start; ; nonce++ {
+		hash := h.hash(nonce)
+		numHashes++
+		if hashMeetsTarget(&hash, target) {
+			return nonce, numHashes, true
+		}
+		if nonce == end {
+			return 0, numHashes, false
+		}
+	}
+}
+
+// targetHash returns the target difficulty represented by the passed compact
+// bits as a hash so block hashes can be compared against it without first
+// converting them to big integers.
+func targetHash(bits uint32) chainhash.Hash {
+	var target chainhash.Hash
+	b := blockchain.CompactToBig(bits).Bytes()
+	if len(b) > chainhash.HashSize {
+		for i := range target {
+			target[i] = 0xff
+		}
+		return target
+	}
+
+	// Hashes are little endian while the bytes of big integers are big
+	// endian.
+	for i, v := range b {
+		target[len(b)-1-i] = v
+	}
+	return target
+}
+
+// hashMeetsTarget returns whether or not the passed block hash is less than or
+// equal to the passed target, both of which are treated as little-endian
+// 256-bit integers.
+func hashMeetsTarget(hash, target *chainhash.Hash) bool {
+	for i := chainhash.HashSize - 1; i >= 0; i-- {
+		if hash[i] != target[i] {
+			return hash[i] < target[i]
+		}
+	}
+	return true
+}
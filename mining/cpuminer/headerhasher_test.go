@@ -0,0 +1,124 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cpuminer
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testHeader returns a block header with random contents for use in the tests.
+func testHeader(rng *rand.Rand) wire.BlockHeader {
+	var header wire.BlockHeader
+	header.Version = rng.Int31()
+	rng.Read(header.PrevBlock[:])
+	rng.Read(header.MerkleRoot[:])
+	header.Timestamp = time.Unix(rng.Int63n(1<<32), 0)
+	header.Bits = chaincfg.MainNetParams.PowLimitBits
+	return header
+}
+
+// TestHeaderHasher ensures the header hasher produces the same hashes as the
+// block header for different nonces, timestamps and difficulty bits.
+func TestHeaderHasher(t *testing.T) {
+	t.Parallel()
+
+	rng := rand.New(rand.NewSource(1))
+	header := testHeader(rng)
+	hasher := newHeaderHasher(&header)
+	for i := 0; i < 100; i++ {
+		if i%10 == 0 {
+			header.Timestamp = time.Unix(rng.Int63n(1<<32), 0)
+			header.Bits = rng.Uint32()
+			hasher.setTimeAndBits(header.Timestamp, header.Bits)
+		}
+		header.Nonce = rng.Uint32()
+		got, want := hasher.hash(header.Nonce), header.BlockHash()
+		if got != want {
+			t.Fatalf("hash %d: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+// TestHashMeetsTarget ensures comparing hashes against targets as hashes
+// matches comparing them as big integers.
+func TestHashMeetsTarget(t *testing.T) {
+	t.Parallel()
+
+	rng := rand.New(rand.NewSource(1))
+	params := []*chaincfg.Params{
+		&chaincfg.MainNetParams,
+		&chaincfg.RegressionNetParams,
+		&chaincfg.SimNetParams,
+	}
+	for i := 0; i < 1000; i++ {
+		bits := params[i%len(params)].PowLimitBits
+		target := targetHash(bits)
+
+		// Use hashes which share a prefix with the target as well as
+		// hashes close to the target in order to exercise ties.
+		var hash chainhash.Hash
+		switch i % 3 {
+		case 0:
+			rng.Read(hash[:])
+		case 1:
+			hash = target
+		case 2:
+			hash = target
+			hash[rng.Intn(chainhash.HashSize)] ^= byte(rng.Intn(256))
+		}
+
+		want := blockchain.HashToBig(&hash).Cmp(
+			blockchain.CompactToBig(bits)) <= 0
+		if got := hashMeetsTarget(&hash, &target); got != want {
+			t.Fatalf("hash %v target %v: got %v, want %v", hash,
+				target, got, want)
+		}
+	}
+}
+
+// BenchmarkBlockHash benchmarks searching a nonce range by hashing the whole
+// block header for each nonce and comparing the result with the target as a
+// big integer.
+func BenchmarkBlockHash(b *testing.B) {
+	header := testHeader(rand.New(rand.NewSource(1)))
+	target := blockchain.CompactToBig(header.Bits)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		header.Nonce = uint32(i)
+		hash := header.BlockHash()
+		if blockchain.HashToBig(&hash).Cmp(target) <= 0 {
+			b.Fatalf("unexpected solution")
+		}
+	}
+}
+
+// BenchmarkHeaderHasher benchmarks searching a nonce range in batches with the
+// header hasher, which caches the midstate and compares the hashes with the
+// target directly.
+func BenchmarkHeaderHasher(b *testing.B) {
+	header := testHeader(rand.New(rand.NewSource(1)))
+	hasher := newHeaderHasher(&header)
+	target := targetHash(header.Bits)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += hashBatchSize {
+		end := i + hashBatchSize - 1
+		if end >= b.N {
+			end = b.N - 1
+		}
+		_, _, solved := hasher.search(uint32(i), uint32(end), &target)
+		if solved {
+			b.Fatalf("unexpected solution")
+		}
+	}
+}
@@ -8,14 +8,15 @@ import (
 	"bytes"
 	"container/heap"
 	"fmt"
+	"math/bits"
 	"time"
 
 	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
-	"github.com/btcsuite/btcd/btcutil"
 )
 
 const (
@@ -31,6 +32,11 @@ const (
 	// and is used to monitor BIP16 support as well as blocks that are
 	// generated via btcd.
 	CoinbaseFlags = "/P2SH/btcd/"
+
+	// MaxCoinbaseTagLen is the maximum length of the tag appended to the
+	// coinbase flags.  It ensures the coinbase script remains within the
+	// maximum allowed length regardless of the height and extra nonce.
+	MaxCoinbaseTagLen = 64
 )
 
 // TxDesc is a descriptor about a transaction in a transaction source along with
@@ -51,6 +57,29 @@ type TxDesc struct {
 
 	// FeePerKB is the fee the transaction pays in Satoshi per 1000 bytes.
 	FeePerKB int64
+
+	// FeeDelta is the fee adjustment, in Satoshi, that has been applied to
+	// the transaction via prioritisetransaction.  It only affects how the
+	// transaction is prioritized and never the fee it actually pays.
+	FeeDelta int64
+}
+
+// ModifiedFee returns the fee of the transaction adjusted by its fee delta.
+// This is the fee that is used when prioritizing the transaction.
+func (txD *TxDesc) ModifiedFee() int64 {
+	return txD.Fee + txD.FeeDelta
+}
+
+// ModifiedFeePerKB returns the fee per kilobyte of the transaction adjusted by
+// its fee delta.
+func (txD *TxDesc) ModifiedFeePerKB() int64 {
+	if txD.FeeDelta == 0 {
+		return txD.FeePerKB
+	}
+
+	vsize := (blockchain.GetTransactionWeight(txD.Tx) +
+		blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor
+	return txD.ModifiedFee() * 1000 / vsize
 }
 
 // TxSource represents a source of transactions to consider for inclusion in
@@ -73,19 +102,12 @@ type TxSource interface {
 }
 
 // txPrioItem houses a transaction along with extra information that allows the
-// transaction to be prioritized and track dependencies on other transactions
-// which have not been mined into a block yet.
+// transaction to be prioritized.
 type txPrioItem struct {
 	tx       *btcutil.Tx
 	fee      int64
 	priority float64
 	feePerKB int64
-
-	// dependsOn holds a map of transaction hashes which this one depends
-	// on.  It will only be set when the transaction references other
-	// transactions in the source pool and hence must come after them in
-	// a block.
-	dependsOn map[chainhash.Hash]struct{}
 }
 
 // txPriorityQueueLessFunc describes a function that can be used as a compare
@@ -219,73 +241,232 @@ type BlockTemplate struct {
 	WitnessCommitment []byte
 }
 
-// mergeUtxoView adds all of the entries in viewB to viewA.  The result is that
-// viewA will contain all of its original entries plus all of the entries
-// in viewB.  It will replace any entries in viewB which also exist in viewA
-// if the entry in viewA is spent.
+// mergeUtxoView adds copies of all of the entries in viewB to viewA.  The
+// result is that viewA will contain all of its original entries plus all of the
+// entries in viewB.  It will replace any entries in viewB which also exist in
+// viewA if the entry in viewA is spent.  The entries are copied so spending
+// them in viewA does not modify viewB, which allows viewB to be reused.
 func mergeUtxoView(viewA *blockchain.UtxoViewpoint, viewB *blockchain.UtxoViewpoint) {
 	viewAEntries := viewA.Entries()
 	for outpoint, entryB := range viewB.Entries() {
 		if entryA, exists := viewAEntries[outpoint]; !exists ||
 			entryA == nil || entryA.IsSpent() {
 
-			viewAEntries[outpoint] = entryB
+			viewAEntries[outpoint] = entryB.Clone()
+		}
+	}
+}
+
+// txCandidate houses a transaction from the source pool along with the details
+// needed to consider it for inclusion in a block template which only change
+// when the best chain does.
+type txCandidate struct {
+	desc *TxDesc
+
+	// utxos houses the outputs in the best chain the transaction spends.
+	utxos *blockchain.UtxoViewpoint
+
+	// poolDeps holds the hashes of the transactions in the source pool
+	// the transaction spends outputs from.
+	poolDeps []chainhash.Hash
+
+	// priority is the priority of the transaction in a block at the
+	// height the candidate was created for.
+	priority float64
+}
+
+// newTxCandidate returns a new candidate for inclusion in a block template at
+// the passed height for the passed source transaction.  It returns nil when the
+// transaction is a coinbase or it spends an output which is neither available
+// in the best chain nor in the source pool.
+func (g *BlkTmplGenerator) newTxCandidate(txDesc *TxDesc, nextBlockHeight int32) *txCandidate {
+	// A block can't have more than one coinbase.
+	tx := txDesc.Tx
+	if blockchain.IsCoinBase(tx) {
+		log.Tracef("Skipping coinbase tx %s", tx.Hash())
+		return nil
+	}
+
+	// Fetch all of the utxos referenced by the this transaction.
+	// NOTE: This intentionally does not fetch inputs from the mempool
+	// since a transaction which depends on other transactions in the
+	// mempool must come after those dependencies in the final generated
+	// block.
+	utxos, err := g.chain.FetchUtxoView(tx)
+	if err != nil {
+		log.Warnf("Unable to fetch utxo view for tx %s: %v", tx.Hash(),
+			err)
+		return nil
+	}
+
+	// Keep track of any transactions in the source pool this one depends
+	// on so they can be properly ordered.
+	var poolDeps []chainhash.Hash
+	for _, txIn := range tx.MsgTx().TxIn {
+		originHash := &txIn.PreviousOutPoint.Hash
+		entry := utxos.LookupEntry(txIn.PreviousOutPoint)
+		if entry == nil || entry.IsSpent() {
+			if !g.txSource.HaveTransaction(originHash) {
+				log.Tracef("Skipping tx %s because it references "+
+					"unspent output %s which is not available",
+					tx.Hash(), txIn.PreviousOutPoint)
+				return nil
+			}
+			poolDeps = append(poolDeps, *originHash)
 		}
 	}
+
+	// Calculate the final transaction priority using the input value age
+	// sum as well as the adjusted transaction size.  The formula is:
+	// sum(inputValue * inputAge) / adjustedTxSize
+	return &txCandidate{
+		desc:     txDesc,
+		utxos:    utxos,
+		poolDeps: poolDeps,
+		priority: CalcPriority(tx.MsgTx(), utxos, nextBlockHeight),
+	}
+}
+
+// CoinbaseFlagsWithTag returns the coinbase flags added to the coinbase script
+// of a generated block with the passed tag appended.
+func CoinbaseFlagsWithTag(tag string) []byte {
+	return []byte(CoinbaseFlags + tag)
 }
 
 // standardCoinbaseScript returns a standard script suitable for use as the
 // signature script of the coinbase transaction of a new block.  In particular,
 // it starts with the block height that is required by version 2 blocks and adds
-// the extra nonce as well as additional coinbase flags.
-func standardCoinbaseScript(nextBlockHeight int32, extraNonce uint64) ([]byte, error) {
+// the extra nonce as well as additional coinbase flags followed by the passed
+// tag.
+func standardCoinbaseScript(nextBlockHeight int32, extraNonce uint64, tag string) ([]byte, error) {
 	return txscript.NewScriptBuilder().AddInt64(int64(nextBlockHeight)).
-		AddInt64(int64(extraNonce)).AddData([]byte(CoinbaseFlags)).
+		AddInt64(int64(extraNonce)).AddData(CoinbaseFlagsWithTag(tag)).
 		Script()
 }
 
 // createCoinbaseTx returns a coinbase transaction paying an appropriate subsidy
-// based on the passed block height to the provided address.  When the address
-// is nil, the coinbase transaction will instead be redeemable by anyone.
+// based on the passed block height split between the provided payouts followed
+// by the passed extra outputs.  The value of the extra outputs is deducted from
+// the subsidy.  When there are no payouts, the remaining value of the coinbase
+// transaction will instead be redeemable by anyone.
 //
 // See the comment for NewBlockTemplate for more information about why the nil
 // address handling is useful.
-func createCoinbaseTx(params *chaincfg.Params, coinbaseScript []byte, nextBlockHeight int32, addr btcutil.Address) (*btcutil.Tx, error) {
-	// Create the script to pay to the provided payment address if one was
+func createCoinbaseTx(params *chaincfg.Params, coinbaseScript []byte,
+	nextBlockHeight int32, payouts []CoinbasePayout,
+	extraOutputs []*wire.TxOut) (*btcutil.Tx, error) {
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(&wire.TxIn{
+		// Coinbase transactions have no inputs, so previous outpoint is
+		// zero hash and max index.
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		SignatureScript: coinbaseScript,
+		Sequence:        wire.MaxTxInSequenceNum,
+	})
+
+	// Create the scripts to pay to the provided payouts if any were
 	// specified.  Otherwise create a script that allows the coinbase to be
 	// redeemable by anyone.
-	var pkScript []byte
-	if addr != nil {
-		var err error
-		pkScript, err = txscript.PayToAddrScript(addr)
+	for _, payout := range payouts {
+		if payout.Weight == 0 {
+			return nil, fmt.Errorf("coinbase payout to %v has no "+
+				"weight", payout.Address)
+		}
+		pkScript, err := txscript.PayToAddrScript(payout.Address)
 		if err != nil {
 			return nil, err
 		}
-	} else {
-		var err error
+		tx.AddTxOut(&wire.TxOut{PkScript: pkScript})
+	}
+	if len(payouts) == 0 {
 		scriptBuilder := txscript.NewScriptBuilder()
-		pkScript, err = scriptBuilder.AddOp(txscript.OP_TRUE).Script()
+		pkScript, err := scriptBuilder.AddOp(txscript.OP_TRUE).Script()
 		if err != nil {
 			return nil, err
 		}
+		tx.AddTxOut(&wire.TxOut{PkScript: pkScript})
 	}
 
-	tx := wire.NewMsgTx(wire.TxVersion)
-	tx.AddTxIn(&wire.TxIn{
-		// Coinbase transactions have no inputs, so previous outpoint is
-		// zero hash and max index.
-		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
-			wire.MaxPrevOutIndex),
-		SignatureScript: coinbaseScript,
-		Sequence:        wire.MaxTxInSequenceNum,
-	})
-	tx.AddTxOut(&wire.TxOut{
-		Value:    blockchain.CalcBlockSubsidy(nextBlockHeight, params),
-		PkScript: pkScript,
-	})
+	// Deduct the value of the extra outputs from the subsidy which must
+	// cover them.
+	value := blockchain.CalcBlockSubsidy(nextBlockHeight, params)
+	for _, txOut := range extraOutputs {
+		if txOut.Value < 0 || txOut.Value > value {
+			return nil, fmt.Errorf("coinbase output value of %d "+
+				"exceeds the remaining block subsidy of %d",
+				txOut.Value, value)
+		}
+		value -= txOut.Value
+		tx.AddTxOut(&wire.TxOut{
+			Value:    txOut.Value,
+			PkScript: txOut.PkScript,
+		})
+	}
+	distributeCoinbaseValue(tx, payouts, value)
+
 	return btcutil.NewTx(tx), nil
 }
 
+// distributeCoinbaseValue sets the values of the outputs of the passed coinbase
+// transaction which pay to the passed payouts so the passed value is split
+// between them according to their weights.  Any remainder is paid to the first
+// payout.  When there are no payouts, the full value is assigned to the first
+// output.
+func distributeCoinbaseValue(tx *wire.MsgTx, payouts []CoinbasePayout, value int64) {
+	if len(payouts) == 0 {
+		tx.TxOut[0].Value = value
+		return
+	}
+
+	var totalWeight uint64
+	for _, payout := range payouts {
+		totalWeight += uint64(payout.Weight)
+	}
+
+	// The value is split in a way that avoids overflowing for any
+	// combination of weights.
+	quotient := uint64(value) / totalWeight
+	remainder := uint64(value) % totalWeight
+	paid := int64(0)
+	for i, payout := range payouts {
+		weight := uint64(payout.Weight)
+		hi, lo := bits.Mul64(remainder, weight)
+		fraction, _ := bits.Div64(hi, lo, totalWeight)
+		share := int64(quotient*weight + fraction)
+		tx.TxOut[i].Value = share
+		paid += share
+	}
+	tx.TxOut[0].Value += value - paid
+}
+
+// addCoinbaseFees adds the passed fees to the value the passed coinbase
+// transaction pays to the passed payouts and splits the result between them
+// according to their weights.
+func addCoinbaseFees(coinbaseTx *btcutil.Tx, payouts []CoinbasePayout, fees int64) {
+	msgTx := coinbaseTx.MsgTx()
+	numPayouts := len(payouts)
+	if numPayouts == 0 {
+		numPayouts = 1
+	}
+	value := fees
+	for _, txOut := range msgTx.TxOut[:numPayouts] {
+		value += txOut.Value
+	}
+	distributeCoinbaseValue(msgTx, payouts, value)
+}
+
+// coinbasePayouts returns the payouts of the coinbase transaction of a block
+// template which pays to the passed address.  The payouts configured by the
+// policy are used when the address is nil.
+func (g *BlkTmplGenerator) coinbasePayouts(payToAddress btcutil.Address) []CoinbasePayout {
+	if payToAddress != nil {
+		return []CoinbasePayout{{Address: payToAddress, Weight: 1}}
+	}
+	return g.policy.CoinbasePayouts
+}
+
 // spendTransaction updates the passed view by marking the inputs to the passed
 // transaction as spent.  It also adds all outputs in the passed transaction
 // which are not provably unspendable as available unspent transaction outputs.
@@ -301,19 +482,6 @@ func spendTransaction(utxoView *blockchain.UtxoViewpoint, tx *btcutil.Tx, height
 	return nil
 }
 
-// logSkippedDeps logs any dependencies which are also skipped as a result of
-// skipping a transaction while generating a block template at the trace level.
-func logSkippedDeps(tx *btcutil.Tx, deps map[chainhash.Hash]*txPrioItem) {
-	if deps == nil {
-		return
-	}
-
-	for _, item := range deps {
-		log.Tracef("Skipping tx %s since it depends on %s\n",
-			item.tx.Hash(), tx.Hash())
-	}
-}
-
 // MinimumMedianTime returns the minimum allowed timestamp for a block building
 // on the end of the provided best chain.  In particular, it is one second after
 // the median timestamp of the last several blocks per the chain consensus
@@ -390,61 +558,102 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 // The transactions selected and included are prioritized according to several
 // factors.  First, each transaction has a priority calculated based on its
 // value, age of inputs, and size.  Transactions which consist of larger
-// amounts, older inputs, and small sizes have the highest priority.  Second, a
-// fee per kilobyte is calculated for each transaction.  Transactions with a
-// higher fee per kilobyte are preferred.  Finally, the block generation related
-// policy settings are all taken into account.
+// amounts, older inputs, and small sizes have the highest priority.  Second,
+// each transaction is grouped into a package along with all of the
+// transactions in the source pool it depends on which have not been included
+// yet, and a fee per kilobyte is calculated for the package as a whole using
+// the modified fees of the transactions.  Packages with a higher fee per
+// kilobyte are preferred.  Finally, the block generation related policy
+// settings are all taken into account.
 //
-// Transactions which only spend outputs from other transactions already in the
-// block chain are immediately added to a priority queue which either
-// prioritizes based on the priority (then fee per kilobyte) or the fee per
-// kilobyte (then priority) depending on whether or not the BlockPrioritySize
-// policy setting allots space for high-priority transactions.  Transactions
-// which spend outputs from other transactions in the source pool are added to a
-// dependency map so they can be added to the priority queue once the
-// transactions they depend on have been included.
+// When the BlockPrioritySize policy setting allots space for high-priority
+// transactions, that area is first filled with the transactions that have the
+// highest priority and only spend outputs from transactions already in the
+// block chain or already included in the block.
 //
 // Once the high-priority area (if configured) has been filled with
 // transactions, or the priority falls below what is considered high-priority,
-// the priority queue is updated to prioritize by fees per kilobyte (then
-// priority).
+// the remaining transactions are selected in packages by their fee per
+// kilobyte.  Grouping transactions this way allows a child transaction that
+// pays a high fee to pull its low-fee parents into the block along with it
+// (child-pays-for-parent).  Whenever a package is included, the fee per
+// kilobyte of the packages of any transactions that depend on it are updated
+// to no longer account for the included transactions.
 //
-// When the fees per kilobyte drop below the TxMinFreeFee policy setting, the
-// transaction will be skipped unless the BlockMinSize policy setting is
-// nonzero, in which case the block will be filled with the low-fee/free
-// transactions until the block size reaches that minimum size.
+// When the package fees per kilobyte drop below the TxMinFreeFee policy
+// setting, the package will be skipped unless the BlockMinSize policy setting
+// is nonzero, in which case the block will be filled with the low-fee/free
+// packages until the block size reaches that minimum size.
 //
-// Any transactions which would cause the block to exceed the BlockMaxSize
-// policy setting, exceed the maximum allowed signature operations per block, or
-// otherwise cause the block to be invalid are skipped.
+// Any packages which would cause the block to exceed the BlockMaxSize policy
+// setting or exceed the maximum allowed signature operations per block are
+// skipped, while transactions which would otherwise cause the block to be
+// invalid are skipped along with all of the transactions that depend on them.
 //
 // Given the above, a block generated by this function is of the following form:
 //
-//   -----------------------------------  --  --
-//  |      Coinbase Transaction         |   |   |
-//  |-----------------------------------|   |   |
-//  |                                   |   |   | ----- policy.BlockPrioritySize
-//  |   High-priority Transactions      |   |   |
-//  |                                   |   |   |
-//  |-----------------------------------|   | --
-//  |                                   |   |
-//  |                                   |   |
-//  |                                   |   |--- policy.BlockMaxSize
-//  |  Transactions prioritized by fee  |   |
-//  |  until <= policy.TxMinFreeFee     |   |
-//  |                                   |   |
-//  |                                   |   |
-//  |                                   |   |
-//  |-----------------------------------|   |
-//  |  Low-fee/Non high-priority (free) |   |
-//  |  transactions (while block size   |   |
-//  |  <= policy.BlockMinSize)          |   |
-//   -----------------------------------  --
+//	 -----------------------------------  --  --
+//	|      Coinbase Transaction         |   |   |
+//	|-----------------------------------|   |   |
+//	|                                   |   |   | ----- policy.BlockPrioritySize
+//	|   High-priority Transactions      |   |   |
+//	|                                   |   |   |
+//	|-----------------------------------|   | --
+//	|                                   |   |
+//	|                                   |   |
+//	|                                   |   |--- policy.BlockMaxSize
+//	|  Packages prioritized by fee      |   |
+//	|  until <= policy.TxMinFreeFee     |   |
+//	|                                   |   |
+//	|                                   |   |
+//	|                                   |   |
+//	|-----------------------------------|   |
+//	|  Low-fee/Non high-priority (free) |   |
+//	|  packages (while block size       |   |
+//	|  <= policy.BlockMinSize)          |   |
+//	 -----------------------------------  --
+//
+// When the passed address is nil and the CoinbasePayouts policy setting is
+// not empty, the coinbase is split between the configured payouts instead.
 func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress btcutil.Address) (*BlockTemplate, error) {
+	return g.NewBlockTemplateWithOutputs(payToAddress, nil)
+}
+
+// NewBlockTemplateWithOutputs returns a new block template the same way as
+// NewBlockTemplate with the passed extra outputs added to its coinbase after
+// the payment outputs.  The value of the extra outputs is deducted from the
+// value paid to the payment address and their weight and signature operations
+// are accounted for when selecting transactions.
+func (g *BlkTmplGenerator) NewBlockTemplateWithOutputs(payToAddress btcutil.Address,
+	extraOutputs []*wire.TxOut) (*BlockTemplate, error) {
+
 	// Extend the most recently known best block.
 	best := g.chain.BestSnapshot()
 	nextBlockHeight := best.Height + 1
 
+	// Gather the current source transactions which are candidates for
+	// inclusion in the block.
+	sourceTxns := g.txSource.MiningDescs()
+	candidates := make([]*txCandidate, 0, len(sourceTxns))
+	for _, txDesc := range sourceTxns {
+		candidate := g.newTxCandidate(txDesc, nextBlockHeight)
+		if candidate != nil {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	return g.newBlockTemplate(best, payToAddress, extraOutputs, candidates)
+}
+
+// newBlockTemplate returns a new block template extending the passed best
+// chain state which pays to the passed address and extra outputs and includes
+// the passed candidate transactions as described by NewBlockTemplate.
+func (g *BlkTmplGenerator) newBlockTemplate(best *blockchain.BestState,
+	payToAddress btcutil.Address, extraOutputs []*wire.TxOut,
+	candidates []*txCandidate) (*BlockTemplate, error) {
+
+	nextBlockHeight := best.Height + 1
+
 	// Create a standard coinbase transaction paying to the provided
 	// address.  NOTE: The coinbase value will be updated to include the
 	// fees from the selected transactions later after they have actually
@@ -454,65 +663,75 @@ func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress btcutil.Address) (*Bloc
 	// same value to the same public key address would otherwise be an
 	// identical transaction for block version 1).
 	extraNonce := uint64(0)
-	coinbaseScript, err := standardCoinbaseScript(nextBlockHeight, extraNonce)
+	coinbaseScript, err := standardCoinbaseScript(nextBlockHeight,
+		extraNonce, g.policy.CoinbaseTag)
 	if err != nil {
 		return nil, err
 	}
+	payouts := g.coinbasePayouts(payToAddress)
 	coinbaseTx, err := createCoinbaseTx(g.chainParams, coinbaseScript,
-		nextBlockHeight, payToAddress)
+		nextBlockHeight, payouts, extraOutputs)
 	if err != nil {
 		return nil, err
 	}
 	coinbaseSigOpCost := int64(blockchain.CountSigOps(coinbaseTx)) * blockchain.WitnessScaleFactor
 
-	// Get the current source transactions and create a priority queue to
-	// hold the transactions which are ready for inclusion into a block
-	// along with some priority related and fee metadata.  Reserve the same
-	// number of items that are available for the priority queue.  Also,
-	// choose the initial sort order for the priority queue based on whether
-	// or not there is an area allocated for high-priority transactions.
-	sourceTxns := g.txSource.MiningDescs()
-	sortedByFee := g.policy.BlockPrioritySize == 0
-	priorityQueue := newTxPriorityQueue(len(sourceTxns), sortedByFee)
+	// Ensure the coinbase alone does not exceed the limits of a block,
+	// which is only possible due to the extra outputs.
+	coinbaseWeight := (blockHeaderOverhead * blockchain.WitnessScaleFactor) +
+		blockchain.GetTransactionWeight(coinbaseTx)
+	if coinbaseWeight > int64(g.policy.BlockMaxWeight) {
+		return nil, fmt.Errorf("coinbase transaction weight of %d "+
+			"exceeds the maximum block weight of %d", coinbaseWeight,
+			g.policy.BlockMaxWeight)
+	}
+	if coinbaseSigOpCost > blockchain.MaxBlockSigOpsCost {
+		return nil, fmt.Errorf("coinbase transaction signature "+
+			"operations cost of %d exceeds the maximum of %d",
+			coinbaseSigOpCost, blockchain.MaxBlockSigOpsCost)
+	}
 
 	// Create a slice to hold the transactions to be included in the
 	// generated block with reserved space.  Also create a utxo view to
 	// house all of the input transactions so multiple lookups can be
 	// avoided.
-	blockTxns := make([]*btcutil.Tx, 0, len(sourceTxns))
+	blockTxns := make([]*btcutil.Tx, 0, len(candidates))
 	blockTxns = append(blockTxns, coinbaseTx)
 	blockUtxos := blockchain.NewUtxoViewpoint()
 
-	// dependers is used to track transactions which depend on another
-	// transaction in the source pool.  This, in conjunction with the
-	// dependsOn map kept with each dependent transaction helps quickly
-	// determine which dependent transactions are now eligible for inclusion
-	// in the block once each transaction has been included.
-	dependers := make(map[chainhash.Hash]map[chainhash.Hash]*txPrioItem)
-
 	// Create slices to hold the fees and number of signature operations
 	// for each of the selected transactions and add an entry for the
 	// coinbase.  This allows the code below to simply append details about
 	// a transaction as it is selected for inclusion in the final block.
 	// However, since the total fees aren't known yet, use a dummy value for
 	// the coinbase fee which will be updated later.
-	txFees := make([]int64, 0, len(sourceTxns))
-	txSigOpCosts := make([]int64, 0, len(sourceTxns))
+	txFees := make([]int64, 0, len(candidates))
+	txSigOpCosts := make([]int64, 0, len(candidates))
 	txFees = append(txFees, -1) // Updated once known
 	txSigOpCosts = append(txSigOpCosts, coinbaseSigOpCost)
 
-	log.Debugf("Considering %d transactions for inclusion to new block",
-		len(sourceTxns))
+	// Query the version bits state to see if segwit has been activated, if
+	// so then this means that we'll include any transactions with witness
+	// data in the mempool, and also add the witness commitment as an
+	// OP_RETURN output in the coinbase transaction.
+	segwitState, err := g.chain.ThresholdState(chaincfg.DeploymentSegwit)
+	if err != nil {
+		return nil, err
+	}
+	segwitActive := segwitState == blockchain.ThresholdActive
 
-mempoolLoop:
-	for _, txDesc := range sourceTxns {
-		// A block can't have more than one coinbase or contain
-		// non-finalized transactions.
-		tx := txDesc.Tx
-		if blockchain.IsCoinBase(tx) {
-			log.Tracef("Skipping coinbase tx %s", tx.Hash())
-			continue
-		}
+	log.Debugf("Considering %d transactions for inclusion to new block",
+		len(candidates))
+
+	// Gather the transactions which are eligible for inclusion along with
+	// their priority and the hashes of the transactions in the source pool
+	// they depend on.
+	eligibleTxns := make([]*TxDesc, 0, len(candidates))
+	priorities := make(map[chainhash.Hash]float64, len(candidates))
+	poolDeps := make(map[chainhash.Hash][]chainhash.Hash)
+	for _, candidate := range candidates {
+		// A block can't contain non-finalized transactions.
+		tx := candidate.desc.Tx
 		if !blockchain.IsFinalizedTransaction(tx, nextBlockHeight,
 			g.timeSource.AdjustedTime()) {
 
@@ -520,272 +739,278 @@ mempoolLoop:
 			continue
 		}
 
-		// Fetch all of the utxos referenced by the this transaction.
-		// NOTE: This intentionally does not fetch inputs from the
-		// mempool since a transaction which depends on other
-		// transactions in the mempool must come after those
-		// dependencies in the final generated block.
-		utxos, err := g.chain.FetchUtxoView(tx)
-		if err != nil {
-			log.Warnf("Unable to fetch utxo view for tx %s: %v",
-				tx.Hash(), err)
+		// If segregated witness has not been activated yet, then we
+		// shouldn't include any witness transactions in the block.
+		if !segwitActive && tx.HasWitness() {
+			log.Tracef("Skipping witness tx %s since segwit is not "+
+				"active", tx.Hash())
 			continue
 		}
 
-		// Setup dependencies for any transactions which reference
-		// other transactions in the mempool so they can be properly
-		// ordered below.
-		prioItem := &txPrioItem{tx: tx}
-		for _, txIn := range tx.MsgTx().TxIn {
-			originHash := &txIn.PreviousOutPoint.Hash
-			entry := utxos.LookupEntry(txIn.PreviousOutPoint)
-			if entry == nil || entry.IsSpent() {
-				if !g.txSource.HaveTransaction(originHash) {
-					log.Tracef("Skipping tx %s because it "+
-						"references unspent output %s "+
-						"which is not available",
-						tx.Hash(), txIn.PreviousOutPoint)
-					continue mempoolLoop
-				}
-
-				// The transaction is referencing another
-				// transaction in the source pool, so setup an
-				// ordering dependency.
-				deps, exists := dependers[*originHash]
-				if !exists {
-					deps = make(map[chainhash.Hash]*txPrioItem)
-					dependers[*originHash] = deps
-				}
-				deps[*prioItem.tx.Hash()] = prioItem
-				if prioItem.dependsOn == nil {
-					prioItem.dependsOn = make(
-						map[chainhash.Hash]struct{})
-				}
-				prioItem.dependsOn[*originHash] = struct{}{}
-
-				// Skip the check below. We already know the
-				// referenced transaction is available.
-				continue
-			}
-		}
-
-		// Calculate the final transaction priority using the input
-		// value age sum as well as the adjusted transaction size.  The
-		// formula is: sum(inputValue * inputAge) / adjustedTxSize
-		prioItem.priority = CalcPriority(tx.MsgTx(), utxos,
-			nextBlockHeight)
-
-		// Calculate the fee in Satoshi/kB.
-		prioItem.feePerKB = txDesc.FeePerKB
-		prioItem.fee = txDesc.Fee
-
-		// Add the transaction to the priority queue to mark it ready
-		// for inclusion in the block unless it has dependencies.
-		if prioItem.dependsOn == nil {
-			heap.Push(priorityQueue, prioItem)
+		priorities[*tx.Hash()] = candidate.priority
+		if len(candidate.poolDeps) > 0 {
+			poolDeps[*tx.Hash()] = candidate.poolDeps
 		}
 
 		// Merge the referenced outputs from the input transactions to
 		// this transaction into the block utxo view.  This allows the
 		// code below to avoid a second lookup.
-		mergeUtxoView(blockUtxos, utxos)
+		mergeUtxoView(blockUtxos, candidate.utxos)
+		eligibleTxns = append(eligibleTxns, candidate.desc)
 	}
 
-	log.Tracef("Priority queue len %d, dependers len %d",
-		priorityQueue.Len(), len(dependers))
+	// Create a package selector for the eligible transactions and exclude
+	// those which depend on a transaction that is not eligible.
+	selector := newTxPackageSelector(eligibleTxns)
+	for _, entry := range selector.ordered {
+		for _, depHash := range poolDeps[*entry.desc.Tx.Hash()] {
+			if _, ok := selector.entries[depHash]; !ok {
+				log.Tracef("Skipping tx %s since it depends on "+
+					"ineligible tx %s", entry.desc.Tx.Hash(),
+					depHash)
+				selector.exclude(entry)
+				break
+			}
+		}
+	}
+
+	// Add the outputs of the eligible transactions to the block utxo view
+	// so the signature operation cost of the transactions which depend on
+	// them can be calculated and validated up front.  The selector ensures
+	// a transaction is never selected before the ones it depends on.
+	for _, entry := range selector.ordered {
+		blockUtxos.AddTxOuts(entry.desc.Tx, UnminedHeight)
+	}
+	sigOpCosts := make(map[chainhash.Hash]int64, len(selector.ordered))
+	for _, entry := range selector.ordered {
+		tx := entry.desc.Tx
+		sigOpCost, err := blockchain.GetSigOpCost(tx, false,
+			blockUtxos, true, segwitActive)
+		if err != nil {
+			log.Tracef("Skipping tx %s due to error in "+
+				"GetSigOpCost: %v", tx.Hash(), err)
+			selector.exclude(entry)
+			continue
+		}
+		sigOpCosts[*tx.Hash()] = int64(sigOpCost)
+	}
 
 	// The starting block size is the size of the block header plus the max
 	// possible transaction count size, plus the size of the coinbase
 	// transaction.
-	blockWeight := uint32((blockHeaderOverhead * blockchain.WitnessScaleFactor) +
-		blockchain.GetTransactionWeight(coinbaseTx))
+	blockWeight := uint32(coinbaseWeight)
 	blockSigOpCost := coinbaseSigOpCost
 	totalFees := int64(0)
 
-	// Query the version bits state to see if segwit has been activated, if
-	// so then this means that we'll include any transactions with witness
-	// data in the mempool, and also add the witness commitment as an
-	// OP_RETURN output in the coinbase transaction.
-	segwitState, err := g.chain.ThresholdState(chaincfg.DeploymentSegwit)
-	if err != nil {
-		return nil, err
+	// If we include a transaction bearing witness data, then we'll also
+	// need to include a witness commitment in the coinbase transaction.
+	// Therefore, we account for the additional weight within the block
+	// with a model coinbase tx with a witness commitment.
+	var commitmentWeight uint32
+	if segwitActive {
+		coinbaseCopy := btcutil.NewTx(coinbaseTx.MsgTx().Copy())
+		coinbaseCopy.MsgTx().TxIn[0].Witness = [][]byte{
+			bytes.Repeat([]byte("a"),
+				blockchain.CoinbaseWitnessDataLen),
+		}
+		coinbaseCopy.MsgTx().AddTxOut(&wire.TxOut{
+			PkScript: bytes.Repeat([]byte("a"),
+				blockchain.CoinbaseWitnessPkScriptLength),
+		})
+
+		// In order to accurately account for the weight addition due
+		// to this coinbase transaction, we'll add the difference of
+		// the transaction before and after the addition of the
+		// commitment to the block weight.
+		commitmentWeight = uint32(blockchain.GetTransactionWeight(coinbaseCopy) -
+			blockchain.GetTransactionWeight(coinbaseTx))
 	}
-	segwitActive := segwitState == blockchain.ThresholdActive
-
 	witnessIncluded := false
 
-	// Choose which transactions make it into the block.
-	for priorityQueue.Len() > 0 {
-		// Grab the highest priority (or highest fee per kilobyte
-		// depending on the sort order) transaction.
-		prioItem := heap.Pop(priorityQueue).(*txPrioItem)
-		tx := prioItem.tx
-
-		switch {
-		// If segregated witness has not been activated yet, then we
-		// shouldn't include any witness transactions in the block.
-		case !segwitActive && tx.HasWitness():
-			continue
-
-		// Otherwise, Keep track of if we've included a transaction
-		// with witness data or not. If so, then we'll need to include
-		// the witness commitment as the last output in the coinbase
-		// transaction.
-		case segwitActive && !witnessIncluded && tx.HasWitness():
-			// If we're about to include a transaction bearing
-			// witness data, then we'll also need to include a
-			// witness commitment in the coinbase transaction.
-			// Therefore, we account for the additional weight
-			// within the block with a model coinbase tx with a
-			// witness commitment.
-			coinbaseCopy := btcutil.NewTx(coinbaseTx.MsgTx().Copy())
-			coinbaseCopy.MsgTx().TxIn[0].Witness = [][]byte{
-				bytes.Repeat([]byte("a"),
-					blockchain.CoinbaseWitnessDataLen),
+	// addPackage attempts to add the passed package to the block.  It
+	// returns whether or not the package was added.  Any transactions in
+	// the package which are invalid are excluded from further selection
+	// along with their descendants.  Low-fee packages are only skipped
+	// when checkFee is set.
+	addPackage := func(pkg *txPackage, checkFee bool) bool {
+		headHash := pkg.head().desc.Tx.Hash()
+
+		// Keep track of if we've included a transaction with witness
+		// data or not.  If so, then we'll need to include the witness
+		// commitment as the last output in the coinbase transaction.
+		pkgWeight := uint32(pkg.weight)
+		pkgHasWitness := false
+		for _, entry := range pkg.entries {
+			if entry.desc.Tx.HasWitness() {
+				pkgHasWitness = true
+				break
 			}
-			coinbaseCopy.MsgTx().AddTxOut(&wire.TxOut{
-				PkScript: bytes.Repeat([]byte("a"),
-					blockchain.CoinbaseWitnessPkScriptLength),
-			})
-
-			// In order to accurately account for the weight
-			// addition due to this coinbase transaction, we'll add
-			// the difference of the transaction before and after
-			// the addition of the commitment to the block weight.
-			weightDiff := blockchain.GetTransactionWeight(coinbaseCopy) -
-				blockchain.GetTransactionWeight(coinbaseTx)
-
-			blockWeight += uint32(weightDiff)
-
-			witnessIncluded = true
 		}
-
-		// Grab any transactions which depend on this one.
-		deps := dependers[*tx.Hash()]
+		if pkgHasWitness && !witnessIncluded {
+			pkgWeight += commitmentWeight
+		}
 
 		// Enforce maximum block size.  Also check for overflow.
-		txWeight := uint32(blockchain.GetTransactionWeight(tx))
-		blockPlusTxWeight := blockWeight + txWeight
-		if blockPlusTxWeight < blockWeight ||
-			blockPlusTxWeight >= g.policy.BlockMaxWeight {
-
-			log.Tracef("Skipping tx %s because it would exceed "+
-				"the max block weight", tx.Hash())
-			logSkippedDeps(tx, deps)
-			continue
+		blockPlusPkgWeight := blockWeight + pkgWeight
+		if blockPlusPkgWeight < blockWeight ||
+			blockPlusPkgWeight >= g.policy.BlockMaxWeight {
+
+			log.Tracef("Skipping tx %s because its package would "+
+				"exceed the max block weight", headHash)
+			return false
 		}
 
 		// Enforce maximum signature operation cost per block.  Also
 		// check for overflow.
-		sigOpCost, err := blockchain.GetSigOpCost(tx, false,
-			blockUtxos, true, segwitActive)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"GetSigOpCost: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
+		var pkgSigOpCost int64
+		for _, entry := range pkg.entries {
+			pkgSigOpCost += sigOpCosts[*entry.desc.Tx.Hash()]
 		}
-		if blockSigOpCost+int64(sigOpCost) < blockSigOpCost ||
-			blockSigOpCost+int64(sigOpCost) > blockchain.MaxBlockSigOpsCost {
-			log.Tracef("Skipping tx %s because it would "+
-				"exceed the maximum sigops per block", tx.Hash())
-			logSkippedDeps(tx, deps)
-			continue
+		if blockSigOpCost+pkgSigOpCost < blockSigOpCost ||
+			blockSigOpCost+pkgSigOpCost > blockchain.MaxBlockSigOpsCost {
+
+			log.Tracef("Skipping tx %s because its package would "+
+				"exceed the maximum sigops per block", headHash)
+			return false
 		}
 
-		// Skip free transactions once the block is larger than the
+		// Skip free packages once the block is larger than the
 		// minimum block size.
-		if sortedByFee &&
-			prioItem.feePerKB < int64(g.policy.TxMinFreeFee) &&
-			blockPlusTxWeight >= g.policy.BlockMinWeight {
+		pkgFeePerKB := pkg.fee * 1000 / pkg.vsize
+		if checkFee && pkgFeePerKB < int64(g.policy.TxMinFreeFee) &&
+			blockPlusPkgWeight >= g.policy.BlockMinWeight {
 
-			log.Tracef("Skipping tx %s with feePerKB %d "+
+			log.Tracef("Skipping tx %s with package feePerKB %d "+
 				"< TxMinFreeFee %d and block weight %d >= "+
-				"minBlockWeight %d", tx.Hash(), prioItem.feePerKB,
-				g.policy.TxMinFreeFee, blockPlusTxWeight,
+				"minBlockWeight %d", headHash, pkgFeePerKB,
+				g.policy.TxMinFreeFee, blockPlusPkgWeight,
 				g.policy.BlockMinWeight)
-			logSkippedDeps(tx, deps)
-			continue
+			return false
 		}
 
-		// Prioritize by fee per kilobyte once the block is larger than
-		// the priority size or there are no more high-priority
-		// transactions.
-		if !sortedByFee && (blockPlusTxWeight >= g.policy.BlockPrioritySize ||
-			prioItem.priority <= MinHighPriority) {
-
-			log.Tracef("Switching to sort by fees per "+
-				"kilobyte blockSize %d >= BlockPrioritySize "+
-				"%d || priority %.2f <= minHighPriority %.2f",
-				blockPlusTxWeight, g.policy.BlockPrioritySize,
-				prioItem.priority, MinHighPriority)
-
-			sortedByFee = true
-			priorityQueue.SetLessFunc(txPQByFee)
-
-			// Put the transaction back into the priority queue and
-			// skip it so it is re-priortized by fees if it won't
-			// fit into the high-priority section or the priority
-			// is too low.  Otherwise this transaction will be the
-			// final one in the high-priority section, so just fall
-			// though to the code below so it is added now.
-			if blockPlusTxWeight > g.policy.BlockPrioritySize ||
-				prioItem.priority < MinHighPriority {
-
-				heap.Push(priorityQueue, prioItem)
-				continue
+		// Ensure the transaction inputs pass all of the necessary
+		// preconditions before allowing the package to be added to the
+		// block.
+		for _, entry := range pkg.entries {
+			tx := entry.desc.Tx
+			_, err := blockchain.CheckTransactionInputs(tx,
+				nextBlockHeight, blockUtxos, g.chainParams)
+			if err != nil {
+				log.Tracef("Skipping tx %s due to error in "+
+					"CheckTransactionInputs: %v", tx.Hash(),
+					err)
+				selector.exclude(entry)
+				return false
+			}
+			err = blockchain.ValidateTransactionScripts(tx,
+				blockUtxos, txscript.StandardVerifyFlags,
+				g.sigCache, g.hashCache)
+			if err != nil {
+				log.Tracef("Skipping tx %s due to error in "+
+					"ValidateTransactionScripts: %v",
+					tx.Hash(), err)
+				selector.exclude(entry)
+				return false
 			}
 		}
 
-		// Ensure the transaction inputs pass all of the necessary
-		// preconditions before allowing it to be added to the block.
-		_, err = blockchain.CheckTransactionInputs(tx, nextBlockHeight,
-			blockUtxos, g.chainParams)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"CheckTransactionInputs: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
+		// Spend the transaction inputs in the block utxo view to
+		// ensure none of the later transactions double spend them,
+		// add the transactions to the block, and save the fees and
+		// signature operation counts to the block template.  Note the
+		// actual fee is what is paid to the coinbase regardless of any
+		// fee delta used for prioritization.
+		for _, entry := range pkg.entries {
+			tx := entry.desc.Tx
+			spendTransaction(blockUtxos, tx, nextBlockHeight)
+			blockTxns = append(blockTxns, tx)
+			totalFees += entry.desc.Fee
+			txFees = append(txFees, entry.desc.Fee)
+			txSigOpCosts = append(txSigOpCosts, sigOpCosts[*tx.Hash()])
+
+			log.Tracef("Adding tx %s (priority %.2f, feePerKB %d, "+
+				"package feePerKB %d)", tx.Hash(),
+				priorities[*tx.Hash()],
+				entry.desc.ModifiedFeePerKB(), pkgFeePerKB)
 		}
-		err = blockchain.ValidateTransactionScripts(tx, blockUtxos,
-			txscript.StandardVerifyFlags, g.sigCache,
-			g.hashCache)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"ValidateTransactionScripts: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
+		blockWeight = blockPlusPkgWeight
+		blockSigOpCost += pkgSigOpCost
+		witnessIncluded = witnessIncluded || pkgHasWitness
+		selector.selectPackage(pkg)
+
+		return true
+	}
+
+	// Fill the high-priority area, if any, with the transactions that have
+	// the highest priority.  Only transactions which don't depend on any
+	// unselected transactions are ready for inclusion, so the transactions
+	// which depend on a selected one are added to the priority queue once
+	// all of the transactions they depend on have been selected.
+	if g.policy.BlockPrioritySize > 0 {
+		priorityQueue := newTxPriorityQueue(len(selector.ordered), false)
+		pushEntry := func(entry *txPackageEntry) {
+			heap.Push(priorityQueue, &txPrioItem{
+				tx:       entry.desc.Tx,
+				fee:      entry.desc.Fee,
+				priority: priorities[*entry.desc.Tx.Hash()],
+				feePerKB: entry.desc.ModifiedFeePerKB(),
+			})
+		}
+		for _, entry := range selector.ordered {
+			if len(entry.parents) == 0 && !entry.failed {
+				pushEntry(entry)
+			}
 		}
 
-		// Spend the transaction inputs in the block utxo view and add
-		// an entry for it to ensure any transactions which reference
-		// this one have it available as an input and can ensure they
-		// aren't double spending.
-		spendTransaction(blockUtxos, tx, nextBlockHeight)
+		for priorityQueue.Len() > 0 {
+			prioItem := heap.Pop(priorityQueue).(*txPrioItem)
+			entry := selector.entries[*prioItem.tx.Hash()]
 
-		// Add the transaction to the block, increment counters, and
-		// save the fees and signature operation counts to the block
-		// template.
-		blockTxns = append(blockTxns, tx)
-		blockWeight += txWeight
-		blockSigOpCost += int64(sigOpCost)
-		totalFees += prioItem.fee
-		txFees = append(txFees, prioItem.fee)
-		txSigOpCosts = append(txSigOpCosts, int64(sigOpCost))
+			// Stop once the high-priority area is full or there
+			// are no more high-priority transactions.
+			txWeight := uint32(entry.weight)
+			if blockWeight+txWeight > g.policy.BlockPrioritySize ||
+				prioItem.priority < MinHighPriority {
 
-		log.Tracef("Adding tx %s (priority %.2f, feePerKB %.2f)",
-			prioItem.tx.Hash(), prioItem.priority, prioItem.feePerKB)
-
-		// Add transactions which depend on this one (and also do not
-		// have any other unsatisified dependencies) to the priority
-		// queue.
-		for _, item := range deps {
-			// Add the transaction to the priority queue if there
-			// are no more dependencies after this one.
-			delete(item.dependsOn, *tx.Hash())
-			if len(item.dependsOn) == 0 {
-				heap.Push(priorityQueue, item)
+				log.Tracef("Switching to sort by package fees "+
+					"per kilobyte blockSize %d >= "+
+					"BlockPrioritySize %d || priority %.2f "+
+					"< minHighPriority %.2f",
+					blockWeight+txWeight,
+					g.policy.BlockPrioritySize,
+					prioItem.priority, MinHighPriority)
+				break
 			}
+
+			if entry.failed ||
+				!addPackage(selector.packageFor(entry), false) {
+
+				continue
+			}
+
+			for _, child := range entry.children {
+				if child.failed {
+					continue
+				}
+				ready := true
+				for _, parent := range child.parents {
+					if !parent.selected {
+						ready = false
+						break
+					}
+				}
+				if ready {
+					pushEntry(child)
+				}
+			}
+		}
+	}
+
+	// Choose which transactions make it into the rest of the block by the
+	// modified fee rate of their packages.
+	for pkg := selector.next(); pkg != nil; pkg = selector.next() {
+		if !addPackage(pkg, true) {
+			selector.skip(pkg)
 		}
 	}
 
@@ -795,7 +1020,7 @@ mempoolLoop:
 	blockWeight -= wire.MaxVarIntPayload -
 		(uint32(wire.VarIntSerializeSize(uint64(len(blockTxns)))) *
 			blockchain.WitnessScaleFactor)
-	coinbaseTx.MsgTx().TxOut[0].Value += totalFees
+	addCoinbaseFees(coinbaseTx, payouts, totalFees)
 	txFees[0] = -totalFees
 
 	// If segwit is active and we included transactions with witness data,
@@ -806,6 +1031,33 @@ mempoolLoop:
 		witnessCommitment = AddWitnessCommitment(coinbaseTx, blockTxns)
 	}
 
+	msgBlock, err := g.assembleBlock(best, nextBlockHeight, blockTxns)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("Created new block template (%d transactions, %d in "+
+		"fees, %d signature operations cost, %d weight, target difficulty "+
+		"%064x)", len(msgBlock.Transactions), totalFees, blockSigOpCost,
+		blockWeight, blockchain.CompactToBig(msgBlock.Header.Bits))
+
+	return &BlockTemplate{
+		Block:             msgBlock,
+		Fees:              txFees,
+		SigOpCosts:        txSigOpCosts,
+		Height:            nextBlockHeight,
+		ValidPayAddress:   len(payouts) > 0,
+		WitnessCommitment: witnessCommitment,
+	}, nil
+}
+
+// assembleBlock returns a new block extending the passed best chain state
+// which contains the passed transactions and is ready to be solved.  The block
+// is fully checked against the chain consensus rules to ensure it properly
+// connects to the current best chain.
+func (g *BlkTmplGenerator) assembleBlock(best *blockchain.BestState,
+	nextBlockHeight int32, blockTxns []*btcutil.Tx) (*wire.MsgBlock, error) {
+
 	// Calculate the required difficulty for the block.  The timestamp
 	// is potentially adjusted to ensure it comes after the median time of
 	// the last several blocks per the chain consensus rules.
@@ -847,17 +1099,131 @@ mempoolLoop:
 		return nil, err
 	}
 
-	log.Debugf("Created new block template (%d transactions, %d in "+
-		"fees, %d signature operations cost, %d weight, target difficulty "+
-		"%064x)", len(msgBlock.Transactions), totalFees, blockSigOpCost,
-		blockWeight, blockchain.CompactToBig(msgBlock.Header.Bits))
+	return &msgBlock, nil
+}
+
+// NewBlockTemplateWithTxs returns a new block template that is ready to be
+// solved and contains exactly the passed transactions in the passed order
+// after a coinbase transaction paying to the passed address.  Unlike
+// NewBlockTemplate, the transactions are not taken from the transaction source
+// nor subject to any policy such as fee or block size limits.  They may spend
+// the outputs of earlier transactions in the list, but not those of any other
+// unconfirmed transactions.
+//
+// An error is returned when the transactions do not form a block that is valid
+// according to the chain consensus rules.
+//
+// See the comment for NewBlockTemplate for more information about the handling
+// of a nil payment address.
+func (g *BlkTmplGenerator) NewBlockTemplateWithTxs(payToAddress btcutil.Address,
+	txns []*btcutil.Tx) (*BlockTemplate, error) {
+
+	// Extend the most recently known best block.
+	best := g.chain.BestSnapshot()
+	nextBlockHeight := best.Height + 1
+
+	// Create a standard coinbase transaction paying to the provided
+	// address.  The coinbase value is updated to include the fees of the
+	// transactions below once they are known.
+	coinbaseScript, err := standardCoinbaseScript(nextBlockHeight, 0,
+		g.policy.CoinbaseTag)
+	if err != nil {
+		return nil, err
+	}
+	payouts := g.coinbasePayouts(payToAddress)
+	coinbaseTx, err := createCoinbaseTx(g.chainParams, coinbaseScript,
+		nextBlockHeight, payouts, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	segwitState, err := g.chain.ThresholdState(chaincfg.DeploymentSegwit)
+	if err != nil {
+		return nil, err
+	}
+	segwitActive := segwitState == blockchain.ThresholdActive
+
+	blockTxns := make([]*btcutil.Tx, 0, len(txns)+1)
+	blockTxns = append(blockTxns, coinbaseTx)
+	txFees := make([]int64, 0, len(txns)+1)
+	txFees = append(txFees, -1) // Updated once known
+	txSigOpCosts := make([]int64, 0, len(txns)+1)
+	txSigOpCosts = append(txSigOpCosts,
+		int64(blockchain.CountSigOps(coinbaseTx))*
+			blockchain.WitnessScaleFactor)
+
+	// Calculate the fee and signature operation cost of each transaction
+	// against a view of the chain that includes the outputs of the
+	// transactions before it.
+	blockUtxos := blockchain.NewUtxoViewpoint()
+	seen := make(map[chainhash.Hash]struct{}, len(txns))
+	totalFees := int64(0)
+	witnessIncluded := false
+	for _, tx := range txns {
+		if blockchain.IsCoinBase(tx) {
+			return nil, fmt.Errorf("transaction %v is a coinbase",
+				tx.Hash())
+		}
+		if _, ok := seen[*tx.Hash()]; ok {
+			return nil, fmt.Errorf("duplicate transaction %v",
+				tx.Hash())
+		}
+		seen[*tx.Hash()] = struct{}{}
+		if !segwitActive && tx.HasWitness() {
+			return nil, fmt.Errorf("transaction %v has witness "+
+				"data, but segwit is not active", tx.Hash())
+		}
+
+		utxos, err := g.chain.FetchUtxoView(tx)
+		if err != nil {
+			return nil, err
+		}
+		mergeUtxoView(blockUtxos, utxos)
+
+		fee, err := blockchain.CheckTransactionInputs(tx,
+			nextBlockHeight, blockUtxos, g.chainParams)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transaction %v: %v",
+				tx.Hash(), err)
+		}
+		sigOpCost, err := blockchain.GetSigOpCost(tx, false,
+			blockUtxos, true, segwitActive)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transaction %v: %v",
+				tx.Hash(), err)
+		}
+		spendTransaction(blockUtxos, tx, nextBlockHeight)
+
+		blockTxns = append(blockTxns, tx)
+		txFees = append(txFees, fee)
+		txSigOpCosts = append(txSigOpCosts, int64(sigOpCost))
+		totalFees += fee
+		witnessIncluded = witnessIncluded || tx.HasWitness()
+	}
+
+	addCoinbaseFees(coinbaseTx, payouts, totalFees)
+	txFees[0] = -totalFees
+
+	var witnessCommitment []byte
+	if witnessIncluded {
+		witnessCommitment = AddWitnessCommitment(coinbaseTx, blockTxns)
+	}
+
+	msgBlock, err := g.assembleBlock(best, nextBlockHeight, blockTxns)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debugf("Created new block template with %d specified "+
+		"transactions (%d in fees, target difficulty %064x)", len(txns),
+		totalFees, blockchain.CompactToBig(msgBlock.Header.Bits))
 
 	return &BlockTemplate{
-		Block:             &msgBlock,
+		Block:             msgBlock,
 		Fees:              txFees,
 		SigOpCosts:        txSigOpCosts,
 		Height:            nextBlockHeight,
-		ValidPayAddress:   payToAddress != nil,
+		ValidPayAddress:   len(payouts) > 0,
 		WitnessCommitment: witnessCommitment,
 	}, nil
 }
@@ -935,7 +1301,8 @@ func (g *BlkTmplGenerator) UpdateBlockTime(msgBlock *wire.MsgBlock) error {
 // height.  It also recalculates and updates the new merkle root that results
 // from changing the coinbase script.
 func (g *BlkTmplGenerator) UpdateExtraNonce(msgBlock *wire.MsgBlock, blockHeight int32, extraNonce uint64) error {
-	coinbaseScript, err := standardCoinbaseScript(blockHeight, extraNonce)
+	coinbaseScript, err := standardCoinbaseScript(blockHeight, extraNonce,
+		g.policy.CoinbaseTag)
 	if err != nil {
 		return err
 	}
@@ -974,3 +1341,11 @@ func (g *BlkTmplGenerator) BestSnapshot() *blockchain.BestState {
 func (g *BlkTmplGenerator) TxSource() TxSource {
 	return g.txSource
 }
+
+// Policy returns the policy block templates are generated with.  It must not
+// be modified.
+//
+// This function is safe for concurrent access.
+func (g *BlkTmplGenerator) Policy() *Policy {
+	return g.policy
+}
@@ -5,11 +5,21 @@
 package mining
 
 import (
+	"bytes"
 	"container/heap"
+	"math"
 	"math/rand"
 	"testing"
+	"time"
 
+	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	_ "github.com/btcsuite/btcd/database/ffldb"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
 )
 
 // TestTxFeePrioHeap ensures the priority queue for transaction fees and
@@ -108,3 +118,447 @@ func TestTxFeePrioHeap(t *testing.T) {
 		highest = prioItem
 	}
 }
+
+// fakeTxSource provides a transaction source backed by a fixed set of mining
+// descriptors.
+type fakeTxSource struct {
+	descs []*TxDesc
+}
+
+// LastUpdated returns the last time a transaction was added to or removed from
+// the source pool.  It is part of the TxSource interface.
+func (s *fakeTxSource) LastUpdated() time.Time {
+	return time.Time{}
+}
+
+// MiningDescs returns a slice of mining descriptors for all the transactions
+// in the source pool.  It is part of the TxSource interface.
+func (s *fakeTxSource) MiningDescs() []*TxDesc {
+	return s.descs
+}
+
+// HaveTransaction returns whether or not the passed transaction hash exists in
+// the source pool.  It is part of the TxSource interface.
+func (s *fakeTxSource) HaveTransaction(hash *chainhash.Hash) bool {
+	for _, desc := range s.descs {
+		if *desc.Tx.Hash() == *hash {
+			return true
+		}
+	}
+	return false
+}
+
+// newTestGenerator returns a block template generator backed by a new regtest
+// chain with enough blocks mined for the coinbase outputs of the returned
+// blocks to be spendable.  The coinbase outputs are spendable by anyone.
+func newTestGenerator(t *testing.T, source *fakeTxSource,
+	numSpendable int) (*BlkTmplGenerator, []*btcutil.Tx) {
+
+	params := chaincfg.RegressionNetParams
+	db, err := database.Create("ffldb", t.TempDir(), params.Net)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	timeSource := blockchain.NewMedianTime()
+	sigCache := txscript.NewSigCache(1000)
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: &params,
+		TimeSource:  timeSource,
+		SigCache:    sigCache,
+	})
+	if err != nil {
+		t.Fatalf("unable to create chain: %v", err)
+	}
+
+	policy := &Policy{
+		BlockMaxWeight: blockchain.MaxBlockWeight - 4000,
+		TxMinFreeFee:   1000,
+	}
+	g := NewBlkTmplGenerator(policy, &params, source, chain, timeSource,
+		sigCache, txscript.NewHashCache(1000))
+
+	// Mine enough blocks for the first coinbase outputs to mature.
+	var coinbases []*btcutil.Tx
+	numBlocks := int(params.CoinbaseMaturity) + numSpendable
+	for i := 0; i < numBlocks; i++ {
+		template, err := g.NewBlockTemplate(nil)
+		if err != nil {
+			t.Fatalf("unable to create block template: %v", err)
+		}
+		msgBlock := template.Block
+		target := blockchain.CompactToBig(msgBlock.Header.Bits)
+		for {
+			hash := msgBlock.Header.BlockHash()
+			if blockchain.HashToBig(&hash).Cmp(target) <= 0 {
+				break
+			}
+			msgBlock.Header.Nonce++
+		}
+		block := btcutil.NewBlock(msgBlock)
+		_, isOrphan, err := chain.ProcessBlock(block, blockchain.BFNone)
+		if err != nil || isOrphan {
+			t.Fatalf("unable to process block: %v (orphan %v)", err,
+				isOrphan)
+		}
+		if i < numSpendable {
+			coinbases = append(coinbases,
+				btcutil.NewTx(msgBlock.Transactions[0]))
+		}
+	}
+
+	return g, coinbases
+}
+
+// newSpendTxDesc returns a mining descriptor for a transaction that spends the
+// first output of the passed anyone-can-spend transaction and pays the passed
+// fee.
+func newSpendTxDesc(prevTx *btcutil.Tx, fee int64) *TxDesc {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	prevOut := wire.NewOutPoint(prevTx.Hash(), 0)
+	tx.AddTxIn(wire.NewTxIn(prevOut, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(prevTx.MsgTx().TxOut[0].Value-fee,
+		[]byte{txscript.OP_TRUE}))
+	return &TxDesc{
+		Tx:       btcutil.NewTx(tx),
+		Fee:      fee,
+		FeePerKB: fee * 1000 / int64(tx.SerializeSize()),
+	}
+}
+
+// TestNewBlockTemplateCPFP ensures block templates select transactions by the
+// fee rate of their packages so a child paying a high fee pulls its low fee
+// parent into the block, which improves the fees collected by the miner
+// compared to selecting transactions by their individual fee rates.
+func TestNewBlockTemplateCPFP(t *testing.T) {
+	t.Parallel()
+
+	source := &fakeTxSource{}
+	g, coinbases := newTestGenerator(t, source, 3)
+
+	// Create a low fee parent with a high fee child along with two
+	// unrelated transactions with a fee rate in between the two.
+	parent := newSpendTxDesc(coinbases[0], 100)
+	child := newSpendTxDesc(parent.Tx, 20000)
+	other1 := newSpendTxDesc(coinbases[1], 5000)
+	other2 := newSpendTxDesc(coinbases[2], 4900)
+
+	// Limit the block to room for only two of the transactions.
+	template, err := g.NewBlockTemplate(nil)
+	if err != nil {
+		t.Fatalf("unable to create block template: %v", err)
+	}
+	emptyWeight := blockHeaderOverhead*blockchain.WitnessScaleFactor +
+		blockchain.GetTransactionWeight(btcutil.NewTx(
+			template.Block.Transactions[0]))
+	txWeight := blockchain.GetTransactionWeight(parent.Tx)
+	g.policy.BlockMaxWeight = uint32(emptyWeight + 2*txWeight + 1)
+
+	tests := []struct {
+		name     string
+		descs    []*TxDesc
+		feeDelta int64
+		wantTxns []*TxDesc
+		wantFees int64
+	}{{
+		// Selecting by individual fee rates would only collect 9900
+		// from the two unrelated transactions.
+		name:     "child pays for parent",
+		descs:    []*TxDesc{other1, child, other2, parent},
+		wantTxns: []*TxDesc{parent, child},
+		wantFees: 20100,
+	}, {
+		name:     "package below unrelated transactions",
+		descs:    []*TxDesc{parent, other1, other2},
+		wantTxns: []*TxDesc{other1, other2},
+		wantFees: 9900,
+	}, {
+		// A fee delta on the parent is taken into account when
+		// selecting it, however only the actual fees are collected.
+		name:     "prioritised parent",
+		descs:    []*TxDesc{other1, other2, parent},
+		feeDelta: 50000,
+		wantTxns: []*TxDesc{parent, other1},
+		wantFees: 5100,
+	}}
+
+	for _, test := range tests {
+		parent.FeeDelta = test.feeDelta
+		source.descs = test.descs
+		template, err := g.NewBlockTemplate(nil)
+		if err != nil {
+			t.Fatalf("%s: unable to create block template: %v",
+				test.name, err)
+		}
+
+		txns := template.Block.Transactions[1:]
+		if len(txns) != len(test.wantTxns) {
+			t.Fatalf("%s: unexpected number of transactions: got "+
+				"%d, want %d", test.name, len(txns),
+				len(test.wantTxns))
+		}
+		for i, want := range test.wantTxns {
+			if txns[i].TxHash() != *want.Tx.Hash() {
+				t.Fatalf("%s: unexpected transaction %d: got "+
+					"%v, want %v", test.name, i,
+					txns[i].TxHash(), want.Tx.Hash())
+			}
+		}
+		if -template.Fees[0] != test.wantFees {
+			t.Fatalf("%s: unexpected fees: got %d, want %d",
+				test.name, -template.Fees[0], test.wantFees)
+		}
+	}
+}
+
+// TestNewBlockTemplateWithTxs ensures block templates created with specified
+// transactions contain exactly those transactions and reject transactions that
+// do not form a valid block.
+func TestNewBlockTemplateWithTxs(t *testing.T) {
+	t.Parallel()
+
+	source := &fakeTxSource{}
+	g, coinbases := newTestGenerator(t, source, 2)
+
+	// Transactions in the source are never included.
+	parent := newSpendTxDesc(coinbases[0], 1000)
+	child := newSpendTxDesc(parent.Tx, 2000)
+	other := newSpendTxDesc(coinbases[1], 50000)
+	source.descs = []*TxDesc{other}
+
+	template, err := g.NewBlockTemplateWithTxs(nil,
+		[]*btcutil.Tx{parent.Tx, child.Tx})
+	if err != nil {
+		t.Fatalf("unable to create block template: %v", err)
+	}
+	txns := template.Block.Transactions[1:]
+	if len(txns) != 2 || txns[0].TxHash() != *parent.Tx.Hash() ||
+		txns[1].TxHash() != *child.Tx.Hash() {
+
+		t.Fatalf("unexpected transactions in block template")
+	}
+	if -template.Fees[0] != 3000 {
+		t.Fatalf("unexpected fees: got %d, want 3000",
+			-template.Fees[0])
+	}
+	subsidy := blockchain.CalcBlockSubsidy(template.Height, g.chainParams)
+	coinbaseValue := template.Block.Transactions[0].TxOut[0].Value
+	if coinbaseValue != subsidy+3000 {
+		t.Fatalf("unexpected coinbase value: got %d, want %d",
+			coinbaseValue, subsidy+3000)
+	}
+
+	// An empty block only contains the coinbase.
+	template, err = g.NewBlockTemplateWithTxs(nil, nil)
+	if err != nil {
+		t.Fatalf("unable to create block template: %v", err)
+	}
+	if len(template.Block.Transactions) != 1 {
+		t.Fatalf("unexpected number of transactions: got %d, want 1",
+			len(template.Block.Transactions))
+	}
+
+	// Transactions must come after the ones they depend on and may only be
+	// included once.
+	invalid := [][]*btcutil.Tx{
+		{child.Tx, parent.Tx},
+		{parent.Tx, parent.Tx},
+		{btcutil.NewTx(template.Block.Transactions[0])},
+	}
+	for i, txns := range invalid {
+		if _, err := g.NewBlockTemplateWithTxs(nil, txns); err == nil {
+			t.Fatalf("%d: block template with invalid transactions "+
+				"was created", i)
+		}
+	}
+}
+
+// TestDistributeCoinbaseValue ensures the coinbase value is split between the
+// payouts according to their weights without losing any value.
+func TestDistributeCoinbaseValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		weights []uint32
+		value   int64
+		want    []int64
+	}{{
+		name:    "single payout",
+		weights: []uint32{5},
+		value:   5000000007,
+		want:    []int64{5000000007},
+	}, {
+		name:    "weighted payouts",
+		weights: []uint32{3, 1},
+		value:   1000,
+		want:    []int64{750, 250},
+	}, {
+		name:    "remainder to first payout",
+		weights: []uint32{1, 1, 1},
+		value:   100,
+		want:    []int64{34, 33, 33},
+	}, {
+		name:    "max weights",
+		weights: []uint32{math.MaxUint32, math.MaxUint32, 1},
+		value:   btcutil.MaxSatoshi,
+		want: []int64{1049999999877765, 1049999999877763,
+			244472},
+	}}
+
+	for _, test := range tests {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		payouts := make([]CoinbasePayout, 0, len(test.weights))
+		for _, weight := range test.weights {
+			tx.AddTxOut(wire.NewTxOut(0, nil))
+			payouts = append(payouts, CoinbasePayout{Weight: weight})
+		}
+		distributeCoinbaseValue(tx, payouts, test.value)
+
+		var total int64
+		for i, txOut := range tx.TxOut {
+			if txOut.Value != test.want[i] {
+				t.Fatalf("%s: unexpected value of payout %d: "+
+					"got %d, want %d", test.name, i,
+					txOut.Value, test.want[i])
+			}
+			total += txOut.Value
+		}
+		if total != test.value {
+			t.Fatalf("%s: unexpected total value: got %d, want %d",
+				test.name, total, test.value)
+		}
+	}
+}
+
+// TestNewBlockTemplateCoinbaseOutputs ensures block templates split the
+// coinbase between the configured payouts, include the coinbase tag and any
+// extra outputs, and account for the weight of the extra outputs.
+func TestNewBlockTemplateCoinbaseOutputs(t *testing.T) {
+	t.Parallel()
+
+	source := &fakeTxSource{}
+	g, coinbases := newTestGenerator(t, source, 1)
+
+	var payouts []CoinbasePayout
+	for i, weight := range []uint32{3, 1} {
+		addr, err := btcutil.NewAddressPubKeyHash(
+			bytes.Repeat([]byte{byte(i + 1)}, 20), g.chainParams)
+		if err != nil {
+			t.Fatalf("unable to create address: %v", err)
+		}
+		payouts = append(payouts, CoinbasePayout{
+			Address: addr,
+			Weight:  weight,
+		})
+	}
+	g.policy.CoinbaseTag = "/pool/"
+	g.policy.CoinbasePayouts = payouts
+
+	desc := newSpendTxDesc(coinbases[0], 4000)
+	source.descs = []*TxDesc{desc}
+	tag := []byte{txscript.OP_RETURN, 0x04, 't', 'e', 's', 't'}
+	extra := []*wire.TxOut{
+		wire.NewTxOut(0, tag),
+		wire.NewTxOut(1000, []byte{txscript.OP_CHECKSIG}),
+	}
+	template, err := g.NewBlockTemplateWithOutputs(nil, extra)
+	if err != nil {
+		t.Fatalf("unable to create block template: %v", err)
+	}
+	if !template.ValidPayAddress {
+		t.Fatalf("template with coinbase payouts has no valid payment " +
+			"address")
+	}
+	if len(template.Block.Transactions) != 2 {
+		t.Fatalf("unexpected number of transactions: got %d, want 2",
+			len(template.Block.Transactions))
+	}
+
+	// The payouts come first and split the subsidy and fees remaining
+	// after the extra outputs.
+	coinbase := template.Block.Transactions[0]
+	subsidy := blockchain.CalcBlockSubsidy(template.Height, g.chainParams)
+	value := subsidy + 4000 - 1000
+	if len(coinbase.TxOut) != 4 {
+		t.Fatalf("unexpected number of coinbase outputs: got %d, "+
+			"want 4", len(coinbase.TxOut))
+	}
+	for i, want := range []int64{value - value/4, value / 4} {
+		pkScript, _ := txscript.PayToAddrScript(payouts[i].Address)
+		txOut := coinbase.TxOut[i]
+		if txOut.Value != want || !bytes.Equal(txOut.PkScript, pkScript) {
+			t.Fatalf("unexpected payout %d: got %d to %x, want %d "+
+				"to %x", i, txOut.Value, txOut.PkScript, want,
+				pkScript)
+		}
+	}
+	for i, want := range extra {
+		txOut := coinbase.TxOut[i+2]
+		if txOut.Value != want.Value ||
+			!bytes.Equal(txOut.PkScript, want.PkScript) {
+
+			t.Fatalf("unexpected extra output %d: %v", i, txOut)
+		}
+	}
+	if !bytes.HasSuffix(coinbase.TxIn[0].SignatureScript,
+		[]byte(CoinbaseFlags+"/pool/")) {
+
+		t.Fatalf("coinbase script %x does not include the tag",
+			coinbase.TxIn[0].SignatureScript)
+	}
+	// Each payout and the extra output have a signature operation.
+	if template.SigOpCosts[0] != 3*blockchain.WitnessScaleFactor {
+		t.Fatalf("unexpected coinbase signature operations cost %d",
+			template.SigOpCosts[0])
+	}
+
+	// The extra nonce is updated without losing the tag.
+	if err := g.UpdateExtraNonce(template.Block, template.Height, 1); err != nil {
+		t.Fatalf("unable to update extra nonce: %v", err)
+	}
+	if !bytes.HasSuffix(coinbase.TxIn[0].SignatureScript,
+		[]byte(CoinbaseFlags+"/pool/")) {
+
+		t.Fatalf("updated coinbase script %x does not include the tag",
+			coinbase.TxIn[0].SignatureScript)
+	}
+
+	// The weight of large extra outputs leaves no room for the
+	// transaction, and the extra outputs can't exceed the block on their
+	// own or pay more than the subsidy.
+	large := []*wire.TxOut{wire.NewTxOut(0,
+		bytes.Repeat([]byte{txscript.OP_NOP}, 10000))}
+	source.descs = nil
+	template, err = g.NewBlockTemplateWithOutputs(nil, large)
+	if err != nil {
+		t.Fatalf("unable to create block template: %v", err)
+	}
+	emptyWeight := blockHeaderOverhead*blockchain.WitnessScaleFactor +
+		blockchain.GetTransactionWeight(btcutil.NewTx(
+			template.Block.Transactions[0]))
+	txWeight := blockchain.GetTransactionWeight(desc.Tx)
+	g.policy.BlockMaxWeight = uint32(emptyWeight + txWeight - 1)
+	source.descs = []*TxDesc{desc}
+	template, err = g.NewBlockTemplateWithOutputs(nil, large)
+	if err != nil {
+		t.Fatalf("unable to create block template: %v", err)
+	}
+	if len(template.Block.Transactions) != 1 {
+		t.Fatalf("unexpected number of transactions: got %d, want 1",
+			len(template.Block.Transactions))
+	}
+	g.policy.BlockMaxWeight = uint32(emptyWeight - 1)
+	if _, err := g.NewBlockTemplateWithOutputs(nil, large); err == nil {
+		t.Fatalf("block template exceeding the maximum weight was " +
+			"created")
+	}
+	g.policy.BlockMaxWeight = blockchain.MaxBlockWeight - 4000
+	excessive := []*wire.TxOut{wire.NewTxOut(subsidy+1, tag)}
+	if _, err := g.NewBlockTemplateWithOutputs(nil, excessive); err == nil {
+		t.Fatalf("block template paying more than the subsidy was " +
+			"created")
+	}
+}
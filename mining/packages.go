@@ -0,0 +1,339 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"bytes"
+	"container/heap"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// txPackageEntry houses a transaction from the source pool along with its
+// relationships to other transactions in the source pool and the aggregate
+// state of its ancestors that have not been selected yet.
+type txPackageEntry struct {
+	desc   *TxDesc
+	fee    int64
+	weight int64
+	vsize  int64
+
+	// parents and children hold the transactions in the source pool this
+	// one spends outputs from and that spend outputs of this one,
+	// respectively.
+	parents  []*txPackageEntry
+	children []*txPackageEntry
+
+	// ancestorFee and ancestorSize are the modified fee and virtual size
+	// of the transaction along with all of its ancestors which have not
+	// been selected yet.
+	ancestorFee  int64
+	ancestorSize int64
+
+	// generation is incremented whenever the ancestor state changes so
+	// that outdated entries in the package queue can be discarded.
+	generation uint64
+
+	selected bool
+	failed   bool
+}
+
+// txPackage houses a transaction along with all of its ancestors which have not
+// been selected yet.  The transactions are ordered such that every transaction
+// comes after the ones it depends on.
+type txPackage struct {
+	entries []*txPackageEntry
+	fee     int64
+	weight  int64
+	vsize   int64
+}
+
+// head returns the transaction the package was formed for, which is the last
+// transaction in the package.
+func (p *txPackage) head() *txPackageEntry {
+	return p.entries[len(p.entries)-1]
+}
+
+// feeRate returns the modified fee rate of the package in satoshis per virtual
+// byte.
+func (p *txPackage) feeRate() float64 {
+	return float64(p.fee) / float64(p.vsize)
+}
+
+// txPackageItem is an item in the package queue that snapshots the ancestor
+// state of an entry at the time it was queued.
+type txPackageItem struct {
+	entry        *txPackageEntry
+	ancestorFee  int64
+	ancestorSize int64
+	generation   uint64
+}
+
+// txPackageQueue implements a priority queue of txPackageItem elements ordered
+// by ancestor fee rate.
+type txPackageQueue []*txPackageItem
+
+// Len returns the number of items in the queue.  It is part of the
+// heap.Interface implementation.
+func (pq txPackageQueue) Len() int {
+	return len(pq)
+}
+
+// Less returns whether the item in the queue with index i should sort before
+// the item with index j.  Items with a higher ancestor fee rate sort first,
+// then smaller packages, and finally lower hashes in order to keep the
+// selection deterministic.  It is part of the heap.Interface implementation.
+func (pq txPackageQueue) Less(i, j int) bool {
+	a, b := pq[i], pq[j]
+	rateA := float64(a.ancestorFee) / float64(a.ancestorSize)
+	rateB := float64(b.ancestorFee) / float64(b.ancestorSize)
+	if rateA != rateB {
+		return rateA > rateB
+	}
+	if a.ancestorSize != b.ancestorSize {
+		return a.ancestorSize < b.ancestorSize
+	}
+	hashA, hashB := a.entry.desc.Tx.Hash(), b.entry.desc.Tx.Hash()
+	return bytes.Compare(hashA[:], hashB[:]) < 0
+}
+
+// Swap swaps the items at the passed indices in the queue.  It is part of the
+// heap.Interface implementation.
+func (pq txPackageQueue) Swap(i, j int) {
+	pq[i], pq[j] = pq[j], pq[i]
+}
+
+// Push pushes the passed item onto the queue.  It is part of the
+// heap.Interface implementation.
+func (pq *txPackageQueue) Push(x interface{}) {
+	*pq = append(*pq, x.(*txPackageItem))
+}
+
+// Pop removes the item with the highest ancestor fee rate from the queue and
+// returns it.  It is part of the heap.Interface implementation.
+func (pq *txPackageQueue) Pop() interface{} {
+	old := *pq
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*pq = old[:n-1]
+	return item
+}
+
+// txPackageSelector selects transactions from a source pool in packages of a
+// transaction along with its unselected ancestors, ordered by the modified fee
+// rate of the package.  This allows a high fee child to pull in the low fee
+// parents it depends on (child-pays-for-parent), while the fee rate of the
+// remaining descendants is updated as their ancestors get selected.
+type txPackageSelector struct {
+	entries map[chainhash.Hash]*txPackageEntry
+	ordered []*txPackageEntry
+	queue   txPackageQueue
+}
+
+// newTxPackageSelector returns a new package selector for the passed source
+// transactions.  Any coinbase transactions are ignored and only dependencies
+// between the passed transactions are considered, so any other inputs are
+// assumed to be available.
+func newTxPackageSelector(descs []*TxDesc) *txPackageSelector {
+	s := &txPackageSelector{
+		entries: make(map[chainhash.Hash]*txPackageEntry, len(descs)),
+		queue:   make(txPackageQueue, 0, len(descs)),
+	}
+
+	for _, desc := range descs {
+		if blockchain.IsCoinBase(desc.Tx) {
+			continue
+		}
+		weight := blockchain.GetTransactionWeight(desc.Tx)
+		entry := &txPackageEntry{
+			desc:   desc,
+			fee:    desc.ModifiedFee(),
+			weight: weight,
+			vsize: (weight + blockchain.WitnessScaleFactor - 1) /
+				blockchain.WitnessScaleFactor,
+		}
+		s.entries[*desc.Tx.Hash()] = entry
+		s.ordered = append(s.ordered, entry)
+	}
+
+	// Link the transactions which depend on one another.
+	for _, entry := range s.ordered {
+		seen := make(map[chainhash.Hash]struct{})
+		for _, txIn := range entry.desc.Tx.MsgTx().TxIn {
+			parentHash := txIn.PreviousOutPoint.Hash
+			parent, ok := s.entries[parentHash]
+			if !ok {
+				continue
+			}
+			if _, ok := seen[parentHash]; ok {
+				continue
+			}
+			seen[parentHash] = struct{}{}
+			entry.parents = append(entry.parents, parent)
+			parent.children = append(parent.children, entry)
+		}
+	}
+
+	// Calculate the ancestor state of every transaction and queue it.
+	for _, entry := range s.ordered {
+		entry.ancestorFee = entry.fee
+		entry.ancestorSize = entry.vsize
+		for _, ancestor := range s.ancestors(entry) {
+			entry.ancestorFee += ancestor.fee
+			entry.ancestorSize += ancestor.vsize
+		}
+		s.push(entry)
+	}
+
+	return s
+}
+
+// push adds the current ancestor state of the passed entry to the queue.
+func (s *txPackageSelector) push(entry *txPackageEntry) {
+	heap.Push(&s.queue, &txPackageItem{
+		entry:        entry,
+		ancestorFee:  entry.ancestorFee,
+		ancestorSize: entry.ancestorSize,
+		generation:   entry.generation,
+	})
+}
+
+// ancestors returns the unselected ancestors of the passed entry ordered such
+// that every transaction comes after the ones it depends on.
+func (s *txPackageSelector) ancestors(entry *txPackageEntry) []*txPackageEntry {
+	var ancestors []*txPackageEntry
+	visited := make(map[*txPackageEntry]struct{})
+	var visit func(e *txPackageEntry)
+	visit = func(e *txPackageEntry) {
+		for _, parent := range e.parents {
+			if parent.selected {
+				continue
+			}
+			if _, ok := visited[parent]; ok {
+				continue
+			}
+			visited[parent] = struct{}{}
+			visit(parent)
+			ancestors = append(ancestors, parent)
+		}
+	}
+	visit(entry)
+	return ancestors
+}
+
+// descendants returns all of the descendants of the passed entry.
+func (s *txPackageSelector) descendants(entry *txPackageEntry) []*txPackageEntry {
+	var descendants []*txPackageEntry
+	visited := make(map[*txPackageEntry]struct{})
+	stack := []*txPackageEntry{entry}
+	for len(stack) > 0 {
+		e := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, child := range e.children {
+			if _, ok := visited[child]; ok {
+				continue
+			}
+			visited[child] = struct{}{}
+			descendants = append(descendants, child)
+			stack = append(stack, child)
+		}
+	}
+	return descendants
+}
+
+// next returns the package with the highest modified fee rate which has not
+// been selected, skipped, or excluded yet.  It returns nil once there are no
+// more packages.
+func (s *txPackageSelector) next() *txPackage {
+	for s.queue.Len() > 0 {
+		item := heap.Pop(&s.queue).(*txPackageItem)
+		entry := item.entry
+		if entry.selected || entry.failed ||
+			item.generation != entry.generation {
+
+			continue
+		}
+
+		return s.packageFor(entry)
+	}
+
+	return nil
+}
+
+// packageFor returns the package consisting of the passed entry along with all
+// of its unselected ancestors.
+func (s *txPackageSelector) packageFor(entry *txPackageEntry) *txPackage {
+	pkg := &txPackage{
+		entries: append(s.ancestors(entry), entry),
+		fee:     entry.ancestorFee,
+		vsize:   entry.ancestorSize,
+	}
+	for _, e := range pkg.entries {
+		pkg.weight += e.weight
+	}
+	return pkg
+}
+
+// selectPackage marks all transactions in the passed package as selected and
+// updates the ancestor state of their descendants accordingly.
+func (s *txPackageSelector) selectPackage(pkg *txPackage) {
+	var updated []*txPackageEntry
+	seen := make(map[*txPackageEntry]struct{})
+	for _, entry := range pkg.entries {
+		entry.selected = true
+		for _, desc := range s.descendants(entry) {
+			desc.ancestorFee -= entry.fee
+			desc.ancestorSize -= entry.vsize
+			if _, ok := seen[desc]; !ok {
+				seen[desc] = struct{}{}
+				updated = append(updated, desc)
+			}
+		}
+	}
+
+	// Requeue the descendants which have not been selected along with the
+	// package with their new ancestor fee rates.
+	for _, desc := range updated {
+		if desc.selected {
+			continue
+		}
+		desc.generation++
+		if !desc.failed {
+			s.push(desc)
+		}
+	}
+}
+
+// skip marks the transaction the passed package was formed for as failed so
+// it is not considered again on its own.  Its descendants are still
+// considered, which allows them to include it in a larger package that might
+// have a higher fee rate.
+func (s *txPackageSelector) skip(pkg *txPackage) {
+	pkg.head().failed = true
+}
+
+// exclude marks the passed transaction and all of its descendants as failed so
+// none of them are considered again.  This is used for transactions which are
+// not valid for inclusion in a block.
+func (s *txPackageSelector) exclude(entry *txPackageEntry) {
+	entry.failed = true
+	for _, desc := range s.descendants(entry) {
+		desc.failed = true
+	}
+}
+
+// unselected returns the descriptors of all transactions which have not been
+// selected.
+func (s *txPackageSelector) unselected() []*TxDesc {
+	descs := make([]*TxDesc, 0, len(s.ordered))
+	for _, entry := range s.ordered {
+		if !entry.selected {
+			descs = append(descs, entry.desc)
+		}
+	}
+	return descs
+}
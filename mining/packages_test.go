@@ -0,0 +1,204 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// newTestTxDesc returns a mining descriptor for a fake transaction that spends
+// the first output of each of the passed parents, pays the passed fee, and has
+// a virtual size of roughly the passed size.  Transactions without parents
+// spend a unique fake outpoint.
+func newTestTxDesc(id uint32, fee int64, size int, parents ...*TxDesc) *TxDesc {
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if len(parents) == 0 {
+		var hash chainhash.Hash
+		hash[0] = byte(id)
+		hash[1] = byte(id >> 8)
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&hash, 0), nil, nil))
+	}
+	for _, parent := range parents {
+		prevOut := wire.NewOutPoint(parent.Tx.Hash(), 0)
+		tx.AddTxIn(wire.NewTxIn(prevOut, nil, nil))
+	}
+
+	// Pad the output script so the transaction has the requested size.
+	padding := size - tx.SerializeSize() - 10
+	if padding < 0 {
+		padding = 0
+	}
+	tx.AddTxOut(wire.NewTxOut(int64(id), make([]byte, padding)))
+
+	btcTx := btcutil.NewTx(tx)
+	return &TxDesc{
+		Tx:       btcTx,
+		Fee:      fee,
+		FeePerKB: fee * 1000 / int64(tx.SerializeSize()),
+	}
+}
+
+// selectAll selects every package from a new selector for the passed
+// descriptors and returns the transaction hashes in order of selection.
+func selectAll(descs []*TxDesc) []chainhash.Hash {
+	var selected []chainhash.Hash
+	selector := newTxPackageSelector(descs)
+	for pkg := selector.next(); pkg != nil; pkg = selector.next() {
+		selector.selectPackage(pkg)
+		for _, entry := range pkg.entries {
+			selected = append(selected, *entry.desc.Tx.Hash())
+		}
+	}
+	return selected
+}
+
+// TestTxPackageSelector ensures transactions are selected by the fee rate of
+// their packages and that descendants are updated as their ancestors are
+// selected.
+func TestTxPackageSelector(t *testing.T) {
+	t.Parallel()
+
+	// A low fee parent with a high fee child must be selected before an
+	// unrelated transaction with a fee rate in between the two.
+	parent := newTestTxDesc(1, 100, 1000)
+	child := newTestTxDesc(2, 20000, 1000, parent)
+	other := newTestTxDesc(3, 5000, 1000)
+	got := selectAll([]*TxDesc{other, child, parent})
+	want := []chainhash.Hash{*parent.Tx.Hash(), *child.Tx.Hash(),
+		*other.Tx.Hash()}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected selection order: got %v, want %v", got,
+			want)
+	}
+
+	// Once a high fee parent is selected, its low fee child is evaluated
+	// on its own rather than along with the parent.
+	parent = newTestTxDesc(4, 20000, 1000)
+	child = newTestTxDesc(5, 100, 1000, parent)
+	other = newTestTxDesc(6, 5000, 1000)
+	got = selectAll([]*TxDesc{child, other, parent})
+	want = []chainhash.Hash{*parent.Tx.Hash(), *other.Tx.Hash(),
+		*child.Tx.Hash()}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected selection order: got %v, want %v", got,
+			want)
+	}
+
+	// Fee deltas are taken into account.
+	parent = newTestTxDesc(7, 100, 1000)
+	parent.FeeDelta = 50000
+	other = newTestTxDesc(8, 5000, 1000)
+	got = selectAll([]*TxDesc{other, parent})
+	want = []chainhash.Hash{*parent.Tx.Hash(), *other.Tx.Hash()}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected selection order: got %v, want %v", got,
+			want)
+	}
+
+	// Excluded transactions and their descendants are never selected
+	// while skipped transactions may still be selected as part of a
+	// descendant's package.
+	parent = newTestTxDesc(9, 100, 1000)
+	child = newTestTxDesc(10, 20000, 1000, parent)
+	other = newTestTxDesc(11, 5000, 1000)
+	selector := newTxPackageSelector([]*TxDesc{parent, child, other})
+	pkg := selector.next()
+	if pkg.head().desc != child || len(pkg.entries) != 2 {
+		t.Fatalf("unexpected package for tx %v", pkg.head().desc.Tx.Hash())
+	}
+	selector.exclude(pkg.entries[0])
+	pkg = selector.next()
+	if pkg.head().desc != other {
+		t.Fatalf("unexpected package for tx %v", pkg.head().desc.Tx.Hash())
+	}
+	selector.selectPackage(pkg)
+	if pkg = selector.next(); pkg != nil {
+		t.Fatalf("unexpected package for tx %v", pkg.head().desc.Tx.Hash())
+	}
+
+	selector = newTxPackageSelector([]*TxDesc{parent, child, other})
+	parentPkg := &txPackage{entries: []*txPackageEntry{
+		selector.entries[*parent.Tx.Hash()],
+	}}
+	selector.skip(parentPkg)
+	pkg = selector.next()
+	if pkg.head().desc != child || len(pkg.entries) != 2 {
+		t.Fatalf("unexpected package for tx %v", pkg.head().desc.Tx.Hash())
+	}
+}
+
+// TestProjectBlocks ensures blocks are projected from the source transactions
+// as expected.
+func TestProjectBlocks(t *testing.T) {
+	t.Parallel()
+
+	// Create transactions paying 1 through 10 sat/vB along with a low fee
+	// parent which is paid for by its child.
+	var descs []*TxDesc
+	for i := 1; i <= 10; i++ {
+		descs = append(descs, newTestTxDesc(uint32(i), int64(i)*1000,
+			1000))
+	}
+	parent := newTestTxDesc(100, 0, 1000)
+	child := newTestTxDesc(101, 19000, 1000, parent)
+	descs = append(descs, parent, child)
+
+	// Allow for roughly four transactions per block.
+	maxWeight := uint32(blockHeaderOverhead*4 + projectedCoinbaseWeight +
+		4*4000 + 100)
+
+	blocks := projectBlocks(descs, maxWeight, 2)
+	if len(blocks) != 2 {
+		t.Fatalf("unexpected number of projected blocks: got %d, "+
+			"want 2", len(blocks))
+	}
+
+	// The first block contains the parent and child at 9.5 sat/vB along
+	// with the 10 and 9 sat/vB transactions.
+	block := blocks[0]
+	if block.NumTxns != 4 || block.Fees != 38000 {
+		t.Fatalf("unexpected first block: %d txns, %d fees",
+			block.NumTxns, block.Fees)
+	}
+	if block.MinFeeRate < 8.9 || block.MinFeeRate > 9.1 ||
+		block.MaxFeeRate < 9.9 || block.MaxFeeRate > 10.1 {
+
+		t.Fatalf("unexpected first block fee rates: min %v, max %v",
+			block.MinFeeRate, block.MaxFeeRate)
+	}
+	percentiles := block.FeeRatePercentiles
+	if len(percentiles) != len(ProjectedFeeRatePercentiles) {
+		t.Fatalf("unexpected number of percentiles: %d",
+			len(percentiles))
+	}
+	if percentiles[0] != block.MinFeeRate ||
+		percentiles[len(percentiles)-1] != block.MaxFeeRate {
+
+		t.Fatalf("unexpected first block percentiles: %v", percentiles)
+	}
+
+	// The second block contains the 5 through 8 sat/vB transactions.
+	block = blocks[1]
+	if block.NumTxns != 4 || block.Fees != 26000 {
+		t.Fatalf("unexpected second block: %d txns, %d fees",
+			block.NumTxns, block.Fees)
+	}
+
+	// Asking for more blocks than there are transactions only returns the
+	// blocks that can be filled.
+	blocks = projectBlocks(descs, maxWeight, 10)
+	if len(blocks) != 3 {
+		t.Fatalf("unexpected number of projected blocks: got %d, "+
+			"want 3", len(blocks))
+	}
+	if blocks[2].NumTxns != 4 {
+		t.Fatalf("unexpected third block: %d txns", blocks[2].NumTxns)
+	}
+}
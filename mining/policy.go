@@ -45,6 +45,28 @@ type Policy struct {
 	// required for a transaction to be treated as free for mining purposes
 	// (block template generation).
 	TxMinFreeFee btcutil.Amount
+
+	// CoinbaseTag is appended to the coinbase flags in the signature script
+	// of the coinbase transaction of generated blocks.  It may be at most
+	// MaxCoinbaseTagLen bytes.
+	CoinbaseTag string
+
+	// CoinbasePayouts is a list of addresses the coinbase transaction of
+	// block templates created without a payment address is split between
+	// according to their weights.  The coinbase is redeemable by anyone
+	// when it is empty.
+	CoinbasePayouts []CoinbasePayout
+}
+
+// CoinbasePayout describes an address paid a share of the value of a coinbase
+// transaction proportional to its weight relative to the total weight of all
+// payouts.
+type CoinbasePayout struct {
+	// Address is the address the payout is made to.
+	Address btcutil.Address
+
+	// Weight is the weight of the payout.  It must be nonzero.
+	Weight uint32
 }
 
 // minInt is a helper function to return the minimum of two ints.  This avoids
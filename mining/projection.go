@@ -0,0 +1,147 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"sort"
+
+	"github.com/btcsuite/btcd/blockchain"
+)
+
+const (
+	// projectedCoinbaseWeight is the weight reserved for the coinbase
+	// transaction of a projected block.
+	projectedCoinbaseWeight = 4000
+)
+
+// ProjectedFeeRatePercentiles are the percentiles, weighted by virtual size,
+// of the fee rates reported for each projected block.
+var ProjectedFeeRatePercentiles = []float64{10, 25, 50, 75, 90}
+
+// ProjectedBlock describes a block that is expected to be mined in the future
+// based on the transactions currently in the source pool.
+type ProjectedBlock struct {
+	// NumTxns is the number of transactions in the block excluding the
+	// coinbase.
+	NumTxns int
+
+	// Weight is the total weight of the transactions in the block.
+	Weight int64
+
+	// Fees is the total fee paid by the transactions in the block.
+	Fees int64
+
+	// MinFeeRate and MaxFeeRate are the lowest and highest effective fee
+	// rates of the transactions in the block in satoshis per virtual byte.
+	MinFeeRate float64
+	MaxFeeRate float64
+
+	// FeeRatePercentiles holds the effective fee rates, in satoshis per
+	// virtual byte, at each of the ProjectedFeeRatePercentiles.
+	FeeRatePercentiles []float64
+}
+
+// projectedFeeRate houses the effective fee rate of a transaction within a
+// projected block along with its virtual size.
+type projectedFeeRate struct {
+	feeRate float64
+	vsize   int64
+}
+
+// feeRatePercentiles returns the passed fee rates at each of the
+// ProjectedFeeRatePercentiles, weighted by the virtual size of the
+// transactions.  The passed slice is sorted in place.
+func feeRatePercentiles(feeRates []projectedFeeRate) []float64 {
+	sort.Slice(feeRates, func(i, j int) bool {
+		return feeRates[i].feeRate < feeRates[j].feeRate
+	})
+
+	var totalSize int64
+	for _, fr := range feeRates {
+		totalSize += fr.vsize
+	}
+
+	percentiles := make([]float64, len(ProjectedFeeRatePercentiles))
+	var cumulative int64
+	next := 0
+	for _, fr := range feeRates {
+		cumulative += fr.vsize
+		for next < len(percentiles) && float64(cumulative) >=
+			float64(totalSize)*ProjectedFeeRatePercentiles[next]/100 {
+
+			percentiles[next] = fr.feeRate
+			next++
+		}
+	}
+
+	return percentiles
+}
+
+// projectBlocks fills up to numBlocks blocks of the given max weight with the
+// passed source transactions, selecting them by ancestor fee rate just like
+// block templates are.  Each transaction is assigned the fee rate of the
+// package it was selected with as its effective fee rate.
+func projectBlocks(descs []*TxDesc, maxWeight uint32, numBlocks int) []*ProjectedBlock {
+	blocks := make([]*ProjectedBlock, 0, numBlocks)
+	for len(blocks) < numBlocks && len(descs) > 0 {
+		selector := newTxPackageSelector(descs)
+		blockWeight := int64(blockHeaderOverhead*blockchain.WitnessScaleFactor +
+			projectedCoinbaseWeight)
+
+		block := &ProjectedBlock{}
+		var feeRates []projectedFeeRate
+		for pkg := selector.next(); pkg != nil; pkg = selector.next() {
+			if blockWeight+pkg.weight >= int64(maxWeight) {
+				selector.skip(pkg)
+				continue
+			}
+			selector.selectPackage(pkg)
+
+			feeRate := pkg.feeRate()
+			for _, entry := range pkg.entries {
+				block.NumTxns++
+				block.Weight += entry.weight
+				block.Fees += entry.desc.Fee
+				feeRates = append(feeRates, projectedFeeRate{
+					feeRate: feeRate,
+					vsize:   entry.vsize,
+				})
+			}
+			blockWeight += pkg.weight
+		}
+
+		// Nothing else can be projected when none of the remaining
+		// transactions fit in a block.
+		if block.NumTxns == 0 {
+			break
+		}
+
+		block.FeeRatePercentiles = feeRatePercentiles(feeRates)
+		block.MinFeeRate = feeRates[0].feeRate
+		block.MaxFeeRate = feeRates[len(feeRates)-1].feeRate
+		blocks = append(blocks, block)
+
+		descs = selector.unselected()
+	}
+
+	return blocks
+}
+
+// ProjectBlocks returns up to numBlocks blocks which are expected to be mined
+// next based on the transactions currently in the source pool.  The blocks are
+// built with the same ancestor fee rate selection that is used for block
+// templates, so a transaction with a low fee is projected along with any
+// descendants which pay for it.  Fewer blocks are returned when the source
+// pool does not contain enough transactions.
+//
+// Since the projection only depends on the source pool, it reacts to changes
+// in the fee market as soon as transactions are accepted rather than once they
+// are confirmed.
+//
+// This function is safe for concurrent access.
+func (g *BlkTmplGenerator) ProjectBlocks(numBlocks int) []*ProjectedBlock {
+	return projectBlocks(g.txSource.MiningDescs(), g.policy.BlockMaxWeight,
+		numBlocks)
+}
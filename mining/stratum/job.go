@@ -0,0 +1,324 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stratum
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/mining"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// ExtraNonce1Size is the size in bytes of the extra nonce assigned to
+	// each client by the server.
+	ExtraNonce1Size = 4
+
+	// ExtraNonce2Size is the size in bytes of the extra nonce that is
+	// rolled by the clients.
+	ExtraNonce2Size = 4
+
+	// maxFutureBlockTime is the maximum number of seconds the timestamp of
+	// a submitted share may be ahead of the current time.
+	maxFutureBlockTime = 2 * 60 * 60
+)
+
+var (
+	// diff1Target is the target of a share with a difficulty of 1 as used
+	// by stratum mining software.
+	diff1Target = new(big.Int).Lsh(big.NewInt(0xffff), 208)
+)
+
+// DifficultyToTarget returns the target a hash must not exceed in order to
+// meet the passed share difficulty.
+func DifficultyToTarget(difficulty float64) *big.Int {
+	target, _ := new(big.Float).Quo(new(big.Float).SetInt(diff1Target),
+		big.NewFloat(difficulty)).Int(nil)
+	return target
+}
+
+// TargetToDifficulty returns the share difficulty for the passed target.
+func TargetToDifficulty(target *big.Int) float64 {
+	difficulty, _ := new(big.Float).Quo(new(big.Float).SetInt(diff1Target),
+		new(big.Float).SetInt(target)).Float64()
+	return difficulty
+}
+
+// coinbaseScript returns the signature script for the coinbase transaction
+// of a block at the passed height with the passed coinbase tag along with the
+// offset of the extra nonces within the script.  The extra nonces are pushed
+// as a single data push with all zero bytes as a placeholder.
+func coinbaseScript(height int32, tag string) ([]byte, int, error) {
+	prefix, err := txscript.NewScriptBuilder().AddInt64(int64(height)).
+		Script()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var placeholder [ExtraNonce1Size + ExtraNonce2Size]byte
+	script, err := txscript.NewScriptBuilder().AddInt64(int64(height)).
+		AddData(placeholder[:]).AddData(mining.CoinbaseFlagsWithTag(tag)).
+		Script()
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(script) > blockchain.MaxCoinbaseScriptLen {
+		return nil, 0, fmt.Errorf("coinbase transaction script length "+
+			"of %d is out of range (min: %d, max: %d)", len(script),
+			blockchain.MinCoinbaseScriptLen,
+			blockchain.MaxCoinbaseScriptLen)
+	}
+
+	// The extra nonces follow the height and the data push opcode.
+	return script, len(prefix) + 1, nil
+}
+
+// splitCoinbase returns the serialized coinbase transaction without witness
+// data split into the parts that come before and after the extra nonces.  The
+// signature script of the passed coinbase transaction is replaced with one
+// that holds a placeholder for the extra nonces and the passed coinbase tag.
+func splitCoinbase(coinbaseTx *wire.MsgTx, height int32, tag string) ([]byte, []byte, error) {
+	script, offset, err := coinbaseScript(height, tag)
+	if err != nil {
+		return nil, nil, err
+	}
+	coinbaseTx.TxIn[0].SignatureScript = script
+
+	var buf bytes.Buffer
+	buf.Grow(coinbaseTx.SerializeSizeStripped())
+	if err := coinbaseTx.SerializeNoWitness(&buf); err != nil {
+		return nil, nil, err
+	}
+	serialized := buf.Bytes()
+
+	// The signature script follows the version, the input count, the
+	// previous outpoint, and the script length.
+	scriptStart := 4 + wire.VarIntSerializeSize(1) + 36 +
+		wire.VarIntSerializeSize(uint64(len(script)))
+	start := scriptStart + offset
+	end := start + ExtraNonce1Size + ExtraNonce2Size
+	return serialized[:start], serialized[end:], nil
+}
+
+// merkleBranch returns the hashes needed to calculate the merkle root of a
+// block from the hash of its coinbase transaction given the passed
+// transactions, which exclude the coinbase.
+func merkleBranch(txns []*btcutil.Tx) []chainhash.Hash {
+	// The first entry is a placeholder for the coinbase, which is never
+	// included in the branch.
+	level := make([]*chainhash.Hash, 1, len(txns)+1)
+	for _, tx := range txns {
+		level = append(level, tx.Hash())
+	}
+
+	var branch []chainhash.Hash
+	for len(level) > 1 {
+		branch = append(branch, *level[1])
+		if len(level)%2 != 0 {
+			level = append(level, level[len(level)-1])
+		}
+
+		next := make([]*chainhash.Hash, 1, len(level)/2)
+		for i := 2; i < len(level); i += 2 {
+			var concat [chainhash.HashSize * 2]byte
+			copy(concat[:chainhash.HashSize], level[i][:])
+			copy(concat[chainhash.HashSize:], level[i+1][:])
+			hash := chainhash.DoubleHashH(concat[:])
+			next = append(next, &hash)
+		}
+		level = next
+	}
+
+	return branch
+}
+
+// merkleRoot returns the merkle root of a block given the hash of its
+// coinbase transaction and the merkle branch of the remaining transactions.
+func merkleRoot(coinbaseHash chainhash.Hash, branch []chainhash.Hash) chainhash.Hash {
+	root := coinbaseHash
+	for i := range branch {
+		var concat [chainhash.HashSize * 2]byte
+		copy(concat[:chainhash.HashSize], root[:])
+		copy(concat[chainhash.HashSize:], branch[i][:])
+		root = chainhash.DoubleHashH(concat[:])
+	}
+	return root
+}
+
+// uint32Hex returns the passed value as a big-endian hex string as used by
+// stratum for the version, bits, time, and nonce fields.
+func uint32Hex(v uint32) string {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	return hex.EncodeToString(b[:])
+}
+
+// parseUint32Hex parses a big-endian hex string as used by stratum for the
+// version, bits, time, and nonce fields.
+func parseUint32Hex(s string) (uint32, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 4 {
+		return 0, fmt.Errorf("invalid 32-bit hex value %q", s)
+	}
+	return binary.BigEndian.Uint32(b), nil
+}
+
+// prevHashHex returns the passed previous block hash in the form expected by
+// stratum mining software, which is the serialized hash with the bytes of each
+// 32-bit word reversed.
+func prevHashHex(hash *chainhash.Hash) string {
+	var b [chainhash.HashSize]byte
+	for i := 0; i < chainhash.HashSize; i += 4 {
+		b[i], b[i+1], b[i+2], b[i+3] = hash[i+3], hash[i+2], hash[i+1],
+			hash[i]
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// job houses a block template that is handed out to clients as a mining job
+// along with the data needed to rebuild blocks from submitted shares.
+type job struct {
+	id       string
+	template *mining.BlockTemplate
+	height   int32
+	coinb1   []byte
+	coinb2   []byte
+	branch   []chainhash.Hash
+	target   *big.Int
+	minTime  time.Time
+	created  time.Time
+	txUpdate time.Time
+
+	mtx         sync.Mutex
+	submissions map[string]struct{}
+}
+
+// newJob returns a new job for the passed block template.  The signature
+// script of the template coinbase is replaced with one that holds a
+// placeholder for the extra nonces and the passed coinbase tag.
+func newJob(id string, template *mining.BlockTemplate, tag string,
+	minTime, txUpdate time.Time) (*job, error) {
+
+	msgBlock := template.Block
+	coinb1, coinb2, err := splitCoinbase(msgBlock.Transactions[0],
+		template.Height, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	txns := make([]*btcutil.Tx, 0, len(msgBlock.Transactions)-1)
+	for _, tx := range msgBlock.Transactions[1:] {
+		txns = append(txns, btcutil.NewTx(tx))
+	}
+
+	return &job{
+		id:          id,
+		template:    template,
+		height:      template.Height,
+		coinb1:      coinb1,
+		coinb2:      coinb2,
+		branch:      merkleBranch(txns),
+		target:      blockchain.CompactToBig(msgBlock.Header.Bits),
+		minTime:     minTime,
+		created:     time.Now(),
+		txUpdate:    txUpdate,
+		submissions: make(map[string]struct{}),
+	}, nil
+}
+
+// notifyParams returns the parameters of the mining.notify message for the
+// job.
+func (j *job) notifyParams(cleanJobs bool) []interface{} {
+	branch := make([]string, 0, len(j.branch))
+	for i := range j.branch {
+		branch = append(branch, hex.EncodeToString(j.branch[i][:]))
+	}
+
+	header := &j.template.Block.Header
+	return []interface{}{
+		j.id,
+		prevHashHex(&header.PrevBlock),
+		hex.EncodeToString(j.coinb1),
+		hex.EncodeToString(j.coinb2),
+		branch,
+		uint32Hex(uint32(header.Version)),
+		uint32Hex(header.Bits),
+		uint32Hex(uint32(header.Timestamp.Unix())),
+		cleanJobs,
+	}
+}
+
+// header returns the block header resulting from the passed share
+// parameters.
+func (j *job) header(extraNonce1, extraNonce2 []byte, nTime,
+	nonce uint32) wire.BlockHeader {
+
+	coinbase := make([]byte, 0, len(j.coinb1)+len(extraNonce1)+
+		len(extraNonce2)+len(j.coinb2))
+	coinbase = append(coinbase, j.coinb1...)
+	coinbase = append(coinbase, extraNonce1...)
+	coinbase = append(coinbase, extraNonce2...)
+	coinbase = append(coinbase, j.coinb2...)
+
+	header := j.template.Block.Header
+	header.MerkleRoot = merkleRoot(chainhash.DoubleHashH(coinbase), j.branch)
+	header.Timestamp = time.Unix(int64(nTime), 0)
+	header.Nonce = nonce
+	return header
+}
+
+// block returns the block resulting from the passed share parameters.
+func (j *job) block(extraNonce1, extraNonce2 []byte, nTime,
+	nonce uint32) *btcutil.Block {
+
+	template := j.template.Block
+	msgBlock := wire.MsgBlock{
+		Header: j.header(extraNonce1, extraNonce2, nTime, nonce),
+		Transactions: make([]*wire.MsgTx, 0,
+			len(template.Transactions)),
+	}
+
+	// Insert the extra nonces into a copy of the coinbase.
+	coinbaseTx := template.Transactions[0].Copy()
+	script := coinbaseTx.TxIn[0].SignatureScript
+	// The offset of the extra nonces does not depend on the tag.
+	_, offset, _ := coinbaseScript(j.height, "")
+	copy(script[offset:], extraNonce1)
+	copy(script[offset+len(extraNonce1):], extraNonce2)
+	msgBlock.Transactions = append(msgBlock.Transactions, coinbaseTx)
+	msgBlock.Transactions = append(msgBlock.Transactions,
+		template.Transactions[1:]...)
+
+	block := btcutil.NewBlock(&msgBlock)
+	block.SetHeight(j.height)
+	return block
+}
+
+// recordSubmission records the passed share parameters and returns whether or
+// not they were already submitted for the job.
+func (j *job) recordSubmission(extraNonce1, extraNonce2 []byte, nTime,
+	nonce uint32) bool {
+
+	key := fmt.Sprintf("%x:%x:%08x:%08x", extraNonce1, extraNonce2, nTime,
+		nonce)
+
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+	if _, ok := j.submissions[key]; ok {
+		return true
+	}
+	j.submissions[key] = struct{}{}
+	return false
+}
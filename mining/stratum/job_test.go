@@ -0,0 +1,135 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stratum
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/mining"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestMerkleBranch ensures the merkle root calculated from the coinbase hash
+// and the merkle branch matches the merkle root of the full block.
+func TestMerkleBranch(t *testing.T) {
+	t.Parallel()
+
+	for numTxns := 1; numTxns <= 12; numTxns++ {
+		txns := make([]*btcutil.Tx, 0, numTxns)
+		for i := 0; i < numTxns; i++ {
+			tx := wire.NewMsgTx(wire.TxVersion)
+			tx.AddTxOut(wire.NewTxOut(int64(i), nil))
+			txns = append(txns, btcutil.NewTx(tx))
+		}
+
+		merkles := blockchain.BuildMerkleTreeStore(txns, false)
+		want := *merkles[len(merkles)-1]
+		got := merkleRoot(*txns[0].Hash(), merkleBranch(txns[1:]))
+		if got != want {
+			t.Fatalf("merkle root mismatch with %d transactions: "+
+				"got %v, want %v", numTxns, got, want)
+		}
+	}
+}
+
+// TestSplitCoinbase ensures the coinbase is split around the extra nonces such
+// that joining the parts with the extra nonces results in the expected
+// coinbase transaction.
+func TestSplitCoinbase(t *testing.T) {
+	t.Parallel()
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		Sequence: wire.MaxTxInSequenceNum,
+	})
+	tx.AddTxOut(wire.NewTxOut(5000000000, []byte{0x51}))
+
+	const height = 123456
+	const tag = "/pool/"
+	coinb1, coinb2, err := splitCoinbase(tx, height, tag)
+	if err != nil {
+		t.Fatalf("splitCoinbase: %v", err)
+	}
+
+	extraNonce1 := []byte{0x01, 0x02, 0x03, 0x04}
+	extraNonce2 := []byte{0x05, 0x06, 0x07, 0x08}
+	var serialized []byte
+	serialized = append(serialized, coinb1...)
+	serialized = append(serialized, extraNonce1...)
+	serialized = append(serialized, extraNonce2...)
+	serialized = append(serialized, coinb2...)
+
+	var joined wire.MsgTx
+	if err := joined.Deserialize(bytes.NewReader(serialized)); err != nil {
+		t.Fatalf("unable to deserialize joined coinbase: %v", err)
+	}
+	script := joined.TxIn[0].SignatureScript
+	_, offset, err := coinbaseScript(height, tag)
+	if err != nil {
+		t.Fatalf("coinbaseScript: %v", err)
+	}
+	got := script[offset : offset+ExtraNonce1Size+ExtraNonce2Size]
+	want := append(extraNonce1[:len(extraNonce1):len(extraNonce1)],
+		extraNonce2...)
+	if !bytes.Equal(got, want) {
+		t.Fatalf("unexpected extra nonces in script: got %x, want %x",
+			got, want)
+	}
+	if !bytes.HasSuffix(script, mining.CoinbaseFlagsWithTag(tag)) {
+		t.Fatalf("coinbase script %x does not end with the tag", script)
+	}
+	gotHeight, err := blockchain.ExtractCoinbaseHeight(
+		btcutil.NewTx(&joined))
+	if err != nil || gotHeight != height {
+		t.Fatalf("unexpected coinbase height %d: %v", gotHeight, err)
+	}
+}
+
+// TestDifficulty ensures converting between share difficulties and targets
+// works as expected.
+func TestDifficulty(t *testing.T) {
+	t.Parallel()
+
+	target := DifficultyToTarget(1)
+	want := blockchain.CompactToBig(0x1d00ffff)
+	if target.Cmp(want) != 0 {
+		t.Fatalf("unexpected difficulty 1 target: got %064x, want "+
+			"%064x", target, want)
+	}
+
+	target = DifficultyToTarget(4)
+	want = new(big.Int).Rsh(want, 2)
+	if target.Cmp(want) != 0 {
+		t.Fatalf("unexpected difficulty 4 target: got %064x, want "+
+			"%064x", target, want)
+	}
+	if difficulty := TargetToDifficulty(want); difficulty != 4 {
+		t.Fatalf("unexpected difficulty: got %v, want 4", difficulty)
+	}
+}
+
+// TestPrevHashHex ensures the previous block hash is encoded with the bytes of
+// each 32-bit word reversed.
+func TestPrevHashHex(t *testing.T) {
+	t.Parallel()
+
+	var hash chainhash.Hash
+	for i := range hash {
+		hash[i] = byte(i)
+	}
+	got := prevHashHex(&hash)
+	want := "03020100070605040b0a09080f0e0d0c" +
+		"13121110171615141b1a19181f1e1d1c"
+	if got != want {
+		t.Fatalf("unexpected previous hash: got %s, want %s", got, want)
+	}
+}
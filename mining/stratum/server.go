@@ -0,0 +1,911 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stratum
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/mining"
+)
+
+const (
+	// DefaultDifficulty is the default initial share difficulty assigned to
+	// clients.
+	DefaultDifficulty = 1
+
+	// DefaultShareInterval is the default time between the shares of a
+	// client that the variable difficulty targets.
+	DefaultShareInterval = 10 * time.Second
+
+	// minDifficulty is the lowest share difficulty the variable difficulty
+	// may assign to a client.
+	minDifficulty = 1.0 / 1024
+
+	// vardiffWindowShares is the number of shares per variable difficulty
+	// retarget window.  A client's difficulty is retargeted at the end of
+	// each window, or early once it submits twice as many shares.
+	vardiffWindowShares = 10
+
+	// maxRetargetFactor is the maximum factor by which a client's
+	// difficulty is changed at once.
+	maxRetargetFactor = 4
+
+	// jobCheckInterval is the interval at which the server checks whether a
+	// new job needs to be created.
+	jobCheckInterval = time.Second
+
+	// jobRefreshInterval is the minimum time between jobs for the same
+	// previous block which are created to include new transactions.
+	jobRefreshInterval = 30 * time.Second
+
+	// maxJobs is the maximum number of jobs for the same previous block
+	// that are kept around to accept shares for.
+	maxJobs = 16
+
+	// maxMessageSize is the maximum size of a message sent by a client.
+	maxMessageSize = 16 * 1024
+
+	// clientIdleTimeout is the time after which a client that doesn't send
+	// any messages is disconnected.
+	clientIdleTimeout = 10 * time.Minute
+
+	// clientWriteTimeout is the time allowed to write a message to a
+	// client.
+	clientWriteTimeout = 30 * time.Second
+)
+
+// Error codes returned to clients for failed requests.
+const (
+	errCodeOther         = 20
+	errCodeJobNotFound   = 21
+	errCodeDuplicate     = 22
+	errCodeLowDifficulty = 23
+	errCodeUnauthorized  = 24
+	errCodeNotSubscribed = 25
+)
+
+// Error describes an error returned to a client in response to a request.
+type Error struct {
+	Code    int
+	Message string
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d: %s", e.Code, e.Message)
+}
+
+// MarshalJSON encodes the error as a stratum error array.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.Code, e.Message, nil})
+}
+
+// request models a request sent by a client.
+type request struct {
+	ID     interface{}       `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// response models a response to a request sent by a client.
+type response struct {
+	ID     interface{} `json:"id"`
+	Result interface{} `json:"result"`
+	Error  *Error      `json:"error"`
+}
+
+// notification models a message sent to a client which is not a response to
+// one of its requests.
+type notification struct {
+	ID     interface{}   `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+// Config is a descriptor containing the stratum server configuration.
+type Config struct {
+	// ChainParams identifies which chain parameters the stratum server is
+	// associated with.
+	ChainParams *chaincfg.Params
+
+	// BlockTemplateGenerator identifies the instance to use in order to
+	// generate the block templates jobs are built from.
+	BlockTemplateGenerator *mining.BlkTmplGenerator
+
+	// MiningAddrs is a list of payment addresses to use for the generated
+	// blocks.  Each job will randomly choose one of them.  When it is empty,
+	// the coinbase payouts of the block template generator policy are used
+	// instead.
+	MiningAddrs []btcutil.Address
+
+	// ProcessBlock defines the function to call with any solved blocks.
+	// It typically must run the provided block through the same set of
+	// rules and handling as any other block coming from the network.
+	ProcessBlock func(*btcutil.Block, blockchain.BehaviorFlags) (bool, error)
+
+	// Listeners defines a slice of listeners for which the stratum server
+	// will take ownership of and accept connections.
+	Listeners []net.Listener
+
+	// Difficulty is the initial share difficulty assigned to clients.
+	Difficulty float64
+
+	// ShareInterval is the time between the shares of a client that the
+	// variable difficulty targets.
+	ShareInterval time.Duration
+}
+
+// WorkerStats houses the share accounting of a worker.  Workers are
+// identified by the name they authorize with, so the shares of all connections
+// for the same worker are combined.
+type WorkerStats struct {
+	// Name is the name the worker authorized with.
+	Name string
+
+	// Connections is the number of connections currently authorized for
+	// the worker.
+	Connections int
+
+	// SharesAccepted, SharesRejected, and SharesStale are the number of
+	// shares submitted by the worker which were accepted, rejected as
+	// invalid, and rejected for being for a job that no longer exists.
+	SharesAccepted uint64
+	SharesRejected uint64
+	SharesStale    uint64
+
+	// BlocksFound is the number of shares submitted by the worker which
+	// solved a block that was accepted.
+	BlocksFound uint64
+
+	// Work is the sum of the difficulties of the accepted shares, which is
+	// proportional to the number of hashes performed by the worker.
+	Work float64
+
+	// HashesPerSec is the estimated hash rate of the worker since its
+	// first accepted share.
+	HashesPerSec float64
+
+	// FirstShare and LastShare are the times of the first and last
+	// accepted shares.
+	FirstShare time.Time
+	LastShare  time.Time
+}
+
+// Server provides a stratum v1 server that hands out jobs built from block
+// templates to mining clients, accounts for the shares they submit, and
+// submits any solved blocks.
+type Server struct {
+	started  int32
+	shutdown int32
+
+	cfg        Config
+	g          *mining.BlkTmplGenerator
+	wg         sync.WaitGroup
+	refreshJob chan struct{}
+	quit       chan struct{}
+
+	// The following fields are protected by the mutex.
+	mtx         sync.Mutex
+	clients     map[*client]struct{}
+	jobs        map[string]*job
+	jobIDs      []string
+	currentJob  *job
+	jobCounter  uint64
+	workers     map[string]*WorkerStats
+	extraNonce1 uint32
+}
+
+// New returns a new instance of a stratum server for the provided
+// configuration.  Use Start to begin accepting clients.
+func New(cfg *Config) *Server {
+	s := &Server{
+		cfg:         *cfg,
+		g:           cfg.BlockTemplateGenerator,
+		refreshJob:  make(chan struct{}, 1),
+		quit:        make(chan struct{}),
+		clients:     make(map[*client]struct{}),
+		jobs:        make(map[string]*job),
+		workers:     make(map[string]*WorkerStats),
+		extraNonce1: rand.Uint32(),
+	}
+	if s.cfg.Difficulty <= 0 {
+		s.cfg.Difficulty = DefaultDifficulty
+	}
+	if s.cfg.ShareInterval <= 0 {
+		s.cfg.ShareInterval = DefaultShareInterval
+	}
+	return s
+}
+
+// Start begins accepting clients and handing out jobs.
+func (s *Server) Start() {
+	if atomic.AddInt32(&s.started, 1) != 1 {
+		return
+	}
+
+	log.Trace("Starting stratum server")
+	s.updateJob(false)
+
+	s.wg.Add(1)
+	go s.jobHandler()
+	for _, listener := range s.cfg.Listeners {
+		s.wg.Add(1)
+		go s.listenHandler(listener)
+	}
+}
+
+// Stop gracefully shuts down the stratum server by closing all listeners and
+// disconnecting all clients.
+func (s *Server) Stop() {
+	if atomic.AddInt32(&s.shutdown, 1) != 1 {
+		log.Infof("Stratum server is already in the process of " +
+			"shutting down")
+		return
+	}
+
+	log.Warnf("Stratum server shutting down")
+	for _, listener := range s.cfg.Listeners {
+		err := listener.Close()
+		if err != nil {
+			log.Errorf("Problem shutting down stratum: %v", err)
+		}
+	}
+	close(s.quit)
+
+	s.mtx.Lock()
+	for c := range s.clients {
+		c.conn.Close()
+	}
+	s.mtx.Unlock()
+
+	s.wg.Wait()
+	log.Infof("Stratum server shutdown complete")
+}
+
+// listenHandler accepts clients on the passed listener.  It must be run as a
+// goroutine.
+func (s *Server) listenHandler(listener net.Listener) {
+	log.Infof("Stratum server listening on %s", listener.Addr())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			// Only log the error if not shutting down.
+			if atomic.LoadInt32(&s.shutdown) == 0 {
+				log.Errorf("Can't accept connection: %v", err)
+			}
+			break
+		}
+
+		c := s.newClient(conn)
+		s.mtx.Lock()
+		s.clients[c] = struct{}{}
+		s.mtx.Unlock()
+
+		s.wg.Add(1)
+		go c.inHandler()
+	}
+
+	s.wg.Done()
+	log.Tracef("Stratum listener done for %s", listener.Addr())
+}
+
+// jobHandler periodically creates new jobs and retargets the difficulty of
+// idle clients.  It must be run as a goroutine.
+func (s *Server) jobHandler() {
+	ticker := time.NewTicker(jobCheckInterval)
+	defer ticker.Stop()
+
+out:
+	for {
+		select {
+		case <-ticker.C:
+			s.updateJob(false)
+
+			s.mtx.Lock()
+			clients := make([]*client, 0, len(s.clients))
+			for c := range s.clients {
+				clients = append(clients, c)
+			}
+			s.mtx.Unlock()
+			for _, c := range clients {
+				c.retarget(time.Now(), false)
+			}
+
+		case <-s.refreshJob:
+			s.updateJob(true)
+
+		case <-s.quit:
+			break out
+		}
+	}
+
+	s.wg.Done()
+	log.Tracef("Stratum job handler done")
+}
+
+// updateJob creates a new job and hands it out to all subscribed clients when
+// the best chain has changed, or when forced or enough time has passed since
+// the current job was created and new transactions are available.
+func (s *Server) updateJob(force bool) {
+	best := s.g.BestSnapshot()
+	s.mtx.Lock()
+	current := s.currentJob
+	s.mtx.Unlock()
+
+	cleanJobs := current == nil ||
+		current.template.Block.Header.PrevBlock != best.Hash
+	txUpdate := s.g.TxSource().LastUpdated()
+	if !cleanJobs && !force && (time.Since(current.created) <
+		jobRefreshInterval || txUpdate == current.txUpdate) {
+
+		return
+	}
+
+	// Choose a payment address at random.
+	var payToAddr btcutil.Address
+	if len(s.cfg.MiningAddrs) > 0 {
+		payToAddr = s.cfg.MiningAddrs[rand.Intn(len(s.cfg.MiningAddrs))]
+	}
+	template, err := s.g.NewBlockTemplate(payToAddr)
+	if err != nil {
+		log.Errorf("Failed to create new block template: %v", err)
+		return
+	}
+	cleanJobs = current == nil ||
+		current.template.Block.Header.PrevBlock !=
+			template.Block.Header.PrevBlock
+
+	s.mtx.Lock()
+	s.jobCounter++
+	id := strconv.FormatUint(s.jobCounter, 16)
+	s.mtx.Unlock()
+	j, err := newJob(id, template, s.g.Policy().CoinbaseTag,
+		mining.MinimumMedianTime(best), txUpdate)
+	if err != nil {
+		log.Errorf("Failed to create new job: %v", err)
+		return
+	}
+
+	s.mtx.Lock()
+	if cleanJobs {
+		s.jobs = make(map[string]*job)
+		s.jobIDs = s.jobIDs[:0]
+	}
+	s.jobs[id] = j
+	s.jobIDs = append(s.jobIDs, id)
+	if len(s.jobIDs) > maxJobs {
+		delete(s.jobs, s.jobIDs[0])
+		s.jobIDs = s.jobIDs[1:]
+	}
+	s.currentJob = j
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mtx.Unlock()
+
+	log.Debugf("Created stratum job %s at height %d with %d "+
+		"transactions", id, j.height, len(template.Block.Transactions))
+
+	for _, c := range clients {
+		c.sendJob(j, cleanJobs)
+	}
+}
+
+// job returns the job with the passed id or nil if it no longer exists.
+func (s *Server) job(id string) *job {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.jobs[id]
+}
+
+// worker returns the stats for the worker with the passed name, creating them
+// if needed.
+//
+// This function MUST be called with the server lock held.
+func (s *Server) worker(name string) *WorkerStats {
+	stats, ok := s.workers[name]
+	if !ok {
+		stats = &WorkerStats{Name: name}
+		s.workers[name] = stats
+	}
+	return stats
+}
+
+// submitBlock submits the passed block solved by a client and returns whether
+// or not it was accepted.
+func (s *Server) submitBlock(block *btcutil.Block, worker string) bool {
+	// Ensure the block is not stale since a new block could have shown up
+	// while the solution was being found.
+	msgBlock := block.MsgBlock()
+	if !msgBlock.Header.PrevBlock.IsEqual(&s.g.BestSnapshot().Hash) {
+		log.Debugf("Block submitted via stratum by %s with previous "+
+			"block %s is stale", worker, msgBlock.Header.PrevBlock)
+		return false
+	}
+
+	// Process this block using the same rules as blocks coming from other
+	// nodes.  This will in turn relay it to the network like normal.
+	isOrphan, err := s.cfg.ProcessBlock(block, blockchain.BFNone)
+	if err != nil {
+		// Anything other than a rule violation is an unexpected error,
+		// so log that error as an internal error.
+		if _, ok := err.(blockchain.RuleError); !ok {
+			log.Errorf("Unexpected error while processing "+
+				"block submitted via stratum: %v", err)
+			return false
+		}
+
+		log.Infof("Block submitted via stratum by %s rejected: %v",
+			worker, err)
+		return false
+	}
+	if isOrphan {
+		log.Infof("Block submitted via stratum by %s is an orphan",
+			worker)
+		return false
+	}
+
+	// The block was accepted, so hand out a new job right away.
+	coinbaseTx := msgBlock.Transactions[0].TxOut[0]
+	log.Infof("Block submitted via stratum by %s accepted (hash %s, "+
+		"amount %v)", worker, block.Hash(),
+		btcutil.Amount(coinbaseTx.Value))
+	select {
+	case s.refreshJob <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// Addrs returns the addresses the server is listening on.
+//
+// This function is safe for concurrent access.
+func (s *Server) Addrs() []net.Addr {
+	addrs := make([]net.Addr, 0, len(s.cfg.Listeners))
+	for _, listener := range s.cfg.Listeners {
+		addrs = append(addrs, listener.Addr())
+	}
+	return addrs
+}
+
+// NumClients returns the number of clients currently connected.
+//
+// This function is safe for concurrent access.
+func (s *Server) NumClients() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return len(s.clients)
+}
+
+// CurrentJob returns the id and height of the most recent job or an empty id
+// when no job has been created yet.
+//
+// This function is safe for concurrent access.
+func (s *Server) CurrentJob() (string, int32) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.currentJob == nil {
+		return "", 0
+	}
+	return s.currentJob.id, s.currentJob.height
+}
+
+// WorkerStats returns the share accounting of all workers that have
+// authorized since the server was started sorted by name.
+//
+// This function is safe for concurrent access.
+func (s *Server) WorkerStats() []WorkerStats {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	now := time.Now()
+	stats := make([]WorkerStats, 0, len(s.workers))
+	for _, worker := range s.workers {
+		stat := *worker
+		elapsed := now.Sub(stat.FirstShare).Seconds()
+		if !stat.FirstShare.IsZero() && elapsed > 0 {
+			stat.HashesPerSec = stat.Work * math.Pow(2, 32) / elapsed
+		}
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+// client houses a connection to a mining client.
+type client struct {
+	server      *Server
+	conn        net.Conn
+	extraNonce1 []byte
+	writeMtx    sync.Mutex
+
+	// The following fields are protected by the mutex.
+	mtx            sync.Mutex
+	subscribed     bool
+	workers        map[string]struct{}
+	difficulty     float64
+	prevDifficulty float64
+	sentDifficulty float64
+	retargetTime   time.Time
+	retargetShares int
+}
+
+// newClient returns a new client for the passed connection with a unique extra
+// nonce.
+func (s *Server) newClient(conn net.Conn) *client {
+	s.mtx.Lock()
+	s.extraNonce1++
+	extraNonce1 := make([]byte, ExtraNonce1Size)
+	for i := range extraNonce1 {
+		extraNonce1[i] = byte(s.extraNonce1 >> (8 * (3 - i)))
+	}
+	s.mtx.Unlock()
+
+	return &client{
+		server:         s,
+		conn:           conn,
+		extraNonce1:    extraNonce1,
+		workers:        make(map[string]struct{}),
+		difficulty:     s.cfg.Difficulty,
+		prevDifficulty: s.cfg.Difficulty,
+		retargetTime:   time.Now(),
+	}
+}
+
+// inHandler handles all incoming messages for the client.  It must be run as
+// a goroutine.
+func (c *client) inHandler() {
+	s := c.server
+	log.Debugf("New stratum client %s", c.conn.RemoteAddr())
+
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 0, 1024), maxMessageSize)
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(clientIdleTimeout))
+		if !scanner.Scan() {
+			break
+		}
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			log.Debugf("Invalid message from stratum client %s: %v",
+				c.conn.RemoteAddr(), err)
+			break
+		}
+		result, rpcErr := c.handleRequest(&req)
+		c.send(&response{ID: req.ID, Result: result, Error: rpcErr})
+
+		// Hand out work right after subscribing.
+		if req.Method == "mining.subscribe" && rpcErr == nil {
+			s.mtx.Lock()
+			j := s.currentJob
+			s.mtx.Unlock()
+			if j != nil {
+				c.sendJob(j, true)
+			}
+		}
+	}
+	c.conn.Close()
+
+	s.mtx.Lock()
+	delete(s.clients, c)
+	c.mtx.Lock()
+	for name := range c.workers {
+		s.worker(name).Connections--
+	}
+	c.mtx.Unlock()
+	s.mtx.Unlock()
+
+	s.wg.Done()
+	log.Debugf("Stratum client %s disconnected", c.conn.RemoteAddr())
+}
+
+// send writes the passed message to the client.  The connection is closed on
+// failure, which causes the input handler to clean up the client.
+func (c *client) send(msg interface{}) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		log.Errorf("Failed to marshal stratum message: %v", err)
+		return
+	}
+	b = append(b, '\n')
+
+	c.writeMtx.Lock()
+	defer c.writeMtx.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(clientWriteTimeout))
+	if _, err := c.conn.Write(b); err != nil {
+		log.Debugf("Failed to write to stratum client %s: %v",
+			c.conn.RemoteAddr(), err)
+		c.conn.Close()
+	}
+}
+
+// shareDifficulty returns the difficulty to use for the shares of the client
+// for the passed job, which never exceeds the difficulty of the network.
+func shareDifficulty(difficulty float64, j *job) float64 {
+	return math.Min(difficulty, TargetToDifficulty(j.target))
+}
+
+// sendJob hands out the passed job to the client if it is subscribed along
+// with its difficulty if it changed.
+func (c *client) sendJob(j *job, cleanJobs bool) {
+	c.mtx.Lock()
+	if !c.subscribed {
+		c.mtx.Unlock()
+		return
+	}
+	difficulty := shareDifficulty(c.difficulty, j)
+	sendDifficulty := difficulty != c.sentDifficulty
+	c.sentDifficulty = difficulty
+	c.prevDifficulty = c.difficulty
+	c.mtx.Unlock()
+
+	if sendDifficulty {
+		c.send(&notification{
+			Method: "mining.set_difficulty",
+			Params: []interface{}{difficulty},
+		})
+	}
+	c.send(&notification{
+		Method: "mining.notify",
+		Params: j.notifyParams(cleanJobs),
+	})
+}
+
+// retarget adjusts the difficulty of the client towards the configured share
+// interval once enough time has passed or enough shares were submitted since
+// the last retarget, and hands out the current job with the new difficulty.
+func (c *client) retarget(now time.Time, shareAccepted bool) {
+	interval := c.server.cfg.ShareInterval
+	c.mtx.Lock()
+	if shareAccepted {
+		c.retargetShares++
+	}
+	elapsed := now.Sub(c.retargetTime)
+	if !c.subscribed || len(c.workers) == 0 || elapsed <= 0 ||
+		(elapsed < interval*vardiffWindowShares &&
+			c.retargetShares < 2*vardiffWindowShares) {
+
+		c.mtx.Unlock()
+		return
+	}
+
+	// Scale the difficulty by the ratio of the actual shares to the
+	// expected shares over the elapsed time.
+	ratio := float64(c.retargetShares) * float64(interval) /
+		float64(elapsed)
+	ratio = math.Max(ratio, 1.0/maxRetargetFactor)
+	ratio = math.Min(ratio, maxRetargetFactor)
+	newDifficulty := math.Max(c.difficulty*ratio, minDifficulty)
+	c.retargetTime = now
+	c.retargetShares = 0
+	if math.Abs(newDifficulty/c.difficulty-1) < 0.1 {
+		c.mtx.Unlock()
+		return
+	}
+
+	log.Debugf("Retargeting stratum client %s difficulty from %v to %v",
+		c.conn.RemoteAddr(), c.difficulty, newDifficulty)
+	c.difficulty = newDifficulty
+	c.mtx.Unlock()
+
+	c.server.mtx.Lock()
+	j := c.server.currentJob
+	c.server.mtx.Unlock()
+	if j != nil {
+		c.sendJob(j, false)
+	}
+}
+
+// handleRequest handles the passed request and returns its result.
+func (c *client) handleRequest(req *request) (interface{}, *Error) {
+	switch req.Method {
+	case "mining.subscribe":
+		return c.handleSubscribe()
+	case "mining.authorize":
+		return c.handleAuthorize(req.Params)
+	case "mining.submit":
+		return c.handleSubmit(req.Params)
+	case "mining.extranonce.subscribe":
+		// The extra nonce of a client never changes.
+		return true, nil
+	}
+
+	return nil, &Error{Code: errCodeOther, Message: "Unknown method"}
+}
+
+// parseStringParams decodes the passed parameters as strings and ensures there
+// are at least the passed number of them.
+func parseStringParams(params []json.RawMessage, n int) ([]string, *Error) {
+	if len(params) < n {
+		return nil, &Error{
+			Code:    errCodeOther,
+			Message: "Not enough parameters",
+		}
+	}
+	strs := make([]string, n)
+	for i := range strs {
+		if err := json.Unmarshal(params[i], &strs[i]); err != nil {
+			return nil, &Error{
+				Code:    errCodeOther,
+				Message: "Invalid parameter",
+			}
+		}
+	}
+	return strs, nil
+}
+
+// handleSubscribe handles mining.subscribe requests.
+func (c *client) handleSubscribe() (interface{}, *Error) {
+	c.mtx.Lock()
+	c.subscribed = true
+	c.mtx.Unlock()
+
+	subscriptionID := hex.EncodeToString(c.extraNonce1)
+	return []interface{}{
+		[][]string{
+			{"mining.set_difficulty", subscriptionID},
+			{"mining.notify", subscriptionID},
+		},
+		hex.EncodeToString(c.extraNonce1),
+		ExtraNonce2Size,
+	}, nil
+}
+
+// handleAuthorize handles mining.authorize requests.  Any worker name is
+// accepted since the blocks always pay to the configured mining addresses and
+// the name is only used for share accounting.
+func (c *client) handleAuthorize(params []json.RawMessage) (interface{}, *Error) {
+	strs, rpcErr := parseStringParams(params, 1)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	name := strs[0]
+	if name == "" {
+		return false, nil
+	}
+
+	c.server.mtx.Lock()
+	c.mtx.Lock()
+	if _, ok := c.workers[name]; !ok {
+		c.workers[name] = struct{}{}
+		c.server.worker(name).Connections++
+	}
+	c.mtx.Unlock()
+	c.server.mtx.Unlock()
+
+	log.Debugf("Stratum client %s authorized worker %s",
+		c.conn.RemoteAddr(), name)
+	return true, nil
+}
+
+// handleSubmit handles mining.submit requests.
+func (c *client) handleSubmit(params []json.RawMessage) (interface{}, *Error) {
+	strs, rpcErr := parseStringParams(params, 5)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	name, jobID := strs[0], strs[1]
+
+	c.mtx.Lock()
+	subscribed := c.subscribed
+	_, authorized := c.workers[name]
+	difficulty := math.Min(c.difficulty, c.prevDifficulty)
+	c.mtx.Unlock()
+	if !authorized {
+		return nil, &Error{
+			Code:    errCodeUnauthorized,
+			Message: "Unauthorized worker",
+		}
+	}
+	if !subscribed {
+		return nil, &Error{
+			Code:    errCodeNotSubscribed,
+			Message: "Not subscribed",
+		}
+	}
+
+	s := c.server
+	j := s.job(jobID)
+	if j == nil {
+		s.mtx.Lock()
+		s.worker(name).SharesStale++
+		s.mtx.Unlock()
+		return nil, &Error{
+			Code:    errCodeJobNotFound,
+			Message: "Job not found",
+		}
+	}
+
+	reject := func(code int, message string) (interface{}, *Error) {
+		s.mtx.Lock()
+		s.worker(name).SharesRejected++
+		s.mtx.Unlock()
+		log.Debugf("Rejected share from stratum worker %s: %s", name,
+			message)
+		return nil, &Error{Code: code, Message: message}
+	}
+
+	extraNonce2, err := hex.DecodeString(strs[2])
+	if err != nil || len(extraNonce2) != ExtraNonce2Size {
+		return reject(errCodeOther, "Invalid extranonce2")
+	}
+	nTime, err := parseUint32Hex(strs[3])
+	if err != nil {
+		return reject(errCodeOther, "Invalid ntime")
+	}
+	if int64(nTime) < j.minTime.Unix() ||
+		int64(nTime) > time.Now().Unix()+maxFutureBlockTime {
+
+		return reject(errCodeOther, "Ntime out of range")
+	}
+	nonce, err := parseUint32Hex(strs[4])
+	if err != nil {
+		return reject(errCodeOther, "Invalid nonce")
+	}
+	if j.recordSubmission(c.extraNonce1, extraNonce2, nTime, nonce) {
+		return reject(errCodeDuplicate, "Duplicate share")
+	}
+
+	// Ensure the share meets the difficulty of the client, which never
+	// exceeds the difficulty of the network.
+	header := j.header(c.extraNonce1, extraNonce2, nTime, nonce)
+	hash := header.BlockHash()
+	hashNum := blockchain.HashToBig(&hash)
+	difficulty = shareDifficulty(difficulty, j)
+	shareTarget := DifficultyToTarget(difficulty)
+	if shareTarget.Cmp(j.target) < 0 {
+		shareTarget = new(big.Int).Set(j.target)
+	}
+	if hashNum.Cmp(shareTarget) > 0 {
+		return reject(errCodeLowDifficulty, "Low difficulty share")
+	}
+
+	// Submit the block when the share also meets the network difficulty.
+	var blockFound bool
+	if hashNum.Cmp(j.target) <= 0 {
+		block := j.block(c.extraNonce1, extraNonce2, nTime, nonce)
+		blockFound = s.submitBlock(block, name)
+	}
+
+	now := time.Now()
+	s.mtx.Lock()
+	stats := s.worker(name)
+	stats.SharesAccepted++
+	stats.Work += difficulty
+	if stats.FirstShare.IsZero() {
+		stats.FirstShare = now
+	}
+	stats.LastShare = now
+	if blockFound {
+		stats.BlocksFound++
+	}
+	s.mtx.Unlock()
+
+	c.retarget(now, true)
+	return true, nil
+}
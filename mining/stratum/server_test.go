@@ -0,0 +1,353 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stratum
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	_ "github.com/btcsuite/btcd/database/ffldb"
+	"github.com/btcsuite/btcd/mining"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// emptyTxSource provides a transaction source without any transactions.
+type emptyTxSource struct{}
+
+// LastUpdated returns the last time a transaction was added to or removed from
+// the source pool.  It is part of the mining.TxSource interface.
+func (emptyTxSource) LastUpdated() time.Time {
+	return time.Time{}
+}
+
+// MiningDescs returns a slice of mining descriptors for all the transactions
+// in the source pool.  It is part of the mining.TxSource interface.
+func (emptyTxSource) MiningDescs() []*mining.TxDesc {
+	return nil
+}
+
+// HaveTransaction returns whether or not the passed transaction hash exists in
+// the source pool.  It is part of the mining.TxSource interface.
+func (emptyTxSource) HaveTransaction(hash *chainhash.Hash) bool {
+	return false
+}
+
+// newTestServer returns a stratum server listening on a local address that
+// builds jobs on top of a new regtest chain.
+func newTestServer(t *testing.T) (*Server, *blockchain.BlockChain) {
+	params := chaincfg.RegressionNetParams
+	db, err := database.Create("ffldb", t.TempDir(), params.Net)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	timeSource := blockchain.NewMedianTime()
+	sigCache := txscript.NewSigCache(1000)
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: &params,
+		TimeSource:  timeSource,
+		SigCache:    sigCache,
+	})
+	if err != nil {
+		t.Fatalf("unable to create chain: %v", err)
+	}
+
+	policy := &mining.Policy{
+		BlockMaxWeight: blockchain.MaxBlockWeight - 4000,
+	}
+	g := mining.NewBlkTmplGenerator(policy, &params, emptyTxSource{},
+		chain, timeSource, sigCache, txscript.NewHashCache(1000))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	s := New(&Config{
+		ChainParams:            &params,
+		BlockTemplateGenerator: g,
+		ProcessBlock: func(block *btcutil.Block,
+			flags blockchain.BehaviorFlags) (bool, error) {
+
+			_, isOrphan, err := chain.ProcessBlock(block, flags)
+			return isOrphan, err
+		},
+		Listeners: []net.Listener{listener},
+	})
+	s.Start()
+	t.Cleanup(s.Stop)
+
+	return s, chain
+}
+
+// testClient is a minimal stratum client used to exercise the server.
+type testClient struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+	nextID int
+
+	extraNonce1 []byte
+	difficulty  float64
+	notify      []interface{}
+}
+
+// testMessage models any message received from the server.
+type testMessage struct {
+	ID     *int              `json:"id"`
+	Method string            `json:"method"`
+	Params []interface{}     `json:"params"`
+	Result json.RawMessage   `json:"result"`
+	Error  []json.RawMessage `json:"error"`
+}
+
+// read reads the next message from the server and tracks any difficulty and
+// job notifications.
+func (c *testClient) read() *testMessage {
+	c.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	line, err := c.reader.ReadBytes('\n')
+	if err != nil {
+		c.t.Fatalf("unable to read message: %v", err)
+	}
+	var msg testMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		c.t.Fatalf("unable to decode message %s: %v", line, err)
+	}
+	switch msg.Method {
+	case "mining.set_difficulty":
+		c.difficulty = msg.Params[0].(float64)
+	case "mining.notify":
+		c.notify = msg.Params
+	}
+	return &msg
+}
+
+// call sends a request to the server and returns the response, tracking any
+// notifications received in the meantime.
+func (c *testClient) call(method string, params ...interface{}) *testMessage {
+	c.nextID++
+	req, _ := json.Marshal(map[string]interface{}{
+		"id":     c.nextID,
+		"method": method,
+		"params": params,
+	})
+	if _, err := c.conn.Write(append(req, '\n')); err != nil {
+		c.t.Fatalf("unable to write request: %v", err)
+	}
+	for {
+		msg := c.read()
+		if msg.ID != nil && *msg.ID == c.nextID {
+			return msg
+		}
+	}
+}
+
+// errorCode returns the error code of the passed response or zero if it was
+// successful.
+func errorCode(t *testing.T, msg *testMessage) int {
+	if len(msg.Error) == 0 {
+		return 0
+	}
+	var code int
+	if err := json.Unmarshal(msg.Error[0], &code); err != nil {
+		t.Fatalf("invalid error code: %v", err)
+	}
+	return code
+}
+
+// header builds the block header for the current job with the passed extra
+// nonce and nonce the same way mining software does.
+func (c *testClient) header(extraNonce2 []byte, nonce uint32) wire.BlockHeader {
+	decode := func(i int) []byte {
+		b, err := hex.DecodeString(c.notify[i].(string))
+		if err != nil {
+			c.t.Fatalf("invalid notify parameter %d: %v", i, err)
+		}
+		return b
+	}
+	parseUint32 := func(i int) uint32 {
+		v, err := parseUint32Hex(c.notify[i].(string))
+		if err != nil {
+			c.t.Fatalf("invalid notify parameter %d: %v", i, err)
+		}
+		return v
+	}
+
+	var coinbase []byte
+	coinbase = append(coinbase, decode(2)...)
+	coinbase = append(coinbase, c.extraNonce1...)
+	coinbase = append(coinbase, extraNonce2...)
+	coinbase = append(coinbase, decode(3)...)
+	root := chainhash.DoubleHashB(coinbase)
+	for _, branch := range c.notify[4].([]interface{}) {
+		b, _ := hex.DecodeString(branch.(string))
+		root = chainhash.DoubleHashB(append(root, b...))
+	}
+
+	var prevHash chainhash.Hash
+	prev := decode(1)
+	for i := 0; i < chainhash.HashSize; i += 4 {
+		prevHash[i], prevHash[i+1], prevHash[i+2], prevHash[i+3] =
+			prev[i+3], prev[i+2], prev[i+1], prev[i]
+	}
+	var merkleRoot chainhash.Hash
+	copy(merkleRoot[:], root)
+
+	return wire.BlockHeader{
+		Version:    int32(parseUint32(5)),
+		PrevBlock:  prevHash,
+		MerkleRoot: merkleRoot,
+		Timestamp:  time.Unix(int64(parseUint32(7)), 0),
+		Bits:       parseUint32(6),
+		Nonce:      nonce,
+	}
+}
+
+// findNonce returns a nonce for the current job which either does or does not
+// meet the network target as requested.
+func (c *testClient) findNonce(extraNonce2 []byte, valid bool) uint32 {
+	for nonce := uint32(0); ; nonce++ {
+		header := c.header(extraNonce2, nonce)
+		hash := header.BlockHash()
+		target := blockchain.CompactToBig(header.Bits)
+		if (blockchain.HashToBig(&hash).Cmp(target) <= 0) == valid {
+			return nonce
+		}
+	}
+}
+
+// TestServer ensures the stratum server hands out jobs, accounts for shares,
+// and submits solved blocks on regtest.
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	s, chain := newTestServer(t)
+	conn, err := net.Dial("tcp", s.cfg.Listeners[0].Addr().String())
+	if err != nil {
+		t.Fatalf("unable to connect: %v", err)
+	}
+	defer conn.Close()
+	c := &testClient{t: t, conn: conn, reader: bufio.NewReader(conn)}
+
+	// Submitting before subscribing and authorizing is rejected.
+	resp := c.call("mining.submit", "worker", "1", "00000000",
+		"00000000", "00000000")
+	if code := errorCode(t, resp); code != errCodeUnauthorized {
+		t.Fatalf("unexpected error code %d", code)
+	}
+
+	resp = c.call("mining.subscribe", "test/1.0")
+	var subscribe []json.RawMessage
+	if err := json.Unmarshal(resp.Result, &subscribe); err != nil ||
+		len(subscribe) != 3 {
+
+		t.Fatalf("unexpected subscribe result %s: %v", resp.Result, err)
+	}
+	var extraNonce1 string
+	json.Unmarshal(subscribe[1], &extraNonce1)
+	c.extraNonce1, _ = hex.DecodeString(extraNonce1)
+	if len(c.extraNonce1) != ExtraNonce1Size {
+		t.Fatalf("unexpected extranonce1 %q", extraNonce1)
+	}
+
+	resp = c.call("mining.authorize", "worker", "x")
+	if string(resp.Result) != "true" {
+		t.Fatalf("unexpected authorize result %s", resp.Result)
+	}
+
+	// The job and difficulty follow the subscription.  The difficulty is
+	// capped to the network difficulty.
+	for c.notify == nil {
+		c.read()
+	}
+	if c.difficulty == 0 || c.difficulty >= DefaultDifficulty {
+		t.Fatalf("unexpected difficulty %v", c.difficulty)
+	}
+	jobID := c.notify[0].(string)
+	nTime := c.notify[7].(string)
+
+	// Shares below the difficulty and duplicate shares are rejected.
+	extraNonce2 := []byte{0, 0, 0, 1}
+	nonce := c.findNonce(extraNonce2, false)
+	submit := []interface{}{"worker", jobID, hex.EncodeToString(extraNonce2),
+		nTime, uint32Hex(nonce)}
+	resp = c.call("mining.submit", submit...)
+	if code := errorCode(t, resp); code != errCodeLowDifficulty {
+		t.Fatalf("unexpected error code %d", code)
+	}
+	resp = c.call("mining.submit", submit...)
+	if code := errorCode(t, resp); code != errCodeDuplicate {
+		t.Fatalf("unexpected error code %d", code)
+	}
+
+	// Shares for unknown jobs are stale.
+	resp = c.call("mining.submit", "worker", "ffff",
+		hex.EncodeToString(extraNonce2), nTime, uint32Hex(nonce))
+	if code := errorCode(t, resp); code != errCodeJobNotFound {
+		t.Fatalf("unexpected error code %d", code)
+	}
+
+	// A share that meets the network target is submitted as a block.
+	nonce = c.findNonce(extraNonce2, true)
+	resp = c.call("mining.submit", "worker", jobID,
+		hex.EncodeToString(extraNonce2), nTime, uint32Hex(nonce))
+	if string(resp.Result) != "true" {
+		t.Fatalf("unexpected submit result %s: %s", resp.Result,
+			resp.Error)
+	}
+	if height := chain.BestSnapshot().Height; height != 1 {
+		t.Fatalf("unexpected best height %d", height)
+	}
+
+	// A new job on top of the new block is handed out.
+	for c.notify[0].(string) == jobID {
+		c.read()
+	}
+	if clean := c.notify[8].(bool); !clean {
+		t.Fatalf("new job on top of a new block is not clean")
+	}
+	if id, height := s.CurrentJob(); id != c.notify[0].(string) ||
+		height != 2 {
+
+		t.Fatalf("unexpected current job %s at height %d", id, height)
+	}
+
+	stats := s.WorkerStats()
+	want := fmt.Sprintf("%+v", WorkerStats{
+		Name:           "worker",
+		Connections:    1,
+		SharesAccepted: 1,
+		SharesRejected: 2,
+		SharesStale:    1,
+		BlocksFound:    1,
+	})
+	if len(stats) != 1 {
+		t.Fatalf("unexpected number of workers %d", len(stats))
+	}
+	stat := stats[0]
+	if stat.Work == 0 || stat.LastShare.IsZero() {
+		t.Fatalf("unexpected share accounting: %+v", stat)
+	}
+	stat.Work, stat.HashesPerSec = 0, 0
+	stat.FirstShare, stat.LastShare = time.Time{}, time.Time{}
+	if got := fmt.Sprintf("%+v", stat); got != want {
+		t.Fatalf("unexpected worker stats: got %s, want %s", got, want)
+	}
+	if s.NumClients() != 1 {
+		t.Fatalf("unexpected number of clients %d", s.NumClients())
+	}
+}
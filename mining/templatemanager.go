@@ -0,0 +1,333 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// templateNotifyDelay is the amount of time the template manager waits
+	// after a change to the candidate transactions or the best chain
+	// before generating a new template for the notification callback.
+	// This allows bursts of changes to be coalesced into a single
+	// notification.
+	templateNotifyDelay = 250 * time.Millisecond
+)
+
+// candidateLess returns whether the passed candidate a should sort before the
+// passed candidate b in the candidate set of the template manager.  Candidates
+// with a higher modified fee rate sort first with ties broken by the lower
+// transaction hash in order to keep the order deterministic.
+func candidateLess(a, b *txCandidate) bool {
+	feeRateA, feeRateB := a.desc.ModifiedFeePerKB(), b.desc.ModifiedFeePerKB()
+	if feeRateA != feeRateB {
+		return feeRateA > feeRateB
+	}
+	hashA, hashB := a.desc.Tx.Hash(), b.desc.Tx.Hash()
+	return bytes.Compare(hashA[:], hashB[:]) < 0
+}
+
+// TemplateManager maintains the set of source pool transactions which are
+// candidates for inclusion in a block template incrementally as transactions
+// are added to and removed from the source pool.  The details which only
+// change along with the best chain, such as the outputs the transactions
+// spend, are only gathered once per transaction and best chain tip, so new
+// templates can be generated much faster than by NewBlockTemplate.
+//
+// The candidate set is kept up to date by passing the additions and removals
+// of the source pool to TxAdded and TxRemoved, while the best chain is
+// tracked by subscribing to the notifications of the chain the generator
+// extends.
+type TemplateManager struct {
+	g      *BlkTmplGenerator
+	notify func(*BlockTemplate)
+
+	// The following fields are protected by the mutex.
+	//
+	// candidates houses all candidate transactions keyed by their hash
+	// while sorted holds the same candidates sorted by candidateLess.
+	// Candidates without a utxo view have not been resolved against the
+	// best chain yet.  The set is only valid for the best chain tip with
+	// the hash tipHash when synced is set, otherwise it has to be rebuilt
+	// from the source pool.
+	mtx        sync.Mutex
+	candidates map[chainhash.Hash]*txCandidate
+	sorted     []*txCandidate
+	synced     bool
+	tipHash    chainhash.Hash
+
+	// lastNotified holds the hashes of the transactions in the template
+	// most recently delivered to the notification callback, including
+	// the coinbase, and is only accessed by the notification handler.
+	lastNotified []chainhash.Hash
+
+	wg      sync.WaitGroup
+	changed chan struct{}
+	quit    chan struct{}
+}
+
+// NewTemplateManager returns a new template manager which generates templates
+// using the passed generator.  The passed notify function, which may be nil,
+// is invoked with a new template whenever the transactions that would be
+// included in the template change once the manager has been started.
+func NewTemplateManager(g *BlkTmplGenerator, notify func(*BlockTemplate)) *TemplateManager {
+	m := &TemplateManager{
+		g:          g,
+		notify:     notify,
+		candidates: make(map[chainhash.Hash]*txCandidate),
+		changed:    make(chan struct{}, 1),
+		quit:       make(chan struct{}),
+	}
+	g.chain.Subscribe(m.handleBlockchainNotification)
+	return m
+}
+
+// signalChange informs the notification handler of a change to the candidate
+// transactions or the best chain without blocking.
+func (m *TemplateManager) signalChange() {
+	select {
+	case m.changed <- struct{}{}:
+	default:
+	}
+}
+
+// handleBlockchainNotification invalidates the candidate set whenever the best
+// chain tip changes.
+func (m *TemplateManager) handleBlockchainNotification(n *blockchain.Notification) {
+	switch n.Type {
+	case blockchain.NTBlockConnected, blockchain.NTBlockDisconnected:
+		m.mtx.Lock()
+		m.synced = false
+		m.mtx.Unlock()
+		m.signalChange()
+	}
+}
+
+// insertCandidate adds the passed candidate to the candidate set.
+//
+// This function MUST be called with the manager lock held.
+func (m *TemplateManager) insertCandidate(candidate *txCandidate) {
+	i := sort.Search(len(m.sorted), func(i int) bool {
+		return !candidateLess(m.sorted[i], candidate)
+	})
+	m.sorted = append(m.sorted, nil)
+	copy(m.sorted[i+1:], m.sorted[i:])
+	m.sorted[i] = candidate
+	m.candidates[*candidate.desc.Tx.Hash()] = candidate
+}
+
+// removeCandidate removes the passed candidate from the candidate set.
+//
+// This function MUST be called with the manager lock held.
+func (m *TemplateManager) removeCandidate(candidate *txCandidate) {
+	i := sort.Search(len(m.sorted), func(i int) bool {
+		return !candidateLess(m.sorted[i], candidate)
+	})
+	if i < len(m.sorted) && m.sorted[i] == candidate {
+		m.sorted = append(m.sorted[:i], m.sorted[i+1:]...)
+	}
+	delete(m.candidates, *candidate.desc.Tx.Hash())
+}
+
+// TxAdded adds the passed source pool transaction to the candidate set or
+// updates its descriptor when it is already a candidate, for example, after
+// its fee delta was changed.
+//
+// This function is safe for concurrent access.
+func (m *TemplateManager) TxAdded(txDesc *TxDesc) {
+	m.mtx.Lock()
+	if m.synced {
+		// Candidates are treated as immutable since templates may be
+		// generated from them without the lock held, so a copy with the
+		// new descriptor replaces an existing candidate.
+		candidate := &txCandidate{desc: txDesc}
+		if existing, ok := m.candidates[*txDesc.Tx.Hash()]; ok {
+			*candidate = *existing
+			candidate.desc = txDesc
+			m.removeCandidate(existing)
+		}
+		m.insertCandidate(candidate)
+	}
+	m.mtx.Unlock()
+	m.signalChange()
+}
+
+// TxRemoved removes the source pool transaction with the passed hash from the
+// candidate set.
+//
+// This function is safe for concurrent access.
+func (m *TemplateManager) TxRemoved(txHash *chainhash.Hash) {
+	m.mtx.Lock()
+	if candidate, ok := m.candidates[*txHash]; ok {
+		m.removeCandidate(candidate)
+	}
+	m.mtx.Unlock()
+	m.signalChange()
+}
+
+// NumCandidates returns the number of transactions in the candidate set.
+//
+// This function is safe for concurrent access.
+func (m *TemplateManager) NumCandidates() int {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return len(m.sorted)
+}
+
+// prepareCandidates ensures the candidate set is valid for the passed best
+// chain state by rebuilding it from the source pool when needed, resolves any
+// candidates which have not been resolved against the best chain yet, and
+// returns the candidates sorted by modified fee rate.
+//
+// This function MUST be called with the manager lock held.
+func (m *TemplateManager) prepareCandidates(best *blockchain.BestState) []*txCandidate {
+	nextBlockHeight := best.Height + 1
+	if !m.synced || m.tipHash != best.Hash {
+		m.candidates = make(map[chainhash.Hash]*txCandidate)
+		m.sorted = m.sorted[:0]
+		for _, txDesc := range m.g.txSource.MiningDescs() {
+			m.insertCandidate(&txCandidate{desc: txDesc})
+		}
+		m.synced = true
+		m.tipHash = best.Hash
+
+		log.Debugf("Rebuilt block template candidate set with %d "+
+			"transactions for block %v", len(m.sorted), best.Hash)
+	}
+
+	// Resolve the candidates which were added since the last template.
+	// Resolving does not change the sort order, so the candidates are
+	// replaced in place while those which are not eligible are removed
+	// afterwards.
+	var ineligible []*txCandidate
+	for i, candidate := range m.sorted {
+		if candidate.utxos != nil {
+			continue
+		}
+		resolved := m.g.newTxCandidate(candidate.desc, nextBlockHeight)
+		if resolved == nil {
+			ineligible = append(ineligible, candidate)
+			continue
+		}
+		m.sorted[i] = resolved
+		m.candidates[*resolved.desc.Tx.Hash()] = resolved
+	}
+	for _, candidate := range ineligible {
+		m.removeCandidate(candidate)
+	}
+
+	candidates := make([]*txCandidate, len(m.sorted))
+	copy(candidates, m.sorted)
+	return candidates
+}
+
+// NewBlockTemplate returns a new block template the same way as
+// NewBlockTemplateWithOutputs on the generator of the manager, however, only
+// the transactions added to the candidate set since the best chain tip changed
+// need to be looked up.
+//
+// This function is safe for concurrent access.
+func (m *TemplateManager) NewBlockTemplate(payToAddress btcutil.Address,
+	extraOutputs []*wire.TxOut) (*BlockTemplate, error) {
+
+	best := m.g.chain.BestSnapshot()
+	m.mtx.Lock()
+	candidates := m.prepareCandidates(best)
+	m.mtx.Unlock()
+
+	return m.g.newBlockTemplate(best, payToAddress, extraOutputs,
+		candidates)
+}
+
+// templateChanged returns whether or not the transactions in the passed
+// template differ from those in the template most recently delivered to the
+// notification callback.  It also records the transactions of the passed
+// template as the most recently delivered ones when they differ.
+func (m *TemplateManager) templateChanged(template *BlockTemplate) bool {
+	msgBlock := template.Block
+	txHashes := make([]chainhash.Hash, 0, len(msgBlock.Transactions))
+	txHashes = append(txHashes, msgBlock.Header.PrevBlock)
+	for _, tx := range msgBlock.Transactions[1:] {
+		txHashes = append(txHashes, tx.TxHash())
+	}
+
+	changed := len(txHashes) != len(m.lastNotified)
+	for i := 0; !changed && i < len(txHashes); i++ {
+		changed = txHashes[i] != m.lastNotified[i]
+	}
+	if changed {
+		m.lastNotified = txHashes
+	}
+	return changed
+}
+
+// notificationHandler generates a new template whenever the candidate
+// transactions or the best chain change and delivers it to the notification
+// callback when the transactions it includes differ from the previous one.
+// Changes within templateNotifyDelay of one another are coalesced.
+//
+// It must be run as a goroutine.
+func (m *TemplateManager) notificationHandler() {
+	defer m.wg.Done()
+
+	for {
+		select {
+		case <-m.changed:
+		case <-m.quit:
+			return
+		}
+
+		select {
+		case <-time.After(templateNotifyDelay):
+		case <-m.quit:
+			return
+		}
+
+		// Drain a change signalled while waiting since it will be
+		// reflected by the template generated below.
+		select {
+		case <-m.changed:
+		default:
+		}
+
+		template, err := m.NewBlockTemplate(nil, nil)
+		if err != nil {
+			log.Debugf("Unable to generate block template for "+
+				"notification: %v", err)
+			continue
+		}
+		if m.templateChanged(template) {
+			m.notify(template)
+		}
+	}
+}
+
+// Start begins delivering new templates to the notification callback of the
+// manager, if any.
+func (m *TemplateManager) Start() {
+	if m.notify == nil {
+		return
+	}
+
+	m.wg.Add(1)
+	go m.notificationHandler()
+}
+
+// Stop stops delivering new templates to the notification callback and waits
+// for any pending delivery to complete.
+func (m *TemplateManager) Stop() {
+	close(m.quit)
+	m.wg.Wait()
+}
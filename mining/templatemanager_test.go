@@ -0,0 +1,149 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+)
+
+// TestTemplateManager ensures the template manager maintains its candidate set
+// as transactions are added and removed and the best chain changes, and that
+// the templates it generates match those generated from scratch.
+func TestTemplateManager(t *testing.T) {
+	t.Parallel()
+
+	source := &fakeTxSource{}
+	g, coinbases := newTestGenerator(t, source, 2)
+	notified := make(chan *BlockTemplate, 10)
+	m := NewTemplateManager(g, func(template *BlockTemplate) {
+		notified <- template
+	})
+
+	// checkTemplate ensures a template generated by the manager contains
+	// the passed transactions in order and matches a template generated
+	// from scratch.
+	checkTemplate := func(name string, want ...*TxDesc) *BlockTemplate {
+		t.Helper()
+
+		template, err := m.NewBlockTemplate(nil, nil)
+		if err != nil {
+			t.Fatalf("%s: unable to create block template: %v", name,
+				err)
+		}
+		txns := template.Block.Transactions[1:]
+		if len(txns) != len(want) {
+			t.Fatalf("%s: unexpected number of transactions: got "+
+				"%d, want %d", name, len(txns), len(want))
+		}
+		for i, desc := range want {
+			if txns[i].TxHash() != *desc.Tx.Hash() {
+				t.Fatalf("%s: unexpected transaction %d: got %v, "+
+					"want %v", name, i, txns[i].TxHash(),
+					desc.Tx.Hash())
+			}
+		}
+
+		scratch, err := g.NewBlockTemplate(nil)
+		if err != nil {
+			t.Fatalf("%s: unable to create block template: %v", name,
+				err)
+		}
+		if scratch.Block.Header.MerkleRoot !=
+			template.Block.Header.MerkleRoot {
+
+			t.Fatalf("%s: template differs from one generated from "+
+				"scratch", name)
+		}
+		if len(m.sorted) != len(source.descs) {
+			t.Fatalf("%s: unexpected number of candidates: got %d, "+
+				"want %d", name, len(m.sorted), len(source.descs))
+		}
+		return template
+	}
+
+	// The candidate set is initially populated from the source pool.
+	parent := newSpendTxDesc(coinbases[0], 1000)
+	source.descs = []*TxDesc{parent}
+	checkTemplate("initial", parent)
+
+	// Added transactions are included while the candidate set is kept
+	// sorted by fee rate.
+	child := newSpendTxDesc(parent.Tx, 5000)
+	other := newSpendTxDesc(coinbases[1], 2000)
+	source.descs = append(source.descs, child, other)
+	m.TxAdded(child)
+	m.TxAdded(other)
+	checkTemplate("added", parent, child, other)
+	for i, want := range []*TxDesc{child, other, parent} {
+		if m.sorted[i].desc != want {
+			t.Fatalf("unexpected candidate %d: got %v, want %v", i,
+				m.sorted[i].desc.Tx.Hash(), want.Tx.Hash())
+		}
+	}
+
+	// A new descriptor for an existing candidate replaces it and moves it
+	// according to its new fee rate.
+	prioritised := *parent
+	prioritised.FeeDelta = 100000
+	source.descs[0] = &prioritised
+	m.TxAdded(&prioritised)
+	checkTemplate("prioritised", &prioritised, child, other)
+	if m.sorted[0].desc != &prioritised {
+		t.Fatalf("prioritised candidate was not moved to the front")
+	}
+
+	// Removed transactions are no longer included.
+	source.descs = []*TxDesc{&prioritised, other}
+	m.TxRemoved(child.Tx.Hash())
+	template := checkTemplate("removed", &prioritised, other)
+
+	// Connecting the template to the best chain causes the candidate set
+	// to be rebuilt from the source pool for the new tip.
+	msgBlock := template.Block
+	target := blockchain.CompactToBig(msgBlock.Header.Bits)
+	for {
+		hash := msgBlock.Header.BlockHash()
+		if blockchain.HashToBig(&hash).Cmp(target) <= 0 {
+			break
+		}
+		msgBlock.Header.Nonce++
+	}
+	_, isOrphan, err := g.chain.ProcessBlock(btcutil.NewBlock(msgBlock),
+		blockchain.BFNone)
+	if err != nil || isOrphan {
+		t.Fatalf("unable to process block: %v (orphan %v)", err, isOrphan)
+	}
+	if m.synced {
+		t.Fatalf("candidate set still synced after tip change")
+	}
+	spend := newSpendTxDesc(other.Tx, 1000)
+	source.descs = []*TxDesc{spend}
+	checkTemplate("new tip", spend)
+
+	// Changes are delivered to the notification callback once started,
+	// while templates which include the same transactions are not.
+	m.Start()
+	defer m.Stop()
+	m.TxAdded(spend)
+	select {
+	case template := <-notified:
+		if len(template.Block.Transactions) != 2 {
+			t.Fatalf("unexpected number of transactions in notified "+
+				"template: %d", len(template.Block.Transactions))
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timeout waiting for template notification")
+	}
+	m.TxAdded(spend)
+	select {
+	case <-notified:
+		t.Fatalf("unexpected notification for unchanged template")
+	case <-time.After(2 * templateNotifyDelay):
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// netPermissions is a set of permission flags granted to peers which connect
+// from a whitelisted network or through a whitebind listener.
+type netPermissions uint32
+
+const (
+	// permBloomFilter allows the peer to use BIP0037 bloom filters even
+	// when they are disabled with --nopeerbloomfilters.  The bloom filter
+	// service is advertised to the peer accordingly.
+	permBloomFilter netPermissions = 1 << iota
+
+	// permRelay allows the peer to relay transactions even with
+	// --blocksonly and exempts its transactions from the rate limiting of
+	// free transactions.
+	permRelay
+
+	// permForceRelay relays the transactions of the peer to other peers
+	// even when they are already in the memory pool.  It implies
+	// permRelay.
+	permForceRelay
+
+	// permDownload allows the peer to request headers while the chain is
+	// not current and exempts it from the upload target.
+	permDownload
+
+	// permNoBan prevents the peer from being banned, disconnected for
+	// misbehavior or evicted.  It implies permDownload.
+	permNoBan
+
+	// permMempool allows the peer to request the memory pool with BIP0035
+	// mempool messages even when bloom filters are disabled.
+	permMempool
+
+	// permAddr allows the peer to request addresses at any time, including
+	// repeated requests and requests from outbound connections.
+	permAddr
+
+	// permAll is the set of all permissions.
+	permAll = permBloomFilter | permRelay | permForceRelay | permDownload |
+		permNoBan | permMempool | permAddr
+
+	// permDefault is the set of permissions granted to whitelisted peers
+	// when no permissions are specified.
+	permDefault = permNoBan | permDownload | permRelay | permMempool
+)
+
+// netPermissionNames maps the names of the permissions used in the
+// configuration and the getpeerinfo RPC to the permissions in the order they
+// are reported in.
+var netPermissionNames = []struct {
+	name string
+	perm netPermissions
+}{
+	{"bloomfilter", permBloomFilter},
+	{"noban", permNoBan},
+	{"forcerelay", permForceRelay},
+	{"relay", permRelay},
+	{"mempool", permMempool},
+	{"download", permDownload},
+	{"addr", permAddr},
+}
+
+// has returns whether all of the passed permissions are granted.
+func (p netPermissions) has(perm netPermissions) bool {
+	return p&perm == perm
+}
+
+// names returns the names of the granted permissions.
+func (p netPermissions) names() []string {
+	names := make([]string, 0, len(netPermissionNames))
+	for _, n := range netPermissionNames {
+		if p.has(n.perm) {
+			names = append(names, n.name)
+		}
+	}
+	return names
+}
+
+// parseNetPermissions parses a comma-separated list of permission names, which
+// may include "all" for all permissions, and adds the permissions implied by
+// the listed ones.
+func parseNetPermissions(s string) (netPermissions, error) {
+	var perms netPermissions
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "all" {
+			perms |= permAll
+			continue
+		}
+
+		found := false
+		for _, n := range netPermissionNames {
+			if n.name == name {
+				perms |= n.perm
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("invalid permission %q", name)
+		}
+	}
+
+	if perms.has(permForceRelay) {
+		perms |= permRelay
+	}
+	if perms.has(permNoBan) {
+		perms |= permDownload
+	}
+	return perms, nil
+}
+
+// parsePermissionEntry splits a --whitelist or --whitebind entry in the form
+// [permissions@]value into its permissions and value.  The default
+// permissions are granted when none are specified.
+func parsePermissionEntry(entry string) (netPermissions, string, error) {
+	sep := strings.Index(entry, "@")
+	if sep < 0 {
+		return permDefault, entry, nil
+	}
+
+	perms, err := parseNetPermissions(entry[:sep])
+	if err != nil {
+		return 0, "", err
+	}
+	return perms, entry[sep+1:], nil
+}
+
+// whitelist is an IP network whose peers are granted permissions.
+type whitelist struct {
+	ipnet       *net.IPNet
+	permissions netPermissions
+}
+
+// whitebind is a listen address whose peers are granted permissions.
+type whitebind struct {
+	addr        string
+	permissions netPermissions
+}
+
+// permissionListener wraps a whitebind listener to grant the permissions of
+// the whitebind to the connections it accepts.
+type permissionListener struct {
+	net.Listener
+	permissions netPermissions
+}
+
+// permissionConn is a connection accepted by a whitebind listener along with
+// the permissions granted to the peer.
+type permissionConn struct {
+	net.Conn
+	permissions netPermissions
+}
+
+// Accept waits for and returns the next connection to the listener along with
+// the permissions of the whitebind.
+//
+// This is part of the net.Listener interface.
+func (l *permissionListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &permissionConn{Conn: conn, permissions: l.permissions}, nil
+}
+
+// connPermissions returns the permissions granted to the peer of the passed
+// connection by the whitelisted networks and the whitebind listener it was
+// accepted by, if any.
+func connPermissions(conn net.Conn) netPermissions {
+	perms := whitelistPermissions(conn.RemoteAddr())
+	if pc, ok := conn.(*permissionConn); ok {
+		perms |= pc.permissions
+	}
+	return perms
+}
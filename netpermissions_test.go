@@ -0,0 +1,139 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+// TestParsePermissionEntry ensures whitelist and whitebind entries are split
+// into their permissions and values, including the implied permissions.
+func TestParsePermissionEntry(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		entry     string
+		wantPerms netPermissions
+		wantValue string
+		wantNames []string
+		wantErr   bool
+	}{
+		{
+			name:      "default permissions",
+			entry:     "192.168.1.0/24",
+			wantPerms: permDefault,
+			wantValue: "192.168.1.0/24",
+			wantNames: []string{"noban", "relay", "mempool", "download"},
+		},
+		{
+			name:      "single permission",
+			entry:     "mempool@::1",
+			wantPerms: permMempool,
+			wantValue: "::1",
+			wantNames: []string{"mempool"},
+		},
+		{
+			name:      "implied permissions",
+			entry:     "noban,forcerelay@127.0.0.1:8333",
+			wantPerms: permNoBan | permDownload | permForceRelay | permRelay,
+			wantValue: "127.0.0.1:8333",
+			wantNames: []string{"noban", "forcerelay", "relay", "download"},
+		},
+		{
+			name:      "all permissions",
+			entry:     "all@10.0.0.1",
+			wantPerms: permAll,
+			wantValue: "10.0.0.1",
+			wantNames: []string{"bloomfilter", "noban", "forcerelay",
+				"relay", "mempool", "download", "addr"},
+		},
+		{
+			name:      "spaces around names",
+			entry:     "bloomfilter, addr@10.0.0.1",
+			wantPerms: permBloomFilter | permAddr,
+			wantValue: "10.0.0.1",
+			wantNames: []string{"bloomfilter", "addr"},
+		},
+		{
+			name:    "unknown permission",
+			entry:   "noban,bogus@10.0.0.1",
+			wantErr: true,
+		},
+		{
+			name:    "empty permissions",
+			entry:   "@10.0.0.1",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		perms, value, err := parsePermissionEntry(test.entry)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if perms != test.wantPerms {
+			t.Errorf("%s: got permissions %b, want %b", test.name,
+				perms, test.wantPerms)
+		}
+		if value != test.wantValue {
+			t.Errorf("%s: got value %q, want %q", test.name, value,
+				test.wantValue)
+		}
+		if names := perms.names(); !reflect.DeepEqual(names,
+			test.wantNames) {
+
+			t.Errorf("%s: got names %v, want %v", test.name, names,
+				test.wantNames)
+		}
+	}
+}
+
+// TestPermissionListener ensures connections accepted by a whitebind listener
+// carry the permissions of the whitebind.
+func TestPermissionListener(t *testing.T) {
+	t.Parallel()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	listener := &permissionListener{
+		Listener:    l,
+		permissions: permRelay | permMempool,
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+	}()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("unable to accept: %v", err)
+	}
+	defer conn.Close()
+
+	pc, ok := conn.(*permissionConn)
+	if !ok {
+		t.Fatalf("accepted connection is a %T", conn)
+	}
+	if pc.permissions != permRelay|permMempool {
+		t.Fatalf("got permissions %b, want %b", pc.permissions,
+			permRelay|permMempool)
+	}
+}
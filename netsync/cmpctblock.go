@@ -0,0 +1,462 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	peerpkg "github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// maxExtraTxns is the maximum number of transactions which are not in
+	// the memory pool, such as rejected, orphan and replaced transactions,
+	// that are kept around for compact block reconstruction.
+	maxExtraTxns = 100
+
+	// maxHighBandwidthPeers is the maximum number of peers which are
+	// requested to announce new blocks with cmpctblock messages as
+	// recommended by BIP0152.
+	maxHighBandwidthPeers = 3
+)
+
+var (
+	// errInvalidCmpctBlock is returned when a cmpctblock or blocktxn
+	// message is malformed in a way which can only be explained by the
+	// peer misbehaving.
+	errInvalidCmpctBlock = errors.New("invalid compact block")
+
+	// errShortIDCollision is returned when a cmpctblock message contains
+	// the same short transaction id more than once, in which case the
+	// block can't be reconstructed and must be requested in full.
+	errShortIDCollision = errors.New("duplicate short transaction id")
+)
+
+// extraTxnCache houses a limited number of recently seen transactions which
+// are not in the memory pool in order to increase the chances of
+// reconstructing compact blocks without a round trip.  Once full, the oldest
+// transaction is replaced by the next one added.
+type extraTxnCache struct {
+	mtx  sync.Mutex
+	txns []*btcutil.Tx
+	next int
+}
+
+// Add adds the passed transaction to the cache, replacing the oldest
+// transaction when the cache is full.
+//
+// This function is safe for concurrent access.
+func (c *extraTxnCache) Add(tx *btcutil.Tx) {
+	c.mtx.Lock()
+	if len(c.txns) < maxExtraTxns {
+		c.txns = append(c.txns, tx)
+	} else {
+		c.txns[c.next] = tx
+		c.next = (c.next + 1) % maxExtraTxns
+	}
+	c.mtx.Unlock()
+}
+
+// Txns returns a copy of the transactions in the cache.
+//
+// This function is safe for concurrent access.
+func (c *extraTxnCache) Txns() []*btcutil.Tx {
+	c.mtx.Lock()
+	txns := make([]*btcutil.Tx, len(c.txns))
+	copy(txns, c.txns)
+	c.mtx.Unlock()
+	return txns
+}
+
+// partialBlock houses a block which is being reconstructed from a cmpctblock
+// message.  The transactions of the block which could not be found locally
+// are nil until they are provided by a blocktxn message.
+type partialBlock struct {
+	header  wire.BlockHeader
+	txns    []*wire.MsgTx
+	missing []uint32
+}
+
+// newPartialBlock reconstructs as much of the block described by the passed
+// cmpctblock message as possible from its prefilled transactions and the
+// passed transactions, which are typically those in the memory pool followed
+// by extra transactions.  Slots of the block whose short transaction id
+// matches more than one of the passed transactions are treated as missing.
+//
+// errInvalidCmpctBlock is returned when the message is malformed and
+// errShortIDCollision when the message contains duplicate short transaction
+// ids.
+func newPartialBlock(msg *wire.MsgCmpctBlock, txns []*btcutil.Tx) (*partialBlock, error) {
+	txCount := msg.TxCount()
+	if len(msg.PrefilledTxs) == 0 && len(msg.ShortIDs) == 0 {
+		return nil, errInvalidCmpctBlock
+	}
+
+	block := &partialBlock{
+		header: msg.Header,
+		txns:   make([]*wire.MsgTx, txCount),
+	}
+	for _, prefilled := range msg.PrefilledTxs {
+		if int(prefilled.Index) >= txCount || prefilled.Tx == nil {
+			return nil, errInvalidCmpctBlock
+		}
+		block.txns[prefilled.Index] = prefilled.Tx
+	}
+
+	// Map the short transaction ids to the slots of the block which are
+	// not prefilled in order.
+	slots := make(map[uint64]int, len(msg.ShortIDs))
+	nextID := 0
+	for i, tx := range block.txns {
+		if tx != nil {
+			continue
+		}
+		if nextID >= len(msg.ShortIDs) {
+			return nil, errInvalidCmpctBlock
+		}
+		shortID := msg.ShortIDs[nextID]
+		if _, exists := slots[shortID]; exists {
+			return nil, errShortIDCollision
+		}
+		slots[shortID] = i
+		nextID++
+	}
+
+	// Fill the slots with the matching transactions.  A slot which
+	// matches different transactions is left empty since it is not known
+	// which one, if any, is part of the block.
+	key := msg.ShortTxIDKey()
+	collided := make(map[int]struct{})
+	for _, tx := range txns {
+		i, ok := slots[key.ShortTxID(tx.WitnessHash())]
+		if !ok {
+			continue
+		}
+		if _, exists := collided[i]; exists {
+			continue
+		}
+		if block.txns[i] != nil {
+			if block.txns[i].WitnessHash() != *tx.WitnessHash() {
+				block.txns[i] = nil
+				collided[i] = struct{}{}
+			}
+			continue
+		}
+		block.txns[i] = tx.MsgTx()
+	}
+
+	for i, tx := range block.txns {
+		if tx == nil {
+			block.missing = append(block.missing, uint32(i))
+		}
+	}
+	return block, nil
+}
+
+// fill fills the missing transactions of the block with the transactions of
+// the passed blocktxn message in order.  errInvalidCmpctBlock is returned when
+// the number of transactions does not match the number of missing ones.
+func (b *partialBlock) fill(msg *wire.MsgBlockTxn) error {
+	if len(msg.Transactions) != len(b.missing) {
+		return errInvalidCmpctBlock
+	}
+	for i, index := range b.missing {
+		b.txns[index] = msg.Transactions[i]
+	}
+	b.missing = nil
+	return nil
+}
+
+// block returns the reconstructed block once all of its transactions are
+// known.  An error is returned when the transactions do not match the merkle
+// root or witness commitment of the block, which happens when a short
+// transaction id matched the wrong transaction, in which case the block must
+// be requested in full.
+func (b *partialBlock) block() (*btcutil.Block, error) {
+	if len(b.missing) > 0 {
+		return nil, fmt.Errorf("block is missing %d transactions",
+			len(b.missing))
+	}
+
+	msgBlock := &wire.MsgBlock{
+		Header:       b.header,
+		Transactions: b.txns,
+	}
+	block := btcutil.NewBlock(msgBlock)
+	merkles := blockchain.BuildMerkleTreeStore(block.Transactions(), false)
+	calculatedMerkleRoot := merkles[len(merkles)-1]
+	if !b.header.MerkleRoot.IsEqual(calculatedMerkleRoot) {
+		return nil, fmt.Errorf("merkle root mismatch: got %v, want %v",
+			calculatedMerkleRoot, b.header.MerkleRoot)
+	}
+	if err := blockchain.ValidateWitnessCommitment(block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// requestFullBlock requests the block with the passed hash including witness
+// data from the passed peer and marks it as requested from the peer so the
+// resulting block message is accepted.
+func (sm *SyncManager) requestFullBlock(peer *peerpkg.Peer,
+	state *peerSyncState, blockHash *chainhash.Hash) {
+
+	limitAdd(sm.requestedBlocks, *blockHash, maxRequestedBlocks)
+	limitAdd(state.requestedBlocks, *blockHash, maxRequestedBlocks)
+
+	gdmsg := wire.NewMsgGetData()
+	gdmsg.AddInvVect(wire.NewInvVect(wire.InvTypeWitnessBlock, blockHash))
+	peer.QueueMessage(gdmsg, nil)
+}
+
+// reconstructionTxns returns the transactions compact blocks are reconstructed
+// from, which are the transactions in the memory pool followed by the extra
+// transactions.
+func (sm *SyncManager) reconstructionTxns() []*btcutil.Tx {
+	txDescs := sm.txMemPool.TxDescs()
+	extraTxns := sm.extraTxns.Txns()
+	txns := make([]*btcutil.Tx, 0, len(txDescs)+len(extraTxns))
+	for _, txDesc := range txDescs {
+		txns = append(txns, txDesc.Tx)
+	}
+	return append(txns, extraTxns...)
+}
+
+// handleCmpctBlockMsg handles cmpctblock messages from all peers.  Blocks are
+// reconstructed from the memory pool and the extra transactions when the chain
+// is current and their parent is known, any transactions which could not be
+// found are requested from the peer and the reconstructed block is then
+// processed like any other block.  Otherwise, the full block is requested.
+func (sm *SyncManager) handleCmpctBlockMsg(cmsg *cmpctBlockMsg) {
+	peer := cmsg.peer
+	state, exists := sm.peerStates[peer]
+	if !exists {
+		log.Warnf("Received cmpctblock message from unknown peer %s", peer)
+		return
+	}
+
+	msg := cmsg.cmpctBlock
+	blockHash := msg.Header.BlockHash()
+
+	// Ignore blocks which are already known.
+	haveBlock, err := sm.chain.HaveBlock(&blockHash)
+	if err != nil {
+		log.Errorf("Failed to check if block %v is known: %v",
+			blockHash, err)
+		return
+	}
+	if haveBlock {
+		delete(state.requestedBlocks, blockHash)
+		delete(sm.requestedBlocks, blockHash)
+		return
+	}
+
+	// Compact blocks may be announced without being requested, so ensure
+	// the peer had to do some work before looking into the block.
+	headerBlock := btcutil.NewBlock(&wire.MsgBlock{Header: msg.Header})
+	err = blockchain.CheckProofOfWork(headerBlock, sm.chainParams.PowLimit)
+	if err != nil {
+		log.Warnf("Got compact block %v with invalid proof of work "+
+			"from %s -- disconnecting", blockHash, peer.Addr())
+		peer.Disconnect()
+		return
+	}
+
+	// Ignore blocks which are already being downloaded from another peer.
+	_, requested := state.requestedBlocks[blockHash]
+	if _, exists := sm.requestedBlocks[blockHash]; exists && !requested {
+		return
+	}
+
+	// Only reconstruct blocks which are likely to extend the chain while
+	// it is current since the memory pool is unlikely to contain the
+	// transactions of any other blocks.
+	haveParent, err := sm.chain.HaveBlock(&msg.Header.PrevBlock)
+	if err != nil {
+		log.Errorf("Failed to check if block %v is known: %v",
+			msg.Header.PrevBlock, err)
+		return
+	}
+	if !sm.current() || !haveParent {
+		sm.requestFullBlock(peer, state, &blockHash)
+		return
+	}
+	limitAdd(sm.requestedBlocks, blockHash, maxRequestedBlocks)
+	limitAdd(state.requestedBlocks, blockHash, maxRequestedBlocks)
+
+	// Only a single block is reconstructed per peer at a time, so request
+	// any block which is still waiting for transactions in full.
+	if state.partialBlock != nil {
+		partialHash := state.partialBlock.header.BlockHash()
+		state.partialBlock = nil
+		if partialHash != blockHash {
+			sm.requestFullBlock(peer, state, &partialHash)
+		}
+	}
+
+	partial, err := newPartialBlock(msg, sm.reconstructionTxns())
+	switch {
+	case err == errInvalidCmpctBlock:
+		log.Warnf("Got invalid compact block %v from %s -- "+
+			"disconnecting", blockHash, peer.Addr())
+		peer.Disconnect()
+		return
+
+	case err != nil:
+		log.Debugf("Unable to reconstruct compact block %v from %s: "+
+			"%v -- requesting full block", blockHash, peer, err)
+		sm.requestFullBlock(peer, state, &blockHash)
+		return
+	}
+
+	// Request the transactions which could not be found from the peer.
+	if len(partial.missing) > 0 {
+		log.Debugf("Requesting %d of %d transactions of compact block "+
+			"%v from %s", len(partial.missing), len(partial.txns),
+			blockHash, peer)
+		state.partialBlock = partial
+		gbtmsg := wire.NewMsgGetBlockTxn(&blockHash)
+		for _, index := range partial.missing {
+			gbtmsg.AddIndex(index)
+		}
+		peer.QueueMessage(gbtmsg, nil)
+		return
+	}
+
+	sm.processPartialBlock(peer, state, partial)
+}
+
+// handleBlockTxnMsg handles blocktxn messages from all peers by completing the
+// block which is being reconstructed for the peer with the provided
+// transactions.
+func (sm *SyncManager) handleBlockTxnMsg(bmsg *blockTxnMsg) {
+	peer := bmsg.peer
+	state, exists := sm.peerStates[peer]
+	if !exists {
+		log.Warnf("Received blocktxn message from unknown peer %s", peer)
+		return
+	}
+
+	msg := bmsg.blockTxn
+	partial := state.partialBlock
+	if partial == nil || partial.header.BlockHash() != msg.BlockHash {
+		log.Debugf("Ignoring unrequested blocktxn message for block "+
+			"%v from %s", msg.BlockHash, peer)
+		return
+	}
+	state.partialBlock = nil
+
+	if err := partial.fill(msg); err != nil {
+		log.Warnf("Got %d transactions instead of %d for compact "+
+			"block %v from %s -- disconnecting",
+			len(msg.Transactions), len(partial.missing),
+			msg.BlockHash, peer.Addr())
+		peer.Disconnect()
+		return
+	}
+
+	sm.processPartialBlock(peer, state, partial)
+}
+
+// processPartialBlock processes the passed reconstructed block like any other
+// block received from the passed peer.  The full block is requested instead
+// when the reconstructed transactions don't match the block.
+func (sm *SyncManager) processPartialBlock(peer *peerpkg.Peer,
+	state *peerSyncState, partial *partialBlock) {
+
+	block, err := partial.block()
+	if err != nil {
+		blockHash := partial.header.BlockHash()
+		log.Debugf("Failed to reconstruct compact block %v from %s: "+
+			"%v -- requesting full block", blockHash, peer, err)
+		sm.requestFullBlock(peer, state, &blockHash)
+		return
+	}
+
+	sm.handleBlockMsg(&blockMsg{block: block, peer: peer})
+}
+
+// updateHighBandwidthPeers requests the passed peer, which just provided a new
+// valid block, to announce new blocks with cmpctblock messages.  When there
+// are already the maximum number of such peers, the one which provided a new
+// block the longest time ago is requested to stop doing so.
+func (sm *SyncManager) updateHighBandwidthPeers(peer *peerpkg.Peer) {
+	if !peer.SupportsCmpctBlocks() {
+		return
+	}
+
+	for i, hbPeer := range sm.hbPeers {
+		if hbPeer == peer {
+			copy(sm.hbPeers[i:], sm.hbPeers[i+1:])
+			sm.hbPeers[len(sm.hbPeers)-1] = peer
+			return
+		}
+	}
+
+	if len(sm.hbPeers) >= maxHighBandwidthPeers {
+		sm.hbPeers[0].PushSendCmpctMsg(false)
+		sm.hbPeers = append(sm.hbPeers[:0], sm.hbPeers[1:]...)
+	}
+	peer.PushSendCmpctMsg(true)
+	sm.hbPeers = append(sm.hbPeers, peer)
+}
+
+// removeHighBandwidthPeer removes the passed peer from the peers which were
+// requested to announce new blocks with cmpctblock messages.
+func (sm *SyncManager) removeHighBandwidthPeer(peer *peerpkg.Peer) {
+	for i, hbPeer := range sm.hbPeers {
+		if hbPeer == peer {
+			sm.hbPeers = append(sm.hbPeers[:i], sm.hbPeers[i+1:]...)
+			return
+		}
+	}
+}
+
+// QueueCmpctBlock adds the passed cmpctblock message and peer to the block
+// handling queue.  Responds to the done channel argument after the message is
+// processed.
+func (sm *SyncManager) QueueCmpctBlock(cmpctBlock *wire.MsgCmpctBlock,
+	peer *peerpkg.Peer, done chan struct{}) {
+
+	// Don't accept more blocks if we're shutting down.
+	if atomic.LoadInt32(&sm.shutdown) != 0 {
+		done <- struct{}{}
+		return
+	}
+
+	sm.msgChan <- &cmpctBlockMsg{cmpctBlock: cmpctBlock, peer: peer,
+		reply: done}
+}
+
+// QueueBlockTxn adds the passed blocktxn message and peer to the block
+// handling queue.  Responds to the done channel argument after the message is
+// processed.
+func (sm *SyncManager) QueueBlockTxn(blockTxn *wire.MsgBlockTxn,
+	peer *peerpkg.Peer, done chan struct{}) {
+
+	// Don't accept more blocks if we're shutting down.
+	if atomic.LoadInt32(&sm.shutdown) != 0 {
+		done <- struct{}{}
+		return
+	}
+
+	sm.msgChan <- &blockTxnMsg{blockTxn: blockTxn, peer: peer, reply: done}
+}
+
+// AddExtraTxn adds the passed transaction, which is not in the memory pool,
+// to the transactions compact blocks are reconstructed from.  This is
+// typically used for transactions which were replaced in the memory pool.
+//
+// This function is safe for concurrent access.
+func (sm *SyncManager) AddExtraTxn(tx *btcutil.Tx) {
+	sm.extraTxns.Add(tx)
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// newTestBlock returns a block with a coinbase transaction followed by the
+// passed number of transactions and a valid merkle root.
+func newTestBlock(numTxns int) *wire.MsgBlock {
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		SignatureScript: []byte{0x51, 0x51},
+		Sequence:        wire.MaxTxInSequenceNum,
+	})
+	coinbase.AddTxOut(wire.NewTxOut(5000000000, []byte{0x51}))
+
+	block := wire.NewMsgBlock(&wire.BlockHeader{})
+	block.AddTransaction(coinbase)
+	for i := 0; i < numTxns; i++ {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: *wire.NewOutPoint(
+				&chainhash.Hash{byte(i)}, 0),
+			Sequence: wire.MaxTxInSequenceNum,
+		})
+		tx.AddTxOut(wire.NewTxOut(int64(i), []byte{0x51}))
+		block.AddTransaction(tx)
+	}
+
+	merkles := blockchain.BuildMerkleTreeStore(
+		btcutil.NewBlock(block).Transactions(), false)
+	block.Header.MerkleRoot = *merkles[len(merkles)-1]
+	return block
+}
+
+// TestPartialBlock ensures blocks are reconstructed from compact blocks and
+// the available transactions, that missing transactions are filled in and
+// that malformed compact blocks are detected.
+func TestPartialBlock(t *testing.T) {
+	t.Parallel()
+
+	block := newTestBlock(4)
+	blockHash := block.BlockHash()
+	cmpctBlock := wire.NewMsgCmpctBlockFromBlock(block, 1)
+
+	// Reconstruct the block with all transactions available.
+	var txns []*btcutil.Tx
+	for _, tx := range block.Transactions[1:] {
+		txns = append(txns, btcutil.NewTx(tx))
+	}
+	partial, err := newPartialBlock(cmpctBlock, txns)
+	if err != nil {
+		t.Fatalf("newPartialBlock: unexpected error: %v", err)
+	}
+	if len(partial.missing) != 0 {
+		t.Fatalf("newPartialBlock: got %d missing transactions, want 0",
+			len(partial.missing))
+	}
+	reconstructed, err := partial.block()
+	if err != nil {
+		t.Fatalf("block: unexpected error: %v", err)
+	}
+	if *reconstructed.Hash() != blockHash {
+		t.Fatalf("block: got hash %v, want %v", reconstructed.Hash(),
+			blockHash)
+	}
+
+	// Reconstruct the block with some transactions missing and fill them
+	// in with a blocktxn message.
+	partial, err = newPartialBlock(cmpctBlock, txns[1:3])
+	if err != nil {
+		t.Fatalf("newPartialBlock: unexpected error: %v", err)
+	}
+	wantMissing := []uint32{1, 4}
+	if len(partial.missing) != len(wantMissing) {
+		t.Fatalf("newPartialBlock: got missing transactions %v, want %v",
+			partial.missing, wantMissing)
+	}
+	for i, index := range wantMissing {
+		if partial.missing[i] != index {
+			t.Fatalf("newPartialBlock: got missing transactions "+
+				"%v, want %v", partial.missing, wantMissing)
+		}
+	}
+	if _, err := partial.block(); err == nil {
+		t.Fatal("block: expected error for missing transactions")
+	}
+	blockTxn := wire.NewMsgBlockTxn(&blockHash)
+	blockTxn.AddTransaction(block.Transactions[1])
+	if err := partial.fill(blockTxn); err != errInvalidCmpctBlock {
+		t.Fatalf("fill: unexpected error: got %v, want %v", err,
+			errInvalidCmpctBlock)
+	}
+	blockTxn.AddTransaction(block.Transactions[4])
+	if err := partial.fill(blockTxn); err != nil {
+		t.Fatalf("fill: unexpected error: %v", err)
+	}
+	reconstructed, err = partial.block()
+	if err != nil {
+		t.Fatalf("block: unexpected error: %v", err)
+	}
+	if *reconstructed.Hash() != blockHash {
+		t.Fatalf("block: got hash %v, want %v", reconstructed.Hash(),
+			blockHash)
+	}
+
+	// Transactions which don't match the merkle root must be detected.
+	partial, err = newPartialBlock(cmpctBlock, txns[:2])
+	if err != nil {
+		t.Fatalf("newPartialBlock: unexpected error: %v", err)
+	}
+	blockTxn = wire.NewMsgBlockTxn(&blockHash)
+	blockTxn.AddTransaction(block.Transactions[4])
+	blockTxn.AddTransaction(block.Transactions[3])
+	if err := partial.fill(blockTxn); err != nil {
+		t.Fatalf("fill: unexpected error: %v", err)
+	}
+	if _, err := partial.block(); err == nil {
+		t.Fatal("block: expected error for mismatched merkle root")
+	}
+
+	// The same transaction matching a slot more than once, such as when
+	// it is both in the memory pool and an extra transaction, must not be
+	// treated as a collision.
+	partial, err = newPartialBlock(cmpctBlock, append(txns, txns...))
+	if err != nil {
+		t.Fatalf("newPartialBlock: unexpected error: %v", err)
+	}
+	if len(partial.missing) != 0 {
+		t.Fatalf("newPartialBlock: got %d missing transactions, want 0",
+			len(partial.missing))
+	}
+
+	// Duplicate short transaction ids require the full block.
+	duplicateMsg := *cmpctBlock
+	duplicateMsg.ShortIDs = append([]uint64(nil), cmpctBlock.ShortIDs...)
+	duplicateMsg.ShortIDs[1] = duplicateMsg.ShortIDs[0]
+	if _, err := newPartialBlock(&duplicateMsg, txns); err != errShortIDCollision {
+		t.Fatalf("newPartialBlock: unexpected error: got %v, want %v",
+			err, errShortIDCollision)
+	}
+
+	// Prefilled transactions beyond the end of the block are invalid.
+	invalidMsg := *cmpctBlock
+	invalidMsg.PrefilledTxs = []*wire.PrefilledTx{{
+		Index: uint32(cmpctBlock.TxCount()),
+		Tx:    block.Transactions[0],
+	}}
+	if _, err := newPartialBlock(&invalidMsg, txns); err != errInvalidCmpctBlock {
+		t.Fatalf("newPartialBlock: unexpected error: got %v, want %v",
+			err, errInvalidCmpctBlock)
+	}
+}
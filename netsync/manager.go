@@ -40,6 +40,16 @@ const (
 	// hashes to store in memory.
 	maxRequestedTxns = wire.MaxInvPerMsg
 
+	// maxRequestedOrphanParents is the maximum number of missing parents
+	// of orphan transactions which may be outstanding from a single peer.
+	maxRequestedOrphanParents = 100
+
+	// orphanParentTimeout is the time after which a missing parent of an
+	// orphan transaction which was requested from a peer is no longer
+	// considered outstanding when the peer neither sent it nor reported
+	// it as not found, so it may be requested again.
+	orphanParentTimeout = time.Minute
+
 	// maxStallDuration is the time after which we will disconnect our
 	// current sync peer if we haven't made progress.
 	maxStallDuration = 3 * time.Minute
@@ -65,6 +75,22 @@ type blockMsg struct {
 	reply chan struct{}
 }
 
+// cmpctBlockMsg packages a bitcoin cmpctblock message and the peer it came
+// from together so the block handler has access to that information.
+type cmpctBlockMsg struct {
+	cmpctBlock *wire.MsgCmpctBlock
+	peer       *peerpkg.Peer
+	reply      chan struct{}
+}
+
+// blockTxnMsg packages a bitcoin blocktxn message and the peer it came from
+// together so the block handler has access to that information.
+type blockTxnMsg struct {
+	blockTxn *wire.MsgBlockTxn
+	peer     *peerpkg.Peer
+	reply    chan struct{}
+}
+
 // invMsg packages a bitcoin inv message and the peer it came from together
 // so the block handler has access to that information.
 type invMsg struct {
@@ -94,9 +120,11 @@ type donePeerMsg struct {
 // txMsg packages a bitcoin tx message and the peer it came from together
 // so the block handler has access to that information.
 type txMsg struct {
-	tx    *btcutil.Tx
-	peer  *peerpkg.Peer
-	reply chan struct{}
+	tx         *btcutil.Tx
+	peer       *peerpkg.Peer
+	rateLimit  bool
+	forceRelay bool
+	reply      chan struct{}
 }
 
 // getSyncPeerMsg is a message type to be sent across the message channel for
@@ -152,6 +180,16 @@ type peerSyncState struct {
 	requestQueue    []*wire.InvVect
 	requestedTxns   map[chainhash.Hash]struct{}
 	requestedBlocks map[chainhash.Hash]struct{}
+
+	// orphanParents houses the hashes of the missing parents of orphan
+	// transactions which were requested from the peer that announced the
+	// orphans along with the time they were requested.
+	orphanParents map[chainhash.Hash]time.Time
+
+	// partialBlock houses the block which is being reconstructed from a
+	// cmpctblock message sent by the peer while its missing transactions
+	// are requested.
+	partialBlock *partialBlock
 }
 
 // limitAdd is a helper function for maps that require a maximum limit by
@@ -198,6 +236,17 @@ type SyncManager struct {
 	peerStates       map[*peerpkg.Peer]*peerSyncState
 	lastProgressTime time.Time
 
+	// lowFeeTxns houses the transactions which were rejected only
+	// because they don't pay enough fees on their own.  They are not
+	// requested again when announced, but may still be requested as the
+	// missing parent of an orphan which might pay for them as a package.
+	lowFeeTxns map[chainhash.Hash]struct{}
+
+	// hbPeers houses the peers which were requested to announce new blocks
+	// with cmpctblock messages ordered from the least to the most recent
+	// one to provide a new block.
+	hbPeers []*peerpkg.Peer
+
 	// The following fields are used for headers-first mode.
 	headersFirstMode bool
 	headerList       *list.List
@@ -206,6 +255,10 @@ type SyncManager struct {
 
 	// An optional fee estimator.
 	feeEstimator *mempool.FeeEstimator
+
+	// extraTxns houses transactions which are not in the memory pool for
+	// compact block reconstruction.
+	extraTxns extraTxnCache
 }
 
 // resetHeaderState sets the headers-first mode state to values appropriate for
@@ -434,6 +487,7 @@ func (sm *SyncManager) handleNewPeerMsg(peer *peerpkg.Peer) {
 		syncCandidate:   isSyncCandidate,
 		requestedTxns:   make(map[chainhash.Hash]struct{}),
 		requestedBlocks: make(map[chainhash.Hash]struct{}),
+		orphanParents:   make(map[chainhash.Hash]time.Time),
 	}
 
 	// Start syncing by choosing the best candidate if needed.
@@ -512,6 +566,7 @@ func (sm *SyncManager) handleDonePeerMsg(peer *peerpkg.Peer) {
 	log.Infof("Lost peer %s", peer)
 
 	sm.clearRequestedState(state)
+	sm.removeHighBandwidthPeer(peer)
 
 	if peer == sm.syncPeer {
 		// Update the sync peer. The server has already disconnected the
@@ -537,6 +592,12 @@ func (sm *SyncManager) clearRequestedState(state *peerSyncState) {
 	for blockHash := range state.requestedBlocks {
 		delete(sm.requestedBlocks, blockHash)
 	}
+
+	// The missing orphan parents requested from the peer are no longer
+	// outstanding since they are part of its requested transactions.
+	for txHash := range state.orphanParents {
+		delete(state.orphanParents, txHash)
+	}
 }
 
 // updateSyncPeer choose a new sync peer to replace the current one. If
@@ -580,6 +641,7 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 	// to disconnect peers for sending unsolicited transactions to provide
 	// interoperability.
 	txHash := tmsg.tx.Hash()
+	wtxid := tmsg.tx.WitnessHash()
 
 	// Ignore transactions that we have already rejected.  Do not
 	// send a reject message here because if the transaction was already
@@ -589,11 +651,18 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 			"transaction %v from %s", txHash, peer)
 		return
 	}
+	if _, exists = sm.lowFeeTxns[*txHash]; exists {
+		if _, requested := state.orphanParents[*txHash]; !requested {
+			log.Debugf("Ignoring unsolicited previously rejected "+
+				"transaction %v from %s", txHash, peer)
+			return
+		}
+	}
 
 	// Process the transaction to include validation, insertion in the
 	// memory pool, orphan handling, etc.
 	acceptedTxs, err := sm.txMemPool.ProcessTransaction(tmsg.tx,
-		true, true, mempool.Tag(peer.ID()))
+		true, tmsg.rateLimit, mempool.Tag(peer.ID()))
 
 	// Remove transaction from request maps. Either the mempool/chain
 	// already knows about it and as such we shouldn't have any more
@@ -601,11 +670,45 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 	// we'll retry next time we get an inv.
 	delete(state.requestedTxns, *txHash)
 	delete(sm.requestedTxns, *txHash)
+	delete(state.requestedTxns, *wtxid)
+	delete(sm.requestedTxns, *wtxid)
+	delete(state.orphanParents, *txHash)
+
+	// Relay a transaction which was rejected since it is already in the
+	// memory pool again when it was received from a peer whose
+	// transactions are force relayed.  Only the duplicate transaction rule
+	// is rejected with a duplicate code while the transaction itself is in
+	// the pool, and the witness hash must match as well so a transaction
+	// with different witness data isn't mistaken for the one in the pool.
+	if err != nil && tmsg.forceRelay {
+		code, _ := mempool.ErrToRejectErr(err)
+		txDesc, fetchErr := sm.txMemPool.FetchTxDesc(txHash)
+		if code == wire.RejectDuplicate && fetchErr == nil &&
+			txDesc.Tx.WitnessHash().IsEqual(wtxid) {
+
+			log.Debugf("Force relaying transaction %v from %s",
+				txHash, peer)
+			sm.peerNotifier.AnnounceNewTransactions(
+				[]*mempool.TxDesc{txDesc})
+			return
+		}
+	}
 
 	if err != nil {
 		// Do not request this transaction again until a new block
-		// has been processed.
-		limitAdd(sm.rejectedTxns, *txHash, maxRejectedTxns)
+		// has been processed, regardless of whether it is announced
+		// by its hash or witness hash.  Transactions which only lack
+		// fees may still be requested as the parent of an orphan.
+		rejectedTxns := sm.rejectedTxns
+		if code, _ := mempool.ErrToRejectErr(err); code ==
+			wire.RejectInsufficientFee {
+
+			rejectedTxns = sm.lowFeeTxns
+		}
+		limitAdd(rejectedTxns, *txHash, maxRejectedTxns)
+		if !wtxid.IsEqual(txHash) {
+			limitAdd(rejectedTxns, *wtxid, maxRejectedTxns)
+		}
 
 		// When the error is a rule error, it means the transaction was
 		// simply rejected as opposed to something actually going wrong,
@@ -614,6 +717,11 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 		if _, ok := err.(mempool.RuleError); ok {
 			log.Debugf("Rejected transaction %v from %s: %v",
 				txHash, peer, err)
+
+			// Rejected transactions might still be included in
+			// blocks, so keep them for compact block
+			// reconstruction.
+			sm.extraTxns.Add(tmsg.tx)
 		} else {
 			log.Errorf("Failed to process transaction %v: %v",
 				txHash, err)
@@ -626,9 +734,88 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 		return
 	}
 
+	// The transaction was added to the orphan pool when nothing was
+	// accepted, so request its missing parents from the peer.  Otherwise,
+	// record that the peer relayed a new transaction, which protects it
+	// from eviction.
+	if len(acceptedTxs) == 0 {
+		sm.extraTxns.Add(tmsg.tx)
+		sm.requestOrphanParents(peer, state, txHash)
+	} else {
+		peer.UpdateLastTxTime(time.Now())
+	}
+
 	sm.peerNotifier.AnnounceNewTransactions(acceptedTxs)
 }
 
+// requestOrphanParents requests the missing parents of the orphan transaction
+// with the passed hash from the peer which announced it so the orphan can be
+// resolved without waiting for the parents to be announced.
+func (sm *SyncManager) requestOrphanParents(peer *peerpkg.Peer,
+	state *peerSyncState, orphanHash *chainhash.Hash) {
+
+	parents := sm.txMemPool.OrphanMissingParents(orphanHash)
+	sm.requestMissingParents(peer, state, orphanHash, parents, time.Now())
+}
+
+// expireOrphanParents removes the missing orphan parents requested from the
+// peer with the passed sync state which have been outstanding for longer than
+// orphanParentTimeout as of the passed time, so they no longer count towards
+// the limit of outstanding parents and may be requested again.
+func (sm *SyncManager) expireOrphanParents(state *peerSyncState, now time.Time) {
+	for txHash, requested := range state.orphanParents {
+		if now.Sub(requested) < orphanParentTimeout {
+			continue
+		}
+		delete(state.orphanParents, txHash)
+		delete(state.requestedTxns, txHash)
+		delete(sm.requestedTxns, txHash)
+	}
+}
+
+// requestMissingParents requests the passed missing parents of the orphan
+// transaction with the passed hash from the peer which announced it as of the
+// passed time.  Parents which are already requested or were rejected are not
+// requested again, and the number of parents outstanding from a peer is
+// limited in order to prevent peers from using orphans to make us request
+// arbitrary transactions.
+func (sm *SyncManager) requestMissingParents(peer *peerpkg.Peer,
+	state *peerSyncState, orphanHash *chainhash.Hash,
+	parents []*chainhash.Hash, now time.Time) {
+
+	sm.expireOrphanParents(state, now)
+
+	gdmsg := wire.NewMsgGetData()
+	for _, parentHash := range parents {
+		if _, exists := sm.requestedTxns[*parentHash]; exists {
+			continue
+		}
+		if _, exists := sm.rejectedTxns[*parentHash]; exists {
+			continue
+		}
+		if len(state.orphanParents) >= maxRequestedOrphanParents {
+			log.Debugf("Not requesting parents of orphan %v from %s "+
+				"-- too many outstanding parents", orphanHash, peer)
+			break
+		}
+
+		state.orphanParents[*parentHash] = now
+		limitAdd(sm.requestedTxns, *parentHash, maxRequestedTxns)
+		limitAdd(state.requestedTxns, *parentHash, maxRequestedTxns)
+
+		invType := wire.InvTypeTx
+		if peer.IsWitnessEnabled() {
+			invType = wire.InvTypeWitnessTx
+		}
+		gdmsg.AddInvVect(wire.NewInvVect(invType, parentHash))
+	}
+	if len(gdmsg.InvList) > 0 {
+		log.Debugf("Requesting %d missing parents of orphan %v from %s",
+			len(gdmsg.InvList), orphanHash, peer)
+		peer.QueueMessage(gdmsg, nil)
+	}
+}
+
 // current returns true if we believe we are synced with our peers, false if we
 // still have blocks to check
 func (sm *SyncManager) current() bool {
@@ -704,6 +891,11 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 	// will fail the insert and thus we'll retry next time we get an inv.
 	delete(state.requestedBlocks, *blockHash)
 	delete(sm.requestedBlocks, *blockHash)
+	if state.partialBlock != nil &&
+		state.partialBlock.header.BlockHash() == *blockHash {
+
+		state.partialBlock = nil
+	}
 
 	// Process the block to include validation, best chain selection, orphan
 	// handling, etc.
@@ -779,6 +971,10 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 			sm.lastProgressTime = time.Now()
 		}
 
+		// Record that the peer relayed a new block, which protects it
+		// from eviction.
+		peer.UpdateLastBlockTime(time.Now())
+
 		// When the block is not an orphan, log information about it and
 		// update the chain state.
 		sm.progressLogger.LogBlockHeight(bmsg.block)
@@ -791,6 +987,13 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 
 		// Clear the rejected transactions.
 		sm.rejectedTxns = make(map[chainhash.Hash]struct{})
+		sm.lowFeeTxns = make(map[chainhash.Hash]struct{})
+
+		// Prefer peers which provide new blocks to announce them
+		// with compact blocks once the chain is current.
+		if sm.current() {
+			sm.updateHighBandwidthPeers(peer)
+		}
 	}
 
 	// Update the block height for this peer. But only send a message to
@@ -1027,10 +1230,13 @@ func (sm *SyncManager) handleNotFoundMsg(nfmsg *notFoundMsg) {
 		log.Warnf("Received notfound message from unknown peer %s", peer)
 		return
 	}
+	var numOrphanParents int
 	for _, inv := range nfmsg.notFound.InvList {
 		// verify the hash was actually announced by the peer
 		// before deleting from the global requested maps.
 		switch inv.Type {
+		case wire.InvTypeCmpctBlock:
+			fallthrough
 		case wire.InvTypeWitnessBlock:
 			fallthrough
 		case wire.InvTypeBlock:
@@ -1039,6 +1245,8 @@ func (sm *SyncManager) handleNotFoundMsg(nfmsg *notFoundMsg) {
 				delete(sm.requestedBlocks, inv.Hash)
 			}
 
+		case wire.InvTypeWTx:
+			fallthrough
 		case wire.InvTypeWitnessTx:
 			fallthrough
 		case wire.InvTypeTx:
@@ -1046,8 +1254,20 @@ func (sm *SyncManager) handleNotFoundMsg(nfmsg *notFoundMsg) {
 				delete(state.requestedTxns, inv.Hash)
 				delete(sm.requestedTxns, inv.Hash)
 			}
+			if _, exists := state.orphanParents[inv.Hash]; exists {
+				delete(state.orphanParents, inv.Hash)
+				numOrphanParents++
+			}
 		}
 	}
+
+	// Honest peers report missing orphan parents as not found when they
+	// were mined or evicted from their memory pool in the meantime, so the
+	// parents simply stop being outstanding.
+	if numOrphanParents > 0 {
+		log.Debugf("Peer %s does not have %d missing orphan parent(s)",
+			peer, numOrphanParents)
+	}
 }
 
 // haveInventory returns whether or not the inventory represented by the passed
@@ -1057,6 +1277,8 @@ func (sm *SyncManager) handleNotFoundMsg(nfmsg *notFoundMsg) {
 // are in the memory pool (either the main pool or orphan pool).
 func (sm *SyncManager) haveInventory(invVect *wire.InvVect) (bool, error) {
 	switch invVect.Type {
+	case wire.InvTypeCmpctBlock:
+		fallthrough
 	case wire.InvTypeWitnessBlock:
 		fallthrough
 	case wire.InvTypeBlock:
@@ -1064,6 +1286,13 @@ func (sm *SyncManager) haveInventory(invVect *wire.InvVect) (bool, error) {
 		// chain, side chain, or orphan).
 		return sm.chain.HaveBlock(&invVect.Hash)
 
+	case wire.InvTypeWTx:
+		// Ask the transaction memory pool if the transaction is known
+		// to it in any form (main pool or orphan).  Unlike below, the
+		// chain can't be checked since it's not indexed by witness
+		// hash.
+		return sm.txMemPool.HaveTransactionByWitnessHash(&invVect.Hash), nil
+
 	case wire.InvTypeWitnessTx:
 		fallthrough
 	case wire.InvTypeTx:
@@ -1155,6 +1384,15 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 		switch iv.Type {
 		case wire.InvTypeBlock:
 		case wire.InvTypeTx:
+			// Peers which negotiated wtxid relay must announce
+			// transactions by their witness hash.
+			if peer.WantsWTxIdRelay() {
+				continue
+			}
+		case wire.InvTypeWTx:
+			if !peer.WantsWTxIdRelay() {
+				continue
+			}
 		case wire.InvTypeWitnessBlock:
 		case wire.InvTypeWitnessTx:
 		default:
@@ -1179,12 +1417,15 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 			continue
 		}
 		if !haveInv {
-			if iv.Type == wire.InvTypeTx {
+			if iv.Type == wire.InvTypeTx || iv.Type == wire.InvTypeWTx {
 				// Skip the transaction if it has already been
 				// rejected.
 				if _, exists := sm.rejectedTxns[iv.Hash]; exists {
 					continue
 				}
+				if _, exists := sm.lowFeeTxns[iv.Hash]; exists {
+					continue
+				}
 			}
 
 			// Ignore invs block invs from non-witness enabled
@@ -1261,9 +1502,16 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 				limitAdd(sm.requestedBlocks, iv.Hash, maxRequestedBlocks)
 				limitAdd(state.requestedBlocks, iv.Hash, maxRequestedBlocks)
 
+				// Request new blocks as compact blocks from
+				// capable peers once the chain is current
+				// since their transactions are likely in the
+				// memory pool already.
 				if peer.IsWitnessEnabled() {
 					iv.Type = wire.InvTypeWitnessBlock
 				}
+				if peer.SupportsCmpctBlocks() && sm.current() {
+					iv.Type = wire.InvTypeCmpctBlock
+				}
 
 				gdmsg.AddInvVect(iv)
 				numRequested++
@@ -1284,6 +1532,18 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 					iv.Type = wire.InvTypeWitnessTx
 				}
 
+				gdmsg.AddInvVect(iv)
+				numRequested++
+			}
+
+		case wire.InvTypeWTx:
+			// Request the transaction by its witness hash if there
+			// is not already a pending request.  The response
+			// always includes all witness data.
+			if _, exists := sm.requestedTxns[iv.Hash]; !exists {
+				limitAdd(sm.requestedTxns, iv.Hash, maxRequestedTxns)
+				limitAdd(state.requestedTxns, iv.Hash, maxRequestedTxns)
+
 				gdmsg.AddInvVect(iv)
 				numRequested++
 			}
@@ -1325,6 +1585,14 @@ out:
 				sm.handleBlockMsg(msg)
 				msg.reply <- struct{}{}
 
+			case *cmpctBlockMsg:
+				sm.handleCmpctBlockMsg(msg)
+				msg.reply <- struct{}{}
+
+			case *blockTxnMsg:
+				sm.handleBlockTxnMsg(msg)
+				msg.reply <- struct{}{}
+
 			case *invMsg:
 				sm.handleInvMsg(msg)
 
@@ -1405,7 +1673,7 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 
 		// Generate the inventory vector and relay it.
 		iv := wire.NewInvVect(wire.InvTypeBlock, block.Hash())
-		sm.peerNotifier.RelayInventory(iv, block.MsgBlock().Header)
+		sm.peerNotifier.RelayInventory(iv, block)
 
 	// A block has been connected to the main block chain.
 	case blockchain.NTBlockConnected:
@@ -1421,9 +1689,12 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 		// new transactions.  Finally, remove any transaction that is
 		// no longer an orphan. Transactions which depend on a confirmed
 		// transaction are NOT removed recursively because they are still
-		// valid.
+		// valid.  Any fee delta applied to a confirmed transaction is no
+		// longer needed, so it is cleared as well.
 		for _, tx := range block.Transactions()[1:] {
-			sm.txMemPool.RemoveTransaction(tx, false)
+			sm.txMemPool.RemoveTransaction(tx, false,
+				mempool.RemovalReasonBlock)
+			sm.txMemPool.ClearFeeDelta(tx.Hash())
 			sm.txMemPool.RemoveDoubleSpends(tx)
 			sm.txMemPool.RemoveOrphan(tx)
 			sm.peerNotifier.TransactionConfirmed(tx)
@@ -1462,7 +1733,8 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 				// Remove the transaction and all transactions
 				// that depend on it if it wasn't accepted into
 				// the transaction pool.
-				sm.txMemPool.RemoveTransaction(tx, true)
+				sm.txMemPool.RemoveTransaction(tx, true,
+					mempool.RemovalReasonReorg)
 			}
 		}
 
@@ -1486,13 +1758,41 @@ func (sm *SyncManager) NewPeer(peer *peerpkg.Peer) {
 // queue. Responds to the done channel argument after the tx message is
 // processed.
 func (sm *SyncManager) QueueTx(tx *btcutil.Tx, peer *peerpkg.Peer, done chan struct{}) {
+	sm.QueueTxWithOptions(tx, peer, QueueTxOptions{}, done)
+}
+
+// QueueTxOptions houses the options which change how a transaction queued with
+// QueueTxWithOptions is handled depending on the permissions of the peer it
+// came from.  The zero value handles the transaction the same as QueueTx.
+type QueueTxOptions struct {
+	// NoRateLimit exempts the transaction from the rate limiting of free
+	// transactions.
+	NoRateLimit bool
+
+	// ForceRelay relays the transaction again when it is already in the
+	// memory pool.
+	ForceRelay bool
+}
+
+// QueueTxWithOptions adds the passed transaction message and peer to the block
+// handling queue to be handled according to the passed options.  Responds to
+// the done channel argument after the tx message is processed.
+func (sm *SyncManager) QueueTxWithOptions(tx *btcutil.Tx, peer *peerpkg.Peer,
+	opts QueueTxOptions, done chan struct{}) {
+
 	// Don't accept more transactions if we're shutting down.
 	if atomic.LoadInt32(&sm.shutdown) != 0 {
 		done <- struct{}{}
 		return
 	}
 
-	sm.msgChan <- &txMsg{tx: tx, peer: peer, reply: done}
+	sm.msgChan <- &txMsg{
+		tx:         tx,
+		peer:       peer,
+		rateLimit:  !opts.NoRateLimit,
+		forceRelay: opts.ForceRelay,
+		reply:      done,
+	}
 }
 
 // QueueBlock adds the passed block message and peer to the block handling
@@ -1628,6 +1928,7 @@ func New(config *Config) (*SyncManager, error) {
 		peerStates:      make(map[*peerpkg.Peer]*peerSyncState),
 		progressLogger:  newBlockProgressLogger("Processed", log),
 		msgChan:         make(chan interface{}, config.MaxPeers*3),
+		lowFeeTxns:      make(map[chainhash.Hash]struct{}),
 		headerList:      list.New(),
 		quit:            make(chan struct{}),
 		feeEstimator:    config.FeeEstimator,
@@ -0,0 +1,152 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	peerpkg "github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// newTestOrphanParents returns the passed number of distinct hashes of missing
+// orphan parents starting at the passed offset.
+func newTestOrphanParents(offset, n int) []*chainhash.Hash {
+	parents := make([]*chainhash.Hash, 0, n)
+	for i := offset; i < offset+n; i++ {
+		parents = append(parents, &chainhash.Hash{byte(i), byte(i >> 8), 1})
+	}
+	return parents
+}
+
+// TestRequestMissingParents ensures the number of missing orphan parents
+// outstanding from a peer is limited, that outstanding parents expire, and
+// that parents reported as not found or requested from a disconnected peer
+// are no longer outstanding.
+func TestRequestMissingParents(t *testing.T) {
+	t.Parallel()
+
+	peer, err := peerpkg.NewOutboundPeer(&peerpkg.Config{}, "127.0.0.1:8333")
+	if err != nil {
+		t.Fatalf("NewOutboundPeer: %v", err)
+	}
+	state := &peerSyncState{
+		requestedTxns:   make(map[chainhash.Hash]struct{}),
+		requestedBlocks: make(map[chainhash.Hash]struct{}),
+		orphanParents:   make(map[chainhash.Hash]time.Time),
+	}
+	sm := &SyncManager{
+		rejectedTxns:    make(map[chainhash.Hash]struct{}),
+		requestedTxns:   make(map[chainhash.Hash]struct{}),
+		requestedBlocks: make(map[chainhash.Hash]struct{}),
+		peerStates:      map[*peerpkg.Peer]*peerSyncState{peer: state},
+		lowFeeTxns:      make(map[chainhash.Hash]struct{}),
+	}
+	orphanHash := &chainhash.Hash{0xff}
+
+	// assertOutstanding ensures the passed parents are outstanding from the
+	// peer and the total number of outstanding parents is as expected.
+	assertOutstanding := func(desc string, parents []*chainhash.Hash, want int) {
+		t.Helper()
+
+		if len(state.orphanParents) != want {
+			t.Fatalf("%s: got %d outstanding parents, want %d", desc,
+				len(state.orphanParents), want)
+		}
+		for _, parent := range parents {
+			if _, ok := state.orphanParents[*parent]; !ok {
+				t.Fatalf("%s: parent %v not outstanding", desc, parent)
+			}
+			if _, ok := state.requestedTxns[*parent]; !ok {
+				t.Fatalf("%s: parent %v not requested from peer",
+					desc, parent)
+			}
+			if _, ok := sm.requestedTxns[*parent]; !ok {
+				t.Fatalf("%s: parent %v not requested", desc, parent)
+			}
+		}
+	}
+
+	// Parents which were rejected or are already requested are skipped,
+	// while parents which were only rejected for their fees are requested
+	// since the orphan might pay for them.
+	now := time.Now()
+	rejected := newTestOrphanParents(0, 1)
+	requested := newTestOrphanParents(1, 1)
+	lowFee := newTestOrphanParents(2, 1)
+	sm.rejectedTxns[*rejected[0]] = struct{}{}
+	sm.requestedTxns[*requested[0]] = struct{}{}
+	sm.lowFeeTxns[*lowFee[0]] = struct{}{}
+	parents := append(lowFee, newTestOrphanParents(3, 9)...)
+	sm.requestMissingParents(peer, state, orphanHash,
+		append(append(rejected, requested...), parents...), now)
+	assertOutstanding("skip known", parents, len(parents))
+	delete(sm.requestedTxns, *requested[0])
+
+	// Only parents up to the limit are requested.
+	overLimit := newTestOrphanParents(100, maxRequestedOrphanParents)
+	sm.requestMissingParents(peer, state, orphanHash, overLimit, now)
+	numRequested := maxRequestedOrphanParents - len(parents)
+	assertOutstanding("limit", overLimit[:numRequested],
+		maxRequestedOrphanParents)
+	for _, parent := range overLimit[numRequested:] {
+		if _, ok := sm.requestedTxns[*parent]; ok {
+			t.Fatalf("limit: parent %v over the limit requested", parent)
+		}
+	}
+
+	// Parents reported as not found are no longer outstanding while other
+	// requested transactions are left untouched.
+	notFound := wire.NewMsgNotFound()
+	for _, parent := range parents[:5] {
+		notFound.AddInvVect(wire.NewInvVect(wire.InvTypeWitnessTx, parent))
+	}
+	sm.handleNotFoundMsg(&notFoundMsg{notFound: notFound, peer: peer})
+	assertOutstanding("notfound", parents[5:],
+		maxRequestedOrphanParents-5)
+	for _, parent := range parents[:5] {
+		if _, ok := sm.requestedTxns[*parent]; ok {
+			t.Fatalf("notfound: parent %v still requested", parent)
+		}
+	}
+
+	// The parents freed by the notfound message can be requested again,
+	// while the remaining parents are still over the limit.
+	sm.requestMissingParents(peer, state, orphanHash,
+		overLimit[numRequested:], now.Add(orphanParentTimeout/2))
+	assertOutstanding("rerequest", overLimit[numRequested:numRequested+5],
+		maxRequestedOrphanParents)
+
+	// Parents outstanding for longer than the timeout expire, which
+	// allows new parents to be requested.
+	expired := now.Add(orphanParentTimeout)
+	fresh := newTestOrphanParents(300, 3)
+	sm.requestMissingParents(peer, state, orphanHash, fresh, expired)
+	assertOutstanding("expiry", append(fresh,
+		overLimit[numRequested:numRequested+5]...), len(fresh)+5)
+	for _, parent := range append(parents[5:], overLimit[:numRequested]...) {
+		if _, ok := sm.requestedTxns[*parent]; ok {
+			t.Fatalf("expiry: parent %v still requested", parent)
+		}
+		if _, ok := state.requestedTxns[*parent]; ok {
+			t.Fatalf("expiry: parent %v still requested from peer",
+				parent)
+		}
+	}
+
+	// Parents requested from a peer which disconnects are no longer
+	// outstanding.
+	sm.handleDonePeerMsg(peer)
+	if len(state.orphanParents) != 0 {
+		t.Fatalf("disconnect: got %d outstanding parents, want 0",
+			len(state.orphanParents))
+	}
+	if len(sm.requestedTxns) != 0 {
+		t.Fatalf("disconnect: got %d requested transactions, want 0",
+			len(sm.requestedTxns))
+	}
+}
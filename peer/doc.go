@@ -19,6 +19,8 @@ A quick overview of the major features peer provides are as follows:
  - Provides a basic concurrent safe bitcoin peer for handling bitcoin
    communications via the peer-to-peer protocol
  - Full duplex reading and writing of bitcoin protocol messages
+ - Optional v2 encrypted transport (BIP0324) with fallback to the v1 transport
+   for inbound peers which do not support it
  - Automatic handling of the initial handshake process including protocol
    version negotiation
  - Asynchronous message queuing of outbound messages with optional channel for
@@ -41,7 +43,10 @@ A quick overview of the major features peer provides are as follows:
    - Disconnects the peer when the protocol version is high enough
    - Does not invoke the related callbacks for older protocol versions
  - Snapshottable peer statistics such as the total number of bytes read and
-   written, the remote address, user agent, and negotiated protocol version
+   written, also broken down per message command, the remote address, user
+   agent, and negotiated protocol version
+ - Negotiation of transaction relay through set reconciliation (BIP0330) with
+   the salts needed to derive the short transaction ids
  - Helper functions pushing addresses, getblocks, getheaders, and reject
    messages
    - These could all be sent manually via the standard message output function,
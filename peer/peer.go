@@ -50,6 +50,11 @@ const (
 	// inventory cache.
 	maxKnownInventory = 1000
 
+	// otherMsgCommand is the command the bytes of messages which could not
+	// be decoded, such as those with unknown commands, are accounted for in
+	// the per message statistics.
+	otherMsgCommand = "*other*"
+
 	// pingInterval is the interval of time to wait in between sending ping
 	// messages.
 	pingInterval = 2 * time.Minute
@@ -203,19 +208,51 @@ type MessageListeners struct {
 	// OnSendAddrV2 is invoked when a peer receives a sendaddrv2 message.
 	OnSendAddrV2 func(p *Peer, msg *wire.MsgSendAddrV2)
 
+	// OnWTxIdRelay is invoked when a peer receives a wtxidrelay message.
+	OnWTxIdRelay func(p *Peer, msg *wire.MsgWTxIdRelay)
+
+	// OnSendCmpct is invoked when a peer receives a sendcmpct bitcoin
+	// message.
+	OnSendCmpct func(p *Peer, msg *wire.MsgSendCmpct)
+
+	// OnCmpctBlock is invoked when a peer receives a cmpctblock bitcoin
+	// message.
+	OnCmpctBlock func(p *Peer, msg *wire.MsgCmpctBlock)
+
+	// OnGetBlockTxn is invoked when a peer receives a getblocktxn bitcoin
+	// message.
+	OnGetBlockTxn func(p *Peer, msg *wire.MsgGetBlockTxn)
+
+	// OnBlockTxn is invoked when a peer receives a blocktxn bitcoin
+	// message.
+	OnBlockTxn func(p *Peer, msg *wire.MsgBlockTxn)
+
+	// OnReqRecon is invoked when a peer receives a reqrecon bitcoin
+	// message.
+	OnReqRecon func(p *Peer, msg *wire.MsgReqRecon)
+
+	// OnSketch is invoked when a peer receives a sketch bitcoin message.
+	OnSketch func(p *Peer, msg *wire.MsgSketch)
+
+	// OnReconcilDiff is invoked when a peer receives a reconcildiff bitcoin
+	// message.
+	OnReconcilDiff func(p *Peer, msg *wire.MsgReconcilDiff)
+
 	// OnRead is invoked when a peer receives a bitcoin message.  It
 	// consists of the number of bytes read, the message, and whether or not
 	// an error in the read occurred.  Typically, callers will opt to use
 	// the callbacks for the specific message types, however this can be
 	// useful for circumstances such as keeping track of server-wide byte
 	// counts or working with custom message types for which the peer does
-	// not directly provide a callback.
+	// not directly provide a callback.  The bytes of the v2 transport
+	// handshake are reported without a message.
 	OnRead func(p *Peer, bytesRead int, msg wire.Message, err error)
 
 	// OnWrite is invoked when we write a bitcoin message to a peer.  It
 	// consists of the number of bytes written, the message, and whether or
 	// not an error in the write occurred.  This can be useful for
-	// circumstances such as keeping track of server-wide byte counts.
+	// circumstances such as keeping track of server-wide byte counts.  The
+	// bytes of the v2 transport handshake are reported without a message.
 	OnWrite func(p *Peer, bytesWritten int, msg wire.Message, err error)
 }
 
@@ -270,6 +307,22 @@ type Config struct {
 	// not send inv messages for transactions.
 	DisableRelayTx bool
 
+	// DisableCmpctBlocks specifies if the remote peer should not be
+	// informed that compact blocks (BIP0152) are supported once the
+	// protocol has been negotiated.
+	DisableCmpctBlocks bool
+
+	// TxReconciliation specifies whether the remote peer should be
+	// informed that relaying transactions through set reconciliation
+	// (BIP0330) is supported during the handshake.  It has no effect when
+	// DisableRelayTx is set.
+	TxReconciliation bool
+
+	// V2Transport specifies whether the v2 encrypted transport protocol
+	// (BIP0324) is used.  Outbound peers initiate the v2 handshake, while
+	// inbound peers accept both v2 and v1 connections.
+	V2Transport bool
+
 	// Listeners houses callback functions to be invoked on receiving peer
 	// messages.
 	Listeners MessageListeners
@@ -393,6 +446,12 @@ type StatsSnap struct {
 	LastPingNonce  uint64
 	LastPingTime   time.Time
 	LastPingMicros int64
+
+	// BytesSentPerMsg and BytesRecvPerMsg hold the number of bytes sent and
+	// received per message command.  Messages with unknown commands are
+	// accounted for as otherMsgCommand.
+	BytesSentPerMsg map[string]uint64
+	BytesRecvPerMsg map[string]uint64
 }
 
 // HashFunc is a function which returns a block hash, height and error
@@ -442,7 +501,8 @@ type Peer struct {
 	connected     int32
 	disconnect    int32
 
-	conn net.Conn
+	conn      net.Conn
+	transport MessageTransport
 
 	// These fields are set at creation time and never modified, so they are
 	// safe to read from concurrently without a mutex.
@@ -462,6 +522,16 @@ type Peer struct {
 	verAckReceived       bool
 	witnessEnabled       bool
 	sendAddrV2           bool
+	wtxidRelay           bool   // peer sent a wtxidrelay message
+	txReconSalt          uint64 // salt we sent in our sendtxrcncl message
+	remoteTxReconSalt    uint64 // salt the peer sent in its sendtxrcncl
+	txReconSupported     bool   // both peers sent a sendtxrcncl message
+	cmpctBlocksSupported bool   // peer sent a supported sendcmpct message
+	sendCmpctPreferred   bool   // peer wants new blocks as cmpctblock
+	cmpctBlocksHB        bool   // we want new blocks as cmpctblock
+	v2Transport          bool   // connection uses the v2 transport
+	reconnectV1          bool   // peer only supports the v1 transport
+	sessionID            [32]byte
 
 	wireEncoding wire.MessageEncoding
 
@@ -484,6 +554,10 @@ type Peer struct {
 	lastPingNonce      uint64    // Set to nonce if we have a pending ping.
 	lastPingTime       time.Time // Time we sent last ping.
 	lastPingMicros     int64     // Time for last ping to return.
+	lastTxTime         time.Time // Time the last new tx was received.
+	lastBlockTime      time.Time // Time the last new block was received.
+	bytesSentPerMsg    map[string]uint64
+	bytesRecvPerMsg    map[string]uint64
 
 	stallControl  chan stallControlMsg
 	outputQueue   chan outMsg
@@ -519,6 +593,26 @@ func (p *Peer) UpdateLastBlockHeight(newHeight int32) {
 	p.statsMtx.Unlock()
 }
 
+// UpdateLastTxTime records that a transaction which was new to the local node
+// was received from the peer at the passed time.
+//
+// This function is safe for concurrent access.
+func (p *Peer) UpdateLastTxTime(t time.Time) {
+	p.statsMtx.Lock()
+	p.lastTxTime = t
+	p.statsMtx.Unlock()
+}
+
+// UpdateLastBlockTime records that a block which was new to the local node was
+// received from the peer at the passed time.
+//
+// This function is safe for concurrent access.
+func (p *Peer) UpdateLastBlockTime(t time.Time) {
+	p.statsMtx.Lock()
+	p.lastBlockTime = t
+	p.statsMtx.Unlock()
+}
+
 // UpdateLastAnnouncedBlock updates meta-data about the last block hash this
 // peer is known to have announced.
 //
@@ -539,6 +633,14 @@ func (p *Peer) AddKnownInventory(invVect *wire.InvVect) {
 	p.knownInventory.Add(invVect)
 }
 
+// IsKnownInventory returns whether the passed inventory is in the cache of
+// known inventory for the peer.
+//
+// This function is safe for concurrent access.
+func (p *Peer) IsKnownInventory(invVect *wire.InvVect) bool {
+	return p.knownInventory.Contains(invVect)
+}
+
 // StatsSnapshot returns a snapshot of the current peer flags and statistics.
 //
 // This function is safe for concurrent access.
@@ -572,6 +674,16 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 		LastPingNonce:  p.lastPingNonce,
 		LastPingMicros: p.lastPingMicros,
 		LastPingTime:   p.lastPingTime,
+		BytesSentPerMsg: make(map[string]uint64,
+			len(p.bytesSentPerMsg)),
+		BytesRecvPerMsg: make(map[string]uint64,
+			len(p.bytesRecvPerMsg)),
+	}
+	for command, n := range p.bytesSentPerMsg {
+		statsSnap.BytesSentPerMsg[command] = n
+	}
+	for command, n := range p.bytesRecvPerMsg {
+		statsSnap.BytesRecvPerMsg[command] = n
 	}
 
 	p.statsMtx.RUnlock()
@@ -682,6 +794,30 @@ func (p *Peer) LastPingMicros() int64 {
 	return lastPingMicros
 }
 
+// LastTxTime returns the time the last transaction which was new to the local
+// node was received from the peer.
+//
+// This function is safe for concurrent access.
+func (p *Peer) LastTxTime() time.Time {
+	p.statsMtx.RLock()
+	lastTxTime := p.lastTxTime
+	p.statsMtx.RUnlock()
+
+	return lastTxTime
+}
+
+// LastBlockTime returns the time the last block which was new to the local
+// node was received from the peer.
+//
+// This function is safe for concurrent access.
+func (p *Peer) LastBlockTime() time.Time {
+	p.statsMtx.RLock()
+	lastBlockTime := p.lastBlockTime
+	p.statsMtx.RUnlock()
+
+	return lastBlockTime
+}
+
 // VersionKnown returns the whether or not the version of a peer is known
 // locally.
 //
@@ -837,6 +973,108 @@ func (p *Peer) WantsAddrV2() bool {
 	return wantsAddrV2
 }
 
+// WantsWTxIdRelay returns if the peer signalled during the handshake that
+// transactions should be announced and requested by their witness hash with
+// MSG_WTX inventory vectors as defined by BIP0339.
+//
+// This function is safe for concurrent access.
+func (p *Peer) WantsWTxIdRelay() bool {
+	p.flagsMtx.Lock()
+	wtxidRelay := p.wtxidRelay
+	p.flagsMtx.Unlock()
+
+	return wtxidRelay
+}
+
+// SupportsTxReconciliation returns if both peers signalled support for
+// relaying transactions through set reconciliation (BIP0330) during the
+// handshake.  Since short transaction ids are derived from witness hashes, the
+// peer must also have negotiated wtxid relay.
+//
+// This function is safe for concurrent access.
+func (p *Peer) SupportsTxReconciliation() bool {
+	p.flagsMtx.Lock()
+	supported := p.txReconSupported && p.wtxidRelay
+	p.flagsMtx.Unlock()
+
+	return supported
+}
+
+// TxReconciliationSalts returns the salts we and the remote peer sent in the
+// sendtxrcncl messages during the handshake, which are used to derive the key
+// of the short transaction ids.  They are only valid when
+// SupportsTxReconciliation returns true.
+//
+// This function is safe for concurrent access.
+func (p *Peer) TxReconciliationSalts() (uint64, uint64) {
+	p.flagsMtx.Lock()
+	localSalt, remoteSalt := p.txReconSalt, p.remoteTxReconSalt
+	p.flagsMtx.Unlock()
+
+	return localSalt, remoteSalt
+}
+
+// SupportsCmpctBlocks returns if the peer has signalled support for compact
+// blocks of the version which includes witness data, which is the only
+// supported version.
+//
+// This function is safe for concurrent access.
+func (p *Peer) SupportsCmpctBlocks() bool {
+	p.flagsMtx.Lock()
+	supported := p.cmpctBlocksSupported && p.witnessEnabled
+	p.flagsMtx.Unlock()
+
+	return supported
+}
+
+// WantsCmpctBlocks returns if the peer wants new blocks to be announced with
+// cmpctblock messages instead of inventory vectors or headers, which is
+// referred to as high-bandwidth mode by BIP0152.
+//
+// This function is safe for concurrent access.
+func (p *Peer) WantsCmpctBlocks() bool {
+	p.flagsMtx.Lock()
+	wants := p.cmpctBlocksSupported && p.witnessEnabled &&
+		p.sendCmpctPreferred
+	p.flagsMtx.Unlock()
+
+	return wants
+}
+
+// IsCmpctBlocksHighBandwidth returns if the peer was requested to announce new
+// blocks with cmpctblock messages by the most recent call to
+// PushSendCmpctMsg.
+//
+// This function is safe for concurrent access.
+func (p *Peer) IsCmpctBlocksHighBandwidth() bool {
+	p.flagsMtx.Lock()
+	highBandwidth := p.cmpctBlocksHB
+	p.flagsMtx.Unlock()
+
+	return highBandwidth
+}
+
+// PushSendCmpctMsg sends a sendcmpct message to the connected peer which
+// signals support for compact blocks and requests new blocks to be announced
+// either with cmpctblock messages when highBandwidth is set or with inventory
+// vectors or headers otherwise.  The message is not sent when the negotiated
+// protocol version does not support compact blocks.
+//
+// This function is safe for concurrent access.
+func (p *Peer) PushSendCmpctMsg(highBandwidth bool) {
+	if p.ProtocolVersion() < wire.BIP0152Version {
+		return
+	}
+
+	p.flagsMtx.Lock()
+	p.cmpctBlocksHB = highBandwidth
+	p.flagsMtx.Unlock()
+
+	msg := wire.NewMsgSendCmpct(highBandwidth,
+		wire.CmpctBlockVersionWitness)
+	p.QueueMessage(msg, nil)
+}
+
 // PushAddrMsg sends an addr message to the connected peer using the provided
 // addresses.  This function is useful over manually sending the message via
 // QueueMessage since it automatically limits the addresses to the maximum
@@ -1065,11 +1303,29 @@ func (p *Peer) handlePongMsg(msg *wire.MsgPong) {
 	}
 }
 
+// addMsgBytes adds the passed number of bytes to the per message statistics
+// for the command of the passed message, which is nil when the message could
+// not be decoded as well as for the bytes of the v2 transport handshake.
+func (p *Peer) addMsgBytes(perMsg map[string]uint64, msg wire.Message, n int) {
+	if n == 0 {
+		return
+	}
+	command := otherMsgCommand
+	if msg != nil {
+		command = msg.Command()
+	}
+
+	p.statsMtx.Lock()
+	perMsg[command] += uint64(n)
+	p.statsMtx.Unlock()
+}
+
 // readMessage reads the next bitcoin message from the peer with logging.
 func (p *Peer) readMessage(encoding wire.MessageEncoding) (wire.Message, []byte, error) {
-	n, msg, buf, err := wire.ReadMessageWithEncodingN(p.conn,
-		p.ProtocolVersion(), p.cfg.ChainParams.Net, encoding)
+	n, msg, buf, err := p.transport.ReadMessage(p.ProtocolVersion(),
+		encoding)
 	atomic.AddUint64(&p.bytesReceived, uint64(n))
+	p.addMsgBytes(p.bytesRecvPerMsg, msg, n)
 	if p.cfg.Listeners.OnRead != nil {
 		p.cfg.Listeners.OnRead(p, n, msg, err)
 	}
@@ -1130,9 +1386,9 @@ func (p *Peer) writeMessage(msg wire.Message, enc wire.MessageEncoding) error {
 	}))
 
 	// Write the message to the peer.
-	n, err := wire.WriteMessageWithEncodingN(p.conn, msg,
-		p.ProtocolVersion(), p.cfg.ChainParams.Net, enc)
+	n, err := p.transport.WriteMessage(msg, p.ProtocolVersion(), enc)
 	atomic.AddUint64(&p.bytesSent, uint64(n))
+	p.addMsgBytes(p.bytesSentPerMsg, msg, n)
 	if p.cfg.Listeners.OnWrite != nil {
 		p.cfg.Listeners.OnWrite(p, n, msg, err)
 	}
@@ -1214,12 +1470,18 @@ func (p *Peer) maybeAddDeadline(pendingResponses map[string]time.Time, msgCmd st
 		pendingResponses[wire.CmdInv] = deadline
 
 	case wire.CmdGetData:
-		// Expects a block, merkleblock, tx, or notfound message.
+		// Expects a block, merkleblock, cmpctblock, tx, or notfound
+		// message.
 		pendingResponses[wire.CmdBlock] = deadline
 		pendingResponses[wire.CmdMerkleBlock] = deadline
+		pendingResponses[wire.CmdCmpctBlock] = deadline
 		pendingResponses[wire.CmdTx] = deadline
 		pendingResponses[wire.CmdNotFound] = deadline
 
+	case wire.CmdGetBlockTxn:
+		// Expects a blocktxn message.
+		pendingResponses[wire.CmdBlockTxn] = deadline
+
 	case wire.CmdGetHeaders:
 		// Expects a headers message.  Use a longer deadline since it
 		// can take a while for the remote peer to load all of the
@@ -1279,11 +1541,14 @@ out:
 					fallthrough
 				case wire.CmdMerkleBlock:
 					fallthrough
+				case wire.CmdCmpctBlock:
+					fallthrough
 				case wire.CmdTx:
 					fallthrough
 				case wire.CmdNotFound:
 					delete(pendingResponses, wire.CmdBlock)
 					delete(pendingResponses, wire.CmdMerkleBlock)
+					delete(pendingResponses, wire.CmdCmpctBlock)
 					delete(pendingResponses, wire.CmdTx)
 					delete(pendingResponses, wire.CmdNotFound)
 
@@ -1471,6 +1736,16 @@ out:
 			// completed.
 			break out
 
+		case *wire.MsgWTxIdRelay:
+			// Disconnect if peer sends this after the handshake is
+			// completed.
+			break out
+
+		case *wire.MsgSendTxRcncl:
+			// Disconnect if peer sends this after the handshake is
+			// completed.
+			break out
+
 		case *wire.MsgGetAddr:
 			if p.cfg.Listeners.OnGetAddr != nil {
 				p.cfg.Listeners.OnGetAddr(p, msg)
@@ -1612,6 +1887,51 @@ out:
 				p.cfg.Listeners.OnSendHeaders(p, msg)
 			}
 
+		case *wire.MsgSendCmpct:
+			// Peers may signal support for multiple versions of
+			// compact blocks, so sendcmpct messages for versions
+			// which are not supported are ignored.
+			if msg.CmpctBlockVersion == wire.CmpctBlockVersionWitness {
+				p.flagsMtx.Lock()
+				p.cmpctBlocksSupported = true
+				p.sendCmpctPreferred = msg.AnnounceUsingCmpctBlock
+				p.flagsMtx.Unlock()
+			}
+
+			if p.cfg.Listeners.OnSendCmpct != nil {
+				p.cfg.Listeners.OnSendCmpct(p, msg)
+			}
+
+		case *wire.MsgCmpctBlock:
+			if p.cfg.Listeners.OnCmpctBlock != nil {
+				p.cfg.Listeners.OnCmpctBlock(p, msg)
+			}
+
+		case *wire.MsgGetBlockTxn:
+			if p.cfg.Listeners.OnGetBlockTxn != nil {
+				p.cfg.Listeners.OnGetBlockTxn(p, msg)
+			}
+
+		case *wire.MsgBlockTxn:
+			if p.cfg.Listeners.OnBlockTxn != nil {
+				p.cfg.Listeners.OnBlockTxn(p, msg)
+			}
+
+		case *wire.MsgReqRecon:
+			if p.cfg.Listeners.OnReqRecon != nil {
+				p.cfg.Listeners.OnReqRecon(p, msg)
+			}
+
+		case *wire.MsgSketch:
+			if p.cfg.Listeners.OnSketch != nil {
+				p.cfg.Listeners.OnSketch(p, msg)
+			}
+
+		case *wire.MsgReconcilDiff:
+			if p.cfg.Listeners.OnReconcilDiff != nil {
+				p.cfg.Listeners.OnReconcilDiff(p, msg)
+			}
+
 		default:
 			log.Debugf("Received unhandled message of type %v "+
 				"from %v", rmsg.Command(), p)
@@ -2160,10 +2480,43 @@ func (p *Peer) writeSendAddrV2Msg(pver uint32) error {
 	return p.writeMessage(sendAddrMsg, wire.LatestEncoding)
 }
 
+// writeWTxIdRelayMsg writes our wtxidrelay message to the remote peer if the
+// peer supports protocol version 70016 and above.
+func (p *Peer) writeWTxIdRelayMsg(pver uint32) error {
+	if pver < wire.WTxIdRelayVersion {
+		return nil
+	}
+
+	return p.writeMessage(wire.NewMsgWTxIdRelay(), wire.LatestEncoding)
+}
+
+// writeSendTxRcnclMsg writes our sendtxrcncl message along with a new random
+// salt to the remote peer if transaction reconciliation is enabled, we relay
+// transactions, and the peer supports protocol version 70016 and above.
+func (p *Peer) writeSendTxRcnclMsg(pver uint32) error {
+	if !p.cfg.TxReconciliation || p.cfg.DisableRelayTx ||
+		pver < wire.TxReconciliationVersion {
+
+		return nil
+	}
+
+	salt, err := wire.RandomUint64()
+	if err != nil {
+		return err
+	}
+	p.flagsMtx.Lock()
+	p.txReconSalt = salt
+	p.flagsMtx.Unlock()
+
+	msg := wire.NewMsgSendTxRcncl(wire.TxReconciliationProtocolVersion, salt)
+	return p.writeMessage(msg, wire.LatestEncoding)
+}
+
 // waitToFinishNegotiation waits until desired negotiation messages are
-// received, recording the remote peer's preference for sendaddrv2 as an
-// example. The list of negotiated features can be expanded in the future. If a
-// verack is received, negotiation stops and the connection is live.
+// received, recording the remote peer's preference for wtxidrelay, sendaddrv2
+// and sendtxrcncl as examples. The list of negotiated features can be expanded
+// in the future. If a verack is received, negotiation stops and the connection
+// is live.
 func (p *Peer) waitToFinishNegotiation(pver uint32) error {
 	// There are several possible messages that can be received here. We
 	// could immediately receive verack and be done with the handshake. We
@@ -2189,6 +2542,28 @@ func (p *Peer) waitToFinishNegotiation(pver uint32) error {
 					p.cfg.Listeners.OnSendAddrV2(p, m)
 				}
 			}
+		case *wire.MsgWTxIdRelay:
+			if pver >= wire.WTxIdRelayVersion {
+				p.flagsMtx.Lock()
+				p.wtxidRelay = true
+				p.flagsMtx.Unlock()
+
+				if p.cfg.Listeners.OnWTxIdRelay != nil {
+					p.cfg.Listeners.OnWTxIdRelay(p, m)
+				}
+			}
+		case *wire.MsgSendTxRcncl:
+			// Reconciliation is only supported when both peers
+			// sent a sendtxrcncl message of a supported version.
+			p.flagsMtx.Lock()
+			sent := p.cfg.TxReconciliation && !p.cfg.DisableRelayTx
+			if sent && pver >= wire.TxReconciliationVersion &&
+				m.Version >= wire.TxReconciliationProtocolVersion {
+
+				p.txReconSupported = true
+				p.remoteTxReconSalt = m.Salt
+			}
+			p.flagsMtx.Unlock()
 		case *wire.MsgVerAck:
 			// Receiving a verack means we are done with the
 			// handshake.
@@ -2208,12 +2583,14 @@ func (p *Peer) waitToFinishNegotiation(pver uint32) error {
 //
 //   1. Remote peer sends their version.
 //   2. We send our version.
-//   3. We send sendaddrv2 if their version is >= 70016.
+//   3. We send wtxidrelay, sendaddrv2 and sendtxrcncl if their version is
+//      >= 70016.
 //   4. We send our verack.
-//   5. Wait until sendaddrv2 or verack is received. Unknown messages are
-//      skipped as it could be wtxidrelay or a different message in the future
-//      that btcd does not implement but bitcoind does.
-//   6. If remote peer sent sendaddrv2 above, wait until receipt of verack.
+//   5. Wait until wtxidrelay, sendaddrv2, sendtxrcncl or verack is received.
+//      Unknown messages are skipped as it could be a different message in the
+//      future that btcd does not implement but bitcoind does.
+//   6. If remote peer sent wtxidrelay, sendaddrv2 or sendtxrcncl above, wait
+//      until receipt of verack.
 func (p *Peer) negotiateInboundProtocol() error {
 	if err := p.readRemoteVersionMsg(); err != nil {
 		return err
@@ -2228,10 +2605,18 @@ func (p *Peer) negotiateInboundProtocol() error {
 	protoVersion = p.protocolVersion
 	p.flagsMtx.Unlock()
 
+	if err := p.writeWTxIdRelayMsg(protoVersion); err != nil {
+		return err
+	}
+
 	if err := p.writeSendAddrV2Msg(protoVersion); err != nil {
 		return err
 	}
 
+	if err := p.writeSendTxRcnclMsg(protoVersion); err != nil {
+		return err
+	}
+
 	err := p.writeMessage(wire.NewMsgVerAck(), wire.LatestEncoding)
 	if err != nil {
 		return err
@@ -2247,11 +2632,13 @@ func (p *Peer) negotiateInboundProtocol() error {
 //
 //   1. We send our version.
 //   2. Remote peer sends their version.
-//   3. We send sendaddrv2 if their version is >= 70016.
+//   3. We send wtxidrelay, sendaddrv2 and sendtxrcncl if their version is
+//      >= 70016.
 //   4. We send our verack.
-//   5. We wait to receive sendaddrv2 or verack, skipping unknown messages as
-//      in the inbound case.
-//   6. If sendaddrv2 was received, wait for receipt of verack.
+//   5. We wait to receive wtxidrelay, sendaddrv2, sendtxrcncl or verack,
+//      skipping unknown messages as in the inbound case.
+//   6. If wtxidrelay, sendaddrv2 or sendtxrcncl was received, wait for receipt
+//      of verack.
 func (p *Peer) negotiateOutboundProtocol() error {
 	if err := p.writeLocalVersionMsg(); err != nil {
 		return err
@@ -2266,10 +2653,18 @@ func (p *Peer) negotiateOutboundProtocol() error {
 	protoVersion = p.protocolVersion
 	p.flagsMtx.Unlock()
 
+	if err := p.writeWTxIdRelayMsg(protoVersion); err != nil {
+		return err
+	}
+
 	if err := p.writeSendAddrV2Msg(protoVersion); err != nil {
 		return err
 	}
 
+	if err := p.writeSendTxRcnclMsg(protoVersion); err != nil {
+		return err
+	}
+
 	err := p.writeMessage(wire.NewMsgVerAck(), wire.LatestEncoding)
 	if err != nil {
 		return err
@@ -2285,6 +2680,11 @@ func (p *Peer) start() error {
 
 	negotiateErr := make(chan error, 1)
 	go func() {
+		if err := p.negotiateTransport(); err != nil {
+			negotiateErr <- err
+			return
+		}
+
 		if p.inbound {
 			negotiateErr <- p.negotiateInboundProtocol()
 		} else {
@@ -2313,6 +2713,13 @@ func (p *Peer) start() error {
 	go p.outHandler()
 	go p.pingHandler()
 
+	// Signal support for compact blocks in low-bandwidth mode.  The
+	// caller may request high-bandwidth mode later on via
+	// PushSendCmpctMsg.
+	if !p.cfg.DisableCmpctBlocks {
+		p.PushSendCmpctMsg(false)
+	}
+
 	return nil
 }
 
@@ -2325,6 +2732,7 @@ func (p *Peer) AssociateConnection(conn net.Conn) {
 	}
 
 	p.conn = conn
+	p.transport = &v1Transport{conn: conn, btcnet: p.cfg.ChainParams.Net}
 	p.timeConnected = time.Now()
 
 	if p.inbound {
@@ -2388,6 +2796,8 @@ func newPeerBase(origCfg *Config, inbound bool) *Peer {
 		inbound:         inbound,
 		wireEncoding:    wire.BaseEncoding,
 		knownInventory:  lru.NewCache(maxKnownInventory),
+		bytesSentPerMsg: make(map[string]uint64),
+		bytesRecvPerMsg: make(map[string]uint64),
 		stallControl:    make(chan stallControlMsg, 1), // nonblocking sync
 		outputQueue:     make(chan outMsg, outputBufferSize),
 		sendQueue:       make(chan outMsg, 1),   // nonblocking sync
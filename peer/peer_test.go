@@ -10,12 +10,14 @@ import (
 	"io"
 	"net"
 	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/v2transport"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/go-socks/socks"
 )
@@ -355,7 +357,7 @@ func TestPeerConnection(t *testing.T) {
 // TestPeerListeners tests that the peer listeners are called as expected.
 func TestPeerListeners(t *testing.T) {
 	verack := make(chan struct{}, 1)
-	ok := make(chan wire.Message, 22)
+	ok := make(chan wire.Message, 26)
 	peerCfg := &peer.Config{
 		Listeners: peer.MessageListeners{
 			OnGetAddr: func(p *peer.Peer, msg *wire.MsgGetAddr) {
@@ -449,14 +451,27 @@ func TestPeerListeners(t *testing.T) {
 			OnAddrV2: func(p *peer.Peer, msg *wire.MsgAddrV2) {
 				ok <- msg
 			},
+			OnSendCmpct: func(p *peer.Peer, msg *wire.MsgSendCmpct) {
+				ok <- msg
+			},
+			OnCmpctBlock: func(p *peer.Peer, msg *wire.MsgCmpctBlock) {
+				ok <- msg
+			},
+			OnGetBlockTxn: func(p *peer.Peer, msg *wire.MsgGetBlockTxn) {
+				ok <- msg
+			},
+			OnBlockTxn: func(p *peer.Peer, msg *wire.MsgBlockTxn) {
+				ok <- msg
+			},
 		},
-		UserAgentName:     "peer",
-		UserAgentVersion:  "1.0",
-		UserAgentComments: []string{"comment"},
-		ChainParams:       &chaincfg.MainNetParams,
-		Services:          wire.SFNodeBloom,
-		TrickleInterval:   time.Second * 10,
-		AllowSelfConns:    true,
+		UserAgentName:      "peer",
+		UserAgentVersion:   "1.0",
+		UserAgentComments:  []string{"comment"},
+		ChainParams:        &chaincfg.MainNetParams,
+		Services:           wire.SFNodeBloom,
+		TrickleInterval:    time.Second * 10,
+		AllowSelfConns:     true,
+		DisableCmpctBlocks: true,
 	}
 	inPeer := peer.NewInboundPeer(peerCfg)
 
@@ -599,6 +614,23 @@ func TestPeerListeners(t *testing.T) {
 			"OnSendHeaders",
 			wire.NewMsgSendHeaders(),
 		},
+		{
+			"OnSendCmpct",
+			wire.NewMsgSendCmpct(true, wire.CmpctBlockVersionWitness),
+		},
+		{
+			"OnCmpctBlock",
+			wire.NewMsgCmpctBlock(wire.NewBlockHeader(1,
+				&chainhash.Hash{}, &chainhash.Hash{}, 1, 1), 1),
+		},
+		{
+			"OnGetBlockTxn",
+			wire.NewMsgGetBlockTxn(&chainhash.Hash{}),
+		},
+		{
+			"OnBlockTxn",
+			wire.NewMsgBlockTxn(&chainhash.Hash{}),
+		},
 		{
 			"OnSendAddrV2",
 			wire.NewMsgSendAddrV2(),
@@ -1203,6 +1235,371 @@ func TestSendAddrV2Handshake(t *testing.T) {
 				test.expectsV2, outPeer.WantsAddrV2())
 		}
 
+		// The wtxidrelay message is negotiated with the same protocol
+		// version as the sendaddrv2 message.
+		if inPeer.WantsWTxIdRelay() != test.expectsV2 {
+			t.Fatalf("TestSendAddrV2Handshake #%d expected "+
+				"wantsWTxIdRelay to be %v instead was %v", i,
+				test.expectsV2, inPeer.WantsWTxIdRelay())
+		} else if outPeer.WantsWTxIdRelay() != test.expectsV2 {
+			t.Fatalf("TestSendAddrV2Handshake #%d expected "+
+				"wantsWTxIdRelay to be %v instead was %v", i,
+				test.expectsV2, outPeer.WantsWTxIdRelay())
+		}
+
+		inPeer.Disconnect()
+		outPeer.Disconnect()
+		inPeer.WaitForDisconnect()
+		outPeer.WaitForDisconnect()
+	}
+}
+
+// TestCmpctBlocksNegotiation tests that compact block support is signalled
+// once the protocol has been negotiated and that the high-bandwidth mode can
+// be requested by either side.
+func TestCmpctBlocksNegotiation(t *testing.T) {
+	verack := make(chan struct{}, 2)
+	sendCmpct := make(chan struct{}, 4)
+	peerCfg := &peer.Config{
+		Listeners: peer.MessageListeners{
+			OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+				verack <- struct{}{}
+			},
+			OnSendCmpct: func(p *peer.Peer, msg *wire.MsgSendCmpct) {
+				sendCmpct <- struct{}{}
+			},
+		},
+		Services:       wire.SFNodeWitness,
+		AllowSelfConns: true,
+		ChainParams:    &chaincfg.MainNetParams,
+	}
+
+	inPeer := peer.NewInboundPeer(peerCfg)
+	outPeer, err := peer.NewOutboundPeer(peerCfg, "10.0.0.2:8333")
+	if err != nil {
+		t.Fatalf("NewOutboundPeer: unexpected err: %v", err)
+	}
+	if err := setupPeerConnection(inPeer, outPeer); err != nil {
+		t.Fatalf("setupPeerConnection: failed: %v", err)
+	}
+	defer func() {
+		inPeer.Disconnect()
+		outPeer.Disconnect()
+	}()
+
+	// waitSendCmpct waits for the passed number of sendcmpct messages to
+	// be received.
+	waitSendCmpct := func(n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			select {
+			case <-sendCmpct:
+			case <-time.After(time.Second * 2):
+				t.Fatalf("sendcmpct timeout")
+			}
+		}
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-verack:
+		case <-time.After(time.Second * 2):
+			t.Fatalf("verack timeout")
+		}
+	}
+
+	// Both peers signal support for compact blocks in low-bandwidth mode
+	// after the handshake.
+	waitSendCmpct(2)
+	for _, p := range []*peer.Peer{inPeer, outPeer} {
+		if !p.SupportsCmpctBlocks() {
+			t.Fatalf("%v does not support compact blocks", p)
+		}
+		if p.WantsCmpctBlocks() || p.IsCmpctBlocksHighBandwidth() {
+			t.Fatalf("%v unexpectedly in high-bandwidth mode", p)
+		}
+	}
+
+	// Request high-bandwidth mode from the inbound peer and ensure it is
+	// reflected on both sides.
+	outPeer.PushSendCmpctMsg(true)
+	waitSendCmpct(1)
+	if !outPeer.IsCmpctBlocksHighBandwidth() {
+		t.Fatalf("high-bandwidth mode was not recorded as requested")
+	}
+	if !inPeer.WantsCmpctBlocks() {
+		t.Fatalf("high-bandwidth mode was not recorded as wanted")
+	}
+
+	// Unsupported versions are ignored.
+	inPeer.QueueMessage(wire.NewMsgSendCmpct(false, 1), nil)
+	outPeer.PushSendCmpctMsg(false)
+	waitSendCmpct(2)
+	if inPeer.WantsCmpctBlocks() {
+		t.Fatalf("high-bandwidth mode was not recorded as unwanted")
+	}
+	if !outPeer.SupportsCmpctBlocks() {
+		t.Fatalf("unsupported version changed compact block support")
+	}
+}
+
+// TestV2Transport tests that peers negotiate the v2 encrypted transport when
+// both sides enable it and that inbound peers fall back to the v1 transport
+// for outbound peers which don't.
+func TestV2Transport(t *testing.T) {
+	tests := []struct {
+		name       string
+		inboundV2  bool
+		outboundV2 bool
+		wantV2     bool
+	}{
+		{"both v2", true, true, true},
+		{"v1 outbound peer", true, false, false},
+		{"v1 inbound peer disabled", false, false, false},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for _, test := range tests {
+		// The handshake bytes are reported to the read and write
+		// listeners without a message and are tracked per direction,
+		// indexed by whether the peer is inbound.
+		var handshakeSent, handshakeRecv [2]uint64
+		index := func(p *peer.Peer) int {
+			if p.Inbound() {
+				return 1
+			}
+			return 0
+		}
+		verack := make(chan struct{}, 2)
+		listeners := peer.MessageListeners{
+			OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+				verack <- struct{}{}
+			},
+			OnRead: func(p *peer.Peer, n int, msg wire.Message, err error) {
+				if msg == nil {
+					atomic.AddUint64(&handshakeRecv[index(p)],
+						uint64(n))
+				}
+			},
+			OnWrite: func(p *peer.Peer, n int, msg wire.Message, err error) {
+				if msg == nil {
+					atomic.AddUint64(&handshakeSent[index(p)],
+						uint64(n))
+				}
+			},
+		}
+		inPeer := peer.NewInboundPeer(&peer.Config{
+			Listeners:      listeners,
+			AllowSelfConns: true,
+			ChainParams:    &chaincfg.MainNetParams,
+			V2Transport:    test.inboundV2,
+		})
+		outPeer, err := peer.NewOutboundPeer(&peer.Config{
+			Listeners:      listeners,
+			AllowSelfConns: true,
+			ChainParams:    &chaincfg.MainNetParams,
+			V2Transport:    test.outboundV2,
+		}, "10.0.0.2:8333")
+		if err != nil {
+			t.Fatalf("%s: NewOutboundPeer: unexpected err: %v",
+				test.name, err)
+		}
+		if err := setupPeerConnection(inPeer, outPeer); err != nil {
+			t.Fatalf("%s: setupPeerConnection: unexpected err: %v",
+				test.name, err)
+		}
+		for i := 0; i < 2; i++ {
+			select {
+			case <-verack:
+			case <-time.After(time.Second * 2):
+				t.Fatalf("%s: verack timeout", test.name)
+			}
+		}
+
+		if inPeer.V2Transport() != test.wantV2 ||
+			outPeer.V2Transport() != test.wantV2 {
+
+			t.Fatalf("%s: got v2 transport %v (inbound) and %v "+
+				"(outbound), want %v", test.name,
+				inPeer.V2Transport(), outPeer.V2Transport(),
+				test.wantV2)
+		}
+		if test.wantV2 && inPeer.SessionID() != outPeer.SessionID() {
+			t.Fatalf("%s: mismatched session ids", test.name)
+		}
+
+		// The bytes of the v2 transport handshake are accounted for
+		// along with the bytes of messages.
+		const minHandshakeLen = v2transport.EllswiftPubKeyLen +
+			v2transport.GarbageTerminatorLen +
+			v2transport.PacketOverhead
+		for _, stats := range []struct {
+			sent, recv uint64
+		}{
+			{atomic.LoadUint64(&handshakeSent[0]),
+				atomic.LoadUint64(&handshakeRecv[1])},
+			{atomic.LoadUint64(&handshakeSent[1]),
+				atomic.LoadUint64(&handshakeRecv[0])},
+		} {
+			if stats.sent != stats.recv {
+				t.Fatalf("%s: sent %d handshake bytes, received "+
+					"%d", test.name, stats.sent, stats.recv)
+			}
+			if test.wantV2 && stats.sent < minHandshakeLen ||
+				!test.wantV2 && stats.sent != 0 {
+
+				t.Fatalf("%s: unexpected %d handshake bytes",
+					test.name, stats.sent)
+			}
+		}
+
+		// Messages must continue to flow after the handshake.
+		pong := make(chan struct{}, 1)
+		done := make(chan struct{})
+		outPeer.QueueMessage(wire.NewMsgPing(1), done)
+		<-done
+		inPeer.QueueMessage(wire.NewMsgPing(2), pong)
+		select {
+		case <-pong:
+		case <-time.After(time.Second * 2):
+			t.Fatalf("%s: message timeout", test.name)
+		}
+
+		inPeer.Disconnect()
+		outPeer.Disconnect()
+		inPeer.WaitForDisconnect()
+		outPeer.WaitForDisconnect()
+	}
+}
+
+// TestV2TransportV1Peer tests that an outbound peer using the v2 transport
+// which connects to a peer only supporting the v1 transport is flagged to be
+// reconnected to using the v1 transport.
+func TestV2TransportV1Peer(t *testing.T) {
+	inPeer := peer.NewInboundPeer(&peer.Config{
+		AllowSelfConns: true,
+		ChainParams:    &chaincfg.MainNetParams,
+	})
+	outPeer, err := peer.NewOutboundPeer(&peer.Config{
+		AllowSelfConns: true,
+		ChainParams:    &chaincfg.MainNetParams,
+		V2Transport:    true,
+	}, "10.0.0.2:8333")
+	if err != nil {
+		t.Fatalf("NewOutboundPeer: unexpected err: %v", err)
+	}
+	if err := setupPeerConnection(inPeer, outPeer); err != nil {
+		t.Fatalf("setupPeerConnection: unexpected err: %v", err)
+	}
+
+	disconnected := make(chan struct{})
+	go func() {
+		outPeer.WaitForDisconnect()
+		close(disconnected)
+	}()
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second * 2):
+		t.Fatal("disconnect timeout")
+	}
+	inPeer.Disconnect()
+	inPeer.WaitForDisconnect()
+
+	if !outPeer.ReconnectV1() {
+		t.Fatal("ReconnectV1: expected reconnect with the v1 transport")
+	}
+	if inPeer.ReconnectV1() {
+		t.Fatal("ReconnectV1: unexpected reconnect of inbound peer")
+	}
+}
+
+// TestTxReconciliationNegotiation tests that transaction reconciliation is
+// only supported when both peers signal it during the handshake and that the
+// exchanged messages are accounted for in the per message statistics.
+func TestTxReconciliationNegotiation(t *testing.T) {
+	tests := []struct {
+		name          string
+		inboundRecon  bool
+		outboundRecon bool
+		disableRelay  bool
+		want          bool
+	}{
+		{"both enabled", true, true, false, true},
+		{"inbound disabled", false, true, false, false},
+		{"outbound disabled", true, false, false, false},
+		{"relay disabled", true, true, true, false},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for _, test := range tests {
+		verack := make(chan struct{}, 2)
+		listeners := peer.MessageListeners{
+			OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+				verack <- struct{}{}
+			},
+		}
+		inPeer := peer.NewInboundPeer(&peer.Config{
+			Listeners:        listeners,
+			AllowSelfConns:   true,
+			ChainParams:      &chaincfg.MainNetParams,
+			TxReconciliation: test.inboundRecon,
+		})
+		outPeer, err := peer.NewOutboundPeer(&peer.Config{
+			Listeners:        listeners,
+			AllowSelfConns:   true,
+			ChainParams:      &chaincfg.MainNetParams,
+			TxReconciliation: test.outboundRecon,
+			DisableRelayTx:   test.disableRelay,
+		}, "10.0.0.2:8333")
+		if err != nil {
+			t.Fatalf("%s: NewOutboundPeer: unexpected err: %v",
+				test.name, err)
+		}
+		if err := setupPeerConnection(inPeer, outPeer); err != nil {
+			t.Fatalf("%s: setupPeerConnection: unexpected err: %v",
+				test.name, err)
+		}
+		for i := 0; i < 2; i++ {
+			select {
+			case <-verack:
+			case <-time.After(time.Second * 2):
+				t.Fatalf("%s: verack timeout", test.name)
+			}
+		}
+
+		if inPeer.SupportsTxReconciliation() != test.want ||
+			outPeer.SupportsTxReconciliation() != test.want {
+
+			t.Fatalf("%s: got reconciliation support %v (inbound) "+
+				"and %v (outbound), want %v", test.name,
+				inPeer.SupportsTxReconciliation(),
+				outPeer.SupportsTxReconciliation(), test.want)
+		}
+		inLocal, inRemote := inPeer.TxReconciliationSalts()
+		outLocal, outRemote := outPeer.TxReconciliationSalts()
+		if test.want && (inLocal != outRemote || outLocal != inRemote) {
+			t.Fatalf("%s: mismatched salts", test.name)
+		}
+
+		// The outbound peer only sends its sendtxrcncl message when it
+		// is enabled, which the inbound peer must account for.
+		wantBytes := uint64(0)
+		if test.outboundRecon && !test.disableRelay {
+			wantBytes = wire.MessageHeaderSize + 12
+		}
+		stats := inPeer.StatsSnapshot()
+		if got := stats.BytesRecvPerMsg[wire.CmdSendTxRcncl]; got != wantBytes {
+			t.Fatalf("%s: got %d sendtxrcncl bytes received, want %d",
+				test.name, got, wantBytes)
+		}
+		var total uint64
+		for _, n := range stats.BytesRecvPerMsg {
+			total += n
+		}
+		if total != stats.BytesRecv {
+			t.Fatalf("%s: got %d bytes received per message, want %d",
+				test.name, total, stats.BytesRecv)
+		}
+
 		inPeer.Disconnect()
 		outPeer.Disconnect()
 		inPeer.WaitForDisconnect()
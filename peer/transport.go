@@ -0,0 +1,141 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+
+	"github.com/btcsuite/btcd/v2transport"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// MessageTransport describes the framing used to read and write bitcoin
+// messages on the connection of a peer.  It sits beneath the read and write
+// paths of a peer so the on-the-wire representation of messages, such as the
+// v1 transport protocol or the encrypted v2 transport protocol (BIP0324), is
+// independent of the message handling.
+type MessageTransport interface {
+	// ReadMessage reads the next bitcoin message and returns the number
+	// of bytes read, the message and its raw payload.
+	ReadMessage(pver uint32, enc wire.MessageEncoding) (int, wire.Message,
+		[]byte, error)
+
+	// WriteMessage writes the passed bitcoin message and returns the
+	// number of bytes written.
+	WriteMessage(msg wire.Message, pver uint32,
+		enc wire.MessageEncoding) (int, error)
+}
+
+// v1Transport implements the MessageTransport interface for the unencrypted v1
+// transport protocol where every message is prefixed by a header containing
+// the network magic, command, length and checksum.
+type v1Transport struct {
+	conn   io.ReadWriter
+	btcnet wire.BitcoinNet
+}
+
+// Ensure v1Transport and the v2 transport implement MessageTransport.
+var _ MessageTransport = (*v1Transport)(nil)
+var _ MessageTransport = (*v2transport.Transport)(nil)
+
+// ReadMessage reads the next bitcoin message from the connection.
+//
+// This is part of the MessageTransport interface.
+func (t *v1Transport) ReadMessage(pver uint32,
+	enc wire.MessageEncoding) (int, wire.Message, []byte, error) {
+
+	return wire.ReadMessageWithEncodingN(t.conn, pver, t.btcnet, enc)
+}
+
+// WriteMessage writes the passed bitcoin message to the connection.
+//
+// This is part of the MessageTransport interface.
+func (t *v1Transport) WriteMessage(msg wire.Message, pver uint32,
+	enc wire.MessageEncoding) (int, error) {
+
+	return wire.WriteMessageWithEncodingN(t.conn, msg, pver, t.btcnet, enc)
+}
+
+// negotiateTransport performs the v2 transport handshake when the v2
+// transport protocol is enabled.  Inbound peers which use the v1 transport
+// protocol are still accepted since the v2 transport falls back to v1 framing
+// for them, while outbound peers which turn out to only support the v1
+// transport protocol are flagged to be reconnected to using it.
+func (p *Peer) negotiateTransport() error {
+	if !p.cfg.V2Transport {
+		return nil
+	}
+
+	// The bytes of the handshake are accounted for the same as those of
+	// messages.
+	transport := v2transport.New(p.conn, p.cfg.ChainParams.Net, !p.inbound)
+	bytesRead, bytesWritten, err := transport.Handshake()
+	atomic.AddUint64(&p.bytesReceived, uint64(bytesRead))
+	p.addMsgBytes(p.bytesRecvPerMsg, nil, bytesRead)
+	if p.cfg.Listeners.OnRead != nil {
+		p.cfg.Listeners.OnRead(p, bytesRead, nil, err)
+	}
+	atomic.AddUint64(&p.bytesSent, uint64(bytesWritten))
+	p.addMsgBytes(p.bytesSentPerMsg, nil, bytesWritten)
+	if p.cfg.Listeners.OnWrite != nil {
+		p.cfg.Listeners.OnWrite(p, bytesWritten, nil, err)
+	}
+	if err != nil {
+		if errors.Is(err, v2transport.ErrV1Peer) {
+			p.flagsMtx.Lock()
+			p.reconnectV1 = true
+			p.flagsMtx.Unlock()
+		}
+		return err
+	}
+	p.transport = transport
+
+	if !transport.V1Fallback() {
+		p.flagsMtx.Lock()
+		p.v2Transport = true
+		p.sessionID = transport.SessionID()
+		p.flagsMtx.Unlock()
+	}
+	return nil
+}
+
+// V2Transport returns whether the connection to the peer is encrypted with
+// the v2 transport protocol (BIP0324).
+//
+// This function is safe for concurrent access.
+func (p *Peer) V2Transport() bool {
+	p.flagsMtx.Lock()
+	v2Transport := p.v2Transport
+	p.flagsMtx.Unlock()
+
+	return v2Transport
+}
+
+// ReconnectV1 returns whether the v2 transport handshake with the outbound peer
+// failed since it only supports the v1 transport protocol, in which case it
+// should be reconnected to using the v1 transport protocol.
+//
+// This function is safe for concurrent access.
+func (p *Peer) ReconnectV1() bool {
+	p.flagsMtx.Lock()
+	reconnectV1 := p.reconnectV1
+	p.flagsMtx.Unlock()
+
+	return reconnectV1
+}
+
+// SessionID returns the id which uniquely identifies the encrypted session
+// with the peer.  It is only valid when V2Transport returns true.
+//
+// This function is safe for concurrent access.
+func (p *Peer) SessionID() [32]byte {
+	p.flagsMtx.Lock()
+	sessionID := p.sessionID
+	p.flagsMtx.Unlock()
+
+	return sessionID
+}
@@ -5,9 +5,12 @@
 package main
 
 import (
+	"net"
 	"sync/atomic"
+	"time"
 
 	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/mempool"
 	"github.com/btcsuite/btcd/netsync"
@@ -40,7 +43,7 @@ func (p *rpcPeer) ToPeer() *peer.Peer {
 // This function is safe for concurrent access and is part of the rpcserverPeer
 // interface implementation.
 func (p *rpcPeer) IsTxRelayDisabled() bool {
-	return (*serverPeer)(p).disableRelayTx
+	return (*serverPeer)(p).relayTxDisabled()
 }
 
 // BanScore returns the current integer value that represents how close the peer
@@ -61,6 +64,24 @@ func (p *rpcPeer) FeeFilter() int64 {
 	return atomic.LoadInt64(&(*serverPeer)(p).feeFilter)
 }
 
+// ConnectionType returns the type of the connection to the peer, such as
+// inbound or block-relay-only.
+//
+// This function is safe for concurrent access and is part of the rpcserverPeer
+// interface implementation.
+func (p *rpcPeer) ConnectionType() string {
+	return (*serverPeer)(p).connectionType()
+}
+
+// Permissions returns the names of the permissions granted to the peer by the
+// whitelisted networks and the whitebind listener it connected through.
+//
+// This function is safe for concurrent access and is part of the rpcserverPeer
+// interface implementation.
+func (p *rpcPeer) Permissions() []string {
+	return (*serverPeer)(p).permissions.names()
+}
+
 // rpcConnManager provides a connection manager for use with the RPC server and
 // implements the rpcserverConnManager interface.
 type rpcConnManager struct {
@@ -164,6 +185,22 @@ func (cm *rpcConnManager) NetTotals() (uint64, uint64) {
 	return cm.server.NetTotals()
 }
 
+// UploadTarget returns the state of the upload target in the current cycle.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) UploadTarget() *btcjson.UploadTargetResult {
+	status := cm.server.uploadTarget.Status()
+	return &btcjson.UploadTargetResult{
+		TimeFrame:             int64(uploadTargetTimeframe / time.Second),
+		Target:                status.target,
+		TargetReached:         status.targetReached,
+		ServeHistoricalBlocks: status.serveHistoricalBlocks,
+		BytesLeftInCycle:      status.bytesLeftInCycle,
+		TimeLeftInCycle:       int64(status.timeLeftInCycle / time.Second),
+	}
+}
+
 // ConnectedPeers returns an array consisting of all connected peers.
 //
 // This function is safe for concurrent access and is part of the
@@ -232,6 +269,69 @@ func (cm *rpcConnManager) NodeAddresses() []*wire.NetAddressV2 {
 	return cm.server.addrManager.AddressCache()
 }
 
+// BanSubnet bans the provided subnet until the provided time, or for the
+// configured ban duration when it is zero, and disconnects the connected peers
+// in the subnet.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) BanSubnet(subnet *net.IPNet, until time.Time) error {
+	if until.IsZero() {
+		until = time.Now().Add(cfg.BanDuration)
+	}
+	err := cm.server.banList.Ban(subnet, until, banReasonManual)
+	if err != nil {
+		return err
+	}
+
+	replyChan := make(chan []*serverPeer)
+	cm.server.query <- getPeersMsg{reply: replyChan}
+	for _, sp := range <-replyChan {
+		host, _, err := net.SplitHostPort(sp.Addr())
+		if err != nil {
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil && subnet.Contains(ip) {
+			srvrLog.Infof("Disconnecting banned peer %s", sp)
+			sp.Disconnect()
+		}
+	}
+	return nil
+}
+
+// UnbanSubnet removes the ban of the provided subnet.  It returns false when
+// the subnet is not banned.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) UnbanSubnet(subnet *net.IPNet) (bool, error) {
+	return cm.server.banList.Unban(subnet)
+}
+
+// IsSubnetBanned returns whether the provided subnet itself is banned.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) IsSubnetBanned(subnet *net.IPNet) bool {
+	return cm.server.banList.IsSubnetBanned(subnet)
+}
+
+// BannedSubnets returns the banned IPs and subnets.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) BannedSubnets() ([]*banEntry, error) {
+	return cm.server.banList.Entries()
+}
+
+// ClearBanned removes all bans.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) ClearBanned() error {
+	return cm.server.banList.Clear()
+}
+
 // rpcSyncMgr provides a block manager for use with the RPC server and
 // implements the rpcserverSyncManager interface.
 type rpcSyncMgr struct {
@@ -888,6 +888,81 @@ func (c *Client) EstimateSmartFee(confTarget int64, mode *btcjson.EstimateSmartF
 	return c.EstimateSmartFeeAsync(confTarget, mode).Receive()
 }
 
+// FutureEstimateRawFeeResult is a future promise to deliver the result of a
+// EstimateRawFeeAsync RPC invocation (or an applicable error).
+type FutureEstimateRawFeeResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the raw
+// fee estimates of each horizon.
+func (r FutureEstimateRawFeeResult) Receive() (*btcjson.EstimateRawFeeResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var verified btcjson.EstimateRawFeeResult
+	err = json.Unmarshal(res, &verified)
+	if err != nil {
+		return nil, err
+	}
+	return &verified, nil
+}
+
+// EstimateRawFeeAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See EstimateRawFee for the blocking version and more details.
+func (c *Client) EstimateRawFeeAsync(confTarget int64, threshold *float64) FutureEstimateRawFeeResult {
+	cmd := btcjson.NewEstimateRawFeeCmd(confTarget, threshold)
+	return c.SendCmd(cmd)
+}
+
+// EstimateRawFee requests the raw fee estimates of each horizon that tracks
+// the given confirmation target, along with the statistics they were derived
+// from.
+func (c *Client) EstimateRawFee(confTarget int64, threshold *float64) (*btcjson.EstimateRawFeeResult, error) {
+	return c.EstimateRawFeeAsync(confTarget, threshold).Receive()
+}
+
+// FutureEstimateMempoolFeeResult is a future promise to deliver the result of a
+// EstimateMempoolFeeAsync RPC invocation (or an applicable error).
+type FutureEstimateMempoolFeeResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the blocks
+// projected from the memory pool.
+func (r FutureEstimateMempoolFeeResult) Receive() ([]btcjson.ProjectedBlockResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []btcjson.ProjectedBlockResult
+	err = json.Unmarshal(res, &blocks)
+	if err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// EstimateMempoolFeeAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See EstimateMempoolFee for the blocking version and more details.
+func (c *Client) EstimateMempoolFeeAsync(numBlocks *int64) FutureEstimateMempoolFeeResult {
+	cmd := btcjson.NewEstimateMempoolFeeCmd(numBlocks)
+	return c.SendCmd(cmd)
+}
+
+// EstimateMempoolFee requests the server to project up to the given number of
+// blocks from its memory pool and returns the fee rates each block contains.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) EstimateMempoolFee(numBlocks *int64) ([]btcjson.ProjectedBlockResult, error) {
+	return c.EstimateMempoolFeeAsync(numBlocks).Receive()
+}
+
 // FutureVerifyChainResult is a future promise to deliver the result of a
 // VerifyChainAsync, VerifyChainLevelAsyncRPC, or VerifyChainBlocksAsync
 // invocation (or an applicable error).
@@ -282,6 +282,12 @@ func (c *Client) trackRegisteredNtfns(cmd interface{}) {
 
 		}
 
+	case *btcjson.NotifyMempoolSequenceCmd:
+		c.ntfnState.notifyMempoolSequence = true
+
+	case *btcjson.NotifyBlockTemplateCmd:
+		c.ntfnState.notifyBlockTemplate = true
+
 	case *btcjson.NotifySpentCmd:
 		for _, op := range bcmd.OutPoints {
 			c.ntfnState.notifySpent[op] = struct{}{}
@@ -589,6 +595,22 @@ func (c *Client) reregisterNtfns() error {
 		}
 	}
 
+	// Reregister notifymempoolsequence if needed.
+	if stateCopy.notifyMempoolSequence {
+		log.Debugf("Reregistering [notifymempoolsequence]")
+		if err := c.NotifyMempoolSequence(); err != nil {
+			return err
+		}
+	}
+
+	// Reregister notifyblocktemplate if needed.
+	if stateCopy.notifyBlockTemplate {
+		log.Debugf("Reregistering [notifyblocktemplate]")
+		if err := c.NotifyBlockTemplate(); err != nil {
+			return err
+		}
+	}
+
 	// Reregister the combination of all previously registered notifyspent
 	// outpoints in one command if needed.
 	nslen := len(stateCopy.notifySpent)
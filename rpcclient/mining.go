@@ -108,6 +108,74 @@ func (c *Client) GenerateToAddress(numBlocks int64, address btcutil.Address, max
 	return c.GenerateToAddressAsync(numBlocks, address, maxTries).Receive()
 }
 
+// FutureGenerateToDescriptorResult is a future promise to deliver the result
+// of a GenerateToDescriptorAsync RPC invocation (or an applicable error).
+type FutureGenerateToDescriptorResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the hashes
+// of the generated blocks.
+func (f FutureGenerateToDescriptorResult) Receive() ([]*chainhash.Hash, error) {
+	return FutureGenerateToAddressResult(f).Receive()
+}
+
+// GenerateToDescriptorAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GenerateToDescriptor for the blocking version and more details.
+func (c *Client) GenerateToDescriptorAsync(numBlocks int64, descriptor string, maxTries *int64) FutureGenerateToDescriptorResult {
+	cmd := btcjson.NewGenerateToDescriptorCmd(numBlocks, descriptor, maxTries)
+	return c.SendCmd(cmd)
+}
+
+// GenerateToDescriptor generates numBlocks blocks to the given output
+// descriptor and returns their hashes.
+func (c *Client) GenerateToDescriptor(numBlocks int64, descriptor string, maxTries *int64) ([]*chainhash.Hash, error) {
+	return c.GenerateToDescriptorAsync(numBlocks, descriptor, maxTries).Receive()
+}
+
+// FutureGenerateBlockResult is a future promise to deliver the result of a
+// GenerateBlockAsync RPC invocation (or an applicable error).
+type FutureGenerateBlockResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the hash
+// of the generated block along with the block itself when it was not
+// submitted.
+func (f FutureGenerateBlockResult) Receive() (*btcjson.GenerateBlockResult, error) {
+	res, err := ReceiveFuture(f)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a generateblock result object.
+	var result btcjson.GenerateBlockResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GenerateBlockAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See GenerateBlock for the blocking version and more details.
+func (c *Client) GenerateBlockAsync(output string, transactions []string, submit *bool) FutureGenerateBlockResult {
+	cmd := btcjson.NewGenerateBlockCmd(output, transactions, submit)
+	return c.SendCmd(cmd)
+}
+
+// GenerateBlock generates a block paying to the given address or output
+// descriptor which contains exactly the given transactions.  Each transaction
+// is either the hash of a transaction in the mempool or a hex-encoded raw
+// transaction.  The block is returned hex-encoded instead of being submitted
+// when submit is false.
+func (c *Client) GenerateBlock(output string, transactions []string, submit *bool) (*btcjson.GenerateBlockResult, error) {
+	return c.GenerateBlockAsync(output, transactions, submit).Receive()
+}
+
 // FutureGetGenerateResult is a future promise to deliver the result of a
 // GetGenerateAsync RPC invocation (or an applicable error).
 type FutureGetGenerateResult chan *Response
@@ -248,6 +316,48 @@ func (c *Client) GetMiningInfo() (*btcjson.GetMiningInfoResult, error) {
 	return c.GetMiningInfoAsync().Receive()
 }
 
+// FutureGetStratumInfoResult is a future promise to deliver the result of a
+// GetStratumInfoAsync RPC invocation (or an applicable error).
+type FutureGetStratumInfoResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the
+// stratum server information.
+func (r FutureGetStratumInfoResult) Receive() (*btcjson.GetStratumInfoResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a getstratuminfo result object.
+	var infoResult btcjson.GetStratumInfoResult
+	err = json.Unmarshal(res, &infoResult)
+	if err != nil {
+		return nil, err
+	}
+
+	return &infoResult, nil
+}
+
+// GetStratumInfoAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See GetStratumInfo for the blocking version and more details.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) GetStratumInfoAsync() FutureGetStratumInfoResult {
+	cmd := btcjson.NewGetStratumInfoCmd()
+	return c.SendCmd(cmd)
+}
+
+// GetStratumInfo returns information about the built-in stratum mining server
+// along with the share accounting of its workers.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) GetStratumInfo() (*btcjson.GetStratumInfoResult, error) {
+	return c.GetStratumInfoAsync().Receive()
+}
+
 // FutureGetNetworkHashPS is a future promise to deliver the result of a
 // GetNetworkHashPSAsync RPC invocation (or an applicable error).
 type FutureGetNetworkHashPS chan *Response
@@ -497,3 +607,52 @@ func (c *Client) GetBlockTemplateAsync(req *btcjson.TemplateRequest) FutureGetBl
 func (c *Client) GetBlockTemplate(req *btcjson.TemplateRequest) (*btcjson.GetBlockTemplateResult, error) {
 	return c.GetBlockTemplateAsync(req).Receive()
 }
+
+// FuturePrioritiseTransactionResult is a future promise to deliver the result
+// of a PrioritiseTransactionAsync RPC invocation (or an applicable error).
+type FuturePrioritiseTransactionResult chan *Response
+
+// Receive waits for the Response promised by the future and returns whether or
+// not the fee delta was applied.
+func (r FuturePrioritiseTransactionResult) Receive() (bool, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return false, err
+	}
+
+	// Unmarshal result as a boolean.
+	var result bool
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return false, err
+	}
+
+	return result, nil
+}
+
+// PrioritiseTransactionAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See PrioritiseTransaction for the blocking version and more details.
+func (c *Client) PrioritiseTransactionAsync(txHash *chainhash.Hash,
+	feeDelta int64) FuturePrioritiseTransactionResult {
+
+	hash := ""
+	if txHash != nil {
+		hash = txHash.String()
+	}
+
+	cmd := btcjson.NewPrioritiseTransactionCmd(hash, feeDelta)
+	return c.SendCmd(cmd)
+}
+
+// PrioritiseTransaction adds the passed fee delta, in satoshis, to the fee of
+// the transaction with the given hash for the purposes of mempool acceptance
+// and block template transaction selection.  The transaction does not need to
+// be in the memory pool yet.
+func (c *Client) PrioritiseTransaction(txHash *chainhash.Hash,
+	feeDelta int64) (bool, error) {
+
+	return c.PrioritiseTransactionAsync(txHash, feeDelta).Receive()
+}
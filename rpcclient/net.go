@@ -391,3 +391,142 @@ func (c *Client) GetNetTotalsAsync() FutureGetNetTotalsResult {
 func (c *Client) GetNetTotals() (*btcjson.GetNetTotalsResult, error) {
 	return c.GetNetTotalsAsync().Receive()
 }
+
+// FutureSetBanResult is a future promise to deliver the result of a
+// SetBanAsync RPC invocation (or an applicable error).
+type FutureSetBanResult chan *Response
+
+// Receive waits for the Response promised by the future and returns an error if
+// any occurred when banning or unbanning the IP or subnet.
+func (r FutureSetBanResult) Receive() error {
+	_, err := ReceiveFuture(r)
+	return err
+}
+
+// SetBanAsync returns an instance of a type that can be used to get the result
+// of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See SetBan for the blocking version and more details.
+func (c *Client) SetBanAsync(subnet string, command btcjson.SetBanSubCmd,
+	banTime *int64, absolute *bool) FutureSetBanResult {
+
+	cmd := btcjson.NewSetBanCmd(subnet, command, banTime, absolute)
+	return c.SendCmd(cmd)
+}
+
+// SetBan bans or unbans the passed IP or subnet in CIDR notation.  The ban
+// time is the duration of the ban in seconds, or the time the ban expires in
+// seconds since 1 Jan 1970 GMT when absolute is set.  Passing nil for the ban
+// time bans the IP or subnet for the ban duration configured on the server.
+func (c *Client) SetBan(subnet string, command btcjson.SetBanSubCmd,
+	banTime *int64, absolute *bool) error {
+
+	return c.SetBanAsync(subnet, command, banTime, absolute).Receive()
+}
+
+// FutureListBannedResult is a future promise to deliver the result of a
+// ListBannedAsync RPC invocation (or an applicable error).
+type FutureListBannedResult chan *Response
+
+// Receive waits for the Response promised by the future and returns the banned
+// IPs and subnets.
+func (r FutureListBannedResult) Receive() ([]btcjson.ListBannedResult, error) {
+	res, err := ReceiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as an array of listbanned result objects.
+	var bans []btcjson.ListBannedResult
+	err = json.Unmarshal(res, &bans)
+	if err != nil {
+		return nil, err
+	}
+
+	return bans, nil
+}
+
+// ListBannedAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See ListBanned for the blocking version and more details.
+func (c *Client) ListBannedAsync() FutureListBannedResult {
+	cmd := btcjson.NewListBannedCmd()
+	return c.SendCmd(cmd)
+}
+
+// ListBanned returns the banned IPs and subnets.
+func (c *Client) ListBanned() ([]btcjson.ListBannedResult, error) {
+	return c.ListBannedAsync().Receive()
+}
+
+// FutureClearBannedResult is a future promise to deliver the result of a
+// ClearBannedAsync RPC invocation (or an applicable error).
+type FutureClearBannedResult chan *Response
+
+// Receive waits for the Response promised by the future and returns an error if
+// any occurred when removing the bans.
+func (r FutureClearBannedResult) Receive() error {
+	_, err := ReceiveFuture(r)
+	return err
+}
+
+// ClearBannedAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See ClearBanned for the blocking version and more details.
+func (c *Client) ClearBannedAsync() FutureClearBannedResult {
+	cmd := btcjson.NewClearBannedCmd()
+	return c.SendCmd(cmd)
+}
+
+// ClearBanned removes all bans of IPs and subnets.
+func (c *Client) ClearBanned() error {
+	return c.ClearBannedAsync().Receive()
+}
+
+// FutureDisconnectNodeResult is a future promise to deliver the result of a
+// DisconnectNodeAsync RPC invocation (or an applicable error).
+type FutureDisconnectNodeResult chan *Response
+
+// Receive waits for the Response promised by the future and returns an error if
+// any occurred when disconnecting the peer.
+func (r FutureDisconnectNodeResult) Receive() error {
+	_, err := ReceiveFuture(r)
+	return err
+}
+
+// DisconnectNodeAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See DisconnectNode for the blocking version and more details.
+func (c *Client) DisconnectNodeAsync(address string) FutureDisconnectNodeResult {
+	cmd := btcjson.NewDisconnectNodeCmd(&address, nil)
+	return c.SendCmd(cmd)
+}
+
+// DisconnectNode disconnects the peer with the passed address.
+func (c *Client) DisconnectNode(address string) error {
+	return c.DisconnectNodeAsync(address).Receive()
+}
+
+// DisconnectNodeByIDAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See DisconnectNodeByID for the blocking version and more details.
+func (c *Client) DisconnectNodeByIDAsync(nodeID int32) FutureDisconnectNodeResult {
+	address := ""
+	cmd := btcjson.NewDisconnectNodeCmd(&address, &nodeID)
+	return c.SendCmd(cmd)
+}
+
+// DisconnectNodeByID disconnects the peer with the passed node ID as shown by
+// GetPeerInfo.
+func (c *Client) DisconnectNodeByID(nodeID int32) error {
+	return c.DisconnectNodeByIDAsync(nodeID).Receive()
+}
@@ -32,11 +32,13 @@ var (
 // registered notification so the state can be automatically re-established on
 // reconnect.
 type notificationState struct {
-	notifyBlocks       bool
-	notifyNewTx        bool
-	notifyNewTxVerbose bool
-	notifyReceived     map[string]struct{}
-	notifySpent        map[btcjson.OutPoint]struct{}
+	notifyBlocks          bool
+	notifyNewTx           bool
+	notifyNewTxVerbose    bool
+	notifyMempoolSequence bool
+	notifyBlockTemplate   bool
+	notifyReceived        map[string]struct{}
+	notifySpent           map[btcjson.OutPoint]struct{}
 }
 
 // Copy returns a deep copy of the receiver.
@@ -45,6 +47,8 @@ func (s *notificationState) Copy() *notificationState {
 	stateCopy.notifyBlocks = s.notifyBlocks
 	stateCopy.notifyNewTx = s.notifyNewTx
 	stateCopy.notifyNewTxVerbose = s.notifyNewTxVerbose
+	stateCopy.notifyMempoolSequence = s.notifyMempoolSequence
+	stateCopy.notifyBlockTemplate = s.notifyBlockTemplate
 	stateCopy.notifyReceived = make(map[string]struct{})
 	for addr := range s.notifyReceived {
 		stateCopy.notifyReceived[addr] = struct{}{}
@@ -179,6 +183,28 @@ type NotificationHandlers struct {
 	// made to register for the notification and the function is non-nil.
 	OnTxAcceptedVerbose func(txDetails *btcjson.TxRawResult)
 
+	// OnMempoolSequence is invoked when a transaction is added to or
+	// removed from the memory pool.  The event is either
+	// btcjson.MempoolSequenceAdded or btcjson.MempoolSequenceRemoved, and
+	// the sequence is the mempool sequence number resulting from the event.
+	// The reason is only set for removals, while replacedBy is only
+	// non-nil for removals caused by a replacement or a conflicting
+	// transaction being mined.  It will only be invoked if a preceding call
+	// to NotifyMempoolSequence has been made to register for the
+	// notification and the function is non-nil.
+	OnMempoolSequence func(hash *chainhash.Hash, event string,
+		sequence uint64, reason string, replacedBy *chainhash.Hash)
+
+	// OnBlockTemplateChanged is invoked when the transactions included in
+	// a new block template change.  The template builds on the block with
+	// the passed hash at the passed height, includes numTxns transactions
+	// besides the coinbase which pay the passed total fees, and results in
+	// a block of the passed weight.  It will only be invoked if a preceding
+	// call to NotifyBlockTemplate has been made to register for the
+	// notification and the function is non-nil.
+	OnBlockTemplateChanged func(prevHash *chainhash.Hash, height int32,
+		numTxns int, fees, weight int64)
+
 	// OnBtcdConnected is invoked when a wallet connects or disconnects from
 	// btcd.
 	//
@@ -409,6 +435,44 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 
 		c.ntfnHandlers.OnTxAcceptedVerbose(rawTx)
 
+	// OnMempoolSequence
+	case btcjson.MempoolSequenceNtfnMethod:
+		// Ignore the notification if the client is not interested in
+		// it.
+		if c.ntfnHandlers.OnMempoolSequence == nil {
+			return
+		}
+
+		hash, event, sequence, reason, replacedBy, err :=
+			parseMempoolSequenceNtfnParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid mempool sequence "+
+				"notification: %v", err)
+			return
+		}
+
+		c.ntfnHandlers.OnMempoolSequence(hash, event, sequence, reason,
+			replacedBy)
+
+	// OnBlockTemplateChanged
+	case btcjson.BlockTemplateChangedNtfnMethod:
+		// Ignore the notification if the client is not interested in
+		// it.
+		if c.ntfnHandlers.OnBlockTemplateChanged == nil {
+			return
+		}
+
+		prevHash, height, numTxns, fees, weight, err :=
+			parseBlockTemplateChangedNtfnParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid block template changed "+
+				"notification: %v", err)
+			return
+		}
+
+		c.ntfnHandlers.OnBlockTemplateChanged(prevHash, height, numTxns,
+			fees, weight)
+
 	// OnBtcdConnected
 	case btcjson.BtcdConnectedNtfnMethod:
 		// Ignore the notification if the client is not interested in
@@ -753,6 +817,124 @@ func parseTxAcceptedNtfnParams(params []json.RawMessage) (*chainhash.Hash,
 	return txHash, amt, nil
 }
 
+// parseMempoolSequenceNtfnParams parses out the transaction hash, event,
+// sequence number, removal reason and replacing transaction hash from the
+// parameters of a mempoolsequence notification.
+func parseMempoolSequenceNtfnParams(params []json.RawMessage) (*chainhash.Hash,
+	string, uint64, string, *chainhash.Hash, error) {
+
+	if len(params) < 3 || len(params) > 5 {
+		return nil, "", 0, "", nil, wrongNumParams(len(params))
+	}
+
+	// Unmarshal first parameter as a string.
+	var txHashStr string
+	err := json.Unmarshal(params[0], &txHashStr)
+	if err != nil {
+		return nil, "", 0, "", nil, err
+	}
+
+	// Unmarshal second parameter as a string.
+	var event string
+	err = json.Unmarshal(params[1], &event)
+	if err != nil {
+		return nil, "", 0, "", nil, err
+	}
+
+	// Unmarshal third parameter as an unsigned integer.
+	var sequence uint64
+	err = json.Unmarshal(params[2], &sequence)
+	if err != nil {
+		return nil, "", 0, "", nil, err
+	}
+
+	// Unmarshal the optional fourth parameter as a string.
+	var reason string
+	if len(params) > 3 {
+		err = json.Unmarshal(params[3], &reason)
+		if err != nil {
+			return nil, "", 0, "", nil, err
+		}
+	}
+
+	// Unmarshal the optional fifth parameter as a string and decode it as
+	// the hash of the replacing transaction.
+	var replacedBy *chainhash.Hash
+	if len(params) > 4 {
+		var replacedByStr string
+		err = json.Unmarshal(params[4], &replacedByStr)
+		if err != nil {
+			return nil, "", 0, "", nil, err
+		}
+		replacedBy, err = chainhash.NewHashFromStr(replacedByStr)
+		if err != nil {
+			return nil, "", 0, "", nil, err
+		}
+	}
+
+	// Decode string encoding of transaction sha.
+	txHash, err := chainhash.NewHashFromStr(txHashStr)
+	if err != nil {
+		return nil, "", 0, "", nil, err
+	}
+
+	return txHash, event, sequence, reason, replacedBy, nil
+}
+
+// parseBlockTemplateChangedNtfnParams parses out the previous block hash,
+// height, number of transactions, total fees and weight of a new block template
+// from the parameters of a blocktemplatechanged notification.
+func parseBlockTemplateChangedNtfnParams(params []json.RawMessage) (*chainhash.Hash,
+	int32, int, int64, int64, error) {
+
+	if len(params) != 5 {
+		return nil, 0, 0, 0, 0, wrongNumParams(len(params))
+	}
+
+	// Unmarshal first parameter as a string.
+	var prevHashStr string
+	err := json.Unmarshal(params[0], &prevHashStr)
+	if err != nil {
+		return nil, 0, 0, 0, 0, err
+	}
+
+	// Unmarshal second parameter as an integer.
+	var height int32
+	err = json.Unmarshal(params[1], &height)
+	if err != nil {
+		return nil, 0, 0, 0, 0, err
+	}
+
+	// Unmarshal third parameter as an integer.
+	var numTxns int
+	err = json.Unmarshal(params[2], &numTxns)
+	if err != nil {
+		return nil, 0, 0, 0, 0, err
+	}
+
+	// Unmarshal fourth parameter as an integer.
+	var fees int64
+	err = json.Unmarshal(params[3], &fees)
+	if err != nil {
+		return nil, 0, 0, 0, 0, err
+	}
+
+	// Unmarshal fifth parameter as an integer.
+	var weight int64
+	err = json.Unmarshal(params[4], &weight)
+	if err != nil {
+		return nil, 0, 0, 0, 0, err
+	}
+
+	// Decode string encoding of the previous block hash.
+	prevHash, err := chainhash.NewHashFromStr(prevHashStr)
+	if err != nil {
+		return nil, 0, 0, 0, 0, err
+	}
+
+	return prevHash, height, numTxns, fees, weight, nil
+}
+
 // parseTxAcceptedVerboseNtfnParams parses out details about a raw transaction
 // from the parameters of a txacceptedverbose notification.
 func parseTxAcceptedVerboseNtfnParams(params []json.RawMessage) (*btcjson.TxRawResult,
@@ -1037,6 +1219,104 @@ func (c *Client) NotifyNewTransactions(verbose bool) error {
 	return c.NotifyNewTransactionsAsync(verbose).Receive()
 }
 
+// FutureNotifyMempoolSequenceResult is a future promise to deliver the result
+// of a NotifyMempoolSequenceAsync RPC invocation (or an applicable error).
+type FutureNotifyMempoolSequenceResult chan *Response
+
+// Receive waits for the Response promised by the future and returns an error
+// if the registration was not successful.
+func (r FutureNotifyMempoolSequenceResult) Receive() error {
+	_, err := ReceiveFuture(r)
+	return err
+}
+
+// NotifyMempoolSequenceAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See NotifyMempoolSequence for the blocking version and more details.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func (c *Client) NotifyMempoolSequenceAsync() FutureNotifyMempoolSequenceResult {
+	// Not supported in HTTP POST mode.
+	if c.config.HTTPPostMode {
+		return newFutureError(ErrWebsocketsRequired)
+	}
+
+	// Ignore the notification if the client is not interested in
+	// notifications.
+	if c.ntfnHandlers == nil {
+		return newNilFutureResult()
+	}
+
+	cmd := btcjson.NewNotifyMempoolSequenceCmd()
+	return c.SendCmd(cmd)
+}
+
+// NotifyMempoolSequence registers the client to receive notifications every
+// time a transaction is added to or removed from the memory pool.  The
+// notifications are delivered to the notification handlers associated with the
+// client.  Calling this function has no effect if there are no notification
+// handlers and will result in an error if the client is configured to run in
+// HTTP POST mode.
+//
+// The notifications delivered as a result of this call will be via
+// OnMempoolSequence.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func (c *Client) NotifyMempoolSequence() error {
+	return c.NotifyMempoolSequenceAsync().Receive()
+}
+
+// FutureNotifyBlockTemplateResult is a future promise to deliver the result of
+// a NotifyBlockTemplateAsync RPC invocation (or an applicable error).
+type FutureNotifyBlockTemplateResult chan *Response
+
+// Receive waits for the Response promised by the future and returns an error
+// if the registration was not successful.
+func (r FutureNotifyBlockTemplateResult) Receive() error {
+	_, err := ReceiveFuture(r)
+	return err
+}
+
+// NotifyBlockTemplateAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See NotifyBlockTemplate for the blocking version and more details.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func (c *Client) NotifyBlockTemplateAsync() FutureNotifyBlockTemplateResult {
+	// Not supported in HTTP POST mode.
+	if c.config.HTTPPostMode {
+		return newFutureError(ErrWebsocketsRequired)
+	}
+
+	// Ignore the notification if the client is not interested in
+	// notifications.
+	if c.ntfnHandlers == nil {
+		return newNilFutureResult()
+	}
+
+	cmd := btcjson.NewNotifyBlockTemplateCmd()
+	return c.SendCmd(cmd)
+}
+
+// NotifyBlockTemplate registers the client to receive notifications every
+// time the transactions included in a new block template change.  The
+// notifications are delivered to the notification handlers associated with the
+// client.  Calling this function has no effect if there are no notification
+// handlers and will result in an error if the client is configured to run in
+// HTTP POST mode.
+//
+// The notifications delivered as a result of this call will be via
+// OnBlockTemplateChanged.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func (c *Client) NotifyBlockTemplate() error {
+	return c.NotifyBlockTemplateAsync().Receive()
+}
+
 // FutureNotifyReceivedResult is a future promise to deliver the result of a
 // NotifyReceivedAsync RPC invocation (or an applicable error).
 //
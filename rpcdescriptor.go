@@ -0,0 +1,219 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+const (
+	// descriptorInputCharset is the set of characters a descriptor may
+	// consist of in the order used to calculate its checksum as defined by
+	// BIP 380.
+	descriptorInputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+		"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+		"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+
+	// descriptorChecksumCharset is the set of characters the checksum of a
+	// descriptor is encoded with.
+	descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+	// descriptorChecksumLen is the length of a descriptor checksum.
+	descriptorChecksumLen = 8
+)
+
+// descriptorPolyMod updates the passed descriptor checksum state with the
+// passed value.
+func descriptorPolyMod(c uint64, val int) uint64 {
+	generator := [5]uint64{0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d,
+		0x3706b1677a, 0x644d626ffd}
+
+	c0 := c >> 35
+	c = ((c & 0x7ffffffff) << 5) ^ uint64(val)
+	for i := uint(0); i < 5; i++ {
+		if (c0>>i)&1 != 0 {
+			c ^= generator[i]
+		}
+	}
+	return c
+}
+
+// descriptorChecksum returns the BIP 380 checksum of the passed descriptor
+// without a checksum.
+func descriptorChecksum(desc string) (string, error) {
+	c := uint64(1)
+	cls, clsCount := 0, 0
+	for _, ch := range desc {
+		pos := strings.IndexRune(descriptorInputCharset, ch)
+		if pos == -1 {
+			return "", fmt.Errorf("invalid character %q in descriptor",
+				ch)
+		}
+
+		// Emit a symbol for the position inside the group for every
+		// character and a symbol for the group itself for every three
+		// characters.
+		c = descriptorPolyMod(c, pos&31)
+		cls = cls*3 + pos>>5
+		clsCount++
+		if clsCount == 3 {
+			c = descriptorPolyMod(c, cls)
+			cls, clsCount = 0, 0
+		}
+	}
+	if clsCount > 0 {
+		c = descriptorPolyMod(c, cls)
+	}
+	for i := 0; i < descriptorChecksumLen; i++ {
+		c = descriptorPolyMod(c, 0)
+	}
+	c ^= 1
+
+	var checksum [descriptorChecksumLen]byte
+	for i := range checksum {
+		shift := 5 * uint(descriptorChecksumLen-1-i)
+		checksum[i] = descriptorChecksumCharset[(c>>shift)&31]
+	}
+	return string(checksum[:]), nil
+}
+
+// descriptorArg returns the argument of the passed descriptor expression when
+// it is a call to the passed function and whether or not it is.
+func descriptorArg(desc, fn string) (string, bool) {
+	if !strings.HasPrefix(desc, fn+"(") || !strings.HasSuffix(desc, ")") {
+		return "", false
+	}
+	return desc[len(fn)+1 : len(desc)-1], true
+}
+
+// parseDescriptorKey parses a hex-encoded public key of a descriptor.
+func parseDescriptorKey(key string) (*btcec.PublicKey, []byte, error) {
+	serialized, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("key '%s' is not a hex-encoded "+
+			"public key", key)
+	}
+	pubKey, err := btcec.ParsePubKey(serialized)
+	if err != nil {
+		return nil, nil, fmt.Errorf("key '%s' is not a valid public "+
+			"key: %v", key, err)
+	}
+	return pubKey, serialized, nil
+}
+
+// descriptorAddress returns the address the passed output descriptor resolves
+// to for the passed network.  Only descriptors which resolve to a single
+// address are supported, which are the following where KEY is a hex-encoded
+// public key:
+//
+//	addr(ADDR), pk(KEY), pkh(KEY), wpkh(KEY), sh(wpkh(KEY)), tr(KEY)
+//
+// The descriptor may optionally end with a checksum as defined by BIP 380,
+// which must be valid when present.
+func descriptorAddress(desc string, params *chaincfg.Params) (btcutil.Address, error) {
+	if i := strings.IndexByte(desc, '#'); i != -1 {
+		checksum, err := descriptorChecksum(desc[:i])
+		if err != nil {
+			return nil, err
+		}
+		if desc[i+1:] != checksum {
+			return nil, fmt.Errorf("invalid descriptor checksum "+
+				"'%s', expected '%s'", desc[i+1:], checksum)
+		}
+		desc = desc[:i]
+	} else if _, err := descriptorChecksum(desc); err != nil {
+		return nil, err
+	}
+
+	if arg, ok := descriptorArg(desc, "addr"); ok {
+		addr, err := btcutil.DecodeAddress(arg, params)
+		if err != nil {
+			return nil, err
+		}
+		if !addr.IsForNet(params) {
+			return nil, fmt.Errorf("address '%s' is on the wrong "+
+				"network", arg)
+		}
+		return addr, nil
+	}
+
+	if arg, ok := descriptorArg(desc, "pk"); ok {
+		_, serialized, err := parseDescriptorKey(arg)
+		if err != nil {
+			return nil, err
+		}
+		return btcutil.NewAddressPubKey(serialized, params)
+	}
+
+	if arg, ok := descriptorArg(desc, "pkh"); ok {
+		_, serialized, err := parseDescriptorKey(arg)
+		if err != nil {
+			return nil, err
+		}
+		return btcutil.NewAddressPubKeyHash(btcutil.Hash160(serialized),
+			params)
+	}
+
+	if arg, ok := descriptorArg(desc, "wpkh"); ok {
+		pubKey, _, err := parseDescriptorKey(arg)
+		if err != nil {
+			return nil, err
+		}
+		return btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(
+			pubKey.SerializeCompressed()), params)
+	}
+
+	if arg, ok := descriptorArg(desc, "sh"); ok {
+		inner, ok := descriptorArg(arg, "wpkh")
+		if !ok {
+			return nil, errors.New("only sh(wpkh(KEY)) descriptors " +
+				"are supported")
+		}
+		pubKey, _, err := parseDescriptorKey(inner)
+		if err != nil {
+			return nil, err
+		}
+		witnessAddr, err := btcutil.NewAddressWitnessPubKeyHash(
+			btcutil.Hash160(pubKey.SerializeCompressed()), params)
+		if err != nil {
+			return nil, err
+		}
+		script, err := txscript.PayToAddrScript(witnessAddr)
+		if err != nil {
+			return nil, err
+		}
+		return btcutil.NewAddressScriptHash(script, params)
+	}
+
+	if arg, ok := descriptorArg(desc, "tr"); ok {
+		// Taproot descriptors commit to x-only public keys, however
+		// compressed keys are accepted as well.
+		var internalKey *btcec.PublicKey
+		serialized, err := hex.DecodeString(arg)
+		switch {
+		case err == nil && len(serialized) == schnorr.PubKeyBytesLen:
+			internalKey, err = schnorr.ParsePubKey(serialized)
+		default:
+			internalKey, _, err = parseDescriptorKey(arg)
+		}
+		if err != nil {
+			return nil, err
+		}
+		outputKey := txscript.ComputeTaprootKeyNoScript(internalKey)
+		return btcutil.NewAddressTaproot(
+			schnorr.SerializePubKey(outputKey), params)
+	}
+
+	return nil, fmt.Errorf("unsupported descriptor '%s'", desc)
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// TestDescriptorChecksum ensures descriptor checksums are calculated as
+// defined by BIP 380.
+func TestDescriptorChecksum(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc string
+		want string
+	}{
+		{"raw(deadbeef)", "89f8spxm"},
+		{"addr(mkmZxiEcEd8ZqjQWVZuC6so5dFMKEFpN2j)", "02wpgw69"},
+	}
+	for _, test := range tests {
+		got, err := descriptorChecksum(test.desc)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.desc, err)
+		}
+		if got != test.want {
+			t.Fatalf("%s: unexpected checksum: got %s, want %s",
+				test.desc, got, test.want)
+		}
+	}
+}
+
+// TestDescriptorAddress ensures descriptors resolve to the expected addresses.
+func TestDescriptorAddress(t *testing.T) {
+	t.Parallel()
+
+	const key = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	tests := []struct {
+		desc  string
+		param *chaincfg.Params
+		want  string
+	}{{
+		desc:  "addr(mkmZxiEcEd8ZqjQWVZuC6so5dFMKEFpN2j)#02wpgw69",
+		param: &chaincfg.TestNet3Params,
+		want:  "mkmZxiEcEd8ZqjQWVZuC6so5dFMKEFpN2j",
+	}, {
+		desc:  "pkh(" + key + ")",
+		param: &chaincfg.MainNetParams,
+		want:  "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH",
+	}, {
+		desc:  "wpkh(" + key + ")",
+		param: &chaincfg.MainNetParams,
+		want:  "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+	}, {
+		desc:  "sh(wpkh(" + key + "))",
+		param: &chaincfg.MainNetParams,
+		want:  "3JvL6Ymt8MVWiCNHC7oWU6nLeHNJKLZGLN",
+	}, {
+		desc:  "pk(" + key + ")",
+		param: &chaincfg.MainNetParams,
+		want:  key,
+	}}
+	for _, test := range tests {
+		addr, err := descriptorAddress(test.desc, test.param)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.desc, err)
+		}
+		if got := addr.String(); got != test.want {
+			t.Fatalf("%s: unexpected address: got %s, want %s",
+				test.desc, got, test.want)
+		}
+	}
+
+	// Taproot descriptors accept both x-only and compressed keys.
+	xOnly, err := descriptorAddress("tr("+key[2:]+")",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	compressed, err := descriptorAddress("tr("+key+")",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if xOnly.String() != compressed.String() {
+		t.Fatalf("mismatched taproot addresses %s and %s", xOnly,
+			compressed)
+	}
+
+	invalid := []string{
+		"addr(mkmZxiEcEd8ZqjQWVZuC6so5dFMKEFpN2j)#00000000",
+		"addr(mkmZxiEcEd8ZqjQWVZuC6so5dFMKEFpN2j)",
+		"pkh(00)",
+		"sh(pkh(" + key + "))",
+		"raw(deadbeef)",
+		"wpkh(" + key + ")\n",
+	}
+	for _, desc := range invalid {
+		if _, err := descriptorAddress(desc, &chaincfg.MainNetParams); err == nil {
+			t.Fatalf("%q: invalid descriptor was accepted", desc)
+		}
+	}
+}
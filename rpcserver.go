@@ -16,6 +16,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"math/big"
 	"math/rand"
 	"net"
@@ -38,6 +39,7 @@ import (
 	"github.com/btcsuite/btcd/mempool"
 	"github.com/btcsuite/btcd/mining"
 	"github.com/btcsuite/btcd/mining/cpuminer"
+	"github.com/btcsuite/btcd/mining/stratum"
 	"github.com/btcsuite/btcd/peer"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
@@ -73,8 +75,17 @@ const (
 	// in the memory pool.
 	gbtRegenerateSeconds = 60
 
+	// gbtMaxCachedTemplates is the maximum number of block templates with
+	// different extra coinbase outputs which are cached for the current
+	// best block and memory pool contents.
+	gbtMaxCachedTemplates = 16
+
 	// maxProtocolVersion is the max protocol version the server supports.
 	maxProtocolVersion = 70002
+
+	// maxProjectedBlocks is the maximum number of blocks that may be
+	// projected from the memory pool by the estimatemempoolfee RPC.
+	maxProjectedBlocks = 100
 )
 
 var (
@@ -86,16 +97,6 @@ var (
 		"time", "transactions/add", "prevblock", "coinbase/append",
 	}
 
-	// gbtCoinbaseAux describes additional data that miners should include
-	// in the coinbase signature script.  It is declared here to avoid the
-	// overhead of creating a new object on every invocation for constant
-	// data.
-	gbtCoinbaseAux = &btcjson.GetBlockTemplateResultAux{
-		Flags: hex.EncodeToString(builderScript(txscript.
-			NewScriptBuilder().
-			AddData([]byte(mining.CoinbaseFlags)))),
-	}
-
 	// gbtCapabilities describes additional capabilities returned with a
 	// block template generated by the getblocktemplate RPC.    It is
 	// declared here to avoid the overhead of creating the slice on every
@@ -131,12 +132,20 @@ type commandHandler func(*rpcServer, interface{}, <-chan struct{}) (interface{},
 var rpcHandlers map[string]commandHandler
 var rpcHandlersBeforeInit = map[string]commandHandler{
 	"addnode":                handleAddNode,
+	"clearbanned":            handleClearBanned,
 	"createrawtransaction":   handleCreateRawTransaction,
 	"debuglevel":             handleDebugLevel,
 	"decoderawtransaction":   handleDecodeRawTransaction,
 	"decodescript":           handleDecodeScript,
+	"disconnectnode":         handleDisconnectNode,
 	"estimatefee":            handleEstimateFee,
+	"estimatemempoolfee":     handleEstimateMempoolFee,
+	"estimaterawfee":         handleEstimateRawFee,
+	"estimatesmartfee":       handleEstimateSmartFee,
 	"generate":               handleGenerate,
+	"generateblock":          handleGenerateBlock,
+	"generatetoaddress":      handleGenerateToAddress,
+	"generatetodescriptor":   handleGenerateToDescriptor,
 	"getaddednodeinfo":       handleGetAddedNodeInfo,
 	"getbestblock":           handleGetBestBlock,
 	"getbestblockhash":       handleGetBestBlockHash,
@@ -155,6 +164,7 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"gethashespersec":        handleGetHashesPerSec,
 	"getheaders":             handleGetHeaders,
 	"getinfo":                handleGetInfo,
+	"getmempoolentry":        handleGetMempoolEntry,
 	"getmempoolinfo":         handleGetMempoolInfo,
 	"getmininginfo":          handleGetMiningInfo,
 	"getnettotals":           handleGetNetTotals,
@@ -163,12 +173,16 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"getpeerinfo":            handleGetPeerInfo,
 	"getrawmempool":          handleGetRawMempool,
 	"getrawtransaction":      handleGetRawTransaction,
+	"getstratuminfo":         handleGetStratumInfo,
 	"gettxout":               handleGetTxOut,
 	"help":                   handleHelp,
+	"listbanned":             handleListBanned,
 	"node":                   handleNode,
 	"ping":                   handlePing,
+	"prioritisetransaction":  handlePrioritiseTransaction,
 	"searchrawtransactions":  handleSearchRawTransactions,
 	"sendrawtransaction":     handleSendRawTransaction,
+	"setban":                 handleSetBan,
 	"setgenerate":            handleSetGenerate,
 	"signmessagewithprivkey": handleSignMessageWithPrivKey,
 	"stop":                   handleStop,
@@ -232,7 +246,6 @@ var rpcAskWallet = map[string]struct{}{
 var rpcUnimplemented = map[string]struct{}{
 	"estimatepriority": {},
 	"getchaintips":     {},
-	"getmempoolentry":  {},
 	"getnetworkinfo":   {},
 	"getwork":          {},
 	"invalidateblock":  {},
@@ -260,6 +273,9 @@ var rpcLimited = map[string]struct{}{
 	"decoderawtransaction":  {},
 	"decodescript":          {},
 	"estimatefee":           {},
+	"estimatemempoolfee":    {},
+	"estimaterawfee":        {},
+	"estimatesmartfee":      {},
 	"getbestblock":          {},
 	"getbestblockhash":      {},
 	"getblock":              {},
@@ -272,9 +288,11 @@ var rpcLimited = map[string]struct{}{
 	"getdifficulty":         {},
 	"getheaders":            {},
 	"getinfo":               {},
+	"getmempoolentry":       {},
 	"getnettotals":          {},
 	"getnetworkhashps":      {},
 	"getrawmempool":         {},
+	"getstratuminfo":        {},
 	"getrawtransaction":     {},
 	"gettxout":              {},
 	"searchrawtransactions": {},
@@ -337,20 +355,49 @@ type gbtWorkState struct {
 	lastGenerated time.Time
 	prevHash      *chainhash.Hash
 	minTimestamp  time.Time
-	template      *mining.BlockTemplate
 	notifyMap     map[chainhash.Hash]map[int64]chan struct{}
 	timeSource    blockchain.MedianTimeSource
+
+	// templates houses the block templates generated for the current best
+	// block and memory pool contents keyed by their extra coinbase
+	// outputs, so clients requesting different outputs don't replace each
+	// other's templates.  All of them share the long poll ID of the
+	// current generation of templates.
+	templates map[string]*mining.BlockTemplate
+
+	// coinbaseAux describes additional data that miners should include in
+	// the coinbase signature script.  It is created once to avoid the
+	// overhead of creating a new object on every invocation for constant
+	// data.
+	coinbaseAux *btcjson.GetBlockTemplateResultAux
 }
 
 // newGbtWorkState returns a new instance of a gbtWorkState with all internal
-// fields initialized and ready to use.
-func newGbtWorkState(timeSource blockchain.MedianTimeSource) *gbtWorkState {
+// fields initialized and ready to use.  The passed coinbase tag is included in
+// the coinbase flags miners are asked to include in their coinbase.
+func newGbtWorkState(timeSource blockchain.MedianTimeSource, coinbaseTag string) *gbtWorkState {
 	return &gbtWorkState{
 		notifyMap:  make(map[chainhash.Hash]map[int64]chan struct{}),
 		timeSource: timeSource,
+		coinbaseAux: &btcjson.GetBlockTemplateResultAux{
+			Flags: hex.EncodeToString(builderScript(txscript.
+				NewScriptBuilder().
+				AddData(mining.CoinbaseFlagsWithTag(coinbaseTag)))),
+		},
 	}
 }
 
+// txOutsKey returns a key which uniquely identifies the passed list of
+// transaction outputs.
+func txOutsKey(txOuts []*wire.TxOut) string {
+	var buf bytes.Buffer
+	for _, txOut := range txOuts {
+		// Writing to a bytes.Buffer can't fail.
+		_ = wire.WriteTxOut(&buf, 0, 0, txOut)
+	}
+	return buf.String()
+}
+
 // handleUnimplemented is the handler for commands that should ultimately be
 // supported but are not yet implemented.
 func handleUnimplemented(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
@@ -513,6 +560,15 @@ func messageToHex(msg wire.Message) (string, error) {
 	return hex.EncodeToString(buf.Bytes()), nil
 }
 
+// handleClearBanned implements the clearbanned command.
+func handleClearBanned(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if err := s.cfg.ConnMgr.ClearBanned(); err != nil {
+		return nil, internalRPCError(err.Error(),
+			"Failed to save the ban list")
+	}
+	return nil, nil
+}
+
 // handleCreateRawTransaction handles createrawtransaction commands.
 func handleCreateRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.CreateRawTransactionCmd)
@@ -860,6 +916,38 @@ func handleDecodeScript(s *rpcServer, cmd interface{}, closeChan <-chan struct{}
 	return reply, nil
 }
 
+// handleDisconnectNode implements the disconnectnode command.
+func handleDisconnectNode(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DisconnectNodeCmd)
+
+	var address string
+	if c.Address != nil {
+		address = *c.Address
+	}
+
+	var err error
+	switch {
+	case address != "" && c.NodeID == nil:
+		addr := normalizeAddress(address, s.cfg.ChainParams.DefaultPort)
+		err = s.cfg.ConnMgr.DisconnectByAddr(addr)
+	case address == "" && c.NodeID != nil:
+		err = s.cfg.ConnMgr.DisconnectByID(*c.NodeID)
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Only one of address and nodeid should be provided.",
+		}
+	}
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCClientNodeNotConnected,
+			Message: "Node not found in connected nodes",
+		}
+	}
+
+	return nil, nil
+}
+
 // handleEstimateFee handles estimatefee commands.
 func handleEstimateFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.EstimateFeeCmd)
@@ -882,28 +970,260 @@ func handleEstimateFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return float64(feeRate), nil
 }
 
+// handleEstimateMempoolFee handles estimatemempoolfee commands.
+func handleEstimateMempoolFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.EstimateMempoolFeeCmd)
+
+	numBlocks := int64(8)
+	if c.NumBlocks != nil {
+		numBlocks = *c.NumBlocks
+	}
+	if numBlocks < 1 || numBlocks > maxProjectedBlocks {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Invalid number of blocks, must be "+
+				"between 1 and %d", maxProjectedBlocks),
+		}
+	}
+
+	// Fee rates are rounded to millisatoshis per virtual byte.
+	roundFeeRate := func(feeRate float64) float64 {
+		return math.Round(feeRate*1000) / 1000
+	}
+
+	blocks := s.cfg.Generator.ProjectBlocks(int(numBlocks))
+	result := make([]btcjson.ProjectedBlockResult, 0, len(blocks))
+	for _, block := range blocks {
+		percentiles := make([]float64, 0, len(block.FeeRatePercentiles))
+		for _, feeRate := range block.FeeRatePercentiles {
+			percentiles = append(percentiles, roundFeeRate(feeRate))
+		}
+		result = append(result, btcjson.ProjectedBlockResult{
+			NumTxs:             int64(block.NumTxns),
+			Weight:             block.Weight,
+			TotalFees:          btcutil.Amount(block.Fees).ToBTC(),
+			MinFeeRate:         roundFeeRate(block.MinFeeRate),
+			MaxFeeRate:         roundFeeRate(block.MaxFeeRate),
+			FeeRatePercentiles: percentiles,
+		})
+	}
+
+	return result, nil
+}
+
+// handleEstimateSmartFee handles estimatesmartfee commands.
+func handleEstimateSmartFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.EstimateSmartFeeCmd)
+
+	if s.cfg.FeeEstimator == nil {
+		return nil, errors.New("Fee estimation disabled")
+	}
+
+	maxTarget := s.cfg.FeeEstimator.HighestTargetTracked(mempool.FeeHorizonLong)
+	if c.ConfTarget < 1 || c.ConfTarget > int64(maxTarget) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Invalid conf_target, must be "+
+				"between 1 and %d", maxTarget),
+		}
+	}
+
+	// Estimates are conservative unless the economical mode is requested.
+	conservative := true
+	if c.EstimateMode != nil {
+		switch btcjson.EstimateSmartFeeMode(strings.ToUpper(string(*c.EstimateMode))) {
+		case btcjson.EstimateModeUnset, btcjson.EstimateModeConservative:
+		case btcjson.EstimateModeEconomical:
+			conservative = false
+		default:
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid estimate_mode parameter",
+			}
+		}
+	}
+
+	feeRate, target, err := s.cfg.FeeEstimator.EstimateSmartFee(
+		uint32(c.ConfTarget), conservative)
+	result := &btcjson.EstimateSmartFeeResult{Blocks: int64(target)}
+	if err != nil {
+		result.Errors = []string{"Insufficient data or no feerate found"}
+		return result, nil
+	}
+
+	// Never estimate less than the minimum fee rate required to relay a
+	// transaction.
+	btcPerKb := float64(feeRate)
+	if minRelayFee := cfg.minRelayTxFee.ToBTC(); btcPerKb < minRelayFee {
+		btcPerKb = minRelayFee
+	}
+	result.FeeRate = &btcPerKb
+
+	return result, nil
+}
+
+// handleEstimateRawFee handles estimaterawfee commands.
+func handleEstimateRawFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.EstimateRawFeeCmd)
+
+	if s.cfg.FeeEstimator == nil {
+		return nil, errors.New("Fee estimation disabled")
+	}
+
+	maxTarget := s.cfg.FeeEstimator.HighestTargetTracked(mempool.FeeHorizonLong)
+	if c.ConfTarget < 1 || c.ConfTarget > int64(maxTarget) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Invalid conf_target, must be "+
+				"between 1 and %d", maxTarget),
+		}
+	}
+
+	threshold := mempool.DefaultEstimateRawFeeThreshold
+	if c.Threshold != nil {
+		threshold = *c.Threshold
+	}
+	if threshold < 0 || threshold > 1 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid threshold",
+		}
+	}
+
+	bucketResult := func(stats *mempool.FeeRateBucketStats) *btcjson.FeeRateBucket {
+		return &btcjson.FeeRateBucket{
+			StartRange:     math.Round(stats.StartRange),
+			EndRange:       math.Round(stats.EndRange),
+			WithinTarget:   math.Round(stats.WithinTarget*100) / 100,
+			TotalConfirmed: math.Round(stats.TotalConfirmed*100) / 100,
+			InMempool:      math.Round(stats.InMempool*100) / 100,
+			LeftMempool:    math.Round(stats.LeftMempool*100) / 100,
+		}
+	}
+
+	result := &btcjson.EstimateRawFeeResult{}
+	for _, horizon := range mempool.FeeHorizons {
+		// Skip horizons which don't track the target.
+		if uint32(c.ConfTarget) > s.cfg.FeeEstimator.HighestTargetTracked(horizon) {
+			continue
+		}
+
+		estimate, err := s.cfg.FeeEstimator.EstimateRawFee(
+			uint32(c.ConfTarget), threshold, horizon)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: err.Error(),
+			}
+		}
+
+		horizonResult := &btcjson.EstimateRawFeeHorizonResult{
+			Decay: estimate.Decay,
+			Scale: int64(estimate.Scale),
+		}
+		if estimate.FeeRate != 0 {
+			feeRate := float64(estimate.FeeRate)
+			horizonResult.FeeRate = &feeRate
+			horizonResult.Pass = bucketResult(&estimate.Pass)
+			if estimate.Fail.StartRange != -1 {
+				horizonResult.Fail = bucketResult(&estimate.Fail)
+			}
+		} else {
+			horizonResult.Fail = bucketResult(&estimate.Fail)
+			horizonResult.Errors = []string{"Insufficient data or " +
+				"no feerate found which meets threshold"}
+		}
+
+		switch horizon {
+		case mempool.FeeHorizonShort:
+			result.Short = horizonResult
+		case mempool.FeeHorizonMedium:
+			result.Medium = horizonResult
+		case mempool.FeeHorizonLong:
+			result.Long = horizonResult
+		}
+	}
+
+	return result, nil
+}
+
+// checkGenerateSupported returns an error if there's virtually 0 chance of
+// mining a block with the CPU on the current network.
+func checkGenerateSupported(s *rpcServer, method string) error {
+	if !s.cfg.ChainParams.GenerateSupported {
+		return &btcjson.RPCError{
+			Code: btcjson.ErrRPCDifficulty,
+			Message: fmt.Sprintf("No support for `%s` on "+
+				"the current network, %s, as it's unlikely to "+
+				"be possible to mine a block with the CPU.",
+				method, s.cfg.ChainParams.Net),
+		}
+	}
+	return nil
+}
+
+// generateToAddress generates the passed number of blocks paying to the passed
+// address and returns their hashes.
+func generateToAddress(s *rpcServer, numBlocks int64, addr btcutil.Address) (interface{}, error) {
+	// Respond with an error if the client is requesting 0 blocks to be
+	// generated or more than fit the parameter of the miner.
+	if numBlocks <= 0 || numBlocks > math.MaxUint32 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Please request a nonzero number of blocks to generate.",
+		}
+	}
+
+	blockHashes, err := s.cfg.CPUMiner.GenerateNBlocksToAddress(
+		uint32(numBlocks), addr)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInternal.Code,
+			Message: err.Error(),
+		}
+	}
+
+	reply := make([]string, len(blockHashes))
+	for i, hash := range blockHashes {
+		reply[i] = hash.String()
+	}
+	return reply, nil
+}
+
+// decodeGenerateOutput decodes the passed output of a generate command, which
+// is either an address or an output descriptor, for the active network.
+func decodeGenerateOutput(s *rpcServer, output string) (btcutil.Address, error) {
+	addr, err := btcutil.DecodeAddress(output, s.cfg.ChainParams)
+	if err == nil && addr.IsForNet(s.cfg.ChainParams) {
+		return addr, nil
+	}
+	addr, err = descriptorAddress(output, s.cfg.ChainParams)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or descriptor: " +
+				err.Error(),
+		}
+	}
+	return addr, nil
+}
+
 // handleGenerate handles generate commands.
 func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Respond with an error if there are no addresses to pay the
 	// created blocks to.
-	if len(cfg.miningAddrs) == 0 {
+	if len(cfg.miningAddrs) == 0 && len(cfg.coinbasePayouts) == 0 {
 		return nil, &btcjson.RPCError{
 			Code: btcjson.ErrRPCInternal.Code,
 			Message: "No payment addresses specified " +
-				"via --miningaddr",
+				"via --miningaddr or --coinbasepayout",
 		}
 	}
 
 	// Respond with an error if there's virtually 0 chance of mining a block
 	// with the CPU.
-	if !s.cfg.ChainParams.GenerateSupported {
-		return nil, &btcjson.RPCError{
-			Code: btcjson.ErrRPCDifficulty,
-			Message: fmt.Sprintf("No support for `generate` on "+
-				"the current network, %s, as it's unlikely to "+
-				"be possible to mine a block with the CPU.",
-				s.cfg.ChainParams.Net),
-		}
+	if err := checkGenerateSupported(s, "generate"); err != nil {
+		return nil, err
 	}
 
 	c := cmd.(*btcjson.GenerateCmd)
@@ -936,6 +1256,114 @@ func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 	return reply, nil
 }
 
+// handleGenerateBlock handles generateblock commands.
+func handleGenerateBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GenerateBlockCmd)
+
+	if err := checkGenerateSupported(s, "generateblock"); err != nil {
+		return nil, err
+	}
+	addr, err := decodeGenerateOutput(s, c.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each transaction is either the hash of a transaction in the memory
+	// pool or a raw transaction which does not need to be in the memory
+	// pool.
+	txns := make([]*btcutil.Tx, 0, len(c.Transactions))
+	for _, str := range c.Transactions {
+		if len(str) == chainhash.MaxHashStringSize {
+			txHash, err := chainhash.NewHashFromStr(str)
+			if err != nil {
+				return nil, rpcDecodeHexError(str)
+			}
+			tx, err := s.cfg.TxMemPool.FetchTransaction(txHash)
+			if err != nil {
+				return nil, &btcjson.RPCError{
+					Code: btcjson.ErrRPCInvalidAddressOrKey,
+					Message: "Transaction " + str + " not " +
+						"in mempool.",
+				}
+			}
+			txns = append(txns, tx)
+			continue
+		}
+
+		serializedTx, err := hex.DecodeString(str)
+		if err != nil {
+			return nil, rpcDecodeHexError(str)
+		}
+		var msgTx wire.MsgTx
+		err = msgTx.Deserialize(bytes.NewReader(serializedTx))
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCDeserialization,
+				Message: "TX decode failed: " + err.Error(),
+			}
+		}
+		txns = append(txns, btcutil.NewTx(&msgTx))
+	}
+
+	submit := c.Submit == nil || *c.Submit
+	block, err := s.cfg.CPUMiner.GenerateBlock(addr, txns, submit)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCVerify,
+			Message: "Failed to generate block: " + err.Error(),
+		}
+	}
+
+	result := &btcjson.GenerateBlockResult{
+		Hash: block.Hash().String(),
+	}
+	if !submit {
+		blockBytes, err := block.Bytes()
+		if err != nil {
+			context := "Failed to serialize block"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		result.Hex = hex.EncodeToString(blockBytes)
+	}
+	return result, nil
+}
+
+// handleGenerateToAddress handles generatetoaddress commands.
+func handleGenerateToAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GenerateToAddressCmd)
+
+	if err := checkGenerateSupported(s, "generatetoaddress"); err != nil {
+		return nil, err
+	}
+	addr, err := btcutil.DecodeAddress(c.Address, s.cfg.ChainParams)
+	if err != nil || !addr.IsForNet(s.cfg.ChainParams) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address: " + c.Address,
+		}
+	}
+
+	return generateToAddress(s, c.NumBlocks, addr)
+}
+
+// handleGenerateToDescriptor handles generatetodescriptor commands.
+func handleGenerateToDescriptor(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GenerateToDescriptorCmd)
+
+	if err := checkGenerateSupported(s, "generatetodescriptor"); err != nil {
+		return nil, err
+	}
+	addr, err := descriptorAddress(c.Descriptor, s.cfg.ChainParams)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid descriptor: " + err.Error(),
+		}
+	}
+
+	return generateToAddress(s, c.NumBlocks, addr)
+}
+
 // handleGetAddedNodeInfo handles getaddednodeinfo commands.
 func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetAddedNodeInfoCmd)
@@ -1539,49 +1967,60 @@ func (state *gbtWorkState) templateUpdateChan(prevHash *chainhash.Hash, lastGene
 	return c
 }
 
-// updateBlockTemplate creates or updates a block template for the work state.
-// A new block template will be generated when the current best block has
-// changed or the transactions in the memory pool have been updated and it has
-// been long enough since the last template was generated.  Otherwise, the
-// timestamp for the existing block template is updated (and possibly the
-// difficulty on testnet per the consesus rules).  Finally, if the
-// useCoinbaseValue flag is false and the existing block template does not
-// already contain a valid payment address, the block template will be updated
-// with a randomly selected payment address from the list of configured
-// addresses.
+// updateBlockTemplate creates or updates the block template of the work state
+// with the passed extra coinbase outputs and returns it.  A new generation of
+// block templates will be started when the current best block has changed or
+// the transactions in the memory pool have been updated and it has been long
+// enough since the last generation was started.  A new block template is also
+// generated when there is no template with the passed extra coinbase outputs
+// in the current generation.  Otherwise, the timestamp for the existing block
+// template is updated (and possibly the difficulty on testnet per the
+// consesus rules).  Finally, if the useCoinbaseValue flag is false and the
+// existing block template does not already contain a valid payment address,
+// the block template will be updated with a randomly selected payment address
+// from the list of configured addresses.
 //
 // This function MUST be called with the state locked.
-func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bool) error {
+func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bool,
+	coinbaseOutputs []*wire.TxOut) (*mining.BlockTemplate, error) {
+
 	generator := s.cfg.Generator
 	lastTxUpdate := generator.TxSource().LastUpdated()
 	if lastTxUpdate.IsZero() {
 		lastTxUpdate = time.Now()
 	}
 
-	// Generate a new block template when the current best block has
-	// changed or the transactions in the memory pool have been updated and
-	// it has been at least gbtRegenerateSecond since the last template was
-	// generated.
-	var msgBlock *wire.MsgBlock
-	var targetDifficulty string
+	// Start a new generation of block templates when the current best
+	// block has changed or the transactions in the memory pool have been
+	// updated and it has been at least gbtRegenerateSecond since the last
+	// generation was started.
 	latestHash := &s.cfg.Chain.BestSnapshot().Hash
-	template := state.template
-	if template == nil || state.prevHash == nil ||
-		!state.prevHash.IsEqual(latestHash) ||
+	if state.prevHash == nil || !state.prevHash.IsEqual(latestHash) ||
 		(state.lastTxUpdate != lastTxUpdate &&
 			time.Now().After(state.lastGenerated.Add(time.Second*
 				gbtRegenerateSeconds))) {
 
-		// Reset the previous best hash the block template was generated
-		// against so any errors below cause the next invocation to try
-		// again.
+		// Reset the previous best hash the block templates were
+		// generated against so any errors below cause the next
+		// invocation to try again.
 		state.prevHash = nil
+		state.templates = make(map[string]*mining.BlockTemplate)
+	}
+
+	// Generate a new block template when the current generation has no
+	// template with the passed extra coinbase outputs.
+	var msgBlock *wire.MsgBlock
+	var targetDifficulty string
+	key := txOutsKey(coinbaseOutputs)
+	template := state.templates[key]
+	if template == nil {
 
 		// Choose a payment address at random if the caller requests a
 		// full coinbase as opposed to only the pertinent details needed
-		// to create their own coinbase.
+		// to create their own coinbase.  The configured coinbase
+		// payouts are used when there are no mining addresses.
 		var payAddr btcutil.Address
-		if !useCoinbaseValue {
+		if !useCoinbaseValue && len(cfg.miningAddrs) > 0 {
 			payAddr = cfg.miningAddrs[rand.Intn(len(cfg.miningAddrs))]
 		}
 
@@ -1590,38 +2029,51 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 		// block template doesn't include the coinbase, so the caller
 		// will ultimately create their own coinbase which pays to the
 		// appropriate address(es).
-		blkTemplate, err := generator.NewBlockTemplate(payAddr)
+		blkTemplate, err := s.cfg.Templates.NewBlockTemplate(payAddr,
+			coinbaseOutputs)
 		if err != nil {
-			return internalRPCError("Failed to create new block "+
-				"template: "+err.Error(), "")
+			return nil, internalRPCError("Failed to create new "+
+				"block template: "+err.Error(), "")
 		}
 		template = blkTemplate
 		msgBlock = template.Block
 		targetDifficulty = fmt.Sprintf("%064x",
 			blockchain.CompactToBig(msgBlock.Header.Bits))
 
-		// Get the minimum allowed timestamp for the block based on the
-		// median timestamp of the last several blocks per the chain
-		// consensus rules.
-		best := s.cfg.Chain.BestSnapshot()
-		minTimestamp := mining.MinimumMedianTime(best)
+		// Cache the template so it isn't generated again until needed,
+		// making room for it by dropping another one if needed.
+		if len(state.templates) >= gbtMaxCachedTemplates {
+			for k := range state.templates {
+				delete(state.templates, k)
+				break
+			}
+		}
+		state.templates[key] = template
+
+		// Update work state to ensure another generation of block
+		// templates isn't started until needed when this is the first
+		// template of the generation.
+		if state.prevHash == nil {
+			// Get the minimum allowed timestamp for the block based
+			// on the median timestamp of the last several blocks per
+			// the chain consensus rules.
+			best := s.cfg.Chain.BestSnapshot()
+			minTimestamp := mining.MinimumMedianTime(best)
 
-		// Update work state to ensure another block template isn't
-		// generated until needed.
-		state.template = template
-		state.lastGenerated = time.Now()
-		state.lastTxUpdate = lastTxUpdate
-		state.prevHash = latestHash
-		state.minTimestamp = minTimestamp
+			state.lastGenerated = time.Now()
+			state.lastTxUpdate = lastTxUpdate
+			state.prevHash = latestHash
+			state.minTimestamp = minTimestamp
+
+			// Notify any clients that are long polling about the
+			// new template.
+			state.notifyLongPollers(latestHash, lastTxUpdate)
+		}
 
 		rpcsLog.Debugf("Generated block template (timestamp %v, "+
 			"target %s, merkle root %s)",
 			msgBlock.Header.Timestamp, targetDifficulty,
 			msgBlock.Header.MerkleRoot)
-
-		// Notify any clients that are long polling about the new
-		// template.
-		state.notifyLongPollers(latestHash, lastTxUpdate)
 	} else {
 		// At this point, there is a saved block template and another
 		// request for a template was made, but either the available
@@ -1644,7 +2096,7 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 			pkScript, err := txscript.PayToAddrScript(payToAddr)
 			if err != nil {
 				context := "Failed to create pay-to-addr script"
-				return internalRPCError(err.Error(), context)
+				return nil, internalRPCError(err.Error(), context)
 			}
 			template.Block.Transactions[0].TxOut[0].PkScript = pkScript
 			template.ValidPayAddress = true
@@ -1671,20 +2123,21 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 			targetDifficulty)
 	}
 
-	return nil
+	return template, nil
 }
 
-// blockTemplateResult returns the current block template associated with the
-// state as a btcjson.GetBlockTemplateResult that is ready to be encoded to JSON
-// and returned to the caller.
+// blockTemplateResult returns the passed block template of the current
+// generation of the state as a btcjson.GetBlockTemplateResult that is ready to
+// be encoded to JSON and returned to the caller.
 //
 // This function MUST be called with the state locked.
-func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld *bool) (*btcjson.GetBlockTemplateResult, error) {
+func (state *gbtWorkState) blockTemplateResult(template *mining.BlockTemplate,
+	useCoinbaseValue bool, submitOld *bool) (*btcjson.GetBlockTemplateResult, error) {
+
 	// Ensure the timestamps are still in valid range for the template.
 	// This should really only ever happen if the local clock is changed
 	// after the template is generated, but it's important to avoid serving
 	// invalid block templates.
-	template := state.template
 	msgBlock := template.Block
 	header := &msgBlock.Header
 	adjustedTime := state.timeSource.AdjustedTime()
@@ -1783,8 +2236,14 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 	}
 
 	if useCoinbaseValue {
-		reply.CoinbaseAux = gbtCoinbaseAux
-		reply.CoinbaseValue = &msgBlock.Transactions[0].TxOut[0].Value
+		// The coinbase value may be split between several outputs when
+		// coinbase payouts are configured.
+		var coinbaseValue int64
+		for _, txOut := range msgBlock.Transactions[0].TxOut {
+			coinbaseValue += txOut.Value
+		}
+		reply.CoinbaseAux = state.coinbaseAux
+		reply.CoinbaseValue = &coinbaseValue
 	} else {
 		// Ensure the template has a valid payment address associated
 		// with it when a full coinbase is requested.
@@ -1794,7 +2253,8 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 				Message: "A coinbase transaction has been " +
 					"requested, but the server has not " +
 					"been configured with any payment " +
-					"addresses via --miningaddr",
+					"addresses via --miningaddr or " +
+					"--coinbasepayout",
 			}
 		}
 
@@ -1830,14 +2290,18 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 // has passed without finding a solution.
 //
 // See https://en.bitcoin.it/wiki/BIP_0022 for more details.
-func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbaseValue bool, closeChan <-chan struct{}) (interface{}, error) {
+func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbaseValue bool,
+	coinbaseOutputs []*wire.TxOut, closeChan <-chan struct{}) (interface{}, error) {
+
 	state := s.gbtWorkState
 	state.Lock()
 	// The state unlock is intentionally not deferred here since it needs to
 	// be manually unlocked before waiting for a notification about block
 	// template changes.
 
-	if err := state.updateBlockTemplate(s, useCoinbaseValue); err != nil {
+	template, err := state.updateBlockTemplate(s, useCoinbaseValue,
+		coinbaseOutputs)
+	if err != nil {
 		state.Unlock()
 		return nil, err
 	}
@@ -1846,7 +2310,8 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 	// the caller is invalid.
 	prevHash, lastGenerated, err := decodeTemplateID(longPollID)
 	if err != nil {
-		result, err := state.blockTemplateResult(useCoinbaseValue, nil)
+		result, err := state.blockTemplateResult(template,
+			useCoinbaseValue, nil)
 		if err != nil {
 			state.Unlock()
 			return nil, err
@@ -1859,7 +2324,7 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 	// Return the block template now if the specific block template
 	// identified by the long poll ID no longer matches the current block
 	// template as this means the provided template is stale.
-	prevTemplateHash := &state.template.Block.Header.PrevBlock
+	prevTemplateHash := &template.Block.Header.PrevBlock
 	if !prevHash.IsEqual(prevTemplateHash) ||
 		lastGenerated != state.lastGenerated.Unix() {
 
@@ -1867,8 +2332,8 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 		// old block template depending on whether or not a solution has
 		// already been found and added to the block chain.
 		submitOld := prevHash.IsEqual(prevTemplateHash)
-		result, err := state.blockTemplateResult(useCoinbaseValue,
-			&submitOld)
+		result, err := state.blockTemplateResult(template,
+			useCoinbaseValue, &submitOld)
 		if err != nil {
 			state.Unlock()
 			return nil, err
@@ -1900,15 +2365,18 @@ func handleGetBlockTemplateLongPoll(s *rpcServer, longPollID string, useCoinbase
 	state.Lock()
 	defer state.Unlock()
 
-	if err := state.updateBlockTemplate(s, useCoinbaseValue); err != nil {
+	template, err = state.updateBlockTemplate(s, useCoinbaseValue,
+		coinbaseOutputs)
+	if err != nil {
 		return nil, err
 	}
 
 	// Include whether or not it is valid to submit work against the old
 	// block template depending on whether or not a solution has already
 	// been found and added to the block chain.
-	submitOld := prevHash.IsEqual(&state.template.Block.Header.PrevBlock)
-	result, err := state.blockTemplateResult(useCoinbaseValue, &submitOld)
+	submitOld := prevHash.IsEqual(&template.Block.Header.PrevBlock)
+	result, err := state.blockTemplateResult(template, useCoinbaseValue,
+		&submitOld)
 	if err != nil {
 		return nil, err
 	}
@@ -1946,20 +2414,58 @@ func handleGetBlockTemplateRequest(s *rpcServer, request *btcjson.TemplateReques
 
 	// When a coinbase transaction has been requested, respond with an error
 	// if there are no addresses to pay the created block template to.
-	if !useCoinbaseValue && len(cfg.miningAddrs) == 0 {
+	if !useCoinbaseValue && len(cfg.miningAddrs) == 0 &&
+		len(cfg.coinbasePayouts) == 0 {
+
 		return nil, &btcjson.RPCError{
 			Code: btcjson.ErrRPCInternal.Code,
 			Message: "A coinbase transaction has been requested, " +
 				"but the server has not been configured with " +
-				"any payment addresses via --miningaddr",
+				"any payment addresses via --miningaddr or " +
+				"--coinbasepayout",
+		}
+	}
+
+	// Decode any extra coinbase outputs requested by the caller.  They
+	// are only meaningful when the coinbase is provided by the server.
+	var coinbaseOutputs []*wire.TxOut
+	if request != nil && len(request.CoinbaseOutputs) > 0 {
+		if useCoinbaseValue {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: "Coinbase outputs require the " +
+					"coinbasetxn capability",
+			}
+		}
+		coinbaseOutputs = make([]*wire.TxOut, 0,
+			len(request.CoinbaseOutputs))
+		for _, output := range request.CoinbaseOutputs {
+			pkScript, err := hex.DecodeString(output.Script)
+			if err != nil {
+				return nil, rpcDecodeHexError(output.Script)
+			}
+			if len(pkScript) > txscript.MaxScriptSize ||
+				output.Value < 0 ||
+				output.Value > btcutil.MaxSatoshi {
+
+				return nil, &btcjson.RPCError{
+					Code: btcjson.ErrRPCInvalidParameter,
+					Message: fmt.Sprintf("Invalid coinbase "+
+						"output %s with value %d",
+						output.Script, output.Value),
+				}
+			}
+			coinbaseOutputs = append(coinbaseOutputs,
+				wire.NewTxOut(output.Value, pkScript))
 		}
 	}
 
 	// Return an error if there are no peers connected since there is no
 	// way to relay a found block or receive transactions to work on.
 	// However, allow this state when running in the regression test or
-	// simulation test mode.
-	if !(cfg.RegressionTest || cfg.SimNet) &&
+	// simulation test mode, or on signet where the blocks of a private
+	// signet may be produced by a single node.
+	if !(cfg.RegressionTest || cfg.SimNet || cfg.SigNet) &&
 		s.cfg.ConnMgr.ConnectedCount() == 0 {
 
 		return nil, &btcjson.RPCError{
@@ -1982,7 +2488,7 @@ func handleGetBlockTemplateRequest(s *rpcServer, request *btcjson.TemplateReques
 	// be replaced with a new one.
 	if request != nil && request.LongPollID != "" {
 		return handleGetBlockTemplateLongPoll(s, request.LongPollID,
-			useCoinbaseValue, closeChan)
+			useCoinbaseValue, coinbaseOutputs, closeChan)
 	}
 
 	// Protect concurrent access when updating block templates.
@@ -1996,10 +2502,12 @@ func handleGetBlockTemplateRequest(s *rpcServer, request *btcjson.TemplateReques
 	// seconds since the last template was generated.  Otherwise, the
 	// timestamp for the existing block template is updated (and possibly
 	// the difficulty on testnet per the consesus rules).
-	if err := state.updateBlockTemplate(s, useCoinbaseValue); err != nil {
+	template, err := state.updateBlockTemplate(s, useCoinbaseValue,
+		coinbaseOutputs)
+	if err != nil {
 		return nil, err
 	}
-	return state.blockTemplateResult(useCoinbaseValue, nil)
+	return state.blockTemplateResult(template, useCoinbaseValue, nil)
 }
 
 // chainErrToGBTErrString converts an error returned from btcchain to a string
@@ -2338,6 +2846,23 @@ func handleGetInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (in
 	return ret, nil
 }
 
+// handleGetMempoolEntry implements the getmempoolentry command.
+func handleGetMempoolEntry(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetMempoolEntryCmd)
+
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+
+	entry, err := s.cfg.TxMemPool.MempoolEntry(txHash)
+	if err != nil {
+		return nil, rpcNoTxInfoError(txHash)
+	}
+
+	return entry, nil
+}
+
 // handleGetMempoolInfo implements the getmempoolinfo command.
 func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	mempoolTxns := s.cfg.TxMemPool.TxDescs()
@@ -2347,9 +2872,22 @@ func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct
 		numBytes += int64(txD.Tx.MsgTx().SerializeSize())
 	}
 
+	// Report the replacement policy the mempool was configured with.
+	replacementPolicy := "optin"
+	switch {
+	case cfg.RejectReplacement:
+		replacementPolicy = "disabled"
+	case cfg.MempoolFullRBF:
+		replacementPolicy = "full"
+	}
+
 	ret := &btcjson.GetMempoolInfoResult{
-		Size:  int64(len(mempoolTxns)),
-		Bytes: numBytes,
+		Size:              int64(len(mempoolTxns)),
+		Bytes:             numBytes,
+		FullRBF:           cfg.MempoolFullRBF,
+		ReplacementPolicy: replacementPolicy,
+		Expired:           s.cfg.TxMemPool.NumExpired(),
+		MempoolSequence:   s.cfg.TxMemPool.Sequence(),
 	}
 
 	return ret, nil
@@ -2398,6 +2936,7 @@ func handleGetNetTotals(s *rpcServer, cmd interface{}, closeChan <-chan struct{}
 		TotalBytesRecv: totalBytesRecv,
 		TotalBytesSent: totalBytesSent,
 		TimeMillis:     time.Now().UTC().UnixNano() / int64(time.Millisecond),
+		UploadTarget:   *s.cfg.ConnMgr.UploadTarget(),
 	}
 	return reply, nil
 }
@@ -2538,26 +3077,38 @@ func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	for _, p := range peers {
 		statsSnap := p.ToPeer().StatsSnapshot()
 		info := &btcjson.GetPeerInfoResult{
-			ID:             statsSnap.ID,
-			Addr:           statsSnap.Addr,
-			AddrLocal:      p.ToPeer().LocalAddr().String(),
-			Services:       fmt.Sprintf("%08d", uint64(statsSnap.Services)),
-			RelayTxes:      !p.IsTxRelayDisabled(),
-			LastSend:       statsSnap.LastSend.Unix(),
-			LastRecv:       statsSnap.LastRecv.Unix(),
-			BytesSent:      statsSnap.BytesSent,
-			BytesRecv:      statsSnap.BytesRecv,
-			ConnTime:       statsSnap.ConnTime.Unix(),
-			PingTime:       float64(statsSnap.LastPingMicros),
-			TimeOffset:     statsSnap.TimeOffset,
-			Version:        statsSnap.Version,
-			SubVer:         statsSnap.UserAgent,
-			Inbound:        statsSnap.Inbound,
-			StartingHeight: statsSnap.StartingHeight,
-			CurrentHeight:  statsSnap.LastBlock,
-			BanScore:       int32(p.BanScore()),
-			FeeFilter:      p.FeeFilter(),
-			SyncNode:       statsSnap.ID == syncPeerID,
+			ID:              statsSnap.ID,
+			Addr:            statsSnap.Addr,
+			AddrLocal:       p.ToPeer().LocalAddr().String(),
+			Services:        fmt.Sprintf("%08d", uint64(statsSnap.Services)),
+			RelayTxes:       !p.IsTxRelayDisabled(),
+			LastSend:        statsSnap.LastSend.Unix(),
+			LastRecv:        statsSnap.LastRecv.Unix(),
+			BytesSent:       statsSnap.BytesSent,
+			BytesRecv:       statsSnap.BytesRecv,
+			ConnTime:        statsSnap.ConnTime.Unix(),
+			PingTime:        float64(statsSnap.LastPingMicros),
+			TimeOffset:      statsSnap.TimeOffset,
+			Version:         statsSnap.Version,
+			SubVer:          statsSnap.UserAgent,
+			Inbound:         statsSnap.Inbound,
+			StartingHeight:  statsSnap.StartingHeight,
+			CurrentHeight:   statsSnap.LastBlock,
+			BanScore:        int32(p.BanScore()),
+			FeeFilter:       p.FeeFilter(),
+			SyncNode:        statsSnap.ID == syncPeerID,
+			BIP152HBTo:      p.ToPeer().IsCmpctBlocksHighBandwidth(),
+			BIP152HBFrom:    p.ToPeer().WantsCmpctBlocks(),
+			BytesSentPerMsg: statsSnap.BytesSentPerMsg,
+			BytesRecvPerMsg: statsSnap.BytesRecvPerMsg,
+			ConnectionType:  p.ConnectionType(),
+			Permissions:     p.Permissions(),
+		}
+		info.TransportProtocolType = "v1"
+		if p.ToPeer().V2Transport() {
+			sessionID := p.ToPeer().SessionID()
+			info.TransportProtocolType = "v2"
+			info.SessionID = hex.EncodeToString(sessionID[:])
 		}
 		if p.ToPeer().LastPingNonce() != 0 {
 			wait := float64(time.Since(statsSnap.LastPingTime).Nanoseconds())
@@ -2708,6 +3259,56 @@ func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan str
 	return *rawTxn, nil
 }
 
+// handleGetStratumInfo implements the getstratuminfo command.
+func handleGetStratumInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if s.cfg.Stratum == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "The stratum server is disabled",
+		}
+	}
+
+	addrs := s.cfg.Stratum.Addrs()
+	listeners := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		listeners = append(listeners, addr.String())
+	}
+
+	var hashesPerSec float64
+	stats := s.cfg.Stratum.WorkerStats()
+	workers := make([]btcjson.StratumWorkerResult, 0, len(stats))
+	for _, stat := range stats {
+		var firstShare, lastShare int64
+		if !stat.FirstShare.IsZero() {
+			firstShare = stat.FirstShare.Unix()
+			lastShare = stat.LastShare.Unix()
+		}
+		workers = append(workers, btcjson.StratumWorkerResult{
+			Name:           stat.Name,
+			Connections:    int64(stat.Connections),
+			SharesAccepted: stat.SharesAccepted,
+			SharesRejected: stat.SharesRejected,
+			SharesStale:    stat.SharesStale,
+			BlocksFound:    stat.BlocksFound,
+			Work:           stat.Work,
+			HashesPerSec:   stat.HashesPerSec,
+			FirstShare:     firstShare,
+			LastShare:      lastShare,
+		})
+		hashesPerSec += stat.HashesPerSec
+	}
+
+	jobID, height := s.cfg.Stratum.CurrentJob()
+	return &btcjson.GetStratumInfoResult{
+		Listeners:    listeners,
+		Clients:      int64(s.cfg.Stratum.NumClients()),
+		JobID:        jobID,
+		Height:       int64(height),
+		HashesPerSec: hashesPerSec,
+		Workers:      workers,
+	}, nil
+}
+
 // handleGetTxOut handles gettxout commands.
 func handleGetTxOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetTxOutCmd)
@@ -2853,6 +3454,29 @@ func handleHelp(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (inter
 	return help, nil
 }
 
+// handleListBanned implements the listbanned command.
+func handleListBanned(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	bans, err := s.cfg.ConnMgr.BannedSubnets()
+	if err != nil {
+		return nil, internalRPCError(err.Error(),
+			"Failed to save the ban list")
+	}
+
+	now := time.Now().Unix()
+	results := make([]btcjson.ListBannedResult, 0, len(bans))
+	for _, ban := range bans {
+		results = append(results, btcjson.ListBannedResult{
+			Address:       ban.Subnet,
+			BanCreated:    ban.Created,
+			BannedUntil:   ban.Until,
+			BanDuration:   ban.Until - ban.Created,
+			TimeRemaining: ban.Until - now,
+			BanReason:     ban.Reason,
+		})
+	}
+	return results, nil
+}
+
 // handlePing implements the ping command.
 func handlePing(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Ask server to ping \o_
@@ -3087,6 +3711,24 @@ func fetchMempoolTxnsForAddress(s *rpcServer, addr btcutil.Address, numToSkip, n
 	return mpTxns[numToSkip:rangeEnd], numToSkip
 }
 
+// handlePrioritiseTransaction implements the prioritisetransaction command.
+func handlePrioritiseTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.PrioritiseTransactionCmd)
+
+	txHash, err := chainhash.NewHashFromStr(c.Txid)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Txid)
+	}
+
+	s.cfg.TxMemPool.PrioritiseTransaction(txHash, c.FeeDelta)
+
+	// Notify getblocktemplate long poll clients since the prioritization
+	// may change which transactions are selected for the next block.
+	s.gbtWorkState.NotifyMempoolTx(s.cfg.TxMemPool.LastUpdated())
+
+	return true, nil
+}
+
 // handleSearchRawTransactions implements the searchrawtransactions command.
 func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Respond with an error if the address index is not enabled.
@@ -3428,7 +4070,8 @@ func handleSendRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan st
 	// Also, since an error is being returned to the caller, ensure the
 	// transaction is removed from the memory pool.
 	if len(acceptedTxs) == 0 || !acceptedTxs[0].Tx.Hash().IsEqual(tx.Hash()) {
-		s.cfg.TxMemPool.RemoveTransaction(tx, true)
+		s.cfg.TxMemPool.RemoveTransaction(tx, true,
+			mempool.RemovalReasonUnknown)
 
 		errStr := fmt.Sprintf("transaction %v is not in accepted list",
 			tx.Hash())
@@ -3453,6 +4096,72 @@ func handleSendRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan st
 	return tx.Hash().String(), nil
 }
 
+// handleSetBan implements the setban command.
+func handleSetBan(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetBanCmd)
+
+	ipnet, err := parseSubnet(c.SubNet)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCClientInvalidIPOrSubnet,
+			Message: "Error: Invalid IP/Subnet",
+		}
+	}
+
+	switch c.SubCmd {
+	case btcjson.SBAdd:
+		if s.cfg.ConnMgr.IsSubnetBanned(ipnet) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCClientNodeAlreadyAdded,
+				Message: "Error: IP/Subnet already banned",
+			}
+		}
+
+		// The ban lasts for the configured ban duration unless a ban
+		// time is given, which is either a duration in seconds or the
+		// time the ban expires when it is absolute.
+		var until time.Time
+		if c.BanTime != nil && *c.BanTime > 0 {
+			if c.Absolute != nil && *c.Absolute {
+				until = time.Unix(*c.BanTime, 0)
+				if !until.After(time.Now()) {
+					return nil, &btcjson.RPCError{
+						Code:    btcjson.ErrRPCInvalidParameter,
+						Message: "Error: Absolute timestamp is in the past",
+					}
+				}
+			} else {
+				until = time.Now().Add(time.Duration(*c.BanTime) *
+					time.Second)
+			}
+		}
+		err = s.cfg.ConnMgr.BanSubnet(ipnet, until)
+
+	case btcjson.SBRemove:
+		var unbanned bool
+		unbanned, err = s.cfg.ConnMgr.UnbanSubnet(ipnet)
+		if err == nil && !unbanned {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCClientInvalidIPOrSubnet,
+				Message: "Error: Unban failed. Requested address/subnet " +
+					"was not previously manually banned.",
+			}
+		}
+
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "invalid subcommand for setban",
+		}
+	}
+	if err != nil {
+		return nil, internalRPCError(err.Error(),
+			"Failed to save the ban list")
+	}
+
+	return nil, nil
+}
+
 // handleSetGenerate implements the setgenerate command.
 func handleSetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.SetGenerateCmd)
@@ -3474,11 +4183,11 @@ func handleSetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	} else {
 		// Respond with an error if there are no addresses to pay the
 		// created blocks to.
-		if len(cfg.miningAddrs) == 0 {
+		if len(cfg.miningAddrs) == 0 && len(cfg.coinbasePayouts) == 0 {
 			return nil, &btcjson.RPCError{
 				Code: btcjson.ErrRPCInternal.Code,
 				Message: "No payment addresses specified " +
-					"via --miningaddr",
+					"via --miningaddr or --coinbasepayout",
 			}
 		}
 
@@ -3867,7 +4576,7 @@ func (s *rpcServer) RequestedProcessShutdown() <-chan struct{} {
 func (s *rpcServer) NotifyNewTransactions(txns []*mempool.TxDesc) {
 	for _, txD := range txns {
 		// Notify websocket clients about mempool transactions.
-		s.ntfnMgr.NotifyMempoolTx(txD.Tx, true)
+		s.ntfnMgr.NotifyMempoolTx(txD.Tx, true, txD.Sequence)
 
 		// Potentially notify any getblocktemplate long poll clients
 		// about stale block templates due to the new transaction.
@@ -3875,6 +4584,23 @@ func (s *rpcServer) NotifyNewTransactions(txns []*mempool.TxDesc) {
 	}
 }
 
+// NotifyTxRemoved notifies websocket clients that have registered for mempool
+// sequence updates about the passed removal of a transaction from the memory
+// pool.
+//
+// This function is safe for concurrent access.
+func (s *rpcServer) NotifyTxRemoved(removal *mempool.TxRemoval) {
+	s.ntfnMgr.NotifyMempoolTxRemoved(removal)
+}
+
+// NotifyBlockTemplate notifies websocket clients that have registered for
+// block template updates about the passed new block template.
+//
+// This function is safe for concurrent access.
+func (s *rpcServer) NotifyBlockTemplate(template *mining.BlockTemplate) {
+	s.ntfnMgr.NotifyBlockTemplate(template)
+}
+
 // limitConnections responds with a 503 service unavailable and returns true if
 // adding another client would exceed the maximum allow RPC clients.
 //
@@ -4463,6 +5189,14 @@ type rpcserverPeer interface {
 	// FeeFilter returns the requested current minimum fee rate for which
 	// transactions should be announced.
 	FeeFilter() int64
+
+	// ConnectionType returns the type of the connection to the peer, such
+	// as inbound or block-relay-only.
+	ConnectionType() string
+
+	// Permissions returns the names of the permissions granted to the
+	// peer.
+	Permissions() []string
 }
 
 // rpcserverConnManager represents a connection manager for use with the RPC
@@ -4505,6 +5239,10 @@ type rpcserverConnManager interface {
 	// network for all peers.
 	NetTotals() (uint64, uint64)
 
+	// UploadTarget returns the state of the upload target in the current
+	// cycle.
+	UploadTarget() *btcjson.UploadTargetResult
+
 	// ConnectedPeers returns an array consisting of all connected peers.
 	ConnectedPeers() []rpcserverPeer
 
@@ -4528,6 +5266,24 @@ type rpcserverConnManager interface {
 	// NodeAddresses returns an array consisting node addresses which can
 	// potentially be used to find new nodes in the network.
 	NodeAddresses() []*wire.NetAddressV2
+
+	// BanSubnet bans the provided subnet until the provided time, or for
+	// the configured ban duration when it is zero, and disconnects the
+	// connected peers in the subnet.
+	BanSubnet(subnet *net.IPNet, until time.Time) error
+
+	// UnbanSubnet removes the ban of the provided subnet.  It returns
+	// false when the subnet is not banned.
+	UnbanSubnet(subnet *net.IPNet) (bool, error)
+
+	// IsSubnetBanned returns whether the provided subnet itself is banned.
+	IsSubnetBanned(subnet *net.IPNet) bool
+
+	// BannedSubnets returns the banned IPs and subnets.
+	BannedSubnets() ([]*banEntry, error)
+
+	// ClearBanned removes all bans.
+	ClearBanned() error
 }
 
 // rpcserverSyncManager represents a sync manager for use with the RPC server.
@@ -4596,6 +5352,17 @@ type rpcserverConfig struct {
 	Generator *mining.BlkTmplGenerator
 	CPUMiner  *cpuminer.CPUMiner
 
+	// Templates maintains the transactions that are candidates for
+	// inclusion in block templates as the mempool changes, which allows
+	// block templates to be generated for getblocktemplate requests
+	// without looking up every transaction in the mempool each time.
+	Templates *mining.TemplateManager
+
+	// Stratum is the built-in stratum mining server which hands out jobs
+	// built from the same generator to external mining software.  It is
+	// nil when the stratum server is disabled.
+	Stratum *stratum.Server
+
 	// These fields define any optional indexes the RPC server can make use
 	// of to provide additional data when queried.
 	TxIndex   *indexers.TxIndex
@@ -4612,7 +5379,7 @@ func newRPCServer(config *rpcserverConfig) (*rpcServer, error) {
 	rpc := rpcServer{
 		cfg:                    *config,
 		statusLines:            make(map[int]string),
-		gbtWorkState:           newGbtWorkState(config.TimeSource),
+		gbtWorkState:           newGbtWorkState(config.TimeSource, config.Generator.Policy().CoinbaseTag),
 		helpCacher:             newHelpCacher(),
 		requestProcessShutdown: make(chan struct{}),
 		quit:                   make(chan int),
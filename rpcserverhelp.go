@@ -40,6 +40,9 @@ var helpDescsEnUS = map[string]string{
 	"node-target":        "Either the IP address and port of the peer to operate on, or a valid peer ID.",
 	"node-connectsubcmd": "'perm' to make the connected peer a permanent one, 'temp' to try a single connect to a peer",
 
+	// ClearBannedCmd help.
+	"clearbanned--synopsis": "Removes all bans of IPs and subnets.",
+
 	// TransactionInput help.
 	"transactioninput-txid": "The hash of the input transaction",
 	"transactioninput-vout": "The specific output of the input transaction to redeem",
@@ -115,6 +118,12 @@ var helpDescsEnUS = map[string]string{
 	"decodescript--synopsis": "Returns a JSON object with information about the provided hex-encoded script.",
 	"decodescript-hexscript": "Hex-encoded script",
 
+	// DisconnectNodeCmd help.
+	"disconnectnode--synopsis": "Disconnects the peer with the given address or node ID.\n" +
+		"Only one of the address and the node ID may be provided.",
+	"disconnectnode-address": "The IP address and port of the peer to disconnect",
+	"disconnectnode-nodeid":  "The ID of the peer to disconnect as shown by getpeerinfo",
+
 	// EstimateFeeCmd help.
 	"estimatefee--synopsis": "Estimate the fee per kilobyte in satoshis " +
 		"required for a transaction to be mined before a certain number of " +
@@ -124,12 +133,91 @@ var helpDescsEnUS = map[string]string{
 	"estimatefee--result0": "Estimated fee per kilobyte in satoshis for a block to " +
 		"be mined in the next NumBlocks blocks.",
 
+	// EstimateMempoolFeeCmd help.
+	"estimatemempoolfee--synopsis": "Projects the next blocks from the transactions currently in the memory pool and returns the fee rates they contain.\n" +
+		"Transactions are selected by the fee rate of their unconfirmed ancestor packages just like block templates, so the result reacts to fee spikes as soon as transactions are accepted rather than once they are confirmed.",
+	"estimatemempoolfee-numblocks": "The maximum number of blocks to project (1 - 100)",
+	"estimatemempoolfee--result0":  "The projected blocks, starting with the next block to be mined",
+
+	// ProjectedBlockResult help.
+	"projectedblockresult-ntx":                "The number of transactions in the block, excluding the coinbase",
+	"projectedblockresult-weight":             "The total weight of the transactions in the block",
+	"projectedblockresult-totalfees":          "The total fees paid by the transactions in the block in BTC",
+	"projectedblockresult-minfeerate":         "The lowest effective fee rate in the block in satoshis per virtual byte",
+	"projectedblockresult-maxfeerate":         "The highest effective fee rate in the block in satoshis per virtual byte",
+	"projectedblockresult-feeratepercentiles": "The effective fee rates at the 10th, 25th, 50th, 75th and 90th percentiles weighted by virtual size in satoshis per virtual byte",
+
+	// EstimateSmartFeeCmd help.
+	"estimatesmartfee--synopsis": "Estimate the fee rate in BTC/kvB required for a transaction to begin confirmation within a certain number of blocks.\n" +
+		"The estimate is derived from decaying statistics of how quickly transactions in exponentially spaced fee rate buckets have confirmed over short, medium and long horizons.",
+	"estimatesmartfee-conftarget":   "The desired number of blocks within which the transaction should begin confirmation (1 - 1008)",
+	"estimatesmartfee-estimatemode": "The fee estimate mode, either ECONOMICAL, which reacts quicker to decreasing fee rates, or CONSERVATIVE, which takes a longer history into account",
+
+	// EstimateSmartFeeResult help.
+	"estimatesmartfeeresult-feerate": "The estimated fee rate in BTC/kvB, which is never lower than the minimum relay fee (omitted if there is no estimate)",
+	"estimatesmartfeeresult-errors":  "Errors encountered during processing (omitted if there are none)",
+	"estimatesmartfeeresult-blocks":  "The number of blocks the estimate is valid for, which may be lower than requested when there isn't enough data",
+
+	// EstimateRawFeeCmd help.
+	"estimaterawfee--synopsis": "Returns the raw fee estimates of each horizon that tracks the requested confirmation target along with the statistics they were derived from.\n" +
+		"This is intended for debugging and its interface may change.",
+	"estimaterawfee-conftarget": "The desired number of blocks within which the transaction should begin confirmation (1 - 1008)",
+	"estimaterawfee-threshold":  "The proportion of transactions in a fee rate range that must have confirmed within the target for the range to be considered",
+
+	// EstimateRawFeeResult help.
+	"estimaterawfeeresult-short":  "The estimate for the short horizon (omitted if the target is not tracked)",
+	"estimaterawfeeresult-medium": "The estimate for the medium horizon (omitted if the target is not tracked)",
+	"estimaterawfeeresult-long":   "The estimate for the long horizon (omitted if the target is not tracked)",
+
+	// EstimateRawFeeHorizonResult help.
+	"estimaterawfeehorizonresult-feerate": "The estimated fee rate in BTC/kvB (omitted if there is no estimate)",
+	"estimaterawfeehorizonresult-decay":   "The exponential decay applied to the statistics every block",
+	"estimaterawfeehorizonresult-scale":   "The number of blocks per confirmation period",
+	"estimaterawfeehorizonresult-pass":    "The lowest range of fee rate buckets that met the threshold (omitted if there is no estimate)",
+	"estimaterawfeehorizonresult-fail":    "The highest range of fee rate buckets that did not meet the threshold (omitted if there is none)",
+	"estimaterawfeehorizonresult-errors":  "Errors encountered during processing (omitted if there are none)",
+
+	// FeeRateBucket help.
+	"feeratebucket-startrange":     "The lower bound of the range in satoshis per kvB",
+	"feeratebucket-endrange":       "The upper bound of the range in satoshis per kvB",
+	"feeratebucket-withintarget":   "The decayed number of transactions in the range that confirmed within the target",
+	"feeratebucket-totalconfirmed": "The decayed number of transactions in the range that confirmed at any point",
+	"feeratebucket-inmempool":      "The number of transactions in the range still in the mempool after more blocks than the target",
+	"feeratebucket-leftmempool":    "The decayed number of transactions in the range that left the mempool without confirming after more blocks than the target",
+
 	// GenerateCmd help
 	"generate--synopsis": "Generates a set number of blocks (simnet or regtest only) and returns a JSON\n" +
 		" array of their hashes.",
 	"generate-numblocks": "Number of blocks to generate",
 	"generate--result0":  "The hashes, in order, of blocks generated by the call",
 
+	// GenerateToAddressCmd help
+	"generatetoaddress--synopsis": "Generates a set number of blocks paying to an address (simnet or regtest only) and returns a JSON\n" +
+		" array of their hashes.",
+	"generatetoaddress-numblocks": "Number of blocks to generate",
+	"generatetoaddress-address":   "The address to pay the generated blocks to",
+	"generatetoaddress-maxtries":  "Accepted for compatibility, the CPU miner always tries until each block is found",
+	"generatetoaddress--result0":  "The hashes, in order, of blocks generated by the call",
+
+	// GenerateToDescriptorCmd help
+	"generatetodescriptor--synopsis": "Generates a set number of blocks paying to an output descriptor (simnet or regtest only) and returns a JSON\n" +
+		" array of their hashes.  Supported descriptors are addr(ADDR), pk(KEY), pkh(KEY), wpkh(KEY), sh(wpkh(KEY)) and tr(KEY) with hex-encoded public keys.",
+	"generatetodescriptor-numblocks":  "Number of blocks to generate",
+	"generatetodescriptor-descriptor": "The descriptor to pay the generated blocks to, optionally followed by its checksum",
+	"generatetodescriptor-maxtries":   "Accepted for compatibility, the CPU miner always tries until each block is found",
+	"generatetodescriptor--result0":   "The hashes, in order, of blocks generated by the call",
+
+	// GenerateBlockResult help.
+	"generateblockresult-hash": "The hash of the generated block",
+	"generateblockresult-hex":  "The hex-encoded generated block (only when submit is false)",
+
+	// GenerateBlockCmd help
+	"generateblock--synopsis": "Generates a block containing exactly the specified transactions in the specified order (simnet or regtest only).\n" +
+		"Transactions given as raw transactions do not need to be in the mempool.",
+	"generateblock-output":       "The address or output descriptor to pay the generated block to",
+	"generateblock-transactions": "The hashes of transactions in the mempool or hex-encoded raw transactions to include in the block",
+	"generateblock-submit":       "Whether or not to submit the block, otherwise it is returned as hex",
+
 	// GetAddedNodeInfoResultAddr help.
 	"getaddednodeinforesultaddr-address":   "The ip address for this DNS entry",
 	"getaddednodeinforesultaddr-connected": "The connection 'direction' (inbound/outbound/false)",
@@ -288,16 +376,21 @@ var helpDescsEnUS = map[string]string{
 	"getblockheaderverboseresult-nextblockhash":     "The hash of the next block (only if there is one)",
 
 	// TemplateRequest help.
-	"templaterequest-mode":         "This is 'template', 'proposal', or omitted",
-	"templaterequest-capabilities": "List of capabilities",
-	"templaterequest-longpollid":   "The long poll ID of a job to monitor for expiration; required and valid only for long poll requests ",
-	"templaterequest-sigoplimit":   "Number of signature operations allowed in blocks (this parameter is ignored)",
-	"templaterequest-sizelimit":    "Number of bytes allowed in blocks (this parameter is ignored)",
-	"templaterequest-maxversion":   "Highest supported block version number (this parameter is ignored)",
-	"templaterequest-target":       "The desired target for the block template (this parameter is ignored)",
-	"templaterequest-data":         "Hex-encoded block data (only for mode=proposal)",
-	"templaterequest-workid":       "The server provided workid if provided in block template (not applicable)",
-	"templaterequest-rules":        "Specific block rules that are to be enforced e.g. '[\"segwit\"]",
+	"templaterequest-mode":            "This is 'template', 'proposal', or omitted",
+	"templaterequest-capabilities":    "List of capabilities",
+	"templaterequest-longpollid":      "The long poll ID of a job to monitor for expiration; required and valid only for long poll requests ",
+	"templaterequest-sigoplimit":      "Number of signature operations allowed in blocks (this parameter is ignored)",
+	"templaterequest-sizelimit":       "Number of bytes allowed in blocks (this parameter is ignored)",
+	"templaterequest-maxversion":      "Highest supported block version number (this parameter is ignored)",
+	"templaterequest-target":          "The desired target for the block template (this parameter is ignored)",
+	"templaterequest-data":            "Hex-encoded block data (only for mode=proposal)",
+	"templaterequest-workid":          "The server provided workid if provided in block template (not applicable)",
+	"templaterequest-rules":           "Specific block rules that are to be enforced e.g. '[\"segwit\"]",
+	"templaterequest-coinbaseoutputs": "Extra outputs to add to the coinbase transaction after the payment outputs, whose value is deducted from the payment (requires the coinbasetxn capability)",
+
+	// TemplateRequestCoinbaseOutput help.
+	"templaterequestcoinbaseoutput-script": "Hex-encoded public key script of the output",
+	"templaterequestcoinbaseoutput-value":  "Value of the output in satoshi",
 
 	// GetBlockTemplateResultTx help.
 	"getblocktemplateresulttx-data":    "Hex-encoded transaction data (byte-for-byte)",
@@ -418,12 +511,44 @@ var helpDescsEnUS = map[string]string{
 	// GetInfoCmd help.
 	"getinfo--synopsis": "Returns a JSON object containing various state info.",
 
+	// GetMempoolEntryCmd help.
+	"getmempoolentry--synopsis": "Returns mempool data for the given transaction.",
+	"getmempoolentry-txid":      "The hash of the transaction",
+
+	// GetMempoolEntryResult help.
+	"getmempoolentryresult-vsize":           "Virtual transaction size as defined in BIP 141",
+	"getmempoolentryresult-size":            "Transaction size in bytes",
+	"getmempoolentryresult-weight":          "Transaction weight as defined in BIP 141",
+	"getmempoolentryresult-fee":             "Transaction fee in bitcoins",
+	"getmempoolentryresult-modifiedfee":     "Transaction fee with fee deltas used for mining priority in bitcoins",
+	"getmempoolentryresult-time":            "Local time transaction entered pool in seconds since 1 Jan 1970 GMT",
+	"getmempoolentryresult-height":          "Block height when transaction entered the pool",
+	"getmempoolentryresult-descendantcount": "Number of in-mempool descendant transactions (including this one)",
+	"getmempoolentryresult-descendantsize":  "Virtual transaction size of in-mempool descendants (including this one)",
+	"getmempoolentryresult-descendantfees":  "Modified fees of in-mempool descendants (including this one) in bitcoins",
+	"getmempoolentryresult-ancestorcount":   "Number of in-mempool ancestor transactions (including this one)",
+	"getmempoolentryresult-ancestorsize":    "Virtual transaction size of in-mempool ancestors (including this one)",
+	"getmempoolentryresult-ancestorfees":    "Modified fees of in-mempool ancestors (including this one) in bitcoins",
+	"getmempoolentryresult-wtxid":           "Hash of the serialized transaction, including witness data",
+	"getmempoolentryresult-fees":            "Fee information in bitcoins",
+	"getmempoolentryresult-depends":         "Unconfirmed transactions used as inputs for this transaction",
+
+	// MempoolFees help.
+	"mempoolfees-base":       "Transaction fee in bitcoins",
+	"mempoolfees-modified":   "Transaction fee with fee deltas used for mining priority in bitcoins",
+	"mempoolfees-ancestor":   "Modified fees of in-mempool ancestors (including this one) in bitcoins",
+	"mempoolfees-descendant": "Modified fees of in-mempool descendants (including this one) in bitcoins",
+
 	// GetMempoolInfoCmd help.
 	"getmempoolinfo--synopsis": "Returns memory pool information",
 
 	// GetMempoolInfoResult help.
-	"getmempoolinforesult-bytes": "Size in bytes of the mempool",
-	"getmempoolinforesult-size":  "Number of transactions in the mempool",
+	"getmempoolinforesult-bytes":             "Size in bytes of the mempool",
+	"getmempoolinforesult-size":              "Number of transactions in the mempool",
+	"getmempoolinforesult-fullrbf":           "Whether the mempool accepts replacements of transactions that do not signal replaceability",
+	"getmempoolinforesult-replacementpolicy": "The Replace-By-Fee (RBF) policy of the mempool (disabled, optin or full)",
+	"getmempoolinforesult-expired":           "The number of transactions expired from the mempool since startup",
+	"getmempoolinforesult-mempoolsequence":   "The current mempool sequence number, which is incremented whenever a transaction is added to or removed from the mempool",
 
 	// GetMiningInfoResult help.
 	"getmininginforesult-blocks":             "Height of the latest best block",
@@ -455,6 +580,15 @@ var helpDescsEnUS = map[string]string{
 	"getnettotalsresult-totalbytesrecv": "Total bytes received",
 	"getnettotalsresult-totalbytessent": "Total bytes sent",
 	"getnettotalsresult-timemillis":     "Number of milliseconds since 1 Jan 1970 GMT",
+	"getnettotalsresult-uploadtarget":   "The state of the upload target",
+
+	// UploadTargetResult help.
+	"uploadtargetresult-timeframe":               "Length of the upload target cycle in seconds",
+	"uploadtargetresult-target":                  "Upload target in bytes per cycle (0 when disabled)",
+	"uploadtargetresult-target_reached":          "Whether the upload target is reached",
+	"uploadtargetresult-serve_historical_blocks": "Whether blocks older than a week are served to peers without the download permission",
+	"uploadtargetresult-bytes_left_in_cycle":     "Bytes left to send in the current cycle before the upload target is reached",
+	"uploadtargetresult-time_left_in_cycle":      "Seconds left in the current cycle",
 
 	// GetNodeAddressesResult help.
 	"getnodeaddressesresult-time":     "Timestamp in seconds since epoch (Jan 1 1970 GMT) keeping track of when the node was last seen",
@@ -468,31 +602,53 @@ var helpDescsEnUS = map[string]string{
 	"getnodeaddresses--result0":  "List of node addresses",
 
 	// GetPeerInfoResult help.
-	"getpeerinforesult-id":             "A unique node ID",
-	"getpeerinforesult-addr":           "The ip address and port of the peer",
-	"getpeerinforesult-addrlocal":      "Local address",
-	"getpeerinforesult-services":       "Services bitmask which represents the services supported by the peer",
-	"getpeerinforesult-relaytxes":      "Peer has requested transactions be relayed to it",
-	"getpeerinforesult-lastsend":       "Time the last message was received in seconds since 1 Jan 1970 GMT",
-	"getpeerinforesult-lastrecv":       "Time the last message was sent in seconds since 1 Jan 1970 GMT",
-	"getpeerinforesult-bytessent":      "Total bytes sent",
-	"getpeerinforesult-bytesrecv":      "Total bytes received",
-	"getpeerinforesult-conntime":       "Time the connection was made in seconds since 1 Jan 1970 GMT",
-	"getpeerinforesult-timeoffset":     "The time offset of the peer",
-	"getpeerinforesult-pingtime":       "Number of microseconds the last ping took",
-	"getpeerinforesult-pingwait":       "Number of microseconds a queued ping has been waiting for a response",
-	"getpeerinforesult-version":        "The protocol version of the peer",
-	"getpeerinforesult-subver":         "The user agent of the peer",
-	"getpeerinforesult-inbound":        "Whether or not the peer is an inbound connection",
-	"getpeerinforesult-startingheight": "The latest block height the peer knew about when the connection was established",
-	"getpeerinforesult-currentheight":  "The current height of the peer",
-	"getpeerinforesult-banscore":       "The ban score",
-	"getpeerinforesult-feefilter":      "The requested minimum fee a transaction must have to be announced to the peer",
-	"getpeerinforesult-syncnode":       "Whether or not the peer is the sync peer",
+	"getpeerinforesult-id":                       "A unique node ID",
+	"getpeerinforesult-addr":                     "The ip address and port of the peer",
+	"getpeerinforesult-addrlocal":                "Local address",
+	"getpeerinforesult-services":                 "Services bitmask which represents the services supported by the peer",
+	"getpeerinforesult-relaytxes":                "Peer has requested transactions be relayed to it",
+	"getpeerinforesult-lastsend":                 "Time the last message was received in seconds since 1 Jan 1970 GMT",
+	"getpeerinforesult-lastrecv":                 "Time the last message was sent in seconds since 1 Jan 1970 GMT",
+	"getpeerinforesult-bytessent":                "Total bytes sent",
+	"getpeerinforesult-bytesrecv":                "Total bytes received",
+	"getpeerinforesult-conntime":                 "Time the connection was made in seconds since 1 Jan 1970 GMT",
+	"getpeerinforesult-timeoffset":               "The time offset of the peer",
+	"getpeerinforesult-pingtime":                 "Number of microseconds the last ping took",
+	"getpeerinforesult-pingwait":                 "Number of microseconds a queued ping has been waiting for a response",
+	"getpeerinforesult-version":                  "The protocol version of the peer",
+	"getpeerinforesult-subver":                   "The user agent of the peer",
+	"getpeerinforesult-inbound":                  "Whether or not the peer is an inbound connection",
+	"getpeerinforesult-startingheight":           "The latest block height the peer knew about when the connection was established",
+	"getpeerinforesult-currentheight":            "The current height of the peer",
+	"getpeerinforesult-banscore":                 "The ban score",
+	"getpeerinforesult-feefilter":                "The requested minimum fee a transaction must have to be announced to the peer",
+	"getpeerinforesult-syncnode":                 "Whether or not the peer is the sync peer",
+	"getpeerinforesult-bip152_hb_to":             "Whether or not the peer was requested to announce new blocks with compact blocks",
+	"getpeerinforesult-bip152_hb_from":           "Whether or not the peer requested new blocks to be announced with compact blocks",
+	"getpeerinforesult-transport_protocol_type":  "The transport protocol used by the connection (v1 or v2)",
+	"getpeerinforesult-session_id":               "The session id of a v2 transport connection, empty for v1 connections",
+	"getpeerinforesult-bytessent_per_msg":        "Total bytes sent per message command",
+	"getpeerinforesult-bytessent_per_msg--key":   "command",
+	"getpeerinforesult-bytessent_per_msg--value": "n",
+	"getpeerinforesult-bytessent_per_msg--desc":  "The number of bytes sent in messages of the command",
+	"getpeerinforesult-bytesrecv_per_msg":        "Total bytes received per message command",
+	"getpeerinforesult-bytesrecv_per_msg--key":   "command",
+	"getpeerinforesult-bytesrecv_per_msg--value": "n",
+	"getpeerinforesult-bytesrecv_per_msg--desc":  "The number of bytes received in messages of the command, with messages that could not be decoded accounted for as *other*",
+	"getpeerinforesult-connection_type":          "The type of the connection (inbound, manual, outbound-full-relay, block-relay-only or feeler)",
+	"getpeerinforesult-permissions":              "The permissions granted to the peer (bloomfilter, noban, forcerelay, relay, mempool, download or addr)",
 
 	// GetPeerInfoCmd help.
 	"getpeerinfo--synopsis": "Returns data about each connected network peer as an array of json objects.",
 
+	// PrioritiseTransactionCmd help.
+	"prioritisetransaction--synopsis": "Accepts the transaction into mined blocks at a higher (or lower) priority.\n" +
+		"The fee delta is tracked even if the transaction is not yet in the memory pool.",
+	"prioritisetransaction-txid": "The hash of the transaction",
+	"prioritisetransaction-feedelta": "The fee value (in satoshis) to add (or subtract, if negative).\n" +
+		"The fee is not actually paid, only the algorithm for selecting transactions into a block considers the transaction as if it paid a higher (or lower) fee.",
+	"prioritisetransaction--result0": "Returns true",
+
 	// GetRawMempoolVerboseResult help.
 	"getrawmempoolverboseresult-size":             "Transaction size in bytes",
 	"getrawmempoolverboseresult-fee":              "Transaction fee in bitcoins",
@@ -519,6 +675,29 @@ var helpDescsEnUS = map[string]string{
 	"getrawtransaction--condition1": "verbose=true",
 	"getrawtransaction--result0":    "Hex-encoded bytes of the serialized transaction",
 
+	// StratumWorkerResult help.
+	"stratumworkerresult-name":           "The name the worker authorized with",
+	"stratumworkerresult-connections":    "The number of connections currently authorized for the worker",
+	"stratumworkerresult-sharesaccepted": "The number of accepted shares",
+	"stratumworkerresult-sharesrejected": "The number of rejected shares, such as duplicate or low difficulty shares",
+	"stratumworkerresult-sharesstale":    "The number of shares rejected because their job was no longer valid",
+	"stratumworkerresult-blocksfound":    "The number of shares that solved a block which was accepted",
+	"stratumworkerresult-work":           "The sum of the difficulties of the accepted shares",
+	"stratumworkerresult-hashespersec":   "The hashrate of the worker estimated from its accepted shares",
+	"stratumworkerresult-firstshare":     "The time of the first accepted share in seconds since 1 Jan 1970 GMT (0 if none)",
+	"stratumworkerresult-lastshare":      "The time of the last accepted share in seconds since 1 Jan 1970 GMT (0 if none)",
+
+	// GetStratumInfoResult help.
+	"getstratuminforesult-listeners":    "The addresses the stratum server is listening on",
+	"getstratuminforesult-clients":      "The number of connected stratum clients",
+	"getstratuminforesult-jobid":        "The id of the most recent job",
+	"getstratuminforesult-height":       "The height of the block the most recent job is for",
+	"getstratuminforesult-hashespersec": "The combined hashrate of all workers estimated from their accepted shares",
+	"getstratuminforesult-workers":      "The share accounting of all workers that authorized since the server was started",
+
+	// GetStratumInfoCmd help.
+	"getstratuminfo--synopsis": "Returns information about the built-in stratum mining server and the share accounting of its workers.",
+
 	// GetTxOutResult help.
 	"gettxoutresult-bestblock":     "The block hash that contains the transaction output",
 	"gettxoutresult-confirmations": "The number of confirmations",
@@ -541,6 +720,17 @@ var helpDescsEnUS = map[string]string{
 	"help--result0":    "List of commands",
 	"help--result1":    "Help for specified command",
 
+	// ListBannedCmd help.
+	"listbanned--synopsis": "Returns the banned IPs and subnets.",
+
+	// ListBannedResult help.
+	"listbannedresult-address":        "The banned IP or subnet",
+	"listbannedresult-ban_created":    "The time the ban was created in seconds since 1 Jan 1970 GMT",
+	"listbannedresult-banned_until":   "The time the ban expires in seconds since 1 Jan 1970 GMT",
+	"listbannedresult-ban_duration":   "The duration of the ban in seconds",
+	"listbannedresult-time_remaining": "The number of seconds until the ban expires",
+	"listbannedresult-ban_reason":     "The reason for the ban (node misbehaving or manually added)",
+
 	// PingCmd help.
 	"ping--synopsis": "Queues a ping to be sent to each connected peer.\n" +
 		"Ping times are provided by getpeerinfo via the pingtime and pingwait fields.",
@@ -569,6 +759,14 @@ var helpDescsEnUS = map[string]string{
 	"sendrawtransaction--result0":     "The hash of the transaction",
 	"allowhighfeesormaxfeerate-value": "Either the boolean value for the allowhighfees parameter in bitcoind < v0.19.0 or the numerical value for the maxfeerate field in bitcoind v0.19.0 and later",
 
+	// SetBanCmd help.
+	"setban--synopsis": "Bans or unbans an IP or subnet.\n" +
+		"Connected peers in a banned IP or subnet are disconnected.",
+	"setban-subnet":   "The IP or the subnet in CIDR notation (eg. 192.168.0.0/24) to operate on",
+	"setban-subcmd":   "'add' to ban the IP or subnet, or 'remove' to unban it",
+	"setban-bantime":  "The duration of the ban in seconds, or the time the ban expires in seconds since 1 Jan 1970 GMT when absolute is true (0 for the configured ban duration)",
+	"setban-absolute": "Whether the ban time is the time the ban expires rather than its duration",
+
 	// SetGenerateCmd help.
 	"setgenerate--synopsis":    "Set the server to generate coins (mine) or not.",
 	"setgenerate-generate":     "Use true to enable generation, false to disable it",
@@ -643,6 +841,20 @@ var helpDescsEnUS = map[string]string{
 	// StopNotifyNewTransactionsCmd help.
 	"stopnotifynewtransactions--synopsis": "Stop sending either a txaccepted or a txacceptedverbose notification when a new transaction is accepted into the mempool.",
 
+	// NotifyMempoolSequenceCmd help.
+	"notifymempoolsequence--synopsis": "Send a mempoolsequence notification whenever a transaction is added to or removed from the mempool.\n" +
+		"Each notification carries the mempool sequence number resulting from the event, which can be used to order events and detect missed ones, and removals also carry the reason and the hash of the replacing transaction, if any.",
+
+	// StopNotifyMempoolSequenceCmd help.
+	"stopnotifymempoolsequence--synopsis": "Stop sending mempoolsequence notifications whenever a transaction is added to or removed from the mempool.",
+
+	// NotifyBlockTemplateCmd help.
+	"notifyblocktemplate--synopsis": "Send a blocktemplatechanged notification whenever the transactions included in a new block template change due to changes to the mempool or the best chain.\n" +
+		"Each notification carries the hash of the block the template builds on, its height, the number of transactions excluding the coinbase, the total fees they pay, and the weight of the block.",
+
+	// StopNotifyBlockTemplateCmd help.
+	"stopnotifyblocktemplate--synopsis": "Stop sending blocktemplatechanged notifications whenever the transactions included in a new block template change.",
+
 	// NotifyReceivedCmd help.
 	"notifyreceived--synopsis": "Send a recvtx notification when a transaction added to mempool or appears in a newly-attached block contains a txout pkScript sending to any of the passed addresses.\n" +
 		"Matching outpoints are automatically registered for redeemingtx notifications.",
@@ -713,12 +925,20 @@ var helpDescsEnUS = map[string]string{
 // pointer to the type (or nil to indicate no return value).
 var rpcResultTypes = map[string][]interface{}{
 	"addnode":                nil,
+	"clearbanned":            nil,
 	"createrawtransaction":   {(*string)(nil)},
 	"debuglevel":             {(*string)(nil), (*string)(nil)},
 	"decoderawtransaction":   {(*btcjson.TxRawDecodeResult)(nil)},
 	"decodescript":           {(*btcjson.DecodeScriptResult)(nil)},
+	"disconnectnode":         nil,
 	"estimatefee":            {(*float64)(nil)},
+	"estimatemempoolfee":     {(*[]btcjson.ProjectedBlockResult)(nil)},
+	"estimaterawfee":         {(*btcjson.EstimateRawFeeResult)(nil)},
+	"estimatesmartfee":       {(*btcjson.EstimateSmartFeeResult)(nil)},
 	"generate":               {(*[]string)(nil)},
+	"generateblock":          {(*btcjson.GenerateBlockResult)(nil)},
+	"generatetoaddress":      {(*[]string)(nil)},
+	"generatetodescriptor":   {(*[]string)(nil)},
 	"getaddednodeinfo":       {(*[]string)(nil), (*[]btcjson.GetAddedNodeInfoResult)(nil)},
 	"getbestblock":           {(*btcjson.GetBestBlockResult)(nil)},
 	"getbestblockhash":       {(*string)(nil)},
@@ -737,6 +957,7 @@ var rpcResultTypes = map[string][]interface{}{
 	"gethashespersec":        {(*float64)(nil)},
 	"getheaders":             {(*[]string)(nil)},
 	"getinfo":                {(*btcjson.InfoChainResult)(nil)},
+	"getmempoolentry":        {(*btcjson.GetMempoolEntryResult)(nil)},
 	"getmempoolinfo":         {(*btcjson.GetMempoolInfoResult)(nil)},
 	"getmininginfo":          {(*btcjson.GetMiningInfoResult)(nil)},
 	"getnettotals":           {(*btcjson.GetNetTotalsResult)(nil)},
@@ -745,12 +966,16 @@ var rpcResultTypes = map[string][]interface{}{
 	"getpeerinfo":            {(*[]btcjson.GetPeerInfoResult)(nil)},
 	"getrawmempool":          {(*[]string)(nil), (*btcjson.GetRawMempoolVerboseResult)(nil)},
 	"getrawtransaction":      {(*string)(nil), (*btcjson.TxRawResult)(nil)},
+	"getstratuminfo":         {(*btcjson.GetStratumInfoResult)(nil)},
 	"gettxout":               {(*btcjson.GetTxOutResult)(nil)},
+	"listbanned":             {(*[]btcjson.ListBannedResult)(nil)},
 	"node":                   nil,
 	"help":                   {(*string)(nil), (*string)(nil)},
 	"ping":                   nil,
+	"prioritisetransaction":  {(*bool)(nil)},
 	"searchrawtransactions":  {(*string)(nil), (*[]btcjson.SearchRawTransactionsResult)(nil)},
 	"sendrawtransaction":     {(*string)(nil)},
+	"setban":                 nil,
 	"setgenerate":            nil,
 	"signmessagewithprivkey": {(*string)(nil)},
 	"stop":                   {(*string)(nil)},
@@ -768,6 +993,10 @@ var rpcResultTypes = map[string][]interface{}{
 	"stopnotifyblocks":          nil,
 	"notifynewtransactions":     nil,
 	"stopnotifynewtransactions": nil,
+	"notifymempoolsequence":     nil,
+	"stopnotifymempoolsequence": nil,
+	"notifyblocktemplate":       nil,
+	"stopnotifyblocktemplate":   nil,
 	"notifyreceived":            nil,
 	"stopnotifyreceived":        nil,
 	"notifyspent":               nil,
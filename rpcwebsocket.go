@@ -25,6 +25,8 @@ import (
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/mining"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcd/btcutil"
@@ -66,11 +68,15 @@ var wsHandlersBeforeInit = map[string]wsCommandHandler{
 	"loadtxfilter":              handleLoadTxFilter,
 	"help":                      handleWebsocketHelp,
 	"notifyblocks":              handleNotifyBlocks,
+	"notifyblocktemplate":       handleNotifyBlockTemplate,
+	"notifymempoolsequence":     handleNotifyMempoolSequence,
 	"notifynewtransactions":     handleNotifyNewTransactions,
 	"notifyreceived":            handleNotifyReceived,
 	"notifyspent":               handleNotifySpent,
 	"session":                   handleSession,
 	"stopnotifyblocks":          handleStopNotifyBlocks,
+	"stopnotifyblocktemplate":   handleStopNotifyBlockTemplate,
+	"stopnotifymempoolsequence": handleStopNotifyMempoolSequence,
 	"stopnotifynewtransactions": handleStopNotifyNewTransactions,
 	"stopnotifyspent":           handleStopNotifySpent,
 	"stopnotifyreceived":        handleStopNotifyReceived,
@@ -229,11 +235,15 @@ func (m *wsNotificationManager) NotifyBlockDisconnected(block *btcutil.Block) {
 // NotifyMempoolTx passes a transaction accepted by mempool to the
 // notification manager for transaction notification processing.  If
 // isNew is true, the tx is is a new transaction, rather than one
-// added to the mempool during a reorg.
-func (m *wsNotificationManager) NotifyMempoolTx(tx *btcutil.Tx, isNew bool) {
+// added to the mempool during a reorg.  The sequence is the mempool sequence
+// number assigned to the addition of the transaction.
+func (m *wsNotificationManager) NotifyMempoolTx(tx *btcutil.Tx, isNew bool,
+	sequence uint64) {
+
 	n := &notificationTxAcceptedByMempool{
-		isNew: isNew,
-		tx:    tx,
+		isNew:    isNew,
+		tx:       tx,
+		sequence: sequence,
 	}
 
 	// As NotifyMempoolTx will be called by mempool and the RPC server
@@ -246,6 +256,32 @@ func (m *wsNotificationManager) NotifyMempoolTx(tx *btcutil.Tx, isNew bool) {
 	}
 }
 
+// NotifyMempoolTxRemoved passes a transaction removed from the mempool to the
+// notification manager for mempool sequence notification processing.
+func (m *wsNotificationManager) NotifyMempoolTxRemoved(removal *mempool.TxRemoval) {
+	// As NotifyMempoolTxRemoved will be called by mempool and the RPC
+	// server may no longer be running, use a select statement to unblock
+	// enqueuing the notification once the RPC server has begun shutting
+	// down.
+	select {
+	case m.queueNotification <- (*notificationTxRemovedFromMempool)(removal):
+	case <-m.quit:
+	}
+}
+
+// NotifyBlockTemplate passes a new block template to the notification manager
+// for block template notification processing.
+func (m *wsNotificationManager) NotifyBlockTemplate(template *mining.BlockTemplate) {
+	// As NotifyBlockTemplate will be called by the block template manager
+	// and the RPC server may no longer be running, use a select statement
+	// to unblock enqueuing the notification once the RPC server has begun
+	// shutting down.
+	select {
+	case m.queueNotification <- (*notificationBlockTemplate)(template):
+	case <-m.quit:
+	}
+}
+
 // wsClientFilter tracks relevant addresses for each websocket client for
 // the `rescanblocks` extension. It is modified by the `loadtxfilter` command.
 //
@@ -448,9 +484,12 @@ func (f *wsClientFilter) removeUnspentOutPoint(op *wire.OutPoint) {
 type notificationBlockConnected btcutil.Block
 type notificationBlockDisconnected btcutil.Block
 type notificationTxAcceptedByMempool struct {
-	isNew bool
-	tx    *btcutil.Tx
+	isNew    bool
+	tx       *btcutil.Tx
+	sequence uint64
 }
+type notificationTxRemovedFromMempool mempool.TxRemoval
+type notificationBlockTemplate mining.BlockTemplate
 
 // Notification control requests
 type notificationRegisterClient wsClient
@@ -459,6 +498,10 @@ type notificationRegisterBlocks wsClient
 type notificationUnregisterBlocks wsClient
 type notificationRegisterNewMempoolTxs wsClient
 type notificationUnregisterNewMempoolTxs wsClient
+type notificationRegisterMempoolSequence wsClient
+type notificationUnregisterMempoolSequence wsClient
+type notificationRegisterBlockTemplate wsClient
+type notificationUnregisterBlockTemplate wsClient
 type notificationRegisterSpent struct {
 	wsc *wsClient
 	ops []*wire.OutPoint
@@ -491,6 +534,8 @@ func (m *wsNotificationManager) notificationHandler() {
 	// since it is quite a bit more efficient than using the entire struct.
 	blockNotifications := make(map[chan struct{}]*wsClient)
 	txNotifications := make(map[chan struct{}]*wsClient)
+	sequenceNotifications := make(map[chan struct{}]*wsClient)
+	templateNotifications := make(map[chan struct{}]*wsClient)
 	watchedOutPoints := make(map[wire.OutPoint]map[chan struct{}]*wsClient)
 	watchedAddrs := make(map[string]map[chan struct{}]*wsClient)
 
@@ -538,6 +583,22 @@ out:
 				}
 				m.notifyForTx(watchedOutPoints, watchedAddrs, n.tx, nil)
 				m.notifyRelevantTxAccepted(n.tx, clients)
+				if len(sequenceNotifications) != 0 {
+					m.notifyMempoolTxAdded(sequenceNotifications,
+						n.tx, n.sequence)
+				}
+
+			case *notificationTxRemovedFromMempool:
+				if len(sequenceNotifications) != 0 {
+					m.notifyMempoolTxRemoved(sequenceNotifications,
+						(*mempool.TxRemoval)(n))
+				}
+
+			case *notificationBlockTemplate:
+				if len(templateNotifications) != 0 {
+					m.notifyBlockTemplate(templateNotifications,
+						(*mining.BlockTemplate)(n))
+				}
 
 			case *notificationRegisterBlocks:
 				wsc := (*wsClient)(n)
@@ -557,6 +618,8 @@ out:
 				// the client itself.
 				delete(blockNotifications, wsc.quit)
 				delete(txNotifications, wsc.quit)
+				delete(sequenceNotifications, wsc.quit)
+				delete(templateNotifications, wsc.quit)
 				for k := range wsc.spentRequests {
 					op := k
 					m.removeSpentRequest(watchedOutPoints, wsc, &op)
@@ -586,6 +649,22 @@ out:
 				wsc := (*wsClient)(n)
 				delete(txNotifications, wsc.quit)
 
+			case *notificationRegisterMempoolSequence:
+				wsc := (*wsClient)(n)
+				sequenceNotifications[wsc.quit] = wsc
+
+			case *notificationUnregisterMempoolSequence:
+				wsc := (*wsClient)(n)
+				delete(sequenceNotifications, wsc.quit)
+
+			case *notificationRegisterBlockTemplate:
+				wsc := (*wsClient)(n)
+				templateNotifications[wsc.quit] = wsc
+
+			case *notificationUnregisterBlockTemplate:
+				wsc := (*wsClient)(n)
+				delete(templateNotifications, wsc.quit)
+
 			default:
 				rpcsLog.Warn("Unhandled notification type")
 			}
@@ -819,6 +898,94 @@ func (m *wsNotificationManager) UnregisterNewMempoolTxsUpdates(wsc *wsClient) {
 	m.queueNotification <- (*notificationUnregisterNewMempoolTxs)(wsc)
 }
 
+// RegisterMempoolSequenceUpdates requests notifications to the passed
+// websocket client when transactions are added to or removed from the memory
+// pool.
+func (m *wsNotificationManager) RegisterMempoolSequenceUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationRegisterMempoolSequence)(wsc)
+}
+
+// UnregisterMempoolSequenceUpdates removes notifications to the passed
+// websocket client when transactions are added to or removed from the memory
+// pool.
+func (m *wsNotificationManager) UnregisterMempoolSequenceUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationUnregisterMempoolSequence)(wsc)
+}
+
+// RegisterBlockTemplateUpdates requests notifications to the passed websocket
+// client when the transactions included in a new block template change.
+func (m *wsNotificationManager) RegisterBlockTemplateUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationRegisterBlockTemplate)(wsc)
+}
+
+// UnregisterBlockTemplateUpdates removes notifications to the passed websocket
+// client when the transactions included in a new block template change.
+func (m *wsNotificationManager) UnregisterBlockTemplateUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationUnregisterBlockTemplate)(wsc)
+}
+
+// notifyBlockTemplate notifies websocket clients that have registered for
+// block template updates about the passed new block template.
+func (m *wsNotificationManager) notifyBlockTemplate(clients map[chan struct{}]*wsClient,
+	template *mining.BlockTemplate) {
+
+	msgBlock := template.Block
+	weight := blockchain.GetBlockWeight(btcutil.NewBlock(msgBlock))
+	ntfn := btcjson.NewBlockTemplateChangedNtfn(
+		msgBlock.Header.PrevBlock.String(), template.Height,
+		len(msgBlock.Transactions)-1, -template.Fees[0], weight)
+	marshalledJSON, err := btcjson.MarshalCmd(btcjson.RpcVersion1, nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal block template notification: "+
+			"%v", err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
+// notifyMempoolTxAdded notifies websocket clients that have registered for
+// mempool sequence updates when a transaction is added to the memory pool.
+func (m *wsNotificationManager) notifyMempoolTxAdded(clients map[chan struct{}]*wsClient,
+	tx *btcutil.Tx, sequence uint64) {
+
+	ntfn := btcjson.NewMempoolSequenceNtfn(tx.Hash().String(),
+		btcjson.MempoolSequenceAdded, sequence, nil, nil)
+	marshalledJSON, err := btcjson.MarshalCmd(btcjson.RpcVersion1, nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal mempool sequence notification: "+
+			"%v", err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
+// notifyMempoolTxRemoved notifies websocket clients that have registered for
+// mempool sequence updates when a transaction is removed from the memory pool.
+func (m *wsNotificationManager) notifyMempoolTxRemoved(clients map[chan struct{}]*wsClient,
+	removal *mempool.TxRemoval) {
+
+	var replacedBy *string
+	if removal.ReplacedBy != nil {
+		replacedBy = btcjson.String(removal.ReplacedBy.String())
+	}
+	ntfn := btcjson.NewMempoolSequenceNtfn(removal.Tx.Hash().String(),
+		btcjson.MempoolSequenceRemoved, removal.Sequence,
+		btcjson.String(removal.Reason.String()), replacedBy)
+	marshalledJSON, err := btcjson.MarshalCmd(btcjson.RpcVersion1, nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal mempool sequence notification: "+
+			"%v", err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
 // notifyForNewTx notifies websocket clients that have registered for updates
 // when a new transaction is added to the memory pool.
 func (m *wsNotificationManager) notifyForNewTx(clients map[chan struct{}]*wsClient, tx *btcutil.Tx) {
@@ -2164,6 +2331,34 @@ func handleStopNotifyNewTransactions(wsc *wsClient, icmd interface{}) (interface
 	return nil, nil
 }
 
+// handleNotifyBlockTemplate implements the notifyblocktemplate command
+// extension for websocket connections.
+func handleNotifyBlockTemplate(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.RegisterBlockTemplateUpdates(wsc)
+	return nil, nil
+}
+
+// handleStopNotifyBlockTemplate implements the stopnotifyblocktemplate
+// command extension for websocket connections.
+func handleStopNotifyBlockTemplate(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.UnregisterBlockTemplateUpdates(wsc)
+	return nil, nil
+}
+
+// handleNotifyMempoolSequence implements the notifymempoolsequence command
+// extension for websocket connections.
+func handleNotifyMempoolSequence(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.RegisterMempoolSequenceUpdates(wsc)
+	return nil, nil
+}
+
+// handleStopNotifyMempoolSequence implements the stopnotifymempoolsequence
+// command extension for websocket connections.
+func handleStopNotifyMempoolSequence(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.UnregisterMempoolSequenceUpdates(wsc)
+	return nil, nil
+}
+
 // handleNotifyReceived implements the notifyreceived command extension for
 // websocket connections.
 func handleNotifyReceived(wsc *wsClient, icmd interface{}) (interface{}, error) {
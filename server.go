@@ -14,6 +14,7 @@ import (
 	"fmt"
 	"math"
 	"net"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strconv"
@@ -22,6 +23,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/aead/siphash"
 	"github.com/btcsuite/btcd/addrmgr"
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/blockchain/indexers"
@@ -31,9 +33,11 @@ import (
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/connmgr"
 	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/erlay"
 	"github.com/btcsuite/btcd/mempool"
 	"github.com/btcsuite/btcd/mining"
 	"github.com/btcsuite/btcd/mining/cpuminer"
+	"github.com/btcsuite/btcd/mining/stratum"
 	"github.com/btcsuite/btcd/netsync"
 	"github.com/btcsuite/btcd/peer"
 	"github.com/btcsuite/btcd/txscript"
@@ -58,6 +62,25 @@ const (
 	// retries when connecting to persistent peers.  It is adjusted by the
 	// number of retries such that there is a retry backoff.
 	connectionRetryInterval = time.Second * 5
+
+	// mempoolExpiryScanInterval is the amount of time in between scans of
+	// the mempool to evict expired transactions.
+	mempoolExpiryScanInterval = time.Minute * 10
+
+	// maxCmpctBlockDepth is the maximum number of blocks a block requested
+	// as a compact block may be below the tip of the main chain to be sent
+	// as such.  Older blocks are sent in full since their transactions are
+	// unlikely to still be in the memory pool of the peer.
+	maxCmpctBlockDepth = 5
+
+	// maxBlockTxnDepth is the maximum number of blocks a block may be below
+	// the tip of the main chain for its transactions to be sent in response
+	// to a getblocktxn message.  Older blocks are sent in full instead.
+	maxBlockTxnDepth = 10
+
+	// txReconciliationInterval is the amount of time in between the rounds
+	// of transaction reconciliation initiated with each outbound peer.
+	txReconciliationInterval = time.Second * 8
 )
 
 var (
@@ -152,12 +175,11 @@ type updatePeerHeightsMsg struct {
 }
 
 // peerState maintains state of inbound, persistent, outbound peers as well
-// as banned peers and outbound groups.
+// as outbound groups.
 type peerState struct {
 	inboundPeers    map[int32]*serverPeer
 	outboundPeers   map[int32]*serverPeer
 	persistentPeers map[int32]*serverPeer
-	banned          map[string]time.Time
 	outboundGroups  map[string]int
 }
 
@@ -216,6 +238,8 @@ type server struct {
 	chain                *blockchain.BlockChain
 	txMemPool            *mempool.TxPool
 	cpuMiner             *cpuminer.CPUMiner
+	stratumServer        *stratum.Server
+	templateManager      *mining.TemplateManager
 	modifyRebroadcastInv chan interface{}
 	newPeers             chan *serverPeer
 	donePeers            chan *serverPeer
@@ -255,6 +279,30 @@ type server struct {
 	// agentWhitelist is a list of whitelisted user agent substrings, no
 	// whitelisting will be applied if the list is empty or nil.
 	agentWhitelist []string
+
+	// v1Fallbacks tracks the addresses of outbound peers which only
+	// support the v1 transport so they are connected to using it.
+	v1Fallbacks lru.Cache
+
+	// v1Retries houses the addresses of outbound peers which only support
+	// the v1 transport that are yet to be reconnected to.  They are
+	// returned before any other address to new outbound connection
+	// requests.
+	v1RetriesMtx sync.Mutex
+	v1Retries    []net.Addr
+
+	// evictionKey is the secret key the network groups of inbound peers
+	// are hashed with to select the groups protected from eviction.
+	evictionKey [siphash.KeySize]byte
+
+	// banList holds the banned IPs and subnets, which are persisted to the
+	// data directory.
+	banList *banList
+
+	// uploadTarget limits the upload traffic by no longer serving
+	// historical blocks once the configured target is close to being
+	// reached.
+	uploadTarget *uploadTarget
 }
 
 // serverPeer extends the peer to maintain state shared by the server and
@@ -268,11 +316,15 @@ type serverPeer struct {
 	connReq        *connmgr.ConnReq
 	server         *server
 	persistent     bool
+	blockRelayOnly bool
+	feeler         bool
 	continueHash   *chainhash.Hash
 	relayMtx       sync.Mutex
 	disableRelayTx bool
 	sentAddrs      bool
-	isWhitelisted  bool
+	permissions    netPermissions
+	disableV2      bool
+	txRecon        *erlay.Reconciler
 	filter         *bloom.Filter
 	addressesMtx   sync.RWMutex
 	knownAddresses lru.Cache
@@ -330,17 +382,61 @@ func (sp *serverPeer) setDisableRelayTx(disable bool) {
 	sp.relayMtx.Unlock()
 }
 
+// hasPermission returns whether the peer was granted all of the passed
+// permissions by the whitelisted networks or the whitebind listener it
+// connected through.
+func (sp *serverPeer) hasPermission(perm netPermissions) bool {
+	return sp.permissions.has(perm)
+}
+
+// services returns the services advertised to the peer, which include bloom
+// filtering when the peer was granted the bloomfilter permission.
+func (sp *serverPeer) services() wire.ServiceFlag {
+	services := sp.server.services
+	if sp.hasPermission(permBloomFilter) {
+		services |= wire.SFNodeBloom
+	}
+	return services
+}
+
 // relayTxDisabled returns whether or not relaying of transactions for the given
-// peer is disabled.
-// It is safe for concurrent access.
+// peer is disabled, which is always the case for block-relay-only and feeler
+// peers.  It is safe for concurrent access.
 func (sp *serverPeer) relayTxDisabled() bool {
 	sp.relayMtx.Lock()
-	isDisabled := sp.disableRelayTx
+	isDisabled := sp.disableRelayTx || sp.blockRelayOnly || sp.feeler
 	sp.relayMtx.Unlock()
 
 	return isDisabled
 }
 
+// connectionType returns the type of the connection to the peer as reported
+// by the getpeerinfo RPC.
+func (sp *serverPeer) connectionType() string {
+	switch {
+	case sp.Inbound():
+		return "inbound"
+	case sp.persistent:
+		return "manual"
+	case sp.blockRelayOnly:
+		return "block-relay-only"
+	case sp.feeler:
+		return "feeler"
+	default:
+		return "outbound-full-relay"
+	}
+}
+
+// txInvVect returns the inventory vector the passed transaction is announced
+// to the peer with, which refers to the transaction by its witness hash when
+// the peer negotiated wtxid relay (BIP0339) and by its hash otherwise.
+func (sp *serverPeer) txInvVect(tx *btcutil.Tx) *wire.InvVect {
+	if sp.WantsWTxIdRelay() {
+		return wire.NewInvVect(wire.InvTypeWTx, tx.WitnessHash())
+	}
+	return wire.NewInvVect(wire.InvTypeTx, tx.Hash())
+}
+
 // pushAddrMsg sends a legacy addr message to the connected peer using the
 // provided addresses.
 func (sp *serverPeer) pushAddrMsg(addresses []*wire.NetAddressV2) {
@@ -420,7 +516,7 @@ func (sp *serverPeer) addBanScore(persistent, transient uint32, reason string) b
 	if cfg.DisableBanning {
 		return false
 	}
-	if sp.isWhitelisted {
+	if sp.hasPermission(permNoBan) {
 		peerLog.Debugf("Misbehaving whitelisted peer %s: %s", sp, reason)
 		return false
 	}
@@ -530,23 +626,144 @@ func (sp *serverPeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) *wire.MsgRej
 // OnVerAck is invoked when a peer receives a verack bitcoin message and is used
 // to kick start communication with them.
 func (sp *serverPeer) OnVerAck(_ *peer.Peer, _ *wire.MsgVerAck) {
+	// Relay transactions to the peer through set reconciliation when both
+	// sides support it.  The reconciler is set up before the peer is added
+	// so it is in place before any transactions are relayed to it.
+	if cfg.TxReconciliation && sp.SupportsTxReconciliation() &&
+		!sp.relayTxDisabled() {
+
+		localSalt, remoteSalt := sp.TxReconciliationSalts()
+		sp.txRecon = erlay.NewReconciler(!sp.Inbound(), localSalt,
+			remoteSalt)
+		if sp.txRecon.IsInitiator() {
+			go sp.txReconHandler()
+		}
+		peerLog.Debugf("Relaying transactions to %v through set "+
+			"reconciliation", sp)
+	}
+
 	sp.server.AddPeer(sp)
 }
 
+// txReconHandler periodically requests a sketch of the reconciliation set of
+// the peer to initiate a round of transaction reconciliation until the peer
+// disconnects.  It must be run as a goroutine.
+func (sp *serverPeer) txReconHandler() {
+	ticker := time.NewTicker(txReconciliationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if msg := sp.txRecon.RequestReconciliation(); msg != nil {
+				sp.QueueMessage(msg, nil)
+			}
+
+		case <-sp.quit:
+			return
+		}
+	}
+}
+
+// announceReconciledTxs queues inventory for the transactions with the passed
+// witness hashes which were found to be missing from the peer during a round
+// of transaction reconciliation.
+func (sp *serverPeer) announceReconciledTxs(wtxids []chainhash.Hash) {
+	for i := range wtxids {
+		sp.QueueInventory(wire.NewInvVect(wire.InvTypeWTx, &wtxids[i]))
+	}
+}
+
+// OnReqRecon is invoked when a peer receives a reqrecon bitcoin message.  It
+// replies with a sketch of the transactions to be announced to the peer.
+func (sp *serverPeer) OnReqRecon(_ *peer.Peer, msg *wire.MsgReqRecon) {
+	if sp.txRecon == nil {
+		peerLog.Debugf("Peer %v sent reqrecon without negotiating "+
+			"transaction reconciliation -- disconnecting", sp)
+		sp.Disconnect()
+		return
+	}
+
+	sketch, err := sp.txRecon.HandleReqRecon(msg)
+	if err != nil {
+		peerLog.Debugf("Unable to handle reqrecon from %v: %v -- "+
+			"disconnecting", sp, err)
+		sp.Disconnect()
+		return
+	}
+	sp.QueueMessage(sketch, nil)
+}
+
+// OnSketch is invoked when a peer receives a sketch bitcoin message.  It
+// concludes the round of transaction reconciliation by announcing the
+// transactions the peer is missing and requesting the ones it has.
+func (sp *serverPeer) OnSketch(_ *peer.Peer, msg *wire.MsgSketch) {
+	if sp.txRecon == nil {
+		peerLog.Debugf("Peer %v sent sketch without negotiating "+
+			"transaction reconciliation -- disconnecting", sp)
+		sp.Disconnect()
+		return
+	}
+
+	announce, diff, err := sp.txRecon.HandleSketch(msg)
+	if err != nil {
+		peerLog.Debugf("Unable to handle sketch from %v: %v -- "+
+			"disconnecting", sp, err)
+		sp.Disconnect()
+		return
+	}
+	if !diff.Success {
+		peerLog.Debugf("Transaction reconciliation with %v failed -- "+
+			"announcing %d transactions", sp, len(announce))
+	}
+	sp.QueueMessage(diff, nil)
+	sp.announceReconciledTxs(announce)
+}
+
+// OnReconcilDiff is invoked when a peer receives a reconcildiff bitcoin
+// message.  It announces the transactions the peer requested.
+func (sp *serverPeer) OnReconcilDiff(_ *peer.Peer, msg *wire.MsgReconcilDiff) {
+	if sp.txRecon == nil {
+		peerLog.Debugf("Peer %v sent reconcildiff without negotiating "+
+			"transaction reconciliation -- disconnecting", sp)
+		sp.Disconnect()
+		return
+	}
+
+	announce, err := sp.txRecon.HandleReconcilDiff(msg)
+	if err != nil {
+		peerLog.Debugf("Unable to handle reconcildiff from %v: %v -- "+
+			"disconnecting", sp, err)
+		sp.Disconnect()
+		return
+	}
+	sp.announceReconciledTxs(announce)
+}
+
 // OnMemPool is invoked when a peer receives a mempool bitcoin message.
 // It creates and sends an inventory message with the contents of the memory
 // pool up to the maximum inventory allowed per message.  When the peer has a
 // bloom filter loaded, the contents are filtered accordingly.
 func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
-	// Only allow mempool requests if the server has bloom filtering
-	// enabled.
-	if sp.server.services&wire.SFNodeBloom != wire.SFNodeBloom {
+	// Only allow mempool requests if bloom filtering is enabled for the
+	// peer or it was granted the mempool permission.
+	if sp.services()&wire.SFNodeBloom != wire.SFNodeBloom &&
+		!sp.hasPermission(permMempool) {
+
 		peerLog.Debugf("peer %v sent mempool request with bloom "+
 			"filtering disabled -- disconnecting", sp)
 		sp.Disconnect()
 		return
 	}
 
+	// Transactions are never relayed to block-relay-only peers.
+	if sp.blockRelayOnly {
+		peerLog.Debugf("Block-relay-only peer %v sent mempool request "+
+			"-- disconnecting", sp)
+		sp.Disconnect()
+		return
+	}
+
 	// A decaying ban score increase is applied to prevent flooding.
 	// The ban score accumulates and passes the ban threshold if a burst of
 	// mempool messages comes from a peer. The score decays each minute to
@@ -569,8 +786,7 @@ func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 		// or only the transactions that match the filter when there is
 		// one.
 		if !sp.filter.IsLoaded() || sp.filter.MatchTxAndUpdate(txDesc.Tx) {
-			iv := wire.NewInvVect(wire.InvTypeTx, txDesc.Tx.Hash())
-			invMsg.AddInvVect(iv)
+			invMsg.AddInvVect(sp.txInvVect(txDesc.Tx))
 			if len(invMsg.InvList)+1 > wire.MaxInvPerMsg {
 				break
 			}
@@ -588,25 +804,45 @@ func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 // handler this does not serialize all transactions through a single thread
 // transactions don't rely on the previous one in a linear fashion like blocks.
 func (sp *serverPeer) OnTx(_ *peer.Peer, msg *wire.MsgTx) {
-	if cfg.BlocksOnly {
+	if cfg.BlocksOnly && !sp.hasPermission(permRelay) {
 		peerLog.Tracef("Ignoring tx %v from %v - blocksonly enabled",
 			msg.TxHash(), sp)
 		return
 	}
 
+	// Block-relay-only peers must not send transactions since relaying
+	// them was disabled in the version message.
+	if sp.blockRelayOnly {
+		peerLog.Infof("Block-relay-only peer %v sent tx %v -- "+
+			"disconnecting", sp, msg.TxHash())
+		sp.Disconnect()
+		return
+	}
+
 	// Add the transaction to the known inventory for the peer.
 	// Convert the raw MsgTx to a btcutil.Tx which provides some convenience
 	// methods and things such as hash caching.
 	tx := btcutil.NewTx(msg)
 	iv := wire.NewInvVect(wire.InvTypeTx, tx.Hash())
 	sp.AddKnownInventory(iv)
+	sp.AddKnownInventory(wire.NewInvVect(wire.InvTypeWTx, tx.WitnessHash()))
+
+	// The peer has the transaction, so there is no need to reconcile it.
+	if sp.txRecon != nil {
+		sp.txRecon.Remove(tx.WitnessHash())
+	}
 
 	// Queue the transaction up to be handled by the sync manager and
 	// intentionally block further receives until the transaction is fully
 	// processed and known good or bad.  This helps prevent a malicious peer
 	// from queuing up a bunch of bad transactions before disconnecting (or
 	// being disconnected) and wasting memory.
-	sp.server.syncManager.QueueTx(tx, sp.Peer, sp.txProcessed)
+	opts := netsync.QueueTxOptions{
+		NoRateLimit: sp.hasPermission(permRelay),
+		ForceRelay:  sp.hasPermission(permForceRelay),
+	}
+	sp.server.syncManager.QueueTxWithOptions(tx, sp.Peer, opts,
+		sp.txProcessed)
 	<-sp.txProcessed
 }
 
@@ -636,12 +872,80 @@ func (sp *serverPeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 	<-sp.blockProcessed
 }
 
+// OnCmpctBlock is invoked when a peer receives a cmpctblock bitcoin message.
+// It queues the compact block up to be reconstructed and processed by the sync
+// manager.
+func (sp *serverPeer) OnCmpctBlock(_ *peer.Peer, msg *wire.MsgCmpctBlock) {
+	// Add the block to the known inventory for the peer.
+	blockHash := msg.Header.BlockHash()
+	iv := wire.NewInvVect(wire.InvTypeBlock, &blockHash)
+	sp.AddKnownInventory(iv)
+
+	// Block further receives until the compact block is processed for
+	// the same reasons as for full blocks.
+	sp.server.syncManager.QueueCmpctBlock(msg, sp.Peer, sp.blockProcessed)
+	<-sp.blockProcessed
+}
+
+// OnBlockTxn is invoked when a peer receives a blocktxn bitcoin message.  It
+// queues the transactions up to complete the compact block being
+// reconstructed by the sync manager.
+func (sp *serverPeer) OnBlockTxn(_ *peer.Peer, msg *wire.MsgBlockTxn) {
+	sp.server.syncManager.QueueBlockTxn(msg, sp.Peer, sp.blockProcessed)
+	<-sp.blockProcessed
+}
+
+// OnGetBlockTxn is invoked when a peer receives a getblocktxn bitcoin message.
+// The requested transactions of recent blocks are sent in a blocktxn message
+// while older blocks are sent in full.
+func (sp *serverPeer) OnGetBlockTxn(_ *peer.Peer, msg *wire.MsgGetBlockTxn) {
+	chain := sp.server.chain
+	height, err := chain.BlockHeightByHash(&msg.BlockHash)
+	if err != nil || chain.BestSnapshot().Height-height >= maxBlockTxnDepth {
+		peerLog.Debugf("Sending full block %v requested with "+
+			"getblocktxn to %v", msg.BlockHash, sp)
+		sp.server.pushBlockMsg(sp, &msg.BlockHash, nil, nil,
+			wire.WitnessEncoding)
+		return
+	}
+
+	block, err := chain.BlockByHash(&msg.BlockHash)
+	if err != nil {
+		peerLog.Tracef("Unable to fetch requested block hash %v: %v",
+			msg.BlockHash, err)
+		return
+	}
+
+	txns := block.MsgBlock().Transactions
+	blockTxn := wire.NewMsgBlockTxn(&msg.BlockHash)
+	for _, index := range msg.Indexes {
+		if int(index) >= len(txns) {
+			sp.addBanScore(100, 0, "getblocktxn index out of range")
+			return
+		}
+		blockTxn.AddTransaction(txns[index])
+	}
+	sp.QueueMessageWithEncoding(blockTxn, nil, wire.WitnessEncoding)
+}
+
 // OnInv is invoked when a peer receives an inv bitcoin message and is
 // used to examine the inventory being advertised by the remote peer and react
 // accordingly.  We pass the message down to blockmanager which will call
 // QueueMessage with any appropriate responses.
 func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
-	if !cfg.BlocksOnly {
+	// Transactions announced by the peer don't need to be reconciled with
+	// it.
+	if sp.txRecon != nil {
+		for _, invVect := range msg.InvList {
+			if invVect.Type == wire.InvTypeWTx {
+				sp.txRecon.Remove(&invVect.Hash)
+			}
+		}
+	}
+
+	if (!cfg.BlocksOnly || sp.hasPermission(permRelay)) &&
+		!sp.blockRelayOnly {
+
 		if len(msg.InvList) > 0 {
 			sp.server.syncManager.QueueInv(msg, sp.Peer)
 		}
@@ -650,9 +954,11 @@ func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
 
 	newInv := wire.NewMsgInvSizeHint(uint(len(msg.InvList)))
 	for _, invVect := range msg.InvList {
-		if invVect.Type == wire.InvTypeTx {
+		if invVect.Type == wire.InvTypeTx ||
+			invVect.Type == wire.InvTypeWTx {
+
 			peerLog.Tracef("Ignoring tx %v in inv from %v -- "+
-				"blocksonly enabled", invVect.Hash, sp)
+				"transaction relay disabled", invVect.Hash, sp)
 			if sp.ProtocolVersion() >= wire.BIP0037Version {
 				peerLog.Infof("Peer %v is announcing "+
 					"transactions -- disconnecting", sp)
@@ -715,14 +1021,18 @@ func (sp *serverPeer) OnGetData(_ *peer.Peer, msg *wire.MsgGetData) {
 		}
 		var err error
 		switch iv.Type {
+		case wire.InvTypeWTx:
+			err = sp.server.pushTxMsg(sp, iv, c, waitChan, wire.WitnessEncoding)
 		case wire.InvTypeWitnessTx:
-			err = sp.server.pushTxMsg(sp, &iv.Hash, c, waitChan, wire.WitnessEncoding)
+			err = sp.server.pushTxMsg(sp, iv, c, waitChan, wire.WitnessEncoding)
 		case wire.InvTypeTx:
-			err = sp.server.pushTxMsg(sp, &iv.Hash, c, waitChan, wire.BaseEncoding)
+			err = sp.server.pushTxMsg(sp, iv, c, waitChan, wire.BaseEncoding)
 		case wire.InvTypeWitnessBlock:
 			err = sp.server.pushBlockMsg(sp, &iv.Hash, c, waitChan, wire.WitnessEncoding)
 		case wire.InvTypeBlock:
 			err = sp.server.pushBlockMsg(sp, &iv.Hash, c, waitChan, wire.BaseEncoding)
+		case wire.InvTypeCmpctBlock:
+			err = sp.server.pushCmpctBlockMsg(sp, &iv.Hash, c, waitChan)
 		case wire.InvTypeFilteredWitnessBlock:
 			err = sp.server.pushMerkleBlockMsg(sp, &iv.Hash, c, waitChan, wire.WitnessEncoding)
 		case wire.InvTypeFilteredBlock:
@@ -803,8 +1113,11 @@ func (sp *serverPeer) OnGetBlocks(_ *peer.Peer, msg *wire.MsgGetBlocks) {
 // OnGetHeaders is invoked when a peer receives a getheaders bitcoin
 // message.
 func (sp *serverPeer) OnGetHeaders(_ *peer.Peer, msg *wire.MsgGetHeaders) {
-	// Ignore getheaders requests if not in sync.
-	if !sp.server.syncManager.IsCurrent() {
+	// Ignore getheaders requests if not in sync unless the peer was
+	// granted the download permission.
+	if !sp.server.syncManager.IsCurrent() &&
+		!sp.hasPermission(permDownload) {
+
 		return
 	}
 
@@ -1158,7 +1471,7 @@ func (sp *serverPeer) OnGetCFCheckpt(_ *peer.Peer, msg *wire.MsgGetCFCheckpt) {
 // version  that is high enough to observe the bloom filter service support bit,
 // it will be banned since it is intentionally violating the protocol.
 func (sp *serverPeer) enforceNodeBloomFlag(cmd string) bool {
-	if sp.server.services&wire.SFNodeBloom != wire.SFNodeBloom {
+	if sp.services()&wire.SFNodeBloom != wire.SFNodeBloom {
 		// Ban the peer if the protocol version is high enough that the
 		// peer is knowingly violating the protocol and banning is
 		// enabled.
@@ -1275,17 +1588,18 @@ func (sp *serverPeer) OnGetAddr(_ *peer.Peer, msg *wire.MsgGetAddr) {
 		return
 	}
 
-	// Do not accept getaddr requests from outbound peers.  This reduces
-	// fingerprinting attacks.
-	if !sp.Inbound() {
+	// Do not accept getaddr requests from outbound peers unless they were
+	// granted the addr permission.  This reduces fingerprinting attacks.
+	if !sp.Inbound() && !sp.hasPermission(permAddr) {
 		peerLog.Debugf("Ignoring getaddr request from outbound peer "+
 			"%v", sp)
 		return
 	}
 
 	// Only allow one getaddr request per connection to discourage
-	// address stamping of inv announcements.
-	if sp.sentAddrs {
+	// address stamping of inv announcements unless the peer was granted
+	// the addr permission.
+	if sp.sentAddrs && !sp.hasPermission(permAddr) {
 		peerLog.Debugf("Ignoring repeated getaddr request from peer "+
 			"%v", sp)
 		return
@@ -1310,6 +1624,12 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 		return
 	}
 
+	// Ignore addresses from block-relay-only peers since address relay is
+	// disabled for them.
+	if sp.blockRelayOnly {
+		return
+	}
+
 	// Ignore old style addresses which don't include a timestamp.
 	if sp.ProtocolVersion() < wire.NetAddressTimeVersion {
 		return
@@ -1359,8 +1679,9 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 // OnAddrV2 is invoked when a peer receives an addrv2 bitcoin message and is
 // used to notify the server about advertised addresses.
 func (sp *serverPeer) OnAddrV2(_ *peer.Peer, msg *wire.MsgAddrV2) {
-	// Ignore if simnet for the same reasons as the regular addr message.
-	if cfg.SimNet {
+	// Ignore if simnet or a block-relay-only peer for the same reasons as
+	// the regular addr message.
+	if cfg.SimNet || sp.blockRelayOnly {
 		return
 	}
 
@@ -1420,10 +1741,14 @@ func (sp *serverPeer) OnNotFound(p *peer.Peer, msg *wire.MsgNotFound) {
 			numBlocks++
 		case wire.InvTypeWitnessBlock:
 			numBlocks++
+		case wire.InvTypeCmpctBlock:
+			numBlocks++
 		case wire.InvTypeTx:
 			numTxns++
 		case wire.InvTypeWitnessTx:
 			numTxns++
+		case wire.InvTypeWTx:
+			numTxns++
 		default:
 			peerLog.Debugf("Invalid inv type '%d' in notfound message from %s",
 				inv.Type, sp)
@@ -1526,18 +1851,73 @@ func (s *server) TransactionConfirmed(tx *btcutil.Tx) {
 	s.RemoveRebroadcastInventory(iv)
 }
 
-// pushTxMsg sends a tx message for the provided transaction hash to the
-// connected peer.  An error is returned if the transaction hash is not known.
-func (s *server) pushTxMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<- struct{},
+// TransactionAccepted is invoked by the mempool whenever a transaction is
+// added to the pool or its fee delta is changed.  The transaction is passed on
+// to the block template manager so it is considered for future templates.
+func (s *server) TransactionAccepted(txDesc *mempool.TxDesc) {
+	s.templateManager.TxAdded(&txDesc.TxDesc)
+}
+
+// TransactionRemoved is invoked by the mempool whenever a transaction is
+// removed from the pool.  The transaction is no longer rebroadcast since it
+// can't be served to peers anymore, and websocket clients are notified about
+// the removal.
+func (s *server) TransactionRemoved(removal *mempool.TxRemoval) {
+	srvrLog.Debugf("Transaction %v removed from the mempool (reason: %v)",
+		removal.Tx.Hash(), removal.Reason)
+
+	s.templateManager.TxRemoved(removal.Tx.Hash())
+
+	// Replaced transactions might still be included in blocks by miners
+	// which did not see the replacement, so keep them for compact block
+	// reconstruction.
+	if removal.Reason == mempool.RemovalReasonReplaced {
+		s.syncManager.AddExtraTxn(removal.Tx)
+	}
+
+	// Rebroadcasting and notifications are only necessary when the RPC
+	// server is active.
+	if s.rpcServer == nil {
+		return
+	}
+
+	iv := wire.NewInvVect(wire.InvTypeTx, removal.Tx.Hash())
+	s.RemoveRebroadcastInventory(iv)
+	s.rpcServer.NotifyTxRemoved(removal)
+}
+
+// BlockTemplateChanged is invoked by the block template manager whenever the
+// transactions that would be included in a new block template change.
+// Websocket clients are notified about the new template.
+func (s *server) BlockTemplateChanged(template *mining.BlockTemplate) {
+	// Notifications are only necessary when the RPC server is active.
+	if s.rpcServer == nil {
+		return
+	}
+
+	s.rpcServer.NotifyBlockTemplate(template)
+}
+
+// pushTxMsg sends a tx message for the transaction requested by the provided
+// inventory vector to the connected peer.  Transactions requested with MSG_WTX
+// inventory vectors are looked up by their witness hash.  An error is
+// returned if the transaction is not known.
+func (s *server) pushTxMsg(sp *serverPeer, iv *wire.InvVect, doneChan chan<- struct{},
 	waitChan <-chan struct{}, encoding wire.MessageEncoding) error {
 
 	// Attempt to fetch the requested transaction from the pool.  A
 	// call could be made to check for existence first, but simply trying
 	// to fetch a missing transaction results in the same behavior.
-	tx, err := s.txMemPool.FetchTransaction(hash)
+	var tx *btcutil.Tx
+	var err error
+	if iv.Type == wire.InvTypeWTx {
+		tx, err = s.txMemPool.FetchTransactionByWitnessHash(&iv.Hash)
+	} else {
+		tx, err = s.txMemPool.FetchTransaction(&iv.Hash)
+	}
 	if err != nil {
 		peerLog.Tracef("Unable to fetch tx %v from transaction "+
-			"pool: %v", hash, err)
+			"pool: %v", iv.Hash, err)
 
 		if doneChan != nil {
 			doneChan <- struct{}{}
@@ -1555,11 +1935,51 @@ func (s *server) pushTxMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<-
 	return nil
 }
 
+// errUploadTargetReached is returned when a requested block is not served
+// since the upload target is close to being reached.
+var errUploadTargetReached = errors.New("upload target reached")
+
+// uploadTargetLimited returns whether the block with the provided hash must
+// not be served to the peer since the upload target is close to being reached.
+// Such blocks are filtered blocks and blocks older than a week, which are only
+// still served to peers with the download permission.  The peer is
+// disconnected when the block is not served.
+func (s *server) uploadTargetLimited(sp *serverPeer, hash *chainhash.Hash,
+	filtered bool) bool {
+
+	if sp.hasPermission(permDownload) || !s.uploadTarget.Reached(true) {
+		return false
+	}
+	if !filtered {
+		header, err := s.chain.HeaderByHash(hash)
+		if err != nil {
+			return false
+		}
+		cutoff := s.timeSource.AdjustedTime().Add(-historicalBlockAge)
+		if !header.Timestamp.Before(cutoff) {
+			return false
+		}
+	}
+
+	peerLog.Infof("Upload target reached, disconnecting peer %s which "+
+		"requested historical block %v", sp, hash)
+	sp.Disconnect()
+	return true
+}
+
 // pushBlockMsg sends a block message for the provided block hash to the
-// connected peer.  An error is returned if the block hash is not known.
+// connected peer.  An error is returned if the block hash is not known or the
+// block is not served due to the upload target.
 func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<- struct{},
 	waitChan <-chan struct{}, encoding wire.MessageEncoding) error {
 
+	if s.uploadTargetLimited(sp, hash, false) {
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return errUploadTargetReached
+	}
+
 	// Fetch the raw block bytes from the database.
 	var blockBytes []byte
 	err := sp.server.db.View(func(dbTx database.Tx) error {
@@ -1621,13 +2041,65 @@ func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan cha
 	return nil
 }
 
+// pushCmpctBlockMsg sends a cmpctblock message for the provided block hash to
+// the connected peer.  Blocks which are too deep in the main chain for their
+// transactions to still be in the memory pool of the peer, as well as blocks
+// which are not in the main chain, are sent in full instead.  An error is
+// returned if the block hash is not known.
+func (s *server) pushCmpctBlockMsg(sp *serverPeer, hash *chainhash.Hash,
+	doneChan chan<- struct{}, waitChan <-chan struct{}) error {
+
+	height, err := s.chain.BlockHeightByHash(hash)
+	if err != nil || s.chain.BestSnapshot().Height-height >= maxCmpctBlockDepth {
+		return s.pushBlockMsg(sp, hash, doneChan, waitChan,
+			wire.WitnessEncoding)
+	}
+
+	blk, err := s.chain.BlockByHash(hash)
+	if err != nil {
+		peerLog.Tracef("Unable to fetch requested block hash %v: %v",
+			hash, err)
+
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return err
+	}
+	nonce, err := wire.RandomUint64()
+	if err != nil {
+		peerLog.Errorf("Failed to generate compact block nonce: %v", err)
+
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return err
+	}
+
+	// Once we have fetched data wait for any previous operation to finish.
+	if waitChan != nil {
+		<-waitChan
+	}
+
+	msg := wire.NewMsgCmpctBlockFromBlock(blk.MsgBlock(), nonce)
+	sp.QueueMessageWithEncoding(msg, doneChan, wire.WitnessEncoding)
+	return nil
+}
+
 // pushMerkleBlockMsg sends a merkleblock message for the provided block hash to
 // the connected peer.  Since a merkle block requires the peer to have a filter
 // loaded, this call will simply be ignored if there is no filter loaded.  An
-// error is returned if the block hash is not known.
+// error is returned if the block hash is not known or the block is not served
+// due to the upload target.
 func (s *server) pushMerkleBlockMsg(sp *serverPeer, hash *chainhash.Hash,
 	doneChan chan<- struct{}, waitChan <-chan struct{}, encoding wire.MessageEncoding) error {
 
+	if s.uploadTargetLimited(sp, hash, true) {
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return errUploadTargetReached
+	}
+
 	// Do not send a response if the peer doesn't have a filter loaded.
 	if !sp.filter.IsLoaded() {
 		if doneChan != nil {
@@ -1737,22 +2209,31 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		sp.Disconnect()
 		return false
 	}
-	if banEnd, ok := state.banned[host]; ok {
-		if time.Now().Before(banEnd) {
+	if ip := net.ParseIP(host); ip != nil && !sp.hasPermission(permNoBan) {
+		if banEnd, ok := s.banList.IsBanned(ip); ok {
 			srvrLog.Debugf("Peer %s is banned for another %v - disconnecting",
 				host, time.Until(banEnd))
 			sp.Disconnect()
 			return false
 		}
+	}
 
-		srvrLog.Infof("Peer %s is no longer banned", host)
-		delete(state.banned, host)
+	// Feeler connections only test whether the address is reachable, so
+	// mark it as good and disconnect now that the handshake succeeded.
+	if sp.feeler {
+		srvrLog.Debugf("Feeler connection to %s succeeded", sp)
+		s.addrManager.Good(sp.NA())
+		sp.Disconnect()
+		return false
 	}
 
 	// TODO: Check for max peers from a single IP.
 
-	// Limit max number of total peers.
-	if state.Count() >= cfg.MaxPeers {
+	// Limit max number of total peers.  New inbound peers make room for
+	// themselves by evicting another inbound peer if possible.
+	if state.Count() >= cfg.MaxPeers &&
+		(!sp.Inbound() || !s.evictInboundPeer(state, sp)) {
+
 		srvrLog.Infof("Max peers reached [%d] - disconnecting peer %s",
 			cfg.MaxPeers, sp)
 		sp.Disconnect()
@@ -1787,12 +2268,15 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	// remote peer for outbound connections. This is skipped when running on
 	// the simulation test network since it is only intended to connect to
 	// specified peers and actively avoids advertising and connecting to
-	// discovered peers.
+	// discovered peers.  Addresses are not exchanged with block-relay-only
+	// peers.
 	if !cfg.SimNet && !sp.Inbound() {
 		// Advertise the local address when the server accepts incoming
 		// connections and it believes itself to be close to the best
 		// known tip.
-		if !cfg.DisableListen && s.syncManager.IsCurrent() {
+		if !cfg.DisableListen && !sp.blockRelayOnly &&
+			s.syncManager.IsCurrent() {
+
 			// Get address that best matches.
 			lna := s.addrManager.GetBestLocalAddress(sp.NA())
 			if addrmgr.IsRoutable(lna) {
@@ -1806,7 +2290,9 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		// more and the peer has a protocol version new enough to
 		// include a timestamp with addresses.
 		hasTimestamp := sp.ProtocolVersion() >= wire.NetAddressTimeVersion
-		if s.addrManager.NeedMoreAddresses() && hasTimestamp {
+		if s.addrManager.NeedMoreAddresses() && hasTimestamp &&
+			!sp.blockRelayOnly {
+
 			sp.QueueMessage(wire.NewMsgGetAddr(), nil)
 		}
 
@@ -1817,6 +2303,34 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	return true
 }
 
+// evictInboundPeer disconnects an inbound peer to make room for the passed new
+// inbound peer.  Peers with the noban permission are never evicted.  It
+// returns false when all inbound peers are protected from eviction.  It is
+// invoked from the peerHandler goroutine.
+func (s *server) evictInboundPeer(state *peerState, newPeer *serverPeer) bool {
+	candidates := make([]*evictionCandidate, 0, len(state.inboundPeers))
+	for _, sp := range state.inboundPeers {
+		// Skip peers which are already disconnecting.
+		if sp.hasPermission(permNoBan) || !sp.Connected() {
+			continue
+		}
+		candidates = append(candidates,
+			newEvictionCandidate(sp, &s.evictionKey))
+	}
+
+	c := selectPeerToEvict(candidates)
+	if c == nil {
+		srvrLog.Debugf("No inbound peer to evict for %s - all %d "+
+			"candidates are protected", newPeer, len(candidates))
+		return false
+	}
+
+	srvrLog.Infof("Evicting inbound peer %s from network group %s to "+
+		"make room for %s", c.addr, c.netGroup, newPeer)
+	state.inboundPeers[c.id].Disconnect()
+	return true
+}
+
 // handleDonePeerMsg deals with peers that have signalled they are done.  It is
 // invoked from the peerHandler goroutine.
 func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
@@ -1832,12 +2346,33 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 	// Regardless of whether the peer was found in our list, we'll inform
 	// our connection manager about the disconnection. This can happen if we
 	// process a peer's `done` message before its `add`.
+	// Feeler connections are neither retried nor replaced.
+	if sp.feeler {
+		s.connManager.Remove(sp.connReq.ID())
+		return
+	}
 	if !sp.Inbound() {
+		// Outbound peers which turned out to only support the v1
+		// transport during the v2 transport handshake are reconnected
+		// to using it instead.  The connection manager retries
+		// persistent peers by itself, while the new connection request
+		// replacing any other peer is handed its address.
+		v1Retry := sp.ReconnectV1()
+		if v1Retry {
+			srvrLog.Debugf("Retrying peer %s with the v1 transport", sp)
+			s.v1Fallbacks.Add(sp.connReq.Addr.String())
+		}
+
 		if sp.persistent {
 			s.connManager.Disconnect(sp.connReq.ID())
 		} else {
 			s.connManager.Remove(sp.connReq.ID())
-			go s.connManager.NewConnReq()
+			if v1Retry {
+				s.v1RetriesMtx.Lock()
+				s.v1Retries = append(s.v1Retries, sp.connReq.Addr)
+				s.v1RetriesMtx.Unlock()
+			}
+			go s.newConnReq(sp.blockRelayOnly)
 		}
 	}
 
@@ -1859,40 +2394,82 @@ func (s *server) handleBanPeerMsg(state *peerState, sp *serverPeer) {
 		srvrLog.Debugf("can't split ban peer %s %v", sp.Addr(), err)
 		return
 	}
+	ipnet, err := parseSubnet(host)
+	if err != nil {
+		srvrLog.Debugf("can't ban peer %s: %v", sp.Addr(), err)
+		return
+	}
 	direction := directionString(sp.Inbound())
 	srvrLog.Infof("Banned peer %s (%s) for %v", host, direction,
 		cfg.BanDuration)
-	state.banned[host] = time.Now().Add(cfg.BanDuration)
+	err = s.banList.Ban(ipnet, time.Now().Add(cfg.BanDuration),
+		banReasonMisbehaving)
+	if err != nil {
+		srvrLog.Errorf("Unable to save ban list: %v", err)
+	}
 }
 
 // handleRelayInvMsg deals with relaying inventory to peers that are not already
 // known to have it.  It is invoked from the peerHandler goroutine.
 func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
+	// The compact block is only created once a peer wants it and then
+	// shared by all peers.
+	var cmpctBlock *wire.MsgCmpctBlock
+
 	state.forAllPeers(func(sp *serverPeer) {
 		if !sp.Connected() {
 			return
 		}
 
-		// If the inventory is a block and the peer prefers headers,
-		// generate and send a headers message instead of an inventory
-		// message.
-		if msg.invVect.Type == wire.InvTypeBlock && sp.WantsHeaders() {
-			blockHeader, ok := msg.data.(wire.BlockHeader)
+		if msg.invVect.Type == wire.InvTypeBlock {
+			block, ok := msg.data.(*btcutil.Block)
 			if !ok {
-				peerLog.Warnf("Underlying data for headers" +
-					" is not a block header")
+				peerLog.Warnf("Underlying data for block inv "+
+					"relay is not a *btcutil.Block: %T",
+					msg.data)
 				return
 			}
-			msgHeaders := wire.NewMsgHeaders()
-			if err := msgHeaders.AddBlockHeader(&blockHeader); err != nil {
-				peerLog.Errorf("Failed to add block"+
-					" header: %v", err)
+
+			// If the peer requested new blocks to be announced
+			// with compact blocks, send it a cmpctblock message
+			// right away unless it already knows the block.
+			if sp.WantsCmpctBlocks() {
+				if sp.IsKnownInventory(msg.invVect) {
+					return
+				}
+				if cmpctBlock == nil {
+					nonce, err := wire.RandomUint64()
+					if err != nil {
+						peerLog.Errorf("Failed to generate "+
+							"compact block nonce: %v", err)
+						return
+					}
+					cmpctBlock = wire.NewMsgCmpctBlockFromBlock(
+						block.MsgBlock(), nonce)
+				}
+				sp.AddKnownInventory(msg.invVect)
+				sp.QueueMessageWithEncoding(cmpctBlock, nil,
+					wire.WitnessEncoding)
+				return
+			}
+
+			// If the peer prefers headers, generate and send a
+			// headers message instead of an inventory message.
+			if sp.WantsHeaders() {
+				msgHeaders := wire.NewMsgHeaders()
+				err := msgHeaders.AddBlockHeader(
+					&block.MsgBlock().Header)
+				if err != nil {
+					peerLog.Errorf("Failed to add block"+
+						" header: %v", err)
+					return
+				}
+				sp.QueueMessage(msgHeaders, nil)
 				return
 			}
-			sp.QueueMessage(msgHeaders, nil)
-			return
 		}
 
+		invVect := msg.invVect
 		if msg.invVect.Type == wire.InvTypeTx {
 			// Don't relay the transaction to the peer when it has
 			// transaction relaying disabled.
@@ -1922,12 +2499,26 @@ func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
 					return
 				}
 			}
+
+			// Announce the transaction by its witness hash to
+			// peers which negotiated wtxid relay.
+			invVect = sp.txInvVect(txD.Tx)
+
+			// Add the transaction to the reconciliation set of
+			// peers relaying transactions through set
+			// reconciliation instead of announcing it right away.
+			// It is only flooded when the set can't hold it.
+			if sp.txRecon != nil && !sp.IsKnownInventory(invVect) &&
+				sp.txRecon.Add(txD.Tx.WitnessHash()) {
+
+				return
+			}
 		}
 
 		// Queue the inventory to be relayed with the next batch.
 		// It will be ignored if the peer is already known to
 		// have the inventory.
-		sp.QueueInventory(msg.invVect)
+		sp.QueueInventory(invVect)
 	})
 }
 
@@ -2120,6 +2711,11 @@ func disconnectPeer(peerList map[int32]*serverPeer, compareFunc func(*serverPeer
 
 // newPeerConfig returns the configuration for the given serverPeer.
 func newPeerConfig(sp *serverPeer) *peer.Config {
+	// Transaction relaying is disabled with --blocksonly unless the peer
+	// was granted the relay permission.
+	disableRelayTx := (cfg.BlocksOnly && !sp.hasPermission(permRelay)) ||
+		sp.blockRelayOnly || sp.feeler
+
 	return &peer.Config{
 		Listeners: peer.MessageListeners{
 			OnVersion:      sp.OnVersion,
@@ -2127,6 +2723,12 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 			OnMemPool:      sp.OnMemPool,
 			OnTx:           sp.OnTx,
 			OnBlock:        sp.OnBlock,
+			OnCmpctBlock:   sp.OnCmpctBlock,
+			OnGetBlockTxn:  sp.OnGetBlockTxn,
+			OnBlockTxn:     sp.OnBlockTxn,
+			OnReqRecon:     sp.OnReqRecon,
+			OnSketch:       sp.OnSketch,
+			OnReconcilDiff: sp.OnReconcilDiff,
 			OnInv:          sp.OnInv,
 			OnHeaders:      sp.OnHeaders,
 			OnGetData:      sp.OnGetData,
@@ -2159,11 +2761,13 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 		UserAgentVersion:    userAgentVersion,
 		UserAgentComments:   cfg.UserAgentComments,
 		ChainParams:         sp.server.chainParams,
-		Services:            sp.server.services,
-		DisableRelayTx:      cfg.BlocksOnly,
+		Services:            sp.services(),
+		DisableRelayTx:      disableRelayTx,
 		ProtocolVersion:     peer.MaxProtocolVersion,
 		TrickleInterval:     cfg.TrickleInterval,
 		DisableStallHandler: cfg.DisableStallHandler,
+		TxReconciliation:    cfg.TxReconciliation,
+		V2Transport:         cfg.V2Transport && !sp.disableV2,
 	}
 }
 
@@ -2173,7 +2777,7 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 // for disconnection.
 func (s *server) inboundPeerConnected(conn net.Conn) {
 	sp := newServerPeer(s, false)
-	sp.isWhitelisted = isWhitelisted(conn.RemoteAddr())
+	sp.permissions = connPermissions(conn)
 	sp.Peer = peer.NewInboundPeer(newPeerConfig(sp))
 	sp.AssociateConnection(conn)
 	go s.peerDoneHandler(sp)
@@ -2186,6 +2790,10 @@ func (s *server) inboundPeerConnected(conn net.Conn) {
 // manager of the attempt.
 func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	sp := newServerPeer(s, c.Permanent)
+	sp.blockRelayOnly = c.BlockRelayOnly
+	sp.feeler = c.Feeler
+	sp.disableV2 = s.v1Fallbacks.Contains(c.Addr.String())
+	sp.permissions = whitelistPermissions(conn.RemoteAddr())
 	p, err := peer.NewOutboundPeer(newPeerConfig(sp), c.Addr.String())
 	if err != nil {
 		srvrLog.Debugf("Cannot create outbound peer %s: %v", c.Addr, err)
@@ -2193,17 +2801,44 @@ func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 			s.connManager.Disconnect(c.ID())
 		} else {
 			s.connManager.Remove(c.ID())
-			go s.connManager.NewConnReq()
+			if !c.Feeler {
+				go s.newConnReq(c.BlockRelayOnly)
+			}
 		}
 		return
 	}
 	sp.Peer = p
 	sp.connReq = c
-	sp.isWhitelisted = isWhitelisted(conn.RemoteAddr())
 	sp.AssociateConnection(conn)
 	go s.peerDoneHandler(sp)
 }
 
+// newConnReq requests a new outbound connection to replace a lost one of the
+// same kind.
+func (s *server) newConnReq(blockRelayOnly bool) {
+	if blockRelayOnly {
+		s.connManager.NewBlockRelayOnlyConnReq()
+		return
+	}
+	s.connManager.NewConnReq()
+}
+
+// popV1Retry returns the address of the next outbound peer to reconnect to
+// using the v1 transport, if any.
+//
+// This function is safe for concurrent access.
+func (s *server) popV1Retry() net.Addr {
+	s.v1RetriesMtx.Lock()
+	defer s.v1RetriesMtx.Unlock()
+
+	if len(s.v1Retries) == 0 {
+		return nil
+	}
+	addr := s.v1Retries[0]
+	s.v1Retries = s.v1Retries[1:]
+	return addr
+}
+
 // peerDoneHandler handles peer disconnects by notifiying the server that it's
 // done along with other performing other desirable cleanup.
 func (s *server) peerDoneHandler(sp *serverPeer) {
@@ -2243,7 +2878,6 @@ func (s *server) peerHandler() {
 		inboundPeers:    make(map[int32]*serverPeer),
 		persistentPeers: make(map[int32]*serverPeer),
 		outboundPeers:   make(map[int32]*serverPeer),
-		banned:          make(map[string]time.Time),
 		outboundGroups:  make(map[string]int),
 	}
 
@@ -2293,6 +2927,10 @@ out:
 			s.handleQuery(state, qmsg)
 
 		case <-s.quit:
+			// Save the block-relay-only peers as anchors to
+			// reconnect to them first on the next startup.
+			s.saveAnchors(state)
+
 			// Disconnect all peers on server shutdown.
 			state.forAllPeers(func(sp *serverPeer) {
 				srvrLog.Tracef("Shutdown peer %s", sp)
@@ -2325,6 +2963,30 @@ cleanup:
 	srvrLog.Tracef("Peer handler done")
 }
 
+// saveAnchors saves the addresses of the connected block-relay-only peers to
+// the anchors file.  It is invoked from the peerHandler goroutine on shutdown.
+func (s *server) saveAnchors(state *peerState) {
+	var anchors []string
+	for _, sp := range state.outboundPeers {
+		if sp.blockRelayOnly && sp.Connected() {
+			anchors = append(anchors, sp.Addr())
+		}
+	}
+	if len(anchors) == 0 {
+		return
+	}
+
+	anchorsFile := filepath.Join(cfg.DataDir, anchorsFilename)
+	if err := writeAnchors(anchorsFile, anchors); err != nil {
+		srvrLog.Errorf("Unable to save anchors to %s: %v", anchorsFile,
+			err)
+		return
+	}
+	numAnchors := uint64(len(anchors))
+	srvrLog.Infof("Saved %d %s to %s", numAnchors,
+		pickNoun(numAnchors, "anchor", "anchors"), anchorsFile)
+}
+
 // AddPeer adds a new peer that has already been connected to the server.
 func (s *server) AddPeer(sp *serverPeer) {
 	s.newPeers <- sp
@@ -2371,6 +3033,7 @@ func (s *server) OutboundGroupCount(key string) int {
 // for the server.  It is safe for concurrent access.
 func (s *server) AddBytesSent(bytesSent uint64) {
 	atomic.AddUint64(&s.bytesSent, bytesSent)
+	s.uploadTarget.AddBytes(bytesSent)
 }
 
 // AddBytesReceived adds the passed number of bytes to the total bytes received
@@ -2454,6 +3117,27 @@ cleanup:
 	s.wg.Done()
 }
 
+// mempoolExpiryHandler periodically evicts transactions that have been in the
+// mempool for longer than the configured expiry timeout.  It must be run as a
+// goroutine.
+func (s *server) mempoolExpiryHandler() {
+	ticker := time.NewTicker(mempoolExpiryScanInterval)
+	defer ticker.Stop()
+
+out:
+	for {
+		select {
+		case <-ticker.C:
+			s.txMemPool.ExpireTransactions()
+
+		case <-s.quit:
+			break out
+		}
+	}
+
+	s.wg.Done()
+}
+
 // Start begins accepting connections from peers.
 func (s *server) Start() {
 	// Already started?
@@ -2476,6 +3160,13 @@ func (s *server) Start() {
 		go s.upnpUpdateThread()
 	}
 
+	// Start the mempool expiry handler if transactions are configured to
+	// expire.
+	if cfg.MempoolExpiry > 0 {
+		s.wg.Add(1)
+		go s.mempoolExpiryHandler()
+	}
+
 	if !cfg.DisableRPC {
 		s.wg.Add(1)
 
@@ -2486,10 +3177,18 @@ func (s *server) Start() {
 		s.rpcServer.Start()
 	}
 
+	// Start delivering block template changes to websocket clients.
+	s.templateManager.Start()
+
 	// Start the CPU miner if generation is enabled.
 	if cfg.Generate {
 		s.cpuMiner.Start()
 	}
+
+	// Start the stratum server if it is enabled.
+	if s.stratumServer != nil {
+		s.stratumServer.Start()
+	}
 }
 
 // Stop gracefully shuts down the server by stopping and disconnecting all
@@ -2506,6 +3205,14 @@ func (s *server) Stop() error {
 	// Stop the CPU miner if needed
 	s.cpuMiner.Stop()
 
+	// Stop delivering block template changes.
+	s.templateManager.Stop()
+
+	// Stop the stratum server if needed.
+	if s.stratumServer != nil {
+		s.stratumServer.Stop()
+	}
+
 	// Shutdown the RPC server if it's not disabled.
 	if !cfg.DisableRPC {
 		s.rpcServer.Stop()
@@ -2716,6 +3423,28 @@ func setupRPCListeners() ([]net.Listener, error) {
 	return listeners, nil
 }
 
+// setupStratumListeners returns a slice of listeners that are configured for
+// use with the stratum server depending on the configuration settings for
+// listen addresses.
+func setupStratumListeners() ([]net.Listener, error) {
+	netAddrs, err := parseListeners(cfg.StratumListeners)
+	if err != nil {
+		return nil, err
+	}
+
+	listeners := make([]net.Listener, 0, len(netAddrs))
+	for _, addr := range netAddrs {
+		listener, err := net.Listen(addr.Network(), addr.String())
+		if err != nil {
+			minrLog.Warnf("Can't listen on %s: %v", addr, err)
+			continue
+		}
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, nil
+}
+
 // newServer returns a new btcd server configured to listen on addr for the
 // bitcoin network type specified by chainParams.  Use start to begin accepting
 // connections from peers.
@@ -2730,6 +3459,9 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 	if cfg.NoCFilters {
 		services &^= wire.SFNodeCF
 	}
+	if cfg.V2Transport {
+		services |= wire.SFNodeP2PV2
+	}
 
 	amgr := addrmgr.New(cfg.DataDir, btcdLookup)
 
@@ -2737,7 +3469,8 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 	var nat NAT
 	if !cfg.DisableListen {
 		var err error
-		listeners, nat, err = initListeners(amgr, listenAddrs, services)
+		listeners, nat, err = initListeners(amgr, listenAddrs,
+			cfg.whitebinds, services)
 		if err != nil {
 			return nil, err
 		}
@@ -2774,6 +3507,23 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		cfCheckptCaches:      make(map[wire.FilterType][]cfHeaderKV),
 		agentBlacklist:       agentBlacklist,
 		agentWhitelist:       agentWhitelist,
+		v1Fallbacks:          lru.NewCache(1000),
+		uploadTarget:         newUploadTarget(cfg.MaxUploadTarget * 1024 * 1024),
+	}
+	if _, err := rand.Read(s.evictionKey[:]); err != nil {
+		return nil, err
+	}
+
+	// Load the bans saved by previous runs.
+	banlistFile := filepath.Join(cfg.DataDir, banlistFilename)
+	s.banList = newBanList(banlistFile)
+	if numBans, err := s.banList.load(); err != nil {
+		srvrLog.Warnf("Unable to load ban list from %s: %v", banlistFile,
+			err)
+	} else if numBans > 0 {
+		srvrLog.Infof("Loaded %d banned %s from %s", numBans,
+			pickNoun(uint64(numBans), "IP or subnet", "IPs or subnets"),
+			banlistFile)
 	}
 
 	// Create the transaction and address indexes if needed.
@@ -2872,11 +3622,14 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 			AcceptNonStd:         cfg.RelayNonStd,
 			FreeTxRelayLimit:     cfg.FreeTxRelayLimit,
 			MaxOrphanTxs:         cfg.MaxOrphanTxs,
+			MaxOrphanTxsPerTag:   cfg.MaxOrphanTxsPerPeer,
 			MaxOrphanTxSize:      defaultMaxOrphanTxSize,
 			MaxSigOpCostPerTx:    blockchain.MaxBlockSigOpsCost / 4,
 			MinRelayTxFee:        cfg.minRelayTxFee,
-			MaxTxVersion:         2,
+			MaxTxVersion:         mempool.TRUCVersion,
 			RejectReplacement:    cfg.RejectReplacement,
+			FullRBF:              cfg.MempoolFullRBF,
+			Expiry:               time.Duration(cfg.MempoolExpiry) * time.Hour,
 		},
 		ChainParams:    chainParams,
 		FetchUtxoView:  s.chain.FetchUtxoView,
@@ -2890,6 +3643,8 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		HashCache:          s.hashCache,
 		AddrIndex:          s.addrIndex,
 		FeeEstimator:       s.feeEstimator,
+		TxRemoved:          s.TransactionRemoved,
+		TxAccepted:         s.TransactionAccepted,
 	}
 	s.txMemPool = mempool.New(&txC)
 
@@ -2918,10 +3673,14 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		BlockMaxSize:      cfg.BlockMaxSize,
 		BlockPrioritySize: cfg.BlockPrioritySize,
 		TxMinFreeFee:      cfg.minRelayTxFee,
+		CoinbaseTag:       cfg.CoinbaseTag,
+		CoinbasePayouts:   cfg.coinbasePayouts,
 	}
 	blockTemplateGenerator := mining.NewBlkTmplGenerator(&policy,
 		s.chainParams, s.txMemPool, s.chain, s.timeSource,
 		s.sigCache, s.hashCache)
+	s.templateManager = mining.NewTemplateManager(blockTemplateGenerator,
+		s.BlockTemplateChanged)
 	s.cpuMiner = cpuminer.New(&cpuminer.Config{
 		ChainParams:            chainParams,
 		BlockTemplateGenerator: blockTemplateGenerator,
@@ -2931,6 +3690,27 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		IsCurrent:              s.syncManager.IsCurrent,
 	})
 
+	if len(cfg.StratumListeners) > 0 {
+		stratumListeners, err := setupStratumListeners()
+		if err != nil {
+			return nil, err
+		}
+		if len(stratumListeners) == 0 {
+			return nil, errors.New("MINR: No valid stratum listen " +
+				"address")
+		}
+
+		s.stratumServer = stratum.New(&stratum.Config{
+			ChainParams:            chainParams,
+			BlockTemplateGenerator: blockTemplateGenerator,
+			MiningAddrs:            cfg.miningAddrs,
+			ProcessBlock:           s.syncManager.ProcessBlock,
+			Listeners:              stratumListeners,
+			Difficulty:             cfg.StratumDifficulty,
+			ShareInterval:          cfg.StratumShareInterval,
+		})
+	}
+
 	// Only setup a function to return new addresses to connect to when
 	// not running in connect-only mode.  The simulation network is always
 	// in connect-only mode since it is only intended to connect to
@@ -2940,6 +3720,10 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 	var newAddressFunc func() (net.Addr, error)
 	if !cfg.SimNet && len(cfg.ConnectPeers) == 0 {
 		newAddressFunc = func() (net.Addr, error) {
+			if addr := s.popV1Retry(); addr != nil {
+				return addr, nil
+			}
+
 			for tries := 0; tries < 100; tries++ {
 				addr := s.addrManager.GetAddress()
 				if addr == nil {
@@ -2980,19 +3764,85 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		}
 	}
 
-	// Create a connection manager.
+	// Periodically test addresses with feeler connections along with the
+	// discovered peers.  The address which would be evicted by a collision
+	// in the tried table is tested first so the collision can be resolved.
+	// Otherwise, a new address is tested so it can be moved to the tried
+	// table.
+	var feelerAddressFunc func() (net.Addr, error)
+	if newAddressFunc != nil {
+		feelerAddressFunc = func() (net.Addr, error) {
+			s.addrManager.ResolveCollisions()
+
+			addr := s.addrManager.SelectTriedCollision()
+			for tries := 0; tries < 10; tries++ {
+				if addr == nil {
+					addr = s.addrManager.GetNewAddress()
+				}
+				if addr == nil {
+					break
+				}
+
+				// Skip addresses in the same group as an
+				// outbound peer and recently attempted ones.
+				key := addrmgr.GroupKey(addr.NetAddress())
+				if s.OutboundGroupCount(key) != 0 ||
+					time.Since(addr.LastAttempt()) < 10*time.Minute {
+
+					addr = nil
+					continue
+				}
+
+				// Mark an attempt for the valid address.
+				s.addrManager.Attempt(addr.NetAddress())
+
+				addrString := addrmgr.NetAddressKey(addr.NetAddress())
+				return addrStringToNetAddr(addrString)
+			}
+
+			return nil, errors.New("no valid feeler address")
+		}
+	}
+
+	// Create a connection manager.  Block-relay-only peers are maintained
+	// in addition to the other outbound peers as long as there is room for
+	// them and are only used along with discovered peers.  The anchors
+	// saved on the last shutdown are reconnected first.
 	targetOutbound := defaultTargetOutbound
 	if cfg.MaxPeers < targetOutbound {
 		targetOutbound = cfg.MaxPeers
 	}
+	var targetBlockRelayOnly uint32
+	var anchors []net.Addr
+	if newAddressFunc != nil {
+		targetBlockRelayOnly = cfg.BlockRelayPeers
+		room := uint32(cfg.MaxPeers - targetOutbound)
+		if room < targetBlockRelayOnly {
+			targetBlockRelayOnly = room
+		}
+
+		anchorsFile := filepath.Join(cfg.DataDir, anchorsFilename)
+		anchors, err = readAnchors(anchorsFile)
+		if err != nil {
+			srvrLog.Warnf("Unable to read anchors from %s: %v",
+				anchorsFile, err)
+		}
+		if numAnchors := uint64(len(anchors)); numAnchors > 0 {
+			srvrLog.Infof("Reconnecting to %d %s", numAnchors,
+				pickNoun(numAnchors, "anchor", "anchors"))
+		}
+	}
 	cmgr, err := connmgr.New(&connmgr.Config{
-		Listeners:      listeners,
-		OnAccept:       s.inboundPeerConnected,
-		RetryDuration:  connectionRetryInterval,
-		TargetOutbound: uint32(targetOutbound),
-		Dial:           btcdDial,
-		OnConnection:   s.outboundPeerConnected,
-		GetNewAddress:  newAddressFunc,
+		Listeners:            listeners,
+		OnAccept:             s.inboundPeerConnected,
+		RetryDuration:        connectionRetryInterval,
+		TargetOutbound:       uint32(targetOutbound),
+		TargetBlockRelayOnly: targetBlockRelayOnly,
+		Anchors:              anchors,
+		Dial:                 btcdDial,
+		OnConnection:         s.outboundPeerConnected,
+		GetNewAddress:        newAddressFunc,
+		GetFeelerAddress:     feelerAddressFunc,
 	})
 	if err != nil {
 		return nil, err
@@ -3038,7 +3888,9 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 			DB:           db,
 			TxMemPool:    s.txMemPool,
 			Generator:    blockTemplateGenerator,
+			Templates:    s.templateManager,
 			CPUMiner:     s.cpuMiner,
+			Stratum:      s.stratumServer,
 			TxIndex:      s.txIndex,
 			AddrIndex:    s.addrIndex,
 			CfIndex:      s.cfIndex,
@@ -3058,10 +3910,11 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 	return &s, nil
 }
 
-// initListeners initializes the configured net listeners and adds any bound
-// addresses to the address manager. Returns the listeners and a NAT interface,
-// which is non-nil if UPnP is in use.
-func initListeners(amgr *addrmgr.AddrManager, listenAddrs []string, services wire.ServiceFlag) ([]net.Listener, NAT, error) {
+// initListeners initializes the configured net listeners, including the
+// whitebind listeners which grant permissions to the peers connecting through
+// them, and adds any bound addresses to the address manager. Returns the
+// listeners and a NAT interface, which is non-nil if UPnP is in use.
+func initListeners(amgr *addrmgr.AddrManager, listenAddrs []string, whitebinds []whitebind, services wire.ServiceFlag) ([]net.Listener, NAT, error) {
 	// Listen for TCP connections at the configured addresses
 	netAddrs, err := parseListeners(listenAddrs)
 	if err != nil {
@@ -3078,6 +3931,26 @@ func initListeners(amgr *addrmgr.AddrManager, listenAddrs []string, services wir
 		listeners = append(listeners, listener)
 	}
 
+	// Listen for TCP connections at the whitebind addresses, granting the
+	// permissions of the whitebinds to the accepted peers.
+	for _, wb := range whitebinds {
+		wbAddrs, err := parseListeners([]string{wb.addr})
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, addr := range wbAddrs {
+			listener, err := net.Listen(addr.Network(), addr.String())
+			if err != nil {
+				srvrLog.Warnf("Can't listen on %s: %v", addr, err)
+				continue
+			}
+			listeners = append(listeners, &permissionListener{
+				Listener:    listener,
+				permissions: wb.permissions,
+			})
+		}
+	}
+
 	var nat NAT
 	if len(cfg.ExternalIPs) != 0 {
 		defaultPort, err := strconv.ParseUint(activeNetParams.DefaultPort, 10, 16)
@@ -3254,30 +4127,31 @@ func dynamicTickDuration(remaining time.Duration) time.Duration {
 	return time.Hour
 }
 
-// isWhitelisted returns whether the IP address is included in the whitelisted
-// networks and IPs.
-func isWhitelisted(addr net.Addr) bool {
+// whitelistPermissions returns the permissions granted to the IP address by
+// the whitelisted networks and IPs which include it.
+func whitelistPermissions(addr net.Addr) netPermissions {
 	if len(cfg.whitelists) == 0 {
-		return false
+		return 0
 	}
 
 	host, _, err := net.SplitHostPort(addr.String())
 	if err != nil {
 		srvrLog.Warnf("Unable to SplitHostPort on '%s': %v", addr, err)
-		return false
+		return 0
 	}
 	ip := net.ParseIP(host)
 	if ip == nil {
 		srvrLog.Warnf("Unable to parse IP '%s'", addr)
-		return false
+		return 0
 	}
 
-	for _, ipnet := range cfg.whitelists {
-		if ipnet.Contains(ip) {
-			return true
+	var perms netPermissions
+	for _, wl := range cfg.whitelists {
+		if wl.ipnet.Contains(ip) {
+			perms |= wl.permissions
 		}
 	}
-	return false
+	return perms
 }
 
 // checkpointSorter implements sort.Interface to allow a slice of checkpoints to
@@ -729,6 +729,17 @@ func (vm *Engine) verifyWitnessProgram(witness wire.TxWitness) error {
 			vm.SetStack(witness[:len(witness)-2])
 		}
 
+	// Keyless pay-to-anchor outputs are anyone-can-spend by design, so
+	// they're exempt from the upgradeable witness program policy.  Like
+	// any other unknown witness program, the segwit behavior is
+	// de-activated, and the stack is left with a single true element so
+	// the spend also satisfies the clean stack rule.
+	case vm.isWitnessVersionActive(TaprootWitnessVersion) &&
+		bytes.Equal(vm.witnessProgram, payToAnchorProgram) && !vm.bip16:
+
+		vm.witnessProgram = nil
+		vm.SetStack([][]byte{{OP_TRUE}})
+
 	case vm.hasFlag(ScriptVerifyDiscourageUpgradeableWitnessProgram):
 		errStr := fmt.Sprintf("new witness program versions "+
 			"invalid: %v", vm.witnessProgram)
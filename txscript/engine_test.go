@@ -196,6 +196,65 @@ func TestInvalidFlagCombinations(t *testing.T) {
 	}
 }
 
+// TestPayToAnchorSpend ensures keyless pay-to-anchor outputs can be spent with
+// an empty signature script and witness under the standard verification flags
+// while other unknown 2-byte version 1 witness programs remain discouraged.
+func TestPayToAnchorSpend(t *testing.T) {
+	t.Parallel()
+
+	tx := &wire.MsgTx{
+		Version: 3,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Index: 0},
+			Sequence:         wire.MaxTxInSequenceNum,
+		}},
+		TxOut: []*wire.TxOut{{
+			Value:    0,
+			PkScript: []byte{OP_RETURN},
+		}},
+	}
+
+	tests := []struct {
+		name     string
+		pkScript []byte
+		wantErr  ErrorCode
+		valid    bool
+	}{{
+		name:     "pay to anchor",
+		pkScript: PayToAnchorScript(),
+		valid:    true,
+	}, {
+		name:     "unknown 2-byte v1 witness program",
+		pkScript: mustParseShortForm("1 DATA_2 0x4e74"),
+		wantErr:  ErrDiscourageUpgradableWitnessProgram,
+	}}
+
+	for _, test := range tests {
+		prevOuts := NewCannedPrevOutputFetcher(test.pkScript, 240)
+		vm, err := NewEngine(
+			test.pkScript, tx, 0, StandardVerifyFlags, nil, nil,
+			240, prevOuts,
+		)
+		if err != nil {
+			t.Fatalf("%s: failed to create engine: %v", test.name,
+				err)
+		}
+
+		err = vm.Execute()
+		if test.valid {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name,
+					err)
+			}
+			continue
+		}
+		if !IsErrorCode(err, test.wantErr) {
+			t.Errorf("%s: unexpected error: got %v, want %v",
+				test.name, err, test.wantErr)
+		}
+	}
+}
+
 // TestCheckPubKeyEncoding ensures the internal checkPubKeyEncoding function
 // works as expected.
 func TestCheckPubKeyEncoding(t *testing.T) {
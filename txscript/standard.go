@@ -5,6 +5,7 @@
 package txscript
 
 import (
+	"bytes"
 	"fmt"
 
 	"github.com/btcsuite/btcd/btcutil"
@@ -64,6 +65,7 @@ const (
 	NullDataTy                               // Empty data-only (provably prunable).
 	WitnessV1TaprootTy                       // Taproot output
 	WitnessUnknownTy                         // Witness unknown
+	PayToAnchorTy                            // Pay to anchor (P2A)
 )
 
 // scriptClassToName houses the human-readable strings which describe each
@@ -79,6 +81,7 @@ var scriptClassToName = []string{
 	NullDataTy:            "nulldata",
 	WitnessV1TaprootTy:    "witness_v1_taproot",
 	WitnessUnknownTy:      "witness_unknown",
+	PayToAnchorTy:         "anchor",
 }
 
 // String implements the Stringer interface by returning the name of
@@ -402,6 +405,36 @@ func extractWitnessV1KeyBytes(script []byte) []byte {
 	return nil
 }
 
+// payToAnchorProgram is the witness program of the keyless pay-to-anchor
+// output script.
+var payToAnchorProgram = []byte{0x4e, 0x73}
+
+// isPayToAnchorScript returns whether or not the passed script is the
+// standard keyless pay-to-anchor script.
+func isPayToAnchorScript(script []byte) bool {
+	// A pay-to-anchor script is of the form:
+	//   OP_1 OP_DATA_2 0x4e73
+	return len(script) == 4 &&
+		script[0] == OP_1 &&
+		script[1] == OP_DATA_2 &&
+		bytes.Equal(script[2:], payToAnchorProgram)
+}
+
+// IsPayToAnchor returns true if the script is in the standard keyless
+// pay-to-anchor (P2A) format, false otherwise.  Anchor outputs may be spent by
+// anyone with an empty signature script and witness and are typically used to
+// bump the fee of the parent transaction via CPFP.
+func IsPayToAnchor(script []byte) bool {
+	return isPayToAnchorScript(script)
+}
+
+// PayToAnchorScript returns the standard keyless pay-to-anchor (P2A) output
+// script.
+func PayToAnchorScript() []byte {
+	return []byte{OP_1, OP_DATA_2, payToAnchorProgram[0],
+		payToAnchorProgram[1]}
+}
+
 // isWitnessScriptHashScript returns whether or not the passed script is a
 // standard pay-to-witness-script-hash script.
 func isWitnessScriptHashScript(script []byte) bool {
@@ -550,6 +583,8 @@ func typeOfScript(scriptVersion uint16, script []byte) ScriptClass {
 		switch {
 		case isWitnessTaprootScript(script):
 			return WitnessV1TaprootTy
+		case isPayToAnchorScript(script):
+			return PayToAnchorTy
 		}
 	}
 
@@ -1037,6 +1072,12 @@ func ExtractPkScriptAddrs(pkScript []byte,
 		return WitnessV1TaprootTy, addrs, 1, nil
 	}
 
+	// Pay-to-anchor outputs are keyless and therefore have no addresses or
+	// required signatures.
+	if isPayToAnchorScript(pkScript) {
+		return PayToAnchorTy, nil, 0, nil
+	}
+
 	// If none of the above passed, then the address must be non-standard.
 	return NonStandardTy, nil, 0, nil
 }
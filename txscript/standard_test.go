@@ -335,6 +335,13 @@ func TestExtractPkScriptAddrs(t *testing.T) {
 			reqSigs: 1,
 			class:   WitnessV1TaprootTy,
 		},
+		{
+			name:    "pay to anchor",
+			script:  hexToBytes("51024e73"),
+			addrs:   nil,
+			reqSigs: 0,
+			class:   PayToAnchorTy,
+		},
 		{
 			name: "1 of 3 multisig with invalid pubkeys 2",
 			script: hexToBytes("514134633365633235396337346461636" +
@@ -1107,6 +1114,19 @@ var scriptClassTests = []struct {
 		script: "0 DATA_32 0x9f96ade4b41d5433f4eda31e1738ec2b36f6e7d1420d94a6af99801a88f7f7ff",
 		class:  WitnessV0ScriptHashTy,
 	},
+	{
+		// A keyless pay to anchor pk script.
+		name:   "Pay To Anchor",
+		script: "1 DATA_2 0x4e73",
+		class:  PayToAnchorTy,
+	},
+	{
+		// A version 1 witness program with a 2-byte program that is not
+		// the anchor program.
+		name:   "almost pay to anchor",
+		script: "1 DATA_2 0x4e74",
+		class:  NonStandardTy,
+	},
 }
 
 // TestScriptClass ensures all the scripts in scriptClassTests have the expected
@@ -1175,6 +1195,11 @@ func TestStringifyClass(t *testing.T) {
 			class:    NullDataTy,
 			stringed: "nulldata",
 		},
+		{
+			name:     "anchorty",
+			class:    PayToAnchorTy,
+			stringed: "anchor",
+		},
 		{
 			name:     "broken",
 			class:    ScriptClass(255),
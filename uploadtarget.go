@@ -0,0 +1,134 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// uploadTargetTimeframe is the period the upload target applies to.
+	// The bytes sent are counted from the start of a cycle of this length.
+	uploadTargetTimeframe = 24 * time.Hour
+
+	// historicalBlockAge is the age after which a block is considered
+	// historical, so it is no longer served to peers without the download
+	// permission once the upload target is about to be reached.
+	historicalBlockAge = 7 * 24 * time.Hour
+
+	// historicalBlockBuffer is the number of bytes of the upload target
+	// kept for serving recent blocks.  Historical blocks are no longer
+	// served once fewer bytes are left in the current cycle, which leaves
+	// room for a maximum size block every ten minutes.
+	historicalBlockBuffer = wire.MaxBlockPayload *
+		uint64(uploadTargetTimeframe/(10*time.Minute))
+)
+
+// uploadTargetStatus describes the state of the upload target in the current
+// cycle.
+type uploadTargetStatus struct {
+	target                uint64
+	targetReached         bool
+	serveHistoricalBlocks bool
+	bytesLeftInCycle      uint64
+	timeLeftInCycle       time.Duration
+}
+
+// uploadTarget tracks the bytes sent to peers during a cycle of the upload
+// target timeframe to limit the upload traffic to the target.  It is safe for
+// concurrent access.
+type uploadTarget struct {
+	mtx        sync.Mutex
+	target     uint64
+	cycleStart time.Time
+	cycleBytes uint64
+}
+
+// newUploadTarget returns a new upload target which limits the upload traffic
+// to the passed number of bytes per timeframe.  A target of zero disables the
+// limit.
+func newUploadTarget(target uint64) *uploadTarget {
+	return &uploadTarget{
+		target:     target,
+		cycleStart: time.Now(),
+	}
+}
+
+// startCycle starts a new cycle when the current one has ended.
+//
+// This function MUST be called with the upload target lock held.
+func (u *uploadTarget) startCycle(now time.Time) {
+	if now.Sub(u.cycleStart) >= uploadTargetTimeframe {
+		u.cycleStart = now
+		u.cycleBytes = 0
+	}
+}
+
+// bytesLeft returns the number of bytes which can be sent in the current cycle
+// before the target is reached.
+//
+// This function MUST be called with the upload target lock held.
+func (u *uploadTarget) bytesLeft() uint64 {
+	if u.cycleBytes >= u.target {
+		return 0
+	}
+	return u.target - u.cycleBytes
+}
+
+// AddBytes adds the passed number of bytes to the bytes sent in the current
+// cycle.
+func (u *uploadTarget) AddBytes(n uint64) {
+	if u.target == 0 {
+		return
+	}
+
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	u.startCycle(time.Now())
+	u.cycleBytes += n
+}
+
+// Reached returns whether the upload target is reached in the current cycle.
+// When historical is true, it instead returns whether the target is close
+// enough to being reached that historical blocks must no longer be served.
+func (u *uploadTarget) Reached(historical bool) bool {
+	if u.target == 0 {
+		return false
+	}
+
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	u.startCycle(time.Now())
+	if historical {
+		return u.bytesLeft() <= historicalBlockBuffer
+	}
+	return u.cycleBytes >= u.target
+}
+
+// Status returns the state of the upload target in the current cycle.
+func (u *uploadTarget) Status() *uploadTargetStatus {
+	if u.target == 0 {
+		return &uploadTargetStatus{serveHistoricalBlocks: true}
+	}
+
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	now := time.Now()
+	u.startCycle(now)
+	bytesLeft := u.bytesLeft()
+	return &uploadTargetStatus{
+		target:                u.target,
+		targetReached:         bytesLeft == 0,
+		serveHistoricalBlocks: bytesLeft > historicalBlockBuffer,
+		bytesLeftInCycle:      bytesLeft,
+		timeLeftInCycle:       u.cycleStart.Add(uploadTargetTimeframe).Sub(now),
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestUploadTarget ensures the upload target reports historical blocks as
+// limited once the bytes left in the cycle drop to the historical block buffer
+// and the target as reached once no bytes are left.
+func TestUploadTarget(t *testing.T) {
+	t.Parallel()
+
+	const target = 2 * historicalBlockBuffer
+	tests := []struct {
+		name           string
+		target         uint64
+		sent           uint64
+		wantReached    bool
+		wantHistorical bool
+		wantBytesLeft  uint64
+		wantServe      bool
+	}{
+		{
+			name:      "disabled",
+			target:    0,
+			sent:      10 * target,
+			wantServe: true,
+		},
+		{
+			name:          "nothing sent",
+			target:        target,
+			wantBytesLeft: target,
+			wantServe:     true,
+		},
+		{
+			name:          "above historical buffer",
+			target:        target,
+			sent:          historicalBlockBuffer - 1,
+			wantBytesLeft: historicalBlockBuffer + 1,
+			wantServe:     true,
+		},
+		{
+			name:           "historical buffer left",
+			target:         target,
+			sent:           historicalBlockBuffer,
+			wantHistorical: true,
+			wantBytesLeft:  historicalBlockBuffer,
+		},
+		{
+			name:           "target reached",
+			target:         target,
+			sent:           target,
+			wantReached:    true,
+			wantHistorical: true,
+		},
+		{
+			name:           "target exceeded",
+			target:         target,
+			sent:           target + 1000,
+			wantReached:    true,
+			wantHistorical: true,
+		},
+		{
+			name:           "target below historical buffer",
+			target:         historicalBlockBuffer / 2,
+			wantHistorical: true,
+			wantBytesLeft:  historicalBlockBuffer / 2,
+		},
+	}
+
+	for _, test := range tests {
+		u := newUploadTarget(test.target)
+		u.AddBytes(test.sent)
+
+		if reached := u.Reached(false); reached != test.wantReached {
+			t.Errorf("%s: got reached %v, want %v", test.name, reached,
+				test.wantReached)
+		}
+		if reached := u.Reached(true); reached != test.wantHistorical {
+			t.Errorf("%s: got historical reached %v, want %v",
+				test.name, reached, test.wantHistorical)
+		}
+
+		status := u.Status()
+		if status.target != test.target ||
+			status.targetReached != test.wantReached ||
+			status.serveHistoricalBlocks != test.wantServe ||
+			status.bytesLeftInCycle != test.wantBytesLeft {
+
+			t.Errorf("%s: unexpected status %+v", test.name, status)
+		}
+	}
+}
+
+// TestUploadTargetCycle ensures the bytes sent are reset once the cycle of the
+// upload target ends.
+func TestUploadTargetCycle(t *testing.T) {
+	t.Parallel()
+
+	u := newUploadTarget(1000)
+	u.AddBytes(1000)
+	if !u.Reached(false) {
+		t.Fatal("upload target not reached")
+	}
+
+	status := u.Status()
+	if status.timeLeftInCycle <= 0 ||
+		status.timeLeftInCycle > uploadTargetTimeframe {
+
+		t.Fatalf("got time left in cycle %v", status.timeLeftInCycle)
+	}
+
+	// Move the start of the cycle back so it ended.
+	u.mtx.Lock()
+	u.cycleStart = u.cycleStart.Add(-uploadTargetTimeframe - time.Second)
+	u.mtx.Unlock()
+
+	if u.Reached(false) {
+		t.Fatal("upload target still reached after the cycle ended")
+	}
+	u.AddBytes(400)
+	if status := u.Status(); status.bytesLeftInCycle != 600 {
+		t.Fatalf("got %d bytes left in cycle, want 600",
+			status.bytesLeftInCycle)
+	}
+}
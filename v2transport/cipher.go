@@ -0,0 +1,296 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package v2transport
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// rekeyInterval is the number of messages encrypted by the forward
+	// secure ciphers before they switch to a new key.
+	rekeyInterval = 224
+
+	// lengthFieldLen is the length of the encrypted length field which
+	// prefixes every packet.
+	lengthFieldLen = 3
+
+	// headerLen is the length of the header which precedes the contents
+	// of a packet.
+	headerLen = 1
+
+	// tagLen is the length of the Poly1305 authentication tag which
+	// follows the encrypted contents of a packet.
+	tagLen = 16
+
+	// ignoreBit is the bit of the packet header which indicates the
+	// packet must be ignored by the receiver.
+	ignoreBit = 1 << 7
+
+	// PacketOverhead is the number of bytes a packet adds to its
+	// contents.
+	PacketOverhead = lengthFieldLen + headerLen + tagLen
+
+	// GarbageTerminatorLen is the length of the garbage terminators which
+	// are sent after the garbage of each side.
+	GarbageTerminatorLen = 16
+
+	// sessionIDLen is the length of the session id which uniquely
+	// identifies an encrypted connection.
+	sessionIDLen = 32
+)
+
+// errDecryptPacket is returned when a packet fails to authenticate.
+var errDecryptPacket = errors.New("packet authentication failed")
+
+// fsChaCha20 is a forward secure ChaCha20 stream cipher which is used to
+// encrypt the length of packets.  Every chunk is encrypted with a continuous
+// keystream, and the key is replaced with the following 32 bytes of keystream
+// every rekeyInterval chunks.
+type fsChaCha20 struct {
+	cipher       *chacha20.Cipher
+	chunkCounter uint32
+	rekeyCounter uint64
+}
+
+// v2Nonce returns the 96-bit nonce used by the forward secure ciphers.
+func v2Nonce(counter uint32, rekeyCounter uint64) []byte {
+	var nonce [chacha20.NonceSize]byte
+	binary.LittleEndian.PutUint32(nonce[:4], counter)
+	binary.LittleEndian.PutUint64(nonce[4:], rekeyCounter)
+	return nonce[:]
+}
+
+// newFSChaCha20 returns a forward secure ChaCha20 stream cipher with the
+// passed initial key.
+func newFSChaCha20(key []byte) *fsChaCha20 {
+	c, err := chacha20.NewUnauthenticatedCipher(key, v2Nonce(0, 0))
+	if err != nil {
+		// The key and nonce sizes are fixed.
+		panic(err)
+	}
+	return &fsChaCha20{cipher: c}
+}
+
+// crypt encrypts or decrypts the passed chunk into dst.
+func (f *fsChaCha20) crypt(dst, chunk []byte) {
+	f.cipher.XORKeyStream(dst, chunk)
+
+	f.chunkCounter++
+	if f.chunkCounter < rekeyInterval {
+		return
+	}
+
+	// Switch to a new key taken from the keystream.
+	var key [chacha20.KeySize]byte
+	f.cipher.XORKeyStream(key[:], key[:])
+	f.chunkCounter = 0
+	f.rekeyCounter++
+	c, err := chacha20.NewUnauthenticatedCipher(key[:],
+		v2Nonce(0, f.rekeyCounter))
+	if err != nil {
+		panic(err)
+	}
+	f.cipher = c
+}
+
+// fsChaCha20Poly1305 is a forward secure ChaCha20Poly1305 AEAD which is used
+// to encrypt the contents of packets.  Every packet is encrypted with a new
+// nonce, and the key is replaced every rekeyInterval packets.
+type fsChaCha20Poly1305 struct {
+	aead          cipher.AEAD
+	packetCounter uint32
+	rekeyCounter  uint64
+}
+
+// newFSChaCha20Poly1305 returns a forward secure ChaCha20Poly1305 AEAD with the
+// passed initial key.
+func newFSChaCha20Poly1305(key []byte) *fsChaCha20Poly1305 {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		// The key size is fixed.
+		panic(err)
+	}
+	return &fsChaCha20Poly1305{aead: aead}
+}
+
+// encrypt appends the encryption of plaintext authenticated along with aad to
+// dst and returns the result.
+func (f *fsChaCha20Poly1305) encrypt(dst, plaintext, aad []byte) []byte {
+	nonce := v2Nonce(f.packetCounter, f.rekeyCounter)
+	out := f.aead.Seal(dst, nonce, plaintext, aad)
+	f.nextPacket()
+	return out
+}
+
+// decrypt appends the decryption of ciphertext to dst and returns the result
+// provided it authenticates along with aad.
+func (f *fsChaCha20Poly1305) decrypt(dst, ciphertext, aad []byte) ([]byte, error) {
+	nonce := v2Nonce(f.packetCounter, f.rekeyCounter)
+	out, err := f.aead.Open(dst, nonce, ciphertext, aad)
+	f.nextPacket()
+	if err != nil {
+		return nil, errDecryptPacket
+	}
+	return out, nil
+}
+
+// nextPacket advances the AEAD to the next packet, switching to a new key when
+// the rekey interval is reached.
+func (f *fsChaCha20Poly1305) nextPacket() {
+	f.packetCounter++
+	if f.packetCounter < rekeyInterval {
+		return
+	}
+
+	// The new key is the first 32 bytes of the encryption of zeros with
+	// a nonce reserved for rekeying.
+	var zeros [chacha20poly1305.KeySize]byte
+	nonce := v2Nonce(0xffffffff, f.rekeyCounter)
+	key := f.aead.Seal(nil, nonce, zeros[:], nil)[:chacha20poly1305.KeySize]
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		panic(err)
+	}
+	f.aead = aead
+	f.packetCounter = 0
+	f.rekeyCounter++
+}
+
+// packetCipher encrypts and decrypts the packets of a v2 transport connection.
+// It houses the ciphers for both directions, which are keyed from the shared
+// secret of the connection.
+type packetCipher struct {
+	sendLength *fsChaCha20
+	sendPacket *fsChaCha20Poly1305
+	recvLength *fsChaCha20
+	recvPacket *fsChaCha20Poly1305
+
+	sessionID             [sessionIDLen]byte
+	sendGarbageTerminator [GarbageTerminatorLen]byte
+	recvGarbageTerminator [GarbageTerminatorLen]byte
+}
+
+// sessionKeys houses the keys and other values of a connection which are
+// derived from its shared secret.
+type sessionKeys struct {
+	initiatorL         []byte
+	initiatorP         []byte
+	responderL         []byte
+	responderP         []byte
+	garbageTerminators []byte
+	sessionID          []byte
+}
+
+// deriveSessionKeys expands the passed shared secret of a connection on the
+// passed network into the keys of the connection as defined by BIP0324.
+func deriveSessionKeys(sharedSecret *chainhash.Hash,
+	btcnet wire.BitcoinNet) *sessionKeys {
+
+	// The keys are expanded from the shared secret using a salt bound to
+	// the network.
+	var magic [4]byte
+	binary.LittleEndian.PutUint32(magic[:], uint32(btcnet))
+	salt := append([]byte("bitcoin_v2_shared_secret"), magic[:]...)
+	prk := hkdf.Extract(sha256.New, sharedSecret[:], salt)
+	expand := func(info string) []byte {
+		key := make([]byte, 32)
+		_, err := io.ReadFull(hkdf.Expand(sha256.New, prk,
+			[]byte(info)), key)
+		if err != nil {
+			panic(err)
+		}
+		return key
+	}
+	return &sessionKeys{
+		initiatorL:         expand("initiator_L"),
+		initiatorP:         expand("initiator_P"),
+		responderL:         expand("responder_L"),
+		responderP:         expand("responder_P"),
+		garbageTerminators: expand("garbage_terminators"),
+		sessionID:          expand("session_id"),
+	}
+}
+
+// newPacketCipher derives the keys of a connection from an ECDH exchange of
+// the ElligatorSwift encoded public keys of both sides and returns a packet
+// cipher using them.
+func newPacketCipher(privKey *btcec.PrivateKey, ellswiftOurs,
+	ellswiftTheirs *[EllswiftPubKeyLen]byte, initiator bool,
+	btcnet wire.BitcoinNet) *packetCipher {
+
+	sharedSecret := v2ECDH(privKey, ellswiftOurs, ellswiftTheirs, initiator)
+	keys := deriveSessionKeys(sharedSecret, btcnet)
+
+	c := &packetCipher{}
+	copy(c.sessionID[:], keys.sessionID)
+	garbageTerminators := keys.garbageTerminators
+	if initiator {
+		c.sendLength = newFSChaCha20(keys.initiatorL)
+		c.sendPacket = newFSChaCha20Poly1305(keys.initiatorP)
+		c.recvLength = newFSChaCha20(keys.responderL)
+		c.recvPacket = newFSChaCha20Poly1305(keys.responderP)
+		copy(c.sendGarbageTerminator[:], garbageTerminators[:16])
+		copy(c.recvGarbageTerminator[:], garbageTerminators[16:])
+	} else {
+		c.sendLength = newFSChaCha20(keys.responderL)
+		c.sendPacket = newFSChaCha20Poly1305(keys.responderP)
+		c.recvLength = newFSChaCha20(keys.initiatorL)
+		c.recvPacket = newFSChaCha20Poly1305(keys.initiatorP)
+		copy(c.sendGarbageTerminator[:], garbageTerminators[16:])
+		copy(c.recvGarbageTerminator[:], garbageTerminators[:16])
+	}
+	return c
+}
+
+// encrypt returns the packet carrying the passed contents authenticated along
+// with aad.  The packet is flagged to be ignored by the receiver when ignore
+// is set.
+func (c *packetCipher) encrypt(contents, aad []byte, ignore bool) []byte {
+	packet := make([]byte, lengthFieldLen, len(contents)+PacketOverhead)
+	length := uint32(len(contents))
+	var lengthField [lengthFieldLen]byte
+	lengthField[0] = byte(length)
+	lengthField[1] = byte(length >> 8)
+	lengthField[2] = byte(length >> 16)
+	c.sendLength.crypt(packet, lengthField[:])
+
+	plaintext := make([]byte, headerLen, headerLen+len(contents))
+	if ignore {
+		plaintext[0] = ignoreBit
+	}
+	plaintext = append(plaintext, contents...)
+	return c.sendPacket.encrypt(packet, plaintext, aad)
+}
+
+// decryptLength decrypts the length field of the next packet and returns the
+// length of its contents.
+func (c *packetCipher) decryptLength(lengthField []byte) uint32 {
+	var length [lengthFieldLen]byte
+	c.recvLength.crypt(length[:], lengthField)
+	return uint32(length[0]) | uint32(length[1])<<8 | uint32(length[2])<<16
+}
+
+// decrypt decrypts the remainder of a packet following its length field and
+// returns its contents along with whether the packet is to be ignored.
+func (c *packetCipher) decrypt(ciphertext, aad []byte) ([]byte, bool, error) {
+	plaintext, err := c.recvPacket.decrypt(nil, ciphertext, aad)
+	if err != nil {
+		return nil, false, err
+	}
+	ignore := plaintext[0]&ignoreBit != 0
+	return plaintext[headerLen:], ignore, nil
+}
@@ -0,0 +1,392 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package v2transport
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/wire"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// hexToBytes converts the passed hex string into bytes and will panic if there
+// is an error.  This is only provided for the hard-coded constants so errors in
+// the source code can be detected.  It will only (and must only) be called with
+// hard-coded values.
+func hexToBytes(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic("invalid hex in source file: " + s)
+	}
+	return b
+}
+
+// TestFSChaCha20 ensures the forward secure stream cipher continues the
+// keystream across chunks and switches to a key taken from the keystream
+// every rekey interval.
+func TestFSChaCha20(t *testing.T) {
+	t.Parallel()
+
+	key := bytes.Repeat([]byte{0x01}, chacha20.KeySize)
+	f := newFSChaCha20(key)
+
+	// The keystream of the first interval along with the next key is the
+	// plain ChaCha20 keystream of the initial key.
+	const chunkLen = lengthFieldLen
+	c, _ := chacha20.NewUnauthenticatedCipher(key, v2Nonce(0, 0))
+	want := make([]byte, rekeyInterval*chunkLen+chacha20.KeySize)
+	c.XORKeyStream(want, want)
+	got := make([]byte, rekeyInterval*chunkLen)
+	for i := 0; i < rekeyInterval; i++ {
+		chunk := got[i*chunkLen : (i+1)*chunkLen]
+		f.crypt(chunk, chunk)
+	}
+	if !bytes.Equal(got, want[:len(got)]) {
+		t.Fatal("crypt: unexpected keystream before rekey")
+	}
+
+	// The following chunk is encrypted with the new key and the nonce of
+	// the next rekey interval.
+	newKey := want[len(got):]
+	c, _ = chacha20.NewUnauthenticatedCipher(newKey, v2Nonce(0, 1))
+	var wantChunk, gotChunk [chunkLen]byte
+	c.XORKeyStream(wantChunk[:], wantChunk[:])
+	f.crypt(gotChunk[:], gotChunk[:])
+	if gotChunk != wantChunk {
+		t.Fatalf("crypt: got keystream %x after rekey, want %x",
+			gotChunk, wantChunk)
+	}
+}
+
+// TestFSChaCha20Poly1305 ensures the forward secure AEAD uses a new nonce for
+// every packet and switches keys every rekey interval.
+func TestFSChaCha20Poly1305(t *testing.T) {
+	t.Parallel()
+
+	key := bytes.Repeat([]byte{0x02}, chacha20poly1305.KeySize)
+	sender := newFSChaCha20Poly1305(key)
+	receiver := newFSChaCha20Poly1305(key)
+	aead, _ := chacha20poly1305.New(key)
+
+	plaintext := []byte("forward secure")
+	aad := []byte("aad")
+	for i := 0; i < rekeyInterval; i++ {
+		ciphertext := sender.encrypt(nil, plaintext, aad)
+		want := aead.Seal(nil, v2Nonce(uint32(i), 0), plaintext, aad)
+		if !bytes.Equal(ciphertext, want) {
+			t.Fatalf("encrypt #%d: got %x, want %x", i, ciphertext,
+				want)
+		}
+		got, err := receiver.decrypt(nil, ciphertext, aad)
+		if err != nil {
+			t.Fatalf("decrypt #%d: unexpected error: %v", i, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Fatalf("decrypt #%d: got %x, want %x", i, got,
+				plaintext)
+		}
+	}
+
+	// The key of the next interval is the encryption of zeros with the
+	// rekey nonce.
+	var zeros [chacha20poly1305.KeySize]byte
+	newKey := aead.Seal(nil, v2Nonce(0xffffffff, 0), zeros[:], nil)
+	aead, _ = chacha20poly1305.New(newKey[:chacha20poly1305.KeySize])
+	ciphertext := sender.encrypt(nil, plaintext, nil)
+	want := aead.Seal(nil, v2Nonce(0, 1), plaintext, nil)
+	if !bytes.Equal(ciphertext, want) {
+		t.Fatalf("encrypt: got %x after rekey, want %x", ciphertext,
+			want)
+	}
+
+	// Tampered packets must fail to authenticate.
+	ciphertext[0] ^= 0x01
+	if _, err := receiver.decrypt(nil, ciphertext, nil); err != errDecryptPacket {
+		t.Fatalf("decrypt: unexpected error: got %v, want %v", err,
+			errDecryptPacket)
+	}
+}
+
+// TestPacketCipher ensures both sides of a connection derive matching keys
+// and are able to exchange packets across rekey intervals.
+func TestPacketCipher(t *testing.T) {
+	t.Parallel()
+
+	initiatorKey, initiatorPub, err := ellswiftCreate()
+	if err != nil {
+		t.Fatalf("ellswiftCreate: unexpected error: %v", err)
+	}
+	responderKey, responderPub, err := ellswiftCreate()
+	if err != nil {
+		t.Fatalf("ellswiftCreate: unexpected error: %v", err)
+	}
+	initiator := newPacketCipher(initiatorKey, &initiatorPub,
+		&responderPub, true, wire.MainNet)
+	responder := newPacketCipher(responderKey, &responderPub,
+		&initiatorPub, false, wire.MainNet)
+
+	if initiator.sessionID != responder.sessionID {
+		t.Fatal("newPacketCipher: mismatched session ids")
+	}
+	if initiator.sendGarbageTerminator != responder.recvGarbageTerminator ||
+		initiator.recvGarbageTerminator != responder.sendGarbageTerminator {
+
+		t.Fatal("newPacketCipher: mismatched garbage terminators")
+	}
+
+	// Ciphers derived for another network must not match.
+	other := newPacketCipher(responderKey, &responderPub, &initiatorPub,
+		false, wire.TestNet3)
+	if other.sessionID == initiator.sessionID {
+		t.Fatal("newPacketCipher: session id not bound to network")
+	}
+
+	// Exchange packets in both directions across multiple rekey
+	// intervals.
+	exchange := func(sender, receiver *packetCipher, i int) {
+		contents := bytes.Repeat([]byte{byte(i)}, i%50)
+		var aad []byte
+		if i == 0 {
+			aad = []byte("garbage")
+		}
+		ignore := i%7 == 0
+		packet := sender.encrypt(contents, aad, ignore)
+		if len(packet) != len(contents)+PacketOverhead {
+			t.Fatalf("encrypt #%d: got packet length %d, want %d",
+				i, len(packet), len(contents)+PacketOverhead)
+		}
+		length := receiver.decryptLength(packet[:lengthFieldLen])
+		if length != uint32(len(contents)) {
+			t.Fatalf("decryptLength #%d: got %d, want %d", i, length,
+				len(contents))
+		}
+		got, gotIgnore, err := receiver.decrypt(packet[lengthFieldLen:],
+			aad)
+		if err != nil {
+			t.Fatalf("decrypt #%d: unexpected error: %v", i, err)
+		}
+		if !bytes.Equal(got, contents) || gotIgnore != ignore {
+			t.Fatalf("decrypt #%d: got contents %x (ignore %v), "+
+				"want %x (ignore %v)", i, got, gotIgnore,
+				contents, ignore)
+		}
+	}
+	for i := 0; i < rekeyInterval*2+10; i++ {
+		exchange(initiator, responder, i)
+		exchange(responder, initiator, i)
+	}
+
+	// Packets authenticated with the wrong aad must be rejected.
+	packet := initiator.encrypt(nil, []byte("garbage"), false)
+	responder.decryptLength(packet[:lengthFieldLen])
+	_, _, err = responder.decrypt(packet[lengthFieldLen:], nil)
+	if err != errDecryptPacket {
+		t.Fatalf("decrypt: unexpected error: got %v, want %v", err,
+			errDecryptPacket)
+	}
+}
+
+// TestPacketEncodingVectors ensures the shared secret, the keys derived from
+// it and the encrypted packets match the BIP0324 packet encoding test vectors.
+func TestPacketEncodingVectors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		idx                      int
+		privOurs                 string
+		ellswiftOurs             string
+		ellswiftTheirs           string
+		initiating               bool
+		contents                 string
+		multiply                 int
+		aad                      string
+		ignore                   bool
+		midXOurs                 string
+		midXTheirs               string
+		midXShared               string
+		midSharedSecret          string
+		midInitiatorL            string
+		midInitiatorP            string
+		midResponderL            string
+		midResponderP            string
+		midSendGarbageTerminator string
+		midRecvGarbageTerminator string
+		outSessionID             string
+		outCiphertext            string
+	}{
+		{
+			idx:      1,
+			privOurs: "61062ea5071d800bbfd59e2e8b53d47d194b095ae5a4df04936b49772ef0d4d7",
+			ellswiftOurs: "ec0adff257bbfe500c188c80b4fdd640f6b45a482bbc15fc7cef5931deff0aa1" +
+				"86f6eb9bba7b85dc4dcc28b28722de1e3d9108b985e2967045668f66098e475b",
+			ellswiftTheirs: "a4a94dfce69b4a2a0a099313d10f9f7e7d649d60501c9e1d274c300e0d89aafa" +
+				"ffffffffffffffffffffffffffffffffffffffffffffffffffffffff8faf88d5",
+			initiating:               true,
+			contents:                 "8e",
+			multiply:                 1,
+			midXOurs:                 "19e965bc20fc40614e33f2f82d4eeff81b5e7516b12a5c6c0d6053527eba0923",
+			midXTheirs:               "0c71defa3fafd74cb835102acd81490963f6b72d889495e06561375bd65f6ffc",
+			midXShared:               "4eb2bf85bd00939468ea2abb25b63bc642e3d1eb8b967fb90caa2d89e716050e",
+			midSharedSecret:          "c6992a117f5edbea70c3f511d32d26b9798be4b81a62eaee1a5acaa8459a3592",
+			midInitiatorL:            "9a6478b5fbab1f4dd2f78994b774c03211c78312786e602da75a0d1767fb55cf",
+			midInitiatorP:            "7d0c7820ba6a4d29ce40baf2caa6035e04f1e1cefd59f3e7e59e9e5af84f1f51",
+			midResponderL:            "17bc726421e4054ac6a1d54915085aaa766f4d3cf67bbd168e6080eac289d15e",
+			midResponderP:            "9f0fc1c0e85fd9a8eee07e6fc41dba2ff54c7729068a239ac97c37c524cca1c0",
+			midSendGarbageTerminator: "faef555dfcdb936425d84aba524758f3",
+			midRecvGarbageTerminator: "02cb8ff24307a6e27de3b4e7ea3fa65b",
+			outSessionID:             "ce72dffb015da62b0d0f5474cab8bc72605225b0cee3f62312ec680ec5f41ba5",
+			outCiphertext:            "7530d2a18720162ac09c25329a60d75adf36eda3c3",
+		},
+		{
+			idx:      999,
+			privOurs: "1f9c581b35231838f0f17cf0c979835baccb7f3abbbb96ffcc318ab71e6e126f",
+			ellswiftOurs: "a1855e10e94e00baa23041d916e259f7044e491da6171269694763f018c7e636" +
+				"93d29575dcb464ac816baa1be353ba12e3876cba7628bd0bd8e755e721eb0140",
+			ellswiftTheirs: "fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f" +
+				"0000000000000000000000000000000000000000000000000000000000000000",
+			initiating:               false,
+			contents:                 "3eb1d4e98035cfd8eeb29bac969ed3824a",
+			multiply:                 1,
+			midXOurs:                 "45b6f1f684fd9f2b16e2651ddc47156c0695c8c5cd2c0c9df6d79a1056c61120",
+			midXTheirs:               "edd1fd3e327ce90cc7a3542614289aee9682003e9cf7dcc9cf2ca9743be5aa0c",
+			midXShared:               "c40eb6190caf399c9007254ad5e5fa20d64af2b41696599c59b2191d16992955",
+			midSharedSecret:          "a0138f564f74d0ad70bc337dacc9d0bf1d2349364caf1188a1e6e8ddb3b7b184",
+			midInitiatorL:            "b82a0a7ce7219777f914d2ab873c5c487c56bd7b68622594d67fe029a8fa7def",
+			midInitiatorP:            "d760ba8f62dd3d29d7d5584e310caf2540285edc6b51c640f9497e99c3536fd2",
+			midResponderL:            "9db0c6f9a903cbab5d7b3c58273a3421eec0001814ec53236bd405131a0d8e90",
+			midResponderP:            "23d2b5e653e6a3a8db160a2ca03d11cb5a79983babba861fcb57c38413323c0c",
+			midSendGarbageTerminator: "efb64fd80acd3825ac9bc2a67216535a",
+			midRecvGarbageTerminator: "b3cb553453bceb002897e751ff7588bf",
+			outSessionID:             "9267c54560607de73f18c563b76a2442718879c52dd39852885d4a3c9912c9ea",
+			outCiphertext:            "1da1bcf589f9b61872f45b7fa5371dd3f8bdf5d515b0c5f9fe9f0044afb8dc0aa1cd39a8c4",
+		},
+		{
+			idx:      223,
+			privOurs: "6c77432d1fda31e9f942f8af44607e10f3ad38a65f8a4bddae823e5eff90dc38",
+			ellswiftOurs: "d2685070c1e6376e633e825296634fd461fa9e5bdf2109bcebd735e5a91f3e58" +
+				"7c5cb782abb797fbf6bb5074fd1542a474f2a45b673763ec2db7fb99b737bbb9",
+			ellswiftTheirs: "56bd0c06f10352c3a1a9f4b4c92f6fa2b26df124b57878353c1fc691c51abea7" +
+				"7c8817daeeb9fa546b77c8daf79d89b22b0e1b87574ece42371f00237aa9d83a",
+			initiating: false,
+			contents: "7e0e78eb6990b059e6cf0ded66ea93ef82e72aa2f18ac24f2fc6ebab561ae557" +
+				"420729da103f64cecfa20527e15f9fb669a49bbbf274ef0389b3e43c8c44e5f6" +
+				"0bf2ac38e2b55e7ec4273dba15ba41d21f8f5b3ee1688b3c29951218caf847a9" +
+				"7fb50d75a86515d445699497d968164bf740012679b8962de573be941c62b7ef",
+			multiply:                 1,
+			ignore:                   true,
+			midXOurs:                 "193d019db571162e52567e0cfdf9dd6964394f32769ae2edc4933b03b502d771",
+			midXTheirs:               "2dd7b9cc85524f8670f695c3143ac26b45cebcabb2782a85e0fe15aee3956535",
+			midXShared:               "5e35f94adfd57976833bffec48ef6dde983d18a55501154191ea352ef06732ee",
+			midSharedSecret:          "1918b741ef5f9d1d7670b050c152b4a4ead2c31be9aecb0681c0cd4324150853",
+			midInitiatorL:            "97124c56236425d792b1ec85e34b846e8d88c9b9f1d4f23ac6cdcc4c177055a0",
+			midInitiatorP:            "8c71b468c61119415e3c1dfdd184134211951e2f623199629a46bff9673611f2",
+			midResponderL:            "b43b8791b51ed682f56d64351601be28e478264411dcf963b14ee60b9ae427fa",
+			midResponderP:            "794dde4b38ef04250c534a7fa638f2e8cc8b6d2c6110ec290ab0171fdf277d51",
+			midSendGarbageTerminator: "cf2e25f23501399f30738d7eee652b90",
+			midRecvGarbageTerminator: "225a477a28a54ea7671d2b217a9c29db",
+			outSessionID:             "7ec02fea8c1484e3d0875f978c5f36d63545e2e4acf56311394422f4b66af612",
+			outCiphertext: "4da2af139e1bba14f637fac79cbb4e3fac44d257729847a3e9eba7a5bff454b5" +
+				"de3b393431ee360736b6c030d7a5bd01d1203d2e98f528543fd2bf886ccaa1ad" +
+				"a5e215a730a36b3f4abfc4e252c89eb01d9512f94916dae8a76bf16e4da28986" +
+				"ffe159090fe5267ee3394300b7ccf4dfad389a26321b3a3423e4594a82ccfbad" +
+				"16d6561ecb8772b0cb040280ff999a29e3d9d4fd",
+		},
+	}
+
+	for i, test := range tests {
+		privKey, _ := btcec.PrivKeyFromBytes(hexToBytes(test.privOurs))
+		var ellswiftOurs, ellswiftTheirs [EllswiftPubKeyLen]byte
+		copy(ellswiftOurs[:], hexToBytes(test.ellswiftOurs))
+		copy(ellswiftTheirs[:], hexToBytes(test.ellswiftTheirs))
+
+		// The encodings must decode to the expected x coordinates, and
+		// the x coordinate of ours must be the one of the public key.
+		xOurs := ellswiftDecode(&ellswiftOurs)
+		if !xOurs.Equals(hexToFieldVal(test.midXOurs)) {
+			t.Errorf("ellswiftDecode #%d: got x %v, want %s", i,
+				xOurs, test.midXOurs)
+			continue
+		}
+		pubX := privKey.PubKey().SerializeCompressed()[1:]
+		if !bytes.Equal(pubX, hexToBytes(test.midXOurs)) {
+			t.Errorf("#%d: got public key x %x, want %s", i, pubX,
+				test.midXOurs)
+			continue
+		}
+		xTheirs := ellswiftDecode(&ellswiftTheirs)
+		if !xTheirs.Equals(hexToFieldVal(test.midXTheirs)) {
+			t.Errorf("ellswiftDecode #%d: got x %v, want %s", i,
+				xTheirs, test.midXTheirs)
+			continue
+		}
+
+		xShared := ellswiftECDHXOnly(&ellswiftTheirs, privKey)
+		if !bytes.Equal(xShared[:], hexToBytes(test.midXShared)) {
+			t.Errorf("ellswiftECDHXOnly #%d: got %x, want %s", i,
+				xShared, test.midXShared)
+			continue
+		}
+		sharedSecret := v2ECDH(privKey, &ellswiftOurs, &ellswiftTheirs,
+			test.initiating)
+		if !bytes.Equal(sharedSecret[:], hexToBytes(test.midSharedSecret)) {
+			t.Errorf("v2ECDH #%d: got %v, want %s", i, sharedSecret,
+				test.midSharedSecret)
+			continue
+		}
+
+		keys := deriveSessionKeys(sharedSecret, wire.MainNet)
+		derived := []struct {
+			name string
+			got  []byte
+			want string
+		}{
+			{"initiator length key", keys.initiatorL, test.midInitiatorL},
+			{"initiator packet key", keys.initiatorP, test.midInitiatorP},
+			{"responder length key", keys.responderL, test.midResponderL},
+			{"responder packet key", keys.responderP, test.midResponderP},
+		}
+		for _, d := range derived {
+			if !bytes.Equal(d.got, hexToBytes(d.want)) {
+				t.Errorf("deriveSessionKeys #%d: got %s %x, want %s",
+					i, d.name, d.got, d.want)
+			}
+		}
+
+		c := newPacketCipher(privKey, &ellswiftOurs, &ellswiftTheirs,
+			test.initiating, wire.MainNet)
+		if !bytes.Equal(c.sessionID[:], hexToBytes(test.outSessionID)) {
+			t.Errorf("newPacketCipher #%d: got session id %x, want %s",
+				i, c.sessionID, test.outSessionID)
+		}
+		sendTerminator := hexToBytes(test.midSendGarbageTerminator)
+		if !bytes.Equal(c.sendGarbageTerminator[:], sendTerminator) {
+			t.Errorf("newPacketCipher #%d: got send garbage terminator "+
+				"%x, want %s", i, c.sendGarbageTerminator,
+				test.midSendGarbageTerminator)
+		}
+		recvTerminator := hexToBytes(test.midRecvGarbageTerminator)
+		if !bytes.Equal(c.recvGarbageTerminator[:], recvTerminator) {
+			t.Errorf("newPacketCipher #%d: got receive garbage "+
+				"terminator %x, want %s", i, c.recvGarbageTerminator,
+				test.midRecvGarbageTerminator)
+		}
+
+		// The packet with the passed index is preceded by packets
+		// without contents.
+		for j := 0; j < test.idx; j++ {
+			c.encrypt(nil, nil, false)
+		}
+		contents := bytes.Repeat(hexToBytes(test.contents), test.multiply)
+		packet := c.encrypt(contents, hexToBytes(test.aad), test.ignore)
+		if !bytes.Equal(packet, hexToBytes(test.outCiphertext)) {
+			t.Errorf("encrypt #%d: got %x, want %s", i, packet,
+				test.outCiphertext)
+		}
+	}
+}
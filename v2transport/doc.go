@@ -0,0 +1,33 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package v2transport implements the v2 encrypted transport protocol for bitcoin
+peer connections as defined by BIP0324.
+
+Handshake
+
+Both sides of a connection generate an ephemeral key pair and send the public
+key encoded with ElligatorSwift, which makes it indistinguishable from random
+bytes, followed by a random amount of garbage.  The keys of the connection are
+derived from an x-only ECDH exchange of the public keys.  Each side then sends
+a garbage terminator followed by a version packet which authenticates the
+garbage it sent.
+
+An inbound connection from a peer which starts with a v1 version message is
+detected during the handshake, in which case the transport falls back to the
+v1 protocol.  An outbound connection to a peer which closes the connection or
+sends a v1 message instead of its public key fails with ErrV1Peer instead, so
+the peer can be reconnected to using the v1 protocol.
+
+Packets
+
+Every message is carried by a packet consisting of its length, encrypted with
+a forward secure ChaCha20 stream cipher, followed by a header and the contents
+encrypted and authenticated with a forward secure ChaCha20Poly1305 AEAD.  Both
+ciphers switch to a new key every 224 messages.  The contents start with a
+short message id for common commands, which avoids sending the full command.
+Packets with the ignore bit set in their header are decoys and are skipped.
+*/
+package v2transport
@@ -0,0 +1,266 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package v2transport
+
+import (
+	"crypto/rand"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// EllswiftPubKeyLen is the length of an ElligatorSwift encoded public key.
+const EllswiftPubKeyLen = 64
+
+var (
+	// ellswiftC is sqrt(-3) mod p, the constant used by the SwiftEC
+	// mapping.
+	ellswiftC = func() btcec.FieldVal {
+		var c btcec.FieldVal
+		c.SetByteSlice([]byte{
+			0x0a, 0x2d, 0x2b, 0xa9, 0x35, 0x07, 0xf1, 0xdf,
+			0x23, 0x37, 0x70, 0xc2, 0xa7, 0x97, 0x96, 0x2c,
+			0xc6, 0x1f, 0x6d, 0x15, 0xda, 0x14, 0xec, 0xd4,
+			0x7d, 0x8d, 0x27, 0xae, 0x1c, 0xd5, 0xf8, 0x52,
+		})
+		return c
+	}()
+
+	// ellswiftECDHTag is the tag of the tagged hash used to derive the
+	// shared secret from an x-only ECDH exchange of ElligatorSwift encoded
+	// public keys.
+	ellswiftECDHTag = []byte("bip324_ellswift_xonly_ecdh")
+)
+
+// isXCoord returns whether the passed field value is the x coordinate of a
+// point on the secp256k1 curve.
+//
+// NOTE: x must have a max magnitude of 8.
+func isXCoord(x *btcec.FieldVal) bool {
+	var y, ySquared btcec.FieldVal
+	ySquared.SquareVal(x).Mul(x).AddInt(7)
+	return y.SquareRootVal(&ySquared)
+}
+
+// xSwiftEC maps the field elements u and t to the x coordinate of a point on
+// the secp256k1 curve as defined by the SwiftEC mapping.  Every pair of field
+// elements maps to a valid x coordinate.
+//
+// NOTE: u and t must be normalized.  The returned x coordinate is normalized.
+func xSwiftEC(uIn, tIn *btcec.FieldVal) *btcec.FieldVal {
+	var u, t btcec.FieldVal
+	u.Set(uIn)
+	t.Set(tIn)
+	if u.IsZero() {
+		u.SetInt(1)
+	}
+	if t.IsZero() {
+		t.SetInt(1)
+	}
+
+	// Double t when g(u) = -t^2 since the mapping is undefined otherwise.
+	var gu, tSquared, sum btcec.FieldVal
+	gu.SquareVal(&u).Mul(&u).AddInt(7)
+	tSquared.SquareVal(&t)
+	if sum.Add2(&gu, &tSquared).Normalize().IsZero() {
+		t.Add(&t).Normalize()
+		tSquared.SquareVal(&t)
+	}
+
+	// X = (u^3 + 7 - t^2) / (2t).
+	var x, denom btcec.FieldVal
+	denom.Add2(&t, &t).Inverse()
+	x.NegateVal(&tSquared, 1).Add(&gu).Mul(&denom)
+
+	// Y = (X + t) / (c * u).
+	var y btcec.FieldVal
+	denom.Mul2(&ellswiftC, &u).Inverse()
+	y.Add2(&x, &t).Mul(&denom)
+
+	// Return the first of u + 4Y^2, (-X/Y - u) / 2 and (X/Y - u) / 2 which
+	// is a valid x coordinate.  At least one of them always is.
+	var candidate btcec.FieldVal
+	candidate.SquareVal(&y).MulInt(4).Add(&u).Normalize()
+	if isXCoord(&candidate) {
+		return &candidate
+	}
+
+	var xDivY, negU, halfInv btcec.FieldVal
+	xDivY.Set(&y).Inverse().Mul(&x)
+	negU.NegateVal(&u, 1)
+	halfInv.SetInt(2).Inverse()
+	candidate.NegateVal(&xDivY, 1).Add(&negU).Mul(&halfInv).Normalize()
+	if isXCoord(&candidate) {
+		return &candidate
+	}
+
+	candidate.Set(&xDivY).Add(&negU).Mul(&halfInv).Normalize()
+	return &candidate
+}
+
+// xSwiftECInv returns a field element t such that xSwiftEC(u, t) = x, or nil
+// when no such t exists for the passed case.  The case, which is in the range
+// [0, 7], selects which of up to eight preimages is returned.
+//
+// NOTE: u and x must be normalized and x must be a valid x coordinate.  The
+// returned field element is normalized.
+func xSwiftECInv(u, x *btcec.FieldVal, c int) *btcec.FieldVal {
+	var s, v, uSquared, gu btcec.FieldVal
+	uSquared.SquareVal(u)
+	gu.Set(&uSquared).Mul(u).AddInt(7)
+
+	if c&2 == 0 {
+		// No preimage exists for this case when -x - u is a valid x
+		// coordinate.
+		var negXU btcec.FieldVal
+		negXU.Add2(x, u).Negate(2)
+		if isXCoord(&negXU) {
+			return nil
+		}
+
+		// v = x and s = -(u^3 + 7) / (u^2 + uv + v^2).
+		var denom btcec.FieldVal
+		v.Set(x)
+		denom.Mul2(u, &v).Add(&uSquared).Add(new(btcec.FieldVal).
+			SquareVal(&v)).Inverse()
+		s.NegateVal(&gu, 2).Mul(&denom)
+	} else {
+		// s = x - u, which must not be zero.
+		s.NegateVal(u, 1).Add(x).Normalize()
+		if s.IsZero() {
+			return nil
+		}
+
+		// r = sqrt(-s * (4(u^3 + 7) + 3su^2)), which must exist and
+		// must be non-zero for odd cases.
+		var r, radicand btcec.FieldVal
+		var fourGU btcec.FieldVal
+		fourGU.Set(&gu).MulInt(4).Normalize()
+		radicand.Mul2(&uSquared, &s).MulInt(3).Add(&fourGU)
+		radicand.Mul(&s).Negate(1)
+		if !r.SquareRootVal(&radicand) {
+			return nil
+		}
+		if c&1 == 1 && r.Normalize().IsZero() {
+			return nil
+		}
+
+		// v = (r/s - u) / 2.
+		var halfInv btcec.FieldVal
+		halfInv.SetInt(2).Inverse()
+		v.Set(&s).Inverse().Mul(&r).Add(new(btcec.FieldVal).
+			NegateVal(u, 1)).Mul(&halfInv)
+	}
+
+	// w = sqrt(s), which must exist.
+	var w btcec.FieldVal
+	if !w.SquareRootVal(&s) {
+		return nil
+	}
+
+	// Depending on the case, t = ±w(u(1 ± c)/2 + v).
+	var factor, halfInv btcec.FieldVal
+	halfInv.SetInt(2).Inverse()
+	if c&1 == 0 {
+		factor.NegateVal(&ellswiftC, 1).AddInt(1)
+	} else {
+		factor.Set(&ellswiftC).AddInt(1)
+	}
+	var t btcec.FieldVal
+	t.Mul2(u, &factor).Mul(&halfInv).Add(&v).Mul(&w)
+	if c&5 == 0 || c&5 == 5 {
+		t.Negate(1)
+	}
+	return t.Normalize()
+}
+
+// xElligatorSwift returns a random ElligatorSwift encoding (u, t) of the passed
+// x coordinate.
+//
+// NOTE: x must be normalized and must be a valid x coordinate.
+func xElligatorSwift(x *btcec.FieldVal) (*btcec.FieldVal, *btcec.FieldVal, error) {
+	for {
+		var randBytes [33]byte
+		if _, err := rand.Read(randBytes[:]); err != nil {
+			return nil, nil, err
+		}
+
+		var u btcec.FieldVal
+		u.SetByteSlice(randBytes[:32])
+		if u.Normalize().IsZero() {
+			continue
+		}
+		t := xSwiftECInv(&u, x, int(randBytes[32]&7))
+		if t != nil && !t.IsZero() {
+			return &u, t, nil
+		}
+	}
+}
+
+// ellswiftCreate generates a new private key and returns it along with a
+// random ElligatorSwift encoding of its public key.
+func ellswiftCreate() (*btcec.PrivateKey, [EllswiftPubKeyLen]byte, error) {
+	var ellswiftPub [EllswiftPubKeyLen]byte
+	privKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		return nil, ellswiftPub, err
+	}
+
+	var x btcec.FieldVal
+	x.SetByteSlice(privKey.PubKey().SerializeCompressed()[1:])
+	u, t, err := xElligatorSwift(&x)
+	if err != nil {
+		return nil, ellswiftPub, err
+	}
+	u.PutBytesUnchecked(ellswiftPub[:32])
+	t.PutBytesUnchecked(ellswiftPub[32:])
+	return privKey, ellswiftPub, nil
+}
+
+// ellswiftDecode returns the x coordinate of the public key encoded by the
+// passed ElligatorSwift encoding.
+func ellswiftDecode(ellswiftPub *[EllswiftPubKeyLen]byte) *btcec.FieldVal {
+	var u, t btcec.FieldVal
+	u.SetByteSlice(ellswiftPub[:32])
+	t.SetByteSlice(ellswiftPub[32:])
+	return xSwiftEC(u.Normalize(), t.Normalize())
+}
+
+// ellswiftECDHXOnly returns the x coordinate of the point resulting from
+// multiplying the public key of the passed ElligatorSwift encoding by the
+// private key.
+func ellswiftECDHXOnly(ellswiftTheirs *[EllswiftPubKeyLen]byte,
+	privKey *btcec.PrivateKey) [32]byte {
+
+	// Either point with the decoded x coordinate results in the same x
+	// coordinate once multiplied, so the y coordinate is chosen
+	// arbitrarily.
+	var pubKey, sharedPoint btcec.JacobianPoint
+	pubKey.X.Set(ellswiftDecode(ellswiftTheirs))
+	btcec.DecompressY(&pubKey.X, false, &pubKey.Y)
+	pubKey.Y.Normalize()
+	pubKey.Z.SetInt(1)
+
+	btcec.ScalarMultNonConst(&privKey.Key, &pubKey, &sharedPoint)
+	sharedPoint.ToAffine()
+	return *sharedPoint.X.Bytes()
+}
+
+// v2ECDH returns the shared secret derived from an x-only ECDH exchange of
+// the ElligatorSwift encoded public keys of both sides of a connection as
+// defined by BIP0324.
+func v2ECDH(privKey *btcec.PrivateKey, ellswiftOurs,
+	ellswiftTheirs *[EllswiftPubKeyLen]byte, initiator bool) *chainhash.Hash {
+
+	ecdhPoint := ellswiftECDHXOnly(ellswiftTheirs, privKey)
+
+	// The encoding of the initiator always comes first.
+	initiatorPub, responderPub := ellswiftOurs, ellswiftTheirs
+	if !initiator {
+		initiatorPub, responderPub = ellswiftTheirs, ellswiftOurs
+	}
+	return chainhash.TaggedHash(ellswiftECDHTag, initiatorPub[:],
+		responderPub[:], ecdhPoint[:])
+}
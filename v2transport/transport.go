@@ -0,0 +1,371 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package v2transport
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"syscall"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// MaxGarbageLen is the maximum number of garbage bytes either side
+	// may send before its garbage terminator.
+	MaxGarbageLen = 4095
+
+	// maxContentsLen is the maximum length of the contents of a packet
+	// which carries a message.
+	maxContentsLen = 1 + wire.CommandSize + wire.MaxBlockPayload
+
+	// v1PrefixLen is the length of the prefix of a v1 version message
+	// which is used to detect v1 peers.
+	v1PrefixLen = 16
+)
+
+var (
+	// ErrGarbageTerminatorNotFound is returned when the remote peer does
+	// not send its garbage terminator within MaxGarbageLen bytes.
+	ErrGarbageTerminatorNotFound = errors.New("garbage terminator not found")
+
+	// ErrPacketTooLarge is returned when a packet exceeds the maximum
+	// allowed length.
+	ErrPacketTooLarge = errors.New("packet exceeds maximum length")
+
+	// ErrV1Peer is returned by the initiator of a connection when the
+	// remote peer closes the connection or starts sending a v1 message
+	// before its public key was received, which means it only supports
+	// the v1 transport protocol.
+	ErrV1Peer = errors.New("remote peer does not support the v2 " +
+		"transport protocol")
+
+	// errNoHandshake is returned when messages are read or written before
+	// the handshake completed.
+	errNoHandshake = errors.New("v2 transport handshake not performed")
+)
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+// Read reads from the underlying reader and counts the bytes read.
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+// Write writes to the underlying writer and counts the bytes written.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
+
+// Transport reads and writes bitcoin messages on a connection using the v2
+// transport protocol defined by BIP0324.  Inbound connections from peers
+// which turn out to use the v1 transport protocol are detected during the
+// handshake, in which case the transport transparently falls back to v1
+// framing.
+type Transport struct {
+	conn      *countingReader
+	r         *bufio.Reader
+	w         *countingWriter
+	btcnet    wire.BitcoinNet
+	initiator bool
+
+	// v1Reader is set once the remote peer is detected to use the v1
+	// transport protocol.  It replays the bytes read during detection
+	// before reading from the connection.
+	v1Reader io.Reader
+
+	cipher *packetCipher
+}
+
+// New returns a new transport on the passed connection.  The initiator flag
+// indicates whether the local peer made the connection.  Handshake must be
+// called before any messages are read or written.
+func New(conn io.ReadWriter, btcnet wire.BitcoinNet, initiator bool) *Transport {
+	r := &countingReader{r: conn}
+	return &Transport{
+		conn:      r,
+		r:         bufio.NewReader(r),
+		w:         &countingWriter{w: conn},
+		btcnet:    btcnet,
+		initiator: initiator,
+	}
+}
+
+// v1Prefix returns the first bytes of a v1 version message for the passed
+// network.
+func v1Prefix(btcnet wire.BitcoinNet) []byte {
+	var prefix [v1PrefixLen]byte
+	binary.LittleEndian.PutUint32(prefix[:4], uint32(btcnet))
+	copy(prefix[4:], wire.CmdVersion)
+	return prefix[:]
+}
+
+// randomGarbage returns a random amount of random bytes to send before the
+// garbage terminator.
+func randomGarbage() ([]byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(MaxGarbageLen+1))
+	if err != nil {
+		return nil, err
+	}
+	garbage := make([]byte, n.Int64())
+	if _, err := rand.Read(garbage); err != nil {
+		return nil, err
+	}
+	return garbage, nil
+}
+
+// Handshake performs the v2 transport handshake.  Both sides send their
+// ElligatorSwift encoded public key followed by garbage, derive the keys of
+// the connection, and then send their garbage terminator followed by a
+// version packet which authenticates the garbage.
+//
+// A responder falls back to the v1 transport protocol when the remote peer
+// starts with a v1 version message instead of a public key, while an
+// initiator returns ErrV1Peer when the remote peer only supports the v1
+// transport protocol so it can be reconnected to using it instead.
+//
+// The number of bytes read and written during the handshake is returned even
+// when it fails.  The bytes of the v1 version message a responder falls back
+// on are not included since they are returned by the first ReadMessage.
+func (t *Transport) Handshake() (int, int, error) {
+	err := t.handshake()
+	bytesRead := t.conn.n - t.r.Buffered()
+	if t.v1Reader != nil {
+		bytesRead -= v1PrefixLen
+	}
+	return bytesRead, t.w.n, err
+}
+
+// isV1Response returns whether the passed error, returned while the initiator
+// reads the public key of the remote peer, and the bytes read so far indicate
+// that the remote peer only supports the v1 transport protocol.  Such peers
+// close the connection once they fail to parse the public key as the header of
+// a v1 message, or they send a v1 message themselves.
+func (t *Transport) isV1Response(read []byte, err error) bool {
+	if len(read) >= 4 && bytes.Equal(read[:4], v1Prefix(t.btcnet)[:4]) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
+
+// handshake performs the v2 transport handshake.  See Handshake for more
+// details.
+func (t *Transport) handshake() error {
+	// The public key of an initiator must not be mistaken for the start of
+	// a v1 version message.
+	privKey, ellswiftOurs, err := ellswiftCreate()
+	for err == nil && t.initiator &&
+		bytes.Equal(ellswiftOurs[:v1PrefixLen], v1Prefix(t.btcnet)) {
+
+		privKey, ellswiftOurs, err = ellswiftCreate()
+	}
+	if err != nil {
+		return err
+	}
+	garbage, err := randomGarbage()
+	if err != nil {
+		return err
+	}
+
+	var ellswiftTheirs [EllswiftPubKeyLen]byte
+	if !t.initiator {
+		// Detect peers using the v1 transport protocol from the
+		// start of their first message.
+		prefix := ellswiftTheirs[:v1PrefixLen]
+		if _, err := io.ReadFull(t.r, prefix); err != nil {
+			return err
+		}
+		if bytes.Equal(prefix, v1Prefix(t.btcnet)) {
+			t.v1Reader = io.MultiReader(bytes.NewReader(prefix), t.r)
+			return nil
+		}
+		_, err := io.ReadFull(t.r, ellswiftTheirs[v1PrefixLen:])
+		if err != nil {
+			return err
+		}
+	}
+
+	// Send our public key followed by the garbage.  The initiator sends it
+	// right away while the responder waits until it knows the remote peer
+	// supports the v2 transport protocol.
+	handshake := make([]byte, 0, EllswiftPubKeyLen+len(garbage)+
+		GarbageTerminatorLen+PacketOverhead)
+	handshake = append(handshake, ellswiftOurs[:]...)
+	handshake = append(handshake, garbage...)
+	if t.initiator {
+		if _, err := t.w.Write(handshake); err != nil {
+			return err
+		}
+		handshake = handshake[:0]
+		n, err := io.ReadFull(t.r, ellswiftTheirs[:])
+		if t.isV1Response(ellswiftTheirs[:n], err) {
+			return ErrV1Peer
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Send the garbage terminator followed by the version packet, which
+	// authenticates the garbage.  The contents of the version packet are
+	// reserved for future extensions and are empty.
+	t.cipher = newPacketCipher(privKey, &ellswiftOurs, &ellswiftTheirs,
+		t.initiator, t.btcnet)
+	handshake = append(handshake, t.cipher.sendGarbageTerminator[:]...)
+	handshake = append(handshake, t.cipher.encrypt(nil, garbage, false)...)
+	if _, err := t.w.Write(handshake); err != nil {
+		return err
+	}
+
+	// Receive the garbage of the remote peer and its version packet,
+	// skipping any decoy packets.  Only the first packet authenticates
+	// the garbage.
+	aad, err := t.readGarbage()
+	if err != nil {
+		return err
+	}
+	for {
+		_, ignore, _, err := t.readPacket(aad)
+		if err != nil {
+			return err
+		}
+		if !ignore {
+			return nil
+		}
+		aad = nil
+	}
+}
+
+// readGarbage reads the garbage sent by the remote peer up to and including
+// its garbage terminator and returns the garbage.
+func (t *Transport) readGarbage() ([]byte, error) {
+	terminator := t.cipher.recvGarbageTerminator[:]
+	garbage := make([]byte, GarbageTerminatorLen,
+		MaxGarbageLen+GarbageTerminatorLen)
+	if _, err := io.ReadFull(t.r, garbage); err != nil {
+		return nil, err
+	}
+	for !bytes.HasSuffix(garbage, terminator) {
+		if len(garbage) == cap(garbage) {
+			return nil, ErrGarbageTerminatorNotFound
+		}
+		b, err := t.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		garbage = append(garbage, b)
+	}
+	return garbage[:len(garbage)-GarbageTerminatorLen], nil
+}
+
+// readPacket reads and decrypts the next packet, authenticating it along with
+// aad.  It returns the contents of the packet, whether it is to be ignored and
+// the number of bytes read.
+func (t *Transport) readPacket(aad []byte) ([]byte, bool, int, error) {
+	var lengthField [lengthFieldLen]byte
+	n, err := io.ReadFull(t.r, lengthField[:])
+	if err != nil {
+		return nil, false, n, err
+	}
+	length := t.cipher.decryptLength(lengthField[:])
+	if length > maxContentsLen {
+		return nil, false, n, ErrPacketTooLarge
+	}
+
+	ciphertext := make([]byte, int(length)+PacketOverhead-lengthFieldLen)
+	read, err := io.ReadFull(t.r, ciphertext)
+	n += read
+	if err != nil {
+		return nil, false, n, err
+	}
+	contents, ignore, err := t.cipher.decrypt(ciphertext, aad)
+	return contents, ignore, n, err
+}
+
+// V1Fallback returns whether the remote peer uses the v1 transport protocol
+// and the transport therefore fell back to it.
+func (t *Transport) V1Fallback() bool {
+	return t.v1Reader != nil
+}
+
+// SessionID returns the id which uniquely identifies the encrypted session.
+// It is only valid once the handshake completed without falling back to the
+// v1 transport protocol.
+func (t *Transport) SessionID() [32]byte {
+	if t.cipher == nil {
+		return [32]byte{}
+	}
+	return t.cipher.sessionID
+}
+
+// ReadMessage reads the next bitcoin message from the connection, skipping
+// decoy packets.  It returns the number of bytes read, the message and its
+// raw payload.
+func (t *Transport) ReadMessage(pver uint32,
+	enc wire.MessageEncoding) (int, wire.Message, []byte, error) {
+
+	if t.v1Reader != nil {
+		return wire.ReadMessageWithEncodingN(t.v1Reader, pver, t.btcnet,
+			enc)
+	}
+	if t.cipher == nil {
+		return 0, nil, nil, errNoHandshake
+	}
+
+	totalBytes := 0
+	for {
+		contents, ignore, n, err := t.readPacket(nil)
+		totalBytes += n
+		if err != nil {
+			return totalBytes, nil, nil, err
+		}
+		if ignore {
+			continue
+		}
+
+		msg, payload, err := wire.DecodeV2Message(contents, pver, enc)
+		return totalBytes, msg, payload, err
+	}
+}
+
+// WriteMessage writes the passed bitcoin message to the connection and
+// returns the number of bytes written.
+func (t *Transport) WriteMessage(msg wire.Message, pver uint32,
+	enc wire.MessageEncoding) (int, error) {
+
+	if t.v1Reader != nil {
+		return wire.WriteMessageWithEncodingN(t.w, msg, pver, t.btcnet,
+			enc)
+	}
+	if t.cipher == nil {
+		return 0, errNoHandshake
+	}
+
+	contents, err := wire.EncodeV2Message(msg, pver, enc)
+	if err != nil {
+		return 0, err
+	}
+	return t.w.Write(t.cipher.encrypt(contents, nil, false))
+}
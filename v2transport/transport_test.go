@@ -0,0 +1,313 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package v2transport
+
+import (
+	"crypto/rand"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// connPair returns both ends of a loopback TCP connection.
+func connPair(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn
+	}()
+
+	outbound, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("unable to dial: %v", err)
+	}
+	inbound, ok := <-accepted
+	if !ok {
+		t.Fatal("unable to accept connection")
+	}
+	t.Cleanup(func() {
+		outbound.Close()
+		inbound.Close()
+	})
+	return outbound, inbound
+}
+
+// TestTransport ensures two v2 transports complete the handshake and exchange
+// messages in both directions, and that the bytes of the handshake are counted.
+func TestTransport(t *testing.T) {
+	t.Parallel()
+
+	outbound, inbound := connPair(t)
+	initiator := New(outbound, wire.MainNet, true)
+	responder := New(inbound, wire.MainNet, false)
+
+	type handshakeResult struct {
+		read, written int
+		err           error
+	}
+	resultChan := make(chan handshakeResult, 1)
+	go func() {
+		read, written, err := responder.Handshake()
+		resultChan <- handshakeResult{read, written, err}
+	}()
+	read, written, err := initiator.Handshake()
+	if err != nil {
+		t.Fatalf("Handshake (initiator): unexpected error: %v", err)
+	}
+	result := <-resultChan
+	if result.err != nil {
+		t.Fatalf("Handshake (responder): unexpected error: %v",
+			result.err)
+	}
+
+	// Both sides send at least their public key, garbage terminator and
+	// version packet, and each side reads what the other side wrote.
+	minLen := EllswiftPubKeyLen + GarbageTerminatorLen + PacketOverhead
+	if written < minLen || result.written < minLen {
+		t.Fatalf("Handshake: wrote %d and %d bytes, want at least %d",
+			written, result.written, minLen)
+	}
+	if read != result.written || result.read != written {
+		t.Fatalf("Handshake: initiator read %d and wrote %d bytes, "+
+			"responder read %d and wrote %d bytes", read, written,
+			result.read, result.written)
+	}
+
+	if initiator.V1Fallback() || responder.V1Fallback() {
+		t.Fatal("V1Fallback: unexpected fallback to v1")
+	}
+	if initiator.SessionID() != responder.SessionID() {
+		t.Fatal("SessionID: mismatched session ids")
+	}
+
+	pver := wire.ProtocolVersion
+	enc := wire.LatestEncoding
+	msgs := []wire.Message{
+		wire.NewMsgPing(1),
+		wire.NewMsgVerAck(),
+		wire.NewMsgSendAddrV2(),
+		wire.NewMsgPong(2),
+	}
+	for _, msg := range msgs {
+		n, err := initiator.WriteMessage(msg, pver, enc)
+		if err != nil {
+			t.Fatalf("WriteMessage: unexpected error: %v", err)
+		}
+		gotN, got, _, err := responder.ReadMessage(pver, enc)
+		if err != nil {
+			t.Fatalf("ReadMessage: unexpected error: %v", err)
+		}
+		if gotN != n {
+			t.Fatalf("ReadMessage: read %d bytes, wrote %d", gotN, n)
+		}
+		if !reflect.DeepEqual(got, msg) {
+			t.Fatalf("ReadMessage: got %v, want %v", got, msg)
+		}
+
+		// Echo the message back to the initiator.
+		if _, err := responder.WriteMessage(got, pver, enc); err != nil {
+			t.Fatalf("WriteMessage: unexpected error: %v", err)
+		}
+		_, got, _, err = initiator.ReadMessage(pver, enc)
+		if err != nil {
+			t.Fatalf("ReadMessage: unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(got, msg) {
+			t.Fatalf("ReadMessage: got %v, want %v", got, msg)
+		}
+	}
+
+	// Decoy packets must be skipped.
+	_, err = outbound.Write(initiator.cipher.encrypt([]byte{1}, nil, true))
+	if err != nil {
+		t.Fatalf("Write: unexpected error: %v", err)
+	}
+	if _, err := initiator.WriteMessage(msgs[0], pver, enc); err != nil {
+		t.Fatalf("WriteMessage: unexpected error: %v", err)
+	}
+	_, got, _, err := responder.ReadMessage(pver, enc)
+	if err != nil {
+		t.Fatalf("ReadMessage: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, msgs[0]) {
+		t.Fatalf("ReadMessage: got %v, want %v", got, msgs[0])
+	}
+}
+
+// TestTransportV1Fallback ensures a responder falls back to the v1 transport
+// protocol for peers which start with a v1 version message.
+func TestTransportV1Fallback(t *testing.T) {
+	t.Parallel()
+
+	outbound, inbound := connPair(t)
+	responder := New(inbound, wire.MainNet, false)
+
+	pver := wire.ProtocolVersion
+	enc := wire.LatestEncoding
+	me := wire.NewNetAddressIPPort(net.ParseIP("127.0.0.1"), 8333, 0)
+	you := wire.NewNetAddressIPPort(net.ParseIP("127.0.0.1"), 8334, 0)
+	version := wire.NewMsgVersion(me, you, 1, 0)
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := wire.WriteMessageWithEncodingN(outbound, version, pver,
+			wire.MainNet, enc)
+		errChan <- err
+	}()
+
+	read, written, err := responder.Handshake()
+	if err != nil {
+		t.Fatalf("Handshake: unexpected error: %v", err)
+	}
+	if read != 0 || written != 0 {
+		t.Fatalf("Handshake: read %d and wrote %d bytes, want none",
+			read, written)
+	}
+	if !responder.V1Fallback() {
+		t.Fatal("V1Fallback: expected fallback to v1")
+	}
+	_, got, _, err := responder.ReadMessage(pver, enc)
+	if err != nil {
+		t.Fatalf("ReadMessage: unexpected error: %v", err)
+	}
+	gotVersion, ok := got.(*wire.MsgVersion)
+	if !ok || gotVersion.Nonce != version.Nonce {
+		t.Fatalf("ReadMessage: got %v, want %v", got, version)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("WriteMessage: unexpected error: %v", err)
+	}
+
+	// Replies use the v1 transport protocol.
+	if _, err := responder.WriteMessage(wire.NewMsgVerAck(), pver, enc); err != nil {
+		t.Fatalf("WriteMessage: unexpected error: %v", err)
+	}
+	_, got, _, err = wire.ReadMessageWithEncodingN(outbound, pver,
+		wire.MainNet, enc)
+	if err != nil {
+		t.Fatalf("ReadMessage: unexpected error: %v", err)
+	}
+	if _, ok := got.(*wire.MsgVerAck); !ok {
+		t.Fatalf("ReadMessage: got %T, want *wire.MsgVerAck", got)
+	}
+}
+
+// TestTransportGarbageLimit ensures the handshake fails when the remote peer
+// does not send its garbage terminator within the maximum garbage length.
+func TestTransportGarbageLimit(t *testing.T) {
+	t.Parallel()
+
+	outbound, inbound := connPair(t)
+	responder := New(inbound, wire.MainNet, false)
+
+	// Send a public key followed by too much garbage.
+	_, ellswiftPub, err := ellswiftCreate()
+	if err != nil {
+		t.Fatalf("ellswiftCreate: unexpected error: %v", err)
+	}
+	garbage := make([]byte, MaxGarbageLen+GarbageTerminatorLen+1)
+	if _, err := rand.Read(garbage); err != nil {
+		t.Fatalf("unable to generate garbage: %v", err)
+	}
+	go outbound.Write(append(ellswiftPub[:], garbage...))
+
+	_, _, err = responder.Handshake()
+	if err != ErrGarbageTerminatorNotFound {
+		t.Fatalf("Handshake: unexpected error: got %v, want %v", err,
+			ErrGarbageTerminatorNotFound)
+	}
+}
+
+// TestTransportV1Peer ensures an initiator only reports that the remote peer
+// doesn't support the v2 transport protocol when the remote peer closes the
+// connection or sends a v1 message before its public key was received.
+func TestTransportV1Peer(t *testing.T) {
+	t.Parallel()
+
+	pver := wire.ProtocolVersion
+	enc := wire.LatestEncoding
+	me := wire.NewNetAddressIPPort(net.ParseIP("127.0.0.1"), 8333, 0)
+	you := wire.NewNetAddressIPPort(net.ParseIP("127.0.0.1"), 8334, 0)
+
+	tests := []struct {
+		name    string
+		respond func(conn net.Conn) error
+		v1Peer  bool
+	}{
+		{
+			// A v1 peer disconnects once it fails to parse the
+			// public key as the header of a v1 message.
+			name: "closes connection",
+			respond: func(conn net.Conn) error {
+				var header [wire.MessageHeaderSize]byte
+				_, err := io.ReadFull(conn, header[:])
+				conn.Close()
+				return err
+			},
+			v1Peer: true,
+		},
+		{
+			name: "sends v1 version",
+			respond: func(conn net.Conn) error {
+				version := wire.NewMsgVersion(me, you, 1, 0)
+				_, err := wire.WriteMessageWithEncodingN(conn,
+					version, pver, wire.MainNet, enc)
+				return err
+			},
+			v1Peer: true,
+		},
+		{
+			// A peer which sends its public key before closing the
+			// connection supports the v2 transport protocol.
+			name: "closes after public key",
+			respond: func(conn net.Conn) error {
+				_, ellswiftPub, err := ellswiftCreate()
+				if err != nil {
+					return err
+				}
+				_, err = conn.Write(ellswiftPub[:])
+				conn.Close()
+				return err
+			},
+			v1Peer: false,
+		},
+	}
+
+	for _, test := range tests {
+		outbound, inbound := connPair(t)
+		initiator := New(outbound, wire.MainNet, true)
+
+		errChan := make(chan error, 1)
+		go func() {
+			errChan <- test.respond(inbound)
+		}()
+
+		_, _, err := initiator.Handshake()
+		if err == nil {
+			t.Fatalf("%s: Handshake: expected error", test.name)
+		}
+		if v1Peer := err == ErrV1Peer; v1Peer != test.v1Peer {
+			t.Fatalf("%s: Handshake: unexpected error: %v",
+				test.name, err)
+		}
+		if err := <-errChan; err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+	}
+}
@@ -59,6 +59,15 @@ const (
 	CmdCFHeaders    = "cfheaders"
 	CmdCFCheckpt    = "cfcheckpt"
 	CmdSendAddrV2   = "sendaddrv2"
+	CmdWTxIdRelay   = "wtxidrelay"
+	CmdSendCmpct    = "sendcmpct"
+	CmdCmpctBlock   = "cmpctblock"
+	CmdGetBlockTxn  = "getblocktxn"
+	CmdBlockTxn     = "blocktxn"
+	CmdSendTxRcncl  = "sendtxrcncl"
+	CmdReqRecon     = "reqrecon"
+	CmdSketch       = "sketch"
+	CmdReconcilDiff = "reconcildiff"
 )
 
 // MessageEncoding represents the wire message encoding format to be used.
@@ -111,6 +120,12 @@ func makeEmptyMessage(command string) (Message, error) {
 	case CmdSendAddrV2:
 		msg = &MsgSendAddrV2{}
 
+	case CmdWTxIdRelay:
+		msg = &MsgWTxIdRelay{}
+
+	case CmdSendTxRcncl:
+		msg = &MsgSendTxRcncl{}
+
 	case CmdGetAddr:
 		msg = &MsgGetAddr{}
 
@@ -195,6 +210,27 @@ func makeEmptyMessage(command string) (Message, error) {
 	case CmdCFCheckpt:
 		msg = &MsgCFCheckpt{}
 
+	case CmdSendCmpct:
+		msg = &MsgSendCmpct{}
+
+	case CmdCmpctBlock:
+		msg = &MsgCmpctBlock{}
+
+	case CmdGetBlockTxn:
+		msg = &MsgGetBlockTxn{}
+
+	case CmdBlockTxn:
+		msg = &MsgBlockTxn{}
+
+	case CmdReqRecon:
+		msg = &MsgReqRecon{}
+
+	case CmdSketch:
+		msg = &MsgSketch{}
+
+	case CmdReconcilDiff:
+		msg = &MsgReconcilDiff{}
+
 	default:
 		return nil, ErrUnknownMessage
 	}
@@ -76,6 +76,18 @@ func TestMessage(t *testing.T) {
 		[]byte("payload"))
 	msgCFHeaders := NewMsgCFHeaders()
 	msgCFCheckpt := NewMsgCFCheckpt(GCSFilterRegular, &chainhash.Hash{}, 0)
+	msgSendCmpct := NewMsgSendCmpct(true, CmpctBlockVersionWitness)
+	msgWTxIdRelay := NewMsgWTxIdRelay()
+	msgCmpctBlock := NewMsgCmpctBlockFromBlock(&blockOne, 0)
+	msgCmpctBlock.ShortIDs = []uint64{1}
+	msgGetBlockTxn := NewMsgGetBlockTxn(&chainhash.Hash{})
+	msgGetBlockTxn.AddIndex(1)
+	msgBlockTxn := NewMsgBlockTxn(&chainhash.Hash{})
+	msgBlockTxn.AddTransaction(NewMsgTx(1))
+	msgSendTxRcncl := NewMsgSendTxRcncl(TxReconciliationProtocolVersion, 1)
+	msgReqRecon := NewMsgReqRecon(10, 8191)
+	msgSketch := NewMsgSketch([]byte{0x01, 0x02, 0x03, 0x04})
+	msgReconcilDiff := NewMsgReconcilDiff(true, []uint32{1, 2})
 
 	tests := []struct {
 		in     Message    // Value to encode
@@ -111,6 +123,15 @@ func TestMessage(t *testing.T) {
 		{msgCFilter, msgCFilter, pver, MainNet, 65},
 		{msgCFHeaders, msgCFHeaders, pver, MainNet, 90},
 		{msgCFCheckpt, msgCFCheckpt, pver, MainNet, 58},
+		{msgSendCmpct, msgSendCmpct, pver, MainNet, 33},
+		{msgWTxIdRelay, msgWTxIdRelay, pver, MainNet, 24},
+		{msgCmpctBlock, msgCmpctBlock, pver, MainNet, 255},
+		{msgGetBlockTxn, msgGetBlockTxn, pver, MainNet, 58},
+		{msgBlockTxn, msgBlockTxn, pver, MainNet, 67},
+		{msgSendTxRcncl, msgSendTxRcncl, pver, MainNet, 36},
+		{msgReqRecon, msgReqRecon, pver, MainNet, 28},
+		{msgSketch, msgSketch, pver, MainNet, 29},
+		{msgReconcilDiff, msgReconcilDiff, pver, MainNet, 34},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -0,0 +1,185 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestBlockTxn tests the MsgBlockTxn API.
+func TestBlockTxn(t *testing.T) {
+	pver := ProtocolVersion
+
+	hash := blockOne.Header.BlockHash()
+	msg := NewMsgBlockTxn(&hash)
+	if !msg.BlockHash.IsEqual(&hash) {
+		t.Errorf("NewMsgBlockTxn: wrong block hash - got %v, want %v",
+			msg.BlockHash, hash)
+	}
+
+	// Ensure the command is expected value.
+	wantCmd := "blocktxn"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgBlockTxn: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(4000000)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure transactions are added properly.
+	tx := blockOne.Transactions[0].Copy()
+	msg.AddTransaction(tx)
+	if !reflect.DeepEqual(msg.Transactions, []*MsgTx{tx}) {
+		t.Errorf("AddTransaction: wrong transactions - got %v, want %v",
+			spew.Sdump(msg.Transactions), spew.Sdump([]*MsgTx{tx}))
+	}
+}
+
+// TestBlockTxnWire tests the MsgBlockTxn wire encode and decode for various
+// message encodings.
+func TestBlockTxnWire(t *testing.T) {
+	hash := chainhash.Hash{0x01}
+
+	baseBlockTxn := NewMsgBlockTxn(&hash)
+	baseBlockTxn.AddTransaction(multiTx)
+	baseBlockTxnEncoded := append(hash[:0:0], hash[:]...)
+	baseBlockTxnEncoded = append(baseBlockTxnEncoded, 0x01)
+	baseBlockTxnEncoded = append(baseBlockTxnEncoded, multiTxEncoded...)
+
+	witnessBlockTxn := NewMsgBlockTxn(&hash)
+	witnessBlockTxn.AddTransaction(multiWitnessTx)
+	witnessBlockTxnEncoded := append(hash[:0:0], hash[:]...)
+	witnessBlockTxnEncoded = append(witnessBlockTxnEncoded, 0x01)
+	witnessBlockTxnEncoded = append(witnessBlockTxnEncoded,
+		multiWitnessTxEncoded...)
+
+	tests := []struct {
+		in   *MsgBlockTxn    // Message to encode
+		out  *MsgBlockTxn    // Expected decoded message
+		buf  []byte          // Wire encoding
+		pver uint32          // Protocol version for wire encoding
+		enc  MessageEncoding // Message encoding format
+	}{
+		// Latest protocol version without witness data.
+		{baseBlockTxn, baseBlockTxn, baseBlockTxnEncoded,
+			ProtocolVersion, BaseEncoding},
+
+		// Latest protocol version with witness data.
+		{witnessBlockTxn, witnessBlockTxn, witnessBlockTxnEncoded,
+			ProtocolVersion, WitnessEncoding},
+
+		// Protocol version BIP0152Version without witness data.
+		{baseBlockTxn, baseBlockTxn, baseBlockTxnEncoded,
+			BIP0152Version, BaseEncoding},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver, test.enc)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgBlockTxn
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver, test.enc)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestBlockTxnWireErrors performs negative tests against wire encode and
+// decode of MsgBlockTxn to confirm error paths work correctly.
+func TestBlockTxnWireErrors(t *testing.T) {
+	pver := ProtocolVersion
+	pverNoCmpctBlocks := BIP0152Version - 1
+	wireErr := &MessageError{}
+
+	hash := chainhash.Hash{0x01}
+	baseBlockTxn := NewMsgBlockTxn(&hash)
+	baseBlockTxn.AddTransaction(multiTx)
+	baseBlockTxnEncoded := append(hash[:0:0], hash[:]...)
+	baseBlockTxnEncoded = append(baseBlockTxnEncoded, 0x01)
+	baseBlockTxnEncoded = append(baseBlockTxnEncoded, multiTxEncoded...)
+
+	// Message that forces an error by having more than the max allowed
+	// transactions.
+	maxBlockTxnEncoded := append(hash[:0:0], hash[:]...)
+	maxBlockTxnEncoded = append(maxBlockTxnEncoded, 0xfe, 0x82, 0x1a,
+		0x06, 0x00)
+
+	tests := []struct {
+		in       *MsgBlockTxn // Value to encode
+		buf      []byte       // Wire encoding
+		pver     uint32       // Protocol version for wire encoding
+		max      int          // Max size of fixed buffer to induce errors
+		writeErr error        // Expected write error
+		readErr  error        // Expected read error
+	}{
+		// Force error in block hash.
+		{baseBlockTxn, baseBlockTxnEncoded, pver, 0, io.ErrShortWrite, io.EOF},
+		// Force error in number of transactions.
+		{baseBlockTxn, baseBlockTxnEncoded, pver, 32, io.ErrShortWrite, io.EOF},
+		// Force error in transactions.
+		{baseBlockTxn, baseBlockTxnEncoded, pver, 33, io.ErrShortWrite, io.EOF},
+		// Force error due to unsupported protocol version.
+		{baseBlockTxn, baseBlockTxnEncoded, pverNoCmpctBlocks, 33, wireErr, wireErr},
+		// Force error with greater than max transactions.
+		{nil, maxBlockTxnEncoded, pver, 37, nil, wireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		if test.in != nil {
+			w := newFixedWriter(test.max)
+			err := test.in.BtcEncode(w, test.pver, BaseEncoding)
+			if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		var msg MsgBlockTxn
+		r := newFixedReader(test.max, test.buf)
+		err := msg.BtcDecode(r, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+	}
+}
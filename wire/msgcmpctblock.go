@@ -0,0 +1,286 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"github.com/aead/siphash"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+const (
+	// ShortTxIDSize is the number of bytes of a short transaction id
+	// within a cmpctblock message.
+	ShortTxIDSize = 6
+
+	// shortTxIDMask is the mask applied to the SipHash of a transaction
+	// hash to obtain its short transaction id.
+	shortTxIDMask = 1<<(ShortTxIDSize*8) - 1
+)
+
+// ShortTxIDKey is the SipHash-2-4 key used to calculate the short transaction
+// ids of a compact block.
+type ShortTxIDKey [siphash.KeySize]byte
+
+// ShortTxID returns the short transaction id of the transaction with the
+// passed hash as defined by BIP0152.  For compact blocks of version
+// CmpctBlockVersionWitness, the hash is the witness hash of the transaction.
+func (key *ShortTxIDKey) ShortTxID(hash *chainhash.Hash) uint64 {
+	return siphash.Sum64(hash[:], (*[siphash.KeySize]byte)(key)) &
+		shortTxIDMask
+}
+
+// PrefilledTx houses a transaction that is sent in its entirety within a
+// cmpctblock message along with its index within the block.
+type PrefilledTx struct {
+	Index uint32
+	Tx    *MsgTx
+}
+
+// MsgCmpctBlock implements the Message interface and represents a bitcoin
+// cmpctblock message.  It is used to relay a block as its header along with
+// short ids of its transactions, which the receiving peer can use to
+// reconstruct the block from the transactions it already knows about, as
+// well as the transactions it likely does not know about such as the
+// coinbase transaction.
+//
+// The indexes of the prefilled transactions are absolute indexes within the
+// block and must be in ascending order.  They are differentially encoded on
+// the wire as defined by BIP0152.
+//
+// This message was not added until protocol versions starting with
+// BIP0152Version.
+type MsgCmpctBlock struct {
+	Header       BlockHeader
+	Nonce        uint64
+	ShortIDs     []uint64
+	PrefilledTxs []*PrefilledTx
+}
+
+// TxCount returns the total number of transactions in the block the message
+// represents.
+func (msg *MsgCmpctBlock) TxCount() int {
+	return len(msg.ShortIDs) + len(msg.PrefilledTxs)
+}
+
+// ShortTxIDKey returns the key used to calculate the short transaction ids of
+// the message, which is derived from the block header and nonce.
+func (msg *MsgCmpctBlock) ShortTxIDKey() ShortTxIDKey {
+	var buf bytes.Buffer
+	buf.Grow(MaxBlockHeaderPayload + 8)
+	_ = writeBlockHeader(&buf, 0, &msg.Header)
+	_ = writeElement(&buf, msg.Nonce)
+
+	var key ShortTxIDKey
+	hash := sha256.Sum256(buf.Bytes())
+	copy(key[:], hash[:])
+	return key
+}
+
+// readDiffIndexes reads count differentially encoded indexes from r and calls
+// the passed function with each absolute index.  An error is returned when an
+// index exceeds the maximum number of transactions of a block.
+func readDiffIndexes(r io.Reader, pver uint32, count uint64, funcName string,
+	f func(index uint32) error) error {
+
+	var next uint64
+	for i := uint64(0); i < count; i++ {
+		diff, err := ReadVarInt(r, pver)
+		if err != nil {
+			return err
+		}
+		index := next + diff
+		if diff >= maxTxPerBlock || index >= maxTxPerBlock {
+			str := fmt.Sprintf("transaction index too large for a "+
+				"block [index %d, max %d]", index, maxTxPerBlock-1)
+			return messageError(funcName, str)
+		}
+		if err := f(uint32(index)); err != nil {
+			return err
+		}
+		next = index + 1
+	}
+	return nil
+}
+
+// writeDiffIndex writes the passed absolute index differentially encoded
+// relative to the previous index to w.  The next index to encode relative to
+// is updated accordingly.  An error is returned when the indexes are not in
+// ascending order.
+func writeDiffIndex(w io.Writer, pver uint32, index uint32, next *uint64,
+	funcName string) error {
+
+	if uint64(index) < *next {
+		str := fmt.Sprintf("transaction indexes are not in ascending "+
+			"order [index %d, min %d]", index, *next)
+		return messageError(funcName, str)
+	}
+	if err := WriteVarInt(w, pver, uint64(index)-*next); err != nil {
+		return err
+	}
+	*next = uint64(index) + 1
+	return nil
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	if pver < BIP0152Version {
+		str := fmt.Sprintf("cmpctblock message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgCmpctBlock.BtcDecode", str)
+	}
+
+	err := readBlockHeader(r, pver, &msg.Header)
+	if err != nil {
+		return err
+	}
+	if err := readElement(r, &msg.Nonce); err != nil {
+		return err
+	}
+
+	// Prevent more short ids than could possibly fit into a block.
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	if count > maxTxPerBlock {
+		str := fmt.Sprintf("too many short ids to fit into a block "+
+			"[count %d, max %d]", count, maxTxPerBlock)
+		return messageError("MsgCmpctBlock.BtcDecode", str)
+	}
+	msg.ShortIDs = make([]uint64, 0, count)
+	var buf [8]byte
+	for i := uint64(0); i < count; i++ {
+		if _, err := io.ReadFull(r, buf[:ShortTxIDSize]); err != nil {
+			return err
+		}
+		msg.ShortIDs = append(msg.ShortIDs, littleEndian.Uint64(buf[:]))
+	}
+
+	count, err = ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	if count+uint64(len(msg.ShortIDs)) > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions to fit into a block "+
+			"[count %d, max %d]", count+uint64(len(msg.ShortIDs)),
+			maxTxPerBlock)
+		return messageError("MsgCmpctBlock.BtcDecode", str)
+	}
+	msg.PrefilledTxs = make([]*PrefilledTx, 0, count)
+	return readDiffIndexes(r, pver, count, "MsgCmpctBlock.BtcDecode",
+		func(index uint32) error {
+			tx := MsgTx{}
+			if err := tx.BtcDecode(r, pver, enc); err != nil {
+				return err
+			}
+			msg.PrefilledTxs = append(msg.PrefilledTxs,
+				&PrefilledTx{Index: index, Tx: &tx})
+			return nil
+		})
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if pver < BIP0152Version {
+		str := fmt.Sprintf("cmpctblock message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgCmpctBlock.BtcEncode", str)
+	}
+
+	err := writeBlockHeader(w, pver, &msg.Header)
+	if err != nil {
+		return err
+	}
+	if err := writeElement(w, msg.Nonce); err != nil {
+		return err
+	}
+
+	if err := WriteVarInt(w, pver, uint64(len(msg.ShortIDs))); err != nil {
+		return err
+	}
+	var buf [8]byte
+	for _, shortID := range msg.ShortIDs {
+		littleEndian.PutUint64(buf[:], shortID)
+		if _, err := w.Write(buf[:ShortTxIDSize]); err != nil {
+			return err
+		}
+	}
+
+	err = WriteVarInt(w, pver, uint64(len(msg.PrefilledTxs)))
+	if err != nil {
+		return err
+	}
+	var next uint64
+	for _, prefilled := range msg.PrefilledTxs {
+		err := writeDiffIndex(w, pver, prefilled.Index, &next,
+			"MsgCmpctBlock.BtcEncode")
+		if err != nil {
+			return err
+		}
+		if err := prefilled.Tx.BtcEncode(w, pver, enc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgCmpctBlock) Command() string {
+	return CmdCmpctBlock
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) MaxPayloadLength(pver uint32) uint32 {
+	// A compact block can't be larger than the block it represents.
+	return MaxBlockPayload
+}
+
+// NewMsgCmpctBlock returns a new bitcoin cmpctblock message that conforms to
+// the Message interface using the passed block header and nonce.  See
+// MsgCmpctBlock for details.
+func NewMsgCmpctBlock(header *BlockHeader, nonce uint64) *MsgCmpctBlock {
+	return &MsgCmpctBlock{
+		Header: *header,
+		Nonce:  nonce,
+	}
+}
+
+// NewMsgCmpctBlockFromBlock returns a new bitcoin cmpctblock message for the
+// passed block using the passed nonce.  The coinbase transaction as well as
+// the transactions at the passed indexes within the block, which must be in
+// ascending order, are prefilled while the remaining transactions are
+// represented by their short ids.
+func NewMsgCmpctBlockFromBlock(block *MsgBlock, nonce uint64,
+	prefilledIndexes ...uint32) *MsgCmpctBlock {
+
+	msg := NewMsgCmpctBlock(&block.Header, nonce)
+	key := msg.ShortTxIDKey()
+	for i, tx := range block.Transactions {
+		prefilled := i == 0
+		if len(prefilledIndexes) > 0 && prefilledIndexes[0] == uint32(i) {
+			prefilledIndexes = prefilledIndexes[1:]
+			prefilled = true
+		}
+		if prefilled {
+			msg.PrefilledTxs = append(msg.PrefilledTxs,
+				&PrefilledTx{Index: uint32(i), Tx: tx})
+			continue
+		}
+
+		hash := tx.WitnessHash()
+		msg.ShortIDs = append(msg.ShortIDs, key.ShortTxID(&hash))
+	}
+	return msg
+}
@@ -0,0 +1,291 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestCmpctBlock tests the MsgCmpctBlock API.
+func TestCmpctBlock(t *testing.T) {
+	pver := ProtocolVersion
+
+	nonce := uint64(0x0807060504030201)
+	msg := NewMsgCmpctBlock(&blockOne.Header, nonce)
+	if !reflect.DeepEqual(&msg.Header, &blockOne.Header) {
+		t.Errorf("NewMsgCmpctBlock: wrong header - got %v, want %v",
+			spew.Sdump(&msg.Header), spew.Sdump(&blockOne.Header))
+	}
+	if msg.Nonce != nonce {
+		t.Errorf("NewMsgCmpctBlock: wrong nonce - got %v, want %v",
+			msg.Nonce, nonce)
+	}
+
+	// Ensure the command is expected value.
+	wantCmd := "cmpctblock"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgCmpctBlock: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(4000000)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure the short id key is the first 16 bytes of the single SHA256
+	// of the serialized header followed by the nonce.
+	preimage := append([]byte(nil), blockOneBytes[:80]...)
+	preimage = append(preimage, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+		0x08)
+	wantKey := sha256.Sum256(preimage)
+	if key := msg.ShortTxIDKey(); !bytes.Equal(key[:], wantKey[:16]) {
+		t.Errorf("ShortTxIDKey: wrong key - got %x, want %x", key,
+			wantKey[:16])
+	}
+
+	// Ensure compact blocks created from blocks prefill the coinbase and
+	// requested transactions while the others are represented by the
+	// short ids of their witness hashes.
+	block := MsgBlock{Header: blockOne.Header}
+	block.AddTransaction(blockOne.Transactions[0])
+	block.AddTransaction(multiTx)
+	block.AddTransaction(multiWitnessTx)
+	block.AddTransaction(multiTx)
+	msg = NewMsgCmpctBlockFromBlock(&block, nonce, 2)
+	if msg.TxCount() != len(block.Transactions) {
+		t.Fatalf("TxCount: wrong count - got %v, want %v",
+			msg.TxCount(), len(block.Transactions))
+	}
+	wantPrefilled := []*PrefilledTx{
+		{Index: 0, Tx: blockOne.Transactions[0]},
+		{Index: 2, Tx: multiWitnessTx},
+	}
+	if !reflect.DeepEqual(msg.PrefilledTxs, wantPrefilled) {
+		t.Errorf("NewMsgCmpctBlockFromBlock: wrong prefilled "+
+			"transactions - got %v, want %v",
+			spew.Sdump(msg.PrefilledTxs), spew.Sdump(wantPrefilled))
+	}
+	key := msg.ShortTxIDKey()
+	hash := multiTx.WitnessHash()
+	wantShortID := key.ShortTxID(&hash)
+	if wantShortID>>(ShortTxIDSize*8) != 0 {
+		t.Errorf("ShortTxID: short id %x exceeds %d bytes", wantShortID,
+			ShortTxIDSize)
+	}
+	wantShortIDs := []uint64{wantShortID, wantShortID}
+	if !reflect.DeepEqual(msg.ShortIDs, wantShortIDs) {
+		t.Errorf("NewMsgCmpctBlockFromBlock: wrong short ids - got %x, "+
+			"want %x", msg.ShortIDs, wantShortIDs)
+	}
+
+	// Ensure a different nonce results in different short ids.
+	other := NewMsgCmpctBlockFromBlock(&block, nonce+1)
+	if other.ShortIDs[0] == wantShortID {
+		t.Errorf("NewMsgCmpctBlockFromBlock: short id %x did not "+
+			"change with the nonce", wantShortID)
+	}
+}
+
+// TestCmpctBlockWire tests the MsgCmpctBlock wire encode and decode for
+// various message encodings.
+func TestCmpctBlockWire(t *testing.T) {
+	msg := NewMsgCmpctBlock(&blockOne.Header, 0x0807060504030201)
+	msg.ShortIDs = []uint64{0x060504030201, 0xffffffffffff}
+	msg.PrefilledTxs = []*PrefilledTx{
+		{Index: 0, Tx: blockOne.Transactions[0]},
+		{Index: 3, Tx: multiWitnessTx},
+	}
+	encodedPrefix := append([]byte(nil), blockOneBytes[:80]...)
+	encodedPrefix = append(encodedPrefix,
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, // Nonce
+		0x02,                               // Varint for number of short ids
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, // Short id
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, // Short id
+		0x02, // Varint for number of prefilled transactions
+		0x00, // Index 0
+	)
+	encodedPrefix = append(encodedPrefix, blockOneBytes[81:]...)
+	encodedPrefix = append(encodedPrefix, 0x02) // Index 3
+
+	baseEncoded := append(encodedPrefix[:len(encodedPrefix):len(encodedPrefix)],
+		multiTxEncoded...)
+	witnessEncoded := append(encodedPrefix[:len(encodedPrefix):len(encodedPrefix)],
+		multiWitnessTxEncoded...)
+
+	// The witness data is stripped when using the base encoding.
+	baseMsg := *msg
+	baseMsg.PrefilledTxs = []*PrefilledTx{
+		msg.PrefilledTxs[0],
+		{Index: 3, Tx: multiTx},
+	}
+
+	tests := []struct {
+		in   *MsgCmpctBlock  // Message to encode
+		out  *MsgCmpctBlock  // Expected decoded message
+		buf  []byte          // Wire encoding
+		pver uint32          // Protocol version for wire encoding
+		enc  MessageEncoding // Message encoding format
+	}{
+		// Latest protocol version with witness data.
+		{msg, msg, witnessEncoded, ProtocolVersion, WitnessEncoding},
+
+		// Latest protocol version without witness data.
+		{&baseMsg, &baseMsg, baseEncoded, ProtocolVersion, BaseEncoding},
+
+		// Protocol version BIP0152Version with witness data.
+		{msg, msg, witnessEncoded, BIP0152Version, WitnessEncoding},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver, test.enc)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgCmpctBlock
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver, test.enc)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestCmpctBlockWireErrors performs negative tests against wire encode and
+// decode of MsgCmpctBlock to confirm error paths work correctly.
+func TestCmpctBlockWireErrors(t *testing.T) {
+	pver := ProtocolVersion
+	pverNoCmpctBlocks := BIP0152Version - 1
+	wireErr := &MessageError{}
+
+	baseCmpctBlock := NewMsgCmpctBlock(&blockOne.Header, 1)
+	baseCmpctBlock.ShortIDs = []uint64{0x060504030201}
+	baseCmpctBlock.PrefilledTxs = []*PrefilledTx{
+		{Index: 0, Tx: blockOne.Transactions[0]},
+	}
+	baseCmpctBlockEncoded := append([]byte(nil), blockOneBytes[:80]...)
+	baseCmpctBlockEncoded = append(baseCmpctBlockEncoded,
+		0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Nonce
+		0x01,                               // Varint for number of short ids
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, // Short id
+		0x01, // Varint for number of prefilled transactions
+		0x00, // Index 0
+	)
+	baseCmpctBlockEncoded = append(baseCmpctBlockEncoded,
+		blockOneBytes[81:]...)
+
+	// Message with prefilled transactions which are not in ascending
+	// order.
+	unorderedCmpctBlock := NewMsgCmpctBlock(&blockOne.Header, 1)
+	unorderedCmpctBlock.PrefilledTxs = []*PrefilledTx{
+		{Index: 1, Tx: blockOne.Transactions[0]},
+		{Index: 0, Tx: blockOne.Transactions[0]},
+	}
+
+	// Message that forces an error by having more than the max allowed
+	// short ids.
+	maxShortIDsEncoded := append([]byte(nil), blockOneBytes[:88]...)
+	maxShortIDsEncoded = append(maxShortIDsEncoded, 0xfe, 0x82, 0x1a,
+		0x06, 0x00)
+
+	// Message that forces an error by having more than the max allowed
+	// transactions in total.
+	maxTxnsEncoded := append([]byte(nil), baseCmpctBlockEncoded[:95]...)
+	maxTxnsEncoded = append(maxTxnsEncoded, 0xfe, 0x81, 0x1a, 0x06, 0x00)
+
+	// Message that forces an error by having a prefilled transaction index
+	// which exceeds the max allowed transactions in a block.
+	maxIndexEncoded := append([]byte(nil), baseCmpctBlockEncoded[:95]...)
+	maxIndexEncoded = append(maxIndexEncoded, 0x01, 0xfe, 0x81, 0x1a,
+		0x06, 0x00)
+
+	tests := []struct {
+		in       *MsgCmpctBlock // Value to encode
+		buf      []byte         // Wire encoding
+		pver     uint32         // Protocol version for wire encoding
+		max      int            // Max size of fixed buffer to induce errors
+		writeErr error          // Expected write error
+		readErr  error          // Expected read error
+	}{
+		// Force error in header.
+		{baseCmpctBlock, baseCmpctBlockEncoded, pver, 0, io.ErrShortWrite, io.EOF},
+		// Force error in nonce.
+		{baseCmpctBlock, baseCmpctBlockEncoded, pver, 80, io.ErrShortWrite, io.EOF},
+		// Force error in number of short ids.
+		{baseCmpctBlock, baseCmpctBlockEncoded, pver, 88, io.ErrShortWrite, io.EOF},
+		// Force error in short ids.
+		{baseCmpctBlock, baseCmpctBlockEncoded, pver, 89, io.ErrShortWrite, io.EOF},
+		// Force error in number of prefilled transactions.
+		{baseCmpctBlock, baseCmpctBlockEncoded, pver, 95, io.ErrShortWrite, io.EOF},
+		// Force error in prefilled transaction index.
+		{baseCmpctBlock, baseCmpctBlockEncoded, pver, 96, io.ErrShortWrite, io.EOF},
+		// Force error in prefilled transaction.
+		{baseCmpctBlock, baseCmpctBlockEncoded, pver, 97, io.ErrShortWrite, io.EOF},
+		// Force error due to unsupported protocol version.
+		{baseCmpctBlock, baseCmpctBlockEncoded, pverNoCmpctBlocks, 97, wireErr, wireErr},
+		// Force error with prefilled transactions out of order.
+		{unorderedCmpctBlock, nil, pver, 1000, wireErr, nil},
+		// Force error with greater than max short ids.
+		{nil, maxShortIDsEncoded, pver, 93, nil, wireErr},
+		// Force error with greater than max transactions.
+		{nil, maxTxnsEncoded, pver, 100, nil, wireErr},
+		// Force error with a prefilled transaction index that is too
+		// large.
+		{nil, maxIndexEncoded, pver, 101, nil, wireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		if test.in != nil {
+			w := newFixedWriter(test.max)
+			err := test.in.BtcEncode(w, test.pver, BaseEncoding)
+			if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		if test.buf != nil {
+			var msg MsgCmpctBlock
+			r := newFixedReader(test.max, test.buf)
+			err := msg.BtcDecode(r, test.pver, BaseEncoding)
+			if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.readErr)
+				continue
+			}
+		}
+	}
+}
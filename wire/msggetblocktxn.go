@@ -0,0 +1,115 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// MsgGetBlockTxn implements the Message interface and represents a bitcoin
+// getblocktxn message.  It is used to request the transactions of a block at
+// the specified indexes which could not be found when reconstructing the block
+// from a cmpctblock message.  The response is a blocktxn message.
+//
+// The indexes are absolute indexes within the block and must be in ascending
+// order.  They are differentially encoded on the wire as defined by BIP0152.
+//
+// This message was not added until protocol versions starting with
+// BIP0152Version.
+type MsgGetBlockTxn struct {
+	BlockHash chainhash.Hash
+	Indexes   []uint32
+}
+
+// AddIndex adds the passed transaction index to the message.
+func (msg *MsgGetBlockTxn) AddIndex(index uint32) {
+	msg.Indexes = append(msg.Indexes, index)
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGetBlockTxn) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	if pver < BIP0152Version {
+		str := fmt.Sprintf("getblocktxn message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgGetBlockTxn.BtcDecode", str)
+	}
+
+	if err := readElement(r, &msg.BlockHash); err != nil {
+		return err
+	}
+
+	// Prevent more indexes than there could possibly be transactions in a
+	// block.
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	if count > maxTxPerBlock {
+		str := fmt.Sprintf("too many transaction indexes for a block "+
+			"[count %d, max %d]", count, maxTxPerBlock)
+		return messageError("MsgGetBlockTxn.BtcDecode", str)
+	}
+	msg.Indexes = make([]uint32, 0, count)
+	return readDiffIndexes(r, pver, count, "MsgGetBlockTxn.BtcDecode",
+		func(index uint32) error {
+			msg.Indexes = append(msg.Indexes, index)
+			return nil
+		})
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGetBlockTxn) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if pver < BIP0152Version {
+		str := fmt.Sprintf("getblocktxn message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgGetBlockTxn.BtcEncode", str)
+	}
+
+	if err := writeElement(w, &msg.BlockHash); err != nil {
+		return err
+	}
+	if err := WriteVarInt(w, pver, uint64(len(msg.Indexes))); err != nil {
+		return err
+	}
+	var next uint64
+	for _, index := range msg.Indexes {
+		err := writeDiffIndex(w, pver, index, &next,
+			"MsgGetBlockTxn.BtcEncode")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGetBlockTxn) Command() string {
+	return CmdGetBlockTxn
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGetBlockTxn) MaxPayloadLength(pver uint32) uint32 {
+	// Block hash + num indexes (varInt) + max allowed indexes, each of
+	// which is a varint that is at most 5 bytes given the maximum number
+	// of transactions in a block.
+	return chainhash.HashSize + MaxVarIntPayload + maxTxPerBlock*5
+}
+
+// NewMsgGetBlockTxn returns a new bitcoin getblocktxn message that conforms to
+// the Message interface using the passed block hash.  See MsgGetBlockTxn for
+// details.
+func NewMsgGetBlockTxn(blockHash *chainhash.Hash) *MsgGetBlockTxn {
+	return &MsgGetBlockTxn{
+		BlockHash: *blockHash,
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestGetBlockTxn tests the MsgGetBlockTxn API.
+func TestGetBlockTxn(t *testing.T) {
+	pver := ProtocolVersion
+
+	hash := blockOne.Header.BlockHash()
+	msg := NewMsgGetBlockTxn(&hash)
+	if !msg.BlockHash.IsEqual(&hash) {
+		t.Errorf("NewMsgGetBlockTxn: wrong block hash - got %v, want %v",
+			msg.BlockHash, hash)
+	}
+
+	// Ensure the command is expected value.
+	wantCmd := "getblocktxn"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgGetBlockTxn: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	// Block hash + num indexes (varInt) + max allowed indexes.
+	wantPayload := uint32(2000046)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure indexes are added properly.
+	msg.AddIndex(1)
+	msg.AddIndex(5)
+	if !reflect.DeepEqual(msg.Indexes, []uint32{1, 5}) {
+		t.Errorf("AddIndex: wrong indexes - got %v, want %v",
+			msg.Indexes, []uint32{1, 5})
+	}
+}
+
+// TestGetBlockTxnWire tests the MsgGetBlockTxn wire encode and decode for
+// various numbers of transaction indexes.
+func TestGetBlockTxnWire(t *testing.T) {
+	hash := blockOne.Header.BlockHash()
+
+	noIndexes := NewMsgGetBlockTxn(&hash)
+	noIndexesDecoded := NewMsgGetBlockTxn(&hash)
+	noIndexesDecoded.Indexes = []uint32{}
+	noIndexesEncoded := append(hash[:0:0], hash[:]...)
+	noIndexesEncoded = append(noIndexesEncoded, 0x00)
+
+	multiIndexes := NewMsgGetBlockTxn(&hash)
+	multiIndexes.Indexes = []uint32{1, 2, 5, 300}
+	multiIndexesEncoded := append(hash[:0:0], hash[:]...)
+	multiIndexesEncoded = append(multiIndexesEncoded,
+		0x04,             // Varint for number of indexes
+		0x01,             // Index 1
+		0x00,             // Index 2
+		0x02,             // Index 5
+		0xfd, 0x26, 0x01, // Index 300
+	)
+
+	tests := []struct {
+		in   *MsgGetBlockTxn // Message to encode
+		out  *MsgGetBlockTxn // Expected decoded message
+		buf  []byte          // Wire encoding
+		pver uint32          // Protocol version for wire encoding
+	}{
+		// Latest protocol version with no indexes.
+		{noIndexes, noIndexesDecoded, noIndexesEncoded, ProtocolVersion},
+
+		// Latest protocol version with multiple indexes.
+		{multiIndexes, multiIndexes, multiIndexesEncoded, ProtocolVersion},
+
+		// Protocol version BIP0152Version with multiple indexes.
+		{multiIndexes, multiIndexes, multiIndexesEncoded, BIP0152Version},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgGetBlockTxn
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestGetBlockTxnWireErrors performs negative tests against wire encode and
+// decode of MsgGetBlockTxn to confirm error paths work correctly.
+func TestGetBlockTxnWireErrors(t *testing.T) {
+	pver := ProtocolVersion
+	pverNoCmpctBlocks := BIP0152Version - 1
+	wireErr := &MessageError{}
+
+	hash := chainhash.Hash{0x01}
+	baseGetBlockTxn := NewMsgGetBlockTxn(&hash)
+	baseGetBlockTxn.Indexes = []uint32{1, 3}
+	baseGetBlockTxnEncoded := append(hash[:0:0], hash[:]...)
+	baseGetBlockTxnEncoded = append(baseGetBlockTxnEncoded, 0x02, 0x01,
+		0x01)
+
+	// Message with indexes which are not in ascending order.
+	unorderedGetBlockTxn := NewMsgGetBlockTxn(&hash)
+	unorderedGetBlockTxn.Indexes = []uint32{3, 1}
+
+	// Message with an index which exceeds the maximum number of
+	// transactions in a block.
+	overflowGetBlockTxnEncoded := append(hash[:0:0], hash[:]...)
+	overflowGetBlockTxnEncoded = append(overflowGetBlockTxnEncoded, 0x02,
+		0xfe, 0x80, 0x1a, 0x06, 0x00, 0x00)
+
+	// Message that forces an error by having more than the max allowed
+	// indexes.
+	maxGetBlockTxnEncoded := append(hash[:0:0], hash[:]...)
+	maxGetBlockTxnEncoded = append(maxGetBlockTxnEncoded, 0xfe, 0x82,
+		0x1a, 0x06, 0x00)
+
+	tests := []struct {
+		in       *MsgGetBlockTxn // Value to encode
+		buf      []byte          // Wire encoding
+		pver     uint32          // Protocol version for wire encoding
+		max      int             // Max size of fixed buffer to induce errors
+		writeErr error           // Expected write error
+		readErr  error           // Expected read error
+	}{
+		// Force error in block hash.
+		{baseGetBlockTxn, baseGetBlockTxnEncoded, pver, 0, io.ErrShortWrite, io.EOF},
+		// Force error in number of indexes.
+		{baseGetBlockTxn, baseGetBlockTxnEncoded, pver, 32, io.ErrShortWrite, io.EOF},
+		// Force error in indexes.
+		{baseGetBlockTxn, baseGetBlockTxnEncoded, pver, 33, io.ErrShortWrite, io.EOF},
+		// Force error due to unsupported protocol version.
+		{baseGetBlockTxn, baseGetBlockTxnEncoded, pverNoCmpctBlocks, 35, wireErr, wireErr},
+		// Force error with indexes out of order.
+		{unorderedGetBlockTxn, baseGetBlockTxnEncoded, pver, 35, wireErr, nil},
+		// Force error with an index that is too large.
+		{baseGetBlockTxn, overflowGetBlockTxnEncoded, pver, 39, nil, wireErr},
+		// Force error with greater than max indexes.
+		{baseGetBlockTxn, maxGetBlockTxnEncoded, pver, 37, nil, wireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.writeErr {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		var msg MsgGetBlockTxn
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.readErr {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.readErr)
+				continue
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+// MsgReconcilDiff implements the Message interface and represents a bitcoin
+// reconcildiff message.  It concludes a round of set reconciliation (BIP0330)
+// and tells the receiver whether the sketch it sent was decoded successfully
+// along with the short ids of the transactions in the set of the receiver the
+// sender is missing.  When decoding failed, the receiver is expected to
+// announce all transactions of its set instead.
+//
+// This message was not added until protocol versions starting with
+// TxReconciliationVersion.
+type MsgReconcilDiff struct {
+	Success     bool
+	AskShortIDs []uint32
+}
+
+// AddShortID adds a short id to the list of requested transactions.
+func (msg *MsgReconcilDiff) AddShortID(shortID uint32) error {
+	if len(msg.AskShortIDs)+1 > MaxSketchCapacity {
+		str := fmt.Sprintf("too many short ids for message [max %v]",
+			MaxSketchCapacity)
+		return messageError("MsgReconcilDiff.AddShortID", str)
+	}
+
+	msg.AskShortIDs = append(msg.AskShortIDs, shortID)
+	return nil
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgReconcilDiff) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	if pver < TxReconciliationVersion {
+		str := fmt.Sprintf("reconcildiff message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgReconcilDiff.BtcDecode", str)
+	}
+
+	if err := readElement(r, &msg.Success); err != nil {
+		return err
+	}
+
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+
+	// Limit to max short ids per message.
+	if count > MaxSketchCapacity {
+		str := fmt.Sprintf("too many short ids for message "+
+			"[count %v, max %v]", count, MaxSketchCapacity)
+		return messageError("MsgReconcilDiff.BtcDecode", str)
+	}
+
+	msg.AskShortIDs = make([]uint32, 0, count)
+	for i := uint64(0); i < count; i++ {
+		shortID, err := binarySerializer.Uint32(r, littleEndian)
+		if err != nil {
+			return err
+		}
+		msg.AskShortIDs = append(msg.AskShortIDs, shortID)
+	}
+
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgReconcilDiff) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if pver < TxReconciliationVersion {
+		str := fmt.Sprintf("reconcildiff message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgReconcilDiff.BtcEncode", str)
+	}
+
+	count := len(msg.AskShortIDs)
+	if count > MaxSketchCapacity {
+		str := fmt.Sprintf("too many short ids for message "+
+			"[count %v, max %v]", count, MaxSketchCapacity)
+		return messageError("MsgReconcilDiff.BtcEncode", str)
+	}
+
+	if err := writeElement(w, msg.Success); err != nil {
+		return err
+	}
+	if err := WriteVarInt(w, pver, uint64(count)); err != nil {
+		return err
+	}
+	for _, shortID := range msg.AskShortIDs {
+		err := binarySerializer.PutUint32(w, littleEndian, shortID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgReconcilDiff) Command() string {
+	return CmdReconcilDiff
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgReconcilDiff) MaxPayloadLength(pver uint32) uint32 {
+	// Success flag 1 byte + varint for the number of short ids + the short
+	// ids 4 bytes each.
+	return 1 + uint32(VarIntSerializeSize(MaxSketchCapacity)) +
+		MaxSketchCapacity*4
+}
+
+// NewMsgReconcilDiff returns a new bitcoin reconcildiff message that conforms
+// to the Message interface.  See MsgReconcilDiff for details.
+func NewMsgReconcilDiff(success bool, askShortIDs []uint32) *MsgReconcilDiff {
+	return &MsgReconcilDiff{
+		Success:     success,
+		AskShortIDs: askShortIDs,
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestReconcilDiff tests the MsgReconcilDiff API against the latest protocol version.
+func TestReconcilDiff(t *testing.T) {
+	pver := ProtocolVersion
+
+	msg := NewMsgReconcilDiff(true, nil)
+	if !msg.Success || len(msg.AskShortIDs) != 0 {
+		t.Errorf("NewMsgReconcilDiff: wrong fields - got %v",
+			spew.Sdump(msg))
+	}
+
+	// Ensure the command is expected value.
+	wantCmd := "reconcildiff"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgReconcilDiff: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(32772)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure short ids are added properly.
+	if err := msg.AddShortID(1); err != nil {
+		t.Errorf("AddShortID: %v", err)
+	}
+	if !reflect.DeepEqual(msg.AskShortIDs, []uint32{1}) {
+		t.Errorf("AddShortID: wrong short ids - got %v, want %v",
+			msg.AskShortIDs, []uint32{1})
+	}
+
+	// Ensure adding more than the max allowed short ids per message returns
+	// an error.
+	for i := 0; i < MaxSketchCapacity; i++ {
+		err := msg.AddShortID(uint32(i))
+		if err != nil && i != MaxSketchCapacity-1 {
+			t.Errorf("AddShortID #%d: unexpected error: %v", i, err)
+		}
+		if err == nil && i == MaxSketchCapacity-1 {
+			t.Errorf("AddShortID: expected error on too many " +
+				"short ids not received")
+		}
+	}
+}
+
+// TestReconcilDiffWire tests the MsgReconcilDiff wire encode and decode for various
+// protocol versions.
+func TestReconcilDiffWire(t *testing.T) {
+	tests := []struct {
+		in   MsgReconcilDiff // Message to encode
+		out  MsgReconcilDiff // Expected decoded message
+		buf  []byte          // Wire encoding
+		pver uint32          // Protocol version for wire encoding
+	}{
+		// Latest protocol version with a failed reconciliation.
+		{
+			MsgReconcilDiff{false, []uint32{}},
+			MsgReconcilDiff{false, []uint32{}},
+			[]byte{0x00, 0x00},
+			ProtocolVersion,
+		},
+
+		// Latest protocol version with requested short ids.
+		{
+			MsgReconcilDiff{true, []uint32{0x04030201, 0x08070605}},
+			MsgReconcilDiff{true, []uint32{0x04030201, 0x08070605}},
+			[]byte{
+				0x01,                   // Success
+				0x02,                   // Varint for number of short ids
+				0x01, 0x02, 0x03, 0x04, // Short id
+				0x05, 0x06, 0x07, 0x08, // Short id
+			},
+			ProtocolVersion,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgReconcilDiff
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestReconcilDiffWireErrors performs negative tests against wire encode and
+// decode of MsgReconcilDiff to confirm error paths work correctly.
+func TestReconcilDiffWireErrors(t *testing.T) {
+	pver := ProtocolVersion
+	pverNoRecon := TxReconciliationVersion - 1
+	wireErr := &MessageError{}
+
+	baseReconcilDiff := NewMsgReconcilDiff(true, []uint32{0x04030201})
+	baseReconcilDiffEncoded := []byte{0x01, 0x01, 0x01, 0x02, 0x03, 0x04}
+
+	// Message which forces an error by having more than the max allowed
+	// short ids.
+	maxReconcilDiff := NewMsgReconcilDiff(true,
+		make([]uint32, MaxSketchCapacity+1))
+	maxReconcilDiffEncoded := []byte{
+		0x01,             // Success
+		0xfd, 0x01, 0x20, // Varint for number of short ids
+	}
+
+	tests := []struct {
+		in       *MsgReconcilDiff // Value to encode
+		buf      []byte           // Wire encoding
+		pver     uint32           // Protocol version for wire encoding
+		max      int              // Max size of fixed buffer to induce errors
+		writeErr error            // Expected write error
+		readErr  error            // Expected read error
+	}{
+		// Latest protocol version with intentional read/write errors.
+		// Force error in success flag.
+		{baseReconcilDiff, baseReconcilDiffEncoded, pver, 0, io.ErrShortWrite, io.EOF},
+		// Force error in short id count.
+		{baseReconcilDiff, baseReconcilDiffEncoded, pver, 1, io.ErrShortWrite, io.EOF},
+		// Force error in short id.
+		{baseReconcilDiff, baseReconcilDiffEncoded, pver, 2, io.ErrShortWrite, io.EOF},
+		// Force error due to unsupported protocol version.
+		{baseReconcilDiff, baseReconcilDiffEncoded, pverNoRecon, 6, wireErr, wireErr},
+		// Force error with greater than max short ids.
+		{maxReconcilDiff, maxReconcilDiffEncoded, pver, 4, wireErr, wireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.writeErr {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		var msg MsgReconcilDiff
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.readErr {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.readErr)
+				continue
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+// MsgReqRecon implements the Message interface and represents a bitcoin
+// reqrecon message.  It is used by the peer which initiated the connection to
+// request a round of set reconciliation (BIP0330).  It carries the size of the
+// reconciliation set of the sender along with the coefficient q, scaled by
+// 2^15-1, which the receiver uses to estimate the capacity of the sketch it
+// replies with.
+//
+// This message was not added until protocol versions starting with
+// TxReconciliationVersion.
+type MsgReqRecon struct {
+	SetSize uint16
+	Q       uint16
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgReqRecon) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	if pver < TxReconciliationVersion {
+		str := fmt.Sprintf("reqrecon message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgReqRecon.BtcDecode", str)
+	}
+
+	var err error
+	msg.SetSize, err = binarySerializer.Uint16(r, littleEndian)
+	if err != nil {
+		return err
+	}
+	msg.Q, err = binarySerializer.Uint16(r, littleEndian)
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgReqRecon) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if pver < TxReconciliationVersion {
+		str := fmt.Sprintf("reqrecon message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgReqRecon.BtcEncode", str)
+	}
+
+	err := binarySerializer.PutUint16(w, littleEndian, msg.SetSize)
+	if err != nil {
+		return err
+	}
+	return binarySerializer.PutUint16(w, littleEndian, msg.Q)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgReqRecon) Command() string {
+	return CmdReqRecon
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgReqRecon) MaxPayloadLength(pver uint32) uint32 {
+	// Set size 2 bytes + q 2 bytes.
+	return 4
+}
+
+// NewMsgReqRecon returns a new bitcoin reqrecon message that conforms to the
+// Message interface.  See MsgReqRecon for details.
+func NewMsgReqRecon(setSize, q uint16) *MsgReqRecon {
+	return &MsgReqRecon{
+		SetSize: setSize,
+		Q:       q,
+	}
+}
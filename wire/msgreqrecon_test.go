@@ -0,0 +1,163 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestReqRecon tests the MsgReqRecon API against the latest protocol version.
+func TestReqRecon(t *testing.T) {
+	pver := ProtocolVersion
+
+	msg := NewMsgReqRecon(10, 8191)
+	if msg.SetSize != 10 || msg.Q != 8191 {
+		t.Errorf("NewMsgReqRecon: wrong fields - got %v",
+			spew.Sdump(msg))
+	}
+
+	// Ensure the command is expected value.
+	wantCmd := "reqrecon"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgReqRecon: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(4)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+}
+
+// TestReqReconWire tests the MsgReqRecon wire encode and decode for various
+// protocol versions.
+func TestReqReconWire(t *testing.T) {
+	tests := []struct {
+		in   MsgReqRecon // Message to encode
+		out  MsgReqRecon // Expected decoded message
+		buf  []byte      // Wire encoding
+		pver uint32      // Protocol version for wire encoding
+	}{
+		// Latest protocol version.
+		{
+			MsgReqRecon{0x0201, 0x0403},
+			MsgReqRecon{0x0201, 0x0403},
+			[]byte{
+				0x01, 0x02, // Set size
+				0x03, 0x04, // Q
+			},
+			ProtocolVersion,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgReqRecon
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestReqReconWireErrors performs negative tests against wire encode and
+// decode of MsgReqRecon to confirm error paths work correctly.
+func TestReqReconWireErrors(t *testing.T) {
+	pver := ProtocolVersion
+	pverNoRecon := TxReconciliationVersion - 1
+	wireErr := &MessageError{}
+
+	baseReqRecon := NewMsgReqRecon(0x0201, 0x0403)
+	baseReqReconEncoded := []byte{0x01, 0x02, 0x03, 0x04}
+
+	tests := []struct {
+		in       *MsgReqRecon // Value to encode
+		buf      []byte       // Wire encoding
+		pver     uint32       // Protocol version for wire encoding
+		max      int          // Max size of fixed buffer to induce errors
+		writeErr error        // Expected write error
+		readErr  error        // Expected read error
+	}{
+		// Latest protocol version with intentional read/write errors.
+		// Force error in set size.
+		{baseReqRecon, baseReqReconEncoded, pver, 0, io.ErrShortWrite, io.EOF},
+		// Force error in q.
+		{baseReqRecon, baseReqReconEncoded, pver, 2, io.ErrShortWrite, io.EOF},
+		// Force error due to unsupported protocol version.
+		{baseReqRecon, baseReqReconEncoded, pverNoRecon, 4, wireErr, wireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.writeErr {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		var msg MsgReqRecon
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.readErr {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.readErr)
+				continue
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+// CmpctBlockVersionWitness is the version of compact blocks defined by
+// BIP0152 which uses the witness transaction hashes for the short transaction
+// ids and serializes transactions including their witness data.  It is the only
+// version of compact blocks that is supported.
+const CmpctBlockVersionWitness uint64 = 2
+
+// MsgSendCmpct implements the Message interface and represents a bitcoin
+// sendcmpct message.  It is used to signal support for compact blocks of the
+// specified version and whether or not the peer wishes new blocks to be
+// announced with a cmpctblock message rather than an inv or headers message,
+// which is referred to as high-bandwidth mode.
+//
+// This message was not added until protocol versions starting with
+// BIP0152Version.
+type MsgSendCmpct struct {
+	AnnounceUsingCmpctBlock bool
+	CmpctBlockVersion       uint64
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgSendCmpct) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	if pver < BIP0152Version {
+		str := fmt.Sprintf("sendcmpct message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgSendCmpct.BtcDecode", str)
+	}
+
+	return readElements(r, &msg.AnnounceUsingCmpctBlock,
+		&msg.CmpctBlockVersion)
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgSendCmpct) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if pver < BIP0152Version {
+		str := fmt.Sprintf("sendcmpct message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgSendCmpct.BtcEncode", str)
+	}
+
+	return writeElements(w, msg.AnnounceUsingCmpctBlock,
+		msg.CmpctBlockVersion)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgSendCmpct) Command() string {
+	return CmdSendCmpct
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgSendCmpct) MaxPayloadLength(pver uint32) uint32 {
+	// Announce flag 1 byte + version 8 bytes.
+	return 9
+}
+
+// NewMsgSendCmpct returns a new bitcoin sendcmpct message that conforms to the
+// Message interface.  See MsgSendCmpct for details.
+func NewMsgSendCmpct(announce bool, version uint64) *MsgSendCmpct {
+	return &MsgSendCmpct{
+		AnnounceUsingCmpctBlock: announce,
+		CmpctBlockVersion:       version,
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+// TxReconciliationProtocolVersion is the version of transaction relay through
+// set reconciliation defined by BIP0330.  It is the only version that is
+// supported.
+const TxReconciliationProtocolVersion uint32 = 1
+
+// MsgSendTxRcncl implements the Message interface and represents a bitcoin
+// sendtxrcncl message.  It is used to signal support for relaying transactions
+// through set reconciliation (BIP0330) of the specified version along with the
+// salt the sender contributes to the short transaction ids of the connection.
+// It must be sent after the version message and before the verack message.
+//
+// This message was not added until protocol versions starting with
+// TxReconciliationVersion.
+type MsgSendTxRcncl struct {
+	Version uint32
+	Salt    uint64
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgSendTxRcncl) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	if pver < TxReconciliationVersion {
+		str := fmt.Sprintf("sendtxrcncl message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgSendTxRcncl.BtcDecode", str)
+	}
+
+	return readElements(r, &msg.Version, &msg.Salt)
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgSendTxRcncl) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if pver < TxReconciliationVersion {
+		str := fmt.Sprintf("sendtxrcncl message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgSendTxRcncl.BtcEncode", str)
+	}
+
+	return writeElements(w, msg.Version, msg.Salt)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgSendTxRcncl) Command() string {
+	return CmdSendTxRcncl
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgSendTxRcncl) MaxPayloadLength(pver uint32) uint32 {
+	// Version 4 bytes + salt 8 bytes.
+	return 12
+}
+
+// NewMsgSendTxRcncl returns a new bitcoin sendtxrcncl message that conforms to
+// the Message interface.  See MsgSendTxRcncl for details.
+func NewMsgSendTxRcncl(version uint32, salt uint64) *MsgSendTxRcncl {
+	return &MsgSendTxRcncl{
+		Version: version,
+		Salt:    salt,
+	}
+}
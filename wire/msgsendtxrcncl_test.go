@@ -0,0 +1,166 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestSendTxRcncl tests the MsgSendTxRcncl API against the latest protocol version.
+func TestSendTxRcncl(t *testing.T) {
+	pver := ProtocolVersion
+
+	msg := NewMsgSendTxRcncl(TxReconciliationProtocolVersion, 0x0102)
+	if msg.Version != TxReconciliationProtocolVersion || msg.Salt != 0x0102 {
+		t.Errorf("NewMsgSendTxRcncl: wrong fields - got %v",
+			spew.Sdump(msg))
+	}
+
+	// Ensure the command is expected value.
+	wantCmd := "sendtxrcncl"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgSendTxRcncl: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(12)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+}
+
+// TestSendTxRcnclWire tests the MsgSendTxRcncl wire encode and decode for various
+// protocol versions.
+func TestSendTxRcnclWire(t *testing.T) {
+	tests := []struct {
+		in   MsgSendTxRcncl // Message to encode
+		out  MsgSendTxRcncl // Expected decoded message
+		buf  []byte         // Wire encoding
+		pver uint32         // Protocol version for wire encoding
+	}{
+		// Latest protocol version.
+		{
+			MsgSendTxRcncl{1, 0x0807060504030201},
+			MsgSendTxRcncl{1, 0x0807060504030201},
+			[]byte{
+				0x01, 0x00, 0x00, 0x00, // Version
+				0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, // Salt
+			},
+			ProtocolVersion,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgSendTxRcncl
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestSendTxRcnclWireErrors performs negative tests against wire encode and
+// decode of MsgSendTxRcncl to confirm error paths work correctly.
+func TestSendTxRcnclWireErrors(t *testing.T) {
+	pver := ProtocolVersion
+	pverNoRecon := TxReconciliationVersion - 1
+	wireErr := &MessageError{}
+
+	baseSendTxRcncl := NewMsgSendTxRcncl(1, 0x0807060504030201)
+	baseSendTxRcnclEncoded := []byte{
+		0x01, 0x00, 0x00, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06,
+		0x07, 0x08,
+	}
+
+	tests := []struct {
+		in       *MsgSendTxRcncl // Value to encode
+		buf      []byte          // Wire encoding
+		pver     uint32          // Protocol version for wire encoding
+		max      int             // Max size of fixed buffer to induce errors
+		writeErr error           // Expected write error
+		readErr  error           // Expected read error
+	}{
+		// Latest protocol version with intentional read/write errors.
+		// Force error in version.
+		{baseSendTxRcncl, baseSendTxRcnclEncoded, pver, 0, io.ErrShortWrite, io.EOF},
+		// Force error in salt.
+		{baseSendTxRcncl, baseSendTxRcnclEncoded, pver, 4, io.ErrShortWrite, io.EOF},
+		// Force error due to unsupported protocol version.
+		{baseSendTxRcncl, baseSendTxRcnclEncoded, pverNoRecon, 12, wireErr, wireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.writeErr {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		var msg MsgSendTxRcncl
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.readErr {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.readErr)
+				continue
+			}
+		}
+	}
+}
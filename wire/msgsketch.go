@@ -0,0 +1,88 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	// MaxSketchCapacity is the maximum capacity of a sketch a sketch
+	// message can carry.
+	MaxSketchCapacity = 2 << 12
+
+	// SketchElementSize is the number of bytes every element of a
+	// serialized sketch occupies.
+	SketchElementSize = 4
+)
+
+// MsgSketch implements the Message interface and represents a bitcoin sketch
+// message.  It is sent in response to a reqrecon message and carries the
+// serialized sketch of the reconciliation set of the sender, which the
+// receiver uses to compute the difference of both sets (BIP0330).
+//
+// This message was not added until protocol versions starting with
+// TxReconciliationVersion.
+type MsgSketch struct {
+	SketchData []byte
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgSketch) BtcDecode(r io.Reader, pver uint32, enc MessageEncoding) error {
+	if pver < TxReconciliationVersion {
+		str := fmt.Sprintf("sketch message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgSketch.BtcDecode", str)
+	}
+
+	var err error
+	msg.SketchData, err = ReadVarBytes(r, pver,
+		MaxSketchCapacity*SketchElementSize, "sketch data")
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgSketch) BtcEncode(w io.Writer, pver uint32, enc MessageEncoding) error {
+	if pver < TxReconciliationVersion {
+		str := fmt.Sprintf("sketch message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgSketch.BtcEncode", str)
+	}
+
+	size := len(msg.SketchData)
+	if size > MaxSketchCapacity*SketchElementSize {
+		str := fmt.Sprintf("sketch data too large for message "+
+			"[size %v, max %v]", size,
+			MaxSketchCapacity*SketchElementSize)
+		return messageError("MsgSketch.BtcEncode", str)
+	}
+
+	return WriteVarBytes(w, pver, msg.SketchData)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgSketch) Command() string {
+	return CmdSketch
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgSketch) MaxPayloadLength(pver uint32) uint32 {
+	// Varint for the sketch data length + the sketch data.
+	return uint32(VarIntSerializeSize(MaxSketchCapacity*SketchElementSize)) +
+		MaxSketchCapacity*SketchElementSize
+}
+
+// NewMsgSketch returns a new bitcoin sketch message that conforms to the
+// Message interface.  See MsgSketch for details.
+func NewMsgSketch(sketchData []byte) *MsgSketch {
+	return &MsgSketch{
+		SketchData: sketchData,
+	}
+}
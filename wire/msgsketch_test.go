@@ -0,0 +1,180 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestSketch tests the MsgSketch API against the latest protocol version.
+func TestSketch(t *testing.T) {
+	pver := ProtocolVersion
+
+	msg := NewMsgSketch([]byte{0x01, 0x02, 0x03, 0x04})
+	if !bytes.Equal(msg.SketchData, []byte{0x01, 0x02, 0x03, 0x04}) {
+		t.Errorf("NewMsgSketch: wrong fields - got %v",
+			spew.Sdump(msg))
+	}
+
+	// Ensure the command is expected value.
+	wantCmd := "sketch"
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgSketch: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	wantPayload := uint32(32771)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+}
+
+// TestSketchWire tests the MsgSketch wire encode and decode for various
+// protocol versions.
+func TestSketchWire(t *testing.T) {
+	tests := []struct {
+		in   MsgSketch // Message to encode
+		out  MsgSketch // Expected decoded message
+		buf  []byte    // Wire encoding
+		pver uint32    // Protocol version for wire encoding
+	}{
+		// Latest protocol version with an empty sketch.
+		{
+			MsgSketch{[]byte{}},
+			MsgSketch{[]byte{}},
+			[]byte{0x00},
+			ProtocolVersion,
+		},
+
+		// Latest protocol version with a sketch of capacity 2.
+		{
+			MsgSketch{[]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}},
+			MsgSketch{[]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}},
+			[]byte{
+				0x08, // Varint for length of sketch data
+				// Sketch data
+				0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+			},
+			ProtocolVersion,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgSketch
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver, BaseEncoding)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestSketchWireErrors performs negative tests against wire encode and
+// decode of MsgSketch to confirm error paths work correctly.
+func TestSketchWireErrors(t *testing.T) {
+	pver := ProtocolVersion
+	pverNoRecon := TxReconciliationVersion - 1
+	wireErr := &MessageError{}
+
+	baseSketch := NewMsgSketch([]byte{0x01, 0x02, 0x03, 0x04})
+	baseSketchEncoded := []byte{0x04, 0x01, 0x02, 0x03, 0x04}
+
+	// Sketch which exceeds the max capacity.
+	maxSketch := NewMsgSketch(make([]byte, MaxSketchCapacity*SketchElementSize+1))
+	maxSketchEncoded := []byte{
+		0xfd, 0x01, 0x80, // Varint for length of sketch data
+	}
+
+	tests := []struct {
+		in       *MsgSketch // Value to encode
+		buf      []byte     // Wire encoding
+		pver     uint32     // Protocol version for wire encoding
+		max      int        // Max size of fixed buffer to induce errors
+		writeErr error      // Expected write error
+		readErr  error      // Expected read error
+	}{
+		// Latest protocol version with intentional read/write errors.
+		// Force error in sketch data length.
+		{baseSketch, baseSketchEncoded, pver, 0, io.ErrShortWrite, io.EOF},
+		// Force error in sketch data.
+		{baseSketch, baseSketchEncoded, pver, 1, io.ErrShortWrite, io.EOF},
+		// Force error due to unsupported protocol version.
+		{baseSketch, baseSketchEncoded, pverNoRecon, 5, wireErr, wireErr},
+		// Force error with greater than max sketch capacity.
+		{maxSketch, maxSketchEncoded, pver, 3, wireErr, wireErr},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode to wire format.
+		w := newFixedWriter(test.max)
+		err := test.in.BtcEncode(w, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.writeErr) {
+			t.Errorf("BtcEncode #%d wrong error got: %v, want: %v",
+				i, err, test.writeErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.writeErr {
+				t.Errorf("BtcEncode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.writeErr)
+				continue
+			}
+		}
+
+		// Decode from wire format.
+		var msg MsgSketch
+		r := newFixedReader(test.max, test.buf)
+		err = msg.BtcDecode(r, test.pver, BaseEncoding)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.readErr) {
+			t.Errorf("BtcDecode #%d wrong error got: %v, want: %v",
+				i, err, test.readErr)
+			continue
+		}
+
+		// For errors which are not of type MessageError, check them for
+		// equality.
+		if _, ok := err.(*MessageError); !ok {
+			if err != test.readErr {
+				t.Errorf("BtcDecode #%d wrong error got: %v, "+
+					"want: %v", i, err, test.readErr)
+				continue
+			}
+		}
+	}
+}
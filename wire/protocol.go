@@ -52,12 +52,30 @@ const (
 	// feefilter message.
 	FeeFilterVersion uint32 = 70013
 
+	// BIP0152Version is the protocol version which added the sendcmpct,
+	// cmpctblock, getblocktxn and blocktxn messages used for compact block
+	// relay.
+	BIP0152Version uint32 = 70014
+
 	// AddrV2Version is the protocol version which added two new messages.
 	// sendaddrv2 is sent during the version-verack handshake and signals
 	// support for sending and receiving the addrv2 message. In the future,
 	// new messages that occur during the version-verack handshake will not
 	// come with a protocol version bump.
 	AddrV2Version uint32 = 70016
+
+	// WTxIdRelayVersion is the protocol version which added the wtxidrelay
+	// message that is sent during the version-verack handshake to signal
+	// support for announcing and requesting transactions by their witness
+	// hash as defined by BIP0339.
+	WTxIdRelayVersion uint32 = 70016
+
+	// TxReconciliationVersion is the protocol version which added the
+	// sendtxrcncl, reqrecon, sketch and reconcildiff messages used to relay
+	// transactions through set reconciliation as defined by BIP0330.  Like
+	// the wtxid relay it builds upon, support for it is negotiated during
+	// the version-verack handshake.
+	TxReconciliationVersion uint32 = 70016
 )
 
 // ServiceFlag identifies services supported by a bitcoin peer.
@@ -93,6 +111,10 @@ const (
 	// SFNode2X is a flag used to indicate a peer is running the Segwit2X
 	// software.
 	SFNode2X
+
+	// SFNodeP2PV2 is a flag used to indicate a peer supports the v2
+	// encrypted transport protocol (BIP0324).
+	SFNodeP2PV2 ServiceFlag = 1 << 11
 )
 
 // Map of service flags back to their constant names for pretty printing.
@@ -105,6 +127,7 @@ var sfStrings = map[ServiceFlag]string{
 	SFNodeBit5:    "SFNodeBit5",
 	SFNodeCF:      "SFNodeCF",
 	SFNode2X:      "SFNode2X",
+	SFNodeP2PV2:   "SFNodeP2PV2",
 }
 
 // orderedSFStrings is an ordered list of service flags from highest to
@@ -118,6 +141,7 @@ var orderedSFStrings = []ServiceFlag{
 	SFNodeBit5,
 	SFNodeCF,
 	SFNode2X,
+	SFNodeP2PV2,
 }
 
 // String returns the ServiceFlag in human-readable form.
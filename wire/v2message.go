@@ -0,0 +1,180 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// v2LongCommandID is the message type byte that indicates the command of a
+// message in the v2 transport protocol is encoded as a full CommandSize
+// string rather than as a single byte short message id.
+const v2LongCommandID = 0
+
+// v2ShortCommandIDs houses the short message ids defined by BIP0324 for the
+// v2 transport protocol.  The index of each command is its short message id,
+// with the first entry reserved to indicate a full command follows.
+var v2ShortCommandIDs = []string{
+	"",
+	CmdAddr,
+	CmdBlock,
+	CmdBlockTxn,
+	CmdCmpctBlock,
+	CmdFeeFilter,
+	CmdFilterAdd,
+	CmdFilterClear,
+	CmdFilterLoad,
+	CmdGetBlocks,
+	CmdGetBlockTxn,
+	CmdGetData,
+	CmdGetHeaders,
+	CmdHeaders,
+	CmdInv,
+	CmdMemPool,
+	CmdMerkleBlock,
+	CmdNotFound,
+	CmdPing,
+	CmdPong,
+	CmdSendCmpct,
+	CmdTx,
+	CmdGetCFilters,
+	CmdCFilter,
+	CmdGetCFHeaders,
+	CmdCFHeaders,
+	CmdGetCFCheckpt,
+	CmdCFCheckpt,
+	CmdAddrV2,
+}
+
+// v2CommandShortIDs maps commands back to their v2 transport short message
+// ids.
+var v2CommandShortIDs = func() map[string]byte {
+	ids := make(map[string]byte, len(v2ShortCommandIDs))
+	for id, cmd := range v2ShortCommandIDs[1:] {
+		ids[cmd] = byte(id + 1)
+	}
+	return ids
+}()
+
+// EncodeV2Message returns the contents of a v2 transport (BIP0324) packet
+// carrying the passed message.  The contents consist of the message type,
+// which is either a single byte short message id or a zero byte followed by
+// the full command, and the encoded message payload.
+func EncodeV2Message(msg Message, pver uint32, enc MessageEncoding) ([]byte, error) {
+	cmd := msg.Command()
+	if len(cmd) > CommandSize {
+		str := fmt.Sprintf("command [%s] is too long [max %v]",
+			cmd, CommandSize)
+		return nil, messageError("EncodeV2Message", str)
+	}
+
+	var bw bytes.Buffer
+	if id, ok := v2CommandShortIDs[cmd]; ok {
+		bw.WriteByte(id)
+	} else {
+		var command [CommandSize]byte
+		copy(command[:], cmd)
+		bw.WriteByte(v2LongCommandID)
+		bw.Write(command[:])
+	}
+	hdrLen := bw.Len()
+
+	if err := msg.BtcEncode(&bw, pver, enc); err != nil {
+		return nil, err
+	}
+
+	// Enforce maximum message payload based on the message type.
+	lenp := bw.Len() - hdrLen
+	if lenp > MaxMessagePayload {
+		str := fmt.Sprintf("message payload is too large - encoded "+
+			"%d bytes, but maximum message payload is %d bytes",
+			lenp, MaxMessagePayload)
+		return nil, messageError("EncodeV2Message", str)
+	}
+	mpl := msg.MaxPayloadLength(pver)
+	if uint32(lenp) > mpl {
+		str := fmt.Sprintf("message payload is too large - encoded "+
+			"%d bytes, but maximum message payload size for "+
+			"messages of type [%s] is %d.", lenp, cmd, mpl)
+		return nil, messageError("EncodeV2Message", str)
+	}
+
+	return bw.Bytes(), nil
+}
+
+// DecodeV2Message parses the contents of a v2 transport (BIP0324) packet and
+// returns the message along with its raw payload.  ErrUnknownMessage is
+// returned for unknown short message ids and commands so callers can treat
+// them the same as unknown messages of the v1 transport.
+func DecodeV2Message(contents []byte, pver uint32, enc MessageEncoding) (Message, []byte, error) {
+	if len(contents) == 0 {
+		str := "missing message type"
+		return nil, nil, messageError("DecodeV2Message", str)
+	}
+
+	var command string
+	payload := contents[1:]
+	switch id := contents[0]; {
+	case id == v2LongCommandID:
+		if len(payload) < CommandSize {
+			str := fmt.Sprintf("command is truncated - got %d "+
+				"bytes, but commands are %d bytes",
+				len(payload), CommandSize)
+			return nil, nil, messageError("DecodeV2Message", str)
+		}
+
+		// The command must consist of printable ASCII characters
+		// followed only by zero padding.
+		rawCommand := payload[:CommandSize]
+		payload = payload[CommandSize:]
+		cmdLen := bytes.IndexByte(rawCommand, 0)
+		if cmdLen == -1 {
+			cmdLen = CommandSize
+		}
+		for i, b := range rawCommand {
+			if (i < cmdLen && (b < ' ' || b > '~')) ||
+				(i >= cmdLen && b != 0) {
+
+				str := fmt.Sprintf("invalid command %v",
+					rawCommand)
+				return nil, nil, messageError("DecodeV2Message",
+					str)
+			}
+		}
+		command = string(rawCommand[:cmdLen])
+
+	case int(id) < len(v2ShortCommandIDs):
+		command = v2ShortCommandIDs[id]
+
+	default:
+		return nil, nil, ErrUnknownMessage
+	}
+
+	// Create struct of appropriate message type based on the command.
+	msg, err := makeEmptyMessage(command)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Check for maximum length based on the message type.
+	mpl := msg.MaxPayloadLength(pver)
+	if uint32(len(payload)) > mpl {
+		str := fmt.Sprintf("payload exceeds max length - packet "+
+			"contains %v bytes, but max payload size for "+
+			"messages of type [%v] is %v.", len(payload), command,
+			mpl)
+		return nil, nil, messageError("DecodeV2Message", str)
+	}
+
+	// Unmarshal message.  NOTE: This must be a *bytes.Buffer since the
+	// MsgVersion BtcDecode function requires it.
+	pr := bytes.NewBuffer(payload)
+	if err := msg.BtcDecode(pr, pver, enc); err != nil {
+		return nil, nil, err
+	}
+
+	return msg, payload, nil
+}
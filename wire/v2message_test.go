@@ -0,0 +1,122 @@
+// Copyright (c) 2024 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestV2Message tests the encoding and decoding of messages carried by v2
+// transport packets.
+func TestV2Message(t *testing.T) {
+	pver := ProtocolVersion
+	enc := LatestEncoding
+
+	pingContents := []byte{
+		18,                                             // Short id
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, // Nonce
+	}
+	verAckContents := []byte{
+		0,                                  // Long command
+		'v', 'e', 'r', 'a', 'c', 'k', 0, 0, // Command
+		0, 0, 0, 0,
+	}
+
+	tests := []struct {
+		in  Message // Value to encode
+		buf []byte  // Encoded packet contents
+	}{
+		{NewMsgPing(0x0807060504030201), pingContents},
+		{NewMsgVerAck(), verAckContents},
+		{NewMsgAddrV2(), []byte{28, 0}},
+		{NewMsgSendCmpct(true, 2), []byte{
+			20,                                             // Short id
+			0x01,                                           // High-bandwidth
+			0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Version
+		}},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		contents, err := EncodeV2Message(test.in, pver, enc)
+		if err != nil {
+			t.Errorf("EncodeV2Message #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(contents, test.buf) {
+			t.Errorf("EncodeV2Message #%d\n got: %s want: %s", i,
+				spew.Sdump(contents), spew.Sdump(test.buf))
+			continue
+		}
+
+		msg, payload, err := DecodeV2Message(test.buf, pver, enc)
+		if err != nil {
+			t.Errorf("DecodeV2Message #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(msg, test.in) {
+			t.Errorf("DecodeV2Message #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.in))
+			continue
+		}
+		if !bytes.Equal(payload, test.buf[len(test.buf)-len(payload):]) {
+			t.Errorf("DecodeV2Message #%d: unexpected payload %x",
+				i, payload)
+		}
+	}
+
+	// Every short message id must round trip through its command.
+	for id, cmd := range v2ShortCommandIDs[1:] {
+		if got := v2CommandShortIDs[cmd]; got != byte(id+1) {
+			t.Errorf("short id for %s: got %d, want %d", cmd, got,
+				id+1)
+		}
+	}
+}
+
+// TestV2MessageErrors performs negative tests against the decoding of v2
+// transport packet contents.
+func TestV2MessageErrors(t *testing.T) {
+	pver := ProtocolVersion
+	enc := LatestEncoding
+
+	tests := []struct {
+		name string
+		buf  []byte
+		err  error
+	}{
+		{"empty contents", nil, &MessageError{}},
+		{"truncated command", []byte{0, 'p', 'i', 'n', 'g'},
+			&MessageError{}},
+		{"non-zero padding", []byte{
+			0, 'v', 'e', 'r', 'a', 'c', 'k', 0, 'x', 0, 0, 0, 0,
+		}, &MessageError{}},
+		{"non-printable command", []byte{
+			0, 'v', 'e', 'r', 0x01, 'c', 'k', 0, 0, 0, 0, 0, 0,
+		}, &MessageError{}},
+		{"unknown short id", []byte{29}, ErrUnknownMessage},
+		{"unknown command", []byte{
+			0, 'b', 'o', 'g', 'u', 's', 0, 0, 0, 0, 0, 0, 0,
+		}, ErrUnknownMessage},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for _, test := range tests {
+		_, _, err := DecodeV2Message(test.buf, pver, enc)
+		if reflect.TypeOf(err) != reflect.TypeOf(test.err) {
+			t.Errorf("DecodeV2Message (%s): wrong error - got %v, "+
+				"want %v", test.name, err, test.err)
+			continue
+		}
+		if _, ok := test.err.(*MessageError); !ok && err != test.err {
+			t.Errorf("DecodeV2Message (%s): wrong error - got %v, "+
+				"want %v", test.name, err, test.err)
+		}
+	}
+}